@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/server"
+)
+
+const (
+	defaultCheckTimeout  = 5 * time.Second
+	defaultProbeInterval = 10 * time.Second
+)
+
+// command is a subcommand of the binary, run with the arguments following its name, it returns the exit code
+type command struct {
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) int
+}
+
+// commands are the subcommands of the binary, serve is run when the first argument is a flag or there is none
+var commands map[string]command
+
+func init() {
+	// set in init because help refers to commands
+	commands = map[string]command{
+		"serve":   {summary: "start the server, the default command", run: serve},
+		"version": {summary: "print the version, git commit and go toolchain of the binary", run: printVersion},
+		"check":   {summary: "request /health and /readiness of a running server, exit 1 when one fails, for container healthchecks", run: check},
+		"probe":   {summary: "request the urls given as arguments every interval and print their status and latency, exit 1 when one failed", run: probe},
+		"help":    {summary: "print this help", run: help},
+	}
+}
+
+// runCommand runs the command named by the first of args, the flags of serve are still accepted without a command name
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	cmd, found := commands[name]
+	if !found {
+		fmt.Fprintf(stderr, "unknown command %q\n", name)
+		help(nil, stderr, stderr)
+		return exitCodeUsage
+	}
+	return cmd.run(args, stdout, stderr)
+}
+
+// help prints the commands with their summary
+func help(_ []string, stdout, _ io.Writer) int {
+	fmt.Fprintf(stdout, "Usage: %s [command] [flags]\n\nCommands:\n", info.APP)
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(stdout, "  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(stdout, "\nRun '%s <command> -h' for the flags of a command.\n", info.APP)
+	return 0
+}
+
+// printVersion prints the build information of the binary, as text or json with -json
+func printVersion(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	asJson := fs.Bool("json", false, "print the build information in json")
+	if err := fs.Parse(args); err != nil {
+		return parseExitCode(err)
+	}
+	bi := info.GetBuildInfo()
+	if *asJson {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(bi)
+		return 0
+	}
+	modified := ""
+	if bi.Modified {
+		modified = " (modified)"
+	}
+	fmt.Fprintf(stdout, "%s %s\nrevision: %s%s\nbuild date: %s\ngo: %s %s %s\n", bi.App, bi.Version, bi.Revision, modified, bi.BuildDate, bi.GoVersion, bi.Compiler, bi.Platform)
+	return 0
+}
+
+// defaultCheckUrl returns the url of the server configured by the env variables, on the admin port when ADMIN_PORT
+// serves the probes, with the loopback address when the server listens on every interface
+func defaultCheckUrl() string {
+	settings, err := config.GetConfigFromEnv()
+	if err != nil {
+		settings = config.DefaultConfig()
+	}
+	scheme, port := "http", settings.Port
+	if settings.AdminPort > 0 {
+		port = settings.AdminPort
+	} else if settings.TlsCertFile != "" {
+		scheme = "https"
+	}
+	host := settings.ListenIp
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// check requests the probes of a running server and exits with exitCodeCheckFailure when one of them fails
+func check(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	baseUrl := fs.String("url", "", "url of the server, by default the loopback address with PORT, or ADMIN_PORT when set")
+	paths := fs.String("paths", strings.Join(server.DefaultCheckPaths, ","), "comma separated paths which should answer with a 2xx status")
+	timeout := fs.Duration("timeout", defaultCheckTimeout, "maximum time of all the requests")
+	insecure := fs.Bool("insecure", false, "do not verify the certificate of an https url, always the case for the default url")
+	quiet := fs.Bool("quiet", false, "print nothing, only the exit code tells the result")
+	if err := fs.Parse(args); err != nil {
+		return parseExitCode(err)
+	}
+	if *baseUrl == "" {
+		// the certificate of the server is not issued for the loopback address
+		*baseUrl, *insecure = defaultCheckUrl(), true
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: *insecure}
+	client := &http.Client{Transport: transport}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	status := 0
+	for _, c := range server.CheckEndpoints(ctx, client, *baseUrl, strings.Split(*paths, ",")) {
+		result := "OK"
+		if !c.Ok() {
+			result, status = "FAIL", exitCodeCheckFailure
+		}
+		if *quiet {
+			continue
+		}
+		if c.Error != "" {
+			fmt.Fprintf(stdout, "%-4s %s %s\n", result, c.Url, c.Error)
+		} else {
+			fmt.Fprintf(stdout, "%-4s %s %d in %s\n", result, c.Url, c.Status, c.Duration.Round(time.Millisecond))
+		}
+	}
+	return status
+}
+
+// probe polls the urls given as arguments like the prober of PROBE_TARGETS, -count rounds or until interrupted, and
+// exits with exitCodeCheckFailure when a request failed
+func probe(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("probe", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	interval := fs.Duration("interval", defaultProbeInterval, "time between two rounds of requests")
+	count := fs.Int("count", 1, "rounds of requests, 0 to poll until interrupted")
+	timeout := fs.Duration("timeout", defaultCheckTimeout, "maximum time of a request")
+	if err := fs.Parse(args); err != nil {
+		return parseExitCode(err)
+	}
+	urls := fs.Args()
+	if len(urls) == 0 {
+		fmt.Fprintln(stderr, "probe needs the urls to request as arguments")
+		return exitCodeUsage
+	}
+	for _, target := range urls {
+		if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fmt.Fprintf(stderr, "probe needs http or https urls, got %q\n", target)
+			return exitCodeUsage
+		}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	prober := server.NewProber(urls, *timeout, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	for round := 1; ctx.Err() == nil; round++ {
+		prober.Poll(ctx)
+		for _, target := range prober.Status().Targets {
+			last := target.History[len(target.History)-1]
+			result := "OK"
+			if !last.Ok() {
+				result = "FAIL"
+			}
+			if last.Error != "" {
+				fmt.Fprintf(stdout, "%s %-4s %s %s\n", last.Time.Format(time.RFC3339), result, target.Url, last.Error)
+			} else {
+				fmt.Fprintf(stdout, "%s %-4s %s %d in %.1fms\n", last.Time.Format(time.RFC3339), result, target.Url, last.Status, last.LatencyMs)
+			}
+		}
+		if *count > 0 && round >= *count {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(*interval):
+		}
+	}
+	status := 0
+	for _, target := range prober.Status().Targets {
+		fmt.Fprintf(stdout, "%s: %d checks, %d failures, avg %.1fms, max %.1fms\n", target.Url, target.Checks, target.Failures, target.AvgLatencyMs, target.MaxLatencyMs)
+		if target.Failures > 0 {
+			status = exitCodeCheckFailure
+		}
+	}
+	return status
+}
+
+// parseExitCode returns the exit code of a command whose flags could not be parsed, 0 for -h
+func parseExitCode(err error) int {
+	if err == flag.ErrHelp {
+		return 0
+	}
+	return exitCodeUsage
+}
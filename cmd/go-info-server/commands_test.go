@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCommand(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/readiness" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+	tests := []struct {
+		name         string
+		args         []string
+		wantCode     int
+		wantStdout   string
+		wantNoStdout bool
+		wantStderr   string
+	}{
+		{name: "1: version should print the app and version", args: []string{"version"}, wantStdout: info.APP + " " + info.VERSION},
+		{name: "2: an unknown command should print the usage", args: []string{"deploy"}, wantCode: exitCodeUsage, wantStderr: "unknown command \"deploy\""},
+		{name: "3: help should list the commands", args: []string{"help"}, wantStdout: "check"},
+		{name: "4: check should succeed when every path answers 2xx", args: []string{"check", "-url", ts.URL, "-paths", "/health"}, wantStdout: "OK   " + ts.URL + "/health 200"},
+		{name: "5: check should fail when a path answers 503", args: []string{"check", "-url", ts.URL}, wantCode: exitCodeCheckFailure, wantStdout: "FAIL " + ts.URL + "/readiness 503"},
+		{name: "6: check -quiet should only give the exit code", args: []string{"check", "-quiet", "-url", ts.URL}, wantCode: exitCodeCheckFailure, wantNoStdout: true},
+		{name: "7: an invalid flag should be a usage error", args: []string{"check", "-bogus"}, wantCode: exitCodeUsage, wantStderr: "flag provided but not defined"},
+		{name: "8: -h of a command should print its flags", args: []string{"version", "-h"}, wantStderr: "-json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			assert.Equal(t, tt.wantCode, runCommand(tt.args, &stdout, &stderr))
+			assert.Contains(t, stdout.String(), tt.wantStdout)
+			assert.Contains(t, stderr.String(), tt.wantStderr)
+			if tt.wantNoStdout {
+				assert.Empty(t, stdout.String())
+			}
+		})
+	}
+}
+
+func TestPrintVersionJson(t *testing.T) {
+	var stdout bytes.Buffer
+	assert.Equal(t, 0, runCommand([]string{"version", "-json"}, &stdout, &stdout))
+	var bi info.BuildInfo
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &bi), "the output should be a valid json")
+	assert.Equal(t, info.VERSION, bi.Version)
+}
+
+func TestDefaultCheckUrl(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{name: "1: the default port should be used on the loopback address", env: map[string]string{}, want: "http://127.0.0.1:8080"},
+		{name: "2: the admin port should be preferred", env: map[string]string{"PORT": "9090", "ADMIN_PORT": "9901"}, want: "http://127.0.0.1:9901"},
+		{name: "3: a listen ip should be used", env: map[string]string{"LISTEN_IP": "10.1.2.3"}, want: "http://10.1.2.3:8080"},
+		{name: "4: a tls certificate should give https", env: map[string]string{"TLS_CERT_FILE": "/tls/tls.crt", "TLS_KEY_FILE": "/tls/tls.key"}, want: "https://127.0.0.1:8080"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, val := range tt.env {
+				t.Setenv(name, val)
+			}
+			assert.Equal(t, tt.want, defaultCheckUrl())
+		})
+	}
+}
+
+func TestProbeCommand(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer ts.Close()
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{name: "1: a target answering 200 should succeed", args: []string{"probe", ts.URL + "/up"}, wantStdout: ts.URL + "/up: 1 checks, 0 failures"},
+		{name: "2: every round should be counted", args: []string{"probe", "-count", "2", "-interval", "10ms", ts.URL + "/up"}, wantStdout: ts.URL + "/up: 2 checks, 0 failures"},
+		{name: "3: a target answering 502 should fail", args: []string{"probe", ts.URL + "/up", ts.URL + "/down"}, wantCode: exitCodeCheckFailure, wantStdout: "FAIL " + ts.URL + "/down 502"},
+		{name: "4: probe without url should be a usage error", args: []string{"probe"}, wantCode: exitCodeUsage, wantStderr: "needs the urls"},
+		{name: "5: a url without scheme should be a usage error", args: []string{"probe", "example.com"}, wantCode: exitCodeUsage, wantStderr: "http or https urls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			assert.Equal(t, tt.wantCode, runCommand(tt.args, &stdout, &stderr))
+			assert.Contains(t, stdout.String(), tt.wantStdout)
+			assert.Contains(t, stderr.String(), tt.wantStderr)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/server"
+)
+
+const (
+	exitCodeServerFailure = 1
+	exitCodeCheckFailure  = 1
+	exitCodeUsage         = 2
+	exitCodeConfigFailure = 78 // EX_CONFIG from sysexits.h
+)
+
+func main() {
+	os.Exit(runCommand(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// serve starts the server configured by the flags in args, the env variables and the config file, it is the command
+// run when the binary is called without a command name
+func serve(args []string, stdout, _ io.Writer) int {
+	settings, err := config.LoadConfig(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		log.Printf("💥💥 ERROR: 'calling LoadConfig got error: %v'\n", err)
+		return exitCodeConfigFailure
+	}
+	var level slog.LevelVar
+	level.Set(settings.Level())
+	l := server.NewLogger(stdout, settings.LogFormat, &level)
+	if settings.AutoMaxProcs {
+		tuned := info.TuneMaxProcs(info.DefaultCgroupRoot, os.LookupEnv)
+		l.Info("GOMAXPROCS chosen", "gomaxprocs", tuned.Value, "previous", tuned.Previous, "source", tuned.Source, "cpu_quota", tuned.CpuQuota)
+	}
+	dependencies, err := server.GetDependencyWaiterFromConfig(settings, l)
+	if err != nil {
+		l.Error("calling GetDependencyWaiterFromConfig got error", "error", err)
+		return exitCodeConfigFailure
+	}
+	clientAuth, clientCAs, err := server.GetTlsClientAuthFromConfig(settings)
+	if err != nil {
+		l.Error("calling GetTlsClientAuthFromConfig got error", "error", err)
+		return exitCodeConfigFailure
+	}
+	readinessChecks, err := server.GetReadinessChecksFromConfig(settings)
+	if err != nil {
+		l.Error("calling GetReadinessChecksFromConfig got error", "error", err)
+		return exitCodeConfigFailure
+	}
+	l.Info("Starting HTTP server", "app", info.APP, "version", info.VERSION, "address", settings.Address())
+	myServer := server.NewGoHttpServerWithConfig(settings.Address(), settings, l)
+	myServer.UseReadinessChecks(readinessChecks...)
+	if dependencies != nil {
+		myServer.UseDependencyWaiter(dependencies)
+	}
+	myServer.UseConfigReload(args, &level)
+	if reportConfig := server.GetReportConfigFromConfig(settings); reportConfig != nil {
+		myServer.UseReporter(*reportConfig)
+	}
+	if webhookConfig := server.GetWebhookConfigFromConfig(settings); webhookConfig != nil {
+		if err := myServer.UseWebhooks(*webhookConfig); err != nil {
+			l.Error("unable to use the webhooks", "error", err)
+			return exitCodeConfigFailure
+		}
+	}
+	if settings.TlsCertFile != "" {
+		certs, err := server.NewCertReloader(settings.TlsCertFile, settings.TlsKeyFile, l)
+		if err != nil {
+			l.Error("unable to load TLS certificate", "cert_file", settings.TlsCertFile, "key_file", settings.TlsKeyFile, "error", err)
+			return exitCodeConfigFailure
+		}
+		myServer.UseTLS(certs)
+		myServer.UseTlsClientAuth(clientAuth, clientCAs)
+	}
+	if err := myServer.StartServer(); err != nil {
+		l.Error("server stopped with an error", "error", err)
+		var configErr *config.ErrorConfig
+		if errors.As(err, &configErr) {
+			// like the dependencies of WAIT_FOR which never came up
+			return exitCodeConfigFailure
+		}
+		return exitCodeServerFailure
+	}
+	return 0
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/server"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPort = 8080
+
+func TestMainExecution(t *testing.T) {
+	listenAddr := fmt.Sprintf("http://:%d/", testPort)
+	err := os.Setenv("PORT", fmt.Sprintf("%d", testPort))
+	if err != nil {
+		t.Errorf("Unable to set env variable PORT")
+		return
+	}
+	// main parses os.Args, which contains the flags of go test
+	os.Args = []string{info.APP}
+	// starting main in his own go routine
+	go main()
+	server.WaitForHttpServer(listenAddr, 1*time.Second, 10)
+
+	resp, err := http.Get(listenAddr)
+	if err != nil {
+		t.Fatalf("Cannot make http get: %v\n", err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Should return an http status ok")
+
+	receivedJson, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Error reading response body: %v\n", err)
+	}
+	var decodedResponse info.OsInfo
+	err = json.Unmarshal(receivedJson, &decodedResponse)
+	assert.Nil(t, err, "the output should be a valid json")
+	if err != nil {
+		t.Fatalf("Cannot decode response <%p> from server. Err: %v", receivedJson, err)
+	}
+
+	assert.Contains(t, string(receivedJson), fmt.Sprintf("\"appname\":\"%s\"", info.APP), "Response should contain the appname field.")
+	assert.Contains(t, string(receivedJson), "\"request_id\":", "Response should contain the request_id field.")
+}
+
+func TestServeWaitForTimeout(t *testing.T) {
+	never, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	neverAddr := never.Addr().String()
+	never.Close()
+	var stdout bytes.Buffer
+	code := serve([]string{"-port=18081", "-wait-for=tcp://" + neverAddr, "-wait-for-timeout=1s"}, &stdout, io.Discard)
+	assert.Equal(t, exitCodeConfigFailure, code, "dependencies which never came up should be a config failure")
+	assert.Contains(t, stdout.String(), neverAddr, "the log should name the missing dependency")
+}
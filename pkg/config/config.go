@@ -0,0 +1,1052 @@
+// Package config loads and validates the settings of the go-cloud-k8s-info server
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrorConfig is the error returned when a setting or an env variable has an invalid value
+type ErrorConfig struct {
+	Err error
+	Msg string
+}
+
+// Error returns a string with an error and a specifics message
+func (e *ErrorConfig) Error() string {
+	return fmt.Sprintf("%s : %v", e.Msg, e.Err)
+}
+
+const (
+	DefaultListenIp              = ""
+	DefaultPort                  = 8080
+	DefaultReadTimeout           = 10 * time.Second // max time to read request from the client
+	DefaultWriteTimeout          = 10 * time.Second // max time to write response to the client
+	DefaultIdleTimeout           = 2 * time.Minute  // max time for connections using TCP Keep-Alive
+	LogFormatJson                = "json"
+	LogFormatText                = "text"
+	AccessLogCombined            = "combined"
+	AccessLogCommon              = "common"
+	AccessLogJson                = "json"
+	defaultLogFormat             = LogFormatJson
+	defaultLogLevel              = slog.LevelInfo
+	defaultSecondsToSleep        = 3
+	defaultMaxWait               = 8 * time.Second // maximum duration accepted by /wait, must stay below DefaultWriteTimeout
+	defaultWaitMaxConcurrent     = 1000
+	defaultSocketMode            = "0660"          // read and write for the user and the group of the process
+	defaultCacheControl          = "no-cache"      // the clients may keep the answers but must check the ETag first
+	secondsShutDownTimeout       = 5 * time.Second // maximum number of second to wait before closing server
+	defaultPreStopDelay          = 5 * time.Second // time to keep serving after SIGTERM so k8s can remove the pod from the endpoints
+	defaultAccessLogFormat       = AccessLogCombined
+	defaultCompressMinBytes      = 1024
+	defaultCompressMediaTypes    = "text/,application/json,application/xml,application/yaml,image/svg+xml"
+	defaultRateLimitBurst        = 20
+	defaultWsMaxConnections      = 50
+	defaultLeaderLease           = 15 * time.Second // like the default of the client-go leader election
+	minLeaderLease               = 5 * time.Second  // the lease is renewed every 2 seconds
+	defaultRequestHistory        = 200
+	defaultNtpPort               = "123"
+	defaultProbeInterval         = 10 * time.Second
+	minProbeInterval             = time.Second
+	defaultUploadTtl             = time.Hour
+	minUploadTtl                 = time.Second
+	defaultUploadMaxMb           = 100
+	maxUploadMaxMb               = 1024
+	defaultUploadQuotaMb         = 1024
+	maxRequestHistory            = 100000 // about 50 MB of records with their headers
+	maxHeapBallastMb             = 16384
+	defaultLivenessMaxGoroutines = 10000
+	defaultLivenessMaxHeapRatio  = 0.9
+	defaultLivenessMaxSchedDelay = time.Second
+	defaultAccessTokenTtl        = 60 * time.Second // lifetime of a one-shot download token
+	defaultRenderContentType     = "text/plain; charset=UTF-8"
+	defaultStoreRetention        = 7 * 24 * time.Hour
+	defaultWaitForTimeout        = 120 * time.Second
+	defaultReportInterval        = 60 * time.Second
+	minReportInterval            = time.Second
+	TlsClientAuthNone            = "none"
+	TlsClientAuthRequest         = "request"
+	TlsClientAuthVerifyIfGiven   = "verify_if_given"
+	TlsClientAuthRequire         = "require"
+	AuthModeNone                 = "none"
+	AuthModeBasic                = "basic"
+	AuthModeBearer               = "bearer"
+	AuthModeJwt                  = "jwt"
+	ClusterDiscoveryDns          = "dns"
+	ClusterDiscoveryEndpoints    = "endpoints"
+	WebhookFormatGeneric         = "generic"
+	WebhookFormatSlack           = "slack"
+	OtlpProtocolHttp             = "http/protobuf"
+	OtlpProtocolGrpc             = "grpc"
+	defaultOtelSampler           = "parentbased_always_on"
+	defaultOtelPropagators       = "tracecontext,baggage"
+)
+
+// otelSamplers are the values of OTEL_TRACES_SAMPLER implemented by the OpenTelemetry go sdk
+var otelSamplers = []string{"always_on", "always_off", "traceidratio", "parentbased_always_on", "parentbased_always_off", "parentbased_traceidratio"}
+
+// otelPropagators are the values of OTEL_PROPAGATORS available without the contrib propagators
+var otelPropagators = []string{"tracecontext", "baggage", "none"}
+
+// leaseNameRegexp matches the names of the k8s objects like the Lease of the leader election, RFC 1123 subdomains
+var leaseNameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]{0,251}[a-z0-9])?$`)
+
+// instanceLabelRegexp matches the values of deploy_track and color, which must be safe in a header
+var instanceLabelRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]{0,63}$`)
+
+// Config contains the settings of the server. each setting can be given, from the lowest to the highest precedence,
+// by its default value, by its json key in the yaml or json file named by CONFIG_FILE, by its env variable
+// or by the command line flag named like the json key with dashes, like -write-timeout=20s.
+// the settings tagged reload:"true" can be changed while the server is running, see ConfigReloader
+type Config struct {
+	ListenIp        string        `json:"listen_ip" env:"LISTEN_IP" help:"ip address to listen on, all interfaces when empty"`
+	Port            int           `json:"port" env:"PORT" help:"tcp port of the http server"`
+	ListenSocket    string        `json:"listen_socket" env:"LISTEN_SOCKET" help:"path of a unix socket served in plain http besides the tcp port, like /var/run/info.sock"`
+	SocketMode      string        `json:"listen_socket_mode" env:"LISTEN_SOCKET_MODE" help:"permissions of the unix socket in octal, like 0660"`
+	SocketOnly      bool          `json:"listen_socket_only" env:"LISTEN_SOCKET_ONLY" help:"serve only the unix socket of listen_socket, without the tcp port"`
+	ExtraListen     string        `json:"extra_listen" env:"EXTRA_LISTEN" help:"comma separated additional addresses served by the same handlers like :9090,:9443=tls,:8443=/etc/other-tls. tls uses the certificates of the main port, a directory its own tls.crt and tls.key"`
+	LogLevel        string        `json:"log_level" env:"LOG_LEVEL" reload:"true" help:"minimum level of the logs : debug, info, warn or error"`
+	NotFoundLog     string        `json:"not_found_log_level" env:"NOT_FOUND_LOG_LEVEL" reload:"true" help:"level of the logs of the unknown paths : debug, info, warn or error"`
+	LogFormat       string        `json:"log_format" env:"LOG_FORMAT" help:"format of the logs : json or text"`
+	ReadTimeout     time.Duration `json:"read_timeout" env:"HTTP_READ_TIMEOUT" help:"max time to read a request from the client"`
+	WriteTimeout    time.Duration `json:"write_timeout" env:"HTTP_WRITE_TIMEOUT" help:"max time to write a response to the client"`
+	IdleTimeout     time.Duration `json:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" help:"max time to keep an idle keep-alive connection"`
+	ShutdownTimeout time.Duration `json:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" help:"max time to wait for the active requests on shutdown"`
+	PreStopDelay    time.Duration `json:"pre_stop_delay" env:"PRE_STOP_DELAY_SECONDS" help:"time to keep serving after SIGTERM while /readiness fails"`
+	StartupDelay    time.Duration `json:"startup_delay" env:"STARTUP_DELAY_SECONDS" help:"/started fails during this warm-up unless POST /admin/ready ends it earlier, 0 to be started at once"`
+	WaitFor         string        `json:"wait_for" env:"WAIT_FOR" help:"comma separated tcp://host:port or http(s)://host:port/path dependencies that must be up before the main port is bound"`
+	WaitForTimeout  time.Duration `json:"wait_for_timeout" env:"WAIT_FOR_TIMEOUT_SECONDS" help:"max time to wait for the dependencies of wait_for before giving up"`
+	WaitDefault     time.Duration `json:"wait_default" env:"WAIT_DEFAULT_SECONDS" reload:"true" help:"duration of /wait without the seconds parameter"`
+	WaitMax         time.Duration `json:"wait_max" env:"WAIT_MAX_SECONDS" reload:"true" help:"maximum duration accepted by /wait, lower than write_timeout"`
+	WaitMaxConc     int           `json:"wait_max_concurrent" env:"WAIT_MAX_CONCURRENT" reload:"true" help:"maximum number of requests waiting in /wait at the same time, 0 for no limit"`
+	AutoMaxProcs    bool          `json:"auto_maxprocs" env:"AUTO_MAXPROCS" help:"set GOMAXPROCS to the cpu quota of the container at startup, unless the GOMAXPROCS env variable is set"`
+	HeapBallastMb   int           `json:"heap_ballast_mb" env:"HEAP_BALLAST_MB" help:"megabytes of heap ballast delaying the gc of a small heap, 0 to disable it, GOMEMLIMIT is usually better"`
+	EnablePprof     bool          `json:"enable_pprof" env:"ENABLE_PPROF" help:"mount the net/http/pprof endpoints under /debug/pprof/"`
+	PprofPort       int           `json:"pprof_port" env:"PPROF_PORT" help:"serve pprof on this port only instead of the main one, 0 to use the main port"`
+	ReadinessChecks string        `json:"readiness_checks" env:"READINESS_CHECKS" help:"json array of the checks of /readiness like [{\"name\":\"db\",\"type\":\"tcp\",\"target\":\"postgres:5432\"}], the types are tcp, http, dns and file"`
+	ReadinessFile   string        `json:"readiness_checks_file" env:"READINESS_CHECKS_FILE" help:"path of a file containing the json array of readiness_checks, used when readiness_checks is empty"`
+	MaxGoroutines   int           `json:"liveness_max_goroutines" env:"LIVENESS_MAX_GOROUTINES" help:"/health fails above this number of goroutines, 0 to disable the check"`
+	MaxHeapRatio    float64       `json:"liveness_max_heap_ratio" env:"LIVENESS_MAX_HEAP_RATIO" help:"/health fails when the heap uses more than this ratio of the cgroup memory limit, 0 to disable the check"`
+	MaxSchedDelay   time.Duration `json:"liveness_max_scheduler_delay" env:"LIVENESS_MAX_SCHEDULER_DELAY" help:"/health fails when a goroutine waits longer than this to run, 0 to disable the check"`
+	AdminPort       int           `json:"admin_port" env:"ADMIN_PORT" help:"serve /health, /readiness, /metrics and pprof on this internal port only, 0 to keep them on the main port"`
+	GrpcPort        int           `json:"grpc_port" env:"GRPC_PORT" help:"serve the grpc InfoService, health and reflection services on this port, 0 to disable grpc"`
+	PreemptionWatch bool          `json:"preemption_watch" env:"PREEMPTION_WATCH" help:"poll the aws spot interruption notice or the gcp preempted flag, shown by /cloud/preemption"`
+	PreemptionReady bool          `json:"preemption_readiness" env:"PREEMPTION_READINESS" help:"make /readiness fail when the instance is going to be preempted, needs preemption_watch"`
+	WsMaxConns      int           `json:"ws_max_connections" env:"WS_MAX_CONNECTIONS" help:"maximum number of clients connected to /ws/stats at the same time"`
+	WsOrigins       string        `json:"ws_allowed_origins" env:"WS_ALLOWED_ORIGINS" help:"comma separated origins like https://dashboard.example.com whose pages may open /ws/stats besides the pages of this server, * for all"`
+	LeaderElection  string        `json:"leader_election" env:"LEADER_ELECTION" help:"name of the coordination.k8s.io Lease used to elect a leader among the replicas, shown by /leader, empty to disable"`
+	LeaderLease     time.Duration `json:"leader_lease_duration" env:"LEADER_LEASE_DURATION" help:"time after which the lease of a leader which stopped renewing it can be taken"`
+	ConfigMapWatch  string        `json:"configmap_watch" env:"CONFIGMAP_WATCH" help:"comma separated paths of configMap volumes or files whose updates by the kubelet are shown by /k8s/configmap-events"`
+	NtpServer       string        `json:"ntp_server" env:"NTP_SERVER" reload:"true" help:"ntp server like pool.ntp.org[:123] queried by /time/diagnostics to measure the offset of the node clock, empty to disable"`
+	ProbeTargets    string        `json:"probe_targets" env:"PROBE_TARGETS" help:"comma separated http or https urls polled in the background, their status and latency history is shown by /probe/status"`
+	ProbeInterval   time.Duration `json:"probe_interval" env:"PROBE_INTERVAL" help:"time between two polls of the probe_targets"`
+	UploadDir       string        `json:"upload_dir" env:"UPLOAD_DIR" help:"absolute path of the directory, like an emptyDir volume, keeping the files of POST /upload, empty to disable /upload"`
+	UploadTtl       time.Duration `json:"upload_ttl" env:"UPLOAD_TTL" help:"time after which an uploaded file is deleted"`
+	UploadMaxMb     int           `json:"upload_max_mb" env:"UPLOAD_MAX_MB" help:"maximum size in megabytes of the body of POST /upload, larger ones are refused with 413"`
+	UploadQuotaMb   int           `json:"upload_quota_mb" env:"UPLOAD_QUOTA_MB" help:"maximum size in megabytes of all the files kept in upload_dir, the uploads above it are refused with 507 until older ones expire"`
+	BinaryReload    bool          `json:"binary_reload" env:"BINARY_RELOAD" help:"on SIGUSR2, start the new binary on the same listener and drain this process, for in place updates outside k8s"`
+	PidFile         string        `json:"pid_file" env:"PID_FILE" help:"file where the pid of the serving process is written, followed by the process manager across binary reloads, empty to disable"`
+	K8sEvents       bool          `json:"k8s_events" env:"K8S_EVENTS" help:"emit k8s Events attached to the pod on startup, shutdown, probe switches and chaos actions, shown by kubectl describe pod"`
+	MountsContents  bool          `json:"mounts_contents" env:"MOUNTS_CONTENTS" reload:"true" help:"allow contents=true of /k8s/mounts to show the files of the configMap volumes, the secrets are never shown"`
+	EnableChaos     bool          `json:"enable_chaos" env:"ENABLE_CHAOS" reload:"true" help:"enable the /chaos failure injection endpoints"`
+	FaultHeaders    bool          `json:"fault_headers" env:"FAULT_HEADERS" reload:"true" help:"honor the X-Inject-Delay and X-Inject-Status request headers delaying or failing this request only"`
+	EnableLoad      bool          `json:"enable_load" env:"ENABLE_LOAD" reload:"true" help:"enable the /load cpu and memory load and the /bench endpoints"`
+	AccessLog       string        `json:"access_log" env:"ACCESS_LOG" help:"destination of the access log : stdout, stderr or a file path, disabled when empty"`
+	RequestHistory  int           `json:"request_history" env:"REQUEST_HISTORY" help:"number of the last requests kept in memory for /requests, 0 to disable it"`
+	AccessLogFormat string        `json:"access_log_format" env:"ACCESS_LOG_FORMAT" help:"format of the access log : combined, common or json"`
+	Compression     bool          `json:"compression" env:"COMPRESSION" help:"compress the responses in gzip or deflate for the clients accepting it"`
+	CompressMin     int           `json:"compress_min_bytes" env:"COMPRESS_MIN_BYTES" help:"minimum size of a compressed response"`
+	CompressTypes   string        `json:"compress_types" env:"COMPRESS_TYPES" help:"comma separated media types to compress, text/ matches all the text types"`
+	CacheControl    string        `json:"cache_control" env:"CACHE_CONTROL" reload:"true" help:"Cache-Control header of the json answers sent with an ETag, empty to send none"`
+	RespEnvelope    bool          `json:"response_envelope" env:"RESPONSE_ENVELOPE" reload:"true" help:"wrap the json answers in an object giving the instance, hostname, pod and timestamp of the answer in its data field"`
+	RateLimitRps    float64       `json:"rate_limit_rps" env:"RATE_LIMIT_RPS" help:"requests per second allowed for each client ip, 0 to disable the rate limit"`
+	RateLimitBurst  int           `json:"rate_limit_burst" env:"RATE_LIMIT_BURST" help:"requests a client ip may send at once above rate_limit_rps"`
+	TrustedProxies  string        `json:"trusted_proxies" env:"TRUSTED_PROXIES" help:"comma separated CIDR ranges of the proxies allowed to give the client ip in X-Forwarded-For, X-Real-IP or the PROXY protocol"`
+	IpAllowlist     string        `json:"ip_allowlist" env:"IP_ALLOWLIST" help:"comma separated CIDR ranges of the client ips allowed to reach the routes of the main port, all when empty"`
+	IpDenylist      string        `json:"ip_denylist" env:"IP_DENYLIST" help:"comma separated CIDR ranges of the client ips refused with 403, even when they are in ip_allowlist"`
+	ProxyProtocol   bool          `json:"proxy_protocol" env:"PROXY_PROTOCOL" help:"read the HAProxy PROXY protocol header of the connections coming from trusted_proxies"`
+	Http2           bool          `json:"http2" env:"HTTP2" help:"serve HTTP/2 over TLS and in clear text with h2c prior knowledge, false to only speak HTTP/1.1"`
+	Http3Port       int           `json:"http3_port" env:"HTTP3_PORT" help:"experimental HTTP/3 over QUIC on this udp port, advertised with Alt-Svc, 0 to disable. needs TLS"`
+	InfoMessage     string        `json:"info_message" env:"INFO_MESSAGE" reload:"true" help:"message shown by / and the dashboard, a go template using {{.Hostname}}, {{.Namespace}} and {{.Version}}"`
+	DeployTrack     string        `json:"deploy_track" env:"DEPLOY_TRACK" help:"track of this deployment like stable or canary, sent in the X-Instance-Info header and the json answers"`
+	Color           string        `json:"color" env:"COLOR" help:"color of this deployment like blue or green, sent like deploy_track"`
+	BannerFile      string        `json:"banner_file" env:"BANNER_FILE" reload:"true" help:"path of a banner shown by / and the dashboard, a go template like info_message, read again when it changes"`
+	ApiToken        string        `json:"api_token" env:"API_TOKEN" secret:"true" help:"bearer token protecting the routes needing credentials, with the access_token issued by POST /token, no protection when empty"`
+	AccessTokenTtl  time.Duration `json:"access_token_ttl" env:"ACCESS_TOKEN_TTL_SECONDS" help:"lifetime of the single-use access tokens"`
+	AuthMode        string        `json:"auth_mode" env:"AUTH_MODE" help:"protection of the info routes, the probes are never protected : none, basic, bearer or jwt"`
+	AuthUsername    string        `json:"auth_username" env:"AUTH_USERNAME" help:"user name expected by the basic auth_mode"`
+	AuthPassword    string        `json:"auth_password" env:"AUTH_PASSWORD" secret:"true" help:"password expected by the basic auth_mode"`
+	AuthToken       string        `json:"auth_token" env:"AUTH_TOKEN" secret:"true" help:"token expected in the Authorization: Bearer header by the bearer auth_mode"`
+	AuthJwksUrl     string        `json:"auth_jwks_url" env:"AUTH_JWKS_URL" help:"http or https url of the keys of the issuer validating the jwt of the Authorization: Bearer header in the jwt auth_mode"`
+	AuthJwtIssuer   string        `json:"auth_jwt_issuer" env:"AUTH_JWT_ISSUER" help:"iss expected in the jwt, not checked when empty"`
+	AuthJwtAudience string        `json:"auth_jwt_audience" env:"AUTH_JWT_AUDIENCE" help:"aud expected in the jwt, not checked when empty"`
+	AuthJwtClaims   string        `json:"auth_jwt_claims" env:"AUTH_JWT_CLAIMS" help:"comma separated claims of the jwt echoed by /whoami, all of them when empty"`
+	EnvRedact       string        `json:"env_var_redact_patterns" env:"ENV_VAR_REDACT_PATTERNS" help:"comma separated regexp of the env variables whose values are masked, added to _PASSWORD$, _TOKEN$, KEY and SECRET"`
+	EnvAllowlist    string        `json:"env_var_allowlist" env:"ENV_VAR_ALLOWLIST" help:"comma separated names of the only env variables that may be shown, all when empty"`
+	DnsResolver     string        `json:"dns_resolver" env:"DNS_RESOLVER" help:"host:port of the dns server used by /dns like 10.96.0.10:53, the resolvers of /etc/resolv.conf when empty"`
+	ConnectAllow    string        `json:"connect_allowlist" env:"CONNECT_ALLOWLIST" help:"comma separated host names, *.domain, ip addresses or CIDR ranges reachable by /connect, /certcheck, /proxy and /bench/net, disabled when empty"`
+	OtlpEndpoint    string        `json:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" help:"base url of the OTLP collector receiving the traces, /v1/traces is appended with http/protobuf, no tracing when empty"`
+	OtlpTraces      string        `json:"otlp_traces_endpoint" env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT" help:"full url of the traces endpoint of the collector, has precedence over otlp_endpoint"`
+	OtlpHeaders     string        `json:"otlp_headers" env:"OTEL_EXPORTER_OTLP_HEADERS" secret:"true" help:"comma separated key=value headers sent to the collector"`
+	OtlpProtocol    string        `json:"otlp_protocol" env:"OTEL_EXPORTER_OTLP_PROTOCOL" help:"transport of the traces to the collector : http/protobuf or grpc, http/json is not supported"`
+	OtelSampler     string        `json:"otel_traces_sampler" env:"OTEL_TRACES_SAMPLER" help:"sampler of the traces : always_on, always_off, traceidratio, parentbased_always_on, parentbased_always_off or parentbased_traceidratio"`
+	OtelSamplerArg  string        `json:"otel_traces_sampler_arg" env:"OTEL_TRACES_SAMPLER_ARG" help:"ratio between 0 and 1 of the traces kept by the traceidratio samplers, 1 when empty"`
+	OtelPropagators string        `json:"otel_propagators" env:"OTEL_PROPAGATORS" help:"comma separated propagators of the trace context in the requests : tracecontext, baggage or none"`
+	OtelResource    string        `json:"otel_resource_attributes" env:"OTEL_RESOURCE_ATTRIBUTES" help:"comma separated key=value attributes of the resource of the traces, like deployment.environment=prod"`
+	OtelService     string        `json:"otel_service_name" env:"OTEL_SERVICE_NAME" help:"name of the service in the traces, the name of the app when empty"`
+	OtelDisabled    bool          `json:"otel_sdk_disabled" env:"OTEL_SDK_DISABLED" help:"disable the tracing even when a collector is given"`
+	TlsCertFile     string        `json:"tls_cert_file" env:"TLS_CERT_FILE" help:"pem file with the certificate and its intermediate chain, reloaded when it changes, to serve https with tls_key_file"`
+	TlsKeyFile      string        `json:"tls_key_file" env:"TLS_KEY_FILE" help:"pem file with the private key of tls_cert_file"`
+	TlsClientAuth   string        `json:"tls_client_auth" env:"TLS_CLIENT_AUTH" help:"client certificates asked during the handshake : none, request, verify_if_given or require"`
+	TlsClientCa     string        `json:"tls_client_ca_file" env:"TLS_CLIENT_CA_FILE" help:"pem bundle of the CAs trusted for the client certificates, needed by verify_if_given and require"`
+	ClusterService  string        `json:"cluster_service" env:"CLUSTER_SERVICE" help:"headless Service selecting the pods of this deployment, whose peers are shown by /cluster, disabled when empty"`
+	ClusterDiscover string        `json:"cluster_discovery" env:"CLUSTER_DISCOVERY" help:"dns to resolve cluster_service, or endpoints to read its Endpoints, which needs the get verb on endpoints"`
+	ClusterPort     int           `json:"cluster_port" env:"CLUSTER_PORT" help:"port of the peers, 0 for the port of this server"`
+	ClusterScheme   string        `json:"cluster_scheme" env:"CLUSTER_SCHEME" help:"scheme of the peers : http or https"`
+	RenderTemplate  string        `json:"render_template_file" env:"RENDER_TEMPLATE_FILE" help:"path of a go template executed with the runtime information of / by /render, disabled when empty"`
+	RenderType      string        `json:"render_content_type" env:"RENDER_CONTENT_TYPE" help:"content type of /render, a text/html one escapes the values"`
+	DatabaseUrl     string        `json:"database_url" env:"DATABASE_URL" secret:"true" help:"postgres:// or sqlite:// url of the database persisting the request records for /requests/query, only kept in memory when empty"`
+	StoreRetention  time.Duration `json:"request_store_retention" env:"REQUEST_STORE_RETENTION" help:"how long the request records of database_url are kept"`
+	ReportUrl       string        `json:"report_url" env:"REPORT_URL" help:"http or https endpoint of a collector receiving a POST of the runtime information of / every report_interval, disabled when empty"`
+	ReportInterval  time.Duration `json:"report_interval" env:"REPORT_INTERVAL" help:"time between two reports to report_url"`
+	ReportToken     string        `json:"report_token" env:"REPORT_TOKEN" secret:"true" help:"token sent to report_url in the Authorization: Bearer header"`
+	WebhookUrls     string        `json:"webhook_urls" env:"WEBHOOK_URLS" secret:"true" help:"comma separated http or https urls receiving a POST for each lifecycle event, disabled when empty"`
+	WebhookFormat   string        `json:"webhook_format" env:"WEBHOOK_FORMAT" help:"generic to post the json of the event, or slack to post only its text like an incoming webhook expects"`
+	WebhookTemplate string        `json:"webhook_template" env:"WEBHOOK_TEMPLATE" help:"go template of the text of the events like {{.Reason}} on {{.Pod}}, a default one with the pod identity when empty"`
+	WebhookEvents   string        `json:"webhook_events" env:"WEBHOOK_EVENTS" help:"comma separated reasons notified like Started,ShuttingDown,NotReady, all of them when empty"`
+
+	file    string            // path of the config file, empty when there is none
+	sources map[string]string // where each setting given by key was set : file, env or flag, default when absent
+}
+
+// DefaultConfig returns the configuration used when nothing is set
+func DefaultConfig() Config {
+	return Config{
+		ListenIp:        DefaultListenIp,
+		Port:            DefaultPort,
+		SocketMode:      defaultSocketMode,
+		LogLevel:        strings.ToLower(defaultLogLevel.String()),
+		LogFormat:       defaultLogFormat,
+		NotFoundLog:     strings.ToLower(slog.LevelDebug.String()),
+		ReadTimeout:     DefaultReadTimeout,
+		WriteTimeout:    DefaultWriteTimeout,
+		IdleTimeout:     DefaultIdleTimeout,
+		ShutdownTimeout: secondsShutDownTimeout,
+		PreStopDelay:    defaultPreStopDelay,
+		WaitDefault:     defaultSecondsToSleep * time.Second,
+		WaitMax:         defaultMaxWait,
+		WaitMaxConc:     defaultWaitMaxConcurrent,
+		AutoMaxProcs:    true,
+		AccessLogFormat: defaultAccessLogFormat,
+		Compression:     true,
+		CompressMin:     defaultCompressMinBytes,
+		CacheControl:    defaultCacheControl,
+		Http2:           true,
+		CompressTypes:   defaultCompressMediaTypes,
+		RateLimitBurst:  defaultRateLimitBurst,
+		WsMaxConns:      defaultWsMaxConnections,
+		LeaderLease:     defaultLeaderLease,
+		ProbeInterval:   defaultProbeInterval,
+		UploadTtl:       defaultUploadTtl,
+		UploadMaxMb:     defaultUploadMaxMb,
+		UploadQuotaMb:   defaultUploadQuotaMb,
+		K8sEvents:       true,
+		RequestHistory:  defaultRequestHistory,
+		MaxGoroutines:   defaultLivenessMaxGoroutines,
+		MaxHeapRatio:    defaultLivenessMaxHeapRatio,
+		MaxSchedDelay:   defaultLivenessMaxSchedDelay,
+		AccessTokenTtl:  defaultAccessTokenTtl,
+		AuthMode:        AuthModeNone,
+		WaitForTimeout:  defaultWaitForTimeout,
+		TlsClientAuth:   TlsClientAuthNone,
+		ClusterDiscover: ClusterDiscoveryDns,
+		ClusterScheme:   "http",
+		RenderType:      defaultRenderContentType,
+		StoreRetention:  defaultStoreRetention,
+		ReportInterval:  defaultReportInterval,
+		WebhookFormat:   WebhookFormatGeneric,
+		OtlpProtocol:    OtlpProtocolHttp,
+		OtelSampler:     defaultOtelSampler,
+		OtelPropagators: defaultOtelPropagators,
+	}
+}
+
+// configField is one setting of the Config with the names used in the file, the env and the flags
+type configField struct {
+	key    string // json key in the config file
+	env    string // name of the env variable
+	flag   string // name of the command line flag
+	help   string
+	reload bool          // can be changed while the server is running
+	secret bool          // its value is never shown, it may be read from the file named by the env variable with a _FILE suffix
+	value  reflect.Value // addressable field of the Config
+}
+
+// fields returns all the settings of the Config, in declaration order
+func (c *Config) fields() []configField {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	fields := make([]configField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("json")
+		if key == "" {
+			continue
+		}
+		fields = append(fields, configField{
+			key:    key,
+			env:    t.Field(i).Tag.Get("env"),
+			flag:   strings.ReplaceAll(key, "_", "-"),
+			help:   t.Field(i).Tag.Get("help"),
+			reload: t.Field(i).Tag.Get("reload") == "true",
+			secret: t.Field(i).Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		})
+	}
+	return fields
+}
+
+// setSource records where the setting key was given
+func (c *Config) setSource(key, source string) {
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	c.sources[key] = source
+}
+
+// Source returns where the setting key was given : default, file, env or flag
+func (c *Config) Source(key string) string {
+	if source, exist := c.sources[key]; exist {
+		return source
+	}
+	return "default"
+}
+
+// File returns the path of the config file, empty when there is none
+func (c *Config) File() string {
+	return c.file
+}
+
+// expected describes the format of a value of the kind of v, for the error messages
+func expected(v reflect.Value) string {
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		return "a duration like 10s or a number of seconds"
+	case v.Kind() == reflect.Int:
+		return "a valid integer"
+	case v.Kind() == reflect.Float64:
+		return "a valid number"
+	case v.Kind() == reflect.Bool:
+		return "true or false"
+	}
+	return "a string"
+}
+
+// set parses raw according to the type of the field and stores it, the field is left unchanged on error
+func (f configField) set(raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch {
+	case f.value.Type() == reflect.TypeOf(time.Duration(0)):
+		// a plain number is a number of seconds, to stay compatible with the *_SECONDS env variables
+		if seconds, err := strconv.ParseFloat(raw, 64); err == nil {
+			f.value.SetInt(int64(seconds * float64(time.Second)))
+			return nil
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(d))
+	case f.value.Kind() == reflect.Int:
+		i, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(i))
+	case f.value.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		f.value.SetFloat(n)
+	case f.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		f.value.SetBool(b)
+	default:
+		f.value.SetString(raw)
+	}
+	return nil
+}
+
+// parseYamlConfig reads the members of a yaml mapping, keeping the text of the scalar values like the env variables
+func parseYamlConfig(data []byte) (map[string]string, error) {
+	var nodes map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &nodes); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(nodes))
+	for key, node := range nodes {
+		if node.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d : the value of %s should be a scalar, nested values are not supported", node.Line, key)
+		}
+		if node.ShortTag() == "!!null" {
+			values[key] = ""
+			continue
+		}
+		values[key] = node.Value
+	}
+	return values, nil
+}
+
+// parseJsonConfig reads the members of a json object, keeping the text of the values that are not strings
+func parseJsonConfig(data []byte) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(raw))
+	for key, val := range raw {
+		var s string
+		if err := json.Unmarshal(val, &s); err == nil {
+			values[key] = s
+		} else {
+			values[key] = string(val)
+		}
+	}
+	return values, nil
+}
+
+// LoadFile applies the settings of the yaml or json file at path, an unknown key is an error to catch the typos
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return &ErrorConfig{Err: err, Msg: "ERROR: CONFIG FILE " + path + " should be readable"}
+	}
+	var values map[string]string
+	if strings.EqualFold(filepath.Ext(path), ".json") || bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		values, err = parseJsonConfig(data)
+	} else {
+		values, err = parseYamlConfig(data)
+	}
+	if err != nil {
+		return &ErrorConfig{Err: err, Msg: "ERROR: CONFIG FILE " + path + " should contain a flat yaml or json object"}
+	}
+	var errs []error
+	known := make(map[string]bool)
+	for _, f := range c.fields() {
+		known[f.key] = true
+		if val, exist := values[f.key]; exist {
+			if err := f.set(val); err != nil {
+				errs = append(errs, &ErrorConfig{Err: err, Msg: fmt.Sprintf("ERROR: CONFIG FILE %s key %s should contain %s", path, f.key, expected(f.value))})
+				continue
+			}
+			c.setSource(f.key, "file")
+		}
+	}
+	for key := range values {
+		if !known[key] {
+			errs = append(errs, &ErrorConfig{Err: fmt.Errorf("unknown key %q", key), Msg: "ERROR: CONFIG FILE " + path + " should only contain known keys"})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LoadEnv applies the settings defined in the env variables, an invalid value leaves the setting unchanged
+func (c *Config) LoadEnv() error {
+	var errs []error
+	for _, f := range c.fields() {
+		val, exist := os.LookupEnv(f.env)
+		if path, isFile := os.LookupEnv(f.env + "_FILE"); f.secret && !exist && isFile {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				errs = append(errs, &ErrorConfig{Err: err, Msg: fmt.Sprintf("ERROR: CONFIG ENV %s_FILE should contain the path of a readable file", f.env)})
+				continue
+			}
+			val, exist = string(content), true
+		}
+		if exist {
+			if err := f.set(val); err != nil {
+				errs = append(errs, &ErrorConfig{Err: err, Msg: fmt.Sprintf("ERROR: CONFIG ENV %s should contain %s", f.env, expected(f.value))})
+				continue
+			}
+			c.setSource(f.key, "env")
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Setting is one setting of a Config with its current value
+type Setting struct {
+	Key        string // json key in the config file
+	Env        string // name of the env variable
+	Value      interface{}
+	Reloadable bool // can be changed while the server is running
+	Secret     bool // the value must never be shown
+}
+
+// Settings returns all the settings of the Config, in declaration order
+func (c *Config) Settings() []Setting {
+	var settings []Setting
+	for _, f := range c.fields() {
+		settings = append(settings, Setting{Key: f.key, Env: f.env, Value: f.value.Interface(), Reloadable: f.reload, Secret: f.secret})
+	}
+	return settings
+}
+
+// KeepStatic copies in c the settings of prev that cannot be changed while running, it returns the keys of the
+// settings whose new value was ignored
+func (c *Config) KeepStatic(prev Config) []string {
+	var ignored []string
+	prevFields := prev.fields()
+	for i, f := range c.fields() {
+		if f.reload || reflect.DeepEqual(f.value.Interface(), prevFields[i].value.Interface()) {
+			continue
+		}
+		f.value.Set(prevFields[i].value)
+		c.setSource(f.key, prev.Source(f.key))
+		ignored = append(ignored, f.key)
+	}
+	return ignored
+}
+
+// Changed returns the keys of the settings having a different value in c and other
+func (c *Config) Changed(other Config) []string {
+	var changed []string
+	otherFields := other.fields()
+	for i, f := range c.fields() {
+		if !reflect.DeepEqual(f.value.Interface(), otherFields[i].value.Interface()) {
+			changed = append(changed, f.key)
+		}
+	}
+	return changed
+}
+
+// Validate checks the consistency of all the settings and returns all the problems found, the log levels and format
+// and the access log format are converted to lower case
+func (c *Config) Validate() error {
+	c.LogLevel = strings.ToLower(c.LogLevel)
+	c.LogFormat = strings.ToLower(c.LogFormat)
+	c.NotFoundLog = strings.ToLower(c.NotFoundLog)
+	c.AccessLogFormat = strings.ToLower(c.AccessLogFormat)
+	c.WebhookFormat = strings.ToLower(c.WebhookFormat)
+	c.AuthMode = strings.ToLower(c.AuthMode)
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, &ErrorConfig{Err: errors.New("invalid value"), Msg: "ERROR: CONFIG " + fmt.Sprintf(format, args...)})
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		invalid("port (env PORT) should contain an integer between 1 and 65535, got %d", c.Port)
+	}
+	if mode, err := strconv.ParseUint(c.SocketMode, 8, 32); err != nil || mode > 0o777 {
+		invalid("listen_socket_mode (env LISTEN_SOCKET_MODE) should be octal permissions like 0660, got %q", c.SocketMode)
+	}
+	if _, err := ParseListenList(c.ExtraListen); err != nil {
+		invalid("extra_listen (env EXTRA_LISTEN) should be a list of addresses like :9090 or :9443=tls, %v", err)
+	}
+	if c.SocketOnly && c.ListenSocket == "" {
+		invalid("listen_socket_only (env LISTEN_SOCKET_ONLY) needs the path of the socket in listen_socket (env LISTEN_SOCKET)")
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		invalid("log_level (env LOG_LEVEL) should be one of debug, info, warn or error, got %q", c.LogLevel)
+	}
+	if err := level.UnmarshalText([]byte(c.NotFoundLog)); err != nil {
+		invalid("not_found_log_level (env NOT_FOUND_LOG_LEVEL) should be one of debug, info, warn or error, got %q", c.NotFoundLog)
+	}
+	if c.LogFormat != LogFormatJson && c.LogFormat != LogFormatText {
+		invalid("log_format (env LOG_FORMAT) should be json or text, got %q", c.LogFormat)
+	}
+	for name, d := range map[string]time.Duration{"read_timeout": c.ReadTimeout, "write_timeout": c.WriteTimeout,
+		"idle_timeout": c.IdleTimeout, "shutdown_timeout": c.ShutdownTimeout} {
+		if d <= 0 {
+			invalid("%s should be a duration greater than 0, got %s", name, d)
+		}
+	}
+	if c.PreStopDelay < 0 {
+		invalid("pre_stop_delay (env PRE_STOP_DELAY_SECONDS) should be greater or equal to 0, got %s", c.PreStopDelay)
+	}
+	if c.StartupDelay < 0 {
+		invalid("startup_delay (env STARTUP_DELAY_SECONDS) should be greater or equal to 0, got %s", c.StartupDelay)
+	}
+	if c.WaitMax <= 0 || c.WaitMax >= c.WriteTimeout {
+		invalid("wait_max (env WAIT_MAX_SECONDS) should be greater than 0 and lower than write_timeout %s, got %s", c.WriteTimeout, c.WaitMax)
+	}
+	if c.WaitDefault < 0 || c.WaitDefault > c.WaitMax {
+		invalid("wait_default (env WAIT_DEFAULT_SECONDS) should be between 0 and wait_max %s, got %s", c.WaitMax, c.WaitDefault)
+	}
+	if c.WaitMaxConc < 0 {
+		invalid("wait_max_concurrent (env WAIT_MAX_CONCURRENT) should be greater or equal to 0, got %d", c.WaitMaxConc)
+	}
+	if strings.ContainsAny(c.CacheControl, "\r\n") {
+		invalid("cache_control (env CACHE_CONTROL) should be a single line header value, got %q", c.CacheControl)
+	}
+	switch c.AccessLogFormat {
+	case AccessLogCombined, AccessLogCommon, AccessLogJson:
+	default:
+		invalid("access_log_format (env ACCESS_LOG_FORMAT) should be combined, common or json, got %q", c.AccessLogFormat)
+	}
+	if c.RequestHistory < 0 || c.RequestHistory > maxRequestHistory {
+		invalid("request_history (env REQUEST_HISTORY) should be between 0 and %d, got %d", maxRequestHistory, c.RequestHistory)
+	}
+	if c.HeapBallastMb < 0 || c.HeapBallastMb > maxHeapBallastMb {
+		invalid("heap_ballast_mb (env HEAP_BALLAST_MB) should be between 0 and %d, got %d", maxHeapBallastMb, c.HeapBallastMb)
+	}
+	if c.CompressMin < 0 {
+		invalid("compress_min_bytes (env COMPRESS_MIN_BYTES) should be greater or equal to 0, got %d", c.CompressMin)
+	}
+	if c.RateLimitRps < 0 {
+		invalid("rate_limit_rps (env RATE_LIMIT_RPS) should be greater or equal to 0, got %g", c.RateLimitRps)
+	}
+	if c.RateLimitBurst < 1 {
+		invalid("rate_limit_burst (env RATE_LIMIT_BURST) should be greater than 0, got %d", c.RateLimitBurst)
+	}
+	if _, err := ParseCidrList(c.TrustedProxies); err != nil {
+		invalid("trusted_proxies (env TRUSTED_PROXIES) should contain CIDR ranges or ip addresses, got %q", c.TrustedProxies)
+	}
+	if _, err := ParseCidrList(c.IpAllowlist); err != nil {
+		invalid("ip_allowlist (env IP_ALLOWLIST) should contain CIDR ranges or ip addresses, got %q", c.IpAllowlist)
+	}
+	if _, err := ParseCidrList(c.IpDenylist); err != nil {
+		invalid("ip_denylist (env IP_DENYLIST) should contain CIDR ranges or ip addresses, got %q", c.IpDenylist)
+	}
+	if c.ProxyProtocol && c.TrustedProxies == "" {
+		invalid("proxy_protocol (env PROXY_PROTOCOL) needs the ranges of the load balancers in trusted_proxies")
+	}
+	if _, err := template.New("info_message").Parse(c.InfoMessage); err != nil {
+		invalid("info_message (env INFO_MESSAGE) should be a valid go template, got %q", c.InfoMessage)
+	}
+	for name, val := range map[string]string{"deploy_track (env DEPLOY_TRACK)": c.DeployTrack, "color (env COLOR)": c.Color} {
+		if !instanceLabelRegexp.MatchString(val) {
+			invalid("%s should contain only letters, digits, dots, dashes or underscores, got %q", name, val)
+		}
+	}
+	if c.PprofPort < 0 || c.PprofPort > 65535 || (c.PprofPort != 0 && c.PprofPort == c.Port) {
+		invalid("pprof_port (env PPROF_PORT) should be 0 or an integer between 1 and 65535 different from port, got %d", c.PprofPort)
+	}
+	if c.PreemptionReady && !c.PreemptionWatch {
+		invalid("preemption_readiness (env PREEMPTION_READINESS) needs preemption_watch to be true")
+	}
+	if c.LeaderElection != "" && !leaseNameRegexp.MatchString(c.LeaderElection) {
+		invalid("leader_election (env LEADER_ELECTION) should be a valid k8s object name, got %q", c.LeaderElection)
+	}
+	if c.LeaderLease < minLeaderLease {
+		invalid("leader_lease_duration (env LEADER_LEASE_DURATION) should be at least %s, got %s", minLeaderLease, c.LeaderLease)
+	}
+	for _, path := range c.ConfigMapPaths() {
+		if !filepath.IsAbs(path) {
+			invalid("configmap_watch (env CONFIGMAP_WATCH) should contain absolute paths, got %q", path)
+		}
+	}
+	for _, target := range c.ProbeUrls() {
+		if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("probe_targets (env PROBE_TARGETS) should contain http or https urls, got %q", target)
+		}
+	}
+	if c.ProbeInterval < minProbeInterval {
+		invalid("probe_interval (env PROBE_INTERVAL) should be at least %s, got %s", minProbeInterval, c.ProbeInterval)
+	}
+	if c.UploadDir != "" && !filepath.IsAbs(c.UploadDir) {
+		invalid("upload_dir (env UPLOAD_DIR) should be an absolute path, got %q", c.UploadDir)
+	}
+	if c.UploadTtl < minUploadTtl {
+		invalid("upload_ttl (env UPLOAD_TTL) should be at least %s, got %s", minUploadTtl, c.UploadTtl)
+	}
+	if c.UploadMaxMb < 1 || c.UploadMaxMb > maxUploadMaxMb {
+		invalid("upload_max_mb (env UPLOAD_MAX_MB) should be between 1 and %d, got %d", maxUploadMaxMb, c.UploadMaxMb)
+	}
+	if c.UploadQuotaMb < 1 {
+		invalid("upload_quota_mb (env UPLOAD_QUOTA_MB) should be at least 1, got %d", c.UploadQuotaMb)
+	}
+	if c.NtpServer != "" {
+		host, port, err := net.SplitHostPort(c.NtpAddress())
+		if p, _ := strconv.Atoi(port); err != nil || host == "" || p < 1 || p > 65535 || (strings.Contains(host, ":") && net.ParseIP(host) == nil) {
+			invalid("ntp_server (env NTP_SERVER) should be a host or host:port, got %q", c.NtpServer)
+		}
+	}
+	if c.WsMaxConns < 1 {
+		invalid("ws_max_connections (env WS_MAX_CONNECTIONS) should be greater than 0, got %d", c.WsMaxConns)
+	}
+	for _, origin := range SplitList(c.WsOrigins) {
+		if u, err := url.Parse(origin); origin != "*" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "") {
+			invalid("ws_allowed_origins (env WS_ALLOWED_ORIGINS) should be * or origins like https://dashboard.example.com, got %q", origin)
+		}
+	}
+	if c.MaxGoroutines < 0 {
+		invalid("liveness_max_goroutines (env LIVENESS_MAX_GOROUTINES) should be greater or equal to 0, got %d", c.MaxGoroutines)
+	}
+	if c.MaxHeapRatio < 0 || c.MaxHeapRatio > 1 {
+		invalid("liveness_max_heap_ratio (env LIVENESS_MAX_HEAP_RATIO) should be between 0 and 1, got %g", c.MaxHeapRatio)
+	}
+	if c.MaxSchedDelay < 0 {
+		invalid("liveness_max_scheduler_delay (env LIVENESS_MAX_SCHEDULER_DELAY) should be greater or equal to 0, got %s", c.MaxSchedDelay)
+	}
+	if c.AdminPort < 0 || c.AdminPort > 65535 || (c.AdminPort != 0 && (c.AdminPort == c.Port || c.AdminPort == c.PprofPort)) {
+		invalid("admin_port (env ADMIN_PORT) should be 0 or an integer between 1 and 65535 different from port and pprof_port, got %d", c.AdminPort)
+	}
+	if c.GrpcPort < 0 || c.GrpcPort > 65535 || (c.GrpcPort != 0 && (c.GrpcPort == c.Port || c.GrpcPort == c.PprofPort || c.GrpcPort == c.AdminPort)) {
+		invalid("grpc_port (env GRPC_PORT) should be 0 or an integer between 1 and 65535 different from port, pprof_port and admin_port, got %d", c.GrpcPort)
+	}
+	if c.Http3Port < 0 || c.Http3Port > 65535 {
+		// the udp port may be the tcp port of the main server, like 443 for both
+		invalid("http3_port (env HTTP3_PORT) should be 0 or an integer between 1 and 65535, got %d", c.Http3Port)
+	}
+	if c.WaitForTimeout < time.Second {
+		invalid("wait_for_timeout (env WAIT_FOR_TIMEOUT_SECONDS) should be at least 1s, got %s", c.WaitForTimeout)
+	}
+	if c.AccessTokenTtl < time.Second {
+		invalid("access_token_ttl (env ACCESS_TOKEN_TTL_SECONDS) should be at least 1s, got %s", c.AccessTokenTtl)
+	}
+	switch c.AuthMode {
+	case AuthModeNone:
+	case AuthModeBasic:
+		if c.AuthUsername == "" || c.AuthPassword == "" {
+			invalid("auth_username (env AUTH_USERNAME) and auth_password (env AUTH_PASSWORD or AUTH_PASSWORD_FILE) should be defined when auth_mode is basic")
+		}
+	case AuthModeBearer:
+		if c.AuthToken == "" {
+			invalid("auth_token (env AUTH_TOKEN or AUTH_TOKEN_FILE) should be defined when auth_mode is bearer")
+		}
+	case AuthModeJwt:
+		if u, err := url.Parse(c.AuthJwksUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("auth_jwks_url (env AUTH_JWKS_URL) should be the http or https url of the keys of the issuer when auth_mode is jwt, got %q", c.AuthJwksUrl)
+		}
+	default:
+		invalid("auth_mode (env AUTH_MODE) should be one of none, basic, bearer or jwt, got %q", c.AuthMode)
+	}
+	for _, pattern := range SplitList(c.EnvRedact) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			invalid("env_var_redact_patterns (env ENV_VAR_REDACT_PATTERNS) should contain valid regexp, got %q", pattern)
+		}
+	}
+	if c.DnsResolver != "" {
+		if _, _, err := net.SplitHostPort(c.DnsResolver); err != nil {
+			invalid("dns_resolver (env DNS_RESOLVER) should be a host:port like 10.96.0.10:53, got %q", c.DnsResolver)
+		}
+	}
+	for name, endpoint := range map[string]string{"otlp_endpoint (env OTEL_EXPORTER_OTLP_ENDPOINT)": c.OtlpEndpoint,
+		"otlp_traces_endpoint (env OTEL_EXPORTER_OTLP_TRACES_ENDPOINT)": c.OtlpTraces, "report_url (env REPORT_URL)": c.ReportUrl} {
+		if endpoint != "" && !isHttpUrl(endpoint) {
+			invalid("%s should be an http or https url, got %q", name, endpoint)
+		}
+	}
+	for _, header := range SplitList(c.OtlpHeaders) {
+		if key, _, found := strings.Cut(header, "="); !found || strings.TrimSpace(key) == "" {
+			// the headers are not shown since they usually contain a secret
+			invalid("otlp_headers (env OTEL_EXPORTER_OTLP_HEADERS) should be a comma separated list of key=value")
+			break
+		}
+	}
+	if c.OtlpProtocol != OtlpProtocolHttp && c.OtlpProtocol != OtlpProtocolGrpc {
+		invalid("otlp_protocol (env OTEL_EXPORTER_OTLP_PROTOCOL) should be http/protobuf or grpc, got %q", c.OtlpProtocol)
+	}
+	if !slices.Contains(otelSamplers, c.OtelSampler) {
+		invalid("otel_traces_sampler (env OTEL_TRACES_SAMPLER) should be one of %s, got %q", strings.Join(otelSamplers, ", "), c.OtelSampler)
+	}
+	if c.OtelSamplerArg != "" {
+		if ratio, err := strconv.ParseFloat(c.OtelSamplerArg, 64); err != nil || ratio < 0 || ratio > 1 {
+			invalid("otel_traces_sampler_arg (env OTEL_TRACES_SAMPLER_ARG) should be a ratio between 0 and 1, got %q", c.OtelSamplerArg)
+		}
+	}
+	for _, propagator := range SplitList(c.OtelPropagators) {
+		if !slices.Contains(otelPropagators, propagator) {
+			invalid("otel_propagators (env OTEL_PROPAGATORS) should only contain %s, got %q", strings.Join(otelPropagators, ", "), propagator)
+		}
+	}
+	for _, attribute := range SplitList(c.OtelResource) {
+		if key, _, found := strings.Cut(attribute, "="); !found || strings.TrimSpace(key) == "" {
+			invalid("otel_resource_attributes (env OTEL_RESOURCE_ATTRIBUTES) should be a comma separated list of key=value, got %q", attribute)
+		}
+	}
+	if (c.TlsCertFile == "") != (c.TlsKeyFile == "") {
+		invalid("tls_cert_file (env TLS_CERT_FILE) and tls_key_file (env TLS_KEY_FILE) should be defined together")
+	}
+	switch c.TlsClientAuth {
+	case TlsClientAuthNone, TlsClientAuthRequest:
+	case TlsClientAuthVerifyIfGiven, TlsClientAuthRequire:
+		if c.TlsClientCa == "" {
+			invalid("tls_client_ca_file (env TLS_CLIENT_CA_FILE) should be defined when tls_client_auth is %s", c.TlsClientAuth)
+		}
+	default:
+		invalid("tls_client_auth (env TLS_CLIENT_AUTH) should be one of none, request, verify_if_given or require, got %q", c.TlsClientAuth)
+	}
+	if c.ClusterDiscover != ClusterDiscoveryDns && c.ClusterDiscover != ClusterDiscoveryEndpoints {
+		invalid("cluster_discovery (env CLUSTER_DISCOVERY) should be dns or endpoints, got %q", c.ClusterDiscover)
+	}
+	if c.ClusterPort < 0 || c.ClusterPort > 65535 {
+		invalid("cluster_port (env CLUSTER_PORT) should be 0 or an integer between 1 and 65535, got %d", c.ClusterPort)
+	}
+	if c.ClusterScheme != "http" && c.ClusterScheme != "https" {
+		invalid("cluster_scheme (env CLUSTER_SCHEME) should be http or https, got %q", c.ClusterScheme)
+	}
+	if c.DatabaseUrl != "" {
+		// the url is not shown since it contains the password
+		if u, err := url.Parse(c.DatabaseUrl); err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql" && u.Scheme != "sqlite") {
+			invalid("database_url (env DATABASE_URL) should be a postgres:// or sqlite:// url")
+		} else if u.Scheme == "sqlite" && strings.TrimPrefix(c.DatabaseUrl, "sqlite://") == "" {
+			invalid("database_url (env DATABASE_URL) should give the path of the sqlite file like sqlite:///data/requests.db")
+		}
+	}
+	if c.StoreRetention <= 0 {
+		invalid("request_store_retention (env REQUEST_STORE_RETENTION) should be a duration greater than 0, got %s", c.StoreRetention)
+	}
+	if c.ReportInterval < minReportInterval {
+		invalid("report_interval (env REPORT_INTERVAL) should be at least %s, got %s", minReportInterval, c.ReportInterval)
+	}
+	for _, u := range SplitList(c.WebhookUrls) {
+		if !isHttpUrl(u) {
+			// the url is not shown since it may contain a secret
+			invalid("webhook_urls (env WEBHOOK_URLS) should contain http or https urls, got one of %d characters", len(u))
+		}
+	}
+	if c.WebhookFormat != WebhookFormatGeneric && c.WebhookFormat != WebhookFormatSlack {
+		invalid("webhook_format (env WEBHOOK_FORMAT) should be generic or slack, got %q", c.WebhookFormat)
+	}
+	if _, err := template.New("webhook_template").Parse(c.WebhookTemplate); err != nil {
+		invalid("webhook_template (env WEBHOOK_TEMPLATE) should be a valid go template, got %q", c.WebhookTemplate)
+	}
+	return errors.Join(errs...)
+}
+
+// Address returns the listen address of the http server
+func (c *Config) Address() string {
+	return fmt.Sprintf("%s:%d", c.ListenIp, c.Port)
+}
+
+// PprofAddress returns the listen address of the dedicated pprof server, empty when pprof uses the main port or is disabled
+func (c *Config) PprofAddress() string {
+	if !c.EnablePprof || c.PprofPort == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", c.ListenIp, c.PprofPort)
+}
+
+// AdminAddress returns the listen address of the admin server, empty when the admin endpoints use the main port
+func (c *Config) AdminAddress() string {
+	if c.AdminPort == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", c.ListenIp, c.AdminPort)
+}
+
+// GrpcAddress returns the listen address of the grpc server, empty when grpc is disabled
+func (c *Config) GrpcAddress() string {
+	if c.GrpcPort == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", c.ListenIp, c.GrpcPort)
+}
+
+// Http3Address returns the udp listen address of the HTTP/3 server, empty when HTTP/3 is disabled
+func (c *Config) Http3Address() string {
+	if c.Http3Port == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", c.ListenIp, c.Http3Port)
+}
+
+// ConfigMapPaths returns the paths of ConfigMapWatch
+func (c *Config) ConfigMapPaths() []string {
+	return SplitList(c.ConfigMapWatch)
+}
+
+// ProbeUrls returns the urls of ProbeTargets
+func (c *Config) ProbeUrls() []string {
+	return SplitList(c.ProbeTargets)
+}
+
+// NtpAddress returns the address of NtpServer with the ntp port when it has none, empty when it is not set
+func (c *Config) NtpAddress() string {
+	if c.NtpServer == "" {
+		return ""
+	}
+	if _, _, err := net.SplitHostPort(c.NtpServer); err == nil {
+		return c.NtpServer
+	}
+	return net.JoinHostPort(strings.Trim(c.NtpServer, "[]"), defaultNtpPort)
+}
+
+// TrustedProxyNets returns the ranges of TrustedProxies, the list must have been validated
+func (c *Config) TrustedProxyNets() []*net.IPNet {
+	nets, _ := ParseCidrList(c.TrustedProxies)
+	return nets
+}
+
+// IpAccessNets returns the ranges of IpAllowlist and IpDenylist, the lists must have been validated
+func (c *Config) IpAccessNets() (allow []*net.IPNet, deny []*net.IPNet) {
+	allow, _ = ParseCidrList(c.IpAllowlist)
+	deny, _ = ParseCidrList(c.IpDenylist)
+	return allow, deny
+}
+
+// Level returns the log level as a slog.Level, the level must have been validated
+func (c *Config) Level() slog.Level {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(c.LogLevel))
+	return level
+}
+
+// NotFoundLevel returns the level of the logs of the unknown paths as a slog.Level, the level must have been validated
+func (c *Config) NotFoundLevel() slog.Level {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(c.NotFoundLog))
+	return level
+}
+
+// SocketFileMode returns the permissions of the unix socket, the mode must have been validated
+func (c *Config) SocketFileMode() os.FileMode {
+	mode, _ := strconv.ParseUint(c.SocketMode, 8, 32)
+	return os.FileMode(mode)
+}
+
+// isHttpUrl returns true when u starts with http:// or https://
+func isHttpUrl(u string) bool {
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")
+}
+
+// SplitList returns the not empty trimmed elements of a comma separated list
+func SplitList(list string) []string {
+	var res []string
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// ParseCidrList parses a comma separated list of CIDR ranges or single ip addresses
+func ParseCidrList(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range SplitList(list) {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip address %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ListenSpec is an additional listen address served by the handlers of the main port
+type ListenSpec struct {
+	Address string
+	Tls     bool
+	CertDir string // directory holding the tls.crt and tls.key of this address, like a mounted kubernetes tls secret. empty to use the certificates of the main port
+}
+
+// ParseListenList parses a comma separated list of addresses like :9090, followed by =tls to serve HTTPS with the
+// certificates of the main port or by =/path/of/a/directory to serve HTTPS with the tls.crt and tls.key it contains
+func ParseListenList(list string) ([]ListenSpec, error) {
+	var specs []ListenSpec
+	seen := make(map[string]bool)
+	for _, entry := range SplitList(list) {
+		address, mode, _ := strings.Cut(entry, "=")
+		spec := ListenSpec{Address: strings.TrimSpace(address)}
+		_, port, err := net.SplitHostPort(spec.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q", spec.Address)
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			return nil, fmt.Errorf("invalid port in %q", spec.Address)
+		}
+		if seen[spec.Address] {
+			return nil, fmt.Errorf("address %q is given twice", spec.Address)
+		}
+		seen[spec.Address] = true
+		switch mode = strings.TrimSpace(mode); {
+		case mode == "" || mode == "plain":
+		case mode == "tls":
+			spec.Tls = true
+		case filepath.IsAbs(mode):
+			spec.Tls, spec.CertDir = true, mode
+		default:
+			return nil, fmt.Errorf("%q should be tls, plain or the absolute path of a directory with tls.crt and tls.key", mode)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// GetConfigFromEnv returns the default configuration overridden by the env variables, an invalid value keeps its default
+func GetConfigFromEnv() (Config, error) {
+	config := DefaultConfig()
+	if err := config.LoadEnv(); err != nil {
+		return config, err
+	}
+	return config, config.Validate()
+}
+
+// LoadConfig returns the configuration built from the defaults, the file given by -config or CONFIG_FILE,
+// the env variables and the command line flags in args, in this order of precedence, then validated.
+// it returns flag.ErrHelp after printing the usage when -h is given
+func LoadConfig(args []string) (Config, error) {
+	config := DefaultConfig()
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "yaml or json configuration file (env CONFIG_FILE)")
+	flagValues := make(map[string]*string)
+	for _, f := range config.fields() {
+		flagValues[f.flag] = fs.String(f.flag, "", fmt.Sprintf("%s (env %s, default %v)", f.help, f.env, f.value.Interface()))
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return config, err
+		}
+		return config, &ErrorConfig{Err: err, Msg: "ERROR: CONFIG FLAGS should be valid"}
+	}
+	if *configFile != "" {
+		config.file = *configFile
+		if err := config.LoadFile(*configFile); err != nil {
+			return config, err
+		}
+	}
+	if err := config.LoadEnv(); err != nil {
+		return config, err
+	}
+	var errs []error
+	set := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	for _, f := range config.fields() {
+		if set[f.flag] {
+			if err := f.set(*flagValues[f.flag]); err != nil {
+				errs = append(errs, &ErrorConfig{Err: err, Msg: fmt.Sprintf("ERROR: CONFIG FLAG -%s should contain %s", f.flag, expected(f.value))})
+				continue
+			}
+			config.setSource(f.key, "flag")
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return config, err
+	}
+	return config, config.Validate()
+}
@@ -0,0 +1,463 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetConfigFromEnv(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		env           map[string]string
+		check         func(t *testing.T, c Config)
+		wantErrPrefix string
+	}{
+		{name: "1: should return the default values when env variables are not set", check: func(t *testing.T, c Config) {
+			assert.Equal(t, DefaultConfig(), c)
+			assert.Equal(t, ":8080", c.Address())
+			assert.Equal(t, "", c.PprofAddress())
+		}},
+		{name: "2: should use PORT and LISTEN_IP", env: map[string]string{"PORT": "3333", "LISTEN_IP": "127.0.0.1"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "127.0.0.1:3333", c.Address())
+		}},
+		{name: "3: PORT not a number should be an error", env: map[string]string{"PORT": "aBigOne"}, wantErrPrefix: "ERROR: CONFIG ENV PORT should contain a valid integer"},
+		{name: "4: PORT < 1 should be an error", env: map[string]string{"PORT": "0"}, wantErrPrefix: "ERROR: CONFIG port (env PORT) should contain an integer between 1 and 65535"},
+		{name: "5: PORT > 65535 should be an error", env: map[string]string{"PORT": "70000"}, wantErrPrefix: "ERROR: CONFIG port (env PORT) should contain an integer between 1 and 65535"},
+		{name: "6: LOG_LEVEL and LOG_FORMAT are case insensitive", env: map[string]string{"LOG_LEVEL": "DEBUG", "LOG_FORMAT": "Text"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "debug", c.LogLevel)
+			assert.Equal(t, LogFormatText, c.LogFormat)
+		}},
+		{name: "7: unknown LOG_LEVEL should be an error", env: map[string]string{"LOG_LEVEL": "verbose"}, wantErrPrefix: "ERROR: CONFIG log_level"},
+		{name: "8: unknown LOG_FORMAT should be an error", env: map[string]string{"LOG_FORMAT": "xml"}, wantErrPrefix: "ERROR: CONFIG log_format"},
+		{name: "9: durations accept seconds or a go duration", env: map[string]string{"PRE_STOP_DELAY_SECONDS": "12", "HTTP_WRITE_TIMEOUT": "1m", "WAIT_MAX_SECONDS": "30"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, 12*time.Second, c.PreStopDelay)
+			assert.Equal(t, time.Minute, c.WriteTimeout)
+			assert.Equal(t, 30*time.Second, c.WaitMax)
+		}},
+		{name: "10: zero PRE_STOP_DELAY_SECONDS should disable the delay", env: map[string]string{"PRE_STOP_DELAY_SECONDS": "0"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, time.Duration(0), c.PreStopDelay)
+		}},
+		{name: "11: negative PRE_STOP_DELAY_SECONDS should be an error", env: map[string]string{"PRE_STOP_DELAY_SECONDS": "-1"}, wantErrPrefix: "ERROR: CONFIG pre_stop_delay"},
+		{name: "12: invalid duration should be an error", env: map[string]string{"PRE_STOP_DELAY_SECONDS": "soon"}, wantErrPrefix: "ERROR: CONFIG ENV PRE_STOP_DELAY_SECONDS should contain a duration"},
+		{name: "13: WAIT_MAX_SECONDS above the write timeout should be an error", env: map[string]string{"WAIT_MAX_SECONDS": "60"}, wantErrPrefix: "ERROR: CONFIG wait_max"},
+		{name: "14: zero WAIT_MAX_SECONDS should be an error", env: map[string]string{"WAIT_MAX_SECONDS": "0"}, wantErrPrefix: "ERROR: CONFIG wait_max"},
+		{name: "15: ENABLE_PPROF=true should mount pprof on the main port", env: map[string]string{"ENABLE_PPROF": "true"}, check: func(t *testing.T, c Config) {
+			assert.True(t, c.EnablePprof)
+			assert.Equal(t, "", c.PprofAddress())
+		}},
+		{name: "16: PPROF_PORT should give a dedicated address", env: map[string]string{"ENABLE_PPROF": "true", "PPROF_PORT": "6060"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, ":6060", c.PprofAddress())
+		}},
+		{name: "17: PPROF_PORT alone should not enable pprof", env: map[string]string{"PPROF_PORT": "6060"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "", c.PprofAddress())
+		}},
+		{name: "18: invalid ENABLE_PPROF should be an error", env: map[string]string{"ENABLE_PPROF": "yes please"}, wantErrPrefix: "ERROR: CONFIG ENV ENABLE_PPROF should contain true or false"},
+		{name: "19: PPROF_PORT equal to PORT should be an error", env: map[string]string{"ENABLE_PPROF": "true", "PPROF_PORT": "8080"}, wantErrPrefix: "ERROR: CONFIG pprof_port"},
+		{name: "20: ENABLE_CHAOS and ENABLE_LOAD should enable the endpoints", env: map[string]string{"ENABLE_CHAOS": "true", "ENABLE_LOAD": "1"}, check: func(t *testing.T, c Config) {
+			assert.True(t, c.EnableChaos)
+			assert.True(t, c.EnableLoad)
+		}},
+		{name: "21: invalid ENABLE_CHAOS should be an error", env: map[string]string{"ENABLE_CHAOS": "maybe"}, wantErrPrefix: "ERROR: CONFIG ENV ENABLE_CHAOS should contain true or false"},
+		{name: "22: ADMIN_PORT should give the admin address", env: map[string]string{"ADMIN_PORT": "8081"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, ":8081", c.AdminAddress())
+		}},
+		{name: "23: ADMIN_PORT equal to PORT should be an error", env: map[string]string{"ADMIN_PORT": "8080"}, wantErrPrefix: "ERROR: CONFIG admin_port"},
+		{name: "24: LIVENESS_MAX_HEAP_RATIO above 1 should be an error", env: map[string]string{"LIVENESS_MAX_HEAP_RATIO": "1.5"}, wantErrPrefix: "ERROR: CONFIG liveness_max_heap_ratio"},
+		{name: "25: PREEMPTION_READINESS without PREEMPTION_WATCH should be an error", env: map[string]string{"PREEMPTION_READINESS": "true"}, wantErrPrefix: "ERROR: CONFIG preemption_readiness"},
+		{name: "26: GRPC_PORT should give the grpc address", env: map[string]string{"GRPC_PORT": "9090"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, ":9090", c.GrpcAddress())
+		}},
+		{name: "27: GRPC_PORT equal to ADMIN_PORT should be an error", env: map[string]string{"GRPC_PORT": "8081", "ADMIN_PORT": "8081"}, wantErrPrefix: "ERROR: CONFIG grpc_port"},
+		{name: "28: STARTUP_DELAY_SECONDS should give the warm-up of /started", env: map[string]string{"STARTUP_DELAY_SECONDS": "20"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, 20*time.Second, c.StartupDelay)
+		}},
+		{name: "29: negative STARTUP_DELAY_SECONDS should be an error", env: map[string]string{"STARTUP_DELAY_SECONDS": "-5"}, wantErrPrefix: "ERROR: CONFIG startup_delay"},
+		{name: "30: REQUEST_HISTORY=0 should disable the history", env: map[string]string{"REQUEST_HISTORY": "0"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, 0, c.RequestHistory)
+		}},
+		{name: "31: too big REQUEST_HISTORY should be an error", env: map[string]string{"REQUEST_HISTORY": "1000000"}, wantErrPrefix: "ERROR: CONFIG request_history"},
+		{name: "32: INFO_MESSAGE should be kept as a template", env: map[string]string{"INFO_MESSAGE": "canary {{.Version}}"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "canary {{.Version}}", c.InfoMessage)
+		}},
+		{name: "33: invalid INFO_MESSAGE template should be an error", env: map[string]string{"INFO_MESSAGE": "canary {{.Version"}, wantErrPrefix: "ERROR: CONFIG info_message"},
+		{name: "34: DEPLOY_TRACK and COLOR should be kept", env: map[string]string{"DEPLOY_TRACK": "canary", "COLOR": "blue"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "canary", c.DeployTrack)
+			assert.Equal(t, "blue", c.Color)
+		}},
+		{name: "35: COLOR unsafe in a header should be an error", env: map[string]string{"COLOR": "blue; track=stable"}, wantErrPrefix: "ERROR: CONFIG color"},
+		{name: "36: FAULT_HEADERS should enable the fault injection headers", env: map[string]string{"FAULT_HEADERS": "true"}, check: func(t *testing.T, c Config) {
+			assert.True(t, c.FaultHeaders)
+		}},
+		{name: "37: AUTO_MAXPROCS=false should keep the GOMAXPROCS of the go runtime", env: map[string]string{"AUTO_MAXPROCS": "false"}, check: func(t *testing.T, c Config) {
+			assert.False(t, c.AutoMaxProcs)
+			assert.True(t, DefaultConfig().AutoMaxProcs)
+		}},
+		{name: "38: negative HEAP_BALLAST_MB should be an error", env: map[string]string{"HEAP_BALLAST_MB": "-1"}, wantErrPrefix: "ERROR: CONFIG heap_ballast_mb"},
+		{name: "39: negative WAIT_MAX_CONCURRENT should be an error", env: map[string]string{"WAIT_MAX_CONCURRENT": "-1"}, wantErrPrefix: "ERROR: CONFIG wait_max_concurrent"},
+		{name: "40: NOT_FOUND_LOG_LEVEL should be converted to lower case", env: map[string]string{"NOT_FOUND_LOG_LEVEL": "WARN"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "warn", c.NotFoundLog)
+			assert.Equal(t, slog.LevelWarn, c.NotFoundLevel())
+		}},
+		{name: "41: invalid NOT_FOUND_LOG_LEVEL should be an error", env: map[string]string{"NOT_FOUND_LOG_LEVEL": "loud"}, wantErrPrefix: "ERROR: CONFIG not_found_log_level"},
+		{name: "42: CACHE_CONTROL should replace the default no-cache", env: map[string]string{"CACHE_CONTROL": "max-age=5"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "max-age=5", c.CacheControl)
+			assert.Equal(t, "no-cache", DefaultConfig().CacheControl)
+		}},
+		{name: "43: CACHE_CONTROL on two lines should be an error", env: map[string]string{"CACHE_CONTROL": "no-cache\r\nX-Evil: 1"}, wantErrPrefix: "ERROR: CONFIG cache_control"},
+		{name: "44: RESPONSE_ENVELOPE should enable the envelope of the json answers", env: map[string]string{"RESPONSE_ENVELOPE": "true"}, check: func(t *testing.T, c Config) {
+			assert.True(t, c.RespEnvelope)
+			assert.False(t, DefaultConfig().RespEnvelope)
+		}},
+		{name: "45: HTTP2=false should disable HTTP/2 and h2c", env: map[string]string{"HTTP2": "false"}, check: func(t *testing.T, c Config) {
+			assert.False(t, c.Http2)
+			assert.True(t, DefaultConfig().Http2)
+		}},
+		{name: "46: HTTP3_PORT may be the port of the main server", env: map[string]string{"PORT": "8443", "HTTP3_PORT": "8443"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, DefaultListenIp+":8443", c.Http3Address())
+		}},
+		{name: "47: HTTP3_PORT out of range should be an error", env: map[string]string{"HTTP3_PORT": "70000"}, wantErrPrefix: "ERROR: CONFIG http3_port"},
+		{name: "48: LISTEN_SOCKET_MODE should give the permissions of the socket", env: map[string]string{"LISTEN_SOCKET": "/tmp/info.sock", "LISTEN_SOCKET_MODE": "0600"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, os.FileMode(0o600), c.SocketFileMode())
+			defaults := DefaultConfig()
+			assert.Equal(t, os.FileMode(0o660), defaults.SocketFileMode())
+		}},
+		{name: "49: invalid LISTEN_SOCKET_MODE should be an error", env: map[string]string{"LISTEN_SOCKET_MODE": "rw-rw----"}, wantErrPrefix: "ERROR: CONFIG listen_socket_mode"},
+		{name: "50: LISTEN_SOCKET_ONLY without LISTEN_SOCKET should be an error", env: map[string]string{"LISTEN_SOCKET_ONLY": "true"}, wantErrPrefix: "ERROR: CONFIG listen_socket_only"},
+		{name: "51: EXTRA_LISTEN should accept plain, tls and certificate directories", env: map[string]string{"EXTRA_LISTEN": ":9090, :9443=tls,127.0.0.1:8443=/etc/other-tls"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, ":9090, :9443=tls,127.0.0.1:8443=/etc/other-tls", c.ExtraListen)
+		}},
+		{name: "52: EXTRA_LISTEN without a port should be an error", env: map[string]string{"EXTRA_LISTEN": "localhost"}, wantErrPrefix: "ERROR: CONFIG extra_listen"},
+		{name: "53: IP_ALLOWLIST and IP_DENYLIST should accept ranges and addresses", env: map[string]string{"IP_ALLOWLIST": "10.0.0.0/8, 192.168.1.4", "IP_DENYLIST": "10.1.0.0/16"}, check: func(t *testing.T, c Config) {
+			allow, deny := c.IpAccessNets()
+			assert.Len(t, allow, 2)
+			assert.Len(t, deny, 1)
+		}},
+		{name: "54: IP_DENYLIST with an invalid range should be an error", env: map[string]string{"IP_DENYLIST": "10.0.0.0/33"}, wantErrPrefix: "ERROR: CONFIG ip_denylist"},
+		{name: "55: MOUNTS_CONTENTS should be read", env: map[string]string{"MOUNTS_CONTENTS": "true"}, check: func(t *testing.T, c Config) {
+			assert.True(t, c.MountsContents)
+		}},
+		{name: "56: CONFIGMAP_WATCH should be read", env: map[string]string{"CONFIGMAP_WATCH": "/etc/app, /etc/other/settings.yaml"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, []string{"/etc/app", "/etc/other/settings.yaml"}, c.ConfigMapPaths())
+		}},
+		{name: "57: CONFIGMAP_WATCH with a relative path should be an error", env: map[string]string{"CONFIGMAP_WATCH": "etc/app"}, wantErrPrefix: "ERROR: CONFIG configmap_watch"},
+		{name: "58: LEADER_ELECTION and LEADER_LEASE_DURATION should be read", env: map[string]string{"LEADER_ELECTION": "go-info-leader", "LEADER_LEASE_DURATION": "30"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "go-info-leader", c.LeaderElection)
+			assert.Equal(t, 30*time.Second, c.LeaderLease)
+		}},
+		{name: "59: LEADER_ELECTION with an invalid name should be an error", env: map[string]string{"LEADER_ELECTION": "Go_Info"}, wantErrPrefix: "ERROR: CONFIG leader_election"},
+		{name: "60: LEADER_LEASE_DURATION too short should be an error", env: map[string]string{"LEADER_LEASE_DURATION": "1s"}, wantErrPrefix: "ERROR: CONFIG leader_lease_duration"},
+		{name: "61: K8S_EVENTS should be read", env: map[string]string{"K8S_EVENTS": "false"}, check: func(t *testing.T, c Config) {
+			assert.False(t, c.K8sEvents)
+		}},
+		{name: "62: NTP_SERVER should be read with the ntp port", env: map[string]string{"NTP_SERVER": "pool.ntp.org"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "pool.ntp.org", c.NtpServer)
+			assert.Equal(t, "pool.ntp.org:123", c.NtpAddress())
+		}},
+		{name: "63: NTP_SERVER with an invalid port should be an error", env: map[string]string{"NTP_SERVER": "pool.ntp.org:a:b"}, wantErrPrefix: "ERROR: CONFIG ntp_server"},
+		{name: "64: BINARY_RELOAD and PID_FILE should be read", env: map[string]string{"BINARY_RELOAD": "true", "PID_FILE": "/run/goinfo.pid"}, check: func(t *testing.T, c Config) {
+			assert.True(t, c.BinaryReload)
+			assert.Equal(t, "/run/goinfo.pid", c.PidFile)
+		}},
+		{name: "65: PROBE_TARGETS and PROBE_INTERVAL should be read", env: map[string]string{"PROBE_TARGETS": "http://api:8080/health, https://example.com", "PROBE_INTERVAL": "30s"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, []string{"http://api:8080/health", "https://example.com"}, c.ProbeUrls())
+			assert.Equal(t, 30*time.Second, c.ProbeInterval)
+		}},
+		{name: "66: PROBE_TARGETS without a scheme should be an error", env: map[string]string{"PROBE_TARGETS": "api:8080/health"}, wantErrPrefix: "ERROR: CONFIG probe_targets"},
+		{name: "67: PROBE_INTERVAL below a second should be an error", env: map[string]string{"PROBE_INTERVAL": "100ms"}, wantErrPrefix: "ERROR: CONFIG probe_interval"},
+		{name: "68: UPLOAD_DIR, UPLOAD_TTL and UPLOAD_MAX_MB should be read", env: map[string]string{"UPLOAD_DIR": "/uploads", "UPLOAD_TTL": "10m", "UPLOAD_MAX_MB": "20"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "/uploads", c.UploadDir)
+			assert.Equal(t, 10*time.Minute, c.UploadTtl)
+			assert.Equal(t, 20, c.UploadMaxMb)
+		}},
+		{name: "69: a relative UPLOAD_DIR should be an error", env: map[string]string{"UPLOAD_DIR": "uploads"}, wantErrPrefix: "ERROR: CONFIG upload_dir"},
+		{name: "70: UPLOAD_MAX_MB above 1024 should be an error", env: map[string]string{"UPLOAD_MAX_MB": "2048"}, wantErrPrefix: "ERROR: CONFIG upload_max_mb"},
+		{name: "71: ACCESS_TOKEN_TTL_SECONDS should be a number of seconds", env: map[string]string{"ACCESS_TOKEN_TTL_SECONDS": "120", "API_TOKEN": "t0ken"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, 2*time.Minute, c.AccessTokenTtl)
+			assert.Equal(t, "t0ken", c.ApiToken)
+		}},
+		{name: "72: ACCESS_TOKEN_TTL_SECONDS of 0 should be an error", env: map[string]string{"ACCESS_TOKEN_TTL_SECONDS": "0"}, wantErrPrefix: "ERROR: CONFIG access_token_ttl"},
+		{name: "73: a secret should be read from the file of its _FILE env variable", env: map[string]string{"API_TOKEN_FILE": secretFile}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "s3cret", c.ApiToken, "the trailing new line should be removed")
+		}},
+		{name: "74: the env variable of a secret should have precedence over its _FILE", env: map[string]string{"API_TOKEN": "direct", "API_TOKEN_FILE": secretFile},
+			check: func(t *testing.T, c Config) {
+				assert.Equal(t, "direct", c.ApiToken)
+			}},
+		{name: "75: a missing _FILE of a secret should be an error", env: map[string]string{"API_TOKEN_FILE": "/missing/token"}, wantErrPrefix: "ERROR: CONFIG ENV API_TOKEN_FILE"},
+		{name: "76: the _FILE of a setting that is not a secret should be ignored", env: map[string]string{"COLOR_FILE": secretFile}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "", c.Color)
+		}},
+		{name: "77: an OTEL_EXPORTER_OTLP_ENDPOINT without scheme should be an error", env: map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "otel-collector:4318"},
+			wantErrPrefix: "ERROR: CONFIG otlp_endpoint"},
+		{name: "78: malformed OTEL_EXPORTER_OTLP_HEADERS should be an error", env: map[string]string{"OTEL_EXPORTER_OTLP_HEADERS": "api-key"}, wantErrPrefix: "ERROR: CONFIG otlp_headers"},
+		{name: "79: an invalid ENV_VAR_REDACT_PATTERNS should be an error", env: map[string]string{"ENV_VAR_REDACT_PATTERNS": "(["}, wantErrPrefix: "ERROR: CONFIG env_var_redact_patterns"},
+		{name: "80: a DNS_RESOLVER without port should be an error", env: map[string]string{"DNS_RESOLVER": "10.96.0.10"}, wantErrPrefix: "ERROR: CONFIG dns_resolver"},
+		{name: "81: verify_if_given TLS_CLIENT_AUTH without TLS_CLIENT_CA_FILE should be an error", env: map[string]string{"TLS_CLIENT_AUTH": "verify_if_given"},
+			wantErrPrefix: "ERROR: CONFIG tls_client_ca_file"},
+		{name: "82: an unknown TLS_CLIENT_AUTH should be an error", env: map[string]string{"TLS_CLIENT_AUTH": "always"}, wantErrPrefix: "ERROR: CONFIG tls_client_auth"},
+		{name: "83: the settings of /cluster should be read", env: map[string]string{"CLUSTER_SERVICE": "go-info", "CLUSTER_DISCOVERY": "endpoints",
+			"CLUSTER_PORT": "9443", "CLUSTER_SCHEME": "https"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "go-info", c.ClusterService)
+			assert.Equal(t, ClusterDiscoveryEndpoints, c.ClusterDiscover)
+			assert.Equal(t, 9443, c.ClusterPort)
+			assert.Equal(t, "https", c.ClusterScheme)
+		}},
+		{name: "84: an unknown CLUSTER_DISCOVERY should be an error", env: map[string]string{"CLUSTER_DISCOVERY": "mdns"}, wantErrPrefix: "ERROR: CONFIG cluster_discovery"},
+		{name: "85: CLUSTER_PORT above 65535 should be an error", env: map[string]string{"CLUSTER_PORT": "70000"}, wantErrPrefix: "ERROR: CONFIG cluster_port"},
+		{name: "86: an unknown CLUSTER_SCHEME should be an error", env: map[string]string{"CLUSTER_SCHEME": "ftp"}, wantErrPrefix: "ERROR: CONFIG cluster_scheme"},
+		{name: "87: the settings of the request store should be read", env: map[string]string{"DATABASE_URL": "sqlite:///data/requests.db", "REQUEST_STORE_RETENTION": "72h"},
+			check: func(t *testing.T, c Config) {
+				assert.Equal(t, "sqlite:///data/requests.db", c.DatabaseUrl)
+				assert.Equal(t, 72*time.Hour, c.StoreRetention)
+			}},
+		{name: "88: a DATABASE_URL of an unknown database should be an error without the password", env: map[string]string{"DATABASE_URL": "mysql://go:s3cret@db/info"},
+			wantErrPrefix: "ERROR: CONFIG database_url"},
+		{name: "89: a sqlite DATABASE_URL without path should be an error", env: map[string]string{"DATABASE_URL": "sqlite://"}, wantErrPrefix: "ERROR: CONFIG database_url"},
+		{name: "90: an invalid REQUEST_STORE_RETENTION should be an error", env: map[string]string{"REQUEST_STORE_RETENTION": "forever"}, wantErrPrefix: "ERROR: CONFIG ENV REQUEST_STORE_RETENTION"},
+		{name: "91: the settings of /render should be read", env: map[string]string{"RENDER_TEMPLATE_FILE": "/etc/render.tmpl"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "/etc/render.tmpl", c.RenderTemplate)
+			assert.Equal(t, "text/plain; charset=UTF-8", c.RenderType)
+		}},
+		{name: "92: the report settings should be read", env: map[string]string{"REPORT_URL": "http://collector", "REPORT_INTERVAL": "15s", "REPORT_TOKEN_FILE": secretFile},
+			check: func(t *testing.T, c Config) {
+				assert.Equal(t, "http://collector", c.ReportUrl)
+				assert.Equal(t, 15*time.Second, c.ReportInterval)
+				assert.Equal(t, "s3cret", c.ReportToken)
+			}},
+		{name: "93: a REPORT_URL without scheme should be an error", env: map[string]string{"REPORT_URL": "collector:8080"}, wantErrPrefix: "ERROR: CONFIG report_url"},
+		{name: "94: REPORT_INTERVAL below a second should be an error", env: map[string]string{"REPORT_INTERVAL": "10ms"}, wantErrPrefix: "ERROR: CONFIG report_interval"},
+		{name: "95: the webhook settings should be read", env: map[string]string{"WEBHOOK_URLS": "https://hooks/a,http://hooks/b", "WEBHOOK_FORMAT": "Slack",
+			"WEBHOOK_EVENTS": "Started"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "https://hooks/a,http://hooks/b", c.WebhookUrls)
+			assert.Equal(t, WebhookFormatSlack, c.WebhookFormat, "the format should be converted to lower case")
+			assert.Equal(t, "Started", c.WebhookEvents)
+		}},
+		{name: "96: WEBHOOK_URLS without scheme should be an error", env: map[string]string{"WEBHOOK_URLS": "hooks/a"}, wantErrPrefix: "ERROR: CONFIG webhook_urls"},
+		{name: "97: an unknown WEBHOOK_FORMAT should be an error", env: map[string]string{"WEBHOOK_FORMAT": "teams"}, wantErrPrefix: "ERROR: CONFIG webhook_format"},
+		{name: "98: an invalid WEBHOOK_TEMPLATE should be an error", env: map[string]string{"WEBHOOK_TEMPLATE": "{{.Reason"}, wantErrPrefix: "ERROR: CONFIG webhook_template"},
+		{name: "99: the dependencies of WAIT_FOR should be read", env: map[string]string{"WAIT_FOR": "tcp://postgres:5432", "WAIT_FOR_TIMEOUT_SECONDS": "30"},
+			check: func(t *testing.T, c Config) {
+				assert.Equal(t, "tcp://postgres:5432", c.WaitFor)
+				assert.Equal(t, 30*time.Second, c.WaitForTimeout)
+			}},
+		{name: "100: WAIT_FOR_TIMEOUT_SECONDS of 0 should be an error", env: map[string]string{"WAIT_FOR_TIMEOUT_SECONDS": "0"}, wantErrPrefix: "ERROR: CONFIG wait_for_timeout"},
+		{name: "101: TLS_CERT_FILE and TLS_KEY_FILE should be read", env: map[string]string{"TLS_CERT_FILE": "/tls/tls.crt", "TLS_KEY_FILE": "/tls/tls.key"},
+			check: func(t *testing.T, c Config) {
+				assert.Equal(t, "/tls/tls.crt", c.TlsCertFile)
+				assert.Equal(t, "/tls/tls.key", c.TlsKeyFile)
+			}},
+		{name: "102: TLS_CERT_FILE without TLS_KEY_FILE should be an error", env: map[string]string{"TLS_CERT_FILE": "/tls/tls.crt"}, wantErrPrefix: "ERROR: CONFIG tls_cert_file"},
+		{name: "103: TLS_KEY_FILE without TLS_CERT_FILE should be an error", env: map[string]string{"TLS_KEY_FILE": "/tls/tls.key"}, wantErrPrefix: "ERROR: CONFIG tls_cert_file"},
+		{name: "104: the basic AUTH_MODE should read the credentials, the password from its _FILE", env: map[string]string{"AUTH_MODE": "Basic", "AUTH_USERNAME": "admin",
+			"AUTH_PASSWORD_FILE": secretFile}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, AuthModeBasic, c.AuthMode, "the mode should be converted to lower case")
+			assert.Equal(t, "admin", c.AuthUsername)
+			assert.Equal(t, "s3cret", c.AuthPassword)
+		}},
+		{name: "105: the basic AUTH_MODE without AUTH_PASSWORD should be an error", env: map[string]string{"AUTH_MODE": "basic", "AUTH_USERNAME": "admin"},
+			wantErrPrefix: "ERROR: CONFIG auth_username"},
+		{name: "106: the bearer AUTH_MODE without AUTH_TOKEN should be an error", env: map[string]string{"AUTH_MODE": "bearer"}, wantErrPrefix: "ERROR: CONFIG auth_token"},
+		{name: "107: an unknown AUTH_MODE should be an error", env: map[string]string{"AUTH_MODE": "digest"}, wantErrPrefix: "ERROR: CONFIG auth_mode"},
+		{name: "108: the jwt AUTH_MODE should read the jwks url, issuer, audience and claims", env: map[string]string{"AUTH_MODE": "jwt", "AUTH_JWKS_URL": "https://issuer.example/keys",
+			"AUTH_JWT_ISSUER": "https://issuer.example", "AUTH_JWT_AUDIENCE": "go-info", "AUTH_JWT_CLAIMS": "sub, email"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "https://issuer.example/keys", c.AuthJwksUrl)
+			assert.Equal(t, "https://issuer.example", c.AuthJwtIssuer)
+			assert.Equal(t, "go-info", c.AuthJwtAudience)
+			assert.Equal(t, "sub, email", c.AuthJwtClaims)
+		}},
+		{name: "109: the jwt AUTH_MODE without AUTH_JWKS_URL should be an error", env: map[string]string{"AUTH_MODE": "jwt"}, wantErrPrefix: "ERROR: CONFIG auth_jwks_url"},
+		{name: "110: WS_ALLOWED_ORIGINS should accept origins and *", env: map[string]string{"WS_ALLOWED_ORIGINS": "https://dashboard.example.com, http://localhost:3000/, *"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, "https://dashboard.example.com, http://localhost:3000/, *", c.WsOrigins)
+		}},
+		{name: "111: WS_ALLOWED_ORIGINS with a path should be an error", env: map[string]string{"WS_ALLOWED_ORIGINS": "https://dashboard.example.com/stats"}, wantErrPrefix: "ERROR: CONFIG ws_allowed_origins"},
+		{name: "112: the OpenTelemetry sampler, propagators and resource should be read", env: map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc", "OTEL_TRACES_SAMPLER": "parentbased_traceidratio",
+			"OTEL_TRACES_SAMPLER_ARG": "0.25", "OTEL_PROPAGATORS": "tracecontext", "OTEL_RESOURCE_ATTRIBUTES": "deployment.environment=prod"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, OtlpProtocolGrpc, c.OtlpProtocol)
+			assert.Equal(t, "parentbased_traceidratio", c.OtelSampler)
+			assert.Equal(t, "0.25", c.OtelSamplerArg)
+			assert.Equal(t, "tracecontext", c.OtelPropagators)
+			assert.Equal(t, "deployment.environment=prod", c.OtelResource)
+		}},
+		{name: "113: the http/json OTEL_EXPORTER_OTLP_PROTOCOL should be an error", env: map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "http/json"}, wantErrPrefix: "ERROR: CONFIG otlp_protocol"},
+		{name: "114: an unknown OTEL_TRACES_SAMPLER should be an error", env: map[string]string{"OTEL_TRACES_SAMPLER": "jaeger_remote"}, wantErrPrefix: "ERROR: CONFIG otel_traces_sampler"},
+		{name: "115: an OTEL_TRACES_SAMPLER_ARG above 1 should be an error", env: map[string]string{"OTEL_TRACES_SAMPLER_ARG": "1.5"}, wantErrPrefix: "ERROR: CONFIG otel_traces_sampler_arg"},
+		{name: "116: the b3 OTEL_PROPAGATORS should be an error", env: map[string]string{"OTEL_PROPAGATORS": "tracecontext,b3"}, wantErrPrefix: "ERROR: CONFIG otel_propagators"},
+		{name: "117: malformed OTEL_RESOURCE_ATTRIBUTES should be an error", env: map[string]string{"OTEL_RESOURCE_ATTRIBUTES": "prod"}, wantErrPrefix: "ERROR: CONFIG otel_resource_attributes"},
+		{name: "118: UPLOAD_QUOTA_MB should be read", env: map[string]string{"UPLOAD_QUOTA_MB": "500"}, check: func(t *testing.T, c Config) {
+			assert.Equal(t, 500, c.UploadQuotaMb)
+		}},
+		{name: "119: UPLOAD_QUOTA_MB of 0 should be an error", env: map[string]string{"UPLOAD_QUOTA_MB": "0"}, wantErrPrefix: "ERROR: CONFIG upload_quota_mb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, val := range tt.env {
+				t.Setenv(name, val)
+			}
+			got, err := GetConfigFromEnv()
+			if tt.wantErrPrefix != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tt.wantErrPrefix)
+					assert.NotContains(t, err.Error(), "s3cret", "the secrets should not be shown")
+				}
+				return
+			}
+			assert.NoError(t, err)
+			tt.check(t, got)
+		})
+	}
+}
+
+func TestParseListenList(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    []ListenSpec
+		wantErr bool
+	}{
+		{name: "1: empty list should give no address"},
+		{name: "2: modes should be parsed", list: ":9090,:9091=plain, :9443=tls ,:8443=/etc/other-tls",
+			want: []ListenSpec{{Address: ":9090"}, {Address: ":9091"}, {Address: ":9443", Tls: true}, {Address: ":8443", Tls: true, CertDir: "/etc/other-tls"}}},
+		{name: "3: port out of range should be an error", list: ":70000", wantErr: true},
+		{name: "4: same address twice should be an error", list: ":9090,:9090=tls", wantErr: true},
+		{name: "5: relative directory should be an error", list: ":9443=certs", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseListenList(tt.list)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseYamlConfig(t *testing.T) {
+	values, err := parseYamlConfig([]byte(`---
+# server settings
+port: 9090
+listen_ip: "127.0.0.1"
+log_level: 'debug'
+write_timeout: 20s # longer for the slow clients
+
+enable_chaos: true
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"port": "9090", "listen_ip": "127.0.0.1", "log_level": "debug", "write_timeout": "20s", "enable_chaos": "true"}, values)
+	_, err = parseYamlConfig([]byte("port 9090\n"))
+	assert.Error(t, err, "a document that is not a mapping should be an error")
+	_, err = parseYamlConfig([]byte("server:\n  port: 9090\n"))
+	assert.Error(t, err, "nested values should be an error")
+	_, err = parseYamlConfig([]byte("cors_origins:\n  - https://example.com\n"))
+	assert.Error(t, err, "a sequence should be an error, the lists are comma separated strings")
+	values, err = parseYamlConfig([]byte("log_format: |\n  text\nversion_tag: 1.10\nip_allowlist:\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"log_format": "text\n", "version_tag": "1.10", "ip_allowlist": ""}, values, "the scalars should keep their text")
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	yamlFile := writeFile("config.yaml", "port: 9090\nlog_format: text\nwrite_timeout: 20s\nwait_max: 15\n")
+	jsonFile := writeFile("config.json", `{"port": 9191, "enable_load": true, "idle_timeout": "30s"}`)
+	typoFile := writeFile("typo.yaml", "prot: 9090\n")
+
+	t.Run("1: the file should override the defaults", func(t *testing.T) {
+		c, err := LoadConfig([]string{"-config", yamlFile})
+		assert.NoError(t, err)
+		assert.Equal(t, 9090, c.Port)
+		assert.Equal(t, LogFormatText, c.LogFormat)
+		assert.Equal(t, 20*time.Second, c.WriteTimeout)
+		assert.Equal(t, 15*time.Second, c.WaitMax)
+		assert.Equal(t, DefaultReadTimeout, c.ReadTimeout)
+	})
+	t.Run("2: CONFIG_FILE should give the file and json should be accepted", func(t *testing.T) {
+		t.Setenv("CONFIG_FILE", jsonFile)
+		c, err := LoadConfig(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 9191, c.Port)
+		assert.True(t, c.EnableLoad)
+		assert.Equal(t, 30*time.Second, c.IdleTimeout)
+	})
+	t.Run("3: env should override the file and flags should override env", func(t *testing.T) {
+		t.Setenv("PORT", "7070")
+		t.Setenv("LOG_FORMAT", "json")
+		c, err := LoadConfig([]string{"-config", yamlFile, "-port", "6060"})
+		assert.NoError(t, err)
+		assert.Equal(t, 6060, c.Port)
+		assert.Equal(t, LogFormatJson, c.LogFormat)
+	})
+	t.Run("4: an unknown key in the file should be an error", func(t *testing.T) {
+		_, err := LoadConfig([]string{"-config", typoFile})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "ERROR: CONFIG FILE")
+			assert.Contains(t, err.Error(), "prot")
+		}
+	})
+	t.Run("5: a missing file should be an error", func(t *testing.T) {
+		_, err := LoadConfig([]string{"-config", filepath.Join(dir, "missing.yaml")})
+		assert.Error(t, err)
+	})
+	t.Run("6: an invalid flag value should be an error", func(t *testing.T) {
+		_, err := LoadConfig([]string{"-write-timeout", "fast"})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "ERROR: CONFIG FLAG -write-timeout")
+		}
+	})
+	t.Run("7: the validation should apply to the merged values", func(t *testing.T) {
+		_, err := LoadConfig([]string{"-config", yamlFile, "-wait-max", "25s"})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "ERROR: CONFIG wait_max")
+		}
+	})
+	t.Run("8: -h should return flag.ErrHelp", func(t *testing.T) {
+		_, err := LoadConfig([]string{"-h"})
+		assert.True(t, errors.Is(err, flag.ErrHelp))
+	})
+}
+
+func TestErrorConfigError(t *testing.T) {
+	err := ErrorConfig{
+		Err: errors.New("a brand ne error test"),
+		Msg: "ERROR: This a test error.",
+	}
+	tests := []struct {
+		name string
+		e    ErrorConfig
+		want string
+	}{
+		{
+			name: "",
+			e:    err,
+			want: fmt.Sprintf("%s : %v", err.Msg, err.Err),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := err
+			if got := e.Error(); got != tt.want {
+				t.Errorf("Error() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package info
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// build metadata, the defaults are overridden at build time with :
+//
+//	PKG=github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info
+//	go build -ldflags "-X ${PKG}.VERSION=0.4.6 -X ${PKG}.GitCommit=$(git rev-parse HEAD) -X ${PKG}.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/go-info-server
+var (
+	VERSION   = "0.4.5"
+	GitCommit = "" // taken from the vcs info embedded by go build when not set
+	BuildDate = "" // taken from the vcs commit time when not set
+)
+
+// BuildInfo describes the binary that is running, like the buildinfo endpoint of Prometheus
+type BuildInfo struct {
+	App       string `json:"app"`
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`           // git commit of the sources
+	Modified  bool   `json:"modified,omitempty"` // true when built from a working tree with uncommitted changes
+	BuildDate string `json:"build_date"`         // RFC3339 date of the build, or of the commit when not injected
+	GoVersion string `json:"go_version"`         // go toolchain used to build the binary
+	Module    string `json:"module,omitempty"`   // main module path
+	Compiler  string `json:"compiler"`           // gc or gccgo
+	Platform  string `json:"platform"`           // GOOS/GOARCH
+}
+
+// GetBuildInfo returns the metadata injected with -ldflags, completed by the vcs information embedded by go build
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{
+		App:       APP,
+		Version:   VERSION,
+		Revision:  GitCommit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Compiler:  runtime.Compiler,
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.Module = bi.Main.Path
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Revision == "" {
+					info.Revision = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	if info.Revision == "" {
+		info.Revision = Unknown
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = Unknown
+	}
+	return info
+}
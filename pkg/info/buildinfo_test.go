@@ -0,0 +1,22 @@
+package info
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBuildInfo(t *testing.T) {
+	info := GetBuildInfo()
+	assert.Equal(t, APP, info.App)
+	assert.Equal(t, VERSION, info.Version)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.NotEmpty(t, info.Revision, "an unknown revision should be reported explicitly")
+
+	defer func(commit, date string) { GitCommit, BuildDate = commit, date }(GitCommit, BuildDate)
+	GitCommit, BuildDate = "0123456789abcdef", "2026-01-02T03:04:05Z"
+	info = GetBuildInfo()
+	assert.Equal(t, "0123456789abcdef", info.Revision, "the ldflags commit should have precedence")
+	assert.Equal(t, "2026-01-02T03:04:05Z", info.BuildDate)
+}
@@ -0,0 +1,182 @@
+package info
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultCgroupRoot = "/sys/fs/cgroup"
+	cgroupUnlimited   = -1 // value reported when the cgroup has no limit
+	// cgroup v1 reports "no limit" as a huge page aligned number instead of max, anything above this is unlimited
+	cgroupV1UnlimitedThreshold = int64(1) << 62
+)
+
+// CgroupMemory contains the memory limit and usage of the container, as seen in the cgroup filesystem
+type CgroupMemory struct {
+	Version    int   `json:"version"`     // 1 or 2
+	LimitBytes int64 `json:"limit_bytes"` // -1 when there is no limit
+	UsageBytes int64 `json:"usage_bytes"`
+}
+
+// CgroupLimits contains the cpu and memory limits given to the container, as seen in the cgroup filesystem
+type CgroupLimits struct {
+	Version          int     `json:"version"`              // 1 or 2
+	CpuQuotaUs       int64   `json:"cpu_quota_us"`         // cpu time allowed per period in microseconds, -1 when there is no limit
+	CpuPeriodUs      int64   `json:"cpu_period_us"`        // length of the cfs period in microseconds
+	CpuLimit         float64 `json:"cpu_limit"`            // quota / period in number of cpus, 0 when there is no limit
+	CpuShares        int64   `json:"cpu_shares,omitempty"` // cgroup v1 cpu.shares (1024 = 1 cpu request)
+	CpuWeight        int64   `json:"cpu_weight,omitempty"` // cgroup v2 cpu.weight (100 is the default)
+	MemoryLimitBytes int64   `json:"memory_limit_bytes"`   // -1 when there is no limit
+}
+
+// cgroupVersion returns 2 when the unified hierarchy is mounted in root, 1 when the legacy one is, 0 otherwise
+func cgroupVersion(root string) int {
+	if fileExists(filepath.Join(root, "cgroup.controllers")) {
+		return 2
+	}
+	if info, err := os.Stat(filepath.Join(root, "memory")); err == nil && info.IsDir() {
+		return 1
+	}
+	return 0
+}
+
+// readCgroupInt reads a file containing a single integer, or max for unlimited
+func readCgroupInt(path string) (int64, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	val := strings.TrimSpace(string(content))
+	if val == "max" {
+		return cgroupUnlimited, nil
+	}
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n >= cgroupV1UnlimitedThreshold {
+		return cgroupUnlimited, nil
+	}
+	return n, nil
+}
+
+// readCgroupCpuMax parses the cgroup v2 cpu.max file : "$MAX $PERIOD" where $MAX can be max
+func readCgroupCpuMax(path string) (quota, period int64, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected content in %s : %q", path, string(content))
+	}
+	period, err = strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if fields[0] == "max" {
+		return cgroupUnlimited, period, nil
+	}
+	quota, err = strconv.ParseInt(fields[0], 10, 64)
+	return quota, period, err
+}
+
+// GetCgroupLimits returns the cpu quota, cpu shares or weight and memory limit of the cgroup mounted in root (v1 or v2),
+// or nil when no cgroup filesystem can be read. a value that cannot be read is left to zero.
+func GetCgroupLimits(root string) *CgroupLimits {
+	limits := CgroupLimits{Version: cgroupVersion(root)}
+	switch limits.Version {
+	case 2:
+		limits.CpuQuotaUs, limits.CpuPeriodUs, _ = readCgroupCpuMax(filepath.Join(root, "cpu.max"))
+		limits.CpuWeight, _ = readCgroupInt(filepath.Join(root, "cpu.weight"))
+		limits.MemoryLimitBytes, _ = readCgroupInt(filepath.Join(root, "memory.max"))
+	case 1:
+		limits.CpuQuotaUs, _ = readCgroupInt(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+		limits.CpuPeriodUs, _ = readCgroupInt(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+		limits.CpuShares, _ = readCgroupInt(filepath.Join(root, "cpu", "cpu.shares"))
+		limits.MemoryLimitBytes, _ = readCgroupInt(filepath.Join(root, "memory", "memory.limit_in_bytes"))
+	default:
+		return nil
+	}
+	if limits.CpuQuotaUs > 0 && limits.CpuPeriodUs > 0 {
+		limits.CpuLimit = float64(limits.CpuQuotaUs) / float64(limits.CpuPeriodUs)
+	}
+	return &limits
+}
+
+// GetCgroupMemory returns the memory limit and usage of the cgroup mounted in root (v1 or v2),
+// or nil when no cgroup filesystem can be read (not in a container, or not on linux).
+func GetCgroupMemory(root string) *CgroupMemory {
+	var limitFile, usageFile string
+	version := cgroupVersion(root)
+	switch version {
+	case 2:
+		limitFile = filepath.Join(root, "memory.max")
+		usageFile = filepath.Join(root, "memory.current")
+	case 1:
+		limitFile = filepath.Join(root, "memory", "memory.limit_in_bytes")
+		usageFile = filepath.Join(root, "memory", "memory.usage_in_bytes")
+	default:
+		return nil
+	}
+	limit, err := readCgroupInt(limitFile)
+	if err != nil {
+		return nil
+	}
+	usage, err := readCgroupInt(usageFile)
+	if err != nil {
+		return nil
+	}
+	return &CgroupMemory{Version: version, LimitBytes: limit, UsageBytes: usage}
+}
+
+// CgroupCpuStat contains the cfs throttling counters of the container
+type CgroupCpuStat struct {
+	NrPeriods   int64 `json:"nr_periods"`   // number of cfs periods elapsed with runnable tasks
+	NrThrottled int64 `json:"nr_throttled"` // number of periods where the quota was exhausted
+	ThrottledUs int64 `json:"throttled_us"` // total time the tasks were throttled in microseconds
+}
+
+// GetCgroupCpuStat returns the throttling counters from the cpu.stat file of the cgroup mounted in root (v1 or v2),
+// or nil when it cannot be read
+func GetCgroupCpuStat(root string) *CgroupCpuStat {
+	var path, throttledKey string
+	var throttledToUs int64
+	switch cgroupVersion(root) {
+	case 2:
+		path, throttledKey, throttledToUs = filepath.Join(root, "cpu.stat"), "throttled_usec", 1
+	case 1:
+		path, throttledKey, throttledToUs = filepath.Join(root, "cpu", "cpu.stat"), "throttled_time", 1000
+	default:
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var stat CgroupCpuStat
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		n, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "nr_periods":
+			stat.NrPeriods = n
+		case "nr_throttled":
+			stat.NrThrottled = n
+		case throttledKey:
+			// cgroup v1 reports the throttled time in nanoseconds
+			stat.ThrottledUs = n / throttledToUs
+		}
+	}
+	return &stat
+}
@@ -0,0 +1,108 @@
+package info
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCgroupFiles creates a fake cgroup filesystem under root, files maps a relative path to its content
+func writeCgroupFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("unable to create %s : %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("unable to write %s : %v", path, err)
+		}
+	}
+}
+
+func TestGetCgroupMemory(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  *CgroupMemory
+	}{
+		{name: "1: no cgroup filesystem should return nil", files: map[string]string{}, want: nil},
+		{name: "2: cgroup v2 with a limit", files: map[string]string{
+			"cgroup.controllers": "cpu memory", "memory.max": "268435456\n", "memory.current": "12345678\n"},
+			want: &CgroupMemory{Version: 2, LimitBytes: 268435456, UsageBytes: 12345678}},
+		{name: "3: cgroup v2 without limit should report -1", files: map[string]string{
+			"cgroup.controllers": "cpu memory", "memory.max": "max\n", "memory.current": "4096\n"},
+			want: &CgroupMemory{Version: 2, LimitBytes: cgroupUnlimited, UsageBytes: 4096}},
+		{name: "4: cgroup v1 with a limit", files: map[string]string{
+			"memory/memory.limit_in_bytes": "536870912\n", "memory/memory.usage_in_bytes": "8192\n"},
+			want: &CgroupMemory{Version: 1, LimitBytes: 536870912, UsageBytes: 8192}},
+		{name: "5: cgroup v1 huge limit should report -1", files: map[string]string{
+			"memory/memory.limit_in_bytes": "9223372036854771712\n", "memory/memory.usage_in_bytes": "8192\n"},
+			want: &CgroupMemory{Version: 1, LimitBytes: cgroupUnlimited, UsageBytes: 8192}},
+		{name: "6: unreadable values should return nil", files: map[string]string{
+			"cgroup.controllers": "cpu memory", "memory.max": "a lot\n", "memory.current": "4096\n"},
+			want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeCgroupFiles(t, root, tt.files)
+			assert.Equal(t, tt.want, GetCgroupMemory(root))
+		})
+	}
+}
+
+func TestGetCgroupLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  *CgroupLimits
+	}{
+		{name: "1: no cgroup filesystem should return nil", files: map[string]string{}, want: nil},
+		{name: "2: cgroup v2 with 1.5 cpu and 256Mi", files: map[string]string{
+			"cgroup.controllers": "cpu memory", "cpu.max": "150000 100000\n", "cpu.weight": "59\n", "memory.max": "268435456\n"},
+			want: &CgroupLimits{Version: 2, CpuQuotaUs: 150000, CpuPeriodUs: 100000, CpuLimit: 1.5, CpuWeight: 59, MemoryLimitBytes: 268435456}},
+		{name: "3: cgroup v2 without limits", files: map[string]string{
+			"cgroup.controllers": "cpu memory", "cpu.max": "max 100000\n", "cpu.weight": "100\n", "memory.max": "max\n"},
+			want: &CgroupLimits{Version: 2, CpuQuotaUs: cgroupUnlimited, CpuPeriodUs: 100000, CpuWeight: 100, MemoryLimitBytes: cgroupUnlimited}},
+		{name: "4: cgroup v1 with half a cpu", files: map[string]string{
+			"cpu/cpu.cfs_quota_us": "50000\n", "cpu/cpu.cfs_period_us": "100000\n", "cpu/cpu.shares": "512\n",
+			"memory/memory.limit_in_bytes": "536870912\n"},
+			want: &CgroupLimits{Version: 1, CpuQuotaUs: 50000, CpuPeriodUs: 100000, CpuLimit: 0.5, CpuShares: 512, MemoryLimitBytes: 536870912}},
+		{name: "5: cgroup v1 without limits", files: map[string]string{
+			"cpu/cpu.cfs_quota_us": "-1\n", "cpu/cpu.cfs_period_us": "100000\n", "cpu/cpu.shares": "2\n",
+			"memory/memory.limit_in_bytes": "9223372036854771712\n"},
+			want: &CgroupLimits{Version: 1, CpuQuotaUs: cgroupUnlimited, CpuPeriodUs: 100000, CpuShares: 2, MemoryLimitBytes: cgroupUnlimited}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeCgroupFiles(t, root, tt.files)
+			assert.Equal(t, tt.want, GetCgroupLimits(root))
+		})
+	}
+}
+
+func TestGetCgroupCpuStat(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  *CgroupCpuStat
+	}{
+		{name: "1: no cgroup filesystem should return nil", files: map[string]string{}, want: nil},
+		{name: "2: cgroup v2 cpu.stat", files: map[string]string{"cgroup.controllers": "cpu memory",
+			"cpu.stat": "usage_usec 8000\nnr_periods 40\nnr_throttled 12\nthrottled_usec 3500\n"},
+			want: &CgroupCpuStat{NrPeriods: 40, NrThrottled: 12, ThrottledUs: 3500}},
+		{name: "3: cgroup v1 throttled time should be converted from ns", files: map[string]string{"memory/memory.limit_in_bytes": "1",
+			"cpu/cpu.stat": "nr_periods 40\nnr_throttled 12\nthrottled_time 3500000\n"},
+			want: &CgroupCpuStat{NrPeriods: 40, NrThrottled: 12, ThrottledUs: 3500}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeCgroupFiles(t, root, tt.files)
+			assert.Equal(t, tt.want, GetCgroupCpuStat(root))
+		})
+	}
+}
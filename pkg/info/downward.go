@@ -0,0 +1,67 @@
+package info
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const DefaultK8sServiceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// K8sDownwardInfo contains the pod identity and resources given by the Kubernetes Downward API
+// and what is mounted from the service account
+type K8sDownwardInfo struct {
+	PodName                 string `json:"pod_name,omitempty"`                  // env MY_POD_NAME or POD_NAME (metadata.name)
+	PodNamespace            string `json:"pod_namespace,omitempty"`             // env MY_POD_NAMESPACE or POD_NAMESPACE (metadata.namespace)
+	PodIp                   string `json:"pod_ip,omitempty"`                    // env MY_POD_IP or POD_IP (status.podIP)
+	NodeName                string `json:"node_name,omitempty"`                 // env MY_NODE_NAME or NODE_NAME (spec.nodeName)
+	ServiceAccount          string `json:"service_account,omitempty"`           // env MY_POD_SERVICE_ACCOUNT or SERVICE_ACCOUNT (spec.serviceAccountName)
+	CpuRequest              string `json:"cpu_request,omitempty"`               // env MY_CPU_REQUEST or CPU_REQUEST (requests.cpu)
+	CpuLimit                string `json:"cpu_limit,omitempty"`                 // env MY_CPU_LIMIT or CPU_LIMIT (limits.cpu)
+	MemoryRequest           string `json:"memory_request,omitempty"`            // env MY_MEM_REQUEST or MEMORY_REQUEST (requests.memory)
+	MemoryLimit             string `json:"memory_limit,omitempty"`              // env MY_MEM_LIMIT or MEMORY_LIMIT (limits.memory)
+	ServiceAccountNamespace string `json:"service_account_namespace,omitempty"` // content of the mounted service account namespace file
+	ServiceAccountToken     bool   `json:"service_account_token"`               // true when a service account token is mounted
+	ServiceAccountCaCert    bool   `json:"service_account_ca_cert"`             // true when the cluster ca certificate is mounted
+}
+
+// LookupFirstEnv returns the value of the first defined and not empty env variable in names
+func LookupFirstEnv(lookupEnv func(string) (string, bool), names ...string) string {
+	for _, name := range names {
+		if val, exist := lookupEnv(name); exist && val != "" {
+			return val
+		}
+	}
+	return ""
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// GetK8sDownwardInfo returns the information exposed by the Downward API env variables and by the service account files
+// in serviceAccountPath. It returns nil when nothing indicates that we are running inside a Kubernetes pod.
+func GetK8sDownwardInfo(lookupEnv func(string) (string, bool), serviceAccountPath string) *K8sDownwardInfo {
+	info := K8sDownwardInfo{
+		PodName:        LookupFirstEnv(lookupEnv, "MY_POD_NAME", "POD_NAME"),
+		PodNamespace:   LookupFirstEnv(lookupEnv, "MY_POD_NAMESPACE", "POD_NAMESPACE"),
+		PodIp:          LookupFirstEnv(lookupEnv, "MY_POD_IP", "POD_IP"),
+		NodeName:       LookupFirstEnv(lookupEnv, "MY_NODE_NAME", "NODE_NAME"),
+		ServiceAccount: LookupFirstEnv(lookupEnv, "MY_POD_SERVICE_ACCOUNT", "SERVICE_ACCOUNT"),
+		CpuRequest:     LookupFirstEnv(lookupEnv, "MY_CPU_REQUEST", "CPU_REQUEST"),
+		CpuLimit:       LookupFirstEnv(lookupEnv, "MY_CPU_LIMIT", "CPU_LIMIT"),
+		MemoryRequest:  LookupFirstEnv(lookupEnv, "MY_MEM_REQUEST", "MEMORY_REQUEST"),
+		MemoryLimit:    LookupFirstEnv(lookupEnv, "MY_MEM_LIMIT", "MEMORY_LIMIT"),
+	}
+	if namespace, err := os.ReadFile(filepath.Join(serviceAccountPath, "namespace")); err == nil {
+		info.ServiceAccountNamespace = string(namespace)
+	}
+	info.ServiceAccountToken = fileExists(filepath.Join(serviceAccountPath, "token"))
+	info.ServiceAccountCaCert = fileExists(filepath.Join(serviceAccountPath, "ca.crt"))
+
+	_, inCluster := lookupEnv("KUBERNETES_SERVICE_HOST")
+	if !inCluster && info == (K8sDownwardInfo{}) {
+		return nil
+	}
+	return &info
+}
@@ -0,0 +1,54 @@
+package info
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetK8sDownwardInfo(t *testing.T) {
+	saPath := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(saPath, "namespace"), []byte("test-go-cloud-k8s-info"), 0600))
+	assert.Nil(t, os.WriteFile(filepath.Join(saPath, "token"), []byte("secret"), 0600))
+
+	envFrom := func(env map[string]string) func(string) (string, bool) {
+		return func(name string) (string, bool) {
+			val, exist := env[name]
+			return val, exist
+		}
+	}
+	tests := []struct {
+		name   string
+		env    map[string]string
+		saPath string
+		want   *K8sDownwardInfo
+	}{
+		{
+			name:   "1: outside k8s the section should be omitted",
+			env:    map[string]string{"HOME": "/home/gouser"},
+			saPath: filepath.Join(saPath, "does_not_exist"),
+			want:   nil,
+		},
+		{
+			name: "2: MY_ prefixed variables should be used first",
+			env: map[string]string{"KUBERNETES_SERVICE_HOST": "10.43.0.1", "MY_POD_NAME": "go-info-server-1", "POD_NAME": "ignored",
+				"MY_NODE_NAME": "node1", "MY_POD_IP": "10.42.0.12", "MY_CPU_LIMIT": "1000", "MY_MEM_LIMIT": "134217728"},
+			saPath: saPath,
+			want: &K8sDownwardInfo{PodName: "go-info-server-1", NodeName: "node1", PodIp: "10.42.0.12", CpuLimit: "1000", MemoryLimit: "134217728",
+				ServiceAccountNamespace: "test-go-cloud-k8s-info", ServiceAccountToken: true},
+		},
+		{
+			name:   "3: unprefixed variables should be used as fallback",
+			env:    map[string]string{"POD_NAME": "go-info-server-2", "POD_NAMESPACE": "default"},
+			saPath: filepath.Join(saPath, "does_not_exist"),
+			want:   &K8sDownwardInfo{PodName: "go-info-server-2", PodNamespace: "default"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, GetK8sDownwardInfo(envFrom(tt.env), tt.saPath))
+		})
+	}
+}
@@ -0,0 +1,271 @@
+// Package info gathers the build, os, cgroup and kubernetes information shown by the go-cloud-k8s-info server
+package info
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+)
+
+const (
+	APP     = "go-cloud-k8s-info"
+	Unknown = "_UNKNOWN_" // value of the information that could not be found
+)
+
+type RuntimeInfo struct {
+	Hostname            string              `json:"hostname"`              // host name reported by the kernel.
+	Pid                 int                 `json:"pid"`                   // process id of the caller.
+	PPid                int                 `json:"ppid"`                  // process id of the caller's parent.
+	Uid                 int                 `json:"uid"`                   // numeric user id of the caller.
+	Appname             string              `json:"appname"`               // name of this application
+	Version             string              `json:"version"`               // version of this application
+	Build               BuildInfo           `json:"build"`                 // git commit, build date and go version of this binary
+	ParamName           string              `json:"param_name"`            // value of the name parameter (_NO_PARAMETER_NAME_ if name was not set)
+	RemoteAddr          string              `json:"remote_addr"`           // remote client ip address
+	RemoteIp            string              `json:"remote_ip"`             // remote client ip address without port
+	RemotePort          int                 `json:"remote_port"`           // remote client port
+	RemoteIpVersion     int                 `json:"remote_ip_version"`     // 4 or 6, 0 when unknown (unix socket)
+	RemotePtr           string              `json:"remote_ptr,omitempty"`  // reverse dns name of remote ip, only with ?rdns=true
+	ProxyAddr           string              `json:"proxy_addr,omitempty"`  // address of the trusted proxy which forwarded the request
+	RequestId           string              `json:"request_id"`            // globally unique request id
+	Message             string              `json:"message,omitempty"`     // INFO_MESSAGE with its variables expanded
+	Banner              string              `json:"banner,omitempty"`      // content of BANNER_FILE with its variables expanded
+	GOOS                string              `json:"goos"`                  // operating system
+	GOARCH              string              `json:"goarch"`                // architecture
+	Runtime             string              `json:"runtime"`               // go runtime at compilation time
+	NumGoroutine        string              `json:"num_goroutine"`         // number of go routines
+	OsReleaseName       string              `json:"os_release_name"`       // Linux release Name or _UNKNOWN_
+	OsReleaseVersion    string              `json:"os_release_version"`    // Linux release Version or _UNKNOWN_
+	OsReleaseVersionId  string              `json:"os_release_version_id"` // Linux release VersionId or _UNKNOWN_
+	NumCPU              string              `json:"num_cpu"`               // number of cpu
+	GoMaxProcs          int                 `json:"gomaxprocs"`            // number of cpu the go scheduler uses at the same time
+	MaxProcs            *MaxProcsInfo       `json:"maxprocs,omitempty"`    // cpu quota and GOMAXPROCS chosen at startup, omitted when AUTO_MAXPROCS is false
+	Cgroup              *CgroupLimits       `json:"cgroup,omitempty"`      // cpu and memory limits of the container, omitted outside a container
+	Uptime              string              `json:"uptime"`                // tells how long this service was started based on an internal variable
+	UptimeOs            string              `json:"uptime_os"`             // tells how long system was started based on /proc/uptime
+	K8sApiUrl           string              `json:"k8s_api_url"`           // url for k8s api based KUBERNETES_SERVICE_HOST
+	K8sVersion          string              `json:"k8s_version"`           // version of k8s cluster
+	K8sCurrentNamespace string              `json:"k8s_current_namespace"` // k8s namespace of this container
+	K8s                 *K8sDownwardInfo    `json:"k8s,omitempty"`         // pod info from the Downward API, omitted outside k8s
+	EnvVars             []string            `json:"env_vars"`              // environment variables
+	Headers             map[string][]string `json:"headers"`               // received headers
+}
+
+type OsInfo struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	VersionId string `json:"versionId"`
+}
+
+type K8sInfo struct {
+	CurrentNamespace string `json:"current_namespace"`
+	Version          string `json:"version"`
+	Token            string `json:"token"`
+	CaCert           string `json:"ca_cert"`
+}
+
+func GetOsUptime() (string, error) {
+	uptimeResult := Unknown
+	content, err := ioutil.ReadFile("/proc/uptime")
+	if err != nil {
+		return uptimeResult, err
+	}
+	uptimeResult = string(content)
+	return uptimeResult, nil
+}
+
+func GetOsInfo() (*OsInfo, config.ErrorConfig) {
+	const (
+		OsReleasePath          = "/etc/os-release"
+		regexFindOsNameVersion = `(?m)^NAME="(?P<name>[^"]+)"\s?|^VERSION="(?P<version>[^"]+)"|^VERSION_ID="?(?P<versid>[^"]+)"?\s`
+	)
+	info := OsInfo{
+		Name:      Unknown,
+		Version:   Unknown,
+		VersionId: Unknown,
+	}
+	content, err := os.ReadFile(OsReleasePath)
+	if err != nil {
+		return &info, config.ErrorConfig{
+			Err: err,
+			Msg: "GetOsInfo: error reading " + OsReleasePath,
+		}
+	}
+	r := regexp.MustCompile(regexFindOsNameVersion)
+	if r.MatchString(string(content)) {
+		res := r.FindAllStringSubmatch(string(content), -1)
+		for i, v := range res {
+			for j, key := range r.SubexpNames() {
+				if j > 0 && i <= len(res) && len(v[j]) > 0 {
+					if key == "name" {
+						info.Name = v[j]
+					}
+					if key == "version" {
+						info.Version = v[j]
+					}
+					if key == "versid" {
+						info.VersionId = v[j]
+					}
+				}
+			}
+		}
+	}
+	return &info, config.ErrorConfig{
+		Err: nil,
+		Msg: "",
+	}
+}
+
+// GetKubernetesApiUrlFromEnv returns the k8s api url based on the content of standard env var :
+//
+//	KUBERNETES_SERVICE_HOST
+//	KUBERNETES_SERVICE_PORT
+//	in case the above ENV variables doesn't  exist the function returns an empty string and an error
+func GetKubernetesApiUrlFromEnv() (string, error) {
+	srvPort := 443
+	k8sApiUrl := "https://"
+
+	var err error
+	val, exist := os.LookupEnv("KUBERNETES_SERVICE_HOST")
+	if !exist {
+		return "", &config.ErrorConfig{
+			Err: err,
+			Msg: "ERROR: KUBERNETES_SERVICE_HOST ENV variable does not exist (not inside K8s ?).",
+		}
+	}
+	k8sApiUrl = fmt.Sprintf("%s%s", k8sApiUrl, val)
+	val, exist = os.LookupEnv("KUBERNETES_SERVICE_PORT")
+	if exist {
+		srvPort, err = strconv.Atoi(val)
+		if err != nil {
+			return "", &config.ErrorConfig{
+				Err: err,
+				Msg: "ERROR: CONFIG ENV PORT should contain a valid integer.",
+			}
+		}
+		if srvPort < 1 || srvPort > 65535 {
+			return "", &config.ErrorConfig{
+				Err: err,
+				Msg: "ERROR: CONFIG ENV PORT should contain an integer between 1 and 65535",
+			}
+		}
+	}
+	return fmt.Sprintf("%s:%d", k8sApiUrl, srvPort), nil
+}
+
+func GetKubernetesConnInfo(logger *slog.Logger) (*K8sInfo, config.ErrorConfig) {
+	K8sServiceAccountPath := DefaultK8sServiceAccountPath
+	K8sNamespacePath := fmt.Sprintf("%s/namespace", K8sServiceAccountPath)
+	K8sTokenPath := fmt.Sprintf("%s/token", K8sServiceAccountPath)
+	K8sCaCertPath := fmt.Sprintf("%s/ca.crt", K8sServiceAccountPath)
+
+	info := K8sInfo{
+		CurrentNamespace: "",
+		Version:          "",
+		Token:            "",
+		CaCert:           "",
+	}
+
+	K8sNamespace, err := os.ReadFile(K8sNamespacePath)
+	if err != nil {
+		return &info, config.ErrorConfig{
+			Err: err,
+			Msg: "GetKubernetesConnInfo: error reading namespace in " + K8sNamespacePath,
+		}
+	}
+	info.CurrentNamespace = string(K8sNamespace)
+
+	K8sToken, err := os.ReadFile(K8sTokenPath)
+	if err != nil {
+		return &info, config.ErrorConfig{
+			Err: err,
+			Msg: "GetKubernetesConnInfo: error reading token in " + K8sTokenPath,
+		}
+	}
+	info.Token = string(K8sToken)
+
+	K8sCaCert, err := os.ReadFile(K8sCaCertPath)
+	if err != nil {
+		return &info, config.ErrorConfig{
+			Err: err,
+			Msg: "GetKubernetesConnInfo: error reading Ca Cert in " + K8sCaCertPath,
+		}
+	}
+	info.CaCert = string(K8sCaCert)
+
+	k8sUrl, err := GetKubernetesApiUrlFromEnv()
+	if err != nil {
+		return &info, config.ErrorConfig{
+			Err: err,
+			Msg: "GetKubernetesConnInfo: error reading GetKubernetesApiUrlFromEnv ",
+		}
+	}
+	urlVersion := fmt.Sprintf("%s/openapi/v2", k8sUrl)
+	res, err := GetJsonFromUrl(urlVersion, info.Token, K8sCaCert, logger)
+	if err != nil {
+		logger.Warn("GetKubernetesConnInfo: error in GetJsonFromUrl", "url", urlVersion, "error", err)
+	} else {
+		logger.Debug("GetKubernetesConnInfo: successfully returned from GetJsonFromUrl", "url", urlVersion)
+		var myVersionRegex = regexp.MustCompile("{\"title\":\"(?P<title>.+)\",\"version\":\"(?P<version>.+)\"}")
+		match := myVersionRegex.FindStringSubmatch(strings.TrimSpace(res[:150]))
+		k8sVersionFields := make(map[string]string)
+		for i, name := range myVersionRegex.SubexpNames() {
+			if i != 0 && name != "" {
+				k8sVersionFields[name] = match[i]
+			}
+		}
+		info.Version = fmt.Sprintf("%s, %s", k8sVersionFields["title"], k8sVersionFields["version"])
+	}
+
+	return &info, config.ErrorConfig{
+		Err: nil,
+		Msg: "",
+	}
+}
+
+func GetJsonFromUrl(url string, token string, caCert []byte, logger *slog.Logger) (string, error) {
+	// Create a Bearer string by appending string access token
+	var bearer = "Bearer " + token
+
+	// Create a new request using http
+	req, err := http.NewRequest("GET", url, nil)
+
+	// add authorization header to the req
+	req.Header.Add("Authorization", bearer)
+	caCertPool := x509.NewCertPool()
+	caCertPool.AppendCertsFromPEM(caCert)
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			RootCAs: caCertPool,
+		},
+	}
+	// Send req using http Client
+	client := &http.Client{
+		Transport: tr,
+		Timeout:   config.DefaultReadTimeout,
+	}
+	resp, err := client.Do(req)
+
+	if err != nil {
+		logger.Error("Error on response", "url", url, "error", err)
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Error("Error while reading the response bytes", "url", url, "error", err)
+		return "", err
+	}
+	return string([]byte(body)), nil
+}
@@ -0,0 +1,69 @@
+package info
+
+import (
+	"math"
+	"runtime"
+	"sync"
+)
+
+const (
+	MaxProcsSourceDefault = "default" // GOMAXPROCS is the number of cpu of the host
+	MaxProcsSourceEnv     = "env"     // GOMAXPROCS was given by the env variable, it is never changed
+	MaxProcsSourceCgroup  = "cgroup"  // GOMAXPROCS was lowered to the cpu quota of the container
+)
+
+// MaxProcsInfo tells how GOMAXPROCS was chosen at startup
+type MaxProcsInfo struct {
+	CpuQuota float64 `json:"cpu_quota"` // cpu limit of the cgroup in number of cpus, 0 when there is no limit
+	Previous int     `json:"previous"`  // GOMAXPROCS set by the go runtime
+	Value    int     `json:"value"`     // GOMAXPROCS used
+	Source   string  `json:"source"`    // default, env or cgroup
+}
+
+var (
+	maxProcsMu   sync.Mutex
+	maxProcsInfo *MaxProcsInfo
+)
+
+// MaxProcsForQuota returns the GOMAXPROCS fitting the cpu quota, rounded down like automaxprocs so that the
+// threads never use more cpu time than the quota in a cfs period, at least 1 and at most numCpu
+func MaxProcsForQuota(quota float64, numCpu int) int {
+	if quota <= 0 {
+		return numCpu
+	}
+	return max(1, min(int(math.Floor(quota)), numCpu))
+}
+
+// TuneMaxProcs sets GOMAXPROCS to the cpu quota of the cgroup found in cgroupRoot, unless the GOMAXPROCS env variable
+// is given by lookupEnv. with the default of the go runtime, a container limited to 2 cpus on a 64 cpus node runs
+// 64 threads which exhaust the quota early in each cfs period and are throttled for the rest of it.
+// the result is kept for GetMaxProcsInfo
+func TuneMaxProcs(cgroupRoot string, lookupEnv func(string) (string, bool)) MaxProcsInfo {
+	tuned := MaxProcsInfo{Previous: runtime.GOMAXPROCS(0), Source: MaxProcsSourceDefault}
+	tuned.Value = tuned.Previous
+	if limits := GetCgroupLimits(cgroupRoot); limits != nil {
+		tuned.CpuQuota = limits.CpuLimit
+	}
+	if _, found := lookupEnv("GOMAXPROCS"); found {
+		tuned.Source = MaxProcsSourceEnv
+	} else if tuned.CpuQuota > 0 {
+		tuned.Value = MaxProcsForQuota(tuned.CpuQuota, runtime.NumCPU())
+		tuned.Source = MaxProcsSourceCgroup
+		runtime.GOMAXPROCS(tuned.Value)
+	}
+	maxProcsMu.Lock()
+	maxProcsInfo = &tuned
+	maxProcsMu.Unlock()
+	return tuned
+}
+
+// GetMaxProcsInfo returns how GOMAXPROCS was chosen by TuneMaxProcs, nil when it was not called
+func GetMaxProcsInfo() *MaxProcsInfo {
+	maxProcsMu.Lock()
+	defer maxProcsMu.Unlock()
+	if maxProcsInfo == nil {
+		return nil
+	}
+	tuned := *maxProcsInfo
+	return &tuned
+}
@@ -0,0 +1,61 @@
+package info
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxProcsForQuota(t *testing.T) {
+	tests := []struct {
+		name   string
+		quota  float64
+		numCpu int
+		want   int
+	}{
+		{name: "1: no quota should keep the number of cpu", quota: 0, numCpu: 8, want: 8},
+		{name: "2: quota should be rounded down", quota: 2.5, numCpu: 8, want: 2},
+		{name: "3: quota below one cpu should give 1", quota: 0.25, numCpu: 8, want: 1},
+		{name: "4: quota above the number of cpu should give the number of cpu", quota: 16, numCpu: 4, want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, MaxProcsForQuota(tt.quota, tt.numCpu))
+		})
+	}
+}
+
+func TestTuneMaxProcs(t *testing.T) {
+	initial := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(initial)
+	noEnv := func(string) (string, bool) { return "", false }
+	tests := []struct {
+		name       string
+		files      map[string]string
+		lookupEnv  func(string) (string, bool)
+		wantSource string
+		wantValue  int
+		wantQuota  float64
+	}{
+		{name: "1: no cgroup should keep the default", files: map[string]string{}, lookupEnv: noEnv,
+			wantSource: MaxProcsSourceDefault, wantValue: initial},
+		{name: "2: cgroup v2 quota should set GOMAXPROCS", files: map[string]string{"cgroup.controllers": "cpu", "cpu.max": "100000 100000\n"},
+			lookupEnv: noEnv, wantSource: MaxProcsSourceCgroup, wantValue: 1, wantQuota: 1},
+		{name: "3: GOMAXPROCS env should win over the quota", files: map[string]string{"cgroup.controllers": "cpu", "cpu.max": "100000 100000\n"},
+			lookupEnv: func(string) (string, bool) { return "3", true }, wantSource: MaxProcsSourceEnv, wantValue: initial, wantQuota: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runtime.GOMAXPROCS(initial)
+			root := t.TempDir()
+			writeCgroupFiles(t, root, tt.files)
+			got := TuneMaxProcs(root, tt.lookupEnv)
+			assert.Equal(t, tt.wantSource, got.Source)
+			assert.Equal(t, tt.wantValue, got.Value)
+			assert.Equal(t, tt.wantValue, runtime.GOMAXPROCS(0))
+			assert.Equal(t, tt.wantQuota, got.CpuQuota)
+			assert.Equal(t, &got, GetMaxProcsInfo())
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+)
+
+const (
+	accessLogTimeLayout = "02/Jan/2006:15:04:05 -0700"
+)
+
+// AccessLogger writes one line per served request, in the Apache common or combined log format or in json,
+// separately from the application logs so the lines can be parsed by the usual log analyzers
+type AccessLogger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format string
+}
+
+// NewAccessLogger is a constructor for an AccessLogger writing to w in format combined, common or json
+func NewAccessLogger(w io.Writer, format string) *AccessLogger {
+	return &AccessLogger{w: w, format: format}
+}
+
+// OpenAccessLog returns the writer for the access log destination : stdout, stderr or the path of a file opened in append mode
+func OpenAccessLog(destination string) (io.Writer, error) {
+	switch destination {
+	case "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	}
+	return os.OpenFile(destination, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+}
+
+// accessLogEntry is one line of the access log in json format
+type accessLogEntry struct {
+	Time       string  `json:"time"`
+	RemoteIp   string  `json:"remote_ip"`
+	User       string  `json:"user,omitempty"`
+	Method     string  `json:"method"`
+	Uri        string  `json:"uri"`
+	Proto      string  `json:"proto"`
+	Status     int     `json:"status"`
+	Bytes      int     `json:"bytes"`
+	DurationMs float64 `json:"duration_ms"`
+	Referer    string  `json:"referer,omitempty"`
+	UserAgent  string  `json:"user_agent,omitempty"`
+	RequestId  string  `json:"request_id,omitempty"`
+}
+
+// escapeLogField escapes the quotes, the backslashes and the control characters of s like Apache does,
+// so a client cannot forge fake lines in the access log
+func escapeLogField(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// dashIfEmpty returns - for an empty field, as in the Apache log formats
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// logUri returns the uri of r for the logs, with the access_token shortened like in the audit log
+// so a token still valid cannot be read from the log
+func logUri(r *http.Request) string {
+	if !strings.Contains(r.URL.RawQuery, "=") {
+		return r.RequestURI
+	}
+	params := strings.Split(r.URL.RawQuery, "&")
+	redacted := false
+	for i, param := range params {
+		rawName, val, found := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(rawName); found && err == nil && name == accessTokenQueryParam {
+			params[i] = rawName + "=" + tokenPrefix(val)
+			redacted = true
+		}
+	}
+	if !redacted {
+		return r.RequestURI
+	}
+	return r.URL.EscapedPath() + "?" + strings.Join(params, "&")
+}
+
+// Format returns the access log line of a request, without the final newline
+func (al *AccessLogger) Format(r *http.Request, status, bytes int, start time.Time, duration time.Duration) string {
+	user, _, _ := r.BasicAuth()
+	remoteIp := ParseRemoteAddr(r.RemoteAddr).RemoteIp
+	if al.format == config.AccessLogJson {
+		line, _ := json.Marshal(accessLogEntry{
+			Time:       start.Format(time.RFC3339Nano),
+			RemoteIp:   remoteIp,
+			User:       user,
+			Method:     r.Method,
+			Uri:        logUri(r),
+			Proto:      r.Proto,
+			Status:     status,
+			Bytes:      bytes,
+			DurationMs: float64(duration.Microseconds()) / 1000,
+			Referer:    r.Referer(),
+			UserAgent:  r.UserAgent(),
+			RequestId:  RequestIdFromContext(r.Context()),
+		})
+		return string(line)
+	}
+	size := "-"
+	if bytes > 0 {
+		size = fmt.Sprintf("%d", bytes)
+	}
+	line := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s", dashIfEmpty(remoteIp), dashIfEmpty(escapeLogField(user)),
+		start.Format(accessLogTimeLayout), escapeLogField(r.Method), escapeLogField(logUri(r)), escapeLogField(r.Proto), status, size)
+	if al.format == config.AccessLogCombined {
+		line += fmt.Sprintf(" \"%s\" \"%s\"", dashIfEmpty(escapeLogField(r.Referer())), dashIfEmpty(escapeLogField(r.UserAgent())))
+	}
+	return line
+}
+
+// Log writes the access log line of a request
+func (al *AccessLogger) Log(r *http.Request, status, bytes int, start time.Time, duration time.Duration) {
+	line := al.Format(r, status, bytes, start, duration)
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	_, _ = io.WriteString(al.w, line+"\n")
+}
+
+// logAccess is the Middleware writing a line in the access log for each request served
+func (s *GoHttpServer) logAccess() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			if sw.status == 0 {
+				sw.status = http.StatusOK
+			}
+			s.accessLog.Log(r, sw.status, sw.bytes, start, time.Since(start))
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLoggerFormat(t *testing.T) {
+	start := time.Date(2024, time.March, 5, 13, 55, 36, 0, time.FixedZone("CET", 3600))
+	r := httptest.NewRequest(http.MethodGet, "/time?tz=UTC", nil)
+	r.RemoteAddr = "192.0.2.10:51234"
+	r.Header.Set("User-Agent", `curl/8.0 "quoted"`)
+	r.Header.Set("Referer", "http://example.com/")
+	r.SetBasicAuth("alice", "secret")
+
+	tests := []struct {
+		name   string
+		format string
+		bytes  int
+		want   string
+	}{
+		{name: "1: common format", format: config.AccessLogCommon, bytes: 42,
+			want: `192.0.2.10 - alice [05/Mar/2024:13:55:36 +0100] "GET /time?tz=UTC HTTP/1.1" 200 42`},
+		{name: "2: combined format should escape the quotes", format: config.AccessLogCombined, bytes: 42,
+			want: `192.0.2.10 - alice [05/Mar/2024:13:55:36 +0100] "GET /time?tz=UTC HTTP/1.1" 200 42 "http://example.com/" "curl/8.0 \"quoted\""`},
+		{name: "3: empty body should be a dash", format: config.AccessLogCommon, bytes: 0,
+			want: `192.0.2.10 - alice [05/Mar/2024:13:55:36 +0100] "GET /time?tz=UTC HTTP/1.1" 200 -`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			al := NewAccessLogger(nil, tt.format)
+			assert.Equal(t, tt.want, al.Format(r, http.StatusOK, tt.bytes, start, 1500*time.Microsecond))
+		})
+	}
+
+	var entry accessLogEntry
+	line := NewAccessLogger(nil, config.AccessLogJson).Format(r, http.StatusOK, 42, start, 1500*time.Microsecond)
+	assert.NoError(t, json.Unmarshal([]byte(line), &entry), "the json format should be valid json")
+	assert.Equal(t, accessLogEntry{Time: "2024-03-05T13:55:36+01:00", RemoteIp: "192.0.2.10", User: "alice", Method: "GET",
+		Uri: "/time?tz=UTC", Proto: "HTTP/1.1", Status: 200, Bytes: 42, DurationMs: 1.5, Referer: "http://example.com/",
+		UserAgent: `curl/8.0 "quoted"`}, entry)
+}
+
+func TestLogUri(t *testing.T) {
+	tests := []struct {
+		name string
+		uri  string
+		want string
+	}{
+		{name: "1: an uri without query should be kept", uri: "/time", want: "/time"},
+		{name: "2: a query without access_token should be kept", uri: "/time?tz=UTC&format=json", want: "/time?tz=UTC&format=json"},
+		{name: "3: the access_token should be shortened", uri: "/env?access_token=0123456789abcdef&x=1", want: "/env?access_token=01234567...&x=1"},
+		{name: "4: an escaped access_token name should be shortened too", uri: "/env?x=1&access%5Ftoken=0123456789abcdef", want: "/env?x=1&access%5Ftoken=01234567..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, logUri(httptest.NewRequest(http.MethodGet, tt.uri, nil)))
+		})
+	}
+}
+
+func TestEscapeLogField(t *testing.T) {
+	assert.Equal(t, `GET /x\x0a127.0.0.1 - - \"forged\"`, escapeLogField("GET /x\n127.0.0.1 - - \"forged\""))
+	assert.Equal(t, `C:\\temp`, escapeLogField(`C:\temp`))
+}
+
+func TestGoHttpServerAccessLog(t *testing.T) {
+	accessLog := filepath.Join(t.TempDir(), "access.log")
+	t.Setenv("ACCESS_LOG", accessLog)
+	t.Setenv("ACCESS_LOG_FORMAT", "common")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	for _, path := range []string{"/time", "/nowhere/buildinfo"} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	content, err := os.ReadFile(accessLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if assert.Len(t, lines, 2, "each request should give one line") {
+		assert.Contains(t, lines[0], `"GET /time HTTP/1.1" 200 `)
+		assert.Contains(t, lines[1], `"GET /nowhere/buildinfo HTTP/1.1" 404 `)
+	}
+}
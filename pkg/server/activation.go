@@ -0,0 +1,67 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const listenFdsStart = 3 // first file descriptor passed by socket activation, SD_LISTEN_FDS_START of systemd
+
+// inheritedListeners returns the listeners passed by systemd socket activation, or by a process manager binding
+// the port before starting the server, following the LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES protocol of
+// sd_listen_fds. the descriptors start at firstFd, none is returned when LISTEN_PID is not the pid of the process
+func inheritedListeners(lookupEnv func(string) (string, bool), pid int, firstFd int) ([]net.Listener, error) {
+	fds, found := lookupEnv("LISTEN_FDS")
+	if !found || fds == "" {
+		return nil, nil
+	}
+	if listenPid, found := lookupEnv("LISTEN_PID"); found && listenPid != strconv.Itoa(pid) {
+		// the variables were meant for the parent process
+		return nil, nil
+	}
+	count, err := strconv.Atoi(fds)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("LISTEN_FDS should be a number of file descriptors, got %q", fds)
+	}
+	fdNames, _ := lookupEnv("LISTEN_FDNAMES")
+	names := strings.Split(fdNames, ":")
+	var listeners []net.Listener
+	for i := range count {
+		name := "LISTEN_FD_" + strconv.Itoa(firstFd+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(firstFd+i), name)
+		// FileListener duplicates the descriptor, the inherited one is not needed anymore
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("file descriptor %d (%s) is not a listening socket: %w", firstFd+i, name, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// takeInheritedListener returns the first listener given by socket activation, nil when the server must bind
+// its port itself. the variables are removed so that the child processes do not take the descriptors as theirs
+func (s *GoHttpServer) takeInheritedListener() (net.Listener, error) {
+	listeners, err := inheritedListeners(os.LookupEnv, os.Getpid(), listenFdsStart)
+	for _, name := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		os.Unsetenv(name)
+	}
+	if err != nil || len(listeners) == 0 {
+		return nil, err
+	}
+	for _, extra := range listeners[1:] {
+		s.logger.Warn("only the first socket activated listener is served, closing the others", "address", extra.Addr().String())
+		extra.Close()
+	}
+	return listeners[0], nil
+}
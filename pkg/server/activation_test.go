@@ -0,0 +1,82 @@
+//go:build unix
+
+package server
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dupFd returns a new descriptor of f, owned by the caller like the ones inherited by socket activation
+func dupFd(t *testing.T, f *os.File) int {
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+func TestInheritedListeners(t *testing.T) {
+	bound, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bound.Close()
+	boundFile, err := bound.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer boundFile.Close()
+	regular, err := os.Create(filepath.Join(t.TempDir(), "not-a-socket"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer regular.Close()
+	pid := strconv.Itoa(os.Getpid())
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		file     *os.File
+		wantLen  int
+		wantAddr string
+		wantErr  bool
+	}{
+		{name: "1: no LISTEN_FDS should give no listener"},
+		{name: "2: LISTEN_PID of another process should give no listener", env: map[string]string{"LISTEN_PID": "1", "LISTEN_FDS": "1"}},
+		{name: "3: invalid LISTEN_FDS should be an error", env: map[string]string{"LISTEN_FDS": "many"}, wantErr: true},
+		{name: "4: inherited socket should be served", env: map[string]string{"LISTEN_PID": pid, "LISTEN_FDS": "1", "LISTEN_FDNAMES": "http"},
+			file: boundFile, wantLen: 1, wantAddr: bound.Addr().String()},
+		{name: "5: descriptor which is not a socket should be an error", env: map[string]string{"LISTEN_PID": pid, "LISTEN_FDS": "1"},
+			file: regular, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			firstFd := listenFdsStart
+			if tt.file != nil {
+				firstFd = dupFd(t, tt.file)
+			}
+			lookupEnv := func(name string) (string, bool) {
+				val, found := tt.env[name]
+				return val, found
+			}
+			listeners, err := inheritedListeners(lookupEnv, os.Getpid(), firstFd)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, listeners, tt.wantLen)
+			for _, ln := range listeners {
+				assert.Equal(t, tt.wantAddr, ln.Addr().String())
+				ln.Close()
+			}
+		})
+	}
+}
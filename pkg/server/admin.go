@@ -0,0 +1,45 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+)
+
+// newAdminServer returns an http server for the internal admin routes, like the pprof one it has no WriteTimeout
+// so that the cpu profiles and traces can be longer than the one of the main server
+func newAdminServer(listenAddress string, router *http.ServeMux, logger *slog.Logger) *http.Server {
+	return &http.Server{
+		Addr:        listenAddress,
+		Handler:     router,
+		ErrorLog:    slog.NewLogLogger(logger.Handler(), slog.LevelError),
+		ReadTimeout: config.DefaultReadTimeout,
+		IdleTimeout: config.DefaultIdleTimeout,
+	}
+}
+
+// (*GoHttpServer) adminMux returns the router of the admin routes, the main one when there is no admin port
+func (s *GoHttpServer) adminMux() *http.ServeMux {
+	if s.adminRouter != nil {
+		return s.adminRouter
+	}
+	return s.router
+}
+
+// (*GoHttpServer) handleAdmin registers an internal route like /health or /metrics on the admin port when there is one
+func (s *GoHttpServer) handleAdmin(path string, handler http.Handler, middlewares ...Middleware) {
+	s.handleOn(s.adminMux(), path, handler, middlewares...)
+}
+
+// startAdminServer starts the admin listener in his own goroutine. it is not shut down with the main server,
+// so that the probes keep seeing /readiness failing while the main port drains, it is closed when StartServer returns
+func (s *GoHttpServer) startAdminServer() {
+	go func() {
+		s.logger.Info("Starting admin server", "url", fmt.Sprintf("http://%s/", s.adminServer.Addr))
+		if err := s.adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.logger.Error("admin server stopped", "address", s.adminServer.Addr, "error", err)
+		}
+	}()
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoHttpServerAdminPort(t *testing.T) {
+	getStatus := func(router http.Handler, path string) (int, string) {
+		ts := httptest.NewServer(router)
+		defer ts.Close()
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("request on %s failed : %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	assert.Nil(t, myServer.adminServer, "there should be no admin server without ADMIN_PORT")
+	status, _ := getStatus(myServer.router, "/health")
+	assert.Equal(t, http.StatusOK, status, "/health should be on the main port without ADMIN_PORT")
+
+	t.Setenv("ADMIN_PORT", "8081")
+	t.Setenv("ENABLE_PPROF", "true")
+	myServer = NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	if !assert.NotNil(t, myServer.adminServer) {
+		return
+	}
+	assert.Equal(t, config.DefaultListenIp+":8081", myServer.adminServer.Addr)
+	for _, path := range []string{"/health", "/readiness", "/metrics"} {
+		status, _ = getStatus(myServer.adminServer.Handler, path)
+		assert.Equal(t, http.StatusOK, status, "%s should be served by the admin port", path)
+		status, _ = getStatus(myServer.router, path)
+		assert.NotEqual(t, http.StatusOK, status, "%s should not be served by the main port", path)
+	}
+	status, _ = getStatus(myServer.adminServer.Handler, pprofPathPrefix)
+	assert.Equal(t, http.StatusForbidden, status, "pprof on the admin port should need credentials")
+	status, _ = getStatus(myServer.router, pprofPathPrefix)
+	assert.Equal(t, http.StatusNotFound, status, "pprof should not be served by the main port")
+	status, _ = getStatus(myServer.router, "/time")
+	assert.Equal(t, http.StatusOK, status, "the info routes should stay on the main port")
+	status, _ = getStatus(myServer.adminServer.Handler, "/time")
+	assert.Equal(t, http.StatusNotFound, status, "the info routes should not be on the admin port")
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	apiV1Prefix        = "/api/v1"
+	apiV1RootPath      = apiV1Prefix + "/runtime" // versioned path of /, which cannot be a subtree of /api/v1
+	apiStatusSuccess   = "success"
+	apiStatusError     = "error"
+	apiVersion1        = "v1"
+	apiMaxEnvelopeSize = 8 << 20 // bigger answers are refused rather than kept in memory
+)
+
+// ApiError is the error of an ApiEnvelope, with the http status of the answer
+type ApiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// ApiEnvelope wraps the json answers of the /api/v1 routes, Data is the payload of the legacy route
+// and is also given with an error when the handler answered one in json
+type ApiEnvelope struct {
+	ApiVersion string          `json:"api_version"`
+	Status     string          `json:"status"` // success or error
+	Data       json.RawMessage `json:"data,omitempty"`
+	Error      *ApiError       `json:"error,omitempty"`
+	dataETag   string          // ETag of the legacy answer, the Data of a poller changes with its request id or uptime
+}
+
+// versionedPath returns the /api/v1 path of a legacy route
+func versionedPath(path string) string {
+	if path == defaultServerPath {
+		return apiV1RootPath
+	}
+	return apiV1Prefix + path
+}
+
+// bufferedResponseWriter keeps the answer of the handler so that it can be wrapped in an envelope
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.body.Len()+len(b) > apiMaxEnvelopeSize {
+		return 0, http.ErrContentLength
+	}
+	return w.body.Write(b)
+}
+
+// Unwrap returns the original ResponseWriter, so http.ResponseController can reach its optional methods
+func (w *bufferedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// (*GoHttpServer) versioned is the Middleware serving the legacy handler of legacyPath under /api/v1. the handler
+// sees the legacy path and always answers json, which is then wrapped in an ApiEnvelope unless raw is true
+func (s *GoHttpServer) versioned(legacyPath string, raw bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			legacy := new(http.Request)
+			*legacy = *r
+			legacy.URL = new(url.URL)
+			*legacy.URL = *r.URL
+			legacy.URL.Path, legacy.URL.RawPath = legacyPath, ""
+			if legacyPath != defaultServerPath {
+				// the path of the request, the one of the route may have wildcards read by r.PathValue
+				legacy.URL.Path = strings.TrimPrefix(r.URL.Path, apiV1Prefix)
+			}
+			if raw {
+				next.ServeHTTP(w, legacy)
+				return
+			}
+			// the envelope is json, the format of the legacy answer cannot be chosen
+			query := legacy.URL.Query()
+			query.Del(formatQueryParam)
+			legacy.URL.RawQuery = query.Encode()
+			legacy.Header = r.Header.Clone()
+			legacy.Header.Set("Accept", MIMEAppJSON)
+			// the ETag is the one of the envelope, the legacy answer must not be a 304
+			legacy.Header.Del("If-None-Match")
+			bw := &bufferedResponseWriter{ResponseWriter: w, header: w.Header()}
+			next.ServeHTTP(bw, withoutSourceEnvelope(legacy))
+			if bw.status == 0 {
+				bw.status = http.StatusOK
+			}
+			envelope := ApiEnvelope{ApiVersion: apiVersion1, Status: apiStatusSuccess}
+			body := bytes.TrimSpace(bw.body.Bytes())
+			isJson := strings.HasPrefix(w.Header().Get(HeaderContentType), MIMEAppJSON) && json.Valid(body)
+			if isJson {
+				envelope.Data = body
+				envelope.dataETag = w.Header().Get("ETag")
+			}
+			if bw.status >= http.StatusBadRequest {
+				envelope.Status = apiStatusError
+				envelope.Error = &ApiError{Code: bw.status, Message: http.StatusText(bw.status)}
+				if !isJson && len(body) > 0 {
+					// the text of http.Error
+					envelope.Error.Message = strings.TrimPrefix(string(body), "ERROR: ")
+				}
+			} else if !isJson && len(body) > 0 {
+				s.logger.WarnContext(r.Context(), "the answer of a versioned route is not json", "path", r.URL.Path)
+				envelope.Data, _ = json.Marshal(string(body))
+			}
+			w.Header().Del("Content-Length")
+			s.jsonResponseWithStatus(w, r, bw.status, envelope)
+		})
+	}
+}
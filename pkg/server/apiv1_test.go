@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionedPath(t *testing.T) {
+	assert.Equal(t, "/api/v1/runtime", versionedPath("/"))
+	assert.Equal(t, "/api/v1/time", versionedPath("/time"))
+	assert.Equal(t, "/api/v1/k8s/pod", versionedPath("/k8s/pod"))
+}
+
+func TestGoHttpServerApiV1(t *testing.T) {
+	t.Setenv("ENABLE_CHAOS", "true")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	myServer.UseAuth(AuthConfig{Mode: authModeBearer, Token: "secret"})
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	call := func(method, path string, authorized bool) (int, http.Header, ApiEnvelope, map[string]interface{}) {
+		req, _ := http.NewRequest(method, ts.URL+path, nil)
+		if authorized {
+			req.Header.Set("Authorization", "Bearer secret")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request on %s failed : %v", path, err)
+		}
+		defer resp.Body.Close()
+		var envelope ApiEnvelope
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&envelope), "%s should answer json", path)
+		var data map[string]interface{}
+		if len(envelope.Data) > 0 {
+			assert.NoError(t, json.Unmarshal(envelope.Data, &data))
+		}
+		return resp.StatusCode, resp.Header, envelope, data
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		authorized  bool
+		wantStatus  int
+		wantApi     string
+		wantMessage string
+		wantData    map[string]interface{}
+	}{
+		{name: "json answer", method: http.MethodGet, path: "/api/v1/buildinfo", wantStatus: http.StatusOK, wantApi: apiStatusSuccess,
+			wantData: map[string]interface{}{"app": info.APP, "version": info.VERSION}},
+		{name: "format is ignored", method: http.MethodGet, path: "/api/v1/buildinfo?format=yaml", wantStatus: http.StatusOK, wantApi: apiStatusSuccess,
+			wantData: map[string]interface{}{"app": info.APP}},
+		{name: "root", method: http.MethodGet, path: "/api/v1/runtime?name=versioned", authorized: true, wantStatus: http.StatusOK, wantApi: apiStatusSuccess,
+			wantData: map[string]interface{}{"appname": info.APP, "param_name": "versioned"}},
+		{name: "text error", method: http.MethodGet, path: "/api/v1/dns", authorized: true, wantStatus: http.StatusBadRequest, wantApi: apiStatusError,
+			wantMessage: "the host parameter is required"},
+		{name: "not authenticated", method: http.MethodGet, path: "/api/v1/config", wantStatus: http.StatusUnauthorized, wantApi: apiStatusError},
+		{name: "method not allowed", method: http.MethodPost, path: "/api/v1/time", wantStatus: http.StatusMethodNotAllowed, wantApi: apiStatusError,
+			wantMessage: httpErrMethodNotAllow[len("ERROR: "):]},
+		{name: "json error", method: http.MethodGet, path: "/api/v1/chaos/error?code=503", authorized: true, wantStatus: http.StatusServiceUnavailable, wantApi: apiStatusError,
+			wantData: map[string]interface{}{"action": "error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, header, envelope, data := call(tt.method, tt.path, tt.authorized)
+			assert.Equal(t, tt.wantStatus, status)
+			assert.True(t, strings.HasPrefix(header.Get(HeaderContentType), MIMEAppJSON))
+			assert.Equal(t, apiVersion1, envelope.ApiVersion)
+			assert.Equal(t, tt.wantApi, envelope.Status)
+			if tt.wantApi == apiStatusError && assert.NotNil(t, envelope.Error) {
+				assert.Equal(t, tt.wantStatus, envelope.Error.Code)
+				assert.Contains(t, envelope.Error.Message, tt.wantMessage)
+			}
+			for key, val := range tt.wantData {
+				assert.Equal(t, val, data[key], key)
+			}
+		})
+	}
+
+	resp, err := http.Get(ts.URL + "/buildinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var legacy map[string]interface{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&legacy))
+	assert.Equal(t, info.APP, legacy["app"], "the legacy path should keep its payload without envelope")
+
+	resp, err = http.Get(ts.URL + "/api/v1/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var doc map[string]interface{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+	assert.Equal(t, openApiVersion, doc["openapi"], "the raw routes should not be wrapped")
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/health", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "the probes should have no versioned path")
+}
+
+func TestGoHttpServerOpenApiV1(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	doc := myServer.OpenApi()
+	legacy, versioned := doc.Paths["/time"]["get"], doc.Paths["/api/v1/time"]["get"]
+	if !assert.NotNil(t, legacy) || !assert.NotNil(t, versioned) {
+		return
+	}
+	assert.True(t, legacy.Deprecated, "the legacy path should be deprecated")
+	assert.False(t, versioned.Deprecated)
+	schema := versioned.Responses["200"].Content[MIMEAppJSON].Schema
+	assert.Contains(t, schema.Properties, "data")
+	if assert.NotNil(t, schema.Properties["data"]) {
+		assert.Equal(t, "#/components/schemas/TimeResponse", schema.Properties["data"].Ref, "the payload should be in the data of the envelope")
+	}
+	assert.Equal(t, "string", doc.Components.Schemas["TimeResponse"].Properties["time"].Type)
+	assert.Contains(t, doc.Paths, "/api/v1/runtime")
+	assert.False(t, doc.Paths["/health"]["get"].Deprecated, "the probes should not be deprecated")
+	raw := doc.Paths["/api/v1/openapi.json"]["get"].Responses["200"].Content[MIMEAppJSON].Schema
+	assert.NotContains(t, raw.Properties, "data")
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+const (
+	authModeNone   = config.AuthModeNone
+	authModeBasic  = config.AuthModeBasic
+	authModeBearer = config.AuthModeBearer
+	authModeJwt    = config.AuthModeJwt
+)
+
+// AuthConfig contains the credentials protecting the info routes, probes are never protected
+type AuthConfig struct {
+	Mode     string   // one of authModeNone, authModeBasic, authModeBearer or authModeJwt
+	Username string   // used in basic mode
+	Password string   // used in basic mode
+	Token    string   // used in bearer mode
+	JwksUrl  string   // keys of the jwt issuer, used in jwt mode
+	Issuer   string   // iss expected in the jwt, not checked when empty
+	Audience string   // aud expected in the jwt, not checked when empty
+	Claims   []string // claims of the jwt echoed by /whoami, all of them when empty
+}
+
+// GetAuthConfigFromConfig returns the authentication of the info routes given by the validated settings :
+//
+//	auth_mode : none, basic, bearer or jwt
+//	auth_username and auth_password : credentials for the basic mode
+//	auth_token : token expected in the Authorization: Bearer header for the bearer mode
+//	auth_jwks_url : keys validating the jwt of the Authorization: Bearer header for the jwt mode
+//	auth_jwt_issuer and auth_jwt_audience : optional iss and aud expected in the jwt
+//	auth_jwt_claims : comma separated claims of the jwt echoed by /whoami, all of them when empty
+func GetAuthConfigFromConfig(settings config.Config) AuthConfig {
+	authConfig := AuthConfig{Mode: settings.AuthMode}
+	switch authConfig.Mode {
+	case authModeBasic:
+		authConfig.Username, authConfig.Password = settings.AuthUsername, settings.AuthPassword
+	case authModeBearer:
+		authConfig.Token = settings.AuthToken
+	case authModeJwt:
+		authConfig.JwksUrl, authConfig.Issuer, authConfig.Audience = settings.AuthJwksUrl, settings.AuthJwtIssuer, settings.AuthJwtAudience
+		authConfig.Claims = config.SplitList(settings.AuthJwtClaims)
+	}
+	return authConfig
+}
+
+// secureEqual compares two secrets in constant time
+func secureEqual(received, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(received), []byte(expected)) == 1
+}
+
+// enabled returns false when the routes are not protected, with AUTH_MODE none or before UseAuth
+func (c AuthConfig) enabled() bool {
+	return c.Mode != "" && c.Mode != authModeNone
+}
+
+// authorized returns true when the request carries the credentials expected by the AuthConfig
+func (c AuthConfig) authorized(r *http.Request) bool {
+	switch c.Mode {
+	case authModeBasic:
+		username, password, ok := r.BasicAuth()
+		// both comparisons are always done to not leak which one failed
+		userOk := secureEqual(username, c.Username)
+		passwordOk := secureEqual(password, c.Password)
+		return ok && userOk && passwordOk
+	case authModeBearer:
+		auth := r.Header.Get("Authorization")
+		return strings.HasPrefix(auth, "Bearer ") && secureEqual(strings.TrimPrefix(auth, "Bearer "), c.Token)
+	case authModeJwt:
+		// the jwt are validated by the JwtValidator of the server
+		return false
+	}
+	return true
+}
+
+// UseAuth protects the info routes with the given AuthConfig
+func (s *GoHttpServer) UseAuth(config AuthConfig) {
+	s.auth = config
+	s.jwt = nil
+	if config.Mode == authModeJwt {
+		s.jwt = NewJwtValidator(NewJwksCache(config.JwksUrl), config.Issuer, config.Audience)
+	}
+}
+
+// authenticatedContextKey marks in the context of a request that it carried valid credentials
+type authenticatedContextKey struct{}
+
+// withAuthenticated returns r marked as carrying valid credentials
+func withAuthenticated(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), authenticatedContextKey{}, true))
+}
+
+// isRequestAuthenticated returns true when the authenticate Middleware accepted the credentials of r
+func isRequestAuthenticated(r *http.Request) bool {
+	authenticated, _ := r.Context().Value(authenticatedContextKey{}).(bool)
+	return authenticated
+}
+
+// authenticateJwt returns r with the claims of its bearer jwt in its context, or the reason why the jwt is refused
+func (s *GoHttpServer) authenticateJwt(r *http.Request) (*http.Request, error) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, ErrJwtMalformed
+	}
+	claims, err := s.jwt.Validate(r.Context(), strings.TrimPrefix(auth, "Bearer "))
+	if err != nil {
+		return nil, err
+	}
+	return r.WithContext(context.WithValue(r.Context(), jwtClaimsContextKey{}, claims)), nil
+}
+
+// authorizedRequest applies the rule of authenticate and requireAuth for the callers not using the Middleware like
+// the grpc services: r is accepted with the credentials of AUTH_MODE or the API_TOKEN bearer, and without any
+// credentials only when neither AUTH_MODE nor API_TOKEN protects the routes
+func (s *GoHttpServer) authorizedRequest(r *http.Request) bool {
+	if !s.auth.enabled() && s.apiToken == "" {
+		return true
+	}
+	if s.isAuthenticated(r) {
+		return true
+	}
+	switch {
+	case s.auth.Mode == authModeJwt:
+		_, err := s.authenticateJwt(r)
+		return err == nil
+	case s.auth.enabled():
+		return s.auth.authorized(r)
+	}
+	return false
+}
+
+// authenticate is the Middleware refusing the requests without the credentials defined by AUTH_MODE, the API_TOKEN
+// bearer and the access_token of requireAuth are accepted too so both protect the same routes.
+// it must not be used on the probes routes so the kubelet can still reach them
+func (s *GoHttpServer) authenticate() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !s.auth.enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var jwtErr error
+			if s.auth.Mode == authModeJwt {
+				authenticated, err := s.authenticateJwt(r)
+				if err == nil {
+					next.ServeHTTP(w, withAuthenticated(authenticated))
+					return
+				}
+				jwtErr = err
+			} else if s.auth.authorized(r) {
+				next.ServeHTTP(w, withAuthenticated(r))
+				return
+			}
+			if s.isAuthenticated(r) || r.URL.Query().Has(accessTokenQueryParam) {
+				if ok, code := s.checkApiToken(r); !ok {
+					w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", info.APP))
+					s.tokenError(w, r, code)
+					return
+				}
+				next.ServeHTTP(w, withAuthenticated(r))
+				return
+			}
+			if jwtErr != nil {
+				s.audit("request denied, invalid jwt", r, "method", r.Method, "error", jwtErr)
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q, error=\"invalid_token\"", info.APP))
+				s.tokenError(w, r, jwtErrorCode(jwtErr))
+				return
+			}
+			s.audit("request denied, invalid "+s.auth.Mode+" credentials", r, "method", r.Method)
+			if s.auth.Mode == authModeBasic {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", info.APP))
+			} else {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", info.APP))
+			}
+			s.tokenError(w, r, tokenErrUnauthorized)
+		})
+	}
+}
+
+// requireCredentials is the Middleware of the routes changing the state of the server, like the probe toggles. it runs
+// after authenticate and lets through only the requests with the credentials of AUTH_MODE or the API_TOKEN bearer, so
+// with the default AUTH_MODE none and no API_TOKEN these routes are refused, on the admin port too since it listens on
+// LISTEN_IP like the main one
+func (s *GoHttpServer) requireCredentials() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isRequestAuthenticated(r) || s.isAuthenticated(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			s.audit("request denied, privileged route without credentials", r, "method", r.Method)
+			if !s.auth.enabled() && s.apiToken == "" {
+				http.Error(w, "ERROR: this route needs the credentials of AUTH_MODE or API_TOKEN", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", info.APP))
+			s.tokenError(w, r, tokenErrUnauthorized)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAuthConfigFromConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(c *config.Config)
+		want      AuthConfig
+	}{
+		{name: "1: the default auth_mode should disable auth", configure: func(c *config.Config) {}, want: AuthConfig{Mode: authModeNone}},
+		{name: "2: basic mode should use the credentials", configure: func(c *config.Config) {
+			c.AuthMode, c.AuthUsername, c.AuthPassword, c.AuthToken = authModeBasic, "admin", "pass", "unused"
+		}, want: AuthConfig{Mode: authModeBasic, Username: "admin", Password: "pass"}},
+		{name: "3: bearer mode should use the token", configure: func(c *config.Config) {
+			c.AuthMode, c.AuthUsername, c.AuthToken = authModeBearer, "unused", "t0ken"
+		}, want: AuthConfig{Mode: authModeBearer, Token: "t0ken"}},
+		{name: "4: jwt mode should use the jwks url, issuer, audience and claims", configure: func(c *config.Config) {
+			c.AuthMode, c.AuthJwksUrl, c.AuthJwtIssuer, c.AuthJwtAudience, c.AuthJwtClaims = authModeJwt, "https://issuer.example/keys", "https://issuer.example", "go-info", "sub, email"
+		}, want: AuthConfig{Mode: authModeJwt, JwksUrl: "https://issuer.example/keys", Issuer: "https://issuer.example", Audience: "go-info", Claims: []string{"sub", "email"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.DefaultConfig()
+			tt.configure(&settings)
+			assert.Equal(t, tt.want, GetAuthConfigFromConfig(settings))
+		})
+	}
+}
+
+func TestGoHttpServerAuth(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	myServer.UseAuth(AuthConfig{Mode: authModeBasic, Username: "admin", Password: "pass"})
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name           string
+		url            string
+		username       string
+		password       string
+		wantStatusCode int
+	}{
+		{name: "1: Get / without credentials should be refused", url: "/", wantStatusCode: http.StatusUnauthorized},
+		{name: "2: Get / with a wrong password should be refused", url: "/", username: "admin", password: "wrong", wantStatusCode: http.StatusUnauthorized},
+		{name: "3: Get / with the credentials should be accepted", url: "/", username: "admin", password: "pass", wantStatusCode: http.StatusOK},
+		{name: "4: Get /info/memory without credentials should be refused", url: "/info/memory", wantStatusCode: http.StatusUnauthorized},
+		{name: "5: Get /health should stay open for the probes", url: "/health", wantStatusCode: http.StatusOK},
+		{name: "6: Get /readiness should stay open for the probes", url: "/readiness", wantStatusCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, ts.URL+tt.url, nil)
+			if tt.username != "" {
+				r.SetBasicAuth(tt.username, tt.password)
+			}
+			resp, err := http.DefaultClient.Do(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode, assertCorrectStatusCodeExpected)
+			if resp.StatusCode == http.StatusUnauthorized {
+				assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "Basic realm=")
+			}
+		})
+	}
+
+	myServer.UseAuth(AuthConfig{Mode: authModeBearer, Token: "secret"})
+	r, _ := http.NewRequest(http.MethodGet, ts.URL+"/", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "the bearer token should be accepted")
+}
+
+func TestGoHttpServerAuthWithAccessToken(t *testing.T) {
+	const apiToken = "a-very-secret-api-token"
+	t.Setenv("API_TOKEN", apiToken)
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	myServer.UseAuth(AuthConfig{Mode: authModeBearer, Token: "secret"})
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	issue := func(path string) string {
+		r, _ := http.NewRequest(http.MethodPost, ts.URL+"/token", strings.NewReader(`{"path":"`+path+`"}`))
+		r.Header.Set("Authorization", "Bearer "+apiToken)
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var issued tokenResponse
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&issued), "the output should be a valid json")
+		return issued.AccessToken
+	}
+	token := issue("/")
+	otherToken := issue("/info/memory")
+
+	tests := []struct {
+		name           string
+		url            string
+		bearer         string
+		wantStatusCode int
+		wantBody       string
+	}{
+		{name: "1: Get / with the AUTH_MODE bearer should be accepted", url: "/", bearer: "secret", wantStatusCode: http.StatusOK, wantBody: `"appname"`},
+		{name: "2: Get / with the API_TOKEN bearer should be accepted", url: "/", bearer: apiToken, wantStatusCode: http.StatusOK, wantBody: `"appname"`},
+		{name: "3: Get / with the access_token should be accepted", url: "/?access_token=" + token, wantStatusCode: http.StatusOK, wantBody: `"appname"`},
+		{name: "4: Get / with the same access_token should be refused", url: "/?access_token=" + token, wantStatusCode: http.StatusUnauthorized, wantBody: tokenErrAlreadyUsed},
+		{name: "5: Get / with the access_token of another path should be refused", url: "/?access_token=" + otherToken, wantStatusCode: http.StatusUnauthorized, wantBody: tokenErrPathMismatch},
+		{name: "6: Get / without credentials should be refused", url: "/", wantStatusCode: http.StatusUnauthorized, wantBody: tokenErrUnauthorized},
+		{name: "7: Get / with a wrong bearer should be refused", url: "/", bearer: "wrong", wantStatusCode: http.StatusUnauthorized, wantBody: tokenErrUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, ts.URL+tt.url, nil)
+			if tt.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			resp, err := http.DefaultClient.Do(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode, assertCorrectStatusCodeExpected)
+			assert.Contains(t, string(body), tt.wantBody)
+		})
+	}
+}
+
+func TestGoHttpServerTokenWithAuthMode(t *testing.T) {
+	t.Setenv("AUTH_MODE", authModeBasic)
+	t.Setenv("AUTH_USERNAME", "admin")
+	t.Setenv("AUTH_PASSWORD", "pass")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	do := func(method, url, body string, basicAuth bool) (int, string) {
+		r, _ := http.NewRequest(method, ts.URL+url, strings.NewReader(body))
+		if basicAuth {
+			r.SetBasicAuth("admin", "pass")
+		}
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		received, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(received)
+	}
+
+	status, _ := do(http.MethodPost, "/token", `{"path":"/config"}`, false)
+	assert.Equal(t, http.StatusUnauthorized, status, "token issuance without credentials should be refused")
+	status, body := do(http.MethodPost, "/token", `{"path":"/config"}`, true)
+	assert.Equal(t, http.StatusOK, status, "the credentials of AUTH_MODE should be enough to issue an access_token")
+	var issued tokenResponse
+	assert.Nil(t, json.Unmarshal([]byte(body), &issued), "the output should be a valid json")
+
+	status, body = do(http.MethodPost, "/token?access_token="+issued.AccessToken, `{"path":"/"}`, false)
+	assert.Equal(t, http.StatusUnauthorized, status, "an access_token should not be exchanged for another one")
+	assert.Contains(t, body, tokenErrPathMismatch)
+	status, body = do(http.MethodGet, "/config?access_token="+issued.AccessToken, "", false)
+	assert.Equal(t, http.StatusOK, status, "the access_token should open the route it is bound to")
+	assert.Contains(t, body, `"settings"`)
+}
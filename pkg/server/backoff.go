@@ -0,0 +1,29 @@
+package server
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// retryWithBackoff calls try until it returns done, with an exponential backoff between the attempts starting at
+// backoffMin and doubling up to backoffMax. onRetry is called with the error of the attempt and the sleep before the
+// next one. it returns the error of the last attempt, or ctx.Err() when ctx is done during a sleep
+func retryWithBackoff(ctx context.Context, backoffMin, backoffMax time.Duration, try func(attempt int) (done bool, err error), onRetry func(attempt int, err error, sleep time.Duration)) error {
+	backoff := backoffMin
+	for attempt := 1; ; attempt++ {
+		done, err := try(attempt)
+		if done {
+			return err
+		}
+		// jitter : sleep a random duration between backoff/2 and backoff
+		sleep := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		onRetry(attempt, err, sleep)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(sleep):
+		}
+		backoff = min(backoff*2, backoffMax)
+	}
+}
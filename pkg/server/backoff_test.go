@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryWithBackoff(t *testing.T) {
+	errDown := errors.New("down")
+	backoffMin, backoffMax := 2*time.Millisecond, 8*time.Millisecond
+	var sleeps []time.Duration
+	err := retryWithBackoff(context.Background(), backoffMin, backoffMax, func(attempt int) (bool, error) {
+		return attempt >= 5, errDown
+	}, func(attempt int, err error, sleep time.Duration) {
+		assert.ErrorIs(t, err, errDown)
+		assert.Equal(t, len(sleeps)+1, attempt)
+		sleeps = append(sleeps, sleep)
+	})
+	assert.ErrorIs(t, err, errDown, "the error of the last attempt should be returned")
+	if assert.Len(t, sleeps, 4, "there should be a sleep between two attempts") {
+		for i, backoff := range []time.Duration{2 * time.Millisecond, 4 * time.Millisecond, 8 * time.Millisecond, 8 * time.Millisecond} {
+			assert.GreaterOrEqual(t, sleeps[i], backoff/2, "sleep %d should be at least half the backoff", i+1)
+			assert.LessOrEqual(t, sleeps[i], backoff, "sleep %d should be at most the backoff, doubled up to the max", i+1)
+		}
+	}
+
+	err = retryWithBackoff(context.Background(), backoffMin, backoffMax, func(attempt int) (bool, error) {
+		return true, nil
+	}, func(attempt int, err error, sleep time.Duration) {
+		t.Error("a successful first attempt should not be retried")
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts := 0
+	err = retryWithBackoff(ctx, time.Hour, time.Hour, func(attempt int) (bool, error) {
+		attempts++
+		return false, errDown
+	}, func(attempt int, err error, sleep time.Duration) {})
+	assert.ErrorIs(t, err, context.Canceled, "a done context should stop the retries")
+	assert.Equal(t, 1, attempts)
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+// BannerVars are the variables available in the templates of INFO_MESSAGE and of BANNER_FILE
+type BannerVars struct {
+	Hostname  string
+	Namespace string // namespace of the pod, empty outside k8s
+	PodName   string
+	Version   string
+	Appname   string
+}
+
+// NewBannerVars returns the variables of the banner taken from the RuntimeInfo
+func NewBannerVars(data info.RuntimeInfo) BannerVars {
+	vars := BannerVars{
+		Hostname:  data.Hostname,
+		Namespace: data.K8sCurrentNamespace,
+		Version:   data.Version,
+		Appname:   data.Appname,
+	}
+	if data.K8s != nil {
+		vars.PodName = data.K8s.PodName
+		if data.K8s.PodNamespace != "" {
+			vars.Namespace = data.K8s.PodNamespace
+		}
+	}
+	return vars
+}
+
+// expandBanner executes text as a go template with the variables vars
+func expandBanner(text string, vars BannerVars) (string, error) {
+	tmpl, err := template.New("banner").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// Banner gives the message of INFO_MESSAGE and the banner of BANNER_FILE shown by / and the dashboard,
+// to tell apart the canary or the blue and green deployments. both settings can be changed while running,
+// and like for ConfigReloader the banner file is read again when its modification time changes, like a mounted configmap.
+type Banner struct {
+	logger    *slog.Logger
+	mu        sync.Mutex
+	path      string    // banner file read last
+	modTime   time.Time // modification time of the banner file when it was read
+	text      string    // content of the banner file
+	lastError string    // last error reading the banner file, logged only when it changes
+}
+
+// NewBanner is a constructor for a Banner
+func NewBanner(logger *slog.Logger) *Banner {
+	return &Banner{logger: logger}
+}
+
+// fileText returns the content of the banner file at path, empty when it cannot be read
+func (b *Banner) fileText(path string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	fileInfo, err := os.Stat(path)
+	if err == nil && path == b.path && fileInfo.ModTime().Equal(b.modTime) {
+		return b.text
+	}
+	var content []byte
+	if err == nil {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		if err.Error() != b.lastError {
+			b.logger.Warn("unable to read the banner file", "file", path, "error", err)
+			b.lastError = err.Error()
+		}
+		b.path, b.modTime, b.text = "", time.Time{}, ""
+		return ""
+	}
+	b.path, b.modTime, b.text, b.lastError = path, fileInfo.ModTime(), string(content), ""
+	return b.text
+}
+
+// Expand returns the message and the banner of the configuration c with the variables vars expanded,
+// a banner file which is not a valid template is returned as is
+func (b *Banner) Expand(c config.Config, vars BannerVars) (message, banner string) {
+	if c.InfoMessage != "" {
+		// the config validation already refused an invalid template
+		message, _ = expandBanner(c.InfoMessage, vars)
+	}
+	if c.BannerFile != "" {
+		text := b.fileText(c.BannerFile)
+		expanded, err := expandBanner(text, vars)
+		if err != nil {
+			b.logger.Debug("banner file is not a valid template, shown as is", "file", c.BannerFile, "error", err)
+			expanded = strings.TrimSpace(text)
+		}
+		banner = expanded
+	}
+	return message, banner
+}
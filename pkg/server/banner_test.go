@@ -0,0 +1,100 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBannerVars(t *testing.T) {
+	tests := []struct {
+		name string
+		data info.RuntimeInfo
+		want BannerVars
+	}{
+		{name: "1: outside k8s should leave the namespace empty", data: info.RuntimeInfo{Hostname: "laptop", Version: "1.2.3", Appname: info.APP},
+			want: BannerVars{Hostname: "laptop", Version: "1.2.3", Appname: info.APP}},
+		{name: "2: downward api namespace should be preferred", data: info.RuntimeInfo{Hostname: "pod-1", K8sCurrentNamespace: "default",
+			K8s: &info.K8sDownwardInfo{PodName: "pod-1", PodNamespace: "canary"}},
+			want: BannerVars{Hostname: "pod-1", Namespace: "canary", PodName: "pod-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NewBannerVars(tt.data))
+		})
+	}
+}
+
+func TestBannerExpand(t *testing.T) {
+	dir := t.TempDir()
+	bannerFile := filepath.Join(dir, "banner.txt")
+	vars := BannerVars{Hostname: "pod-1", Namespace: "green", Version: "1.2.3"}
+	b := NewBanner(getTestLogger())
+	tests := []struct {
+		name        string
+		content     string
+		config      config.Config
+		wantMessage string
+		wantBanner  string
+	}{
+		{name: "1: nothing configured should give nothing"},
+		{name: "2: INFO_MESSAGE should be expanded", config: config.Config{InfoMessage: "{{.Namespace}} v{{.Version}}"}, wantMessage: "green v1.2.3"},
+		{name: "3: banner file should be expanded", content: "served by {{.Hostname}}\n", config: config.Config{BannerFile: bannerFile},
+			wantBanner: "served by pod-1"},
+		{name: "4: changed banner file should be read again", content: "now {{.Namespace}}", config: config.Config{BannerFile: bannerFile},
+			wantBanner: "now green"},
+		{name: "5: invalid template in the banner file should be shown as is", content: "{{ broken", config: config.Config{BannerFile: bannerFile},
+			wantBanner: "{{ broken"},
+		{name: "6: missing banner file should give no banner", config: config.Config{BannerFile: filepath.Join(dir, "missing.txt")}},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.content != "" {
+				assert.Nil(t, os.WriteFile(bannerFile, []byte(tt.content), 0o600))
+				// the cache is based on the modification time, which may not change between two fast writes
+				modTime := time.Now().Add(time.Duration(i) * time.Second)
+				assert.Nil(t, os.Chtimes(bannerFile, modTime, modTime))
+			}
+			message, banner := b.Expand(tt.config, vars)
+			assert.Equal(t, tt.wantMessage, message)
+			assert.Equal(t, tt.wantBanner, banner)
+		})
+	}
+}
+
+func TestGoHttpServerBanner(t *testing.T) {
+	t.Setenv("INFO_MESSAGE", "canary <{{.Version}}>")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		accept   string
+		wantBody string
+	}{
+		{name: "1: json should contain the message", accept: "application/json", wantBody: `"message":"canary \u003c` + info.VERSION},
+		{name: "2: dashboard should show the escaped message", accept: "text/html", wantBody: "canary &lt;" + info.VERSION + "&gt;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+"/", nil)
+			req.Header.Set("Accept", tt.accept)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			assert.Contains(t, string(body), tt.wantBody)
+		})
+	}
+}
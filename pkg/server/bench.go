@@ -0,0 +1,268 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	mathrand "math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDiskBenchBytes  = 64 << 20
+	maxDiskBenchBytes      = 1 << 30
+	diskBenchSeqBlockBytes = 1 << 20 // size of the sequential writes and reads
+	diskBenchRandBlockSize = 4 << 10 // size of the random writes and reads, the page size of most databases
+	diskBenchMaxRandomOps  = 500     // bounds the random phases, each random write is followed by a fsync
+	diskBenchNote          = "the reads may be served by the page cache of the node, the writes are synced to the storage"
+)
+
+var (
+	errBenchBusy    = errors.New("another benchmark is running")
+	errBenchNoSpace = errors.New("not enough available space for the benchmark file")
+	errBenchNotADir = errors.New("the path should be an existing directory")
+)
+
+// LatencyStats summarizes the latencies of the operations of a benchmark, in milliseconds
+type LatencyStats struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min_ms"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+// NewLatencyStats returns the percentiles of latencies, using the nearest rank method
+func NewLatencyStats(latencies []time.Duration) LatencyStats {
+	if len(latencies) == 0 {
+		return LatencyStats{}
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	rank := func(p float64) float64 {
+		return ms(sorted[int(math.Ceil(p*float64(len(sorted))))-1])
+	}
+	return LatencyStats{
+		Count: len(sorted),
+		Min:   ms(sorted[0]),
+		P50:   rank(0.50),
+		P90:   rank(0.90),
+		P99:   rank(0.99),
+		Max:   ms(sorted[len(sorted)-1]),
+	}
+}
+
+// parseByteSize parses a size like 512, 4K, 100M or 1G, the suffixes are powers of 1024 and may end with i or iB
+func parseByteSize(val string) (int64, error) {
+	val = strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(val)), "B"), "I")
+	multiplier := int64(1)
+	if n := len(val); n > 0 {
+		switch val[n-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			val = val[:n-1]
+		}
+	}
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("invalid size %q", val)
+	}
+	return n * multiplier, nil
+}
+
+// DiskBenchPhase is the result of one phase of the disk benchmark
+type DiskBenchPhase struct {
+	Name           string       `json:"name"` // sequential_write, sequential_read, random_write or random_read
+	BlockBytes     int          `json:"block_bytes"`
+	Bytes          int64        `json:"bytes"`
+	Seconds        float64      `json:"seconds"`
+	ThroughputMBps float64      `json:"throughput_mbps"` // in MiB per second
+	Iops           float64      `json:"iops"`
+	Latency        LatencyStats `json:"latency"`
+}
+
+// DiskBenchReport is the result of a disk benchmark made by /bench/disk
+type DiskBenchReport struct {
+	Path        string           `json:"path"`
+	SizeBytes   int64            `json:"size_bytes"`
+	Phases      []DiskBenchPhase `json:"phases"`
+	Interrupted bool             `json:"interrupted"` // true when the client disconnected before the end
+	Note        string           `json:"note"`
+}
+
+// DiskBenchmark measures the storage of a mount, allowing one benchmark at a time
+type DiskBenchmark struct {
+	mu       sync.Mutex
+	maxBytes int64
+}
+
+// NewDiskBenchmark is a constructor for a DiskBenchmark writing at most maxBytes per run
+func NewDiskBenchmark(maxBytes int64) *DiskBenchmark {
+	return &DiskBenchmark{maxBytes: maxBytes}
+}
+
+// newPhase returns the phase named name with its throughput, iops and latencies
+func newPhase(name string, blockBytes int, latencies []time.Duration, elapsed time.Duration) DiskBenchPhase {
+	phase := DiskBenchPhase{
+		Name:       name,
+		BlockBytes: blockBytes,
+		Bytes:      int64(blockBytes) * int64(len(latencies)),
+		Seconds:    elapsed.Seconds(),
+		Latency:    NewLatencyStats(latencies),
+	}
+	if elapsed > 0 {
+		phase.ThroughputMBps = float64(phase.Bytes) / (1 << 20) / elapsed.Seconds()
+		phase.Iops = float64(len(latencies)) / elapsed.Seconds()
+	}
+	return phase
+}
+
+// Run writes then reads a temporary file of size bytes in dir, sequentially then at random offsets, and removes it.
+// it stops between two operations when ctx is done, returning the phases completed so far
+func (db *DiskBenchmark) Run(ctx context.Context, dir string, size int64) (DiskBenchReport, error) {
+	report := DiskBenchReport{Path: dir, SizeBytes: size, Phases: []DiskBenchPhase{}, Note: diskBenchNote}
+	if !db.mu.TryLock() {
+		return report, errBenchBusy
+	}
+	defer db.mu.Unlock()
+	if st, err := os.Stat(dir); err != nil || !st.IsDir() {
+		return report, errBenchNotADir
+	}
+	if usage, err := statFilesystem(dir); err == nil && usage.TotalBytes > 0 && usage.AvailableBytes < uint64(size)*2 {
+		// keeps half of the available space, so the benchmark cannot fill a volume used by the application
+		return report, errBenchNoSpace
+	}
+	f, err := os.CreateTemp(dir, ".go-info-bench-*")
+	if err != nil {
+		return report, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// random data, so that compression or deduplication of the storage does not flatter the result
+	block := make([]byte, diskBenchSeqBlockBytes)
+	if _, err := rand.Read(block); err != nil {
+		return report, err
+	}
+	seqBlocks := int((size + diskBenchSeqBlockBytes - 1) / diskBenchSeqBlockBytes)
+	randOps := int(min(size/diskBenchRandBlockSize, diskBenchMaxRandomOps))
+	randOffset := func() int64 {
+		return mathrand.Int64N(size/diskBenchRandBlockSize) * diskBenchRandBlockSize
+	}
+	type phaseRunner struct {
+		name       string
+		blockBytes int
+		ops        int
+		op         func(i int) error
+		after      func() error
+	}
+	phases := []phaseRunner{
+		{name: "sequential_write", blockBytes: diskBenchSeqBlockBytes, ops: seqBlocks, op: func(i int) error {
+			_, err := f.WriteAt(block, int64(i)*diskBenchSeqBlockBytes)
+			return err
+		}, after: f.Sync},
+		{name: "sequential_read", blockBytes: diskBenchSeqBlockBytes, ops: seqBlocks, op: func(i int) error {
+			_, err := f.ReadAt(block, int64(i)*diskBenchSeqBlockBytes)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}},
+		{name: "random_write", blockBytes: diskBenchRandBlockSize, ops: randOps, op: func(_ int) error {
+			if _, err := f.WriteAt(block[:diskBenchRandBlockSize], randOffset()); err != nil {
+				return err
+			}
+			return f.Sync()
+		}},
+		{name: "random_read", blockBytes: diskBenchRandBlockSize, ops: randOps, op: func(_ int) error {
+			_, err := f.ReadAt(block[:diskBenchRandBlockSize], randOffset())
+			return err
+		}},
+	}
+	for _, p := range phases {
+		latencies := make([]time.Duration, 0, p.ops)
+		start := time.Now()
+		for i := 0; i < p.ops; i++ {
+			if ctx.Err() != nil {
+				report.Interrupted = true
+				return report, nil
+			}
+			opStart := time.Now()
+			if err := p.op(i); err != nil {
+				return report, fmt.Errorf("%s: %w", p.name, err)
+			}
+			latencies = append(latencies, time.Since(opStart))
+		}
+		if p.after != nil {
+			if err := p.after(); err != nil {
+				return report, fmt.Errorf("%s: %w", p.name, err)
+			}
+		}
+		report.Phases = append(report.Phases, newPhase(p.name, p.blockBytes, latencies, time.Since(start)))
+	}
+	return report, nil
+}
+
+//############# BEGIN BENCH HANDLERS
+
+// DiskBenchHandler writes and reads a temporary file of the size parameter (like 100M) in the path directory,
+// reporting the throughput and latency percentiles, to verify the performance of a storage class from the pod
+func (s *GoHttpServer) DiskBenchHandler(db *DiskBenchmark) http.HandlerFunc {
+	handlerName := "DiskBenchHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("path") == "" {
+			http.Error(w, "ERROR: a path parameter with the directory to benchmark is required", http.StatusBadRequest)
+			return
+		}
+		size := int64(defaultDiskBenchBytes)
+		if val := query.Get("size"); val != "" {
+			var err error
+			size, err = parseByteSize(val)
+			if err != nil || size < diskBenchRandBlockSize || size > db.maxBytes {
+				http.Error(w, fmt.Sprintf("ERROR: parameter size should be a size like 100M between %d and %d bytes", diskBenchRandBlockSize, db.maxBytes), http.StatusBadRequest)
+				return
+			}
+		}
+		dir := filepath.Clean(query.Get("path"))
+		extendWriteDeadline(w, maxLoadDuration)
+		ctx, cancel := context.WithTimeout(r.Context(), maxLoadDuration)
+		defer cancel()
+		s.logger.Info("starting disk benchmark", "handler", handlerName, "path", dir, "size", size)
+		report, err := db.Run(ctx, dir, size)
+		switch {
+		case errors.Is(err, errBenchBusy):
+			http.Error(w, "ERROR: "+err.Error(), http.StatusTooManyRequests)
+		case errors.Is(err, errBenchNotADir):
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+		case errors.Is(err, errBenchNoSpace):
+			http.Error(w, "ERROR: "+err.Error(), http.StatusInsufficientStorage)
+		case err != nil:
+			s.logger.Error("disk benchmark failed", "handler", handlerName, "path", dir, "error", err)
+			http.Error(w, "ERROR: disk benchmark failed : "+err.Error(), http.StatusInternalServerError)
+		default:
+			s.render(w, r, http.StatusOK, report)
+		}
+	}
+}
+
+// ############# END BENCH HANDLERS
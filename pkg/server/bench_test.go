@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLatencyStats(t *testing.T) {
+	assert.Equal(t, LatencyStats{}, NewLatencyStats(nil))
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	stats := NewLatencyStats(latencies)
+	assert.Equal(t, LatencyStats{Count: 100, Min: 1, P50: 50, P90: 90, P99: 99, Max: 100}, stats)
+	assert.Equal(t, time.Duration(100)*time.Millisecond, latencies[0], "the latencies given should not be sorted in place")
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		val     string
+		want    int64
+		wantErr bool
+	}{
+		{name: "1: plain bytes", val: "512", want: 512},
+		{name: "2: kilobytes", val: "4K", want: 4096},
+		{name: "3: megabytes in lower case", val: "100m", want: 100 << 20},
+		{name: "4: gibibytes", val: "1GiB", want: 1 << 30},
+		{name: "5: megabytes with B", val: "10MB", want: 10 << 20},
+		{name: "6: negative size should be an error", val: "-1M", wantErr: true},
+		{name: "7: unknown suffix should be an error", val: "1T", wantErr: true},
+		{name: "8: empty should be an error", val: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseByteSize(tt.val)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseByteSize(%q) error = %v, wantErr %v", tt.val, err, tt.wantErr)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDiskBenchmarkRun(t *testing.T) {
+	dir := t.TempDir()
+	db := NewDiskBenchmark(maxDiskBenchBytes)
+	report, err := db.Run(context.Background(), dir, 2<<20)
+	assert.NoError(t, err)
+	assert.False(t, report.Interrupted)
+	if assert.Len(t, report.Phases, 4) {
+		assert.Equal(t, "sequential_write", report.Phases[0].Name)
+		assert.Equal(t, int64(2<<20), report.Phases[0].Bytes)
+		assert.Equal(t, 2, report.Phases[1].Latency.Count)
+		assert.Equal(t, "random_read", report.Phases[3].Name)
+		assert.Equal(t, diskBenchMaxRandomOps, report.Phases[3].Latency.Count)
+	}
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries, "the benchmark file should be removed")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report, err = db.Run(ctx, dir, 1<<20)
+	assert.NoError(t, err)
+	assert.True(t, report.Interrupted, "the benchmark should stop when the client disconnects")
+
+	_, err = db.Run(context.Background(), filepath.Join(dir, "missing"), 1<<20)
+	assert.ErrorIs(t, err, errBenchNotADir)
+
+	db.mu.Lock()
+	_, err = db.Run(context.Background(), dir, 1<<20)
+	db.mu.Unlock()
+	assert.ErrorIs(t, err, errBenchBusy, "only one benchmark should run at a time")
+}
+
+func TestGoHttpServerDiskBenchHandler(t *testing.T) {
+	t.Setenv("ENABLE_LOAD", "true")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+	dir := t.TempDir()
+
+	tests := []struct {
+		name           string
+		url            string
+		wantStatusCode int
+	}{
+		{name: "1: small benchmark should report the phases", url: "/bench/disk?size=64K&path=" + dir, wantStatusCode: http.StatusOK},
+		{name: "2: missing path should be a bad request", url: "/bench/disk", wantStatusCode: http.StatusBadRequest},
+		{name: "3: too big size should be a bad request", url: "/bench/disk?size=10G&path=" + dir, wantStatusCode: http.StatusBadRequest},
+		{name: "4: invalid size should be a bad request", url: "/bench/disk?size=lots&path=" + dir, wantStatusCode: http.StatusBadRequest},
+		{name: "5: missing directory should be a bad request", url: "/bench/disk?path=" + filepath.Join(dir, "missing"), wantStatusCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode, assertCorrectStatusCodeExpected)
+			if resp.StatusCode == http.StatusOK {
+				var report DiskBenchReport
+				assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report), "the output should be a valid json")
+				assert.Len(t, report.Phases, 4)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+const (
+	defaultNetBenchBytes = 64 << 20
+	maxNetBenchBytes     = 1 << 30
+	defaultNetBenchPings = 10
+	maxNetBenchPings     = 1000
+	netBenchSinkPath     = "/bench/net/sink"
+)
+
+// NetBenchSinkReport is what the peer received on /bench/net/sink, measured on its side
+type NetBenchSinkReport struct {
+	Bytes          int64   `json:"bytes"`
+	Seconds        float64 `json:"seconds"`
+	ThroughputMbps float64 `json:"throughput_mbps"` // in megabits per second, like the network links
+	Hostname       string  `json:"hostname"`
+	NodeName       string  `json:"node_name,omitempty"` // from the downward api env MY_NODE_NAME or NODE_NAME
+}
+
+// NetBenchReport is the result of a network benchmark made by /bench/net against a peer go-info-server
+type NetBenchReport struct {
+	Peer           string              `json:"peer"`
+	ResolvedIp     string              `json:"resolved_ip,omitempty"`
+	NodeName       string              `json:"node_name,omitempty"` // node of this pod, to compare with the node of the peer
+	Success        bool                `json:"success"`
+	FirstRequestMs float64             `json:"first_request_ms,omitempty"` // includes the tcp connection and the tls handshake
+	Latency        LatencyStats        `json:"latency"`                    // round trips of empty requests on the open connection
+	UploadBytes    int64               `json:"upload_bytes"`
+	UploadSeconds  float64             `json:"upload_seconds"`
+	ThroughputMbps float64             `json:"throughput_mbps"` // in megabits per second, as measured by this pod
+	Sink           *NetBenchSinkReport `json:"sink,omitempty"`  // the upload as measured by the peer
+	Error          string              `json:"error,omitempty"`
+}
+
+// repeatReader returns remaining bytes taken from block again and again
+type repeatReader struct {
+	block     []byte
+	offset    int
+	remaining int64
+}
+
+func (rr *repeatReader) Read(p []byte) (int, error) {
+	if rr.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > rr.remaining {
+		p = p[:rr.remaining]
+	}
+	n := copy(p, rr.block[rr.offset:])
+	rr.offset = (rr.offset + n) % len(rr.block)
+	rr.remaining -= int64(n)
+	return n, nil
+}
+
+// megabitsPerSecond returns the throughput of bytes sent in elapsed
+func megabitsPerSecond(bytes int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(bytes) * 8 / 1e6 / elapsed.Seconds()
+}
+
+// NetBenchmark measures the network between this pod and a peer go-info-server, allowing one benchmark at a time
+type NetBenchmark struct {
+	mu        sync.Mutex
+	connector *Connector
+	maxBytes  int64
+}
+
+// NewNetBenchmark is a constructor for a NetBenchmark reaching the peers allowed by connector and sending at most maxBytes
+func NewNetBenchmark(connector *Connector, maxBytes int64) *NetBenchmark {
+	return &NetBenchmark{connector: connector, maxBytes: maxBytes}
+}
+
+// Run sends pings empty requests then size bytes to the /bench/net/sink of peer, given as the base url of a go-info-server.
+// authorization is sent as is to the peer, so the same token works when the instances share their AUTH settings
+func (nb *NetBenchmark) Run(ctx context.Context, peer string, size int64, pings int, authorization string) (NetBenchReport, error) {
+	report := NetBenchReport{Peer: peer, NodeName: info.LookupFirstEnv(os.LookupEnv, "MY_NODE_NAME", "NODE_NAME")}
+	if !nb.mu.TryLock() {
+		return report, errBenchBusy
+	}
+	defer nb.mu.Unlock()
+	u, err := url.Parse(peer)
+	if err != nil {
+		return report, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return report, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	ip, err := nb.connector.resolve(ctx, u.Hostname())
+	if errors.Is(err, errConnectNotAllowed) {
+		return report, err
+	}
+	if err != nil {
+		report.Error = err.Error()
+		return report, nil
+	}
+	report.ResolvedIp = ip.String()
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			_, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, network, net.JoinHostPort(report.ResolvedIp, port))
+		},
+		// the compression would measure the cpu instead of the network
+		DisableCompression: true,
+	}
+	defer transport.CloseIdleConnections()
+	client := http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	sinkUrl := u.JoinPath(netBenchSinkPath).String()
+	send := func(body io.Reader, contentLength int64) (*NetBenchSinkReport, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, sinkUrl, body)
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = contentLength
+		req.Header.Set("Accept", MIMEAppJSON)
+		req.Header.Set("User-Agent", fmt.Sprintf("%s/%s", info.APP, info.VERSION))
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		InjectRequestId(ctx, req)
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("the peer answered %s to %s", resp.Status, sinkUrl)
+		}
+		var sink NetBenchSinkReport
+		if err := json.NewDecoder(resp.Body).Decode(&sink); err != nil {
+			return nil, fmt.Errorf("decoding the answer of the peer: %w", err)
+		}
+		return &sink, nil
+	}
+
+	// the first request opens the connection, the next ones reuse it and only measure the round trip
+	latencies := make([]time.Duration, 0, pings)
+	for i := 0; i <= pings; i++ {
+		start := time.Now()
+		if _, err := send(http.NoBody, 0); err != nil {
+			report.Error = err.Error()
+			return report, nil
+		}
+		if i == 0 {
+			report.FirstRequestMs = float64(time.Since(start).Microseconds()) / 1000
+		} else {
+			latencies = append(latencies, time.Since(start))
+		}
+	}
+	report.Latency = NewLatencyStats(latencies)
+
+	// random data, so that a compressing proxy or tunnel does not flatter the result
+	block := make([]byte, diskBenchSeqBlockBytes)
+	if _, err := rand.Read(block); err != nil {
+		return report, err
+	}
+	start := time.Now()
+	sink, err := send(&repeatReader{block: block, remaining: size}, size)
+	elapsed := time.Since(start)
+	if err != nil {
+		report.Error = err.Error()
+		return report, nil
+	}
+	report.Success = true
+	report.UploadBytes = size
+	report.UploadSeconds = elapsed.Seconds()
+	report.ThroughputMbps = megabitsPerSecond(size, elapsed)
+	report.Sink = sink
+	return report, nil
+}
+
+//############# BEGIN BENCH HANDLERS
+
+// NetBenchSinkHandler reads and discards the request body, reporting the bytes received and the throughput.
+// it is the server side of /bench/net, called by the peer go-info-server
+func (s *GoHttpServer) NetBenchSinkHandler() http.HandlerFunc {
+	handlerName := "NetBenchSinkHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	hostname, _ := os.Hostname()
+	nodeName := info.LookupFirstEnv(os.LookupEnv, "MY_NODE_NAME", "NODE_NAME")
+	return func(w http.ResponseWriter, r *http.Request) {
+		// the error is ignored, when the connection does not support deadlines there is no timeout to extend
+		_ = http.NewResponseController(w).SetReadDeadline(time.Now().Add(maxLoadDuration))
+		start := time.Now()
+		n, err := io.Copy(io.Discard, io.LimitReader(r.Body, maxNetBenchBytes))
+		elapsed := time.Since(start)
+		if err != nil {
+			http.Error(w, "ERROR: reading the body failed : "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.render(w, r, http.StatusOK, NetBenchSinkReport{
+			Bytes:          n,
+			Seconds:        elapsed.Seconds(),
+			ThroughputMbps: megabitsPerSecond(n, elapsed),
+			Hostname:       hostname,
+			NodeName:       nodeName,
+		})
+	}
+}
+
+// NetBenchHandler measures the latency and the throughput between this pod and the peer parameter, the base url of
+// another go-info-server, by sending the pings parameter empty requests then the size parameter bytes (like 100M)
+func (s *GoHttpServer) NetBenchHandler(nb *NetBenchmark) http.HandlerFunc {
+	handlerName := "NetBenchHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("peer") == "" {
+			http.Error(w, "ERROR: a peer parameter with the url of another go-info-server is required", http.StatusBadRequest)
+			return
+		}
+		size := int64(defaultNetBenchBytes)
+		if val := query.Get("size"); val != "" {
+			var err error
+			size, err = parseByteSize(val)
+			if err != nil || size <= 0 || size > nb.maxBytes {
+				http.Error(w, fmt.Sprintf("ERROR: parameter size should be a size like 100M between 1 and %d bytes", nb.maxBytes), http.StatusBadRequest)
+				return
+			}
+		}
+		pings, err := parseIntParam(r, "pings", defaultNetBenchPings, 1, maxNetBenchPings)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		extendWriteDeadline(w, maxLoadDuration)
+		ctx, cancel := context.WithTimeout(r.Context(), maxLoadDuration)
+		defer cancel()
+		s.logger.Info("starting network benchmark", "handler", handlerName, "peer", query.Get("peer"), "size", size, "pings", pings)
+		report, err := nb.Run(ctx, query.Get("peer"), size, pings, r.Header.Get("Authorization"))
+		switch {
+		case errors.Is(err, errBenchBusy):
+			http.Error(w, "ERROR: "+err.Error(), http.StatusTooManyRequests)
+		case errors.Is(err, errConnectNotAllowed):
+			s.audit("network benchmark denied, peer not allowed", r, "peer", report.Peer)
+			http.Error(w, "ERROR: "+err.Error(), http.StatusForbidden)
+		case err != nil:
+			report.Error = err.Error()
+			s.render(w, r, http.StatusBadRequest, report)
+		default:
+			s.render(w, r, http.StatusOK, report)
+		}
+	}
+}
+
+// ############# END BENCH HANDLERS
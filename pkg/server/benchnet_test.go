@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepeatReader(t *testing.T) {
+	content, err := io.ReadAll(&repeatReader{block: []byte("abc"), remaining: 7})
+	assert.NoError(t, err)
+	assert.Equal(t, "abcabca", string(content))
+}
+
+func TestMegabitsPerSecond(t *testing.T) {
+	assert.Equal(t, 8.0, megabitsPerSecond(1e6, time.Second))
+	assert.Equal(t, 0.0, megabitsPerSecond(1e6, 0))
+}
+
+func TestNetBenchmarkRun(t *testing.T) {
+	t.Setenv("ENABLE_LOAD", "true")
+	t.Setenv("MY_NODE_NAME", "node-a")
+	peer := httptest.NewServer(NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger()).router)
+	defer peer.Close()
+	al, _ := ParseConnectAllowlist("127.0.0.1")
+	nb := NewNetBenchmark(NewConnector(al, nil), maxNetBenchBytes)
+
+	report, err := nb.Run(context.Background(), peer.URL, 1<<20, 3, "")
+	assert.NoError(t, err)
+	assert.True(t, report.Success, "error : %s", report.Error)
+	assert.Equal(t, "node-a", report.NodeName)
+	assert.Equal(t, 3, report.Latency.Count)
+	assert.Greater(t, report.ThroughputMbps, 0.0)
+	if assert.NotNil(t, report.Sink) {
+		assert.Equal(t, int64(1<<20), report.Sink.Bytes, "the peer should receive every byte")
+		assert.Equal(t, "node-a", report.Sink.NodeName)
+	}
+
+	report, err = nb.Run(context.Background(), "http://10.1.2.3:8080", 1<<20, 1, "")
+	assert.ErrorIs(t, err, errConnectNotAllowed)
+
+	nb.mu.Lock()
+	_, err = nb.Run(context.Background(), peer.URL, 1<<20, 1, "")
+	nb.mu.Unlock()
+	assert.ErrorIs(t, err, errBenchBusy, "only one benchmark should run at a time")
+}
+
+func TestGoHttpServerNetBenchHandler(t *testing.T) {
+	t.Setenv("ENABLE_LOAD", "true")
+	noLoad := httptest.NewServer(http.NotFoundHandler())
+	defer noLoad.Close()
+	peer := httptest.NewServer(NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger()).router)
+	defer peer.Close()
+	t.Setenv("CONNECT_ALLOWLIST", "127.0.0.1")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name           string
+		url            string
+		wantStatusCode int
+		wantSuccess    bool
+	}{
+		{name: "1: benchmark against a peer should succeed", url: "/bench/net?size=256K&pings=2&peer=" + peer.URL, wantStatusCode: http.StatusOK, wantSuccess: true},
+		{name: "2: peer without the sink should report the failure", url: "/bench/net?size=1K&peer=" + noLoad.URL, wantStatusCode: http.StatusOK},
+		{name: "3: missing peer should be a bad request", url: "/bench/net", wantStatusCode: http.StatusBadRequest},
+		{name: "4: peer outside the allowlist should be forbidden", url: "/bench/net?peer=http://10.1.2.3:8080", wantStatusCode: http.StatusForbidden},
+		{name: "5: too big size should be a bad request", url: "/bench/net?size=2G&peer=" + peer.URL, wantStatusCode: http.StatusBadRequest},
+		{name: "6: invalid pings should be a bad request", url: "/bench/net?pings=0&peer=" + peer.URL, wantStatusCode: http.StatusBadRequest},
+		{name: "7: unsupported scheme should be a bad request", url: "/bench/net?peer=ftp://127.0.0.1/", wantStatusCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode, assertCorrectStatusCodeExpected)
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+			var report NetBenchReport
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report), "the output should be a valid json")
+			assert.Equal(t, tt.wantSuccess, report.Success, "error : %s", report.Error)
+		})
+	}
+}
+
+func TestGoHttpServerNetBenchDisabled(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	assert.Nil(t, myServer.netBench, "/bench/net should be disabled without CONNECT_ALLOWLIST")
+}
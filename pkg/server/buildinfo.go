@@ -0,0 +1,20 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+//############# BEGIN INFO HANDLERS
+
+// BuildInfoHandler returns the version, git commit, build date and go version of the running binary
+func (s *GoHttpServer) BuildInfoHandler() http.HandlerFunc {
+	handlerName := "BuildInfoHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, r, http.StatusOK, info.GetBuildInfo())
+	}
+}
+
+// ############# END INFO HANDLERS
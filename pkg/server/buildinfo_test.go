@@ -0,0 +1,27 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoHttpServerBuildInfoHandler(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/buildinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var build info.BuildInfo
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&build), "the output should be a valid json")
+	assert.Equal(t, info.VERSION, build.Version)
+}
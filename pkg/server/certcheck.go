@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// CertCheckReport is the result of a tls handshake made by /certcheck with the certificate chain presented by the target
+type CertCheckReport struct {
+	Target          string               `json:"target"`
+	ServerName      string               `json:"server_name"` // SNI sent and name verified in the certificate
+	ResolvedIp      string               `json:"resolved_ip,omitempty"`
+	Success         bool                 `json:"success"` // true when the handshake succeeded, even with an invalid certificate
+	DurationMs      float64              `json:"duration_ms"`
+	Tls             *TlsConnInfo         `json:"tls,omitempty"`
+	Chain           []CertificateDetails `json:"chain,omitempty"`           // certificates as presented, the leaf first
+	ExpiresInDays   int                  `json:"expires_in_days,omitempty"` // days before the leaf certificate expires, negative when expired
+	Valid           bool                 `json:"valid"`                     // true when the chain is trusted by the CA bundle of the pod
+	ValidationError string               `json:"validation_error,omitempty"`
+	Error           string               `json:"error,omitempty"`
+}
+
+// verifyChain verifies chain, the leaf first, for serverName against roots or the CA bundle of the pod when roots is nil.
+// like the go clients it uses, the bundle can be changed with the SSL_CERT_FILE and SSL_CERT_DIR env variables
+func verifyChain(chain []*x509.Certificate, serverName string, roots *x509.CertPool) error {
+	if len(chain) == 0 {
+		return errors.New("no certificate presented")
+	}
+	if roots == nil {
+		var err error
+		if roots, err = x509.SystemCertPool(); err != nil {
+			return fmt.Errorf("loading the CA bundle of the pod: %w", err)
+		}
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{DNSName: serverName, Roots: roots, Intermediates: intermediates})
+	return err
+}
+
+// CheckCertificate makes a tls handshake with target given as host:port and verifies the presented chain.
+// serverName replaces the host as SNI and verified name when not empty, it is useful when the target is an ip
+func (c *Connector) CheckCertificate(ctx context.Context, target, serverName string) (CertCheckReport, error) {
+	report := CertCheckReport{Target: target}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return report, err
+	}
+	if serverName == "" {
+		serverName = host
+	}
+	report.ServerName = serverName
+	start := time.Now()
+	ip, err := c.resolve(ctx, host)
+	if errors.Is(err, errConnectNotAllowed) {
+		return report, err
+	}
+	if err != nil {
+		report.Error = err.Error()
+		return report, nil
+	}
+	report.ResolvedIp = ip.String()
+	// the chain is verified after the handshake, so that it is reported even when it is not trusted
+	d := tls.Dialer{Config: &tls.Config{ServerName: serverName, InsecureSkipVerify: true}}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(report.ResolvedIp, port))
+	report.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		report.Error = err.Error()
+		return report, nil
+	}
+	defer conn.Close()
+	cs := conn.(*tls.Conn).ConnectionState()
+	report.Success = true
+	report.Tls = NewTlsConnInfo(&cs)
+	for _, cert := range cs.PeerCertificates {
+		report.Chain = append(report.Chain, NewCertificateDetails(cert))
+	}
+	if len(cs.PeerCertificates) > 0 {
+		report.ExpiresInDays = int(time.Until(cs.PeerCertificates[0].NotAfter).Hours() / 24)
+	}
+	if err := verifyChain(cs.PeerCertificates, serverName, c.roots); err != nil {
+		report.ValidationError = err.Error()
+	} else {
+		report.Valid = true
+	}
+	return report, nil
+}
+
+//############# BEGIN CERTCHECK HANDLERS
+
+// CertCheckHandler makes a tls handshake from inside the pod with the host=host:port parameter and reports the
+// certificate chain and its validation, the servername parameter overrides the SNI and the timeout is a duration like 2s
+func (s *GoHttpServer) CertCheckHandler(connector *Connector) http.HandlerFunc {
+	handlerName := "CertCheckHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("host") == "" {
+			http.Error(w, "ERROR: a host=host:port parameter is required", http.StatusBadRequest)
+			return
+		}
+		timeout, err := parseTimeoutParam(r)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		report, err := connector.CheckCertificate(ctx, query.Get("host"), query.Get("servername"))
+		if errors.Is(err, errConnectNotAllowed) {
+			s.audit("certcheck denied, target not allowed", r, "target", report.Target)
+			http.Error(w, "ERROR: "+err.Error(), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			report.Error = err.Error()
+			s.render(w, r, http.StatusBadRequest, report)
+			return
+		}
+		s.render(w, r, http.StatusOK, report)
+	}
+}
+
+// ############# END CERTCHECK HANDLERS
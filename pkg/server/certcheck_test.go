@@ -0,0 +1,82 @@
+package server
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoHttpServerCertCheckHandler(t *testing.T) {
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(target.Certificate())
+
+	t.Setenv("CONNECT_ALLOWLIST", "127.0.0.1")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+	targetAddr := target.Listener.Addr().String()
+
+	tests := []struct {
+		name            string
+		query           string
+		roots           *x509.CertPool
+		wantStatusCode  int
+		wantSuccess     bool
+		wantValid       bool
+		wantValidateErr bool
+	}{
+		{name: "1: trusted certificate should be valid", query: "host=" + targetAddr, roots: roots, wantStatusCode: http.StatusOK, wantSuccess: true, wantValid: true},
+		{name: "2: certificate unknown to the CA bundle should be invalid", query: "host=" + targetAddr, wantStatusCode: http.StatusOK, wantSuccess: true, wantValidateErr: true},
+		{name: "3: wrong server name should be invalid", query: "host=" + targetAddr + "&servername=other.test", roots: roots, wantStatusCode: http.StatusOK, wantSuccess: true, wantValidateErr: true},
+		{name: "4: plain http target should report the handshake failure", query: "host=" + plain.Listener.Addr().String(), wantStatusCode: http.StatusOK},
+		{name: "5: target outside the allowlist should be forbidden", query: "host=10.1.2.3:443", wantStatusCode: http.StatusForbidden},
+		{name: "6: missing host should be a bad request", query: "", wantStatusCode: http.StatusBadRequest},
+		{name: "7: host without port should be a bad request", query: "host=127.0.0.1", wantStatusCode: http.StatusBadRequest},
+		{name: "8: invalid timeout should be a bad request", query: "host=" + targetAddr + "&timeout=1h", wantStatusCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myServer.connector.roots = tt.roots
+			resp, err := http.Get(ts.URL + "/certcheck?" + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode, assertCorrectStatusCodeExpected)
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+			var report CertCheckReport
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report), "the output should be a valid json")
+			assert.Equal(t, tt.wantSuccess, report.Success, "error : %s", report.Error)
+			assert.Equal(t, tt.wantValid, report.Valid, "validation error : %s", report.ValidationError)
+			assert.Equal(t, tt.wantValidateErr, report.ValidationError != "")
+			if tt.wantSuccess {
+				assert.Len(t, report.Chain, 1)
+				assert.Contains(t, report.Chain[0].IpAddresses, "127.0.0.1")
+				assert.Greater(t, report.ExpiresInDays, 0)
+			}
+		})
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	assert.Error(t, verifyChain(nil, "example.com", nil), "an empty chain should be an error")
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(target.Certificate())
+	chain := []*x509.Certificate{target.Certificate()}
+	assert.NoError(t, verifyChain(chain, "example.com", roots))
+	assert.NoError(t, verifyChain(chain, "127.0.0.1", roots))
+	assert.Error(t, verifyChain(chain, "other.test", roots))
+}
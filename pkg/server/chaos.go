@@ -0,0 +1,155 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultChaosErrorCode   = http.StatusInternalServerError
+	defaultChaosCrashDelay  = 1 * time.Second
+	maxChaosCrashDelay      = 5 * time.Minute
+	defaultChaosOomChunk    = 10 * 1024 * 1024 // bytes allocated at each step of /chaos/oom
+	defaultChaosOomInterval = 100 * time.Millisecond
+)
+
+// Chaos keeps the state of the failures injected by the /chaos endpoints
+type Chaos struct {
+	exit        func(code int) // os.Exit, replaced in tests
+	oomChunk    int
+	oomInterval time.Duration
+	mu          sync.Mutex
+	oomStarted  bool
+	allocated   [][]byte // memory kept by /chaos/oom so the garbage collector cannot free it
+}
+
+// NewChaos is a constructor for a Chaos calling exit to crash the process
+func NewChaos(exit func(code int)) *Chaos {
+	return &Chaos{exit: exit, oomChunk: defaultChaosOomChunk, oomInterval: defaultChaosOomInterval}
+}
+
+// Crash exits the process with code after delay, without any graceful shutdown
+func (c *Chaos) Crash(code int, delay time.Duration) {
+	time.AfterFunc(delay, func() { c.exit(code) })
+}
+
+// StartOom allocates memory forever until the process is killed, it returns false when it was already started
+func (c *Chaos) StartOom() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.oomStarted {
+		return false
+	}
+	c.oomStarted = true
+	go func() {
+		for {
+			chunk := make([]byte, c.oomChunk)
+			// touch every page so the memory is really committed and counted by the cgroup
+			for i := 0; i < len(chunk); i += os.Getpagesize() {
+				chunk[i] = 1
+			}
+			c.mu.Lock()
+			c.allocated = append(c.allocated, chunk)
+			c.mu.Unlock()
+			time.Sleep(c.oomInterval)
+		}
+	}()
+	return true
+}
+
+// AllocatedBytes returns the memory allocated by StartOom so far
+func (c *Chaos) AllocatedBytes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.allocated) * c.oomChunk
+}
+
+type chaosResponse struct {
+	Action  string `json:"action"`
+	Message string `json:"message"`
+}
+
+// parseIntParam returns the integer query parameter name or defaultValue, checking it is between min and max
+func parseIntParam(r *http.Request, name string, defaultValue, min, max int) (int, error) {
+	val := r.URL.Query().Get(name)
+	if val == "" {
+		return defaultValue, nil
+	}
+	i, err := strconv.Atoi(val)
+	if err != nil || i < min || i > max {
+		return 0, fmt.Errorf("parameter %s should be an integer between %d and %d", name, min, max)
+	}
+	return i, nil
+}
+
+//############# BEGIN CHAOS HANDLERS
+
+// ChaosErrorHandler answers with the http status given in the code parameter, 500 by default
+func (s *GoHttpServer) ChaosErrorHandler() http.HandlerFunc {
+	handlerName := "ChaosErrorHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		code, err := parseIntParam(r, "code", defaultChaosErrorCode, 400, 599)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.render(w, r, code, chaosResponse{Action: "error", Message: http.StatusText(code)})
+	}
+}
+
+// ChaosCrashHandler exits the process with the exit code parameter (1 by default) after the delay parameter (1s by default)
+func (s *GoHttpServer) ChaosCrashHandler(chaos *Chaos) http.HandlerFunc {
+	handlerName := "ChaosCrashHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		code, err := parseIntParam(r, "exit", 1, 0, 255)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		delay := defaultChaosCrashDelay
+		if val := r.URL.Query().Get("delay"); val != "" {
+			delay, err = time.ParseDuration(val)
+			if err != nil || delay < 0 || delay > maxChaosCrashDelay {
+				http.Error(w, fmt.Sprintf("ERROR: parameter delay should be a duration between 0 and %s", maxChaosCrashDelay), http.StatusBadRequest)
+				return
+			}
+		}
+		s.audit("chaos crash requested", r, "exit_code", code, "delay", delay.String())
+		s.lifecycleEvent(k8sEventTypeWarning, "ChaosCrash", fmt.Sprintf("/chaos/crash requested, exiting with code %d in %s", code, delay))
+		chaos.Crash(code, delay)
+		s.render(w, r, http.StatusAccepted, chaosResponse{Action: "crash", Message: fmt.Sprintf("exiting with code %d in %s", code, delay)})
+	}
+}
+
+// ChaosHangHandler never answers, the request is only released when the client gives up
+func (s *GoHttpServer) ChaosHangHandler() http.HandlerFunc {
+	handlerName := "ChaosHangHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.logger.Info("chaos hang, waiting for the client to disconnect", "handler", handlerName)
+		<-r.Context().Done()
+	}
+}
+
+// ChaosOomHandler starts allocating memory until the container is killed for exceeding its memory limit
+func (s *GoHttpServer) ChaosOomHandler(chaos *Chaos) http.HandlerFunc {
+	handlerName := "ChaosOomHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !chaos.StartOom() {
+			s.render(w, r, http.StatusConflict, chaosResponse{Action: "oom", Message: fmt.Sprintf("already allocating, %d bytes so far", chaos.AllocatedBytes())})
+			return
+		}
+		s.audit("chaos oom requested", r)
+		s.lifecycleEvent(k8sEventTypeWarning, "ChaosOom", "/chaos/oom requested, allocating memory until killed")
+		s.render(w, r, http.StatusAccepted, chaosResponse{Action: "oom", Message: "allocating memory until killed"})
+	}
+}
+
+// ############# END CHAOS HANDLERS
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoHttpServerChaosDisabled(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/chaos/crash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.NotEqual(t, http.StatusAccepted, resp.StatusCode, "chaos endpoints should not exist without ENABLE_CHAOS")
+}
+
+func TestGoHttpServerChaosHandlers(t *testing.T) {
+	t.Setenv("ENABLE_CHAOS", "true")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	exitCode := make(chan int, 1)
+	myServer.chaos.exit = func(code int) { exitCode <- code }
+	myServer.chaos.oomInterval = time.Hour
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name           string
+		url            string
+		wantStatusCode int
+	}{
+		{name: "1: error without code should return 500", url: "/chaos/error", wantStatusCode: http.StatusInternalServerError},
+		{name: "2: error with code should return this code", url: "/chaos/error?code=503", wantStatusCode: http.StatusServiceUnavailable},
+		{name: "3: error with a code outside 400-599 should be a bad request", url: "/chaos/error?code=200", wantStatusCode: http.StatusBadRequest},
+		{name: "4: crash with an invalid delay should be a bad request", url: "/chaos/crash?delay=forever", wantStatusCode: http.StatusBadRequest},
+		{name: "5: crash should be accepted", url: "/chaos/crash?delay=10ms&exit=3", wantStatusCode: http.StatusAccepted},
+		{name: "6: oom should be accepted", url: "/chaos/oom", wantStatusCode: http.StatusAccepted},
+		{name: "7: oom should only be started once", url: "/chaos/oom", wantStatusCode: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode, assertCorrectStatusCodeExpected)
+		})
+	}
+	select {
+	case code := <-exitCode:
+		assert.Equal(t, 3, code, "the requested exit code should be used")
+	case <-time.After(time.Second):
+		t.Error("crash should exit after the delay")
+	}
+	assert.Equal(t, defaultChaosOomChunk, myServer.chaos.AllocatedBytes())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/chaos/hang", nil)
+	_, err := http.DefaultClient.Do(req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "hang should never answer")
+}
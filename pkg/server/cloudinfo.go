@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCloudMetadataUrl   = "http://169.254.169.254" // link local address of the metadata services of all the clouds
+	defaultCloudProbeTimeout  = time.Second              // max time of one metadata service probe
+	maxCloudMetadataSize      = 1 << 20
+	cloudProviderNone         = "none"
+	cloudProviderAws          = "aws"
+	cloudProviderGcp          = "gcp"
+	cloudProviderAzure        = "azure"
+	cloudProviderOpenStack    = "openstack"
+	awsMetadataTokenTtlHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+)
+
+// CloudInfo tells where this server runs, as given by the instance metadata service of the cloud provider
+type CloudInfo struct {
+	Provider     string `json:"provider"` // aws, gcp, azure, openstack or none
+	Region       string `json:"region,omitempty"`
+	Zone         string `json:"zone,omitempty"`
+	InstanceType string `json:"instance_type,omitempty"`
+	InstanceId   string `json:"instance_id,omitempty"`
+}
+
+// errCloudMetadataNotFound is returned when the metadata service answers 404
+var errCloudMetadataNotFound = errors.New("not found in the metadata service")
+
+// gcpHeaders are needed by all the requests to the gcp metadata service
+var gcpHeaders = map[string]string{"Metadata-Flavor": "Google"}
+
+// cloudProbe asks the metadata service of one provider, it returns an error when it is not this provider
+type cloudProbe func(ctx context.Context, d *CloudDetector) (*CloudInfo, error)
+
+// CloudDetector probes the metadata services once and keeps the answer, the instance does not move while we run
+type CloudDetector struct {
+	baseUrl    string
+	httpClient *http.Client
+	probes     []cloudProbe // in order of preference when several answer
+	once       sync.Once
+	info       CloudInfo
+}
+
+// NewCloudDetector is a constructor for a CloudDetector asking the metadata services at baseUrl, each probe
+// being limited by timeout
+func NewCloudDetector(baseUrl string, timeout time.Duration) *CloudDetector {
+	return &CloudDetector{
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		httpClient: &http.Client{
+			// the metadata services must never be reached through an http proxy
+			Transport: &http.Transport{Proxy: nil},
+			Timeout:   timeout,
+		},
+		probes: []cloudProbe{probeAws, probeGcp, probeAzure, probeOpenStack},
+	}
+}
+
+// Detect returns the cloud of this instance, the probes run concurrently only on the first call. they do not use
+// the context of the request, a client going away must not leave none as the answer for all the next ones
+func (d *CloudDetector) Detect() CloudInfo {
+	d.once.Do(func() {
+		ctx := context.Background()
+		results := make([]*CloudInfo, len(d.probes))
+		var wg sync.WaitGroup
+		for i, probe := range d.probes {
+			wg.Add(1)
+			go func(i int, probe cloudProbe) {
+				defer wg.Done()
+				results[i], _ = probe(ctx, d)
+			}(i, probe)
+		}
+		wg.Wait()
+		d.info = CloudInfo{Provider: cloudProviderNone}
+		for _, info := range results {
+			if info != nil {
+				d.info = *info
+				break
+			}
+		}
+	})
+	return d.info
+}
+
+// get sends a request to the metadata service path and returns the body of a 200 answer
+func (d *CloudDetector) get(ctx context.Context, method, path string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, d.baseUrl+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, val := range headers {
+		req.Header.Set(name, val)
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s %s : %w", method, path, errCloudMetadataNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata service answered %s %s with status %d", method, path, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxCloudMetadataSize))
+}
+
+// getJson decodes the json answer of the metadata service path into result
+func (d *CloudDetector) getJson(ctx context.Context, path string, headers map[string]string, result interface{}) error {
+	body, err := d.get(ctx, http.MethodGet, path, headers)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, result)
+}
+
+// lastPathElement returns what follows the last / of a gcp resource name like projects/123/zones/europe-west6-a
+func lastPathElement(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// awsHeaders returns the headers of the IMDSv2 requests, with a session token obtained with a PUT
+func (d *CloudDetector) awsHeaders(ctx context.Context) (map[string]string, error) {
+	token, err := d.get(ctx, http.MethodPut, "/latest/api/token", map[string]string{awsMetadataTokenTtlHeader: "60"})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"X-aws-ec2-metadata-token": string(token)}, nil
+}
+
+// probeAws uses IMDSv2 to read the instance identity document
+func probeAws(ctx context.Context, d *CloudDetector) (*CloudInfo, error) {
+	headers, err := d.awsHeaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+		InstanceType     string `json:"instanceType"`
+		InstanceId       string `json:"instanceId"`
+	}
+	if err := d.getJson(ctx, "/latest/dynamic/instance-identity/document", headers, &doc); err != nil {
+		return nil, err
+	}
+	return &CloudInfo{Provider: cloudProviderAws, Region: doc.Region, Zone: doc.AvailabilityZone,
+		InstanceType: doc.InstanceType, InstanceId: doc.InstanceId}, nil
+}
+
+// probeGcp needs the Metadata-Flavor header, the zone and the machine type are full resource names
+func probeGcp(ctx context.Context, d *CloudDetector) (*CloudInfo, error) {
+	var instance struct {
+		Id          json.Number `json:"id"`
+		Zone        string      `json:"zone"`
+		MachineType string      `json:"machineType"`
+	}
+	if err := d.getJson(ctx, "/computeMetadata/v1/instance/?recursive=true", gcpHeaders, &instance); err != nil {
+		return nil, err
+	}
+	info := CloudInfo{Provider: cloudProviderGcp, Zone: lastPathElement(instance.Zone),
+		InstanceType: lastPathElement(instance.MachineType), InstanceId: instance.Id.String()}
+	// a zone like europe-west6-a is in the region europe-west6
+	if i := strings.LastIndex(info.Zone, "-"); i > 0 {
+		info.Region = info.Zone[:i]
+	}
+	return &info, nil
+}
+
+// probeAzure needs the Metadata header and an api-version
+func probeAzure(ctx context.Context, d *CloudDetector) (*CloudInfo, error) {
+	var compute struct {
+		Location string `json:"location"`
+		Zone     string `json:"zone"`
+		VmSize   string `json:"vmSize"`
+		VmId     string `json:"vmId"`
+	}
+	headers := map[string]string{"Metadata": "true"}
+	if err := d.getJson(ctx, "/metadata/instance/compute?api-version=2021-02-01", headers, &compute); err != nil {
+		return nil, err
+	}
+	return &CloudInfo{Provider: cloudProviderAzure, Region: compute.Location, Zone: compute.Zone,
+		InstanceType: compute.VmSize, InstanceId: compute.VmId}, nil
+}
+
+// probeOpenStack reads the openstack metadata, the flavor only comes from the ec2 compatible metadata
+func probeOpenStack(ctx context.Context, d *CloudDetector) (*CloudInfo, error) {
+	var meta struct {
+		Uuid             string `json:"uuid"`
+		AvailabilityZone string `json:"availability_zone"`
+	}
+	if err := d.getJson(ctx, "/openstack/latest/meta_data.json", nil, &meta); err != nil {
+		return nil, err
+	}
+	info := CloudInfo{Provider: cloudProviderOpenStack, Zone: meta.AvailabilityZone, InstanceId: meta.Uuid}
+	if flavor, err := d.get(ctx, http.MethodGet, "/latest/meta-data/instance-type", nil); err == nil {
+		info.InstanceType = strings.TrimSpace(string(flavor))
+	}
+	return &info, nil
+}
+
+// CloudInfoHandler returns the cloud provider, region, zone, instance type and id of the instance running this server
+func (s *GoHttpServer) CloudInfoHandler(detector *CloudDetector) http.HandlerFunc {
+	handlerName := "CloudInfoHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, r, http.StatusOK, detector.Detect())
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeMetadataService answers like the metadata service of provider, checking the headers each cloud requires
+func newFakeMetadataService(provider string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case provider == cloudProviderAws && r.Method == http.MethodPut && r.URL.Path == "/latest/api/token" && r.Header.Get(awsMetadataTokenTtlHeader) != "":
+			fmt.Fprint(w, "aws-session-token")
+		case provider == cloudProviderAws && r.URL.Path == "/latest/dynamic/instance-identity/document" && r.Header.Get("X-aws-ec2-metadata-token") == "aws-session-token":
+			fmt.Fprint(w, `{"region":"eu-central-2","availabilityZone":"eu-central-2a","instanceType":"m6i.large","instanceId":"i-0123456789abcdef0"}`)
+		case provider == cloudProviderGcp && r.URL.Path == "/computeMetadata/v1/instance/" && r.Header.Get("Metadata-Flavor") == "Google":
+			fmt.Fprint(w, `{"id":4567891234567891234,"zone":"projects/123456/zones/europe-west6-a","machineType":"projects/123456/machineTypes/e2-medium"}`)
+		case provider == cloudProviderAzure && r.URL.Path == "/metadata/instance/compute" && r.Header.Get("Metadata") == "true":
+			fmt.Fprint(w, `{"location":"switzerlandnorth","zone":"1","vmSize":"Standard_D2s_v3","vmId":"02aab8a4-74ef-476e-8182-f6d2ba4166a6"}`)
+		case provider == cloudProviderOpenStack && r.URL.Path == "/openstack/latest/meta_data.json":
+			fmt.Fprint(w, `{"uuid":"d8e02d56-2648-49a3-bf97-6be8f1204f38","availability_zone":"nova","name":"worker-1"}`)
+		case provider == cloudProviderOpenStack && r.URL.Path == "/latest/meta-data/instance-type":
+			fmt.Fprint(w, "m1.small")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestCloudDetectorDetect(t *testing.T) {
+	tests := []struct {
+		provider string
+		want     CloudInfo
+	}{
+		{provider: cloudProviderAws, want: CloudInfo{Provider: cloudProviderAws, Region: "eu-central-2", Zone: "eu-central-2a", InstanceType: "m6i.large", InstanceId: "i-0123456789abcdef0"}},
+		{provider: cloudProviderGcp, want: CloudInfo{Provider: cloudProviderGcp, Region: "europe-west6", Zone: "europe-west6-a", InstanceType: "e2-medium", InstanceId: "4567891234567891234"}},
+		{provider: cloudProviderAzure, want: CloudInfo{Provider: cloudProviderAzure, Region: "switzerlandnorth", Zone: "1", InstanceType: "Standard_D2s_v3", InstanceId: "02aab8a4-74ef-476e-8182-f6d2ba4166a6"}},
+		{provider: cloudProviderOpenStack, want: CloudInfo{Provider: cloudProviderOpenStack, Zone: "nova", InstanceType: "m1.small", InstanceId: "d8e02d56-2648-49a3-bf97-6be8f1204f38"}},
+		{provider: cloudProviderNone, want: CloudInfo{Provider: cloudProviderNone}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			ts := newFakeMetadataService(tt.provider)
+			defer ts.Close()
+			d := NewCloudDetector(ts.URL, time.Second)
+			assert.Equal(t, tt.want, d.Detect())
+			ts.Close()
+			assert.Equal(t, tt.want, d.Detect(), "the answer should be kept after the first detection")
+		})
+	}
+}
+
+func TestCloudDetectorUnreachable(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	url := ts.URL
+	ts.Close()
+	d := NewCloudDetector(url, 100*time.Millisecond)
+	assert.Equal(t, CloudInfo{Provider: cloudProviderNone}, d.Detect())
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	clusterDiscoveryDns       = config.ClusterDiscoveryDns
+	clusterDiscoveryEndpoints = config.ClusterDiscoveryEndpoints
+	defaultClusterPeerTimeout = 2 * time.Second
+	clusterMaxParallelPeers   = 16 // bounds the requests in flight, a deployment may have hundreds of replicas
+)
+
+// ClusterDiscovery tells how to find the sibling pods of this server
+type ClusterDiscovery struct {
+	Service string // name of the Service selecting the pods of the deployment
+	Mode    string // dns to resolve the headless Service, endpoints to read its Endpoints from the k8s api
+	Port    int    // port of the peers
+	Scheme  string // http or https
+}
+
+// GetClusterDiscoveryFromConfig returns how /cluster finds the peers based on the validated cluster_service,
+// cluster_discovery, cluster_port and cluster_scheme settings, the port of the server being used when cluster_port is 0.
+// it returns nil when cluster_service is empty
+func GetClusterDiscoveryFromConfig(settings config.Config) *ClusterDiscovery {
+	if settings.ClusterService == "" {
+		return nil
+	}
+	discovery := ClusterDiscovery{Service: settings.ClusterService, Mode: settings.ClusterDiscover, Port: settings.ClusterPort, Scheme: settings.ClusterScheme}
+	if discovery.Port == 0 {
+		discovery.Port = settings.Port
+	}
+	return &discovery
+}
+
+// ClusterPeer is what a sibling pod answered on /
+type ClusterPeer struct {
+	Address    string  `json:"address"`
+	Self       bool    `json:"self"` // true for the pod answering /cluster
+	Hostname   string  `json:"hostname,omitempty"`
+	PodName    string  `json:"pod_name,omitempty"`
+	NodeName   string  `json:"node_name,omitempty"`
+	Version    string  `json:"version,omitempty"`
+	Uptime     string  `json:"uptime,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// ClusterReport is the aggregated view of the pods of the deployment returned by /cluster
+type ClusterReport struct {
+	Service   string         `json:"service"`
+	Discovery string         `json:"discovery"`
+	Total     int            `json:"total"`
+	Reachable int            `json:"reachable"`
+	Versions  map[string]int `json:"versions"` // number of pods by version, more than one during a rollout
+	Nodes     map[string]int `json:"nodes"`    // number of pods by node, to check the spreading of the replicas
+	Peers     []ClusterPeer  `json:"peers"`
+}
+
+// ClusterInspector discovers the sibling pods and fans out to their / endpoint
+type ClusterInspector struct {
+	discovery ClusterDiscovery
+	resolver  *net.Resolver
+	k8s       *K8sClient // only used by the endpoints discovery
+	client    *http.Client
+}
+
+// NewClusterInspector is a constructor for a ClusterInspector, k8s may be nil with the dns discovery
+func NewClusterInspector(discovery ClusterDiscovery, resolver *net.Resolver, k8s *K8sClient) (*ClusterInspector, error) {
+	if discovery.Mode == clusterDiscoveryEndpoints && k8s == nil {
+		return nil, errors.New("the endpoints discovery needs the k8s api client")
+	}
+	return &ClusterInspector{
+		discovery: discovery,
+		resolver:  resolver,
+		k8s:       k8s,
+		client: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+// peerIps returns the ip of the ready pods behind the Service
+func (ci *ClusterInspector) peerIps(ctx context.Context) ([]string, error) {
+	var ips []string
+	if ci.discovery.Mode == clusterDiscoveryEndpoints {
+		endpoints, err := ci.k8s.clientset.CoreV1().Endpoints(ci.k8s.Namespace()).Get(ctx, ci.discovery.Service, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, subset := range endpoints.Subsets {
+			for _, addr := range subset.Addresses {
+				ips = append(ips, addr.IP)
+			}
+		}
+	} else {
+		addrs, err := ci.resolver.LookupIPAddr(ctx, ci.discovery.Service)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP.String())
+		}
+	}
+	sort.Strings(ips)
+	return ips, nil
+}
+
+// inspectPeer returns what the peer at address answered on /, the error is reported in the peer
+func (ci *ClusterInspector) inspectPeer(ctx context.Context, address, authorization string) (peer ClusterPeer) {
+	peer.Address = address
+	ctx, cancel := context.WithTimeout(ctx, defaultClusterPeerTimeout)
+	defer cancel()
+	start := time.Now()
+	defer func() {
+		peer.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	}()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s://%s/", ci.discovery.Scheme, address), nil)
+	if err != nil {
+		peer.Error = err.Error()
+		return peer
+	}
+	req.Header.Set("Accept", MIMEAppJSON)
+	req.Header.Set("User-Agent", fmt.Sprintf("%s/%s", info.APP, info.VERSION))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	InjectRequestId(ctx, req)
+	resp, err := ci.client.Do(req)
+	if err != nil {
+		peer.Error = err.Error()
+		return peer
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		peer.Error = "the peer answered " + resp.Status
+		return peer
+	}
+	var runtimeInfo info.RuntimeInfo
+	if err := json.NewDecoder(resp.Body).Decode(&runtimeInfo); err != nil {
+		peer.Error = "decoding the answer of the peer: " + err.Error()
+		return peer
+	}
+	peer.Hostname = runtimeInfo.Hostname
+	peer.Version = runtimeInfo.Version
+	peer.Uptime = runtimeInfo.Uptime
+	if runtimeInfo.K8s != nil {
+		peer.PodName = runtimeInfo.K8s.PodName
+		peer.NodeName = runtimeInfo.K8s.NodeName
+	}
+	return peer
+}
+
+// Inspect asks the / endpoint of every pod behind the Service, authorization is sent as is to the peers.
+// a peer that does not answer is reported with its error, an error is returned only when the discovery fails
+func (ci *ClusterInspector) Inspect(ctx context.Context, authorization string) (ClusterReport, error) {
+	report := ClusterReport{
+		Service:   ci.discovery.Service,
+		Discovery: ci.discovery.Mode,
+		Versions:  make(map[string]int),
+		Nodes:     make(map[string]int),
+		Peers:     []ClusterPeer{},
+	}
+	ips, err := ci.peerIps(ctx)
+	if err != nil {
+		return report, err
+	}
+	hostname, _ := os.Hostname()
+	report.Total = len(ips)
+	report.Peers = make([]ClusterPeer, len(ips))
+	sem := make(chan struct{}, clusterMaxParallelPeers)
+	var wg sync.WaitGroup
+	for i, ip := range ips {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			report.Peers[i] = ci.inspectPeer(ctx, net.JoinHostPort(ip, strconv.Itoa(ci.discovery.Port)), authorization)
+		}()
+	}
+	wg.Wait()
+	for i, peer := range report.Peers {
+		if peer.Error != "" {
+			continue
+		}
+		report.Reachable++
+		report.Versions[peer.Version]++
+		if peer.NodeName != "" {
+			report.Nodes[peer.NodeName]++
+		}
+		report.Peers[i].Self = peer.Hostname == hostname
+	}
+	return report, nil
+}
+
+//############# BEGIN CLUSTER HANDLERS
+
+// ClusterHandler returns the hostname, node, version and uptime of every pod of the deployment, as they answer on /
+func (s *GoHttpServer) ClusterHandler(ci *ClusterInspector) http.HandlerFunc {
+	handlerName := "ClusterHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		report, err := ci.Inspect(r.Context(), r.Header.Get("Authorization"))
+		var apiErr apierrors.APIStatus
+		if errors.As(err, &apiErr) {
+			s.k8sErrorResponse(w, handlerName, err)
+			return
+		}
+		if err != nil {
+			s.logger.Error("cluster peers discovery failed", "handler", handlerName, "service", ci.discovery.Service, "error", err)
+			http.Error(w, "ERROR: discovery of the peers failed : "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		s.render(w, r, http.StatusOK, report)
+	}
+}
+
+// ############# END CLUSTER HANDLERS
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetClusterDiscoveryFromConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(c *config.Config)
+		want      *ClusterDiscovery
+	}{
+		{name: "1: no service should disable /cluster", configure: func(c *config.Config) {}},
+		{name: "2: service alone should use dns on the port of the server", configure: func(c *config.Config) { c.ClusterService = "go-info-headless" },
+			want: &ClusterDiscovery{Service: "go-info-headless", Mode: "dns", Port: config.DefaultPort, Scheme: "http"}},
+		{name: "3: every setting should be used", configure: func(c *config.Config) {
+			c.ClusterService, c.ClusterDiscover, c.ClusterPort, c.ClusterScheme = "go-info", "endpoints", 9443, "https"
+		}, want: &ClusterDiscovery{Service: "go-info", Mode: "endpoints", Port: 9443, Scheme: "https"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.DefaultConfig()
+			tt.configure(&settings)
+			assert.Equal(t, tt.want, GetClusterDiscoveryFromConfig(settings))
+		})
+	}
+}
+
+// startClusterPeer serves the routes of a GoHttpServer on addr, returning false when addr cannot be listened
+func startClusterPeer(t *testing.T, addr string) bool {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return false
+	}
+	peer := httptest.NewUnstartedServer(NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger()).router)
+	peer.Listener = l
+	peer.Start()
+	t.Cleanup(peer.Close)
+	return true
+}
+
+func TestClusterInspectorInspect(t *testing.T) {
+	t.Setenv("MY_POD_NAME", "go-info-0")
+	t.Setenv("MY_NODE_NAME", "node-a")
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen : %v", err)
+	}
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	l.Close()
+	if !startClusterPeer(t, "127.0.0.1:"+port) || !startClusterPeer(t, "127.0.0.2:"+port) {
+		t.Skip("unable to listen on 127.0.0.1 and 127.0.0.2 with the same port")
+	}
+	api, k8sClient := newFakeK8sApi(t, map[string]string{
+		"/api/v1/namespaces/test-go-cloud-k8s-info/endpoints/go-info": `{"kind":"Endpoints","subsets":[{"addresses":[{"ip":"127.0.0.2"},{"ip":"127.0.0.3"},{"ip":"127.0.0.1"}]}]}`,
+	})
+	defer api.Close()
+	p, _ := strconv.Atoi(port)
+	ci, err := NewClusterInspector(ClusterDiscovery{Service: "go-info", Mode: "endpoints", Port: p, Scheme: "http"}, nil, k8sClient)
+	if err != nil {
+		t.Fatalf("NewClusterInspector() error = %v", err)
+	}
+
+	report, err := ci.Inspect(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Total)
+	assert.Equal(t, 2, report.Reachable, "nothing listens on 127.0.0.3")
+	assert.Equal(t, map[string]int{info.VERSION: 2}, report.Versions)
+	assert.Equal(t, map[string]int{"node-a": 2}, report.Nodes)
+	if assert.Len(t, report.Peers, 3) {
+		assert.Equal(t, "127.0.0.1:"+port, report.Peers[0].Address, "the peers should be sorted by ip")
+		assert.Equal(t, "go-info-0", report.Peers[0].PodName)
+		assert.True(t, report.Peers[0].Self, "the peers run in this process")
+		assert.NotEmpty(t, report.Peers[2].Error)
+	}
+
+	ci.discovery.Service = "missing"
+	_, err = ci.Inspect(context.Background(), "")
+	assert.Error(t, err, "a missing Service should be an error")
+
+	_, err = NewClusterInspector(ClusterDiscovery{Service: "go-info", Mode: "endpoints"}, nil, nil)
+	assert.Error(t, err, "the endpoints discovery should need the k8s api client")
+}
+
+func TestGoHttpServerClusterHandler(t *testing.T) {
+	t.Setenv("CLUSTER_SERVICE", "localhost")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var report ClusterReport
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report), "the output should be a valid json")
+	assert.Equal(t, "dns", report.Discovery)
+	assert.Greater(t, report.Total, 0, "localhost should resolve to at least one address")
+}
+
+func TestGoHttpServerClusterDisabled(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	assert.Nil(t, myServer.cluster, "/cluster should be disabled without CLUSTER_SERVICE")
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+)
+
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+// Compression decides which responses are compressed : the ones of at least minBytes with a compressible media type
+type Compression struct {
+	minBytes   int
+	mediaTypes []string // media types like application/json, or prefixes ending with / like text/
+}
+
+// NewCompression is a constructor for a Compression of the responses of at least minBytes having one of the media types
+// given as a comma separated list, where an entry ending with / like text/ matches all the subtypes
+func NewCompression(minBytes int, mediaTypes string) *Compression {
+	return &Compression{minBytes: minBytes, mediaTypes: config.SplitList(strings.ToLower(mediaTypes))}
+}
+
+// Compressible returns true when a response with this Content-Type header value may be compressed
+func (c *Compression) Compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	for _, t := range c.mediaTypes {
+		if mediaType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptedEncoding returns the content coding to use for an Accept-Encoding header, gzip being preferred to deflate,
+// or an empty string when the client accepts none of them
+func acceptedEncoding(acceptEncoding string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if key, val, found := strings.Cut(strings.TrimSpace(params), "="); found && strings.TrimSpace(key) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+				q = f
+			}
+		}
+		accepted[name] = q > 0
+	}
+	switch {
+	case accepted[encodingGzip]:
+		return encodingGzip
+	case accepted[encodingDeflate]:
+		return encodingDeflate
+	}
+	return ""
+}
+
+// compressResponseWriter keeps the beginning of the response until it knows if it is big enough to be compressed,
+// then sends it compressed or as is
+type compressResponseWriter struct {
+	http.ResponseWriter
+	compression *Compression
+	encoding    string
+	status      int
+	buf         []byte
+	decided     bool
+	disabled    bool           // the handler asked to send the response as is with noCompression
+	compressor  io.WriteCloser // nil when the response is sent as is
+}
+
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	w.status = code
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		// these responses have no body
+		w.decide()
+	}
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		return w.write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) < w.compression.minBytes && !w.disabled {
+		return len(b), nil
+	}
+	w.decide()
+	if err := w.writeBuffer(); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// decide sends the headers, with a Content-Encoding when the response is compressed
+func (w *compressResponseWriter) decide() {
+	w.decided = true
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	h.Add("Vary", "Accept-Encoding")
+	if !w.disabled && h.Get("Content-Encoding") == "" && len(w.buf) >= w.compression.minBytes && w.compression.Compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		if w.encoding == encodingGzip {
+			w.compressor = gzip.NewWriter(w.ResponseWriter)
+		} else {
+			w.compressor, _ = flate.NewWriter(w.ResponseWriter, flate.DefaultCompression)
+		}
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *compressResponseWriter) write(b []byte) (int, error) {
+	if w.compressor != nil {
+		return w.compressor.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressResponseWriter) writeBuffer() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// Flush sends what was written so far, a small response flushed early is not compressed
+func (w *compressResponseWriter) Flush() {
+	if !w.decided {
+		w.decide()
+		_ = w.writeBuffer()
+	}
+	if gz, ok := w.compressor.(*gzip.Writer); ok {
+		_ = gz.Flush()
+	} else if fl, ok := w.compressor.(*flate.Writer); ok {
+		_ = fl.Flush()
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Close sends the rest of the response and terminates the compressed stream
+func (w *compressResponseWriter) Close() error {
+	if !w.decided {
+		w.decide()
+	}
+	if err := w.writeBuffer(); err != nil {
+		return err
+	}
+	if w.compressor != nil {
+		return w.compressor.Close()
+	}
+	return nil
+}
+
+// Unwrap returns the original ResponseWriter, so http.ResponseController can reach its optional methods
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// noCompression tells the compress Middleware to send the response written to w as is, for the answers whose size
+// on the wire matters. it must be called before the first Write
+func noCompression(w http.ResponseWriter) {
+	for {
+		switch rw := w.(type) {
+		case *compressResponseWriter:
+			rw.disabled = true
+			return
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return
+		}
+	}
+}
+
+// compress is the Middleware compressing the responses in gzip or deflate for the clients accepting it
+func (s *GoHttpServer) compress() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+			// the connection of a protocol upgrade like websocket is taken over by the handler
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressResponseWriter{ResponseWriter: w, compression: s.compression, encoding: encoding}
+			defer cw.Close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
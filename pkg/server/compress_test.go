@@ -0,0 +1,103 @@
+package server
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: ""},
+		{header: "gzip", want: encodingGzip},
+		{header: "deflate, gzip;q=1.0, *;q=0.5", want: encodingGzip},
+		{header: "gzip;q=0, deflate", want: encodingDeflate},
+		{header: "br", want: ""},
+		{header: "identity", want: ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, acceptedEncoding(tt.header), "acceptedEncoding(%q)", tt.header)
+	}
+}
+
+func TestCompressionCompressible(t *testing.T) {
+	c := NewCompression(config.DefaultConfig().CompressMin, config.DefaultConfig().CompressTypes)
+	assert.True(t, c.Compressible(MIMEAppJSONCharsetUTF8))
+	assert.True(t, c.Compressible("text/html; charset=utf-8"))
+	assert.True(t, c.Compressible(MIMETextPlainPrometheus))
+	assert.False(t, c.Compressible("image/png"))
+	assert.False(t, c.Compressible("application/octet-stream"))
+	assert.False(t, c.Compressible(""))
+}
+
+func TestGoHttpServerCompress(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	myServer.compression = NewCompression(100, config.DefaultConfig().CompressTypes)
+	body := strings.Repeat("compress me ", 50)
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size := len(body)
+		if r.URL.Query().Get("small") == "true" {
+			size = 10
+		}
+		w.Header().Set(HeaderContentType, r.URL.Query().Get("type"))
+		if r.URL.Query().Get("raw") == "true" {
+			noCompression(w)
+		}
+		for i := 0; i < size; i += 20 {
+			io.WriteString(w, body[i:min(i+20, size)])
+		}
+	}), myServer.compress())
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tests := []struct {
+		name         string
+		query        string
+		encoding     string
+		wantEncoding string
+		wantBody     string
+	}{
+		{name: "1: text should be compressed in gzip", query: "type=text/plain", encoding: "gzip", wantEncoding: encodingGzip, wantBody: body},
+		{name: "2: json should be compressed in deflate", query: "type=application/json", encoding: "deflate", wantEncoding: encodingDeflate, wantBody: body},
+		{name: "3: small responses should not be compressed", query: "type=text/plain&small=true", encoding: "gzip", wantBody: body[:10]},
+		{name: "4: images should not be compressed", query: "type=image/png", encoding: "gzip", wantBody: body},
+		{name: "5: nothing should be compressed without Accept-Encoding", query: "type=text/plain", wantBody: body},
+		{name: "6: a handler calling noCompression should not be compressed", query: "type=text/plain&raw=true", encoding: "gzip", wantBody: body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+"/?"+tt.query, nil)
+			// an explicit Accept-Encoding disables the transparent decompression of the client
+			req.Header.Set("Accept-Encoding", tt.encoding)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantEncoding, resp.Header.Get("Content-Encoding"))
+			var reader io.Reader = resp.Body
+			switch tt.wantEncoding {
+			case encodingGzip:
+				reader, err = gzip.NewReader(resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+			case encodingDeflate:
+				reader = flate.NewReader(resp.Body)
+			}
+			received, err := io.ReadAll(reader)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantBody, string(received))
+		})
+	}
+}
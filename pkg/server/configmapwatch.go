@@ -0,0 +1,249 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConfigMapWatchInterval = 2 * time.Second // the kubelet syncs the volumes every minute or so, a finer poll dates the updates
+	defaultConfigMapEvents        = 100             // events kept for /k8s/configmap-events
+)
+
+// ConfigMapEvent is a change seen in a watched configMap volume
+type ConfigMapEvent struct {
+	Time             time.Time `json:"time"`
+	Path             string    `json:"path"`
+	Kind             string    `json:"kind"` // initial, updated, revision when only the ..data link changed, or error
+	Revision         string    `json:"revision,omitempty"`
+	PreviousRevision string    `json:"previous_revision,omitempty"`
+	Added            []string  `json:"added,omitempty"`
+	Removed          []string  `json:"removed,omitempty"`
+	Modified         []string  `json:"modified,omitempty"`
+	SinceLastSeconds float64   `json:"since_last_seconds,omitempty"` // time since the previous change of this path
+	Error            string    `json:"error,omitempty"`
+}
+
+// WatchedConfigMap is the current state of a watched path
+type WatchedConfigMap struct {
+	Path       string     `json:"path"`
+	Revision   string     `json:"revision,omitempty"` // directory of the current revision written by the kubelet
+	Files      int        `json:"files"`
+	SubPath    bool       `json:"sub_path"` // mounted with subPath, the kubelet never updates it
+	LastChange *time.Time `json:"last_change,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// ConfigMapEventsReport is the timeline of the changes of the watched configMap volumes
+type ConfigMapEventsReport struct {
+	IntervalSeconds float64            `json:"interval_seconds"`
+	Watched         []WatchedConfigMap `json:"watched"`
+	Events          []ConfigMapEvent   `json:"events"` // oldest first
+	Warnings        []string           `json:"warnings,omitempty"`
+}
+
+// configMapSnapshot is the content of a watched path at one poll
+type configMapSnapshot struct {
+	revision string
+	files    map[string]string // sha256 of each file by path relative to the watched directory
+}
+
+// snapshotConfigMap returns the revision and the hash of the files of path, a configMap directory or a single file
+func snapshotConfigMap(path string) (configMapSnapshot, error) {
+	snapshot := configMapSnapshot{files: map[string]string{}}
+	info, err := os.Stat(path)
+	if err != nil {
+		return snapshot, err
+	}
+	names := []string{""}
+	if info.IsDir() {
+		snapshot.revision = atomicWriterRevision(path)
+		files, err := listMountedFiles(path, false)
+		if err != nil {
+			return snapshot, err
+		}
+		names = names[:0]
+		for _, f := range files {
+			names = append(names, f.Path)
+		}
+	}
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(path, name))
+		if err != nil {
+			return snapshot, err
+		}
+		sum := sha256.Sum256(content)
+		if name == "" {
+			name = filepath.Base(path)
+		}
+		snapshot.files[name] = hex.EncodeToString(sum[:])
+	}
+	return snapshot, nil
+}
+
+// diffSnapshots returns the sorted files added, removed and modified from previous to current
+func diffSnapshots(previous, current configMapSnapshot) (added, removed, modified []string) {
+	for name, hash := range current.files {
+		old, exist := previous.files[name]
+		if !exist {
+			added = append(added, name)
+		} else if old != hash {
+			modified = append(modified, name)
+		}
+	}
+	for name := range previous.files {
+		if _, exist := current.files[name]; !exist {
+			removed = append(removed, name)
+		}
+	}
+	slices.Sort(added)
+	slices.Sort(removed)
+	slices.Sort(modified)
+	return added, removed, modified
+}
+
+// watchedState is what the ConfigMapWatcher remembers of a path between two polls
+type watchedState struct {
+	WatchedConfigMap
+	snapshot configMapSnapshot
+	polled   bool
+}
+
+// ConfigMapWatcher polls the configMap volumes mounted on some paths and records when the kubelet updates them,
+// to verify the sync delay of the kubelet or that a subPath mount never changes
+type ConfigMapWatcher struct {
+	mountInfoPath string
+	logger        *slog.Logger
+	maxEvents     int
+	now           func() time.Time // time.Now, replaced in tests
+	mu            sync.RWMutex
+	interval      time.Duration
+	watched       []*watchedState
+	events        []ConfigMapEvent
+}
+
+// NewConfigMapWatcher is a constructor for a ConfigMapWatcher of the paths, the subPath mounts are found in mountInfoPath
+func NewConfigMapWatcher(paths []string, mountInfoPath string, logger *slog.Logger) *ConfigMapWatcher {
+	w := &ConfigMapWatcher{mountInfoPath: mountInfoPath, logger: logger, maxEvents: defaultConfigMapEvents, now: time.Now}
+	for _, path := range paths {
+		w.watched = append(w.watched, &watchedState{WatchedConfigMap: WatchedConfigMap{Path: filepath.Clean(path)}})
+	}
+	return w
+}
+
+// record adds the event of state, it must be called with the lock held
+func (w *ConfigMapWatcher) record(state *watchedState, event ConfigMapEvent) {
+	event.Path = state.Path
+	if state.LastChange != nil && event.Kind != "error" {
+		event.SinceLastSeconds = event.Time.Sub(*state.LastChange).Seconds()
+	}
+	if event.Kind != "error" {
+		changed := event.Time
+		state.LastChange = &changed
+	}
+	w.events = append(w.events, event)
+	if len(w.events) > w.maxEvents {
+		w.events = w.events[len(w.events)-w.maxEvents:]
+	}
+}
+
+// Poll compares the watched paths with their previous content and records the changes
+func (w *ConfigMapWatcher) Poll() {
+	subPaths := map[string]bool{}
+	if mounts, err := ReadMountInfo(w.mountInfoPath); err == nil {
+		for _, m := range mounts {
+			subPaths[m.MountPoint] = m.VolumeKind == "subPath"
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, state := range w.watched {
+		now := w.now().UTC()
+		state.SubPath = subPaths[state.Path]
+		snapshot, err := snapshotConfigMap(state.Path)
+		if err != nil {
+			if state.Error != err.Error() {
+				state.Error = err.Error()
+				w.record(state, ConfigMapEvent{Time: now, Kind: "error", Error: state.Error})
+			}
+			continue
+		}
+		state.Error = ""
+		state.Revision, state.Files = snapshot.revision, len(snapshot.files)
+		if !state.polled {
+			state.polled, state.snapshot = true, snapshot
+			w.record(state, ConfigMapEvent{Time: now, Kind: "initial", Revision: snapshot.revision})
+			continue
+		}
+		added, removed, modified := diffSnapshots(state.snapshot, snapshot)
+		event := ConfigMapEvent{Time: now, Kind: "updated", Revision: snapshot.revision, PreviousRevision: state.snapshot.revision,
+			Added: added, Removed: removed, Modified: modified}
+		switch {
+		case len(added)+len(removed)+len(modified) > 0:
+			w.logger.Info("configMap updated", "path", state.Path, "revision", snapshot.revision, "added", added, "removed", removed, "modified", modified)
+		case snapshot.revision != state.snapshot.revision:
+			event.Kind = "revision"
+		default:
+			continue
+		}
+		state.snapshot = snapshot
+		w.record(state, event)
+	}
+}
+
+// Report returns the state of the watched paths and the timeline of their changes
+func (w *ConfigMapWatcher) Report() ConfigMapEventsReport {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	report := ConfigMapEventsReport{IntervalSeconds: w.interval.Seconds(), Watched: []WatchedConfigMap{}, Events: slices.Clone(w.events)}
+	if report.Events == nil {
+		report.Events = []ConfigMapEvent{}
+	}
+	for _, state := range w.watched {
+		report.Watched = append(report.Watched, state.WatchedConfigMap)
+		if state.SubPath {
+			report.Warnings = append(report.Warnings, state.Path+" is mounted with subPath, the kubelet never updates it, restart the pod to see the changes")
+		}
+	}
+	return report
+}
+
+// Watch polls the watched paths every interval until ctx is done
+func (w *ConfigMapWatcher) Watch(ctx context.Context, interval time.Duration) {
+	w.mu.Lock()
+	w.interval = interval
+	w.mu.Unlock()
+	w.logger.Info("Watching the configMap volumes", "paths", len(w.watched), "interval", interval.String())
+	w.Poll()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Poll()
+		}
+	}
+}
+
+//############# BEGIN K8S HANDLERS
+
+// ConfigMapEventsHandler returns the timeline of the updates of the configMap volumes of CONFIGMAP_WATCH
+func (s *GoHttpServer) ConfigMapEventsHandler(watcher *ConfigMapWatcher) http.HandlerFunc {
+	handlerName := "ConfigMapEventsHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, r, http.StatusOK, watcher.Report())
+	}
+}
+
+// ############# END K8S HANDLERS
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// updateAtomicVolume switches the ..data link of dir to a new revision with files, like the kubelet does
+func updateAtomicVolume(t *testing.T, dir string, revision string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, revision), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, revision, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); err != nil {
+			if err := os.Symlink(filepath.Join(atomicWriterDataDir, name), link); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	tmpLink := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(revision, tmpLink); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmpLink, filepath.Join(dir, atomicWriterDataDir)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigMapWatcherPoll(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "settings")
+	subPathFile := filepath.Join(root, "app.conf")
+	writeAtomicVolume(t, dir, map[string]string{"level": "info", "color": "blue"})
+	if err := os.WriteFile(subPathFile, []byte("a=1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mountInfo := filepath.Join(root, "mountinfo")
+	line := fmt.Sprintf("28 22 8:1 /var/lib/kubelet/pods/0a1b/volume-subpaths/config/app/0 %s ro,relatime - ext4 /dev/sda1 rw\n", subPathFile)
+	if err := os.WriteFile(mountInfo, []byte(line), 0600); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	watcher := NewConfigMapWatcher([]string{dir, subPathFile, filepath.Join(root, "missing")}, mountInfo, getTestLogger())
+	watcher.now = func() time.Time { return now }
+
+	watcher.Poll()
+	now = now.Add(time.Minute)
+	watcher.Poll()
+	report := watcher.Report()
+	if !assert.Len(t, report.Events, 3, "a poll without change should not add events") {
+		return
+	}
+	assert.Equal(t, "initial", report.Events[0].Kind)
+	assert.Equal(t, "error", report.Events[2].Kind, "a missing path should give one error event")
+	assert.Equal(t, 2, report.Watched[0].Files)
+	assert.True(t, report.Watched[1].SubPath)
+	assert.Len(t, report.Warnings, 1, "the subPath mount should be warned")
+
+	updateAtomicVolume(t, dir, "..2024_03_05_12_01_10.000000002", map[string]string{"level": "debug", "color": "blue", "size": "big"})
+	now = now.Add(10 * time.Second)
+	watcher.Poll()
+	report = watcher.Report()
+	if !assert.Len(t, report.Events, 4) {
+		return
+	}
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{name: "1: the update should be an updated event", got: report.Events[3].Kind, want: "updated"},
+		{name: "2: the new file should be added", got: report.Events[3].Added, want: []string{"size"}},
+		{name: "3: the changed file should be modified", got: report.Events[3].Modified, want: []string{"level"}},
+		{name: "4: the revision should follow ..data", got: report.Events[3].Revision, want: "..2024_03_05_12_01_10.000000002"},
+		{name: "5: the delay since the previous change should be given", got: report.Events[3].SinceLastSeconds, want: 70.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.got)
+		})
+	}
+
+	updateAtomicVolume(t, dir, "..2024_03_05_12_02_10.000000003", map[string]string{"level": "debug", "color": "blue", "size": "big"})
+	watcher.Poll()
+	report = watcher.Report()
+	assert.Equal(t, "revision", report.Events[len(report.Events)-1].Kind, "a new revision with the same files should be a revision event")
+}
+
+func TestGoHttpServerConfigMapEventsHandler(t *testing.T) {
+	dir := t.TempDir()
+	writeAtomicVolume(t, dir, map[string]string{"level": "info"})
+	t.Setenv("CONFIGMAP_WATCH", dir)
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	myServer.configMaps.Poll()
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/k8s/configmap-events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var report ConfigMapEventsReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("the output should be a valid json : %v", err)
+	}
+	assert.Len(t, report.Events, 1)
+}
@@ -0,0 +1,280 @@
+package server
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+const (
+	defaultConnectTimeout = 2 * time.Second
+	maxConnectTimeout     = 10 * time.Second
+)
+
+var errConnectNotAllowed = errors.New("target is not in CONNECT_ALLOWLIST")
+
+// ConnectAllowlist restricts the targets /connect, /certcheck, /proxy and /bench/net may reach, so it does not become an open proxy
+type ConnectAllowlist struct {
+	hosts    map[string]bool // exact host names or ip
+	suffixes []string        // domain suffixes given as *.example.com, stored as .example.com
+	nets     []*net.IPNet    // CIDR ranges checked against the resolved ip
+}
+
+// ParseConnectAllowlist parses a comma separated list of host names, *.domain wildcards, ip addresses or CIDR ranges
+func ParseConnectAllowlist(list string) (*ConnectAllowlist, error) {
+	al := ConnectAllowlist{hosts: make(map[string]bool)}
+	for _, entry := range config.SplitList(strings.ToLower(list)) {
+		switch {
+		case strings.HasPrefix(entry, "*."):
+			al.suffixes = append(al.suffixes, entry[1:])
+		case strings.Contains(entry, "/"):
+			_, ipNet, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, err
+			}
+			al.nets = append(al.nets, ipNet)
+		default:
+			al.hosts[entry] = true
+		}
+	}
+	return &al, nil
+}
+
+// Empty returns true when nothing is allowed
+func (al *ConnectAllowlist) Empty() bool {
+	return len(al.hosts) == 0 && len(al.suffixes) == 0 && len(al.nets) == 0
+}
+
+// Allowed returns true when the host name or its resolved ip matches an entry of the allowlist
+func (al *ConnectAllowlist) Allowed(host string, ip net.IP) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if al.hosts[host] || al.hosts[ip.String()] {
+		return true
+	}
+	for _, suffix := range al.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	for _, ipNet := range al.nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetConnectAllowlistFromConfig returns the targets allowed for /connect given by the connect_allowlist setting, a comma
+// separated list like db.default.svc.cluster.local,*.example.com,10.0.0.0/8 (/connect, /certcheck, /proxy and /bench/net
+// are disabled when it is empty)
+func GetConnectAllowlistFromConfig(settings config.Config) (*ConnectAllowlist, error) {
+	al, err := ParseConnectAllowlist(settings.ConnectAllow)
+	if err != nil {
+		return &ConnectAllowlist{}, &config.ErrorConfig{
+			Err: err,
+			Msg: "ERROR: CONFIG connect_allowlist (env CONNECT_ALLOWLIST) should contain host names, *.domain, ip addresses or CIDR ranges",
+		}
+	}
+	return al, nil
+}
+
+// ConnectReport is the result of an outbound connection attempt made by /connect
+type ConnectReport struct {
+	Target     string       `json:"target"`
+	Protocol   string       `json:"protocol"` // tcp or http
+	ResolvedIp string       `json:"resolved_ip,omitempty"`
+	Success    bool         `json:"success"`
+	DurationMs float64      `json:"duration_ms"` // time to connect for tcp, to receive the response headers for http
+	HttpStatus int          `json:"http_status,omitempty"`
+	Tls        *TlsConnInfo `json:"tls,omitempty"`
+	Error      string       `json:"error,omitempty"`
+}
+
+// Connector makes the outbound connections of /connect, /certcheck and /proxy to the allowed targets only
+type Connector struct {
+	allowlist *ConnectAllowlist
+	resolver  *net.Resolver
+	roots     *x509.CertPool // CAs trusted by /certcheck, nil for the CA bundle of the pod
+}
+
+// NewConnector is a constructor for a Connector using the given allowlist and resolver
+func NewConnector(allowlist *ConnectAllowlist, resolver *net.Resolver) *Connector {
+	return &Connector{allowlist: allowlist, resolver: resolver}
+}
+
+// resolve returns the first ip of host, or errConnectNotAllowed when neither the host nor this ip is allowed.
+// the connection must then be made to this ip, so that a dns change cannot redirect it to a forbidden address
+func (c *Connector) resolve(ctx context.Context, host string) (net.IP, error) {
+	if len(c.allowlist.nets) == 0 && !c.allowlist.Allowed(host, nil) {
+		// without CIDR ranges the host name alone decides, so forbidden names are not even resolved
+		return nil, errConnectNotAllowed
+	}
+	addrs, err := c.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address found for %s", host)
+	}
+	if !c.allowlist.Allowed(host, addrs[0].IP) {
+		return addrs[0].IP, errConnectNotAllowed
+	}
+	return addrs[0].IP, nil
+}
+
+// DialTcp opens then closes a tcp connection to target given as host:port
+func (c *Connector) DialTcp(ctx context.Context, target string) (ConnectReport, error) {
+	report := ConnectReport{Target: target, Protocol: "tcp"}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return report, err
+	}
+	start := time.Now()
+	ip, err := c.resolve(ctx, host)
+	if errors.Is(err, errConnectNotAllowed) {
+		return report, err
+	}
+	if err != nil {
+		report.Error = err.Error()
+		return report, nil
+	}
+	report.ResolvedIp = ip.String()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(report.ResolvedIp, port))
+	report.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		report.Error = err.Error()
+		return report, nil
+	}
+	conn.Close()
+	report.Success = true
+	return report, nil
+}
+
+// pinnedHttpClient returns a client connecting to ip whatever the host of the url, without keep-alive nor redirects
+func pinnedHttpClient(ip string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				_, port, err := net.SplitHostPort(addr)
+				if err != nil {
+					return nil, err
+				}
+				var d net.Dialer
+				return d.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// HttpGet sends a GET to target given as an http or https url, redirects are not followed
+func (c *Connector) HttpGet(ctx context.Context, target string) (ConnectReport, error) {
+	report := ConnectReport{Target: target, Protocol: "http"}
+	u, err := url.Parse(target)
+	if err != nil {
+		return report, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return report, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	start := time.Now()
+	ip, err := c.resolve(ctx, u.Hostname())
+	if errors.Is(err, errConnectNotAllowed) {
+		return report, err
+	}
+	if err != nil {
+		report.Error = err.Error()
+		return report, nil
+	}
+	report.ResolvedIp = ip.String()
+	client := pinnedHttpClient(report.ResolvedIp)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return report, err
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("%s/%s", info.APP, info.VERSION))
+	InjectTraceContext(ctx, req)
+	InjectRequestId(ctx, req)
+	resp, err := client.Do(req)
+	report.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		report.Error = err.Error()
+		return report, nil
+	}
+	resp.Body.Close()
+	report.Success = true
+	report.HttpStatus = resp.StatusCode
+	if resp.TLS != nil {
+		report.Tls = NewTlsConnInfo(resp.TLS)
+	}
+	return report, nil
+}
+
+// parseTimeoutParam returns the duration of the timeout parameter like 2s, defaultConnectTimeout when it is not given,
+// checking it is positive and at most maxConnectTimeout
+func parseTimeoutParam(r *http.Request) (time.Duration, error) {
+	val := r.URL.Query().Get("timeout")
+	if val == "" {
+		return defaultConnectTimeout, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d <= 0 || d > maxConnectTimeout {
+		return 0, fmt.Errorf("the timeout parameter should be a duration between 0 and %s", maxConnectTimeout)
+	}
+	return d, nil
+}
+
+//############# BEGIN CONNECT HANDLERS
+
+// ConnectHandler tries an outbound connection from inside the pod, either a tcp dial to the target=host:port parameter
+// or an http GET to the url parameter, the timeout parameter is a duration like 2s
+func (s *GoHttpServer) ConnectHandler(connector *Connector) http.HandlerFunc {
+	handlerName := "ConnectHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		timeout, err := parseTimeoutParam(r)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		var report ConnectReport
+		switch {
+		case query.Get("target") != "":
+			report, err = connector.DialTcp(ctx, query.Get("target"))
+		case query.Get("url") != "":
+			report, err = connector.HttpGet(ctx, query.Get("url"))
+		default:
+			http.Error(w, "ERROR: a target=host:port or an url parameter is required", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, errConnectNotAllowed) {
+			s.audit("connect denied, target not allowed", r, "target", report.Target)
+			http.Error(w, "ERROR: "+err.Error(), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			report.Error = err.Error()
+			s.render(w, r, http.StatusBadRequest, report)
+			return
+		}
+		s.render(w, r, http.StatusOK, report)
+	}
+}
+
+// ############# END CONNECT HANDLERS
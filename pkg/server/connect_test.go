@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectAllowlist(t *testing.T) {
+	al, err := ParseConnectAllowlist("db.default.svc.cluster.local, *.Example.com,10.0.0.0/8,192.168.1.1")
+	assert.NoError(t, err)
+	tests := []struct {
+		name string
+		host string
+		ip   string
+		want bool
+	}{
+		{name: "1: exact host should be allowed", host: "db.default.svc.cluster.local", ip: "172.16.0.1", want: true},
+		{name: "2: sub domain of a wildcard should be allowed", host: "api.example.com.", ip: "172.16.0.1", want: true},
+		{name: "3: the wildcard domain itself should not be allowed", host: "example.com", ip: "172.16.0.1", want: false},
+		{name: "4: ip in a CIDR range should be allowed", host: "anything.local", ip: "10.1.2.3", want: true},
+		{name: "5: exact ip should be allowed", host: "192.168.1.1", ip: "192.168.1.1", want: true},
+		{name: "6: other host should be refused", host: "evil.com", ip: "172.16.0.1", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, al.Allowed(tt.host, net.ParseIP(tt.ip)))
+		})
+	}
+	_, err = ParseConnectAllowlist("10.0.0.0/33")
+	assert.Error(t, err, "an invalid CIDR should be an error")
+	al, _ = ParseConnectAllowlist(" , ")
+	assert.True(t, al.Empty())
+}
+
+func TestParseTimeoutParam(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "1: no timeout should be the default", want: defaultConnectTimeout},
+		{name: "2: a duration should be accepted", query: "?timeout=500ms", want: 500 * time.Millisecond},
+		{name: "3: the maximum should be accepted", query: "?timeout=10s", want: maxConnectTimeout},
+		{name: "4: above the maximum should be refused", query: "?timeout=11s", wantErr: true},
+		{name: "5: zero should be refused", query: "?timeout=0s", wantErr: true},
+		{name: "6: a number without unit should be refused", query: "?timeout=5", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTimeoutParam(httptest.NewRequest(http.MethodGet, "/connect"+tt.query, nil))
+			if tt.wantErr {
+				assert.EqualError(t, err, "the timeout parameter should be a duration between 0 and 10s")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGoHttpServerConnectHandler(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer target.Close()
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	t.Setenv("CONNECT_ALLOWLIST", "127.0.0.1")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name           string
+		query          string
+		wantStatusCode int
+		wantSuccess    bool
+		wantHttpStatus int
+	}{
+		{name: "1: tcp dial to an allowed target should succeed", query: "target=" + target.Listener.Addr().String(), wantStatusCode: http.StatusOK, wantSuccess: true},
+		{name: "2: http get to an allowed url should report the status", query: "url=" + target.URL + "/x", wantStatusCode: http.StatusOK, wantSuccess: true, wantHttpStatus: http.StatusTeapot},
+		{name: "3: tcp dial to a closed port should report the failure", query: "target=" + closedAddr, wantStatusCode: http.StatusOK},
+		{name: "4: target outside the allowlist should be forbidden", query: "target=10.1.2.3:80", wantStatusCode: http.StatusForbidden},
+		{name: "5: missing target should be a bad request", query: "", wantStatusCode: http.StatusBadRequest},
+		{name: "6: invalid timeout should be a bad request", query: "target=127.0.0.1:80&timeout=1h", wantStatusCode: http.StatusBadRequest},
+		{name: "7: unsupported scheme should be a bad request", query: "url=ftp://127.0.0.1/", wantStatusCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + "/connect?" + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode, assertCorrectStatusCodeExpected)
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+			var report ConnectReport
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report), "the output should be a valid json")
+			assert.Equal(t, tt.wantSuccess, report.Success, "error : %s", report.Error)
+			assert.Equal(t, tt.wantHttpStatus, report.HttpStatus)
+			assert.Equal(t, "127.0.0.1", report.ResolvedIp)
+		})
+	}
+}
+
+func TestGoHttpServerConnectDisabled(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	assert.Nil(t, myServer.connector, "/connect should be disabled without CONNECT_ALLOWLIST")
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"sort"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+// dashboardTemplate renders a RuntimeInfo for humans, reusing the skeleton.css header of the other html pages
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"sortedKeys": func(m map[string][]string) []string {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	},
+}).Parse(`{{.HeaderStart}}<title>{{.Info.Appname}} on {{.Info.Hostname}}</title>
+<style>td{word-break:break-all}h5{margin-top:2rem}.banner{padding:1rem;border-left:4px solid #33c3f0;background:#f4f4f4;white-space:pre-wrap}</style></head>
+<body><div class="container">
+{{with .Info}}
+<h3>{{.Appname}} <small>v{{.Version}}</small></h3>
+{{if .Message}}<p class="banner"><strong>{{.Message}}</strong></p>{{end}}
+{{if .Banner}}<pre class="banner">{{.Banner}}</pre>{{end}}
+<div class="row">
+<div class="six columns">
+<h5>Server</h5>
+<table class="u-full-width"><tbody>
+<tr><th>hostname</th><td>{{.Hostname}}</td></tr>
+<tr><th>pid / ppid / uid</th><td>{{.Pid}} / {{.PPid}} / {{.Uid}}</td></tr>
+<tr><th>uptime</th><td>{{.Uptime}}</td></tr>
+<tr><th>os uptime</th><td>{{.UptimeOs}}</td></tr>
+<tr><th>os release</th><td>{{.OsReleaseName}} {{.OsReleaseVersion}}</td></tr>
+<tr><th>go runtime</th><td>{{.Runtime}} {{.GOOS}}/{{.GOARCH}}</td></tr>
+<tr><th>cpu / gomaxprocs</th><td>{{.NumCPU}} / {{.GoMaxProcs}}{{with .MaxProcs}} (from {{.Source}}{{if gt .CpuQuota 0.0}}, quota {{.CpuQuota}} cpu{{end}}){{end}}</td></tr>
+<tr><th>goroutines</th><td>{{.NumGoroutine}}</td></tr>
+</tbody></table>
+</div>
+<div class="six columns">
+<h5>Request</h5>
+<table class="u-full-width"><tbody>
+<tr><th>request id</th><td>{{.RequestId}}</td></tr>
+<tr><th>remote ip</th><td>{{.RemoteIp}} (IPv{{.RemoteIpVersion}}){{if .RemotePtr}} {{.RemotePtr}}{{end}}</td></tr>
+<tr><th>remote port</th><td>{{.RemotePort}}</td></tr>
+<tr><th>name parameter</th><td>{{.ParamName}}</td></tr>
+</tbody></table>
+</div>
+</div>
+{{if .K8s}}{{with .K8s}}
+<h5>Kubernetes</h5>
+<table class="u-full-width"><tbody>
+<tr><th>pod</th><td>{{.PodNamespace}}/{{.PodName}}</td></tr>
+<tr><th>pod ip</th><td>{{.PodIp}}</td></tr>
+<tr><th>node</th><td>{{.NodeName}}</td></tr>
+<tr><th>service account</th><td>{{.ServiceAccount}}</td></tr>
+<tr><th>cpu request / limit</th><td>{{.CpuRequest}} / {{.CpuLimit}}</td></tr>
+<tr><th>memory request / limit</th><td>{{.MemoryRequest}} / {{.MemoryLimit}}</td></tr>
+</tbody></table>
+{{end}}{{end}}
+{{if .K8sApiUrl}}<p>k8s api {{.K8sApiUrl}} version {{.K8sVersion}}, namespace {{.K8sCurrentNamespace}}</p>{{end}}
+{{if .Cgroup}}{{with .Cgroup}}
+<h5>cgroup v{{.Version}} limits</h5>
+<table class="u-full-width"><tbody>
+<tr><th>cpu limit</th><td>{{if gt .CpuLimit 0.0}}{{.CpuLimit}}{{else}}unlimited{{end}}</td></tr>
+<tr><th>memory limit (bytes)</th><td>{{if gt .MemoryLimitBytes 0}}{{.MemoryLimitBytes}}{{else}}unlimited{{end}}</td></tr>
+</tbody></table>
+{{end}}{{end}}
+<h5>Headers</h5>
+<table class="u-full-width"><tbody>
+{{range $k := sortedKeys .Headers}}<tr><th>{{$k}}</th><td>{{range index $.Info.Headers $k}}{{.}} {{end}}</td></tr>
+{{end}}</tbody></table>
+<h5>Environment</h5>
+<table class="u-full-width"><tbody>
+{{range .EnvVars}}<tr><td>{{.}}</td></tr>
+{{end}}</tbody></table>
+{{end}}
+</div></body></html>
+`))
+
+// renderDashboard writes the RuntimeInfo as an html page
+func (s *GoHttpServer) renderDashboard(w http.ResponseWriter, runtimeInfo info.RuntimeInfo) {
+	var page bytes.Buffer
+	err := dashboardTemplate.Execute(&page, struct {
+		HeaderStart template.HTML
+		Info        info.RuntimeInfo
+	}{HeaderStart: template.HTML(htmlHeaderStart), Info: runtimeInfo})
+	if err != nil {
+		s.logger.Error("dashboard template failed", "error", err)
+		http.Error(w, "Internal server error. unable to render the dashboard", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(HeaderContentType, MIMETextHTMLCharsetUTF8)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	w.Write(page.Bytes())
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoHttpServerDashboard(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantBody        []string
+		wantNotInBody   []string
+	}{
+		{name: "1: browser should get the html dashboard", accept: "text/html,application/xhtml+xml,*/*;q=0.8",
+			wantContentType: MIMETextHTMLCharsetUTF8,
+			wantBody:        []string{"skeleton.min.css", "<h3>" + info.APP, "&lt;script&gt;"},
+			wantNotInBody:   []string{"<script>alert"}},
+		{name: "2: json client should get json", accept: "application/json",
+			wantContentType: MIMEAppJSONCharsetUTF8, wantBody: []string{`"appname":"` + info.APP + `"`}},
+		{name: "3: no preference should get json", accept: "*/*",
+			wantContentType: MIMEAppJSONCharsetUTF8, wantBody: []string{`"appname":"` + info.APP + `"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+"/", nil)
+			req.Header.Set("Accept", tt.accept)
+			req.Header.Set("X-Evil", "<script>alert(1)</script>")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+			assert.Equal(t, tt.wantContentType, resp.Header.Get(HeaderContentType))
+			body, _ := io.ReadAll(resp.Body)
+			for _, want := range tt.wantBody {
+				assert.Contains(t, string(body), want)
+			}
+			for _, notWant := range tt.wantNotInBody {
+				assert.NotContains(t, string(body), notWant, "received headers should be escaped")
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+)
+
+const (
+	defaultDnsLookupTimeout = 5 * time.Second
+	dnsTypeA                = "A"
+	dnsTypeAAAA             = "AAAA"
+	dnsTypeCNAME            = "CNAME"
+	dnsTypeSRV              = "SRV"
+)
+
+var dnsAllTypes = []string{dnsTypeA, dnsTypeAAAA, dnsTypeCNAME, dnsTypeSRV}
+
+// DnsLookup is the result of one lookup of a given record type
+type DnsLookup struct {
+	Type       string   `json:"type"`
+	Records    []string `json:"records"`
+	DurationMs float64  `json:"duration_ms"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// DnsReport contains all the lookups done for one host by /dns
+type DnsReport struct {
+	Host     string      `json:"host"`
+	Resolver string      `json:"resolver"` // address of the dns server, or system when using resolv.conf
+	Lookups  []DnsLookup `json:"lookups"`
+}
+
+// GetDnsResolverFromConfig returns the resolver used by /dns and its name, based on the validated dns_resolver setting,
+// the host:port of a dns server like 10.96.0.10:53. the resolvers of /etc/resolv.conf are used when it is empty
+func GetDnsResolverFromConfig(settings config.Config) (*net.Resolver, string) {
+	if settings.DnsResolver == "" {
+		return net.DefaultResolver, "system"
+	}
+	return NewDnsResolver(settings.DnsResolver), settings.DnsResolver
+}
+
+// NewDnsResolver returns a resolver sending all its queries to the dns server at addr
+func NewDnsResolver(addr string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+// parseDnsTypes returns the record types asked in the comma separated list, all of them when the list is empty
+func parseDnsTypes(list string) ([]string, error) {
+	if list == "" {
+		return dnsAllTypes, nil
+	}
+	var types []string
+	for _, t := range config.SplitList(strings.ToUpper(list)) {
+		switch t {
+		case dnsTypeA, dnsTypeAAAA, dnsTypeCNAME, dnsTypeSRV:
+			types = append(types, t)
+		default:
+			return nil, fmt.Errorf("unknown record type %q", t)
+		}
+	}
+	return types, nil
+}
+
+// dnsLookup performs the lookup of one record type for host and measures its duration
+func dnsLookup(ctx context.Context, resolver *net.Resolver, host string, recordType string) DnsLookup {
+	res := DnsLookup{Type: recordType, Records: []string{}}
+	start := time.Now()
+	var err error
+	switch recordType {
+	case dnsTypeA, dnsTypeAAAA:
+		network := "ip4"
+		if recordType == dnsTypeAAAA {
+			network = "ip6"
+		}
+		var ips []net.IP
+		ips, err = resolver.LookupIP(ctx, network, host)
+		for _, ip := range ips {
+			res.Records = append(res.Records, ip.String())
+		}
+	case dnsTypeCNAME:
+		var cname string
+		cname, err = resolver.LookupCNAME(ctx, host)
+		if err == nil {
+			res.Records = append(res.Records, cname)
+		}
+	case dnsTypeSRV:
+		var srvs []*net.SRV
+		_, srvs, err = resolver.LookupSRV(ctx, "", "", host)
+		for _, srv := range srvs {
+			res.Records = append(res.Records, fmt.Sprintf("%d %d %d %s", srv.Priority, srv.Weight, srv.Port, srv.Target))
+		}
+	}
+	res.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			res.Error = "not found"
+		} else {
+			res.Error = err.Error()
+		}
+	}
+	return res
+}
+
+// LookupDns performs the lookups of all the given record types for host, one after the other
+func LookupDns(ctx context.Context, resolver *net.Resolver, resolverName string, host string, types []string) DnsReport {
+	report := DnsReport{Host: host, Resolver: resolverName, Lookups: make([]DnsLookup, 0, len(types))}
+	for _, t := range types {
+		report.Lookups = append(report.Lookups, dnsLookup(ctx, resolver, host, t))
+	}
+	return report
+}
+
+//############# BEGIN DNS HANDLERS
+
+// DnsHandler performs the dns lookups of the host parameter, the type parameter restricts the record types
+// with a comma separated list among A, AAAA, CNAME and SRV
+func (s *GoHttpServer) DnsHandler(resolver *net.Resolver, resolverName string) http.HandlerFunc {
+	handlerName := "DnsHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := strings.TrimSpace(r.URL.Query().Get("host"))
+		if host == "" {
+			http.Error(w, "ERROR: the host parameter is required, like /dns?host=kubernetes.default", http.StatusBadRequest)
+			return
+		}
+		types, err := parseDnsTypes(r.URL.Query().Get("type"))
+		if err != nil {
+			http.Error(w, "ERROR: the type parameter should be a list of A, AAAA, CNAME or SRV", http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), defaultDnsLookupTimeout)
+		defer cancel()
+		s.render(w, r, http.StatusOK, LookupDns(ctx, resolver, resolverName, host, types))
+	}
+}
+
+// ############# END DNS HANDLERS
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDnsResolverFromConfig(t *testing.T) {
+	settings := config.DefaultConfig()
+	_, name := GetDnsResolverFromConfig(settings)
+	assert.Equal(t, "system", name)
+
+	settings.DnsResolver = "10.96.0.10:53"
+	_, name = GetDnsResolverFromConfig(settings)
+	assert.Equal(t, "10.96.0.10:53", name)
+}
+
+func TestParseDnsTypes(t *testing.T) {
+	types, err := parseDnsTypes("")
+	assert.NoError(t, err)
+	assert.Equal(t, dnsAllTypes, types)
+	types, err = parseDnsTypes("a, srv")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{dnsTypeA, dnsTypeSRV}, types)
+	_, err = parseDnsTypes("A,MX")
+	assert.Error(t, err)
+}
+
+func TestGoHttpServerDnsHandler(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name           string
+		url            string
+		wantStatusCode int
+	}{
+		{name: "1: Get /dns without host should be a bad request", url: "/dns", wantStatusCode: http.StatusBadRequest},
+		{name: "2: Get /dns with an unknown type should be a bad request", url: "/dns?host=localhost&type=MX", wantStatusCode: http.StatusBadRequest},
+		{name: "3: Get /dns with a host should be accepted", url: "/dns?host=localhost&type=A", wantStatusCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode, assertCorrectStatusCodeExpected)
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+			var report DnsReport
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report), "the output should be a valid json")
+			assert.Equal(t, "localhost", report.Host)
+			if assert.Len(t, report.Lookups, 1) {
+				assert.Equal(t, dnsTypeA, report.Lookups[0].Type)
+				assert.Contains(t, report.Lookups[0].Records, "127.0.0.1", "localhost should be resolved from /etc/hosts")
+			}
+		})
+	}
+}
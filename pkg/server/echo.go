@@ -0,0 +1,114 @@
+package server
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"unicode/utf8"
+)
+
+const defaultEchoMaxBodyBytes = 64 * 1024 // bodies bigger than this are truncated in the /echo response
+
+// TlsConnInfo describes a tls connection
+type TlsConnInfo struct {
+	Version            string   `json:"version"`
+	CipherSuite        string   `json:"cipher_suite"`
+	ServerName         string   `json:"server_name,omitempty"`         // SNI sent by the client
+	NegotiatedProtocol string   `json:"negotiated_protocol,omitempty"` // ALPN protocol, h2 or http/1.1
+	PeerCertificates   []string `json:"peer_certificates,omitempty"`   // subjects of the certificates presented by the peer
+}
+
+// NewTlsConnInfo returns the description of the tls connection state cs
+func NewTlsConnInfo(cs *tls.ConnectionState) *TlsConnInfo {
+	info := TlsConnInfo{
+		Version:            tls.VersionName(cs.Version),
+		CipherSuite:        tls.CipherSuiteName(cs.CipherSuite),
+		ServerName:         cs.ServerName,
+		NegotiatedProtocol: cs.NegotiatedProtocol,
+	}
+	for _, cert := range cs.PeerCertificates {
+		info.PeerCertificates = append(info.PeerCertificates, cert.Subject.String())
+	}
+	return &info
+}
+
+// EchoInfo is the complete dump of a request received by /echo
+type EchoInfo struct {
+	Method        string              `json:"method"`
+	Url           string              `json:"url"`
+	Proto         string              `json:"proto"`
+	Protocol      string              `json:"protocol"` // h3, h2, h2c or http/1.1
+	Host          string              `json:"host"`
+	Remote        RemoteAddress       `json:"remote"` // ip, port and family of the client, its rdns name with ?rdns=true
+	Headers       map[string][]string `json:"headers"`
+	Query         map[string][]string `json:"query"`
+	ContentLength int64               `json:"content_length"`     // -1 when unknown
+	Body          string              `json:"body"`               // utf8 body, or base64 when BodyEncoding is base64
+	BodyEncoding  string              `json:"body_encoding"`      // text or base64
+	BodyTruncated bool                `json:"body_truncated"`     // true when the body was longer than the size cap
+	Trailers      map[string][]string `json:"trailers,omitempty"` // only available when the whole body was read
+	Tls           *TlsConnInfo        `json:"tls,omitempty"`      // omitted for plain http requests
+	TransferEnc   []string            `json:"transfer_encoding,omitempty"`
+}
+
+// GetEchoInfo reads at most maxBodyBytes of the request body and returns the dump of the request
+func GetEchoInfo(r *http.Request, maxBodyBytes int64) (EchoInfo, error) {
+	info := EchoInfo{
+		Method:        r.Method,
+		Url:           r.URL.String(),
+		Proto:         r.Proto,
+		Protocol:      RequestProtocol(r),
+		Host:          r.Host,
+		Remote:        ParseRemoteAddr(r.RemoteAddr),
+		Headers:       r.Header,
+		Query:         r.URL.Query(),
+		ContentLength: r.ContentLength,
+		BodyEncoding:  "text",
+		TransferEnc:   r.TransferEncoding,
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+	if err != nil {
+		return info, err
+	}
+	if int64(len(body)) > maxBodyBytes {
+		body = body[:maxBodyBytes]
+		info.BodyTruncated = true
+	} else if len(r.Trailer) > 0 {
+		// trailers are only filled once the body has been read up to EOF
+		info.Trailers = r.Trailer
+	}
+	if utf8.Valid(body) {
+		info.Body = string(body)
+	} else {
+		info.Body = base64.StdEncoding.EncodeToString(body)
+		info.BodyEncoding = "base64"
+	}
+	if r.TLS != nil {
+		info.Tls = NewTlsConnInfo(r.TLS)
+	}
+	return info, nil
+}
+
+//############# BEGIN ECHO HANDLERS
+
+// EchoHandler returns the complete incoming request, whatever its http method, to debug ingress and service meshes.
+// the reverse dns name of the client is looked up when the rdns parameter is true
+func (s *GoHttpServer) EchoHandler(maxBodyBytes int64) http.HandlerFunc {
+	handlerName := "EchoHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := GetEchoInfo(r, maxBodyBytes)
+		if err != nil {
+			s.logger.Error("unable to read the request body", "handler", handlerName, "error", err)
+			http.Error(w, "ERROR: unable to read the request body", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("rdns") == "true" {
+			info.Remote.RemotePtr = s.rdns.Lookup(r.Context(), info.Remote.RemoteIp)
+		}
+		s.render(w, r, http.StatusOK, info)
+	}
+}
+
+// ############# END ECHO HANDLERS
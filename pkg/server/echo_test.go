@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoHttpServerEchoHandler(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	doEcho := func(method string, body io.Reader) EchoInfo {
+		r, _ := http.NewRequest(method, ts.URL+"/echo?x=1&x=2", body)
+		r.Header.Set("X-Forwarded-For", "10.0.0.1")
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+		var info EchoInfo
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&info), "the output should be a valid json")
+		return info
+	}
+
+	info := doEcho(http.MethodDelete, strings.NewReader("hello echo"))
+	assert.Equal(t, http.MethodDelete, info.Method, "any http method should be echoed")
+	assert.Equal(t, "/echo?x=1&x=2", info.Url)
+	assert.Equal(t, []string{"1", "2"}, info.Query["x"])
+	assert.Equal(t, []string{"10.0.0.1"}, info.Headers["X-Forwarded-For"])
+	assert.Equal(t, "hello echo", info.Body)
+	assert.Equal(t, "text", info.BodyEncoding)
+	assert.False(t, info.BodyTruncated)
+	assert.Nil(t, info.Tls, "a plain http request should not have tls info")
+	assert.Equal(t, "127.0.0.1", info.Remote.RemoteIp, "the address of the client should be parsed")
+	assert.Equal(t, 4, info.Remote.RemoteIpVersion)
+	assert.Greater(t, info.Remote.RemotePort, 0)
+	assert.Empty(t, info.Remote.RemotePtr, "the reverse dns name should only be looked up with rdns=true")
+
+	info = doEcho(http.MethodPut, strings.NewReader(strings.Repeat("a", defaultEchoMaxBodyBytes+10)))
+	assert.True(t, info.BodyTruncated, "a body bigger than the cap should be truncated")
+	assert.Equal(t, defaultEchoMaxBodyBytes, len(info.Body))
+
+	info = doEcho(http.MethodPost, strings.NewReader("\xff\xfe"))
+	assert.Equal(t, "base64", info.BodyEncoding, "a binary body should be base64 encoded")
+	assert.Equal(t, "//4=", info.Body)
+}
+
+func TestGetEchoInfoTrailers(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("chunk"))
+	r.Trailer = http.Header{"X-Checksum": []string{"42"}}
+	info, err := GetEchoInfo(r, defaultEchoMaxBodyBytes)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"42"}, info.Trailers["X-Checksum"])
+	assert.Equal(t, RemoteAddress{RemoteAddr: "192.0.2.1:1234", RemoteIp: "192.0.2.1", RemotePort: 1234, RemoteIpVersion: 4}, info.Remote)
+}
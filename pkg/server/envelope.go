@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+// HeaderResponseEnvelope is sent with the json answers wrapped in a SourceEnvelope
+const HeaderResponseEnvelope = "X-Response-Envelope"
+
+// SourceEnvelope wraps the json answers when response_envelope is true, so that the tools collecting the answers
+// of many pods always know which one answered and when
+type SourceEnvelope struct {
+	Instance  *InstanceInfo `json:"instance"`
+	Hostname  string        `json:"hostname"`
+	Pod       string        `json:"pod,omitempty"` // POD_NAME of the downward api
+	Timestamp string        `json:"timestamp"`     // time of the answer, RFC3339 with nanoseconds
+	Data      interface{}   `json:"data"`
+}
+
+// NewSourceEnvelope returns the envelope of this server, the fields not depending on the answer are set once
+func NewSourceEnvelope(instance *InstanceInfo, hostname string, downward *info.K8sDownwardInfo) SourceEnvelope {
+	if instance == nil {
+		instance = &InstanceInfo{Hostname: hostname, Version: info.VERSION}
+	}
+	envelope := SourceEnvelope{Instance: instance, Hostname: hostname}
+	if downward != nil {
+		envelope.Pod = downward.PodName
+	}
+	return envelope
+}
+
+// Wrap returns the envelope of the data answered at now
+func (e SourceEnvelope) Wrap(data interface{}, now time.Time) SourceEnvelope {
+	e.Data = data
+	e.Timestamp = now.Format(time.RFC3339Nano)
+	return e
+}
+
+type sourceEnvelopeContextKey struct{}
+
+// withoutSourceEnvelope returns r telling jsonResponseWithStatus not to wrap the answer, because the caller
+// wraps it itself like the envelope of /api/v1
+func withoutSourceEnvelope(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), sourceEnvelopeContextKey{}, true))
+}
+
+// wantsSourceEnvelope tells if the json answer to r must be wrapped in the SourceEnvelope
+func (s *GoHttpServer) wantsSourceEnvelope(r *http.Request) bool {
+	if skip, _ := r.Context().Value(sourceEnvelopeContextKey{}).(bool); skip {
+		return false
+	}
+	return s.settings.Current().RespEnvelope
+}
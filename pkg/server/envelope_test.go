@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSourceEnvelope(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		instance *InstanceInfo
+		downward *info.K8sDownwardInfo
+		want     string
+	}{
+		{name: "1: outside k8s should give the hostname and the version",
+			want: `{"instance":{"hostname":"laptop","version":"` + info.VERSION + `"},"hostname":"laptop","timestamp":"2024-05-01T12:00:00Z","data":{"a":1}}`},
+		{name: "2: pod and track should be given", instance: &InstanceInfo{Hostname: "laptop", Version: "1.0.0", Track: "canary"},
+			downward: &info.K8sDownwardInfo{PodName: "pod-1"},
+			want:     `{"instance":{"hostname":"laptop","version":"1.0.0","track":"canary"},"hostname":"laptop","pod":"pod-1","timestamp":"2024-05-01T12:00:00Z","data":{"a":1}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(NewSourceEnvelope(tt.instance, "laptop", tt.downward).Wrap(json.RawMessage(`{"a":1}`), now))
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestGoHttpServerSourceEnvelope(t *testing.T) {
+	t.Setenv("RESPONSE_ENVELOPE", "true")
+	t.Setenv("DEPLOY_TRACK", "canary")
+	t.Setenv("POD_NAME", "pod-1")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		wantData string
+	}{
+		{name: "1: json answer should be the data of the envelope", path: "/buildinfo", wantData: `"data":{`},
+		{name: "2: versioned answer should be wrapped once", path: "/api/v1/buildinfo", wantData: `"api_version":"v1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var etags []string
+			for range 2 {
+				resp, err := http.Get(ts.URL + tt.path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+				assert.Equal(t, "source", resp.Header.Get(HeaderResponseEnvelope))
+				assert.Contains(t, string(body), `"pod":"pod-1"`)
+				assert.Contains(t, string(body), `"timestamp":"`)
+				assert.Contains(t, string(body), tt.wantData)
+				assert.Equal(t, 1, strings.Count(string(body), `"pod":"pod-1"`), "the answer should be wrapped once")
+				etags = append(etags, resp.Header.Get("ETag"))
+			}
+			assert.Equal(t, etags[0], etags[1], "the ETag should not change with the timestamp")
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+)
+
+const redactedValue = "********"
+
+// defaultEnvRedactPatterns match the names of the env variables whose values are masked in the responses
+var defaultEnvRedactPatterns = []string{`(?i)_PASSWORD$`, `(?i)_TOKEN$`, `(?i)KEY`, `(?i)SECRET`}
+
+// EnvRedactor filters the environment variables before they are sent to a client : the values of the variables
+// matching a deny pattern are masked, and when an allowlist is defined the other variables are not shown at all
+type EnvRedactor struct {
+	deny  []*regexp.Regexp
+	allow map[string]bool // nil when every variable may be shown
+}
+
+// NewEnvRedactor is a constructor for an EnvRedactor, it returns an error listing all the invalid patterns
+func NewEnvRedactor(denyPatterns []string, allowlist []string) (*EnvRedactor, error) {
+	er := EnvRedactor{}
+	var invalid []string
+	for _, p := range denyPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%q (%v)", p, err))
+			continue
+		}
+		er.deny = append(er.deny, re)
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("invalid patterns : %s", strings.Join(invalid, ", "))
+	}
+	if len(allowlist) > 0 {
+		er.allow = make(map[string]bool, len(allowlist))
+		for _, name := range allowlist {
+			er.allow[name] = true
+		}
+	}
+	return &er, nil
+}
+
+// GetEnvRedactorFromConfig returns the EnvRedactor configured by the settings :
+//
+//	env_var_redact_patterns : comma separated list of regexp added to the default ones (_PASSWORD$, _TOKEN$, KEY, SECRET)
+//	env_var_allowlist : comma separated list of the only variable names that may be shown (all when empty)
+//
+// on error the default redactor is returned together with the error, so that secrets are never shown by mistake
+func GetEnvRedactorFromConfig(settings config.Config) (*EnvRedactor, error) {
+	patterns := append(append([]string{}, defaultEnvRedactPatterns...), config.SplitList(settings.EnvRedact)...)
+	er, err := NewEnvRedactor(patterns, config.SplitList(settings.EnvAllowlist))
+	if err != nil {
+		defaultRedactor, _ := NewEnvRedactor(defaultEnvRedactPatterns, nil)
+		return defaultRedactor, &config.ErrorConfig{Err: err, Msg: "ERROR: CONFIG env_var_redact_patterns (env ENV_VAR_REDACT_PATTERNS) should contain valid regexp"}
+	}
+	return er, nil
+}
+
+// Redact returns the NAME=value entries of environ that may be shown, with the sensitive values masked
+func (er *EnvRedactor) Redact(environ []string) []string {
+	res := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if er.allow != nil && !er.allow[name] {
+			continue
+		}
+		if er.Masked(name) {
+			kv = name + "=" + redactedValue
+		}
+		res = append(res, kv)
+	}
+	return res
+}
+
+// Masked returns true when the value of the variable name is sensitive and must not be shown
+func (er *EnvRedactor) Masked(name string) bool {
+	for _, re := range er.deny {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvRedactorRedact(t *testing.T) {
+	environ := []string{"PATH=/usr/bin", "DB_PASSWORD=s3cr3t", "API_TOKEN=abc", "AWS_ACCESS_KEY_ID=AKIA", "HOME=/root", "EMPTY="}
+	tests := []struct {
+		name      string
+		patterns  []string
+		allowlist []string
+		want      []string
+	}{
+		{name: "1: default patterns should mask the secrets", patterns: defaultEnvRedactPatterns,
+			want: []string{"PATH=/usr/bin", "DB_PASSWORD=" + redactedValue, "API_TOKEN=" + redactedValue, "AWS_ACCESS_KEY_ID=" + redactedValue, "HOME=/root", "EMPTY="}},
+		{name: "2: extra pattern should mask more variables", patterns: append(append([]string{}, defaultEnvRedactPatterns...), "^HOME$"),
+			want: []string{"PATH=/usr/bin", "DB_PASSWORD=" + redactedValue, "API_TOKEN=" + redactedValue, "AWS_ACCESS_KEY_ID=" + redactedValue, "HOME=" + redactedValue, "EMPTY="}},
+		{name: "3: allowlist should hide the other variables and still mask the secrets", patterns: defaultEnvRedactPatterns,
+			allowlist: []string{"PATH", "API_TOKEN"}, want: []string{"PATH=/usr/bin", "API_TOKEN=" + redactedValue}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			er, err := NewEnvRedactor(tt.patterns, tt.allowlist)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, er.Redact(environ))
+		})
+	}
+}
+
+func TestGetEnvRedactorFromConfig(t *testing.T) {
+	settings := config.DefaultConfig()
+	settings.EnvRedact, settings.EnvAllowlist = "^HOME$, ", "HOME,PATH"
+	er, err := GetEnvRedactorFromConfig(settings)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"HOME=" + redactedValue, "PATH=/usr/bin"}, er.Redact([]string{"HOME=/root", "PATH=/usr/bin", "USER=root"}))
+
+	settings.EnvRedact = "(["
+	er, err = GetEnvRedactorFromConfig(settings)
+	assert.Error(t, err, "an invalid regexp should be reported")
+	assert.Equal(t, []string{"DB_PASSWORD=" + redactedValue, "USER=root"}, er.Redact([]string{"DB_PASSWORD=x", "USER=root"}),
+		"the default redactor should be used on error")
+}
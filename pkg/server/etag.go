@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"strings"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+// etagVolatileHeaders are the request headers echoed by / and /headers which change on every request of a poller
+var etagVolatileHeaders = []string{"If-None-Match", "If-Modified-Since", HeaderRequestId, traceparentHeader}
+
+// etagStableHeaders returns a copy of headers without the etagVolatileHeaders
+func etagStableHeaders(headers http.Header) http.Header {
+	stable := headers.Clone()
+	for _, name := range etagVolatileHeaders {
+		stable.Del(name)
+	}
+	return stable
+}
+
+// etagSubject returns the part of result the ETag is computed on, result without the fields changing on every
+// request like the request id, the uptime or the If-None-Match header echoed back, which would never give a 304
+func etagSubject(result interface{}) interface{} {
+	switch v := result.(type) {
+	case info.RuntimeInfo:
+		v.RequestId, v.Uptime, v.UptimeOs, v.RemoteAddr, v.RemotePort = "", "", "", "", 0
+		v.Headers = etagStableHeaders(v.Headers)
+		return v
+	case HeadersReport:
+		v.Headers = etagStableHeaders(v.Headers)
+		v.HeaderBytes = 0
+		return v
+	case ApiEnvelope:
+		if v.dataETag != "" {
+			v.Data, _ = json.Marshal(v.dataETag)
+		}
+		return v
+	}
+	return result
+}
+
+// resultETag returns a weak entity tag of the compact json encoding of the etagSubject of result, streamed into the
+// hash. the tag is weak since the same result may be sent pretty printed, or in gzip or deflate by the compress middleware
+func resultETag(result interface{}) (string, error) {
+	h := fnv.New64a()
+	if err := json.NewEncoder(h).Encode(etagSubject(result)); err != nil {
+		return "", err
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil)) + `"`, nil
+}
+
+// etagMatches tells if the If-None-Match header ifNoneMatch lists etag or is *, comparing the tags the weak way
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	opaque := strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the ETag of result and the Cache-Control of the configuration on the successful answer to a GET
+// or a HEAD, and answers 304 without a body when the client already has this version. frequent pollers of the
+// big answers like /headers or / then only get the headers while nothing changes
+func (s *GoHttpServer) notModified(w http.ResponseWriter, r *http.Request, status int, result interface{}) bool {
+	if status != http.StatusOK || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+	etag, err := resultETag(result)
+	if err != nil {
+		return false
+	}
+	w.Header().Set("ETag", etag)
+	if cacheControl := s.settings.Current().CacheControl; cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
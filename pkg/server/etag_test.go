@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtagMatches(t *testing.T) {
+	etag, err := resultETag(map[string]int{"a": 1})
+	assert.NoError(t, err)
+	other, _ := resultETag(map[string]int{"a": 2})
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        bool
+	}{
+		{name: "1: no header should not match"},
+		{name: "2: same tag should match", ifNoneMatch: etag, want: true},
+		{name: "3: tag in a list should match", ifNoneMatch: `"other", ` + etag, want: true},
+		{name: "4: strong form of the weak tag should match", ifNoneMatch: etag[len("W/"):], want: true},
+		{name: "5: star should match", ifNoneMatch: "*", want: true},
+		{name: "6: other tag should not match", ifNoneMatch: other},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, etagMatches(tt.ifNoneMatch, etag))
+		})
+	}
+}
+
+func TestGoHttpServerConditionalGet(t *testing.T) {
+	t.Setenv("CACHE_CONTROL", "max-age=5")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	first, err := http.Get(ts.URL + "/buildinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Body.Close()
+	etag := first.Header.Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, "max-age=5", first.Header.Get("Cache-Control"))
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		ifNoneMatch string
+		wantStatus  int
+		wantETag    bool
+	}{
+		{name: "1: known ETag should get a 304", method: http.MethodGet, path: "/buildinfo", ifNoneMatch: etag, wantStatus: http.StatusNotModified, wantETag: true},
+		{name: "2: outdated ETag should get the answer", method: http.MethodGet, path: "/buildinfo", ifNoneMatch: `W/"outdated"`, wantStatus: http.StatusOK, wantETag: true},
+		{name: "3: versioned answer should have its own ETag", method: http.MethodGet, path: "/api/v1/buildinfo", ifNoneMatch: etag, wantStatus: http.StatusOK, wantETag: true},
+		{name: "4: error should not get an ETag", method: http.MethodGet, path: "/nowhere", ifNoneMatch: "*", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, ts.URL+tt.path, nil)
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatus, resp.StatusCode, assertCorrectStatusCodeExpected)
+			assert.Equal(t, tt.wantETag, resp.Header.Get("ETag") != "")
+		})
+	}
+}
+
+func TestGoHttpServerConditionalGetVolatileFields(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	for _, path := range []string{"/", "/headers", "/api/v1/runtime"} {
+		t.Run(path, func(t *testing.T) {
+			first, err := http.Get(ts.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			first.Body.Close()
+			etag := first.Header.Get("ETag")
+			assert.NotEmpty(t, etag)
+
+			time.Sleep(10 * time.Millisecond) // the uptime changes
+			// a new connection, the remote port changes too
+			client := &http.Client{Transport: &http.Transport{}}
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+			req.Header.Set("If-None-Match", etag)
+			second, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second.Body.Close()
+			assert.Equal(t, http.StatusNotModified, second.StatusCode, "the request id, the uptime and the echoed If-None-Match should not change the ETag")
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderInjectDelay   = "X-Inject-Delay"
+	HeaderInjectStatus  = "X-Inject-Status"
+	HeaderFaultInjected = "X-Fault-Injected" // tells the client the answer was delayed or failed on its request
+)
+
+// Fault is the failure asked by a request with the X-Inject-Delay and X-Inject-Status headers
+type Fault struct {
+	Delay  time.Duration
+	Status int // 0 to answer normally after the delay
+}
+
+// String returns the fault like "delay=500ms; status=503", sent in the X-Fault-Injected header
+func (f Fault) String() string {
+	var fields []string
+	if f.Delay > 0 {
+		fields = append(fields, "delay="+f.Delay.String())
+	}
+	if f.Status != 0 {
+		fields = append(fields, "status="+strconv.Itoa(f.Status))
+	}
+	return strings.Join(fields, "; ")
+}
+
+// parseFault returns the fault asked by the headers of r, the delay must not be longer than maxDelay
+func parseFault(r *http.Request, maxDelay time.Duration) (Fault, error) {
+	var fault Fault
+	if val := r.Header.Get(HeaderInjectDelay); val != "" {
+		delay, err := time.ParseDuration(val)
+		if err != nil || delay < 0 || delay > maxDelay {
+			return fault, fmt.Errorf("%s should be a duration between 0 and %s like 500ms, got %q", HeaderInjectDelay, maxDelay, val)
+		}
+		fault.Delay = delay
+	}
+	if val := r.Header.Get(HeaderInjectStatus); val != "" {
+		status, err := strconv.Atoi(val)
+		if err != nil || status < 200 || status > 599 {
+			return fault, fmt.Errorf("%s should be an http status between 200 and 599, got %q", HeaderInjectStatus, val)
+		}
+		fault.Status = status
+	}
+	return fault, nil
+}
+
+// injectFaults is the Middleware delaying or failing the requests asking it with the X-Inject-Delay and X-Inject-Status
+// headers, so that the retry policies of the clients or of a service mesh can be tested on chosen requests.
+// the headers are ignored unless fault_headers is true, and the delay is limited by wait_max like /wait
+func (s *GoHttpServer) injectFaults() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(HeaderInjectDelay) == "" && r.Header.Get(HeaderInjectStatus) == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			config := s.settings.Current()
+			if !config.FaultHeaders {
+				next.ServeHTTP(w, r)
+				return
+			}
+			fault, err := parseFault(r, config.WaitMax)
+			if err != nil {
+				http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.logger.DebugContext(r.Context(), "fault injected", "path", r.URL.Path, "fault", fault.String())
+			w.Header().Set(HeaderFaultInjected, fault.String())
+			if fault.Delay > 0 {
+				timer := time.NewTimer(fault.Delay)
+				defer timer.Stop()
+				select {
+				case <-timer.C:
+				case <-r.Context().Done():
+					return
+				}
+			}
+			if fault.Status != 0 {
+				http.Error(w, fmt.Sprintf("ERROR: status %d injected by the %s header", fault.Status, HeaderInjectStatus), fault.Status)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
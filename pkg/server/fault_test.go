@@ -0,0 +1,87 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFault(t *testing.T) {
+	tests := []struct {
+		name      string
+		delay     string
+		status    string
+		want      Fault
+		wantError bool
+	}{
+		{name: "1: no header should give no fault"},
+		{name: "2: delay and status should be parsed", delay: "250ms", status: "503", want: Fault{Delay: 250 * time.Millisecond, Status: 503}},
+		{name: "3: delay above the maximum should be an error", delay: "10s", wantError: true},
+		{name: "4: negative delay should be an error", delay: "-1s", wantError: true},
+		{name: "5: status which is not an http status should be an error", status: "42", wantError: true},
+		{name: "6: status which is not a number should be an error", status: "unavailable", wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.delay != "" {
+				r.Header.Set(HeaderInjectDelay, tt.delay)
+			}
+			if tt.status != "" {
+				r.Header.Set(HeaderInjectStatus, tt.status)
+			}
+			got, err := parseFault(r, time.Second)
+			assert.Equal(t, tt.wantError, err != nil)
+			if err == nil {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGoHttpServerInjectFaults(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    bool
+		headers    map[string]string
+		wantStatus int
+		wantDelay  time.Duration
+		wantHeader string
+	}{
+		{name: "1: headers should be ignored when disabled", headers: map[string]string{HeaderInjectStatus: "503"}, wantStatus: http.StatusOK},
+		{name: "2: injected status should be answered", enabled: true, headers: map[string]string{HeaderInjectStatus: "503"},
+			wantStatus: http.StatusServiceUnavailable, wantHeader: "status=503"},
+		{name: "3: injected delay should be waited before the answer", enabled: true, headers: map[string]string{HeaderInjectDelay: "100ms"},
+			wantStatus: http.StatusOK, wantDelay: 100 * time.Millisecond, wantHeader: "delay=100ms"},
+		{name: "4: invalid header should be a bad request", enabled: true, headers: map[string]string{HeaderInjectDelay: "1h"},
+			wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.DefaultConfig()
+			settings.FaultHeaders = tt.enabled
+			myServer := NewGoHttpServerWithConfig(config.DefaultListenIp+":0", settings, getTestLogger())
+			ts := httptest.NewServer(myServer.router)
+			defer ts.Close()
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+"/time", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			start := time.Now()
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			io.Copy(io.Discard, resp.Body)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode, assertCorrectStatusCodeExpected)
+			assert.GreaterOrEqual(t, time.Since(start), tt.wantDelay)
+			assert.Equal(t, tt.wantHeader, resp.Header.Get(HeaderFaultInjected))
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultProcSelfFd = "/proc/self/fd"
+	maxListedFds      = 1000 // descriptors detailed by /info/fds, the counts include all of them
+	fdsWarnPercent    = 80   // a usage of the open files limit above this percent is warned
+)
+
+// FdInfo is an open file descriptor of this process
+type FdInfo struct {
+	Fd     int    `json:"fd"`
+	Type   string `json:"type"`   // file, socket, pipe, anon_inode, device or other
+	Target string `json:"target"` // path of the file, or socket:[inode] like the link in /proc
+}
+
+// FdReport is the answer of /info/fds
+type FdReport struct {
+	Count       int            `json:"count"`
+	Limit       int            `json:"limit,omitempty"` // soft limit of open files, omitted when unlimited or unknown
+	UsedPercent float64        `json:"used_percent,omitempty"`
+	ByType      map[string]int `json:"by_type"`
+	Fds         []FdInfo       `json:"fds"` // sorted by fd, truncated to the first 1000
+	Truncated   bool           `json:"truncated,omitempty"`
+	Warnings    []string       `json:"warnings,omitempty"`
+	Errors      []string       `json:"errors,omitempty"` // problems met while collecting the information
+}
+
+// fdType returns the kind of descriptor of a link target of /proc/[pid]/fd
+func fdType(target string) string {
+	switch {
+	case strings.HasPrefix(target, "socket:["):
+		return "socket"
+	case strings.HasPrefix(target, "pipe:["):
+		return "pipe"
+	case strings.HasPrefix(target, "anon_inode:"):
+		return "anon_inode"
+	case strings.HasPrefix(target, "/dev/"):
+		return "device"
+	case strings.HasPrefix(target, "/"):
+		return "file"
+	}
+	return "other"
+}
+
+// ReadFds returns the descriptors of the fd directory at dir, sorted by fd. the ones closed while reading are skipped
+func ReadFds(dir string) ([]FdInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	fds := make([]FdInfo, 0, len(entries))
+	for _, entry := range entries {
+		fd, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		fds = append(fds, FdInfo{Fd: fd, Type: fdType(target), Target: target})
+	}
+	slices.SortFunc(fds, func(a, b FdInfo) int { return a.Fd - b.Fd })
+	return fds, nil
+}
+
+// socketInodes returns the inodes of the sockets of the fd directory at dir, to find them in /proc/net/tcp
+func socketInodes(dir string) map[string]bool {
+	inodes := map[string]bool{}
+	fds, _ := ReadFds(dir)
+	for _, fd := range fds {
+		if inode, found := strings.CutPrefix(fd.Target, "socket:["); found {
+			inodes[strings.TrimSuffix(inode, "]")] = true
+		}
+	}
+	return inodes
+}
+
+// GetFdReport returns the descriptors of the fd directory at fdDir, compared to the soft limit of the limits file at limitsPath
+func GetFdReport(fdDir, limitsPath string) FdReport {
+	report := FdReport{ByType: map[string]int{}, Fds: []FdInfo{}}
+	fds, err := ReadFds(fdDir)
+	if err != nil {
+		report.Errors = append(report.Errors, "fds: "+err.Error())
+		return report
+	}
+	report.Count = len(fds)
+	for _, fd := range fds {
+		report.ByType[fd.Type]++
+	}
+	if len(fds) > maxListedFds {
+		fds, report.Truncated = fds[:maxListedFds], true
+	}
+	report.Fds = fds
+	rlimits, err := ReadRlimits(limitsPath)
+	if err != nil {
+		report.Errors = append(report.Errors, "rlimits: "+err.Error())
+	}
+	for _, l := range rlimits {
+		if limit, err := strconv.Atoi(l.Soft); err == nil && l.Resource == "nofile" && limit > 0 {
+			report.Limit = limit
+			report.UsedPercent = float64(report.Count) * 100 / float64(limit)
+		}
+	}
+	if report.UsedPercent > fdsWarnPercent {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%d descriptors are open, %.0f%% of the limit of %d open files", report.Count, report.UsedPercent, report.Limit))
+	}
+	return report
+}
+
+//############# BEGIN INFO HANDLERS
+
+// FdsHandler returns the open file descriptors of the process by type with their targets
+func (s *GoHttpServer) FdsHandler(fdDir, limitsPath string) http.HandlerFunc {
+	handlerName := "FdsHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, r, http.StatusOK, GetFdReport(fdDir, limitsPath))
+	}
+}
+
+// ############# END INFO HANDLERS
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestFdDir returns a fd directory with links like the ones of /proc/self/fd
+func writeTestFdDir(t *testing.T, targets map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for fd, target := range targets {
+		if err := os.Symlink(target, filepath.Join(dir, fd)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestGetFdReport(t *testing.T) {
+	fdDir := writeTestFdDir(t, map[string]string{
+		"0": "/dev/null", "1": "pipe:[201214]", "2": "pipe:[201215]", "3": "socket:[1114]",
+		"4": "anon_inode:[eventpoll]", "10": "/var/log/access.log",
+	})
+	limitsDir := t.TempDir()
+	limitsPath := filepath.Join(limitsDir, "limits")
+	if err := os.WriteFile(limitsPath, []byte(testProcLimits), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report := GetFdReport(fdDir, limitsPath)
+	assert.Empty(t, report.Errors)
+	assert.Equal(t, 6, report.Count)
+	assert.Equal(t, map[string]int{"device": 1, "pipe": 2, "socket": 1, "anon_inode": 1, "file": 1}, report.ByType)
+	assert.Equal(t, 1024, report.Limit)
+	if !assert.Len(t, report.Fds, 6) {
+		return
+	}
+	tests := []struct {
+		name string
+		got  FdInfo
+		want FdInfo
+	}{
+		{name: "1: the fds should be sorted by number", got: report.Fds[5], want: FdInfo{Fd: 10, Type: "file", Target: "/var/log/access.log"}},
+		{name: "2: a socket should keep its inode", got: report.Fds[3], want: FdInfo{Fd: 3, Type: "socket", Target: "socket:[1114]"}},
+		{name: "3: a device should be recognized", got: report.Fds[0], want: FdInfo{Fd: 0, Type: "device", Target: "/dev/null"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.got)
+		})
+	}
+	assert.Equal(t, map[string]bool{"1114": true}, socketInodes(fdDir))
+}
+
+func TestGoHttpServerFdsHandler(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.FdsHandler(defaultProcSelfFd, defaultProcSelfLimits))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var report FdReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("the output should be a valid json : %v", err)
+	}
+	if _, err := os.Stat(defaultProcSelfFd); err != nil {
+		t.Skip("no proc filesystem")
+	}
+	assert.Greater(t, report.Count, 2, "the test process should have stdin, stdout and stderr open")
+}
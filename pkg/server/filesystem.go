@@ -0,0 +1,187 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMountInfoPath       = "/proc/self/mountinfo"
+	filesystemWarnAvailPercent = 10 // volumes with less available space than this percent are warned
+)
+
+// pseudoFsTypes are the kernel filesystems without storage, hidden unless all=true is asked
+var pseudoFsTypes = map[string]bool{
+	"proc": true, "sysfs": true, "cgroup": true, "cgroup2": true, "devpts": true, "mqueue": true, "securityfs": true,
+	"debugfs": true, "tracefs": true, "bpf": true, "pstore": true, "fusectl": true, "configfs": true, "nsfs": true,
+	"binfmt_misc": true, "autofs": true, "hugetlbfs": true, "selinuxfs": true,
+}
+
+// kubeletVolumeKinds maps the plugin directory of the kubelet volumes to the kind of volume of the pod spec
+var kubeletVolumeKinds = []struct {
+	dir  string
+	kind string
+}{
+	{"/volumes/kubernetes.io~empty-dir/", "emptyDir"},
+	{"/volumes/kubernetes.io~configmap/", "configMap"},
+	{"/volumes/kubernetes.io~secret/", "secret"},
+	{"/volumes/kubernetes.io~projected/", "projected"},
+	{"/volumes/kubernetes.io~downward-api/", "downwardAPI"},
+	{"/volumes/kubernetes.io~csi/", "pvc"},
+	{"/volumes/kubernetes.io~", "pvc"}, // the in-tree volume plugins like nfs, aws-ebs or local-volume
+	{"/volume-subpaths/", "subPath"},
+}
+
+// FilesystemUsage is the space and inodes of a mounted filesystem, as given by statfs
+type FilesystemUsage struct {
+	TotalBytes     uint64  `json:"total_bytes"`
+	FreeBytes      uint64  `json:"free_bytes"`      // free for root
+	AvailableBytes uint64  `json:"available_bytes"` // free for the unprivileged users
+	UsedBytes      uint64  `json:"used_bytes"`
+	UsedPercent    float64 `json:"used_percent"`
+	TotalInodes    uint64  `json:"total_inodes"`
+	FreeInodes     uint64  `json:"free_inodes"`
+}
+
+// MountInfo describes one mount of the container
+type MountInfo struct {
+	MountPoint string           `json:"mount_point"`
+	Source     string           `json:"source"`
+	FsType     string           `json:"fs_type"`
+	Options    string           `json:"options"`
+	ReadOnly   bool             `json:"read_only"`
+	Root       string           `json:"root"`                  // directory of the source mounted here, the kubelet volume path for the pod volumes
+	VolumeKind string           `json:"volume_kind,omitempty"` // emptyDir, configMap, secret, projected, downwardAPI, pvc or subPath
+	VolumeName string           `json:"volume_name,omitempty"` // name of the volume in the pod spec, or of the persistent volume
+	Usage      *FilesystemUsage `json:"usage,omitempty"`       // omitted when statfs fails or gives no storage
+}
+
+// FilesystemInfo lists the mounts of the container and highlights the volumes of the pod
+type FilesystemInfo struct {
+	Mounts   []MountInfo `json:"mounts"`
+	Warnings []string    `json:"warnings,omitempty"`
+	Errors   []string    `json:"errors,omitempty"` // problems met while collecting the information
+}
+
+// unescapeMountPath replaces the octal escapes like \040 used by the kernel for the spaces and tabs in the paths
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var sb strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+4 <= len(path) {
+			if c, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(path[i])
+	}
+	return sb.String()
+}
+
+// kubeletVolume returns the kind and the name of the pod volume mounted from root, empty strings for other mounts.
+// the tmpfs volumes like secrets are mounted from their own root, only their place under /run/secrets reveals them
+func kubeletVolume(root, mountPoint, fsType string) (string, string) {
+	for _, v := range kubeletVolumeKinds {
+		if _, after, found := strings.Cut(root, v.dir); found {
+			name, _, _ := strings.Cut(after, "/")
+			return v.kind, name
+		}
+	}
+	if fsType == "tmpfs" && (strings.HasPrefix(mountPoint, "/var/run/secrets/") || strings.HasPrefix(mountPoint, "/run/secrets/")) {
+		return "secret", ""
+	}
+	return "", ""
+}
+
+// ReadMountInfo returns the mounts found in the mountinfo file at path, the detailed form of /proc/mounts also giving the
+// root of each mount. a line looks like : 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+func ReadMountInfo(path string) ([]MountInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var mounts []MountInfo
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || sep+2 >= len(fields) {
+			continue
+		}
+		m := MountInfo{
+			Root:       unescapeMountPath(fields[3]),
+			MountPoint: unescapeMountPath(fields[4]),
+			FsType:     fields[sep+1],
+			Source:     unescapeMountPath(fields[sep+2]),
+			Options:    fields[5],
+		}
+		for _, opt := range strings.Split(m.Options, ",") {
+			if opt == "ro" {
+				m.ReadOnly = true
+			}
+		}
+		m.VolumeKind, m.VolumeName = kubeletVolume(m.Root, m.MountPoint, m.FsType)
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+// GetFilesystemInfo returns the mounts found in the mountinfo file at path with their usage, the kernel pseudo
+// filesystems are skipped unless all is true
+func GetFilesystemInfo(mountInfoPath string, all bool) FilesystemInfo {
+	fsInfo := FilesystemInfo{Mounts: []MountInfo{}}
+	mounts, err := ReadMountInfo(mountInfoPath)
+	if err != nil {
+		fsInfo.Errors = append(fsInfo.Errors, "mountinfo: "+err.Error())
+		return fsInfo
+	}
+	for _, m := range mounts {
+		if pseudoFsTypes[m.FsType] && !all {
+			continue
+		}
+		usage, err := statFilesystem(m.MountPoint)
+		if err != nil {
+			if !os.IsPermission(err) && !os.IsNotExist(err) && !errors.Is(err, errors.ErrUnsupported) {
+				fsInfo.Errors = append(fsInfo.Errors, fmt.Sprintf("statfs %s: %v", m.MountPoint, err))
+			}
+		} else if usage.TotalBytes > 0 {
+			m.Usage = &usage
+			if m.VolumeKind != "" && !m.ReadOnly && usage.AvailableBytes*100 < usage.TotalBytes*filesystemWarnAvailPercent {
+				fsInfo.Warnings = append(fsInfo.Warnings, fmt.Sprintf("%s volume %s mounted on %s has only %d bytes available",
+					m.VolumeKind, m.VolumeName, m.MountPoint, usage.AvailableBytes))
+			}
+		}
+		fsInfo.Mounts = append(fsInfo.Mounts, m)
+	}
+	return fsInfo
+}
+
+//############# BEGIN INFO HANDLERS
+
+// FilesystemInfoHandler returns the mounts of the container with their usage, the all=true parameter adds the pseudo filesystems
+func (s *GoHttpServer) FilesystemInfoHandler(mountInfoPath string) http.HandlerFunc {
+	handlerName := "FilesystemInfoHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		all, _ := strconv.ParseBool(r.URL.Query().Get("all"))
+		s.render(w, r, http.StatusOK, GetFilesystemInfo(mountInfoPath, all))
+	}
+}
+
+// ############# END INFO HANDLERS
@@ -0,0 +1,24 @@
+package server
+
+import "syscall"
+
+// statFilesystem returns the usage of the filesystem mounted on path
+func statFilesystem(path string) (FilesystemUsage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return FilesystemUsage{}, err
+	}
+	bsize := uint64(st.Bsize)
+	usage := FilesystemUsage{
+		TotalBytes:     st.Blocks * bsize,
+		FreeBytes:      st.Bfree * bsize,
+		AvailableBytes: st.Bavail * bsize,
+		TotalInodes:    st.Files,
+		FreeInodes:     st.Ffree,
+	}
+	usage.UsedBytes = usage.TotalBytes - usage.FreeBytes
+	if usage.TotalBytes > 0 {
+		usage.UsedPercent = float64(usage.UsedBytes) * 100 / float64(usage.TotalBytes)
+	}
+	return usage, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package server
+
+import "errors"
+
+// statFilesystem is only available on linux, where the containers run
+func statFilesystem(_ string) (FilesystemUsage, error) {
+	return FilesystemUsage{}, errors.ErrUnsupported
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const testMountInfo = `22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+23 22 0:5 / /proc rw,nosuid,nodev,noexec,relatime - proc proc rw
+24 22 8:1 /var/lib/kubelet/pods/0a1b/volumes/kubernetes.io~empty-dir/cache /cache rw,relatime - ext4 /dev/sda1 rw
+25 22 8:1 /var/lib/kubelet/pods/0a1b/volumes/kubernetes.io~configmap/settings /etc/app ro,relatime - ext4 /dev/sda1 rw
+26 22 0:45 / /var/run/secrets/kubernetes.io/serviceaccount ro,relatime - tmpfs tmpfs rw,size=1024k
+27 22 8:16 /var/lib/kubelet/pods/0a1b/volumes/kubernetes.io~csi/pvc-42/mount /my\040data rw,relatime shared:5 - xfs /dev/sdb rw
+28 22 8:1 /var/lib/kubelet/pods/0a1b/volume-subpaths/config/app/0 /etc/app.conf ro,relatime - ext4 /dev/sda1 rw
+broken line
+`
+
+func TestReadMountInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mountinfo")
+	if err := os.WriteFile(path, []byte(testMountInfo), 0600); err != nil {
+		t.Fatalf("unable to write mountinfo : %v", err)
+	}
+	mounts, err := ReadMountInfo(path)
+	assert.NoError(t, err)
+	if !assert.Len(t, mounts, 7, "the broken line should be skipped") {
+		return
+	}
+	tests := []struct {
+		name           string
+		mount          MountInfo
+		wantMountPoint string
+		wantKind       string
+		wantVolume     string
+		wantReadOnly   bool
+	}{
+		{name: "1: root filesystem should not be a volume", mount: mounts[0], wantMountPoint: "/"},
+		{name: "2: emptyDir should be recognized", mount: mounts[2], wantMountPoint: "/cache", wantKind: "emptyDir", wantVolume: "cache"},
+		{name: "3: configMap should be read-only", mount: mounts[3], wantMountPoint: "/etc/app", wantKind: "configMap", wantVolume: "settings", wantReadOnly: true},
+		{name: "4: tmpfs under /var/run/secrets should be a secret", mount: mounts[4], wantMountPoint: "/var/run/secrets/kubernetes.io/serviceaccount", wantKind: "secret", wantReadOnly: true},
+		{name: "5: csi volume should be a pvc with an unescaped mount point", mount: mounts[5], wantMountPoint: "/my data", wantKind: "pvc", wantVolume: "pvc-42"},
+		{name: "6: subPath should be recognized", mount: mounts[6], wantMountPoint: "/etc/app.conf", wantKind: "subPath", wantVolume: "config", wantReadOnly: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantMountPoint, tt.mount.MountPoint)
+			assert.Equal(t, tt.wantKind, tt.mount.VolumeKind)
+			assert.Equal(t, tt.wantVolume, tt.mount.VolumeName)
+			assert.Equal(t, tt.wantReadOnly, tt.mount.ReadOnly)
+		})
+	}
+	assert.Equal(t, "xfs", mounts[5].FsType)
+	assert.Equal(t, "/dev/sdb", mounts[5].Source)
+}
+
+func TestGetFilesystemInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mountinfo")
+	if err := os.WriteFile(path, []byte(testMountInfo), 0600); err != nil {
+		t.Fatalf("unable to write mountinfo : %v", err)
+	}
+	fsInfo := GetFilesystemInfo(path, false)
+	assert.Len(t, fsInfo.Mounts, 6, "the proc pseudo filesystem should be skipped")
+	assert.Len(t, GetFilesystemInfo(path, true).Mounts, 7)
+	assert.Empty(t, fsInfo.Errors, "the missing mount points should not be errors")
+
+	fsInfo = GetFilesystemInfo(filepath.Join(t.TempDir(), "missing"), false)
+	assert.Empty(t, fsInfo.Mounts)
+	assert.Len(t, fsInfo.Errors, 1)
+}
+
+func TestGoHttpServerFilesystemInfoHandler(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/info/filesystem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var fsInfo FilesystemInfo
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&fsInfo), "the output should be a valid json")
+}
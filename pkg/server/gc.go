@@ -0,0 +1,196 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	maxGcPercent       = 10000
+	maxHeapBallastMb   = 16384
+	gcMemoryLimitUnset = -1 // memory limit reported when there is none
+)
+
+// GcReport gives the parameters of the garbage collector and the state of the heap
+type GcReport struct {
+	GoGC             int     `json:"gogc"`                     // heap growth in percent triggering a gc, -1 when the gc is off
+	MemoryLimitBytes int64   `json:"memory_limit_bytes"`       // soft memory limit of the runtime, -1 when there is none
+	EnvGoGC          string  `json:"env_gogc,omitempty"`       // GOGC env variable read at startup
+	EnvGoMemLimit    string  `json:"env_gomemlimit,omitempty"` // GOMEMLIMIT env variable read at startup
+	BallastBytes     int     `json:"ballast_bytes"`            // size of the heap ballast
+	HeapAllocBytes   uint64  `json:"heap_alloc_bytes"`
+	NextGCBytes      uint64  `json:"next_gc_bytes"` // heap size triggering the next gc
+	NumGC            uint32  `json:"num_gc"`
+	NumForcedGC      uint32  `json:"num_forced_gc"`
+	LastGC           string  `json:"last_gc,omitempty"` // time the last gc finished, RFC3339
+	GCCPUFraction    float64 `json:"gc_cpu_fraction"`
+}
+
+// GcTuner changes the parameters of the garbage collector while running and keeps the heap ballast, a big
+// allocation never touched which only counts in the heap size and so delays the gc cycles of a small heap.
+// GOMEMLIMIT is usually the better tool, the ballast is there to compare both
+type GcTuner struct {
+	mu      sync.Mutex
+	ballast []byte
+}
+
+// NewGcTuner is a constructor for a GcTuner starting with a ballast of ballastMb megabytes
+func NewGcTuner(ballastMb int) *GcTuner {
+	t := &GcTuner{}
+	t.SetBallast(ballastMb)
+	return t
+}
+
+// SetBallast replaces the heap ballast by one of mb megabytes, 0 to remove it
+func (t *GcTuner) SetBallast(mb int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ballast = nil
+	if mb > 0 {
+		// the pages are never written, so the ballast uses virtual memory only and not the memory of the container
+		t.ballast = make([]byte, mb<<20)
+	}
+}
+
+// Report returns the current parameters of the garbage collector
+func (t *GcTuner) Report() GcReport {
+	samples := []metrics.Sample{{Name: "/gc/gogc:percent"}}
+	metrics.Read(samples)
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	t.mu.Lock()
+	report := GcReport{BallastBytes: len(t.ballast)}
+	t.mu.Unlock()
+	report.GoGC = gcPercentFromMetric(samples[0].Value)
+	report.MemoryLimitBytes = debug.SetMemoryLimit(-1) // a negative limit only reads it
+	if report.MemoryLimitBytes == math.MaxInt64 {
+		report.MemoryLimitBytes = gcMemoryLimitUnset
+	}
+	report.EnvGoGC = os.Getenv("GOGC")
+	report.EnvGoMemLimit = os.Getenv("GOMEMLIMIT")
+	report.HeapAllocBytes = m.HeapAlloc
+	report.NextGCBytes = m.NextGC
+	report.NumGC = m.NumGC
+	report.NumForcedGC = m.NumForcedGC
+	report.GCCPUFraction = m.GCCPUFraction
+	if m.LastGC > 0 {
+		report.LastGC = time.Unix(0, int64(m.LastGC)).Format(time.RFC3339)
+	}
+	return report
+}
+
+// gcPercentFromMetric returns the value of /gc/gogc:percent, -1 when the runtime does not report it
+func gcPercentFromMetric(v metrics.Value) int {
+	if v.Kind() != metrics.KindUint64 {
+		return -1
+	}
+	return int(int64(v.Uint64()))
+}
+
+// GcUpdate are the changes asked to POST /admin/gc, nil fields are left unchanged
+type GcUpdate struct {
+	GoGC             *int
+	MemoryLimitBytes *int64 // -1 to remove the limit
+	BallastMb        *int
+	RunGC            bool
+}
+
+// Apply changes the parameters of the garbage collector
+func (t *GcTuner) Apply(u GcUpdate) {
+	if u.GoGC != nil {
+		debug.SetGCPercent(*u.GoGC)
+	}
+	if u.MemoryLimitBytes != nil {
+		limit := *u.MemoryLimitBytes
+		if limit < 0 {
+			limit = math.MaxInt64
+		}
+		debug.SetMemoryLimit(limit)
+	}
+	if u.BallastMb != nil {
+		t.SetBallast(*u.BallastMb)
+	}
+	if u.RunGC {
+		runtime.GC()
+	}
+}
+
+// parseGcUpdate returns the changes given by the parameters gogc, memory_limit_mb, ballast_mb and run of r.
+// gogc and memory_limit_mb accept off like the env variables
+func parseGcUpdate(r *http.Request) (GcUpdate, error) {
+	var u GcUpdate
+	query := r.URL.Query()
+	if val := query.Get("gogc"); val != "" {
+		gogc := -1
+		if val != "off" {
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 || n > maxGcPercent {
+				return u, fmt.Errorf("parameter gogc should be off or an integer between 1 and %d", maxGcPercent)
+			}
+			gogc = n
+		}
+		u.GoGC = &gogc
+	}
+	if val := query.Get("memory_limit_mb"); val != "" {
+		limit := int64(gcMemoryLimitUnset)
+		if val != "off" {
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil || n < 1 || n > math.MaxInt64>>20 {
+				return u, fmt.Errorf("parameter memory_limit_mb should be off or an integer greater than 0")
+			}
+			limit = n << 20
+		}
+		u.MemoryLimitBytes = &limit
+	}
+	if query.Get("ballast_mb") != "" {
+		mb, err := parseIntParam(r, "ballast_mb", 0, 0, maxHeapBallastMb)
+		if err != nil {
+			return u, err
+		}
+		u.BallastMb = &mb
+	}
+	u.RunGC = query.Get("run") == "true"
+	if u.GoGC == nil && u.MemoryLimitBytes == nil && u.BallastMb == nil && !u.RunGC {
+		return u, fmt.Errorf("one of the parameters gogc, memory_limit_mb, ballast_mb or run=true is needed")
+	}
+	return u, nil
+}
+
+//############# BEGIN GC HANDLERS
+
+// GcHandler returns the parameters of the garbage collector, the GOGC and GOMEMLIMIT env variables and the ballast
+func (s *GoHttpServer) GcHandler(tuner *GcTuner) http.HandlerFunc {
+	handlerName := "GcHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, r, http.StatusOK, tuner.Report())
+	}
+}
+
+// AdminGcHandler changes GOGC, GOMEMLIMIT or the heap ballast while running, or runs a gc
+func (s *GoHttpServer) AdminGcHandler(tuner *GcTuner) http.HandlerFunc {
+	handlerName := "AdminGcHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		update, err := parseGcUpdate(r)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		tuner.Apply(update)
+		report := tuner.Report()
+		s.audit("gc parameters changed", r, "gogc", report.GoGC, "memory_limit_bytes", report.MemoryLimitBytes,
+			"ballast_bytes", report.BallastBytes, "run_gc", update.RunGC)
+		s.render(w, r, http.StatusOK, report)
+	}
+}
+
+// ############# END GC HANDLERS
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"runtime/debug"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGcUpdate(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+	int64Ptr := func(i int64) *int64 { return &i }
+	tests := []struct {
+		name      string
+		query     string
+		want      GcUpdate
+		wantError bool
+	}{
+		{name: "1: no parameter should be an error", wantError: true},
+		{name: "2: gogc should be parsed", query: "gogc=200", want: GcUpdate{GoGC: intPtr(200)}},
+		{name: "3: gogc off should disable the gc", query: "gogc=off", want: GcUpdate{GoGC: intPtr(-1)}},
+		{name: "4: memory limit should be converted in bytes", query: "memory_limit_mb=512&run=true",
+			want: GcUpdate{MemoryLimitBytes: int64Ptr(512 << 20), RunGC: true}},
+		{name: "5: memory limit off should remove the limit", query: "memory_limit_mb=off", want: GcUpdate{MemoryLimitBytes: int64Ptr(-1)}},
+		{name: "6: ballast should be parsed", query: "ballast_mb=0", want: GcUpdate{BallastMb: intPtr(0)}},
+		{name: "7: negative gogc should be an error", query: "gogc=-5", wantError: true},
+		{name: "8: too big ballast should be an error", query: "ballast_mb=100000", wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGcUpdate(httptest.NewRequest(http.MethodPost, "/admin/gc?"+tt.query, nil))
+			assert.Equal(t, tt.wantError, err != nil)
+			if err == nil {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGcTuner(t *testing.T) {
+	initialPercent := debug.SetGCPercent(100)
+	initialLimit := debug.SetMemoryLimit(-1)
+	defer func() {
+		debug.SetGCPercent(initialPercent)
+		debug.SetMemoryLimit(initialLimit)
+	}()
+	tuner := NewGcTuner(1)
+	assert.Equal(t, 1<<20, tuner.Report().BallastBytes)
+
+	gogc, limit, ballast := 250, int64(256<<20), 0
+	tuner.Apply(GcUpdate{GoGC: &gogc, MemoryLimitBytes: &limit, BallastMb: &ballast, RunGC: true})
+	report := tuner.Report()
+	assert.Equal(t, 250, report.GoGC)
+	assert.Equal(t, int64(256<<20), report.MemoryLimitBytes)
+	assert.Equal(t, 0, report.BallastBytes)
+	assert.Greater(t, report.NumForcedGC, uint32(0))
+
+	unset := int64(-1)
+	tuner.Apply(GcUpdate{MemoryLimitBytes: &unset})
+	assert.Equal(t, int64(gcMemoryLimitUnset), tuner.Report().MemoryLimitBytes)
+	assert.Equal(t, int64(math.MaxInt64), debug.SetMemoryLimit(-1))
+}
+
+func TestGoHttpServerAdminGcHandler(t *testing.T) {
+	initialPercent := debug.SetGCPercent(100)
+	defer debug.SetGCPercent(initialPercent)
+	myServer := &GoHttpServer{logger: getTestLogger(), settings: NewConfigReloader(config.DefaultConfig(), getTestLogger())}
+	handler := myServer.AdminGcHandler(NewGcTuner(0))
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantGoGC   int
+	}{
+		{name: "1: valid gogc should be applied", query: "gogc=150", wantStatus: http.StatusOK, wantGoGC: 150},
+		{name: "2: invalid gogc should be a bad request", query: "gogc=many", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodPost, "/admin/gc?"+tt.query, nil))
+			assert.Equal(t, tt.wantStatus, rec.Code, assertCorrectStatusCodeExpected)
+			if tt.wantStatus == http.StatusOK {
+				var report GcReport
+				assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &report))
+				assert.Equal(t, tt.wantGoGC, report.GoGC)
+			}
+		})
+	}
+}
+
+func TestGoHttpServerAdminGcCredentials(t *testing.T) {
+	initialPercent := debug.SetGCPercent(100)
+	defer debug.SetGCPercent(initialPercent)
+	tests := []struct {
+		name       string
+		apiToken   string
+		bearer     string
+		adminPort  bool
+		wantStatus int
+		wantGoGC   int
+	}{
+		{name: "1: anonymous POST in the default config should be forbidden", wantStatus: http.StatusForbidden, wantGoGC: 100},
+		{name: "2: anonymous POST with API_TOKEN should be unauthorized", apiToken: "s3cret", wantStatus: http.StatusUnauthorized, wantGoGC: 100},
+		{name: "3: POST with the API_TOKEN bearer should be applied", apiToken: "s3cret", bearer: "s3cret", wantStatus: http.StatusOK, wantGoGC: 150},
+		{name: "4: anonymous POST on ADMIN_PORT should be forbidden", adminPort: true, wantStatus: http.StatusForbidden, wantGoGC: 100},
+		{name: "5: POST with the API_TOKEN bearer on ADMIN_PORT should be applied", apiToken: "s3cret", bearer: "s3cret", adminPort: true, wantStatus: http.StatusOK, wantGoGC: 150},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			debug.SetGCPercent(100)
+			t.Setenv("API_TOKEN", tt.apiToken)
+			if tt.adminPort {
+				t.Setenv("ADMIN_PORT", "8081")
+			}
+			myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+			var handler http.Handler = myServer.router
+			if tt.adminPort {
+				handler = myServer.adminServer.Handler
+			}
+			req := httptest.NewRequest(http.MethodPost, "/admin/gc?gogc=150", nil)
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code, assertCorrectStatusCodeExpected)
+			assert.Equal(t, tt.wantGoGC, debug.SetGCPercent(100), "GOGC should only change with credentials")
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultGenerateBytes = 1 << 20
+	maxGenerateBytes     = 1 << 30
+	generateChunkBytes   = 32 << 10 // bytes written between two flushes of a chunked or throttled answer
+	generateTypeJson     = "json"
+	generateTypeText     = "text"
+	generateTypeBinary   = "binary"
+	MIMEAppOctetStream   = "application/octet-stream"
+)
+
+// generateLine is repeated in the text payloads and in the string of the json ones, it is 64 bytes long
+const generateLine = "go-cloud-k8s-info synthetic payload 0123456789 abcdefghijklmnop\n"
+
+// Payload is a synthetic answer of /generate of an exact size
+type Payload struct {
+	Type        string
+	ContentType string
+	Size        int64
+	body        io.Reader
+}
+
+// NewPayload returns a payload of size bytes of the kind payloadType :
+//
+//	json : a valid json object, its data string is padded up to the size
+//	text : lines of 64 printable characters, the last one is cut at the size
+//	binary : random bytes, so that a compressing proxy does not shrink them
+func NewPayload(payloadType string, size int64) (*Payload, error) {
+	switch payloadType {
+	case generateTypeJson:
+		head := fmt.Sprintf(`{"type":"json","size":%d,"data":"`, size)
+		tail := `"}`
+		if size < int64(len(head)+len(tail)) {
+			return nil, fmt.Errorf("a json payload needs at least %d bytes", len(head)+len(tail))
+		}
+		// the newline of the line would be escaped in the json string, a space keeps the length of the block
+		block := []byte(strings.ReplaceAll(generateLine, "\n", " "))
+		padding := &repeatReader{block: block, remaining: size - int64(len(head)+len(tail))}
+		return &Payload{Type: payloadType, ContentType: MIMEAppJSONCharsetUTF8, Size: size,
+			body: io.MultiReader(strings.NewReader(head), padding, strings.NewReader(tail))}, nil
+	case generateTypeText:
+		return &Payload{Type: payloadType, ContentType: "text/plain; " + charsetUTF8, Size: size,
+			body: &repeatReader{block: []byte(generateLine), remaining: size}}, nil
+	case generateTypeBinary:
+		block := make([]byte, generateChunkBytes)
+		if _, err := rand.Read(block); err != nil {
+			return nil, err
+		}
+		return &Payload{Type: payloadType, ContentType: MIMEAppOctetStream, Size: size,
+			body: &repeatReader{block: block, remaining: size}}, nil
+	}
+	return nil, fmt.Errorf("unknown payload type %q, it should be json, text or binary", payloadType)
+}
+
+// WriteTo sends the payload to w in chunks, calling flush after each one when it is not nil, and keeping the
+// throughput under rate bytes per second when rate is above 0. it returns the bytes written
+func (p *Payload) WriteTo(ctx context.Context, w io.Writer, flush func() error, rate int64) (int64, error) {
+	buf := make([]byte, generateChunkBytes)
+	start := time.Now()
+	var written int64
+	for {
+		n, err := io.ReadFull(p.body, buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return written, err
+			}
+			written += int64(n)
+			if flush != nil {
+				if err := flush(); err != nil {
+					return written, err
+				}
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+		if rate > 0 {
+			// the time at which the bytes written so far are allowed by the rate
+			due := start.Add(time.Duration(float64(written) / float64(rate) * float64(time.Second)))
+			select {
+			case <-ctx.Done():
+				return written, ctx.Err()
+			case <-time.After(time.Until(due)):
+			}
+		}
+	}
+}
+
+//############# BEGIN INFO HANDLERS
+
+// GenerateHandler answers with a synthetic payload of the size parameter bytes (like 1M) of the type parameter,
+// with a chunked transfer when the chunked parameter is true and at the rate parameter bytes per second, to test the
+// buffer limits and body size policies of the ingress and proxies. the payload is never compressed by COMPRESSION,
+// so the bytes on the wire are the size asked
+func (s *GoHttpServer) GenerateHandler() http.HandlerFunc {
+	handlerName := "GenerateHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		size := int64(defaultGenerateBytes)
+		if val := query.Get("size"); val != "" {
+			var err error
+			size, err = parseByteSize(val)
+			if err != nil || size > maxGenerateBytes {
+				http.Error(w, fmt.Sprintf("ERROR: parameter size should be a size like 1M between 0 and %d bytes", maxGenerateBytes), http.StatusBadRequest)
+				return
+			}
+		}
+		payloadType := strings.ToLower(query.Get("type"))
+		if payloadType == "" {
+			payloadType = generateTypeJson
+		}
+		chunked := false
+		if val := query.Get("chunked"); val != "" {
+			var err error
+			if chunked, err = strconv.ParseBool(val); err != nil {
+				http.Error(w, "ERROR: parameter chunked should be true or false", http.StatusBadRequest)
+				return
+			}
+		}
+		var rate int64
+		if val := query.Get("rate"); val != "" {
+			var err error
+			rate, err = parseByteSize(val)
+			if err != nil || rate <= 0 {
+				http.Error(w, "ERROR: parameter rate should be a number of bytes per second like 100K", http.StatusBadRequest)
+				return
+			}
+		}
+		duration := time.Duration(0)
+		if rate > 0 {
+			duration = time.Duration(float64(size) / float64(rate) * float64(time.Second))
+			if duration > maxLoadDuration {
+				http.Error(w, fmt.Sprintf("ERROR: sending %d bytes at the rate of %d bytes per second would take more than %v", size, rate, maxLoadDuration), http.StatusBadRequest)
+				return
+			}
+		}
+		payload, err := NewPayload(payloadType, size)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		extendWriteDeadline(w, duration)
+		noCompression(w)
+		var flush func() error
+		w.Header().Set(HeaderContentType, payload.ContentType)
+		w.Header().Set("Cache-Control", "no-store")
+		if chunked {
+			// without Content-Length and with a flush after each chunk, http/1.1 sends a chunked transfer encoding
+			flush = http.NewResponseController(w).Flush
+			w.Header().Set("X-Accel-Buffering", "no")
+		} else {
+			w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodHead {
+			return
+		}
+		written, err := payload.WriteTo(r.Context(), w, flush, rate)
+		if err != nil {
+			s.logger.DebugContext(r.Context(), "payload interrupted", "handler", handlerName, "type", payloadType, "size", size, "written", written, "error", err)
+		}
+	}
+}
+
+// ############# END INFO HANDLERS
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPayload(t *testing.T) {
+	tests := []struct {
+		name        string
+		payloadType string
+		size        int64
+		wantType    string
+		wantErr     bool
+	}{
+		{name: "1: a json payload should be a valid json object of the size", payloadType: "json", size: 100000, wantType: MIMEAppJSONCharsetUTF8},
+		{name: "2: the smallest json payload should be valid", payloadType: "json", size: 36, wantType: MIMEAppJSONCharsetUTF8},
+		{name: "3: a json payload below its envelope should be an error", payloadType: "json", size: 10, wantErr: true},
+		{name: "4: a text payload should be cut at the size", payloadType: "text", size: 100, wantType: "text/plain; " + charsetUTF8},
+		{name: "5: a binary payload should have the size", payloadType: "binary", size: 100001, wantType: MIMEAppOctetStream},
+		{name: "6: an empty binary payload should be allowed", payloadType: "binary", size: 0, wantType: MIMEAppOctetStream},
+		{name: "7: an unknown type should be an error", payloadType: "xml", size: 100, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := NewPayload(tt.payloadType, tt.size)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantType, payload.ContentType)
+			var buf bytes.Buffer
+			written, err := payload.WriteTo(context.Background(), &buf, nil, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.size, written)
+			assert.Equal(t, tt.size, int64(buf.Len()))
+			switch tt.payloadType {
+			case "json":
+				var body struct {
+					Type string `json:"type"`
+					Size int64  `json:"size"`
+					Data string `json:"data"`
+				}
+				assert.NoError(t, json.Unmarshal(buf.Bytes(), &body), "the payload should be valid json")
+				assert.Equal(t, tt.size, body.Size)
+			case "text":
+				assert.Equal(t, generateLine+generateLine[:36], buf.String())
+			}
+		})
+	}
+}
+
+func TestPayloadWriteToRate(t *testing.T) {
+	payload, err := NewPayload("text", 3*generateChunkBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flushes := 0
+	start := time.Now()
+	written, err := payload.WriteTo(context.Background(), io.Discard, func() error { flushes++; return nil }, 20*generateChunkBytes)
+	elapsed := time.Since(start)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3*generateChunkBytes), written)
+	assert.Equal(t, 3, flushes, "each chunk should be flushed")
+	assert.GreaterOrEqual(t, elapsed, 100*time.Millisecond, "3 chunks at 20 chunks per second should take at least 150ms, minus the first one")
+
+	payload, _ = NewPayload("text", 3*generateChunkBytes)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	written, err = payload.WriteTo(ctx, io.Discard, nil, 1)
+	assert.ErrorIs(t, err, context.Canceled, "a client leaving should stop the payload")
+	assert.Equal(t, int64(generateChunkBytes), written)
+}
+
+func TestGoHttpServerGenerateHandler(t *testing.T) {
+	ts := httptest.NewServer(NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger()).router)
+	defer ts.Close()
+	tests := []struct {
+		name        string
+		query       string
+		wantStatus  int
+		wantBytes   int64
+		wantType    string
+		wantChunked bool
+	}{
+		{name: "1: the default payload should be 1M of json", query: "", wantStatus: http.StatusOK, wantBytes: 1 << 20, wantType: MIMEAppJSONCharsetUTF8},
+		{name: "2: a text payload should have a Content-Length", query: "?size=4K&type=text", wantStatus: http.StatusOK, wantBytes: 4096, wantType: "text/plain; " + charsetUTF8},
+		{name: "3: a chunked payload should have no Content-Length", query: "?size=200K&type=binary&chunked=true", wantStatus: http.StatusOK, wantBytes: 200 << 10,
+			wantType: MIMEAppOctetStream, wantChunked: true},
+		{name: "4: a throttled payload should be sent", query: "?size=64K&type=binary&rate=1M", wantStatus: http.StatusOK, wantBytes: 64 << 10, wantType: MIMEAppOctetStream},
+		{name: "5: a size above 1G should be refused", query: "?size=2G", wantStatus: http.StatusBadRequest},
+		{name: "6: an unknown type should be refused", query: "?type=xml", wantStatus: http.StatusBadRequest},
+		{name: "7: an invalid chunked should be refused", query: "?chunked=maybe", wantStatus: http.StatusBadRequest},
+		{name: "8: a rate too slow for the size should be refused", query: "?size=1G&rate=1K", wantStatus: http.StatusBadRequest},
+		{name: "9: an invalid rate should be refused", query: "?rate=fast", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + "/generate" + tt.query)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			if !assert.Equal(t, tt.wantStatus, resp.StatusCode, string(body)) || tt.wantStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.wantType, resp.Header.Get(HeaderContentType))
+			assert.Equal(t, tt.wantBytes, int64(len(body)))
+			if tt.wantChunked {
+				assert.Equal(t, int64(-1), resp.ContentLength)
+				assert.Equal(t, []string{"chunked"}, resp.TransferEncoding)
+			} else {
+				assert.Equal(t, tt.wantBytes, resp.ContentLength)
+			}
+			if tt.wantType == MIMEAppJSONCharsetUTF8 {
+				assert.True(t, json.Valid(body), "the payload should be valid json")
+				assert.True(t, strings.HasPrefix(string(body), `{"type":"json","size":1048576,`))
+			}
+		})
+	}
+}
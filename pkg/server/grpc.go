@@ -0,0 +1,255 @@
+package server
+
+//go:generate protoc -I ../../proto --go_out=../../proto --go_opt=paths=source_relative --go-grpc_out=../../proto --go-grpc_opt=paths=source_relative goinfo/v1/info.proto
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	goinfov1 "github.com/lao-tseu-is-alive/go-cloud-k8s-info/proto/goinfo/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	grpcInfoService         = "goinfo.v1.InfoService"
+	grpcHealthService       = "grpc.health.v1.Health"
+	grpcHealthWatchInterval = time.Second // how often the readiness checks update the status of the health service
+)
+
+// GrpcServer serves the InfoService generated from proto/goinfo/v1/info.proto, next to the standard health
+// service and the server reflection of grpc-go
+type GrpcServer struct {
+	goinfov1.UnimplementedInfoServiceServer
+	s        *GoHttpServer
+	server   *grpc.Server
+	health   *health.Server
+	hostname string
+}
+
+// (*GoHttpServer) newGrpcServer registers the services on a grpc server created with opts, the InfoService
+// needs the credentials of AUTH_MODE or API_TOKEN while the health and reflection services stay public for the probes
+func (s *GoHttpServer) newGrpcServer(opts ...grpc.ServerOption) *GrpcServer {
+	hostname, _ := os.Hostname()
+	g := &GrpcServer{s: s, health: health.NewServer(), hostname: hostname}
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(g.unaryInterceptor),
+		grpc.ChainStreamInterceptor(g.streamInterceptor),
+		grpc.KeepaliveParams(keepalive.ServerParameters{MaxConnectionIdle: config.DefaultIdleTimeout}),
+	)
+	g.server = grpc.NewServer(opts...)
+	goinfov1.RegisterInfoServiceServer(g.server, g)
+	healthgrpc.RegisterHealthServer(g.server, g.health)
+	reflection.Register(g.server)
+	g.health.SetServingStatus(grpcHealthService, healthgrpc.HealthCheckResponse_SERVING)
+	return g
+}
+
+// startGrpcServer starts the grpc listener in his own goroutine, with the certificates of the main server if any
+func (s *GoHttpServer) startGrpcServer(ctx context.Context) {
+	var opts []grpc.ServerOption
+	if s.certs != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: s.certs.GetCertificate})))
+	}
+	ln, err := net.Listen("tcp", s.grpcAddress)
+	if err != nil {
+		s.logger.Error("grpc server stopped", "address", s.grpcAddress, "error", err)
+		return
+	}
+	s.grpcServer = s.newGrpcServer(opts...)
+	s.logger.Info("Starting grpc server", "address", s.grpcAddress, "tls", s.certs != nil)
+	go func() {
+		if err := s.grpcServer.Serve(ctx, ln); err != nil {
+			s.logger.Error("grpc server stopped", "address", s.grpcAddress, "error", err)
+		}
+	}()
+}
+
+// Serve answers the grpc calls of ln until Stop, the status of the health service follows /readiness until ctx is done
+func (g *GrpcServer) Serve(ctx context.Context, ln net.Listener) error {
+	g.updateHealth(ctx)
+	go g.watchHealth(ctx)
+	return g.server.Serve(ln)
+}
+
+// Stop closes the listener and all the connections, the running calls are canceled
+func (g *GrpcServer) Stop() {
+	g.health.Shutdown()
+	g.server.Stop()
+}
+
+// updateHealth sets the status of the whole server and of the InfoService from /readiness, so that they are
+// NOT_SERVING while draining
+func (g *GrpcServer) updateHealth(ctx context.Context) {
+	servingStatus := healthgrpc.HealthCheckResponse_NOT_SERVING
+	if g.s.readiness.Run(ctx).Status == readinessStatusReady {
+		servingStatus = healthgrpc.HealthCheckResponse_SERVING
+	}
+	g.health.SetServingStatus("", servingStatus)
+	g.health.SetServingStatus(grpcInfoService, servingStatus)
+}
+
+// watchHealth runs updateHealth every grpcHealthWatchInterval until ctx is done, Health/Watch sends the changes
+func (g *GrpcServer) watchHealth(ctx context.Context) {
+	ticker := time.NewTicker(grpcHealthWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			g.updateHealth(ctx)
+		}
+	}
+}
+
+// authorize refuses the calls of the InfoService without the credentials of AUTH_MODE or the API_TOKEN bearer in the
+// authorization metadata, when one of them protects the routes
+func (g *GrpcServer) authorize(ctx context.Context, method string) error {
+	if !strings.HasPrefix(method, "/"+grpcInfoService+"/") {
+		return nil
+	}
+	r, _ := http.NewRequestWithContext(ctx, http.MethodPost, method, nil)
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, auth := range md.Get("authorization") {
+		r.Header.Add("Authorization", auth)
+	}
+	if !g.s.authorizedRequest(r) {
+		return status.Error(codes.Unauthenticated, "missing or invalid credentials")
+	}
+	return nil
+}
+
+// logCall logs the method, the status code and the duration of a finished call
+func (g *GrpcServer) logCall(ctx context.Context, method string, start time.Time, err error) {
+	g.s.logger.InfoContext(ctx, "grpc call", "method", method, "code", status.Code(err).String(),
+		"remote_addr", remoteAddr(ctx), "duration", time.Since(start).String())
+}
+
+func (g *GrpcServer) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	var resp any
+	err := g.authorize(ctx, info.FullMethod)
+	if err == nil {
+		resp, err = handler(ctx, req)
+	}
+	g.logCall(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+func (g *GrpcServer) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := g.authorize(ss.Context(), info.FullMethod)
+	if err == nil {
+		err = handler(srv, ss)
+	}
+	g.logCall(ss.Context(), info.FullMethod, start, err)
+	return err
+}
+
+// remoteAddr returns the address of the client of the call
+func remoteAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// GetRuntimeInfo answers goinfo.v1.InfoService/GetRuntimeInfo with the main fields of the json of /
+func (g *GrpcServer) GetRuntimeInfo(ctx context.Context, _ *goinfov1.GetRuntimeInfoRequest) (*goinfov1.RuntimeInfo, error) {
+	build := info.GetBuildInfo()
+	return &goinfov1.RuntimeInfo{
+		Hostname:     g.hostname,
+		Pid:          int32(os.Getpid()),
+		Appname:      info.APP,
+		Version:      build.Version,
+		Revision:     build.Revision,
+		BuildDate:    build.BuildDate,
+		Goos:         runtime.GOOS,
+		Goarch:       runtime.GOARCH,
+		Runtime:      runtime.Version(),
+		NumGoroutine: int32(runtime.NumGoroutine()),
+		NumCpu:       int32(runtime.NumCPU()),
+		Gomaxprocs:   int32(runtime.GOMAXPROCS(0)),
+		Uptime:       time.Since(g.s.startTime).Round(time.Second).String(),
+		RemoteAddr:   remoteAddr(ctx),
+	}, nil
+}
+
+// checkResults converts the checks of a report to their protobuf messages
+func checkResults(checks []CheckResult) []*goinfov1.CheckResult {
+	results := make([]*goinfov1.CheckResult, 0, len(checks))
+	for _, c := range checks {
+		results = append(results, &goinfov1.CheckResult{Name: c.Name, Type: c.Type, Status: c.Status, Error: c.Error, DurationMs: c.DurationMs})
+	}
+	return results
+}
+
+// GetHealth answers goinfo.v1.InfoService/GetHealth with the reports of /health and /readiness
+func (g *GrpcServer) GetHealth(ctx context.Context, _ *goinfov1.GetHealthRequest) (*goinfov1.HealthReport, error) {
+	health := g.s.healthReport(ctx)
+	readiness := g.s.readiness.Run(ctx)
+	return &goinfov1.HealthReport{
+		Status:          health.Status,
+		Checks:          checkResults(health.Checks),
+		Readiness:       readiness.Status,
+		ReadinessChecks: checkResults(readiness.Checks),
+	}, nil
+}
+
+// StreamStats answers goinfo.v1.InfoService/StreamStats with a LiveStats every interval_seconds, 2 by default,
+// until the client cancels the call or its deadline is exceeded
+func (g *GrpcServer) StreamStats(req *goinfov1.StreamStatsRequest, stream grpc.ServerStreamingServer[goinfov1.LiveStats]) error {
+	interval := defaultStatsInterval
+	if seconds := req.GetIntervalSeconds(); seconds != 0 {
+		if seconds > maxStatsInterval {
+			return status.Errorf(codes.InvalidArgument, "interval_seconds should be between 1 and %d", maxStatsInterval)
+		}
+		interval = time.Duration(seconds) * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		stats := g.s.liveStats(g.hostname)
+		msg := &goinfov1.LiveStats{
+			Time:           stats.Time,
+			Hostname:       stats.Hostname,
+			Uptime:         stats.Uptime,
+			NumGoroutine:   int32(stats.NumGoroutine),
+			NumCpu:         int32(stats.NumCPU),
+			Gomaxprocs:     int32(stats.GoMaxProcs),
+			HeapAllocBytes: stats.Memory.HeapAllocBytes,
+			HeapSysBytes:   stats.Memory.HeapSysBytes,
+			SysBytes:       stats.Memory.SysBytes,
+			NumGc:          stats.Memory.NumGC,
+		}
+		if stats.Memory.Cgroup != nil {
+			msg.CgroupMemoryLimitBytes = stats.Memory.Cgroup.LimitBytes
+			msg.CgroupMemoryUsageBytes = stats.Memory.Cgroup.UsageBytes
+		}
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+		select {
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case <-ticker.C:
+		}
+	}
+}
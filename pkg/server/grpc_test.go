@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	goinfov1 "github.com/lao-tseu-is-alive/go-cloud-k8s-info/proto/goinfo/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+)
+
+// startGrpcTestServer serves the grpc services of myServer on an in memory listener and returns a client connection
+func startGrpcTestServer(t *testing.T, myServer *GoHttpServer) *grpc.ClientConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	ln := bufconn.Listen(1 << 20)
+	g := myServer.newGrpcServer()
+	go g.Serve(ctx, ln)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		g.Stop()
+		cancel()
+	})
+	return conn
+}
+
+func TestGrpcServerInfoService(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	myServer.UseReadinessChecks(&FileCheck{CheckName: "missing", Path: "/nonexistent/go-info"})
+	conn := startGrpcTestServer(t, myServer)
+	client := goinfov1.NewInfoServiceClient(conn)
+	ctx := context.Background()
+	hostname, _ := os.Hostname()
+
+	ri, err := client.GetRuntimeInfo(ctx, &goinfov1.GetRuntimeInfoRequest{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, hostname, ri.GetHostname())
+		assert.Equal(t, int32(os.Getpid()), ri.GetPid())
+		assert.Equal(t, info.APP, ri.GetAppname())
+		assert.Equal(t, info.VERSION, ri.GetVersion())
+		assert.Equal(t, runtime.GOOS, ri.GetGoos())
+		assert.Equal(t, runtime.Version(), ri.GetRuntime())
+		assert.Equal(t, int32(runtime.NumCPU()), ri.GetNumCpu())
+		assert.NotEmpty(t, ri.GetRemoteAddr())
+	}
+
+	report, err := client.GetHealth(ctx, &goinfov1.GetHealthRequest{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, livenessStatusAlive, report.GetStatus())
+		assert.Equal(t, readinessStatusNotReady, report.GetReadiness())
+		if assert.Len(t, report.GetReadinessChecks(), 1) {
+			check := report.GetReadinessChecks()[0]
+			assert.Equal(t, "missing", check.GetName())
+			assert.Equal(t, "file", check.GetType())
+			assert.Equal(t, checkStatusDown, check.GetStatus())
+			assert.Contains(t, check.GetError(), "/nonexistent/go-info")
+		}
+	}
+
+	streamCtx, cancel := context.WithTimeout(ctx, 1500*time.Millisecond)
+	defer cancel()
+	stream, err := client.StreamStats(streamCtx, &goinfov1.StreamStatsRequest{IntervalSeconds: 1})
+	if assert.NoError(t, err) {
+		var stats []*goinfov1.LiveStats
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				assert.Equal(t, codes.DeadlineExceeded, status.Code(err), "the stream should end with the deadline of the client")
+				break
+			}
+			stats = append(stats, msg)
+		}
+		if assert.Len(t, stats, 2, "the stats should keep coming every interval") {
+			_, err := time.Parse(time.RFC3339, stats[0].GetTime())
+			assert.NoError(t, err)
+			assert.Equal(t, hostname, stats[0].GetHostname())
+			assert.Greater(t, stats[0].GetNumGoroutine(), int32(0))
+			assert.Greater(t, stats[0].GetHeapAllocBytes(), uint64(0))
+		}
+	}
+
+	stream, err = client.StreamStats(ctx, &goinfov1.StreamStatsRequest{IntervalSeconds: maxStatsInterval + 1})
+	if assert.NoError(t, err) {
+		_, err = stream.Recv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), "an interval too long should be an invalid argument")
+	}
+
+	err = conn.Invoke(ctx, "/"+grpcInfoService+"/Unknown", &goinfov1.GetHealthRequest{}, &goinfov1.HealthReport{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestGrpcServerAuthentication(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	myServer.UseAuth(AuthConfig{Mode: authModeBearer, Token: "secret"})
+	conn := startGrpcTestServer(t, myServer)
+	client := goinfov1.NewInfoServiceClient(conn)
+	ctx := context.Background()
+
+	_, err := client.GetRuntimeInfo(ctx, &goinfov1.GetRuntimeInfoRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "the InfoService should need the credentials")
+	stream, err := client.StreamStats(ctx, &goinfov1.StreamStatsRequest{})
+	if assert.NoError(t, err) {
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Unauthenticated, status.Code(err), "the streams of the InfoService should need the credentials too")
+	}
+	_, err = client.GetRuntimeInfo(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret"), &goinfov1.GetRuntimeInfoRequest{})
+	assert.NoError(t, err)
+	_, err = healthgrpc.NewHealthClient(conn).Check(ctx, &healthgrpc.HealthCheckRequest{})
+	assert.NoError(t, err, "the health service should stay public for the probes")
+}
+
+func TestGrpcServerApiToken(t *testing.T) {
+	const apiToken = "a-very-secret-api-token"
+	ctx := context.Background()
+	withToken := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+apiToken)
+
+	t.Run("AUTH_MODE none", func(t *testing.T) {
+		myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+		myServer.apiToken = apiToken
+		client := goinfov1.NewInfoServiceClient(startGrpcTestServer(t, myServer))
+		_, err := client.GetRuntimeInfo(ctx, &goinfov1.GetRuntimeInfoRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err), "API_TOKEN alone should protect the InfoService")
+		_, err = client.GetHealth(ctx, &goinfov1.GetHealthRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		stream, err := client.StreamStats(ctx, &goinfov1.StreamStatsRequest{})
+		if assert.NoError(t, err) {
+			_, err = stream.Recv()
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		}
+		_, err = client.GetRuntimeInfo(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer wrong"), &goinfov1.GetRuntimeInfoRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		_, err = client.GetRuntimeInfo(withToken, &goinfov1.GetRuntimeInfoRequest{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("AUTH_MODE basic", func(t *testing.T) {
+		myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+		myServer.UseAuth(AuthConfig{Mode: authModeBasic, Username: "admin", Password: "pass"})
+		myServer.apiToken = apiToken
+		client := goinfov1.NewInfoServiceClient(startGrpcTestServer(t, myServer))
+		_, err := client.GetRuntimeInfo(ctx, &goinfov1.GetRuntimeInfoRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		_, err = client.GetRuntimeInfo(withToken, &goinfov1.GetRuntimeInfoRequest{})
+		assert.NoError(t, err, "the API_TOKEN bearer should be accepted next to the basic credentials")
+		basic := metadata.AppendToOutgoingContext(ctx, "authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:pass")))
+		_, err = client.GetRuntimeInfo(basic, &goinfov1.GetRuntimeInfoRequest{})
+		assert.NoError(t, err)
+	})
+}
+
+func TestGrpcServerHealth(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	client := healthgrpc.NewHealthClient(startGrpcTestServer(t, myServer))
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		service    string
+		wantCode   codes.Code
+		wantStatus healthgrpc.HealthCheckResponse_ServingStatus
+	}{
+		{name: "1: the server should follow the readiness", service: "", wantCode: codes.OK, wantStatus: healthgrpc.HealthCheckResponse_SERVING},
+		{name: "2: the info service should follow the readiness", service: grpcInfoService, wantCode: codes.OK, wantStatus: healthgrpc.HealthCheckResponse_SERVING},
+		{name: "3: the health service should serve", service: grpcHealthService, wantCode: codes.OK, wantStatus: healthgrpc.HealthCheckResponse_SERVING},
+		{name: "4: an unknown service should not be found", service: "foo.Bar", wantCode: codes.NotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := client.Check(ctx, &healthgrpc.HealthCheckRequest{Service: tt.service})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantStatus, res.GetStatus())
+		})
+	}
+
+	watchCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond+2*grpcHealthWatchInterval)
+	defer cancel()
+	stream, err := client.Watch(watchCtx, &healthgrpc.HealthCheckRequest{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		myServer.readiness.StartDraining()
+	}()
+	var statuses []healthgrpc.HealthCheckResponse_ServingStatus
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			break
+		}
+		statuses = append(statuses, res.GetStatus())
+	}
+	assert.Equal(t, []healthgrpc.HealthCheckResponse_ServingStatus{healthgrpc.HealthCheckResponse_SERVING, healthgrpc.HealthCheckResponse_NOT_SERVING},
+		statuses, "Watch should send the status then its change, the server should not serve while draining")
+}
+
+func TestGrpcServerReflection(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	client := reflectiongrpc.NewServerReflectionClient(startGrpcTestServer(t, myServer))
+	stream, err := client.ServerReflectionInfo(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+	ask := func(req *reflectiongrpc.ServerReflectionRequest) *reflectiongrpc.ServerReflectionResponse {
+		assert.NoError(t, stream.Send(req))
+		res, err := stream.Recv()
+		assert.NoError(t, err)
+		return res
+	}
+
+	res := ask(&reflectiongrpc.ServerReflectionRequest{MessageRequest: &reflectiongrpc.ServerReflectionRequest_ListServices{}})
+	var names []string
+	for _, svc := range res.GetListServicesResponse().GetService() {
+		names = append(names, svc.GetName())
+	}
+	assert.Contains(t, names, grpcInfoService)
+	assert.Contains(t, names, grpcHealthService)
+
+	res = ask(&reflectiongrpc.ServerReflectionRequest{MessageRequest: &reflectiongrpc.ServerReflectionRequest_FileContainingSymbol{
+		FileContainingSymbol: grpcInfoService + ".GetHealth"}})
+	if files := res.GetFileDescriptorResponse().GetFileDescriptorProto(); assert.Len(t, files, 1) {
+		want, _ := proto.Marshal(protodesc.ToFileDescriptorProto(goinfov1.File_goinfo_v1_info_proto))
+		assert.Equal(t, want, files[0], "the descriptor should be the one generated from info.proto")
+	}
+
+	res = ask(&reflectiongrpc.ServerReflectionRequest{MessageRequest: &reflectiongrpc.ServerReflectionRequest_FileContainingSymbol{
+		FileContainingSymbol: "foo.Bar"}})
+	assert.Equal(t, int32(codes.NotFound), res.GetErrorResponse().GetErrorCode())
+}
+
+func TestGoHttpServerGrpcPort(t *testing.T) {
+	address := freeAddress(t)
+	myServer := NewGoHttpServer(address, getTestLogger())
+	myServer.grpcAddress = freeAddress(t)
+	stopped := make(chan error, 1)
+	go func() { stopped <- myServer.StartServer() }()
+	WaitForHttpServer("http://"+address+"/health", 50*time.Millisecond, 20)
+
+	conn, err := grpc.NewClient(myServer.grpcAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	res, err := healthgrpc.NewHealthClient(conn).Check(context.Background(), &healthgrpc.HealthCheckRequest{Service: grpcInfoService})
+	if assert.NoError(t, err, "the grpc services should be served on GRPC_PORT") {
+		assert.Equal(t, healthgrpc.HealthCheckResponse_SERVING, res.GetStatus())
+	}
+
+	myServer.Stop()
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("StartServer should return after Stop")
+	}
+	_, err = net.DialTimeout("tcp", myServer.grpcAddress, time.Second)
+	assert.Error(t, err, "the grpc port should be closed with the server")
+}
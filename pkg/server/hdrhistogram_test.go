@@ -0,0 +1,48 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHdrIndex(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 129, 255, 256, 1000, 123456, 1 << 30, hdrMaxValue} {
+		i := hdrIndex(v)
+		assert.Less(t, i, hdrBucketCount, "the bucket of %d should exist", v)
+		high := hdrHighestValue(i)
+		assert.GreaterOrEqual(t, high, v, "the bucket of %d should hold it", v)
+		assert.LessOrEqual(t, float64(high-v), float64(v)/64+1, "the bucket of %d should be within 1/64 of it", v)
+		if i > 0 {
+			assert.Less(t, hdrHighestValue(i-1), v, "the previous bucket of %d should end before it", v)
+		}
+	}
+}
+
+func TestHdrHistogramQuantile(t *testing.T) {
+	var h HdrHistogram
+	assert.Equal(t, time.Duration(0), h.Quantile(0.5), "an empty histogram should give 0")
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	tests := []struct {
+		name string
+		q    float64
+		want time.Duration
+	}{
+		{name: "1: p50 should be the median", q: 0.5, want: 500 * time.Millisecond},
+		{name: "2: p95 should be near 950ms", q: 0.95, want: 950 * time.Millisecond},
+		{name: "3: p99 should be near 990ms", q: 0.99, want: 990 * time.Millisecond},
+		{name: "4: p100 should be the max", q: 1, want: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := h.Quantile(tt.q)
+			assert.LessOrEqual(t, (got - tt.want).Abs(), tt.want/64, "got %s", got)
+		})
+	}
+	assert.Equal(t, uint64(1000), h.Count())
+	assert.Equal(t, time.Second, h.Max())
+	assert.Equal(t, 500500*time.Millisecond, h.Sum())
+}
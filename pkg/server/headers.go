@@ -0,0 +1,218 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	headersWarnTotalBytes = 8 << 10 // default header buffer of nginx (large_client_header_buffers) and of many load balancers
+	headersWarnValueBytes = 4 << 10 // a single header this big is usually a cookie or a token that grew too much
+)
+
+// knownProxyHeaders maps a header to the proxy or load balancer adding it to the requests
+var knownProxyHeaders = []struct {
+	header string
+	proxy  string
+}{
+	{"X-Amzn-Trace-Id", "aws load balancer"},
+	{"X-Cloud-Trace-Context", "google cloud load balancer"},
+	{"X-Azure-Ref", "azure front door"},
+	{"X-Azure-ClientIP", "azure front door"},
+	{"Cf-Ray", "cloudflare"},
+	{"Cf-Connecting-Ip", "cloudflare"},
+	{"Fastly-Client-Ip", "fastly"},
+	{"Akamai-Origin-Hop", "akamai"},
+	{"X-Envoy-External-Address", "envoy"},
+	{"X-Envoy-Expected-Rq-Timeout-Ms", "envoy"},
+	{"X-Forwarded-Client-Cert", "envoy or istio sidecar"},
+	{"X-Original-Forwarded-For", "ingress-nginx"},
+	{"X-Forwarded-Scheme", "ingress-nginx"},
+	{"X-Forwarded-Server", "traefik"},
+	{"X-Real-Ip", "nginx"},
+}
+
+// ProxyHop is one proxy the request went through, as told by the Forwarded, X-Forwarded-For or Via headers
+type ProxyHop struct {
+	Source string `json:"source"`          // header giving the hop : Forwarded, X-Forwarded-For or Via
+	For    string `json:"for,omitempty"`   // client of the hop
+	By     string `json:"by,omitempty"`    // proxy receiving the request, from Forwarded by= or the Via received-by
+	Proto  string `json:"proto,omitempty"` // scheme or protocol used by the client of the hop
+	Host   string `json:"host,omitempty"`  // host asked by the client of the hop
+}
+
+// HeadersReport contains the headers of a request and what they tell about the proxies in front of the pod
+type HeadersReport struct {
+	Headers        map[string][]string `json:"headers"`
+	Host           string              `json:"host"`                      // host seen by the pod
+	Scheme         string              `json:"scheme"`                    // scheme of the connection received by the pod
+	Protocol       string              `json:"protocol"`                  // h3, h2, h2c or http/1.1 between the last hop and the pod
+	OriginalHost   string              `json:"original_host,omitempty"`   // host asked by the client to the first proxy
+	OriginalScheme string              `json:"original_scheme,omitempty"` // scheme used by the client with the first proxy
+	ClientIp       string              `json:"client_ip"`                 // ip of the client, resolved with TRUSTED_PROXIES
+	ProxyAddr      string              `json:"proxy_addr,omitempty"`      // address of the trusted proxy which forwarded the request
+	Hops           []ProxyHop          `json:"hops,omitempty"`            // proxies found in Forwarded, X-Forwarded-For and Via
+	Proxies        []string            `json:"proxies,omitempty"`         // proxies and load balancers recognized by their headers
+	HeaderBytes    int                 `json:"header_bytes"`              // approximate size of the headers as sent on the wire
+	Warnings       []string            `json:"warnings,omitempty"`
+}
+
+// parseForwarded returns the hops of the Forwarded header values, defined by RFC 7239
+func parseForwarded(values []string) []ProxyHop {
+	var hops []ProxyHop
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			hop := ProxyHop{Source: "Forwarded"}
+			for _, pair := range strings.Split(element, ";") {
+				key, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if !found {
+					continue
+				}
+				val = strings.Trim(val, `"`)
+				switch strings.ToLower(key) {
+				case "for":
+					hop.For = val
+				case "by":
+					hop.By = val
+				case "proto":
+					hop.Proto = val
+				case "host":
+					hop.Host = val
+				}
+			}
+			if hop != (ProxyHop{Source: "Forwarded"}) {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// parseVia returns the hops of the Via header values, like 1.1 vegur or HTTP/1.1 proxy.example.com (comment)
+func parseVia(values []string) []ProxyHop {
+	var hops []ProxyHop
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			fields := strings.Fields(element)
+			if len(fields) < 2 {
+				continue
+			}
+			proto := fields[0]
+			if !strings.Contains(proto, "/") {
+				proto = "HTTP/" + proto
+			}
+			hops = append(hops, ProxyHop{Source: "Via", Proto: proto, By: fields[1]})
+		}
+	}
+	return hops
+}
+
+// firstListValue returns the first element of the comma separated values of the header, set by the first proxy
+func firstListValue(h http.Header, name string) string {
+	if values := splitHeaderList(h, name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// splitHeaderList returns the elements of the comma separated values of the header, in order
+func splitHeaderList(h http.Header, name string) []string {
+	var res []string
+	for _, value := range h.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				res = append(res, v)
+			}
+		}
+	}
+	return res
+}
+
+// GetHeadersReport analyzes the headers of r, trusted tells if the proxies are allowed to give the client ip
+func GetHeadersReport(r *http.Request, trusted bool) HeadersReport {
+	report := HeadersReport{
+		Headers:   r.Header,
+		Host:      r.Host,
+		Scheme:    "http",
+		Protocol:  RequestProtocol(r),
+		ClientIp:  ParseRemoteAddr(r.RemoteAddr).RemoteIp,
+		ProxyAddr: ProxyAddrFromContext(r.Context()),
+	}
+	if r.TLS != nil {
+		report.Scheme = "https"
+	}
+	forwarded := parseForwarded(r.Header.Values("Forwarded"))
+	report.Hops = append(report.Hops, forwarded...)
+	for _, client := range splitHeaderList(r.Header, "X-Forwarded-For") {
+		report.Hops = append(report.Hops, ProxyHop{Source: "X-Forwarded-For", For: client})
+	}
+	via := parseVia(r.Header.Values("Via"))
+	report.Hops = append(report.Hops, via...)
+	report.OriginalHost = firstListValue(r.Header, "X-Forwarded-Host")
+	report.OriginalScheme = firstListValue(r.Header, "X-Forwarded-Proto")
+	if len(forwarded) > 0 {
+		if report.OriginalHost == "" {
+			report.OriginalHost = forwarded[0].Host
+		}
+		if report.OriginalScheme == "" {
+			report.OriginalScheme = forwarded[0].Proto
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, known := range knownProxyHeaders {
+		if r.Header.Get(known.header) != "" && !seen[known.proxy] {
+			seen[known.proxy] = true
+			report.Proxies = append(report.Proxies, known.proxy)
+		}
+	}
+	for _, hop := range via {
+		if !seen[hop.By] {
+			seen[hop.By] = true
+			report.Proxies = append(report.Proxies, hop.By)
+		}
+	}
+
+	// each header line is sent as name: value\r\n
+	names := make([]string, 0, len(r.Header))
+	for name := range r.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range r.Header[name] {
+			report.HeaderBytes += len(name) + len(value) + 4
+			if len(value) > headersWarnValueBytes {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("header %s uses %d bytes, above %d", name, len(value), headersWarnValueBytes))
+			}
+		}
+	}
+	if report.HeaderBytes > headersWarnTotalBytes {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("the headers use %d bytes, above the %d bytes buffer of many proxies", report.HeaderBytes, headersWarnTotalBytes))
+	}
+	if len(r.Header.Values("Forwarded")) > 0 && len(r.Header.Values("X-Forwarded-For")) > 0 {
+		report.Warnings = append(report.Warnings, "both Forwarded and X-Forwarded-For are present, they may disagree")
+	}
+	if !trusted && (r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("X-Real-Ip") != "") {
+		report.Warnings = append(report.Warnings, "the client ip given by the proxies is ignored because TRUSTED_PROXIES is empty")
+	}
+	if report.OriginalScheme == "https" && report.Scheme == "http" {
+		report.Warnings = append(report.Warnings, "tls is terminated before the pod, the redirects built from the request will use http")
+	}
+	return report
+}
+
+//############# BEGIN HEADERS HANDLERS
+
+// HeadersHandler returns the request headers with the proxies, scheme and host they reveal, to debug ingress
+func (s *GoHttpServer) HeadersHandler() http.HandlerFunc {
+	handlerName := "HeadersHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, r, http.StatusOK, GetHeadersReport(r, len(s.trustedProxies) > 0))
+	}
+}
+
+// ############# END HEADERS HANDLERS
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetHeadersReport(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		trusted    bool
+		check      func(t *testing.T, report HeadersReport)
+		wantWarned string
+	}{
+		{name: "1: direct request should have no hop", check: func(t *testing.T, report HeadersReport) {
+			assert.Empty(t, report.Hops)
+			assert.Empty(t, report.Proxies)
+			assert.Equal(t, "http", report.Scheme)
+			assert.Equal(t, "example.com", report.Host)
+			assert.Empty(t, report.Warnings)
+		}},
+		{name: "2: X-Forwarded headers should give the original host and scheme", trusted: true,
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1, 10.0.0.2", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "app.example.org"},
+			check: func(t *testing.T, report HeadersReport) {
+				assert.Equal(t, []ProxyHop{{Source: "X-Forwarded-For", For: "198.51.100.1"}, {Source: "X-Forwarded-For", For: "10.0.0.2"}}, report.Hops)
+				assert.Equal(t, "app.example.org", report.OriginalHost)
+				assert.Equal(t, "https", report.OriginalScheme)
+			}, wantWarned: "tls is terminated before the pod"},
+		{name: "3: Forwarded should be parsed", trusted: true,
+			headers: map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=https;host=app.example.org, for=10.0.0.2;by=10.0.0.3`},
+			check: func(t *testing.T, report HeadersReport) {
+				assert.Equal(t, []ProxyHop{
+					{Source: "Forwarded", For: "[2001:db8::1]:4711", Proto: "https", Host: "app.example.org"},
+					{Source: "Forwarded", For: "10.0.0.2", By: "10.0.0.3"},
+				}, report.Hops)
+				assert.Equal(t, "app.example.org", report.OriginalHost)
+				assert.Equal(t, "https", report.OriginalScheme)
+			}},
+		{name: "4: Via and the load balancer headers should reveal the proxies", trusted: true,
+			headers: map[string]string{"Via": "1.1 google, HTTP/1.1 envoy-proxy (istio)", "X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000/1", "X-Envoy-External-Address": "198.51.100.1"},
+			check: func(t *testing.T, report HeadersReport) {
+				assert.Equal(t, []ProxyHop{{Source: "Via", Proto: "HTTP/1.1", By: "google"}, {Source: "Via", Proto: "HTTP/1.1", By: "envoy-proxy"}}, report.Hops)
+				assert.Equal(t, []string{"google cloud load balancer", "envoy", "google", "envoy-proxy"}, report.Proxies)
+			}},
+		{name: "5: forwarded client ip without trusted proxies should be warned",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			wantWarned: "TRUSTED_PROXIES is empty"},
+		{name: "6: huge headers should be warned", trusted: true,
+			headers: map[string]string{"Cookie": strings.Repeat("a", 5000), "X-Big": strings.Repeat("b", 5000)},
+			check: func(t *testing.T, report HeadersReport) {
+				assert.Greater(t, report.HeaderBytes, headersWarnTotalBytes)
+				assert.Len(t, report.Warnings, 3, "both values and the total should be warned")
+			}, wantWarned: "buffer of many proxies"},
+		{name: "7: Forwarded with X-Forwarded-For should be warned", trusted: true,
+			headers:    map[string]string{"Forwarded": "for=198.51.100.1", "X-Forwarded-For": "198.51.100.2"},
+			wantWarned: "they may disagree"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://example.com/headers", nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			report := GetHeadersReport(r, tt.trusted)
+			if tt.check != nil {
+				tt.check(t, report)
+			}
+			if tt.wantWarned != "" {
+				assert.Contains(t, strings.Join(report.Warnings, "\n"), tt.wantWarned)
+			}
+		})
+	}
+}
+
+func TestGoHttpServerHeadersHandler(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+	r, _ := http.NewRequest(http.MethodGet, ts.URL+"/headers", nil)
+	r.Header.Set("X-Amzn-Trace-Id", "Root=1-67891233-abcdef012345678912345678")
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var report HeadersReport
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report), "the output should be a valid json")
+	assert.Equal(t, []string{"aws load balancer"}, report.Proxies)
+	assert.Equal(t, "127.0.0.1", report.ClientIp)
+	assert.Greater(t, report.HeaderBytes, 0)
+}
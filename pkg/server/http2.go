@@ -0,0 +1,32 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// newProtocols returns the protocols of the main http server. with http2 the clients may use HTTP/2 over TLS,
+// chosen by ALPN, and in clear text with h2c prior knowledge like the ingress controllers and the meshes talking
+// HTTP/2 to their backends. the h2c upgrade from HTTP/1.1 is not supported, as in net/http
+func newProtocols(http2 bool) *http.Protocols {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(http2)
+	protocols.SetUnencryptedHTTP2(http2)
+	return protocols
+}
+
+// RequestProtocol returns the protocol of the connection of r like its ALPN name : h3, h2, h2c when HTTP/2 is used
+// without TLS, or http/1.1
+func RequestProtocol(r *http.Request) string {
+	if r.ProtoMajor == 3 {
+		return "h3"
+	}
+	if r.ProtoMajor == 2 {
+		if r.TLS == nil {
+			return "h2c"
+		}
+		return "h2"
+	}
+	return strings.ToLower(r.Proto)
+}
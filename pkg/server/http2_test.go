@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoHttpServerHttp2(t *testing.T) {
+	tests := []struct {
+		name         string
+		http2        string
+		tls          bool
+		wantProto    string
+		wantProtocol string
+	}{
+		{name: "1: h2c client should use HTTP/2 in clear text", http2: "true", wantProto: "HTTP/2.0", wantProtocol: "h2c"},
+		{name: "2: tls client should negotiate h2", http2: "true", tls: true, wantProto: "HTTP/2.0", wantProtocol: "h2"},
+		{name: "3: HTTP2=false should keep tls clients in HTTP/1.1", http2: "false", tls: true, wantProto: "HTTP/1.1", wantProtocol: "http/1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HTTP2", tt.http2)
+			myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+			ts := httptest.NewUnstartedServer(myServer.router)
+			ts.Config.Protocols = myServer.httpServer.Protocols
+			var client *http.Client
+			if tt.tls {
+				ts.EnableHTTP2 = tt.http2 == "true"
+				ts.StartTLS()
+				client = ts.Client()
+			} else {
+				ts.Start()
+				protocols := new(http.Protocols)
+				protocols.SetUnencryptedHTTP2(true)
+				client = &http.Client{Transport: &http.Transport{Protocols: protocols}}
+			}
+			defer ts.Close()
+
+			resp, err := client.Get(ts.URL + "/echo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+			var echo EchoInfo
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&echo))
+			assert.Equal(t, tt.wantProto, echo.Proto)
+			assert.Equal(t, tt.wantProtocol, echo.Protocol)
+		})
+	}
+}
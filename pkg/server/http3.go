@@ -0,0 +1,54 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+const http3AltSvcMaxAge = "86400" // seconds the clients may remember that HTTP/3 is available
+
+// Http3Server is the HTTP/3 listener over QUIC, a quic-go http3.Server
+type Http3Server interface {
+	ListenAndServe() error
+	Close() error
+}
+
+// NewHttp3Server returns the quic-go HTTP/3 server of addr, replaced in tests
+var NewHttp3Server = func(addr string, handler http.Handler, tlsConfig *tls.Config) Http3Server {
+	return &http3.Server{Addr: addr, Handler: handler, TLSConfig: http3.ConfigureTLSConfig(tlsConfig)}
+}
+
+// startHttp3Server starts the experimental HTTP/3 listener on the udp port of HTTP3_PORT in his own goroutine, with the
+// handlers and the certificates of the main server since QUIC is always encrypted
+func (s *GoHttpServer) startHttp3Server() error {
+	if s.certs == nil {
+		return errors.New("HTTP/3 needs TLS_CERT_FILE and TLS_KEY_FILE, QUIC is always encrypted")
+	}
+	s.http3Server = NewHttp3Server(s.http3Address, s.httpServer.Handler, s.httpServer.TLSConfig.Clone())
+	go func() {
+		s.logger.Info("Starting http3 server", "address", s.http3Address)
+		if err := s.http3Server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("http3 server stopped", "address", s.http3Address, "error", err)
+		}
+	}()
+	return nil
+}
+
+// advertiseHttp3 is the Middleware sending the Alt-Svc header telling the clients they can switch to HTTP/3 on
+// the udp port of HTTP3_PORT, once the listener is started
+func (s *GoHttpServer) advertiseHttp3() Middleware {
+	_, port, _ := net.SplitHostPort(s.http3Address)
+	altSvc := `h3=":` + port + `"; ma=` + http3AltSvcMaxAge
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.http3Server != nil {
+				w.Header().Set("Alt-Svc", altSvc)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeHttp3Server is an Http3Server blocking until it is closed
+type fakeHttp3Server struct {
+	addr   string
+	closed chan struct{}
+}
+
+func (f *fakeHttp3Server) ListenAndServe() error {
+	<-f.closed
+	return http.ErrServerClosed
+}
+
+func (f *fakeHttp3Server) Close() error {
+	close(f.closed)
+	return nil
+}
+
+func TestRequestProtocol(t *testing.T) {
+	tests := []struct {
+		name       string
+		protoMajor int
+		proto      string
+		tls        bool
+		want       string
+	}{
+		{name: "1: HTTP/1.1 should be http/1.1", protoMajor: 1, proto: "HTTP/1.1", want: "http/1.1"},
+		{name: "2: HTTP/2 without tls should be h2c", protoMajor: 2, proto: "HTTP/2.0", want: "h2c"},
+		{name: "3: HTTP/2 with tls should be h2", protoMajor: 2, proto: "HTTP/2.0", tls: true, want: "h2"},
+		{name: "4: HTTP/3 should be h3", protoMajor: 3, proto: "HTTP/3.0", tls: true, want: "h3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.ProtoMajor, r.Proto = tt.protoMajor, tt.proto
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			assert.Equal(t, tt.want, RequestProtocol(r))
+		})
+	}
+}
+
+func TestGoHttpServerHttp3(t *testing.T) {
+	t.Setenv("HTTP3_PORT", "8443")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+
+	rec := httptest.NewRecorder()
+	myServer.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/time", nil))
+	assert.Equal(t, "", rec.Header().Get("Alt-Svc"), "HTTP/3 should not be advertised before its listener is started")
+
+	if err := myServer.startHttp3Server(); assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "TLS_CERT_FILE", "HTTP/3 should need TLS")
+	}
+
+	myServer.UseTLS(&CertReloader{})
+	defer func(previous func(string, http.Handler, *tls.Config) Http3Server) { NewHttp3Server = previous }(NewHttp3Server)
+	fake := &fakeHttp3Server{closed: make(chan struct{})}
+	NewHttp3Server = func(addr string, handler http.Handler, tlsConfig *tls.Config) Http3Server {
+		fake.addr = addr
+		return fake
+	}
+	assert.NoError(t, myServer.startHttp3Server())
+	defer myServer.closeSideServers()
+	assert.Equal(t, config.DefaultListenIp+":8443", fake.addr)
+	rec = httptest.NewRecorder()
+	myServer.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/time", nil))
+	assert.Equal(t, `h3=":8443"; ma=86400`, rec.Header().Get("Alt-Svc"))
+}
+
+func TestGoHttpServerHttp3OverQuic(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeTestKeyPair(t, certFile, keyFile, "localhost")
+	cr, err := NewCertReloader(certFile, keyFile, getTestLogger())
+	if err != nil {
+		t.Fatalf("NewCertReloader() error = %v", err)
+	}
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen : %v", err)
+	}
+	port := udp.LocalAddr().(*net.UDPAddr).Port
+	udp.Close()
+	t.Setenv("LISTEN_IP", "127.0.0.1")
+	t.Setenv("HTTP3_PORT", strconv.Itoa(port))
+	myServer := NewGoHttpServer("127.0.0.1:0", getTestLogger())
+	myServer.UseTLS(cr)
+	assert.NoError(t, myServer.startHttp3Server())
+	defer myServer.closeSideServers()
+
+	transport := &http3.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	defer transport.Close()
+	client := &http.Client{Transport: transport, Timeout: time.Second}
+	var resp *http.Response
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		// the listener is started in its own goroutine
+		if resp, err = client.Get(fmt.Sprintf("https://127.0.0.1:%d/headers", port)); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("HTTP/3 request failed : %v", err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 3, resp.ProtoMajor)
+	var report HeadersReport
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	assert.Equal(t, "h3", report.Protocol)
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+const HeaderInstanceInfo = "X-Instance-Info"
+
+// InstanceInfo identifies the variant of the deployment answering, so that the traffic split of a canary
+// or of a blue green rollout (Argo Rollouts, Flagger) can be checked with curl
+type InstanceInfo struct {
+	Hostname string `json:"hostname"`
+	Version  string `json:"version"`
+	Track    string `json:"track,omitempty"` // DEPLOY_TRACK, like stable or canary
+	Color    string `json:"color,omitempty"` // COLOR, like blue or green
+}
+
+// NewInstanceInfo returns the InstanceInfo of the configuration c, nil when neither DEPLOY_TRACK nor COLOR is set
+func NewInstanceInfo(c config.Config, hostname string) *InstanceInfo {
+	if c.DeployTrack == "" && c.Color == "" {
+		return nil
+	}
+	return &InstanceInfo{Hostname: hostname, Version: info.VERSION, Track: c.DeployTrack, Color: c.Color}
+}
+
+// Header returns the value of the X-Instance-Info header, like "hostname=pod-1; version=0.4.5; track=canary"
+func (ii *InstanceInfo) Header() string {
+	fields := []string{"hostname=" + ii.Hostname, "version=" + ii.Version}
+	if ii.Track != "" {
+		fields = append(fields, "track="+ii.Track)
+	}
+	if ii.Color != "" {
+		fields = append(fields, "color="+ii.Color)
+	}
+	return strings.Join(fields, "; ")
+}
+
+// instanceWriter adds an instance field first in the json object answered by the handler, the other answers
+// are sent as is. only the opening brace is rewritten, so the streamed answers are not delayed
+type instanceWriter struct {
+	http.ResponseWriter
+	field   []byte // "instance":{...}
+	checked bool   // the content type of the answer was checked
+	state   int    // 0 before the opening brace, 1 before the first member of the object, 2 when the rest is sent as is
+}
+
+func (w *instanceWriter) check() {
+	if w.checked {
+		return
+	}
+	w.checked = true
+	if !strings.HasPrefix(w.Header().Get(HeaderContentType), MIMEAppJSON) || w.Header().Get(HeaderResponseEnvelope) != "" {
+		// the SourceEnvelope already has the instance
+		w.state = 2
+		return
+	}
+	w.Header().Del("Content-Length")
+}
+
+func (w *instanceWriter) WriteHeader(code int) {
+	w.check()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *instanceWriter) Write(b []byte) (int, error) {
+	w.check()
+	n := len(b)
+	if w.state < 2 {
+		b = bytes.TrimLeft(b, " \t\r\n")
+		if len(b) == 0 {
+			return n, nil
+		}
+	}
+	if w.state == 0 {
+		if b[0] != '{' {
+			w.state = 2
+		} else {
+			if _, err := w.ResponseWriter.Write(append([]byte{'{'}, w.field...)); err != nil {
+				return 0, err
+			}
+			w.state = 1
+			if b = bytes.TrimLeft(b[1:], " \t\r\n"); len(b) == 0 {
+				return n, nil
+			}
+		}
+	}
+	if w.state == 1 {
+		if b[0] != '}' {
+			if _, err := w.ResponseWriter.Write([]byte{','}); err != nil {
+				return 0, err
+			}
+		}
+		w.state = 2
+	}
+	if _, err := w.ResponseWriter.Write(b); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Unwrap returns the original ResponseWriter, so http.ResponseController can reach its optional methods
+func (w *instanceWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// instanceBody is the Middleware adding the instance to the json objects answered
+func (s *GoHttpServer) instanceBody() Middleware {
+	instance, _ := json.Marshal(s.instance)
+	field := append([]byte(`"instance":`), instance...)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&instanceWriter{ResponseWriter: w, field: field}, r)
+		})
+	}
+}
+
+// instanceHeader is the Middleware sending the X-Instance-Info header in every response
+func (s *GoHttpServer) instanceHeader() Middleware {
+	header := s.instance.Header()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HeaderInstanceInfo, header)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
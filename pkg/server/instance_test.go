@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewInstanceInfo(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     config.Config
+		wantNil    bool
+		wantHeader string
+	}{
+		{name: "1: no track nor color should disable the instance info", wantNil: true},
+		{name: "2: track alone should be in the header", config: config.Config{DeployTrack: "canary"},
+			wantHeader: "hostname=pod-1; version=" + info.VERSION + "; track=canary"},
+		{name: "3: track and color should be in the header", config: config.Config{DeployTrack: "stable", Color: "blue"},
+			wantHeader: "hostname=pod-1; version=" + info.VERSION + "; track=stable; color=blue"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewInstanceInfo(tt.config, "pod-1")
+			assert.Equal(t, tt.wantNil, got == nil)
+			if got != nil {
+				assert.Equal(t, tt.wantHeader, got.Header())
+			}
+		})
+	}
+}
+
+func TestInstanceBody(t *testing.T) {
+	myServer := &GoHttpServer{instance: &InstanceInfo{Hostname: "pod-1", Version: "1.0.0", Color: "green"}}
+	instance := `"instance":{"hostname":"pod-1","version":"1.0.0","color":"green"}`
+	tests := []struct {
+		name        string
+		contentType string
+		writes      []string
+		want        string
+	}{
+		{name: "1: object should get the instance first", contentType: MIMEAppJSONCharsetUTF8, writes: []string{`{"time":"now"}`},
+			want: `{` + instance + `,"time":"now"}`},
+		{name: "2: empty object written in parts should get the instance alone", contentType: MIMEAppJSONCharsetUTF8, writes: []string{"\n{", " ", "}"},
+			want: `{` + instance + `}`},
+		{name: "3: json array should be kept as is", contentType: MIMEAppJSONCharsetUTF8, writes: []string{`[1,2]`}, want: `[1,2]`},
+		{name: "4: text should be kept as is", contentType: "text/plain", writes: []string{`{not json}`}, want: `{not json}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(HeaderContentType, tt.contentType)
+				for _, s := range tt.writes {
+					w.Write([]byte(s))
+				}
+			}), myServer.instanceBody())
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, tt.want, rec.Body.String())
+			if tt.contentType == MIMEAppJSONCharsetUTF8 {
+				assert.True(t, json.Valid(rec.Body.Bytes()), "the answer should stay valid json")
+			}
+		})
+	}
+}
+
+func TestGoHttpServerInstanceInfo(t *testing.T) {
+	t.Setenv("DEPLOY_TRACK", "canary")
+	t.Setenv("COLOR", "green")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "1: json answer should contain the instance", path: "/time", wantStatus: http.StatusOK, wantBody: `"track":"canary"`},
+		{name: "2: versioned answer should contain the instance once", path: "/api/v1/time", wantStatus: http.StatusOK, wantBody: `"color":"green"`},
+		{name: "3: not found should still get the header", path: "/does-not-exist", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatus, resp.StatusCode, assertCorrectStatusCodeExpected)
+			assert.Contains(t, resp.Header.Get(HeaderInstanceInfo), "track=canary; color=green")
+			body, _ := io.ReadAll(resp.Body)
+			assert.Contains(t, string(body), tt.wantBody)
+			assert.LessOrEqual(t, strings.Count(string(body), `"instance"`), 1, "the instance should be in the answer at most once")
+		})
+	}
+}
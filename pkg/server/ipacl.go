@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net"
+	"net/http"
+)
+
+// IpAccessDenied is the json answer of the requests refused by the IpAccessList
+type IpAccessDenied struct {
+	Error    string `json:"error"`
+	ClientIp string `json:"client_ip"`
+	Reason   string `json:"reason"`
+}
+
+// IpAccessList allows the clients by their ip, the deny list is checked first, then the client must be in the allow
+// list when it is not empty
+type IpAccessList struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewIpAccessList is a constructor for an IpAccessList, it returns nil when both lists are empty
+func NewIpAccessList(allow []*net.IPNet, deny []*net.IPNet) *IpAccessList {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil
+	}
+	return &IpAccessList{allow: allow, deny: deny}
+}
+
+// Check tells if the client ip is allowed, and why when it is not
+func (l *IpAccessList) Check(clientIp string) (bool, string) {
+	ip := net.ParseIP(clientIp)
+	if ip == nil {
+		return false, "the client ip is unknown"
+	}
+	if inNets(ip, l.deny) {
+		return false, "the client ip is in ip_denylist"
+	}
+	if len(l.allow) > 0 && !inNets(ip, l.allow) {
+		return false, "the client ip is not in ip_allowlist"
+	}
+	return true, ""
+}
+
+// checkIpAccess is the Middleware answering 403 to the clients refused by the IpAccessList, it runs after
+// resolveRealIp so that the ip checked is the one of the client and not the one of the proxy
+func (s *GoHttpServer) checkIpAccess() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := ParseRemoteAddr(r.RemoteAddr).RemoteIp
+			allowed, reason := s.ipAccess.Check(client)
+			if !allowed {
+				s.logger.WarnContext(r.Context(), "client ip refused", "path", r.URL.Path, "client_ip", client, "reason", reason)
+				s.jsonResponseWithStatus(w, r, http.StatusForbidden, IpAccessDenied{Error: "access denied", ClientIp: client, Reason: reason})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIpAccessListCheck(t *testing.T) {
+	allow, _ := config.ParseCidrList("10.0.0.0/8,2001:db8::/32")
+	deny, _ := config.ParseCidrList("10.66.0.0/16")
+	tests := []struct {
+		name        string
+		allow       string
+		clientIp    string
+		wantAllowed bool
+		wantReason  string
+	}{
+		{name: "1: an ip of the allow list should be allowed", clientIp: "10.1.2.3", wantAllowed: true},
+		{name: "2: an ipv6 of the allow list should be allowed", clientIp: "2001:db8::1", wantAllowed: true},
+		{name: "3: an ip outside the allow list should be refused", clientIp: "192.0.2.1", wantReason: "not in ip_allowlist"},
+		{name: "4: the deny list should win over the allow list", clientIp: "10.66.0.1", wantReason: "in ip_denylist"},
+		{name: "5: an unknown client ip should be refused", clientIp: "", wantReason: "unknown"},
+	}
+	acl := NewIpAccessList(allow, deny)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, reason := acl.Check(tt.clientIp)
+			assert.Equal(t, tt.wantAllowed, allowed)
+			if tt.wantReason != "" {
+				assert.Contains(t, reason, tt.wantReason)
+			}
+		})
+	}
+	assert.Nil(t, NewIpAccessList(nil, nil), "the access list should be disabled without ranges")
+	onlyDeny := NewIpAccessList(nil, deny)
+	allowed, _ := onlyDeny.Check("192.0.2.1")
+	assert.True(t, allowed, "without allow list every ip outside the deny list should be allowed")
+}
+
+func TestGoHttpServerIpAccess(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "127.0.0.0/8,::1")
+	t.Setenv("IP_ALLOWLIST", "10.0.0.0/8")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		clientIp   string
+		wantStatus int
+	}{
+		{name: "1: a client in the allow list behind the proxy should be served", clientIp: "10.1.2.3", wantStatus: http.StatusOK},
+		{name: "2: a client outside the allow list behind the proxy should get 403", clientIp: "198.51.100.1", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+"/time", nil)
+			req.Header.Set("X-Forwarded-For", tt.clientIp)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusForbidden {
+				return
+			}
+			var denied IpAccessDenied
+			if err := json.NewDecoder(resp.Body).Decode(&denied); err != nil {
+				t.Fatalf("the output should be a valid json : %v", err)
+			}
+			assert.Equal(t, tt.clientIp, denied.ClientIp, "the ip resolved behind the proxy should be checked")
+			assert.Contains(t, denied.Reason, "ip_allowlist")
+		})
+	}
+}
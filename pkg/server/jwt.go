@@ -0,0 +1,391 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // hashes of the RS256, PS256 and ES256 signatures
+	_ "crypto/sha512" // hashes of the 384 and 512 signatures
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJwksTimeout    = 10 * time.Second // max time to download the JWKS
+	defaultJwksMaxAge     = time.Hour        // the keys are downloaded again after this time, to follow the rotations
+	defaultJwksMinRefresh = 30 * time.Second // min time between two downloads for an unknown kid, so tokens cannot flood the issuer
+	defaultJwtLeeway      = time.Minute      // clock skew tolerated on exp and nbf
+	maxJwksSize           = 1 << 20
+)
+
+var (
+	ErrJwtMalformed  = errors.New("jwt_malformed")
+	ErrJwtAlgorithm  = errors.New("jwt_unsupported_algorithm")
+	ErrJwtSignature  = errors.New("jwt_invalid_signature")
+	ErrJwtExpired    = errors.New("jwt_expired")
+	ErrJwtNotYet     = errors.New("jwt_not_yet_valid")
+	ErrJwtIssuer     = errors.New("jwt_invalid_issuer")
+	ErrJwtAudience   = errors.New("jwt_invalid_audience")
+	ErrJwtUnknownKey = errors.New("jwt_unknown_key")
+)
+
+// JwtClaims are the decoded claims of a validated jwt
+type JwtClaims map[string]interface{}
+
+// Select returns the claims among names that are present, all the claims when names is empty
+func (c JwtClaims) Select(names []string) JwtClaims {
+	if len(names) == 0 {
+		return c
+	}
+	selected := JwtClaims{}
+	for _, name := range names {
+		if val, exist := c[name]; exist {
+			selected[name] = val
+		}
+	}
+	return selected
+}
+
+// time returns the NumericDate claim name, false when it is missing or not a number
+func (c JwtClaims) time(name string) (time.Time, bool) {
+	val, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(val), 0), true
+}
+
+// hasAudience tells if the aud claim, a string or an array of strings, contains audience
+func (c JwtClaims) hasAudience(audience string) bool {
+	switch aud := c["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jwtErrorCode returns the error code sent to the client for err, without the details like the url of the JWKS
+func jwtErrorCode(err error) string {
+	for _, known := range []error{ErrJwtMalformed, ErrJwtAlgorithm, ErrJwtSignature, ErrJwtExpired, ErrJwtNotYet, ErrJwtIssuer, ErrJwtAudience, ErrJwtUnknownKey} {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+	return tokenErrInvalid
+}
+
+type jwtClaimsContextKey struct{}
+
+// JwtClaimsFromContext returns the claims of the jwt validated by the authenticate Middleware, nil without jwt
+func JwtClaimsFromContext(ctx context.Context) JwtClaims {
+	claims, _ := ctx.Value(jwtClaimsContextKey{}).(JwtClaims)
+	return claims
+}
+
+// jsonWebKey is a key of a JWKS, only the fields of the RSA and EC public keys are read
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey returns the RSA or EC public key of k
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, errN := decode(k.N)
+		e, errE := decode(k.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA key %q", k.Kid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q of key %q", k.Crv, k.Kid)
+		}
+		x, errX := decode(k.X)
+		y, errY := decode(k.Y)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("invalid EC key %q", k.Kid)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("invalid EC key %q, the point is not on the curve", k.Kid)
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q of key %q", k.Kty, k.Kid)
+}
+
+// JwksCache keeps the signing keys of a JWKS url, downloaded again when they are too old or a token
+// is signed by an unknown kid, as the issuers publish the new keys before using them
+type JwksCache struct {
+	url        string
+	httpClient *http.Client
+	maxAge     time.Duration
+	minRefresh time.Duration
+	now        func() time.Time // time.Now, replaced in tests
+	mu         sync.Mutex
+	keys       map[string]crypto.PublicKey
+	fetched    time.Time
+	refresh    *jwksRefresh // the download in progress, nil without
+}
+
+// jwksRefresh is a download of the keys shared by all the requests waiting for it
+type jwksRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+// NewJwksCache is a constructor for a JwksCache of the keys published at url
+func NewJwksCache(url string) *JwksCache {
+	return &JwksCache{
+		url:        url,
+		httpClient: &http.Client{Timeout: defaultJwksTimeout},
+		maxAge:     defaultJwksMaxAge,
+		minRefresh: defaultJwksMinRefresh,
+		now:        time.Now,
+	}
+}
+
+// fetch downloads the keys of the JWKS, the keys that are not used for the signatures or not supported are ignored
+func (c *JwksCache) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks %s answered %s", c.url, resp.Status)
+	}
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJwksSize)).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("invalid jwks %s : %w", c.url, err)
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// Key returns the key kid, an empty kid is accepted when the JWKS contains a single key.
+// the keys are downloaded without holding the lock, by a single download shared by all the waiting requests
+func (c *JwksCache) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	now := c.now()
+	key, found := c.lookup(kid)
+	age := now.Sub(c.fetched)
+	if c.keys != nil && age <= c.maxAge && (found || age <= c.minRefresh) {
+		c.mu.Unlock()
+		if !found {
+			return nil, ErrJwtUnknownKey
+		}
+		return key, nil
+	}
+	refresh := c.refresh
+	if refresh == nil {
+		refresh = &jwksRefresh{done: make(chan struct{})}
+		c.refresh = refresh
+		go c.refreshKeys(refresh, now)
+	}
+	c.mu.Unlock()
+	select {
+	case <-refresh.done:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w : %v", ErrJwtUnknownKey, ctx.Err())
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// when the download fails the previous keys are kept, the issuer may only be unreachable for a while
+	if refresh.err != nil && c.keys == nil {
+		return nil, fmt.Errorf("%w : %v", ErrJwtUnknownKey, refresh.err)
+	}
+	if key, found = c.lookup(kid); !found {
+		return nil, ErrJwtUnknownKey
+	}
+	return key, nil
+}
+
+// refreshKeys downloads the keys for refresh, without the context of a request so a client going away
+// does not fail the download for the others
+func (c *JwksCache) refreshKeys(refresh *jwksRefresh, now time.Time) {
+	keys, err := c.fetch(context.Background())
+	c.mu.Lock()
+	if err == nil {
+		c.keys, c.fetched = keys, now
+	}
+	refresh.err = err
+	c.refresh = nil
+	c.mu.Unlock()
+	close(refresh.done)
+}
+
+func (c *JwksCache) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key, true
+		}
+	}
+	key, found := c.keys[kid]
+	return key, found
+}
+
+// JwtValidator validates the signature of the jwt with the keys of a JWKS, and their exp, nbf, iss and aud claims
+type JwtValidator struct {
+	keys     *JwksCache
+	issuer   string // expected iss, not checked when empty
+	audience string // expected in aud, not checked when empty
+	leeway   time.Duration
+	now      func() time.Time // time.Now, replaced in tests
+}
+
+// NewJwtValidator is a constructor for a JwtValidator of the tokens signed by the keys of the JWKS
+func NewJwtValidator(keys *JwksCache, issuer string, audience string) *JwtValidator {
+	return &JwtValidator{keys: keys, issuer: issuer, audience: audience, leeway: defaultJwtLeeway, now: time.Now}
+}
+
+// jwtCurves are the curves of the EC keys of the ES algorithms
+var jwtCurves = map[string]string{"ES256": "P-256", "ES384": "P-384", "ES512": "P-521"}
+
+// verifyJwtSignature checks the signature of signed with key for the algorithm alg
+func verifyJwtSignature(alg string, key crypto.PublicKey, signed []byte, signature []byte) error {
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return ErrJwtAlgorithm
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	switch alg[:2] {
+	case "RS", "PS":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrJwtAlgorithm
+		}
+		var err error
+		if alg[0] == 'P' {
+			err = rsa.VerifyPSS(rsaKey, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			err = rsa.VerifyPKCS1v15(rsaKey, hash, digest, signature)
+		}
+		if err != nil {
+			return ErrJwtSignature
+		}
+		return nil
+	case "ES":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || ecKey.Curve.Params().Name != jwtCurves[alg] {
+			return ErrJwtAlgorithm
+		}
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return ErrJwtSignature
+		}
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(ecKey, digest, r, s) {
+			return ErrJwtSignature
+		}
+		return nil
+	}
+	return ErrJwtAlgorithm
+}
+
+// Validate returns the claims of token when its signature and claims are valid. only the asymmetric algorithms
+// are accepted, none and the HMAC ones would let anyone knowing the public keys forge a token
+func (v *JwtValidator) Validate(ctx context.Context, token string) (JwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrJwtMalformed
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(rawHeader, &header) != nil {
+		return nil, ErrJwtMalformed
+	}
+	if len(header.Alg) != 5 {
+		return nil, ErrJwtAlgorithm
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrJwtMalformed
+	}
+	key, err := v.keys.Key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJwtSignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+	var claims JwtClaims
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(rawClaims, &claims) != nil || claims == nil {
+		return nil, ErrJwtMalformed
+	}
+	now := v.now()
+	if exp, ok := claims.time("exp"); ok && now.After(exp.Add(v.leeway)) {
+		return nil, ErrJwtExpired
+	}
+	if nbf, ok := claims.time("nbf"); ok && now.Add(v.leeway).Before(nbf) {
+		return nil, ErrJwtNotYet
+	}
+	if iss, _ := claims["iss"].(string); v.issuer != "" && iss != v.issuer {
+		return nil, ErrJwtIssuer
+	}
+	if v.audience != "" && !claims.hasAudience(v.audience) {
+		return nil, ErrJwtAudience
+	}
+	return claims, nil
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testJwks serves the public keys of the test issuer and counts the downloads
+type testJwks struct {
+	rsaKey    *rsa.PrivateKey
+	ecKey     *ecdsa.PrivateKey
+	downloads atomic.Int32
+	server    *httptest.Server
+}
+
+func newTestJwks(t *testing.T) *testJwks {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := &testJwks{rsaKey: rsaKey, ecKey: ecKey}
+	encode := base64.RawURLEncoding.EncodeToString
+	keys := map[string][]jsonWebKey{"keys": {
+		{Kty: "RSA", Kid: "rsa-1", Use: "sig", N: encode(rsaKey.N.Bytes()), E: encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{Kty: "EC", Kid: "ec-1", Crv: "P-256", X: encode(ecKey.X.FillBytes(make([]byte, 32))), Y: encode(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{Kty: "EC", Kid: "ec-384", Crv: "P-384", X: encode(ec384Key.X.FillBytes(make([]byte, 48))), Y: encode(ec384Key.Y.FillBytes(make([]byte, 48)))},
+		{Kty: "RSA", Kid: "enc-1", Use: "enc", N: encode(rsaKey.N.Bytes()), E: "AQAB"},
+	}}
+	jwks.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jwks.downloads.Add(1)
+		json.NewEncoder(w).Encode(keys)
+	}))
+	t.Cleanup(jwks.server.Close)
+	return jwks
+}
+
+// sign returns a jwt of claims signed with the key of alg, RS256, PS256 or ES256
+func (j *testJwks) sign(t *testing.T, alg string, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	encode := base64.RawURLEncoding.EncodeToString
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := encode(header) + "." + encode(payload)
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(signed))
+	var signature []byte
+	var err error
+	switch alg {
+	case "RS256":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, j.rsaKey, crypto.SHA256, digest.Sum(nil))
+	case "PS256":
+		signature, err = rsa.SignPSS(rand.Reader, j.rsaKey, crypto.SHA256, digest.Sum(nil), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, j.ecKey, digest.Sum(nil))
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	default:
+		signature = []byte("not-a-signature")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + encode(signature)
+}
+
+func TestJwtValidatorValidate(t *testing.T) {
+	jwks := newTestJwks(t)
+	now := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	validator := NewJwtValidator(NewJwksCache(jwks.server.URL), "https://issuer.example", "go-info")
+	validator.now = func() time.Time { return now }
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{"iss": "https://issuer.example", "aud": []string{"other", "go-info"}, "sub": "alice", "exp": now.Add(time.Hour).Unix()}
+		for k, v := range changes {
+			c[k] = v
+		}
+		return c
+	}
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "1: a RS256 jwt should be valid", token: jwks.sign(t, "RS256", "rsa-1", claims(nil))},
+		{name: "2: a PS256 jwt should be valid", token: jwks.sign(t, "PS256", "rsa-1", claims(nil))},
+		{name: "3: an ES256 jwt should be valid", token: jwks.sign(t, "ES256", "ec-1", claims(map[string]interface{}{"aud": "go-info"}))},
+		{name: "4: a jwt expired for longer than the leeway should be refused", token: jwks.sign(t, "RS256", "rsa-1", claims(map[string]interface{}{"exp": now.Add(-2 * time.Minute).Unix()})), wantErr: ErrJwtExpired},
+		{name: "5: a jwt expired within the leeway should be valid", token: jwks.sign(t, "RS256", "rsa-1", claims(map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()}))},
+		{name: "6: a jwt not yet valid should be refused", token: jwks.sign(t, "RS256", "rsa-1", claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})), wantErr: ErrJwtNotYet},
+		{name: "7: a jwt of another issuer should be refused", token: jwks.sign(t, "RS256", "rsa-1", claims(map[string]interface{}{"iss": "https://evil.example"})), wantErr: ErrJwtIssuer},
+		{name: "8: a jwt for another audience should be refused", token: jwks.sign(t, "RS256", "rsa-1", claims(map[string]interface{}{"aud": "other"})), wantErr: ErrJwtAudience},
+		{name: "9: a jwt signed by the ec key with the rsa kid should be refused", token: jwks.sign(t, "ES256", "rsa-1", claims(nil)), wantErr: ErrJwtAlgorithm},
+		{name: "10: a jwt with an HMAC algorithm should be refused", token: jwks.sign(t, "HS256", "rsa-1", claims(nil)), wantErr: ErrJwtAlgorithm},
+		{name: "11: a jwt with the none algorithm should be refused", token: jwks.sign(t, "none", "rsa-1", claims(nil)), wantErr: ErrJwtAlgorithm},
+		{name: "12: a jwt of a key not used for signatures should be refused", token: jwks.sign(t, "RS256", "enc-1", claims(nil)), wantErr: ErrJwtUnknownKey},
+		{name: "13: a token without three parts should be refused", token: "not.a-jwt", wantErr: ErrJwtMalformed},
+		{name: "14: an ES256 jwt with the kid of a P-384 key should be refused", token: jwks.sign(t, "ES256", "ec-384", claims(nil)), wantErr: ErrJwtAlgorithm},
+		{name: "15: an ES384 jwt with the kid of a P-256 key should be refused", token: jwks.sign(t, "ES384", "ec-1", claims(nil)), wantErr: ErrJwtAlgorithm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validator.Validate(context.Background(), tt.token)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, "alice", got["sub"])
+			}
+		})
+	}
+
+	tampered := jwks.sign(t, "RS256", "rsa-1", claims(nil))
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+	_, err := validator.Validate(context.Background(), tampered)
+	assert.ErrorIs(t, err, ErrJwtSignature, "a jwt with a modified signature should be refused")
+}
+
+func TestJwksCacheKey(t *testing.T) {
+	jwks := newTestJwks(t)
+	now := time.Now()
+	cache := NewJwksCache(jwks.server.URL)
+	cache.now = func() time.Time { return now }
+
+	_, err := cache.Key(context.Background(), "rsa-1")
+	assert.NoError(t, err)
+	_, err = cache.Key(context.Background(), "ec-1")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), jwks.downloads.Load(), "the known keys should be kept in the cache")
+
+	_, err = cache.Key(context.Background(), "rotated")
+	assert.ErrorIs(t, err, ErrJwtUnknownKey)
+	assert.Equal(t, int32(1), jwks.downloads.Load(), "an unknown kid should not download the keys again before minRefresh")
+
+	now = now.Add(defaultJwksMinRefresh + time.Second)
+	_, err = cache.Key(context.Background(), "rotated")
+	assert.ErrorIs(t, err, ErrJwtUnknownKey)
+	assert.Equal(t, int32(2), jwks.downloads.Load(), "an unknown kid should download the keys again after minRefresh")
+
+	jwks.server.Close()
+	now = now.Add(defaultJwksMaxAge + time.Second)
+	_, err = cache.Key(context.Background(), "rsa-1")
+	assert.NoError(t, err, "the previous keys should be kept when the issuer is unreachable")
+
+	_, err = NewJwksCache(jwks.server.URL).Key(context.Background(), "rsa-1")
+	assert.ErrorIs(t, err, ErrJwtUnknownKey, "a cache without keys should fail when the issuer is unreachable")
+}
+
+func TestJwksCacheKeyConcurrent(t *testing.T) {
+	var downloads atomic.Int32
+	release := make(chan struct{})
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		<-release
+		w.Write([]byte(`{"keys":[{"kty":"RSA","kid":"rsa-1","n":"AQAB","e":"AQAB"}]}`))
+	}))
+	defer issuer.Close()
+	cache := NewJwksCache(issuer.URL)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.Key(context.Background(), "rsa-1")
+			errs <- err
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	cache.mu.Lock()
+	assert.NotNil(t, cache.refresh, "the lock should not be held during the download")
+	cache.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := cache.Key(ctx, "rsa-1")
+	assert.ErrorIs(t, err, ErrJwtUnknownKey, "a request should stop waiting when its context ends")
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), downloads.Load(), "the waiting requests should share a single download")
+}
+
+func TestJwtClaimsSelect(t *testing.T) {
+	claims := JwtClaims{"sub": "alice", "email": "alice@example.com", "exp": 1.0}
+	assert.Equal(t, claims, claims.Select(nil), "all the claims should be selected without names")
+	assert.Equal(t, JwtClaims{"sub": "alice"}, claims.Select([]string{"sub", "groups"}))
+}
@@ -0,0 +1,406 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// K8sClient is a Kubernetes api client using the credentials of the pod service account, built on the typed
+// clients of client-go which read again the projected token rotated by the kubelet
+type K8sClient struct {
+	clientset  kubernetes.Interface
+	restConfig *rest.Config // kept to read the current token for /k8s/identity
+	namespace  string
+}
+
+// traceTransport injects the trace context of the request in the calls to the k8s api
+type traceTransport struct {
+	next http.RoundTripper
+}
+
+func (t traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	InjectTraceContext(req.Context(), req)
+	return t.next.RoundTrip(req)
+}
+
+// newK8sClientForConfig is a constructor for a K8sClient of the namespace using restConfig
+func newK8sClientForConfig(restConfig *rest.Config, namespace string) (*K8sClient, error) {
+	restConfig.ContentType = MIMEAppJSON // GetPodJson returns the answer as is, it must be json
+	restConfig.Timeout = config.DefaultReadTimeout
+	restConfig.Wrap(func(rt http.RoundTripper) http.RoundTripper { return traceTransport{next: rt} })
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &K8sClient{clientset: clientset, restConfig: restConfig, namespace: strings.TrimSpace(namespace)}, nil
+}
+
+// NewK8sClient is a constructor for a K8sClient talking to apiUrl with the bearer token, trusting only the caCert pem
+func NewK8sClient(apiUrl, token string, caCert []byte, namespace string) (*K8sClient, error) {
+	return newK8sClientForConfig(&rest.Config{
+		Host:            apiUrl,
+		BearerToken:     strings.TrimSpace(token),
+		TLSClientConfig: rest.TLSClientConfig{CAData: caCert},
+	}, namespace)
+}
+
+// NewK8sClientInCluster returns a K8sClient configured by rest.InClusterConfig from the service account of the pod
+// and the KUBERNETES_SERVICE_HOST/PORT env variables, with the namespace mounted in serviceAccountPath, or an error
+// when we are not inside a pod with a service account.
+func NewK8sClientInCluster(serviceAccountPath string) (*K8sClient, error) {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, &config.ErrorConfig{Err: err, Msg: "NewK8sClientInCluster: no in-cluster configuration"}
+	}
+	namespace, err := os.ReadFile(filepath.Join(serviceAccountPath, "namespace"))
+	if err != nil {
+		return nil, &config.ErrorConfig{Err: err, Msg: "NewK8sClientInCluster: no service account namespace"}
+	}
+	return newK8sClientForConfig(restConfig, string(namespace))
+}
+
+// bearerToken returns the current service account token, client-go reads it again from the same file
+func (c *K8sClient) bearerToken() string {
+	if c.restConfig.BearerTokenFile != "" {
+		if token, err := os.ReadFile(c.restConfig.BearerTokenFile); err == nil {
+			return strings.TrimSpace(string(token))
+		}
+	}
+	return c.restConfig.BearerToken
+}
+
+// Namespace returns the namespace of the service account
+func (c *K8sClient) Namespace() string {
+	return c.namespace
+}
+
+// GetPodName returns the name of the pod we are running in, from the Downward API or else the hostname
+func GetPodName() string {
+	if name := info.LookupFirstEnv(os.LookupEnv, "MY_POD_NAME", "POD_NAME"); name != "" {
+		return name
+	}
+	hostName, _ := os.Hostname()
+	return hostName
+}
+
+// GetPodJson returns the raw json of the Pod named name in the namespace of the service account, as the api gives it
+func (c *K8sClient) GetPodJson(ctx context.Context, name string) ([]byte, error) {
+	return c.clientset.CoreV1().RESTClient().Get().Namespace(c.namespace).Resource("pods").Name(name).DoRaw(ctx)
+}
+
+// GetNodeName returns the name of the node we are scheduled on, from the Downward API or else the spec of our Pod
+func (c *K8sClient) GetNodeName(ctx context.Context) (string, error) {
+	if name := info.LookupFirstEnv(os.LookupEnv, "MY_NODE_NAME", "NODE_NAME"); name != "" {
+		return name, nil
+	}
+	pod, err := c.clientset.CoreV1().Pods(c.namespace).Get(ctx, GetPodName(), metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if pod.Spec.NodeName == "" {
+		return "", errors.New("the pod is not scheduled on a node")
+	}
+	return pod.Spec.NodeName, nil
+}
+
+// K8sTaint is a taint of a Node, repelling the pods that do not tolerate it
+type K8sTaint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"`
+}
+
+// K8sNodeCondition is one condition of a Node, like Ready or MemoryPressure
+type K8sNodeCondition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// K8sNodeInfo is the part of a Node object useful to understand the scheduling and the resource pressure of a pod
+type K8sNodeInfo struct {
+	Name                    string             `json:"name"`
+	Labels                  map[string]string  `json:"labels,omitempty"`
+	Unschedulable           bool               `json:"unschedulable"`
+	KubeletVersion          string             `json:"kubelet_version"`
+	ContainerRuntimeVersion string             `json:"container_runtime_version"`
+	OsImage                 string             `json:"os_image"`
+	KernelVersion           string             `json:"kernel_version"`
+	Architecture            string             `json:"architecture"`
+	Capacity                map[string]string  `json:"capacity"`
+	Allocatable             map[string]string  `json:"allocatable"`
+	Taints                  []K8sTaint         `json:"taints"`
+	Conditions              []K8sNodeCondition `json:"conditions"`
+}
+
+// resourceStrings returns the quantities of resources as strings, like 3800m or 7515420Ki
+func resourceStrings(resources corev1.ResourceList) map[string]string {
+	quantities := make(map[string]string, len(resources))
+	for name, quantity := range resources {
+		quantities[string(name)] = quantity.String()
+	}
+	return quantities
+}
+
+// GetNodeInfo returns the resources, versions, taints and conditions of the Node named nodeName
+func (c *K8sClient) GetNodeInfo(ctx context.Context, nodeName string) (*K8sNodeInfo, error) {
+	node, err := c.clientset.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	info := K8sNodeInfo{
+		Name:                    node.Name,
+		Labels:                  node.Labels,
+		Unschedulable:           node.Spec.Unschedulable,
+		KubeletVersion:          node.Status.NodeInfo.KubeletVersion,
+		ContainerRuntimeVersion: node.Status.NodeInfo.ContainerRuntimeVersion,
+		OsImage:                 node.Status.NodeInfo.OSImage,
+		KernelVersion:           node.Status.NodeInfo.KernelVersion,
+		Architecture:            node.Status.NodeInfo.Architecture,
+		Capacity:                resourceStrings(node.Status.Capacity),
+		Allocatable:             resourceStrings(node.Status.Allocatable),
+		Taints:                  make([]K8sTaint, 0, len(node.Spec.Taints)),
+		Conditions:              make([]K8sNodeCondition, 0, len(node.Status.Conditions)),
+	}
+	for _, taint := range node.Spec.Taints {
+		info.Taints = append(info.Taints, K8sTaint{Key: taint.Key, Value: taint.Value, Effect: string(taint.Effect)})
+	}
+	for _, cond := range node.Status.Conditions {
+		condition := K8sNodeCondition{Type: string(cond.Type), Status: string(cond.Status), Reason: cond.Reason, Message: cond.Message}
+		if !cond.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = cond.LastTransitionTime.UTC().Format(time.RFC3339)
+		}
+		info.Conditions = append(info.Conditions, condition)
+	}
+	return &info, nil
+}
+
+// K8sDeploymentSummary gives the replica counts of a Deployment
+type K8sDeploymentSummary struct {
+	Name              string `json:"name"`
+	Replicas          int    `json:"replicas"`
+	ReadyReplicas     int    `json:"ready_replicas"`
+	UpdatedReplicas   int    `json:"updated_replicas"`
+	AvailableReplicas int    `json:"available_replicas"`
+}
+
+// K8sPodSummary gives the phase and the readiness of the containers of a Pod
+type K8sPodSummary struct {
+	Name            string `json:"name"`
+	Phase           string `json:"phase"`
+	ReadyContainers int    `json:"ready_containers"`
+	Containers      int    `json:"containers"`
+	Restarts        int    `json:"restarts"`
+	NodeName        string `json:"node_name,omitempty"`
+	PodIp           string `json:"pod_ip,omitempty"`
+}
+
+// K8sServiceSummary gives the type, cluster ip and ports of a Service
+type K8sServiceSummary struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	ClusterIp string   `json:"cluster_ip,omitempty"`
+	Ports     []string `json:"ports"` // port/protocol->targetPort
+}
+
+// K8sNamespaceSummary lists the workloads of a namespace, a list the service account is not allowed to read
+// is left empty and its error is given in Errors
+type K8sNamespaceSummary struct {
+	Namespace   string                 `json:"namespace"`
+	Deployments []K8sDeploymentSummary `json:"deployments"`
+	Pods        []K8sPodSummary        `json:"pods"`
+	Services    []K8sServiceSummary    `json:"services"`
+	Errors      map[string]string      `json:"errors,omitempty"` // error of each list that could not be read
+}
+
+// listDeployments returns the replica counts of all the Deployments of the namespace
+func (c *K8sClient) listDeployments(ctx context.Context) ([]K8sDeploymentSummary, error) {
+	list, err := c.clientset.AppsV1().Deployments(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	deployments := make([]K8sDeploymentSummary, 0, len(list.Items))
+	for _, d := range list.Items {
+		replicas := 1 // default of the api when spec.replicas is absent
+		if d.Spec.Replicas != nil {
+			replicas = int(*d.Spec.Replicas)
+		}
+		deployments = append(deployments, K8sDeploymentSummary{
+			Name:              d.Name,
+			Replicas:          replicas,
+			ReadyReplicas:     int(d.Status.ReadyReplicas),
+			UpdatedReplicas:   int(d.Status.UpdatedReplicas),
+			AvailableReplicas: int(d.Status.AvailableReplicas),
+		})
+	}
+	return deployments, nil
+}
+
+// listPods returns the phase and the ready containers of all the Pods of the namespace
+func (c *K8sClient) listPods(ctx context.Context) ([]K8sPodSummary, error) {
+	list, err := c.clientset.CoreV1().Pods(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]K8sPodSummary, 0, len(list.Items))
+	for _, p := range list.Items {
+		pod := K8sPodSummary{
+			Name:       p.Name,
+			Phase:      string(p.Status.Phase),
+			Containers: len(p.Status.ContainerStatuses),
+			NodeName:   p.Spec.NodeName,
+			PodIp:      p.Status.PodIP,
+		}
+		for _, cs := range p.Status.ContainerStatuses {
+			if cs.Ready {
+				pod.ReadyContainers++
+			}
+			pod.Restarts += int(cs.RestartCount)
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+// listServices returns the type and the ports of all the Services of the namespace
+func (c *K8sClient) listServices(ctx context.Context) ([]K8sServiceSummary, error) {
+	list, err := c.clientset.CoreV1().Services(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	services := make([]K8sServiceSummary, 0, len(list.Items))
+	for _, svc := range list.Items {
+		service := K8sServiceSummary{Name: svc.Name, Type: string(svc.Spec.Type), ClusterIp: svc.Spec.ClusterIP, Ports: []string{}}
+		for _, p := range svc.Spec.Ports {
+			port := fmt.Sprintf("%d/%s", p.Port, p.Protocol)
+			// the target port is a number or the name of a container port
+			if target := p.TargetPort.String(); target != "" && target != "0" {
+				port += "->" + target
+			}
+			service.Ports = append(service.Ports, port)
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+// GetNamespaceSummary returns the Deployments, Pods and Services of the namespace of the service account. an error is
+// returned only when none of them can be read, otherwise the lists that failed are reported in Errors
+func (c *K8sClient) GetNamespaceSummary(ctx context.Context) (*K8sNamespaceSummary, error) {
+	summary := K8sNamespaceSummary{Namespace: c.namespace, Errors: make(map[string]string)}
+	var errs []error
+	var err error
+	if summary.Deployments, err = c.listDeployments(ctx); err != nil {
+		summary.Errors["deployments"] = err.Error()
+		errs = append(errs, err)
+	}
+	if summary.Pods, err = c.listPods(ctx); err != nil {
+		summary.Errors["pods"] = err.Error()
+		errs = append(errs, err)
+	}
+	if summary.Services, err = c.listServices(ctx); err != nil {
+		summary.Errors["services"] = err.Error()
+		errs = append(errs, err)
+	}
+	if len(errs) == 3 {
+		return nil, errs[0]
+	}
+	if summary.Deployments == nil {
+		summary.Deployments = []K8sDeploymentSummary{}
+	}
+	if summary.Pods == nil {
+		summary.Pods = []K8sPodSummary{}
+	}
+	if summary.Services == nil {
+		summary.Services = []K8sServiceSummary{}
+	}
+	return &summary, nil
+}
+
+// k8sErrorResponse sends the k8s api error to the client, keeping the status code of the api when there is one
+func (s *GoHttpServer) k8sErrorResponse(w http.ResponseWriter, handlerName string, err error) {
+	s.logger.Error("k8s api call failed", "handler", handlerName, "error", err)
+	status := http.StatusBadGateway
+	var apiErr apierrors.APIStatus
+	if errors.As(err, &apiErr) && apiErr.Status().Code != 0 {
+		status = int(apiErr.Status().Code)
+	}
+	w.Header().Set(HeaderContentType, MIMEAppJSONCharsetUTF8)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+//############# BEGIN K8S HANDLERS
+
+// K8sPodHandler returns the full Pod object (spec, status, owner references, container statuses) of this server
+func (s *GoHttpServer) K8sPodHandler() http.HandlerFunc {
+	handlerName := "K8sPodHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	podName := GetPodName()
+	return func(w http.ResponseWriter, r *http.Request) {
+		pod, err := s.k8s.GetPodJson(r.Context(), podName)
+		if err != nil {
+			s.k8sErrorResponse(w, handlerName, err)
+			return
+		}
+		s.render(w, r, http.StatusOK, json.RawMessage(pod))
+	}
+}
+
+// K8sNodeHandler returns the allocatable and capacity resources, kubelet version, taints and conditions of the Node
+// this server is scheduled on, the service account needs the permission to get the nodes
+func (s *GoHttpServer) K8sNodeHandler() http.HandlerFunc {
+	handlerName := "K8sNodeHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeName, err := s.k8s.GetNodeName(r.Context())
+		if err != nil {
+			s.k8sErrorResponse(w, handlerName, err)
+			return
+		}
+		node, err := s.k8s.GetNodeInfo(r.Context(), nodeName)
+		if err != nil {
+			s.k8sErrorResponse(w, handlerName, err)
+			return
+		}
+		s.render(w, r, http.StatusOK, node)
+	}
+}
+
+// K8sNamespaceHandler returns the Deployments, Pods and Services of the namespace of this server with their
+// replica and readiness counts, like a lightweight dashboard of the namespace
+func (s *GoHttpServer) K8sNamespaceHandler() http.HandlerFunc {
+	handlerName := "K8sNamespaceHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		summary, err := s.k8s.GetNamespaceSummary(r.Context())
+		if err != nil {
+			s.k8sErrorResponse(w, handlerName, err)
+			return
+		}
+		if len(summary.Errors) > 0 {
+			s.logger.WarnContext(r.Context(), "some k8s lists could not be read", "handler", handlerName, "errors", summary.Errors)
+		}
+		s.render(w, r, http.StatusOK, summary)
+	}
+}
+
+// ############# END K8S HANDLERS
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+)
+
+const testK8sToken = "a-service-account-token"
+
+// newFakeK8sApi starts a tls server answering like the k8s api for the given paths and returns a client trusting it
+func newFakeK8sApi(t *testing.T, objects map[string]string) (*httptest.Server, *K8sClient) {
+	api := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testK8sToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"kind":"Status","reason":"Unauthorized","code":401}`)
+			return
+		}
+		obj, exist := objects[r.URL.Path]
+		if !exist {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"kind":"Status","reason":"NotFound","code":404}`)
+			return
+		}
+		w.Header().Set(HeaderContentType, MIMEAppJSON)
+		fmt.Fprint(w, obj)
+	}))
+	return api, newTestK8sClient(t, api, testK8sToken+"\n", "test-go-cloud-k8s-info\n")
+}
+
+// newTestK8sClient returns a K8sClient of namespace using token and trusting the certificate of the tls server api
+func newTestK8sClient(t *testing.T, api *httptest.Server, token, namespace string) *K8sClient {
+	t.Helper()
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: api.Certificate().Raw})
+	client, err := NewK8sClient(api.URL, token, caCert, namespace)
+	if err != nil {
+		t.Fatalf("NewK8sClient() error = %v", err)
+	}
+	return client
+}
+
+func TestK8sClientApiError(t *testing.T) {
+	api, client := newFakeK8sApi(t, map[string]string{})
+	defer api.Close()
+	assert.Equal(t, "test-go-cloud-k8s-info", client.Namespace(), "namespace should be trimmed")
+
+	_, err := client.GetPodJson(context.Background(), "unknown")
+	assert.True(t, apierrors.IsNotFound(err), "a 404 answer should give a NotFound StatusError")
+
+	myServer := NewGoHttpServer(fmt.Sprintf(":%d", config.DefaultPort), getTestLogger())
+	w := httptest.NewRecorder()
+	myServer.k8sErrorResponse(w, "test", err)
+	assert.Equal(t, http.StatusNotFound, w.Code, "the status code of the api should be kept")
+	w = httptest.NewRecorder()
+	myServer.k8sErrorResponse(w, "test", errors.New("connection refused"))
+	assert.Equal(t, http.StatusBadGateway, w.Code, "an error without status should be a bad gateway")
+}
+
+func TestK8sClientBearerTokenFile(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("a-first-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := newK8sClientForConfig(&rest.Config{Host: "https://127.0.0.1:6443", BearerTokenFile: tokenFile}, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "a-first-token", client.bearerToken())
+
+	// the kubelet rotated the projected token
+	if err := os.WriteFile(tokenFile, []byte("a-rotated-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "a-rotated-token", client.bearerToken(), "the identity should show the token in use, read again from its file")
+}
+
+func TestGoHttpServerK8sPodHandler(t *testing.T) {
+	t.Setenv("MY_POD_NAME", "go-info-server-1")
+	api, client := newFakeK8sApi(t, map[string]string{
+		"/api/v1/namespaces/test-go-cloud-k8s-info/pods/go-info-server-1": `{"kind":"Pod","metadata":{"name":"go-info-server-1",
+"ownerReferences":[{"kind":"ReplicaSet","name":"go-info-server-6d4cf56db6"}]},"status":{"containerStatuses":[{"name":"go-info-server","ready":true}]}}`,
+	})
+	defer api.Close()
+	myServer := NewGoHttpServer(fmt.Sprintf(":%d", config.DefaultPort), getTestLogger())
+	myServer.k8s = client
+	ts := httptest.NewServer(Chain(myServer.K8sPodHandler(), myServer.allowMethods(http.MethodGet)))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/k8s/pod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	received, _ := ioutil.ReadAll(resp.Body)
+	var pod map[string]interface{}
+	assert.Nil(t, json.Unmarshal(received, &pod), "the output should be a valid json")
+	assert.Contains(t, string(received), "ownerReferences")
+	assert.Contains(t, string(received), "containerStatuses")
+
+	resp, err = http.Post(ts.URL+"/k8s/pod", MIMEAppJSON, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode, assertCorrectStatusCodeExpected)
+}
+
+func TestGoHttpServerK8sNodeHandler(t *testing.T) {
+	t.Setenv("MY_POD_NAME", "go-info-server-1")
+	api, client := newFakeK8sApi(t, map[string]string{
+		"/api/v1/namespaces/test-go-cloud-k8s-info/pods/go-info-server-1": `{"kind":"Pod","spec":{"nodeName":"worker-2"}}`,
+		"/api/v1/nodes/worker-2": `{"kind":"Node","metadata":{"name":"worker-2","labels":{"kubernetes.io/arch":"amd64"}},
+"spec":{"taints":[{"key":"dedicated","value":"infra","effect":"PreferNoSchedule"}]},
+"status":{"capacity":{"cpu":"4","memory":"8142108Ki","pods":"110"},"allocatable":{"cpu":"3800m","memory":"7515420Ki","pods":"110"},
+"conditions":[{"type":"MemoryPressure","status":"False","reason":"KubeletHasSufficientMemory"},{"type":"Ready","status":"True"}],
+"nodeInfo":{"kubeletVersion":"v1.24.3+k3s1","osImage":"Ubuntu 22.04.1 LTS","architecture":"amd64"}}}`,
+	})
+	defer api.Close()
+	myServer := NewGoHttpServer(fmt.Sprintf(":%d", config.DefaultPort), getTestLogger())
+	myServer.k8s = client
+	ts := httptest.NewServer(Chain(myServer.K8sNodeHandler(), myServer.allowMethods(http.MethodGet)))
+	defer ts.Close()
+
+	getNode := func() (int, K8sNodeInfo) {
+		resp, err := http.Get(ts.URL + "/k8s/node")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var node K8sNodeInfo
+		json.NewDecoder(resp.Body).Decode(&node)
+		return resp.StatusCode, node
+	}
+	status, node := getNode()
+	assert.Equal(t, http.StatusOK, status, "the node name should be found in the spec of the pod")
+	assert.Equal(t, "worker-2", node.Name)
+	assert.Equal(t, "v1.24.3+k3s1", node.KubeletVersion)
+	assert.Equal(t, "3800m", node.Allocatable["cpu"])
+	assert.Equal(t, "4", node.Capacity["cpu"])
+	assert.Equal(t, []K8sTaint{{Key: "dedicated", Value: "infra", Effect: "PreferNoSchedule"}}, node.Taints)
+	assert.Len(t, node.Conditions, 2)
+
+	t.Setenv("MY_NODE_NAME", "worker-3")
+	status, _ = getNode()
+	assert.Equal(t, http.StatusNotFound, status, "the node name of the Downward API should be used first")
+}
+
+func TestK8sClientGetNamespaceSummary(t *testing.T) {
+	objects := map[string]string{
+		"/api/v1/namespaces/test-go-cloud-k8s-info/pods": `{"kind":"PodList","items":[{"metadata":{"name":"go-info-server-1"},
+"spec":{"nodeName":"worker-2"},"status":{"phase":"Running","podIP":"10.42.0.12","containerStatuses":[{"ready":true,"restartCount":2},{"ready":false,"restartCount":1}]}}]}`,
+		"/api/v1/namespaces/test-go-cloud-k8s-info/services": `{"kind":"ServiceList","items":[{"metadata":{"name":"go-info-service"},
+"spec":{"type":"ClusterIP","clusterIP":"10.43.12.7","ports":[{"port":80,"protocol":"TCP","targetPort":8000},{"port":8081,"protocol":"TCP","targetPort":"admin"}]}}]}`,
+	}
+	api, client := newFakeK8sApi(t, objects)
+	summary, err := client.GetNamespaceSummary(context.Background())
+	api.Close()
+	if assert.NoError(t, err, "a list that cannot be read should not fail the summary") {
+		assert.Equal(t, "test-go-cloud-k8s-info", summary.Namespace)
+		assert.Empty(t, summary.Deployments)
+		assert.Contains(t, summary.Errors["deployments"], "could not find the requested resource")
+		assert.Equal(t, []K8sPodSummary{{Name: "go-info-server-1", Phase: "Running", ReadyContainers: 1, Containers: 2, Restarts: 3,
+			NodeName: "worker-2", PodIp: "10.42.0.12"}}, summary.Pods)
+		assert.Equal(t, []K8sServiceSummary{{Name: "go-info-service", Type: "ClusterIP", ClusterIp: "10.43.12.7",
+			Ports: []string{"80/TCP->8000", "8081/TCP->admin"}}}, summary.Services)
+	}
+
+	objects["/apis/apps/v1/namespaces/test-go-cloud-k8s-info/deployments"] = `{"kind":"DeploymentList","items":[
+{"metadata":{"name":"go-info-server"},"spec":{"replicas":3},"status":{"readyReplicas":2,"updatedReplicas":3,"availableReplicas":2}},
+{"metadata":{"name":"no-replicas"},"spec":{},"status":{}}]}`
+	api, client = newFakeK8sApi(t, objects)
+	summary, err = client.GetNamespaceSummary(context.Background())
+	api.Close()
+	if assert.NoError(t, err) {
+		assert.Empty(t, summary.Errors)
+		assert.Equal(t, []K8sDeploymentSummary{
+			{Name: "go-info-server", Replicas: 3, ReadyReplicas: 2, UpdatedReplicas: 3, AvailableReplicas: 2},
+			{Name: "no-replicas", Replicas: 1},
+		}, summary.Deployments)
+	}
+
+	api, client = newFakeK8sApi(t, map[string]string{})
+	_, err = client.GetNamespaceSummary(context.Background())
+	api.Close()
+	assert.Error(t, err, "the summary should fail when no list can be read")
+}
+
+func TestGoHttpServerK8sRoutesDisabledOutsideCluster(t *testing.T) {
+	myServer := NewGoHttpServer(fmt.Sprintf(":%d", config.DefaultPort), getTestLogger())
+	assert.Nil(t, myServer.k8s, "no k8s client should be created outside a cluster")
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/k8s/pod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "/k8s/pod should not be routed outside a cluster")
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	k8sEventComponent    = "go-cloud-k8s-info" // source of the events shown by kubectl describe pod
+	k8sEventTypeNormal   = corev1.EventTypeNormal
+	k8sEventTypeWarning  = corev1.EventTypeWarning
+	defaultK8sEventQueue = 32              // events waiting to be sent, the next ones are dropped
+	k8sEventSendTimeout  = 5 * time.Second // maximum time to send one event
+)
+
+// K8sEventRecorder sends Events attached to the pod of this server, so kubectl describe pod tells what it did.
+// Emit never blocks the caller, the events are sent one at a time by Run
+type K8sEventRecorder struct {
+	client *K8sClient
+	pod    string
+	logger *slog.Logger
+	now    func() time.Time // time.Now, replaced in tests
+	queue  chan *corev1.Event
+	mu     sync.Mutex
+	uid    string // uid of the pod, read once from the api
+	host   string // node of the pod
+	failed bool   // the last event could not be sent, to log the error once
+}
+
+// NewK8sEventRecorder is a constructor for a K8sEventRecorder of the pod named pod in the namespace of client
+func NewK8sEventRecorder(client *K8sClient, pod string, logger *slog.Logger) *K8sEventRecorder {
+	return &K8sEventRecorder{client: client, pod: pod, logger: logger, now: time.Now, queue: make(chan *corev1.Event, defaultK8sEventQueue)}
+}
+
+// newEvent returns the Event of reason about the pod, with a unique name like the ones of client-go
+func (er *K8sEventRecorder) newEvent(eventType, reason, message string) *corev1.Event {
+	now := metav1.NewTime(er.now().UTC())
+	return &corev1.Event{
+		ObjectMeta:          metav1.ObjectMeta{Name: er.pod + "." + strconv.FormatInt(now.UnixNano(), 16), Namespace: er.client.Namespace()},
+		InvolvedObject:      corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Name: er.pod, Namespace: er.client.Namespace()},
+		Reason:              reason,
+		Message:             message,
+		Type:                eventType,
+		Source:              corev1.EventSource{Component: k8sEventComponent},
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+		ReportingController: k8sEventComponent,
+		ReportingInstance:   er.pod,
+	}
+}
+
+// Emit queues an Event of eventType (Normal or Warning) to be sent by Run, it is dropped when too many are waiting
+func (er *K8sEventRecorder) Emit(eventType, reason, message string) {
+	select {
+	case er.queue <- er.newEvent(eventType, reason, message):
+	default:
+		er.logger.Warn("too many k8s events waiting, event dropped", "reason", reason)
+	}
+}
+
+// podRef reads once the uid and the node of the pod, kubectl describe only shows the events of the pod uid
+func (er *K8sEventRecorder) podRef(ctx context.Context) (string, string) {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+	if er.uid != "" {
+		return er.uid, er.host
+	}
+	pod, err := er.client.clientset.CoreV1().Pods(er.client.Namespace()).Get(ctx, er.pod, metav1.GetOptions{})
+	if err != nil {
+		er.logger.Debug("unable to read the pod of the k8s events", "pod", er.pod, "error", err)
+		return "", ""
+	}
+	er.uid, er.host = string(pod.UID), pod.Spec.NodeName
+	return er.uid, er.host
+}
+
+// send creates event in the k8s api
+func (er *K8sEventRecorder) send(ctx context.Context, event *corev1.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, k8sEventSendTimeout)
+	defer cancel()
+	uid, host := er.podRef(ctx)
+	event.InvolvedObject.UID, event.Source.Host = types.UID(uid), host
+	_, err := er.client.clientset.CoreV1().Events(er.client.Namespace()).Create(ctx, event, metav1.CreateOptions{})
+	er.mu.Lock()
+	defer er.mu.Unlock()
+	if err != nil && !er.failed {
+		er.logger.Warn("unable to create the k8s event, check the create verb on events in the role of the service account", "reason", event.Reason, "error", err)
+	}
+	er.failed = err != nil
+	return err
+}
+
+// drainQueue passes the items waiting in queue to send until there is none left or ctx is done. a failed send is
+// skipped, it was logged by send, unless it failed because ctx is done
+func drainQueue[T any](ctx context.Context, queue chan T, send func(context.Context, T) error) error {
+	for {
+		select {
+		case item := <-queue:
+			if err := send(ctx, item); err != nil && ctx.Err() != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			return nil
+		}
+	}
+}
+
+// Flush creates in the k8s api the Events still waiting in the queue, the ones the api refuses are dropped
+func (er *K8sEventRecorder) Flush(ctx context.Context) error {
+	return drainQueue(ctx, er.queue, er.send)
+}
+
+// Stop returns a shutdown hook sending at once an Event of reason, then the events still waiting, so the stop
+// of the server is recorded before the process exits
+func (er *K8sEventRecorder) Stop(reason, message string) ShutdownHook {
+	return func(ctx context.Context) error {
+		if err := er.send(ctx, er.newEvent(k8sEventTypeNormal, reason, message)); err != nil {
+			return err
+		}
+		return er.Flush(ctx)
+	}
+}
+
+// Run sends the queued events until ctx is done
+func (er *K8sEventRecorder) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-er.queue:
+			er.send(ctx, event)
+		}
+	}
+}
+
+// lifecycleEvent emits an Event attached to the pod and notifies the webhooks, each of them does nothing when it is
+// not configured
+func (s *GoHttpServer) lifecycleEvent(eventType, reason, message string) {
+	if s.k8sEvents != nil {
+		s.k8sEvents.Emit(eventType, reason, message)
+	}
+	if s.webhooks != nil {
+		s.webhooks.Notify(eventType, reason, message)
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// newFakeEventsApi starts a server answering the pod go-info-0 like the k8s api and storing the events created,
+// it returns a recorder for this pod and a function giving the events received so far
+func newFakeEventsApi(t *testing.T) (*K8sEventRecorder, func() []corev1.Event) {
+	t.Helper()
+	var mu sync.Mutex
+	events := []corev1.Event{}
+	api := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, MIMEAppJSON)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/namespaces/test-go-cloud-k8s-info/pods/go-info-0":
+			w.Write([]byte(`{"metadata":{"uid":"6b1f0c2e"},"spec":{"nodeName":"node-1"}}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/namespaces/test-go-cloud-k8s-info/events":
+			var event corev1.Event
+			if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			mu.Lock()
+			events = append(events, event)
+			mu.Unlock()
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(event)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(api.Close)
+	client := newTestK8sClient(t, api, testK8sToken, "test-go-cloud-k8s-info")
+	recorder := NewK8sEventRecorder(client, "go-info-0", getTestLogger())
+	recorder.now = func() time.Time { return time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC) }
+	return recorder, func() []corev1.Event {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]corev1.Event(nil), events...)
+	}
+}
+
+func TestK8sEventRecorder(t *testing.T) {
+	recorder, received := newFakeEventsApi(t)
+	recorder.Emit(k8sEventTypeNormal, "Started", "listening")
+	recorder.Emit(k8sEventTypeWarning, "ChaosOom", "allocating")
+	assert.NoError(t, recorder.Flush(context.Background()))
+	assert.NoError(t, recorder.Stop("ShuttingDown", "stopped")(context.Background()))
+
+	events := received()
+	if !assert.Len(t, events, 3) {
+		return
+	}
+	tests := []struct {
+		name       string
+		event      corev1.Event
+		wantReason string
+		wantType   string
+	}{
+		{name: "1: the first queued event should be sent first", event: events[0], wantReason: "Started", wantType: "Normal"},
+		{name: "2: a warning should keep its type", event: events[1], wantReason: "ChaosOom", wantType: "Warning"},
+		{name: "3: the stop hook should send its event", event: events[2], wantReason: "ShuttingDown", wantType: "Normal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantReason, tt.event.Reason)
+			assert.Equal(t, tt.wantType, tt.event.Type)
+			assert.Equal(t, corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Name: "go-info-0", Namespace: "test-go-cloud-k8s-info", UID: "6b1f0c2e"},
+				tt.event.InvolvedObject, "the event should be attached to the pod uid to be shown by kubectl describe")
+			assert.Equal(t, "node-1", tt.event.Source.Host)
+			assert.Equal(t, time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC), tt.event.FirstTimestamp.UTC())
+			assert.Contains(t, tt.event.Name, "go-info-0.")
+		})
+	}
+}
+
+func TestK8sEventRecorderQueue(t *testing.T) {
+	recorder, received := newFakeEventsApi(t)
+	for i := 0; i < defaultK8sEventQueue+5; i++ {
+		recorder.Emit(k8sEventTypeNormal, "Started", "listening")
+	}
+	assert.Len(t, recorder.queue, defaultK8sEventQueue, "the events above the queue size should be dropped without blocking")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go recorder.Run(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(received()) < defaultK8sEventQueue && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Len(t, received(), defaultK8sEventQueue, "Run should send the queued events")
+}
+
+func TestGoHttpServerProbeToggleEvent(t *testing.T) {
+	recorder, received := newFakeEventsApi(t)
+	myServer := &GoHttpServer{logger: getTestLogger(), settings: NewConfigReloader(config.DefaultConfig(), getTestLogger()), k8sEvents: recorder}
+	toggle := NewProbeToggle("readiness", NewReadinessRunner(time.Second))
+	ts := httptest.NewServer(myServer.ProbeToggleHandler(toggle))
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"?state=down", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	assert.NoError(t, recorder.Flush(context.Background()))
+	if events := received(); assert.Len(t, events, 1) {
+		assert.Equal(t, "ProbeSwitchedDown", events[0].Reason)
+		assert.Equal(t, "Warning", events[0].Type)
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// K8sTokenInfo contains the claims of the service account token, which is a jwt signed by the api server
+type K8sTokenInfo struct {
+	Issuer    string                 `json:"issuer"`
+	Subject   string                 `json:"subject"`
+	Audiences []string               `json:"audiences"`
+	IssuedAt  *time.Time             `json:"issued_at,omitempty"`
+	ExpiresAt *time.Time             `json:"expires_at,omitempty"` // absent for the legacy tokens of the secrets
+	ExpiresIn string                 `json:"expires_in,omitempty"`
+	Expired   bool                   `json:"expired"`
+	Claims    map[string]interface{} `json:"claims"`
+}
+
+// K8sAccessCheck is the answer of a SelfSubjectAccessReview for one verb on one resource
+type K8sAccessCheck struct {
+	Verb      string `json:"verb"`
+	Group     string `json:"group,omitempty"`
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace,omitempty"` // empty for the cluster scoped resources
+	Allowed   bool   `json:"allowed"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// K8sResourceRule is one rule of a SelfSubjectRulesReview
+type K8sResourceRule struct {
+	Verbs         []string `json:"verbs"`
+	ApiGroups     []string `json:"apiGroups,omitempty"`
+	Resources     []string `json:"resources,omitempty"`
+	ResourceNames []string `json:"resourceNames,omitempty"`
+}
+
+// K8sNonResourceRule is one rule on the non resource urls of a SelfSubjectRulesReview
+type K8sNonResourceRule struct {
+	Verbs           []string `json:"verbs"`
+	NonResourceURLs []string `json:"nonResourceURLs,omitempty"`
+}
+
+// K8sRulesReview is the list of what the service account may do in a namespace, Incomplete is true when
+// the api server uses an authorizer that cannot list its rules, like a webhook
+type K8sRulesReview struct {
+	ResourceRules    []K8sResourceRule    `json:"resource_rules"`
+	NonResourceRules []K8sNonResourceRule `json:"non_resource_rules"`
+	Incomplete       bool                 `json:"incomplete"`
+	EvaluationError  string               `json:"evaluation_error,omitempty"`
+}
+
+// K8sIdentity is who this pod is for the api server and what it is allowed to do
+type K8sIdentity struct {
+	Namespace string            `json:"namespace"`
+	Token     *K8sTokenInfo     `json:"token,omitempty"`
+	Access    []K8sAccessCheck  `json:"access"`
+	Rules     *K8sRulesReview   `json:"rules,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"` // error of each part that could not be obtained
+}
+
+// k8sAccessChecks are the permissions needed by the /k8s endpoints of this server
+var k8sAccessChecks = []K8sAccessCheck{
+	{Verb: "get", Resource: "pods"},
+	{Verb: "list", Resource: "pods"},
+	{Verb: "list", Resource: "services"},
+	{Verb: "list", Group: "apps", Resource: "deployments"},
+	{Verb: "get", Resource: "nodes"},
+}
+
+// ParseServiceAccountToken returns the claims of a jwt token without verifying its signature, the api server does it
+func ParseServiceAccountToken(token string, now time.Time) (*K8sTokenInfo, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, errors.New("the token is not a jwt")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwt payload encoding : %w", err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("invalid jwt payload : %w", err)
+	}
+	info := K8sTokenInfo{Audiences: []string{}, Claims: claims}
+	info.Issuer, _ = claims["iss"].(string)
+	info.Subject, _ = claims["sub"].(string)
+	switch aud := claims["aud"].(type) {
+	case string:
+		info.Audiences = append(info.Audiences, aud)
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				info.Audiences = append(info.Audiences, s)
+			}
+		}
+	}
+	if iat, ok := claims["iat"].(float64); ok {
+		t := time.Unix(int64(iat), 0).UTC()
+		info.IssuedAt = &t
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		t := time.Unix(int64(exp), 0).UTC()
+		info.ExpiresAt = &t
+		info.Expired = !now.Before(t)
+		if !info.Expired {
+			info.ExpiresIn = t.Sub(now).Round(time.Second).String()
+		}
+	}
+	return &info, nil
+}
+
+// AccessReview asks the api server with a SelfSubjectAccessReview if the service account may do check.Verb
+// on check.Resource and returns check with Allowed and Reason filled
+func (c *K8sClient) AccessReview(ctx context.Context, check K8sAccessCheck) (K8sAccessCheck, error) {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: check.Namespace,
+				Verb:      check.Verb,
+				Group:     check.Group,
+				Resource:  check.Resource,
+			},
+		},
+	}
+	answer, err := c.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return check, err
+	}
+	check.Allowed, check.Reason = answer.Status.Allowed, answer.Status.Reason
+	return check, nil
+}
+
+// RulesReview returns the rules of the service account in its namespace with a SelfSubjectRulesReview
+func (c *K8sClient) RulesReview(ctx context.Context) (*K8sRulesReview, error) {
+	review := &authorizationv1.SelfSubjectRulesReview{Spec: authorizationv1.SelfSubjectRulesReviewSpec{Namespace: c.namespace}}
+	answer, err := c.clientset.AuthorizationV1().SelfSubjectRulesReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	rules := K8sRulesReview{
+		ResourceRules:    make([]K8sResourceRule, 0, len(answer.Status.ResourceRules)),
+		NonResourceRules: make([]K8sNonResourceRule, 0, len(answer.Status.NonResourceRules)),
+		Incomplete:       answer.Status.Incomplete,
+		EvaluationError:  answer.Status.EvaluationError,
+	}
+	for _, r := range answer.Status.ResourceRules {
+		rules.ResourceRules = append(rules.ResourceRules, K8sResourceRule{Verbs: r.Verbs, ApiGroups: r.APIGroups, Resources: r.Resources, ResourceNames: r.ResourceNames})
+	}
+	for _, r := range answer.Status.NonResourceRules {
+		rules.NonResourceRules = append(rules.NonResourceRules, K8sNonResourceRule{Verbs: r.Verbs, NonResourceURLs: r.NonResourceURLs})
+	}
+	return &rules, nil
+}
+
+// GetIdentity returns the claims of the token of the service account, the answers of the access reviews of the
+// permissions needed by this server and the rules of the service account in its namespace
+func (c *K8sClient) GetIdentity(ctx context.Context, now time.Time) *K8sIdentity {
+	identity := K8sIdentity{Namespace: c.namespace, Access: []K8sAccessCheck{}, Errors: make(map[string]string)}
+	var err error
+	if identity.Token, err = ParseServiceAccountToken(c.bearerToken(), now); err != nil {
+		identity.Errors["token"] = err.Error()
+	}
+	for _, check := range k8sAccessChecks {
+		if check.Resource != "nodes" {
+			check.Namespace = c.namespace
+		}
+		answer, err := c.AccessReview(ctx, check)
+		if err != nil {
+			identity.Errors["access"] = err.Error()
+			break
+		}
+		identity.Access = append(identity.Access, answer)
+	}
+	if identity.Rules, err = c.RulesReview(ctx); err != nil {
+		identity.Errors["rules"] = err.Error()
+	}
+	return &identity
+}
+
+// K8sIdentityHandler returns the claims of the service account token of this pod and what it is allowed to do
+func (s *GoHttpServer) K8sIdentityHandler() http.HandlerFunc {
+	handlerName := "K8sIdentityHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity := s.k8s.GetIdentity(r.Context(), time.Now())
+		if len(identity.Errors) > 0 {
+			s.logger.WarnContext(r.Context(), "k8s identity is incomplete", "handler", handlerName, "errors", identity.Errors)
+		}
+		s.render(w, r, http.StatusOK, identity)
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestJwt returns an unsigned jwt with the given claims, enough for ParseServiceAccountToken
+func newTestJwt(claims string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"RS256","kid":"test"}`)) + "." + enc.EncodeToString([]byte(claims)) + ".c2lnbmF0dXJl"
+}
+
+func TestParseServiceAccountToken(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name          string
+		token         string
+		wantErr       bool
+		wantAudiences []string
+		wantExpired   bool
+		wantExpiresIn string
+	}{
+		{name: "1: projected token should give its audiences and expiry",
+			token:         newTestJwt(`{"aud":["https://kubernetes.default.svc"],"exp":1700003600,"iat":1699996400,"iss":"https://kubernetes.default.svc","sub":"system:serviceaccount:test:default"}`),
+			wantAudiences: []string{"https://kubernetes.default.svc"}, wantExpiresIn: "1h0m0s"},
+		{name: "2: a single audience string should be accepted", token: newTestJwt(`{"aud":"vault","exp":1699999999}`),
+			wantAudiences: []string{"vault"}, wantExpired: true},
+		{name: "3: legacy token without exp should not expire", token: newTestJwt(`{"iss":"kubernetes/serviceaccount"}`), wantAudiences: []string{}},
+		{name: "4: a token which is not a jwt should be an error", token: "opaque-token", wantErr: true},
+		{name: "5: an invalid payload should be an error", token: "a.!!!.c", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ParseServiceAccountToken(tt.token, now)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.wantAudiences, info.Audiences)
+				assert.Equal(t, tt.wantExpired, info.Expired)
+				assert.Equal(t, tt.wantExpiresIn, info.ExpiresIn)
+			}
+		})
+	}
+}
+
+func TestK8sClientGetIdentity(t *testing.T) {
+	token := newTestJwt(`{"aud":["https://kubernetes.default.svc"],"exp":4102444800,"sub":"system:serviceaccount:test-go-cloud-k8s-info:default"}`)
+	api := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set(HeaderContentType, MIMEAppJSON)
+		body, _ := io.ReadAll(r.Body)
+		var review struct {
+			Spec struct {
+				ResourceAttributes struct {
+					Resource string `json:"resource"`
+				} `json:"resourceAttributes"`
+			} `json:"spec"`
+		}
+		json.Unmarshal(body, &review)
+		switch r.URL.Path {
+		case "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews":
+			allowed := review.Spec.ResourceAttributes.Resource == "pods"
+			fmt.Fprintf(w, `{"kind":"SelfSubjectAccessReview","status":{"allowed":%t,"reason":"RBAC"}}`, allowed)
+		case "/apis/authorization.k8s.io/v1/selfsubjectrulesreviews":
+			fmt.Fprint(w, `{"kind":"SelfSubjectRulesReview","status":{"resourceRules":[{"verbs":["get","list"],"apiGroups":[""],"resources":["pods"]}],
+"nonResourceRules":[{"verbs":["get"],"nonResourceURLs":["/healthz"]}],"incomplete":false}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer api.Close()
+	client := newTestK8sClient(t, api, token, "test-go-cloud-k8s-info")
+
+	identity := client.GetIdentity(context.Background(), time.Now())
+	assert.Empty(t, identity.Errors)
+	if assert.NotNil(t, identity.Token) {
+		assert.Equal(t, "system:serviceaccount:test-go-cloud-k8s-info:default", identity.Token.Subject)
+		assert.False(t, identity.Token.Expired)
+	}
+	if assert.Len(t, identity.Access, len(k8sAccessChecks)) {
+		assert.True(t, identity.Access[0].Allowed)
+		assert.Equal(t, "test-go-cloud-k8s-info", identity.Access[0].Namespace)
+		assert.False(t, identity.Access[4].Allowed, "nodes should not be allowed")
+		assert.Empty(t, identity.Access[4].Namespace, "nodes are cluster scoped")
+	}
+	if assert.NotNil(t, identity.Rules) {
+		assert.Equal(t, []string{"pods"}, identity.Rules.ResourceRules[0].Resources)
+		assert.Equal(t, []string{"/healthz"}, identity.Rules.NonResourceRules[0].NonResourceURLs)
+	}
+
+	client = newTestK8sClient(t, api, "another-token", "test-go-cloud-k8s-info")
+	identity = client.GetIdentity(context.Background(), time.Now())
+	assert.Contains(t, identity.Errors, "token")
+	assert.Contains(t, identity.Errors, "access")
+	assert.Contains(t, identity.Errors, "rules")
+}
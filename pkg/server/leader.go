@@ -0,0 +1,259 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultLeaderRetryPeriod = 2 * time.Second // time between two attempts to acquire or renew the lease
+	defaultLeaderTransitions = 50              // transitions kept for /leader
+)
+
+// leaseHolder returns the holder identity of the lease, empty when it is released
+func leaseHolder(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// LeaderTransition is a change of the leader seen by this replica
+type LeaderTransition struct {
+	Time time.Time `json:"time"`
+	From string    `json:"from,omitempty"` // empty when the lease was free
+	To   string    `json:"to,omitempty"`   // empty when the leader released the lease
+}
+
+// LeaderReport is the state of the leader election seen by this replica
+type LeaderReport struct {
+	Lease            string             `json:"lease"` // namespace/name of the Lease
+	Identity         string             `json:"identity"`
+	IsLeader         bool               `json:"is_leader"`
+	Leader           string             `json:"leader,omitempty"`
+	LeaseDuration    float64            `json:"lease_duration_seconds"`
+	LeaseTransitions int                `json:"lease_transitions"`
+	RenewTime        string             `json:"renew_time,omitempty"`
+	LastError        string             `json:"last_error,omitempty"`
+	Transitions      []LeaderTransition `json:"transitions"` // oldest first
+}
+
+// LeaderElector elects a leader among the replicas sharing a Lease, like the leader election of client-go :
+// the holder renews the lease, the others take it when it was not renewed during its duration. the time of
+// expiry is measured with the local clock from the last change seen, so the clocks of the replicas may differ
+type LeaderElector struct {
+	client        *K8sClient
+	name          string
+	identity      string
+	leaseDuration time.Duration
+	retryPeriod   time.Duration
+	logger        *slog.Logger
+	now           func() time.Time // time.Now, replaced in tests
+	mu            sync.RWMutex
+	observed      coordinationv1.LeaseSpec // spec of the lease at the last change seen
+	observedTime  time.Time                // local time of the last change seen
+	report        LeaderReport
+}
+
+// NewLeaderElector is a constructor for a LeaderElector of identity on the Lease name of the namespace of client
+func NewLeaderElector(client *K8sClient, name string, identity string, leaseDuration time.Duration, logger *slog.Logger) *LeaderElector {
+	return &LeaderElector{
+		client:        client,
+		name:          name,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		retryPeriod:   defaultLeaderRetryPeriod,
+		logger:        logger,
+		now:           time.Now,
+		report: LeaderReport{
+			Lease:         client.Namespace() + "/" + name,
+			Identity:      identity,
+			LeaseDuration: leaseDuration.Seconds(),
+			Transitions:   []LeaderTransition{},
+		},
+	}
+}
+
+// IsLeader tells if this replica holds the lease
+func (le *LeaderElector) IsLeader() bool {
+	le.mu.RLock()
+	defer le.mu.RUnlock()
+	return le.report.IsLeader
+}
+
+// Report returns the state of the election and the transitions seen
+func (le *LeaderElector) Report() LeaderReport {
+	le.mu.RLock()
+	defer le.mu.RUnlock()
+	report := le.report
+	report.Transitions = slices.Clone(le.report.Transitions)
+	return report
+}
+
+// observe records the lease read or written, it must be called with the lock held
+func (le *LeaderElector) observe(lease *coordinationv1.Lease, now time.Time) {
+	holder := leaseHolder(lease)
+	if holder != le.report.Leader {
+		le.report.Transitions = append(le.report.Transitions, LeaderTransition{Time: now.UTC(), From: le.report.Leader, To: holder})
+		if len(le.report.Transitions) > defaultLeaderTransitions {
+			le.report.Transitions = le.report.Transitions[len(le.report.Transitions)-defaultLeaderTransitions:]
+		}
+		switch {
+		case holder == le.identity:
+			le.logger.Info("became the leader", "lease", le.report.Lease, "identity", le.identity)
+		case le.report.Leader == le.identity:
+			le.logger.Warn("lost the leadership", "lease", le.report.Lease, "leader", holder)
+		}
+	}
+	if !equalLeaseSpec(lease.Spec, le.observed) {
+		le.observed, le.observedTime = lease.Spec, now
+	}
+	le.report.Leader, le.report.IsLeader = holder, holder == le.identity
+	le.report.LeaseTransitions = int(derefInt32(lease.Spec.LeaseTransitions))
+	le.report.RenewTime = microTimeString(lease.Spec.RenewTime)
+}
+
+func derefInt32(v *int32) int32 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+func derefString(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+// microTimeString returns the time of the lease as the api serializes it, empty when it is not set
+func microTimeString(t *metav1.MicroTime) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(metav1.RFC3339Micro)
+}
+
+func equalLeaseSpec(a, b coordinationv1.LeaseSpec) bool {
+	return derefString(a.HolderIdentity) == derefString(b.HolderIdentity) && microTimeString(a.RenewTime) == microTimeString(b.RenewTime) &&
+		microTimeString(a.AcquireTime) == microTimeString(b.AcquireTime) && derefInt32(a.LeaseTransitions) == derefInt32(b.LeaseTransitions)
+}
+
+// tryAcquireOrRenew creates the lease, renews it when this replica holds it or takes it when it expired
+func (le *LeaderElector) tryAcquireOrRenew(ctx context.Context) error {
+	now := le.now()
+	nowTime := metav1.NewMicroTime(now.UTC())
+	seconds := int32(le.leaseDuration.Seconds())
+	leases := le.client.clientset.CoordinationV1().Leases(le.client.Namespace())
+	lease, err := leases.Get(ctx, le.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		transitions := int32(0)
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: le.name, Namespace: le.client.Namespace()},
+			Spec: coordinationv1.LeaseSpec{HolderIdentity: &le.identity, LeaseDurationSeconds: &seconds, AcquireTime: &nowTime,
+				RenewTime: &nowTime, LeaseTransitions: &transitions},
+		}
+		written, err := leases.Create(ctx, lease, metav1.CreateOptions{})
+		return le.recordWrite(written, err, now)
+	}
+	if err != nil {
+		return err
+	}
+	le.mu.Lock()
+	le.observe(lease, now)
+	expired := now.After(le.observedTime.Add(le.leaseDuration))
+	le.mu.Unlock()
+	holder := leaseHolder(lease)
+	if holder != "" && holder != le.identity && !expired {
+		return nil
+	}
+	if holder != le.identity {
+		transitions := derefInt32(lease.Spec.LeaseTransitions) + 1
+		lease.Spec.LeaseTransitions, lease.Spec.AcquireTime = &transitions, &nowTime
+	}
+	lease.Spec.HolderIdentity, lease.Spec.LeaseDurationSeconds, lease.Spec.RenewTime = &le.identity, &seconds, &nowTime
+	// the resourceVersion read makes the api refuse the update with a conflict when another replica changed the lease
+	written, err := leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return le.recordWrite(written, err, now)
+}
+
+// recordWrite observes the lease written by the api, or returns the error of the write
+func (le *LeaderElector) recordWrite(written *coordinationv1.Lease, err error, now time.Time) error {
+	if err != nil {
+		return err
+	}
+	le.mu.Lock()
+	le.observe(written, now)
+	le.mu.Unlock()
+	return nil
+}
+
+// release frees the lease when this replica holds it, so another one takes it at once instead of waiting its expiry
+func (le *LeaderElector) release(ctx context.Context) error {
+	leases := le.client.clientset.CoordinationV1().Leases(le.client.Namespace())
+	lease, err := leases.Get(ctx, le.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if leaseHolder(lease) != le.identity {
+		return nil
+	}
+	empty, oneSecond := "", int32(1)
+	lease.Spec.HolderIdentity, lease.Spec.LeaseDurationSeconds = &empty, &oneSecond
+	written, err := leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return le.recordWrite(written, err, le.now())
+}
+
+// Run takes part in the election until ctx is done, then releases the lease when this replica holds it
+func (le *LeaderElector) Run(ctx context.Context) {
+	le.logger.Info("Taking part in the leader election", "lease", le.report.Lease, "identity", le.identity, "lease_duration", le.leaseDuration.String())
+	ticker := time.NewTicker(le.retryPeriod)
+	defer ticker.Stop()
+	for {
+		err := le.tryAcquireOrRenew(ctx)
+		le.mu.Lock()
+		if err != nil && ctx.Err() == nil {
+			le.report.LastError = err.Error()
+			if le.report.IsLeader && le.now().After(le.observedTime.Add(le.leaseDuration)) {
+				// the lease could not be renewed in time, another replica may already hold it
+				le.logger.Warn("lost the leadership, the lease was not renewed", "lease", le.report.Lease, "error", err)
+				le.report.IsLeader = false
+			}
+		} else if err == nil {
+			le.report.LastError = ""
+		}
+		le.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			releaseCtx, cancel := context.WithTimeout(context.Background(), le.retryPeriod)
+			if err := le.release(releaseCtx); err != nil {
+				le.logger.Warn("unable to release the lease", "lease", le.report.Lease, "error", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//############# BEGIN K8S HANDLERS
+
+// LeaderHandler returns the current leader and the transitions seen by this replica
+func (s *GoHttpServer) LeaderHandler(elector *LeaderElector) http.HandlerFunc {
+	handlerName := "LeaderHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, r, http.StatusOK, elector.Report())
+	}
+}
+
+// ############# END K8S HANDLERS
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+)
+
+// newFakeLeaseApi starts a server storing one Lease like the k8s api, refusing with a conflict the updates
+// of an outdated resourceVersion, and returns a client for it
+func newFakeLeaseApi(t *testing.T) *K8sClient {
+	t.Helper()
+	var mu sync.Mutex
+	var stored *coordinationv1.Lease
+	version := 0
+	api := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set(HeaderContentType, MIMEAppJSON)
+		if r.Method == http.MethodGet {
+			if stored == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(stored)
+			return
+		}
+		var lease coordinationv1.Lease
+		if err := json.NewDecoder(r.Body).Decode(&lease); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && stored != nil:
+			w.WriteHeader(http.StatusConflict)
+			return
+		case r.Method == http.MethodPut && (stored == nil || lease.ResourceVersion != stored.ResourceVersion):
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		version++
+		lease.ResourceVersion = strconv.Itoa(version)
+		stored = &lease
+		json.NewEncoder(w).Encode(stored)
+	}))
+	t.Cleanup(api.Close)
+	return newTestK8sClient(t, api, testK8sToken, "test-go-cloud-k8s-info")
+}
+
+func TestLeaderElector(t *testing.T) {
+	client := newFakeLeaseApi(t)
+	now := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	first := NewLeaderElector(client, "go-info", "pod-a", 15*time.Second, getTestLogger())
+	second := NewLeaderElector(client, "go-info", "pod-b", 15*time.Second, getTestLogger())
+	first.now, second.now = clock, clock
+	ctx := context.Background()
+
+	assert.NoError(t, first.tryAcquireOrRenew(ctx))
+	assert.NoError(t, second.tryAcquireOrRenew(ctx))
+	now = now.Add(10 * time.Second)
+	assert.NoError(t, first.tryAcquireOrRenew(ctx), "the leader should renew its lease")
+	now = now.Add(10 * time.Second)
+	assert.NoError(t, second.tryAcquireOrRenew(ctx))
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{name: "1: the first replica should be elected", got: first.IsLeader(), want: true},
+		{name: "2: the second replica should follow", got: second.IsLeader(), want: false},
+		{name: "3: the second replica should know the leader", got: second.Report().Leader, want: "pod-a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.got)
+		})
+	}
+
+	// the leader stops renewing, the lease renewed at 10s expires for the second replica at 35s
+	now = now.Add(16 * time.Second)
+	assert.NoError(t, second.tryAcquireOrRenew(ctx))
+	assert.True(t, second.IsLeader(), "the expired lease should be taken")
+	report := second.Report()
+	assert.Equal(t, 1, report.LeaseTransitions)
+	if assert.Len(t, report.Transitions, 2) {
+		assert.Equal(t, LeaderTransition{Time: now, From: "pod-a", To: "pod-b"}, report.Transitions[1])
+	}
+
+	assert.NoError(t, first.tryAcquireOrRenew(ctx))
+	assert.False(t, first.IsLeader(), "the former leader should see it lost the lease")
+
+	assert.NoError(t, second.release(ctx))
+	assert.NoError(t, first.tryAcquireOrRenew(ctx))
+	assert.True(t, first.IsLeader(), "a released lease should be taken at once")
+}
+
+func TestGoHttpServerLeaderHandler(t *testing.T) {
+	client := newFakeLeaseApi(t)
+	elector := NewLeaderElector(client, "go-info", "pod-a", 15*time.Second, getTestLogger())
+	assert.NoError(t, elector.tryAcquireOrRenew(context.Background()))
+	myServer := &GoHttpServer{logger: getTestLogger(), settings: NewConfigReloader(config.DefaultConfig(), getTestLogger())}
+	ts := httptest.NewServer(myServer.LeaderHandler(elector))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var report LeaderReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("the output should be a valid json : %v", err)
+	}
+	assert.Equal(t, "test-go-cloud-k8s-info/go-info", report.Lease)
+	assert.True(t, report.IsLeader)
+	assert.NotEmpty(t, report.RenewTime)
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultProcSelfLimits = "/proc/self/limits"
+	defaultSysDir         = "/sys"
+	limitsWarnNofile      = 4096 // a soft limit of open files below this is warned
+	limitsWarnSomaxconn   = 128  // the old default of the kernels before 5.4
+)
+
+// rlimitNames maps the names of /proc/self/limits to the resource names of ulimit and setrlimit
+var rlimitNames = map[string]string{
+	"Max cpu time": "cpu", "Max file size": "fsize", "Max data size": "data", "Max stack size": "stack",
+	"Max core file size": "core", "Max resident set": "rss", "Max processes": "nproc", "Max open files": "nofile",
+	"Max locked memory": "memlock", "Max address space": "as", "Max file locks": "locks",
+	"Max pending signals": "sigpending", "Max msgqueue size": "msgqueue", "Max nice priority": "nice",
+	"Max realtime priority": "rtprio", "Max realtime timeout": "rttime",
+}
+
+// limitsSysctls are the kernel parameters shown by /info/limits, the net ones belong to the network namespace of the pod,
+// the others are the values of the node
+var limitsSysctls = []string{
+	"net.core.somaxconn", "net.ipv4.ip_local_port_range", "net.ipv4.tcp_max_syn_backlog", "net.ipv4.tcp_fin_timeout",
+	"net.ipv4.tcp_tw_reuse", "fs.file-max", "fs.file-nr", "fs.nr_open", "vm.max_map_count", "vm.overcommit_memory",
+	"vm.swappiness", "kernel.pid_max",
+}
+
+// Rlimit is a resource limit of this process, Soft and Hard are a number or unlimited
+type Rlimit struct {
+	Resource string `json:"resource"` // name used by ulimit and setrlimit like nofile
+	Name     string `json:"name"`     // name of /proc/self/limits like Max open files
+	Soft     string `json:"soft"`
+	Hard     string `json:"hard"`
+	Unit     string `json:"unit,omitempty"`
+}
+
+// Sysctl is a kernel parameter read from /proc/sys
+type Sysctl struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+	Error string `json:"error,omitempty"` // the parameter is missing in this kernel or not readable
+}
+
+// TransparentHugepages is the transparent huge pages setting of the node, the mode is the one in brackets
+type TransparentHugepages struct {
+	Enabled string `json:"enabled"` // always, madvise or never
+	Defrag  string `json:"defrag"`
+}
+
+// LimitsInfo is the answer of /info/limits, to verify the tuning of the node from inside a pod
+type LimitsInfo struct {
+	Rlimits   []Rlimit              `json:"rlimits"`
+	Sysctls   []Sysctl              `json:"sysctls"`
+	Hugepages *TransparentHugepages `json:"transparent_hugepages,omitempty"` // omitted when the kernel has no THP
+	Warnings  []string              `json:"warnings,omitempty"`
+	Errors    []string              `json:"errors,omitempty"` // problems met while collecting the information
+}
+
+// ReadRlimits returns the limits of the /proc/[pid]/limits file at path, the columns are found with the header
+// because the names contain spaces and the units may be empty
+func ReadRlimits(path string) ([]Rlimit, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("empty %s", path)
+	}
+	header := scanner.Text()
+	soft, hard, units := strings.Index(header, "Soft Limit"), strings.Index(header, "Hard Limit"), strings.Index(header, "Units")
+	if soft < 0 || hard < soft || units < hard {
+		return nil, fmt.Errorf("unexpected header in %s", path)
+	}
+	column := func(line string, from, to int) string {
+		if from >= len(line) {
+			return ""
+		}
+		return strings.TrimSpace(line[from:min(to, len(line))])
+	}
+	var limits []Rlimit
+	for scanner.Scan() {
+		line := scanner.Text()
+		l := Rlimit{Name: column(line, 0, soft), Soft: column(line, soft, hard), Hard: column(line, hard, units), Unit: column(line, units, len(line))}
+		if l.Name == "" {
+			continue
+		}
+		l.Resource = rlimitNames[l.Name]
+		limits = append(limits, l)
+	}
+	return limits, scanner.Err()
+}
+
+// readSysctl returns the value of the kernel parameter name like net.core.somaxconn, read in the sys directory of procDir
+func readSysctl(procDir, name string) Sysctl {
+	param := Sysctl{Name: name}
+	content, err := os.ReadFile(filepath.Join(procDir, "sys", strings.ReplaceAll(name, ".", "/")))
+	if err != nil {
+		param.Error = err.Error()
+		return param
+	}
+	param.Value = strings.Join(strings.Fields(string(content)), " ")
+	return param
+}
+
+// selectedMode returns the mode in brackets of a setting of /sys like always [madvise] never
+func selectedMode(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if _, after, found := strings.Cut(string(content), "["); found {
+		mode, _, _ := strings.Cut(after, "]")
+		return mode, nil
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// GetLimitsInfo returns the resource limits of the limits file at limitsPath, the kernel parameters of procDir
+// and the transparent huge pages setting of sysDir
+func GetLimitsInfo(limitsPath, procDir, sysDir string) LimitsInfo {
+	limitsInfo := LimitsInfo{Rlimits: []Rlimit{}, Sysctls: []Sysctl{}}
+	rlimits, err := ReadRlimits(limitsPath)
+	if err != nil {
+		limitsInfo.Errors = append(limitsInfo.Errors, "rlimits: "+err.Error())
+	}
+	limitsInfo.Rlimits = append(limitsInfo.Rlimits, rlimits...)
+	for _, l := range rlimits {
+		if soft, err := strconv.Atoi(l.Soft); err == nil && l.Resource == "nofile" && soft < limitsWarnNofile {
+			limitsInfo.Warnings = append(limitsInfo.Warnings, fmt.Sprintf("the soft limit of open files is %d, a busy server may run out of file descriptors", soft))
+		}
+	}
+	for _, name := range limitsSysctls {
+		param := readSysctl(procDir, name)
+		limitsInfo.Sysctls = append(limitsInfo.Sysctls, param)
+		if val, err := strconv.Atoi(param.Value); err == nil && name == "net.core.somaxconn" && val <= limitsWarnSomaxconn {
+			limitsInfo.Warnings = append(limitsInfo.Warnings, fmt.Sprintf("net.core.somaxconn is %d, the accept queue may overflow under bursts of connections", val))
+		}
+	}
+	thpDir := filepath.Join(sysDir, "kernel", "mm", "transparent_hugepage")
+	if enabled, err := selectedMode(filepath.Join(thpDir, "enabled")); err == nil {
+		limitsInfo.Hugepages = &TransparentHugepages{Enabled: enabled}
+		limitsInfo.Hugepages.Defrag, _ = selectedMode(filepath.Join(thpDir, "defrag"))
+	} else if !os.IsNotExist(err) {
+		limitsInfo.Errors = append(limitsInfo.Errors, "transparent hugepages: "+err.Error())
+	}
+	return limitsInfo
+}
+
+//############# BEGIN INFO HANDLERS
+
+// LimitsHandler returns the resource limits of the process, the kernel parameters and the transparent huge pages
+func (s *GoHttpServer) LimitsHandler(limitsPath, procDir, sysDir string) http.HandlerFunc {
+	handlerName := "LimitsHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, r, http.StatusOK, GetLimitsInfo(limitsPath, procDir, sysDir))
+	}
+}
+
+// ############# END INFO HANDLERS
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const testProcLimits = `Limit                     Soft Limit           Hard Limit           Units     
+Max cpu time              unlimited            unlimited            seconds   
+Max core file size        0                    unlimited            bytes     
+Max processes             23960                23960                processes 
+Max open files            1024                 1048576              files     
+Max nice priority         0                    0                    
+`
+
+// writeTestLimits returns a limits file, a proc and a sys directory with some kernel parameters and the THP settings
+func writeTestLimits(t *testing.T) (string, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"limits":                                     testProcLimits,
+		"proc/sys/net/core/somaxconn":                "4096\n",
+		"proc/sys/net/ipv4/ip_local_port_range":      "32768\t60999\n",
+		"proc/sys/fs/file-max":                       "9223372036854775807\n",
+		"sys/kernel/mm/transparent_hugepage/enabled": "always [madvise] never\n",
+		"sys/kernel/mm/transparent_hugepage/defrag":  "always defer defer+madvise [madvise] never\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "limits"), filepath.Join(dir, "proc"), filepath.Join(dir, "sys")
+}
+
+func TestGetLimitsInfo(t *testing.T) {
+	limitsPath, procDir, sysDir := writeTestLimits(t)
+	limitsInfo := GetLimitsInfo(limitsPath, procDir, sysDir)
+	assert.Empty(t, limitsInfo.Errors)
+	if !assert.Len(t, limitsInfo.Rlimits, 5) {
+		return
+	}
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{name: "1: a limit should be read with its resource name", got: limitsInfo.Rlimits[3],
+			want: Rlimit{Resource: "nofile", Name: "Max open files", Soft: "1024", Hard: "1048576", Unit: "files"}},
+		{name: "2: unlimited should be kept", got: limitsInfo.Rlimits[1],
+			want: Rlimit{Resource: "core", Name: "Max core file size", Soft: "0", Hard: "unlimited", Unit: "bytes"}},
+		{name: "3: a limit without unit should be read", got: limitsInfo.Rlimits[4],
+			want: Rlimit{Resource: "nice", Name: "Max nice priority", Soft: "0", Hard: "0"}},
+		{name: "4: a sysctl should be read", got: limitsInfo.Sysctls[0], want: Sysctl{Name: "net.core.somaxconn", Value: "4096"}},
+		{name: "5: the tab of a range should be replaced", got: limitsInfo.Sysctls[1], want: Sysctl{Name: "net.ipv4.ip_local_port_range", Value: "32768 60999"}},
+		{name: "6: the selected THP modes should be read", got: *limitsInfo.Hugepages, want: TransparentHugepages{Enabled: "madvise", Defrag: "madvise"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.got)
+		})
+	}
+	assert.Equal(t, len(limitsSysctls), len(limitsInfo.Sysctls))
+	assert.NotEmpty(t, limitsInfo.Sysctls[len(limitsSysctls)-1].Error, "a missing sysctl should give its error")
+	assert.Len(t, limitsInfo.Warnings, 1, "a soft limit of 1024 open files should be warned")
+}
+
+func TestGoHttpServerLimitsHandler(t *testing.T) {
+	limitsPath, procDir, sysDir := writeTestLimits(t)
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.LimitsHandler(limitsPath, procDir, sysDir))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var limitsInfo LimitsInfo
+	if err := json.NewDecoder(resp.Body).Decode(&limitsInfo); err != nil {
+		t.Fatalf("the output should be a valid json : %v", err)
+	}
+	assert.Len(t, limitsInfo.Rlimits, 5)
+	assert.NotNil(t, limitsInfo.Hugepages)
+}
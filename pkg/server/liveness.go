@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+const (
+	defaultLivenessCheckTimeout = 2 * time.Second // max time of one liveness check
+	livenessStatusAlive         = "alive"
+	livenessStatusUnhealthy     = "unhealthy"
+)
+
+// GoroutineCheck fails when there are more than Max goroutines, which usually means that they are leaking
+type GoroutineCheck struct {
+	Max int
+}
+
+func (c *GoroutineCheck) Name() string { return "goroutines" }
+func (c *GoroutineCheck) Type() string { return "runtime" }
+
+func (c *GoroutineCheck) Check(_ context.Context) error {
+	if n := runtime.NumGoroutine(); n > c.Max {
+		return fmt.Errorf("%d goroutines running, more than the maximum of %d", n, c.Max)
+	}
+	return nil
+}
+
+// HeapCheck fails when the heap uses more than MaxRatio of the memory limit of the cgroup mounted in CgroupRoot,
+// it always succeeds when the container has no memory limit
+type HeapCheck struct {
+	CgroupRoot string
+	MaxRatio   float64
+}
+
+func (c *HeapCheck) Name() string { return "heap" }
+func (c *HeapCheck) Type() string { return "runtime" }
+
+func (c *HeapCheck) Check(_ context.Context) error {
+	limits := info.GetCgroupLimits(c.CgroupRoot)
+	if limits == nil || limits.MemoryLimitBytes <= 0 {
+		return nil
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	ratio := float64(ms.HeapAlloc) / float64(limits.MemoryLimitBytes)
+	if ratio > c.MaxRatio {
+		return fmt.Errorf("heap of %d bytes uses %.0f%% of the memory limit of %d bytes, more than %.0f%%",
+			ms.HeapAlloc, ratio*100, limits.MemoryLimitBytes, c.MaxRatio*100)
+	}
+	return nil
+}
+
+// SchedulerCheck fails when a new goroutine waits more than MaxDelay before running,
+// the go scheduler being starved by cpu throttling or by goroutines that never yield
+type SchedulerCheck struct {
+	MaxDelay time.Duration
+}
+
+func (c *SchedulerCheck) Name() string { return "scheduler" }
+func (c *SchedulerCheck) Type() string { return "runtime" }
+
+func (c *SchedulerCheck) Check(ctx context.Context) error {
+	start := time.Now()
+	started := make(chan struct{})
+	go close(started)
+	select {
+	case <-started:
+	case <-ctx.Done():
+		return fmt.Errorf("goroutine not scheduled after %s", time.Since(start).Round(time.Millisecond))
+	}
+	if delay := time.Since(start); delay > c.MaxDelay {
+		return fmt.Errorf("goroutine scheduled after %s, more than the maximum of %s", delay.Round(time.Millisecond), c.MaxDelay)
+	}
+	return nil
+}
+
+// LockCheck fails when Locker cannot be acquired before the end of the check, which means that it is held
+// for too long or never released, a lock left in this state blocks all the requests needing it
+type LockCheck struct {
+	CheckName string
+	Locker    sync.Locker
+}
+
+func (c *LockCheck) Name() string { return c.CheckName }
+func (c *LockCheck) Type() string { return "lock" }
+
+func (c *LockCheck) Check(ctx context.Context) error {
+	acquired := make(chan struct{})
+	go func() {
+		// when the lock is stuck this goroutine waits with it, it ends as soon as the lock is released
+		c.Locker.Lock()
+		c.Locker.Unlock()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("lock not acquired before the timeout, possible deadlock")
+	}
+}
+
+// (*GoHttpServer) livenessChecks returns the self checks of /health configured in config, with a LockCheck
+// for each internal lock shared by the requests
+func (s *GoHttpServer) livenessChecks(config config.Config) []HealthChecker {
+	var checks []HealthChecker
+	if config.MaxGoroutines > 0 {
+		checks = append(checks, &GoroutineCheck{Max: config.MaxGoroutines})
+	}
+	if config.MaxHeapRatio > 0 {
+		checks = append(checks, &HeapCheck{CgroupRoot: info.DefaultCgroupRoot, MaxRatio: config.MaxHeapRatio})
+	}
+	if config.MaxSchedDelay > 0 {
+		checks = append(checks, &SchedulerCheck{MaxDelay: config.MaxSchedDelay})
+	}
+	checks = append(checks,
+		&LockCheck{CheckName: "lock_metrics", Locker: &s.metrics.mu},
+		&LockCheck{CheckName: "lock_tokens", Locker: &s.tokens.mu},
+		&LockCheck{CheckName: "lock_rdns", Locker: &s.rdns.mu},
+		&LockCheck{CheckName: "lock_settings", Locker: &s.settings.mu},
+		&LockCheck{CheckName: "lock_readiness", Locker: &s.readiness.mu},
+	)
+	if s.rateLimiter != nil {
+		checks = append(checks, &LockCheck{CheckName: "lock_ratelimit", Locker: &s.rateLimiter.mu})
+	}
+	if s.accessLog != nil {
+		checks = append(checks, &LockCheck{CheckName: "lock_accesslog", Locker: &s.accessLog.mu})
+	}
+	if s.history != nil {
+		checks = append(checks, &LockCheck{CheckName: "lock_requests", Locker: &s.history.mu})
+	}
+	if s.requestStore != nil {
+		checks = append(checks, &LockCheck{CheckName: "lock_requeststore", Locker: &s.requestStore.mu})
+	}
+	return checks
+}
+
+// (*GoHttpServer) healthReport runs the liveness self checks, the status is unhealthy when one of them fails
+func (s *GoHttpServer) healthReport(ctx context.Context) ReadinessReport {
+	report := s.liveness.Run(ctx)
+	report.Status = livenessStatusAlive
+	for _, res := range report.Checks {
+		if res.Status != checkStatusUp {
+			report.Status = livenessStatusUnhealthy
+		}
+	}
+	return report
+}
+
+// HealthHandler runs the liveness self checks and answers 200 when all succeed, 503 otherwise
+// so that the kubelet restarts a server which cannot recover by itself
+func (s *GoHttpServer) HealthHandler() http.HandlerFunc {
+	handlerName := "HealthHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := s.healthReport(r.Context())
+		status := http.StatusOK
+		if report.Status != livenessStatusAlive {
+			status = http.StatusServiceUnavailable
+			s.logger.ErrorContext(r.Context(), "liveness checks failed", "handler", handlerName, "checks", report.Checks)
+		}
+		s.render(w, r, status, report)
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLivenessChecks(t *testing.T) {
+	noLimit, tinyLimit := t.TempDir(), t.TempDir()
+	writeCgroupFiles(t, noLimit, map[string]string{"cgroup.controllers": "cpu memory", "memory.max": "max\n"})
+	writeCgroupFiles(t, tinyLimit, map[string]string{"cgroup.controllers": "cpu memory", "memory.max": "1024\n"})
+	var stuck sync.Mutex
+	stuck.Lock()
+	defer stuck.Unlock()
+
+	tests := []struct {
+		name    string
+		check   HealthChecker
+		wantErr bool
+	}{
+		{name: "1: goroutines below the maximum should succeed", check: &GoroutineCheck{Max: 100000}},
+		{name: "2: goroutines above the maximum should fail", check: &GoroutineCheck{Max: 1}, wantErr: true},
+		{name: "3: heap without memory limit should succeed", check: &HeapCheck{CgroupRoot: noLimit, MaxRatio: 0.1}},
+		{name: "4: heap above the ratio of the limit should fail", check: &HeapCheck{CgroupRoot: tinyLimit, MaxRatio: 0.9}, wantErr: true},
+		{name: "5: heap without cgroup should succeed", check: &HeapCheck{CgroupRoot: t.TempDir(), MaxRatio: 0.1}},
+		{name: "6: a responsive scheduler should succeed", check: &SchedulerCheck{MaxDelay: time.Second}},
+		{name: "7: a free lock should succeed", check: &LockCheck{CheckName: "free", Locker: &sync.Mutex{}}},
+		{name: "8: a lock never released should fail", check: &LockCheck{CheckName: "stuck", Locker: &stuck}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			err := tt.check.Check(ctx)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGoHttpServerHealthChecks(t *testing.T) {
+	getReport := func(router http.Handler) (int, ReadinessReport) {
+		ts := httptest.NewServer(router)
+		defer ts.Close()
+		resp, err := http.Get(ts.URL + "/health")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var report ReadinessReport
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+		return resp.StatusCode, report
+	}
+
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	status, report := getReport(myServer.router)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, livenessStatusAlive, report.Status)
+	assert.NotEmpty(t, report.Checks)
+
+	t.Setenv("LIVENESS_MAX_GOROUTINES", "1")
+	myServer = NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	status, report = getReport(myServer.router)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, livenessStatusUnhealthy, report.Status)
+	for _, res := range report.Checks {
+		if res.Name == "goroutines" {
+			assert.Equal(t, checkStatusDown, res.Status)
+			assert.Contains(t, res.Error, "more than the maximum of 1")
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+const (
+	defaultProcSelfStat = "/proc/self/stat"
+	procClockTicks      = 100 // USER_HZ, the unit of the cpu times in /proc/self/stat on linux
+	maxLoadDuration     = 5 * time.Minute
+	defaultCpuLoadCores = 1
+	defaultLoadDuration = 10 * time.Second
+	maxMemoryLoadMb     = 64 * 1024
+	// fraction of the cgroup memory limit /load/memory refuses to exceed without force, to leave room for the process itself
+	memoryLoadLimitRatio = 0.9
+)
+
+var (
+	errLoadBusy         = errors.New("not enough free cores for this load")
+	errMemoryAboveLimit = errors.New("the allocation would exceed 90% of the cgroup memory limit, use force=true to allocate anyway")
+)
+
+// burnSink receives the result of the cpu burning loop so the compiler cannot drop it
+var burnSink uint64
+
+// readProcessCpuTime returns the user + system cpu time consumed by this process, read from the /proc/self/stat file at path
+func readProcessCpuTime(path string) (time.Duration, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	// the command name in field 2 may contain spaces, so the fields are counted after its closing parenthesis
+	stat := string(content)
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("unexpected content in %s", path)
+	}
+	utime, err := strconv.ParseInt(fields[11], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	stime, err := strconv.ParseInt(fields[12], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(utime+stime) * time.Second / procClockTicks, nil
+}
+
+// CpuLoadReport is the result of a cpu load generated by /load/cpu
+type CpuLoadReport struct {
+	Cores              int                 `json:"cores"`
+	RequestedSeconds   float64             `json:"requested_seconds"`
+	ElapsedSeconds     float64             `json:"elapsed_seconds"`
+	CpuSeconds         float64             `json:"cpu_seconds,omitempty"`         // cpu time used by the whole process during the load
+	UtilizationPercent float64             `json:"utilization_percent,omitempty"` // cpu_seconds in percent of elapsed_seconds * cores
+	Throttling         *info.CgroupCpuStat `json:"throttling,omitempty"`          // cfs throttling that happened during the load
+	Interrupted        bool                `json:"interrupted"`                   // true when the client disconnected before the end
+}
+
+// LoadGenerator burns cpu and holds memory for the load endpoints, limiting the number of cores busy at the same time
+type LoadGenerator struct {
+	mu           sync.Mutex
+	maxCores     int
+	busyCores    int
+	procSelfStat string
+	cgroupRoot   string
+}
+
+// NewLoadGenerator is a constructor for a LoadGenerator using at most maxCores at the same time
+func NewLoadGenerator(maxCores int, procSelfStat, cgroupRoot string) *LoadGenerator {
+	return &LoadGenerator{maxCores: maxCores, procSelfStat: procSelfStat, cgroupRoot: cgroupRoot}
+}
+
+// burn keeps one core busy until ctx is done
+func burn(ctx context.Context) {
+	x := 1.0
+	for {
+		select {
+		case <-ctx.Done():
+			atomic.StoreUint64(&burnSink, math.Float64bits(x))
+			return
+		default:
+		}
+		for i := 0; i < 100000; i++ {
+			x = x*1.0000001 + 1
+		}
+	}
+}
+
+// BurnCpu keeps cores goroutines busy during duration or until ctx is done, and reports the cpu really used
+func (lg *LoadGenerator) BurnCpu(ctx context.Context, cores int, duration time.Duration) (CpuLoadReport, error) {
+	lg.mu.Lock()
+	if lg.busyCores+cores > lg.maxCores {
+		lg.mu.Unlock()
+		return CpuLoadReport{}, errLoadBusy
+	}
+	lg.busyCores += cores
+	lg.mu.Unlock()
+	defer func() {
+		lg.mu.Lock()
+		lg.busyCores -= cores
+		lg.mu.Unlock()
+	}()
+
+	report := CpuLoadReport{Cores: cores, RequestedSeconds: duration.Seconds()}
+	cpuBefore, cpuErr := readProcessCpuTime(lg.procSelfStat)
+	throttledBefore := info.GetCgroupCpuStat(lg.cgroupRoot)
+	start := time.Now()
+	loadCtx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < cores; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			burn(loadCtx)
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	report.ElapsedSeconds = elapsed.Seconds()
+	report.Interrupted = ctx.Err() != nil
+	if cpuAfter, err := readProcessCpuTime(lg.procSelfStat); cpuErr == nil && err == nil {
+		report.CpuSeconds = (cpuAfter - cpuBefore).Seconds()
+		report.UtilizationPercent = report.CpuSeconds * 100 / (elapsed.Seconds() * float64(cores))
+	}
+	if throttledAfter := info.GetCgroupCpuStat(lg.cgroupRoot); throttledBefore != nil && throttledAfter != nil {
+		report.Throttling = &info.CgroupCpuStat{
+			NrPeriods:   throttledAfter.NrPeriods - throttledBefore.NrPeriods,
+			NrThrottled: throttledAfter.NrThrottled - throttledBefore.NrThrottled,
+			ThrottledUs: throttledAfter.ThrottledUs - throttledBefore.ThrottledUs,
+		}
+	}
+	return report, nil
+}
+
+// MemoryLoadReport is the result of a memory allocation made by /load/memory
+type MemoryLoadReport struct {
+	RequestedMb    int                `json:"requested_mb"`
+	AllocatedBytes int64              `json:"allocated_bytes"`
+	HeldSeconds    float64            `json:"held_seconds"`
+	CgroupBefore   *info.CgroupMemory `json:"cgroup_before,omitempty"` // usage of the container before the allocation
+	CgroupHeld     *info.CgroupMemory `json:"cgroup_held,omitempty"`   // usage of the container while the memory was held
+	Interrupted    bool               `json:"interrupted"`             // true when the client disconnected before the end
+}
+
+// checkMemoryLimit returns errMemoryAboveLimit when adding bytes to the current usage would exceed the cgroup limit guard
+func checkMemoryLimit(cg *info.CgroupMemory, bytes int64) error {
+	if cg == nil || cg.LimitBytes <= 0 {
+		return nil
+	}
+	if float64(cg.UsageBytes+bytes) > float64(cg.LimitBytes)*memoryLoadLimitRatio {
+		return errMemoryAboveLimit
+	}
+	return nil
+}
+
+// HoldMemory allocates mb megabytes, keeps them during hold or until ctx is done, then gives them back to the os.
+// unless force is true, the allocation is refused when it would bring the container close to its cgroup memory limit
+func (lg *LoadGenerator) HoldMemory(ctx context.Context, mb int, hold time.Duration, force bool) (MemoryLoadReport, error) {
+	report := MemoryLoadReport{RequestedMb: mb, CgroupBefore: info.GetCgroupMemory(lg.cgroupRoot)}
+	size := int64(mb) * 1024 * 1024
+	if !force {
+		if err := checkMemoryLimit(report.CgroupBefore, size); err != nil {
+			return report, err
+		}
+	}
+	block := make([]byte, size)
+	// touch every page so the memory is really committed and counted by the cgroup
+	for i := 0; i < len(block); i += os.Getpagesize() {
+		block[i] = 1
+	}
+	report.AllocatedBytes = int64(len(block))
+	report.CgroupHeld = info.GetCgroupMemory(lg.cgroupRoot)
+	start := time.Now()
+	timer := time.NewTimer(hold)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		report.Interrupted = true
+	}
+	report.HeldSeconds = time.Since(start).Seconds()
+	// after this point the block is unreachable, so FreeOSMemory can give it back to the os
+	runtime.KeepAlive(block)
+	debug.FreeOSMemory()
+	return report, nil
+}
+
+// parseLoadDuration returns the duration given in seconds by the query parameter name, or defaultValue
+func parseLoadDuration(r *http.Request, name string, defaultValue time.Duration) (time.Duration, error) {
+	val := r.URL.Query().Get(name)
+	if val == "" {
+		return defaultValue, nil
+	}
+	seconds, err := strconv.ParseFloat(val, 64)
+	d := time.Duration(seconds * float64(time.Second))
+	if err != nil || d <= 0 || d > maxLoadDuration {
+		return 0, fmt.Errorf("parameter %s should be a number of seconds between 0 and %v", name, maxLoadDuration.Seconds())
+	}
+	return d, nil
+}
+
+// extendWriteDeadline lets a long running handler answer after the server WriteTimeout
+func extendWriteDeadline(w http.ResponseWriter, d time.Duration) {
+	// the error is ignored, when the writer does not support deadlines there is no timeout to extend
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d + config.DefaultWriteTimeout))
+}
+
+//############# BEGIN LOAD HANDLERS
+
+// CpuLoadHandler keeps the cores parameter number of cpu busy during the seconds parameter, then reports the
+// utilization achieved, to test the horizontal pod autoscaler and the cpu throttling of the container
+func (s *GoHttpServer) CpuLoadHandler(lg *LoadGenerator) http.HandlerFunc {
+	handlerName := "CpuLoadHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		cores, err := parseIntParam(r, "cores", defaultCpuLoadCores, 1, lg.maxCores)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		duration, err := parseLoadDuration(r, "seconds", defaultLoadDuration)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		extendWriteDeadline(w, duration)
+		s.logger.Info("starting cpu load", "handler", handlerName, "cores", cores, "duration", duration.String())
+		report, err := lg.BurnCpu(r.Context(), cores, duration)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		s.render(w, r, http.StatusOK, report)
+	}
+}
+
+// MemoryLoadHandler allocates the mb parameter megabytes and holds them during the hold parameter (a duration like 60s)
+// before releasing them, to test memory based autoscaling. force=true skips the check against the cgroup memory limit
+func (s *GoHttpServer) MemoryLoadHandler(lg *LoadGenerator) http.HandlerFunc {
+	handlerName := "MemoryLoadHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		mb, err := parseIntParam(r, "mb", 0, 1, maxMemoryLoadMb)
+		if err != nil || mb == 0 {
+			http.Error(w, fmt.Sprintf("ERROR: parameter mb should be an integer between 1 and %d", maxMemoryLoadMb), http.StatusBadRequest)
+			return
+		}
+		hold := defaultLoadDuration
+		if val := r.URL.Query().Get("hold"); val != "" {
+			hold, err = time.ParseDuration(val)
+			if err != nil || hold <= 0 || hold > maxLoadDuration {
+				http.Error(w, fmt.Sprintf("ERROR: parameter hold should be a duration between 0 and %s", maxLoadDuration), http.StatusBadRequest)
+				return
+			}
+		}
+		force := r.URL.Query().Get("force") == "true"
+		extendWriteDeadline(w, hold)
+		s.logger.Info("starting memory load", "handler", handlerName, "mb", mb, "hold", hold.String(), "force", force)
+		report, err := lg.HoldMemory(r.Context(), mb, hold, force)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		s.render(w, r, http.StatusOK, report)
+	}
+}
+
+// ############# END LOAD HANDLERS
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadProcessCpuTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stat")
+	content := "42 (go cloud (info)) S 1 42 42 0 -1 4194560 1000 0 0 0 250 150 0 0 20 0 12 0 100 0 0\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cpu, err := readProcessCpuTime(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 4*time.Second, cpu, "utime 250 + stime 150 ticks should be 4 seconds")
+
+	_, err = readProcessCpuTime(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestLoadGeneratorBurnCpu(t *testing.T) {
+	lg := NewLoadGenerator(2, defaultProcSelfStat, t.TempDir())
+	report, err := lg.BurnCpu(context.Background(), 1, 100*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Cores)
+	assert.GreaterOrEqual(t, report.ElapsedSeconds, 0.1)
+	assert.False(t, report.Interrupted)
+	assert.Nil(t, report.Throttling, "no throttling should be reported without cgroup")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	report, err = lg.BurnCpu(ctx, 2, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, report.Interrupted, "the load should stop when the client disconnects")
+	assert.Less(t, report.ElapsedSeconds, 1.0)
+
+	lg.busyCores = 2
+	_, err = lg.BurnCpu(context.Background(), 1, time.Millisecond)
+	assert.ErrorIs(t, err, errLoadBusy, "the load should be refused when all the cores are busy")
+}
+
+func TestGoHttpServerCpuLoadHandler(t *testing.T) {
+	t.Setenv("ENABLE_LOAD", "true")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name           string
+		url            string
+		wantStatusCode int
+	}{
+		{name: "1: cpu load should report the utilization", url: "/load/cpu?cores=1&seconds=0.1", wantStatusCode: http.StatusOK},
+		{name: "2: too many cores should be a bad request", url: "/load/cpu?cores=100000", wantStatusCode: http.StatusBadRequest},
+		{name: "3: too long load should be a bad request", url: "/load/cpu?seconds=3600", wantStatusCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode, assertCorrectStatusCodeExpected)
+			if resp.StatusCode == http.StatusOK {
+				var report CpuLoadReport
+				assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report), "the output should be a valid json")
+				assert.Equal(t, 1, report.Cores)
+			}
+		})
+	}
+}
+
+func TestLoadGeneratorHoldMemory(t *testing.T) {
+	root := t.TempDir()
+	writeCgroupFiles(t, root, map[string]string{"cgroup.controllers": "cpu memory", "memory.max": "104857600\n", "memory.current": "52428800\n"})
+	lg := NewLoadGenerator(1, defaultProcSelfStat, root)
+
+	report, err := lg.HoldMemory(context.Background(), 8, 10*time.Millisecond, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8*1024*1024), report.AllocatedBytes)
+	assert.GreaterOrEqual(t, report.HeldSeconds, 0.01)
+	assert.Equal(t, int64(52428800), report.CgroupBefore.UsageBytes)
+
+	_, err = lg.HoldMemory(context.Background(), 64, time.Millisecond, false)
+	assert.ErrorIs(t, err, errMemoryAboveLimit, "50MB used + 64MB should exceed 90% of the 100MB limit")
+	report, err = lg.HoldMemory(context.Background(), 64, time.Millisecond, true)
+	assert.NoError(t, err, "force should skip the limit check")
+	assert.Equal(t, int64(64*1024*1024), report.AllocatedBytes)
+}
+
+func TestGoHttpServerMemoryLoadHandler(t *testing.T) {
+	t.Setenv("ENABLE_LOAD", "true")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name           string
+		url            string
+		wantStatusCode int
+	}{
+		{name: "1: memory load should be held then released", url: "/load/memory?mb=4&hold=10ms", wantStatusCode: http.StatusOK},
+		{name: "2: missing mb should be a bad request", url: "/load/memory", wantStatusCode: http.StatusBadRequest},
+		{name: "3: invalid hold should be a bad request", url: "/load/memory?mb=4&hold=60", wantStatusCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode, assertCorrectStatusCodeExpected)
+			if resp.StatusCode == http.StatusOK {
+				var report MemoryLoadReport
+				assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report), "the output should be a valid json")
+				assert.Equal(t, int64(4*1024*1024), report.AllocatedBytes)
+			}
+		})
+	}
+}
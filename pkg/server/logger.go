@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+const ()
+
+// NewLogger returns a structured logger writing json lines, or the classic human-readable lines when format is text.
+// the level is a LevelVar, so it can be changed while the server is running.
+// the lines logged with a request context contain the request_id of the request.
+func NewLogger(w io.Writer, format string, level *slog.LevelVar) *slog.Logger {
+	if format == config.LogFormatText {
+		return slog.New(requestIdLogHandler{newTextLogHandler(w, fmt.Sprintf("HTTP_SERVER_%s ", info.APP), level)})
+	}
+	return slog.New(requestIdLogHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{AddSource: true, Level: level})})
+}
+
+// textLogHandler is a slog.Handler writing lines in the format of the previous log.Logger of this server :
+//
+//	HTTP_SERVER_go-cloud-k8s-info 2022/06/02 10:43:44 server.go:42: INFO: 'message' key=value
+type textLogHandler struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	level  slog.Leveler
+	attrs  string
+	group  string
+}
+
+func newTextLogHandler(w io.Writer, prefix string, level slog.Leveler) *textLogHandler {
+	return &textLogHandler{mu: &sync.Mutex{}, w: w, prefix: prefix, level: level}
+}
+
+func (h *textLogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *textLogHandler) appendAttr(b *strings.Builder, a slog.Attr) {
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			h.appendAttr(b, slog.Attr{Key: a.Key + "." + ga.Key, Value: ga.Value})
+		}
+		return
+	}
+	fmt.Fprintf(b, " %s%s=%v", h.group, a.Key, a.Value.Resolve().Any())
+}
+
+func (h *textLogHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(h.prefix)
+	b.WriteString(r.Time.Format("2006/01/02 15:04:05 "))
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		fmt.Fprintf(&b, "%s:%d: ", filepath.Base(frame.File), frame.Line)
+	}
+	level := r.Level.String()
+	if r.Level >= slog.LevelError {
+		level = "💥💥 " + level
+	}
+	fmt.Fprintf(&b, "%s: '%s'", level, r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		h.appendAttr(&b, a)
+		return true
+	})
+	b.WriteString("\n")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *textLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		h.appendAttr(&b, a)
+	}
+	h2 := *h
+	h2.attrs = h.attrs + b.String()
+	return &h2
+}
+
+func (h *textLogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.group = h.group + name + "."
+	return &h2
+}
+
+// logMethodNotAllowed logs a request refused because of its http method
+func (s *GoHttpServer) logMethodNotAllowed(handlerName string, r *http.Request) {
+	s.logger.WarnContext(r.Context(), httpErrMethodNotAllow, "handler", handlerName, "method", r.Method, "path", r.URL.Path,
+		"remote_ip", ParseRemoteAddr(r.RemoteAddr).RemoteIp)
+}
+
+// logRequestDone logs one served request with its status and duration
+func (s *GoHttpServer) logRequestDone(route string, r *http.Request, status int, duration time.Duration) {
+	s.logger.InfoContext(r.Context(), "request served", "handler", route, "method", r.Method, "path", r.URL.Path,
+		"remote_ip", ParseRemoteAddr(r.RemoteAddr).RemoteIp, "status", status, "duration", duration)
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+const maxRecentGcPauses = 10 // number of the last gc pauses reported
+
+// MemoryInfo compares the Go heap with the memory limit of the container
+type MemoryInfo struct {
+	HeapAllocBytes     uint64             `json:"heap_alloc_bytes"`                // bytes of allocated heap objects
+	HeapInuseBytes     uint64             `json:"heap_inuse_bytes"`                // bytes in in-use spans
+	HeapSysBytes       uint64             `json:"heap_sys_bytes"`                  // bytes of heap memory obtained from the OS
+	HeapObjects        uint64             `json:"heap_objects"`                    // number of allocated heap objects
+	StackInuseBytes    uint64             `json:"stack_inuse_bytes"`               // bytes in stack spans
+	SysBytes           uint64             `json:"sys_bytes"`                       // total bytes of memory obtained from the OS
+	TotalAllocBytes    uint64             `json:"total_alloc_bytes"`               // cumulative bytes allocated for heap objects
+	Mallocs            uint64             `json:"mallocs"`                         // cumulative count of heap objects allocated
+	Frees              uint64             `json:"frees"`                           // cumulative count of heap objects freed
+	NumGC              uint32             `json:"num_gc"`                          // number of completed GC cycles
+	NextGCBytes        uint64             `json:"next_gc_bytes"`                   // target heap size of the next GC cycle
+	LastGC             string             `json:"last_gc,omitempty"`               // time the last GC finished, RFC3339
+	PauseTotalNs       uint64             `json:"pause_total_ns"`                  // cumulative ns in GC stop-the-world pauses
+	RecentPausesNs     []uint64           `json:"recent_pauses_ns"`                // last GC pauses, most recent first
+	GCCPUFraction      float64            `json:"gc_cpu_fraction"`                 // fraction of the available CPU used by the GC
+	Cgroup             *info.CgroupMemory `json:"cgroup,omitempty"`                // memory limit and usage of the container
+	HeapToLimitPercent float64            `json:"heap_to_limit_percent,omitempty"` // heap_sys_bytes in percent of the cgroup limit
+}
+
+// GetMemoryInfo returns the current memory statistics of the Go runtime and of the cgroup mounted in cgroupRoot
+func GetMemoryInfo(cgroupRoot string) MemoryInfo {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	info := MemoryInfo{
+		HeapAllocBytes:  m.HeapAlloc,
+		HeapInuseBytes:  m.HeapInuse,
+		HeapSysBytes:    m.HeapSys,
+		HeapObjects:     m.HeapObjects,
+		StackInuseBytes: m.StackInuse,
+		SysBytes:        m.Sys,
+		TotalAllocBytes: m.TotalAlloc,
+		Mallocs:         m.Mallocs,
+		Frees:           m.Frees,
+		NumGC:           m.NumGC,
+		NextGCBytes:     m.NextGC,
+		PauseTotalNs:    m.PauseTotalNs,
+		RecentPausesNs:  []uint64{},
+		GCCPUFraction:   m.GCCPUFraction,
+		Cgroup:          info.GetCgroupMemory(cgroupRoot),
+	}
+	if m.LastGC > 0 {
+		info.LastGC = time.Unix(0, int64(m.LastGC)).Format(time.RFC3339)
+	}
+	// PauseNs is a circular buffer, the most recent pause is at (NumGC+255)%256
+	for i := uint32(0); i < m.NumGC && i < maxRecentGcPauses; i++ {
+		info.RecentPausesNs = append(info.RecentPausesNs, m.PauseNs[(m.NumGC-1-i)%uint32(len(m.PauseNs))])
+	}
+	if info.Cgroup != nil && info.Cgroup.LimitBytes > 0 {
+		info.HeapToLimitPercent = float64(m.HeapSys) * 100 / float64(info.Cgroup.LimitBytes)
+	}
+	return info
+}
+
+//############# BEGIN INFO HANDLERS
+
+// MemoryInfoHandler returns the Go memory and GC statistics together with the memory limit of the container
+func (s *GoHttpServer) MemoryInfoHandler(cgroupRoot string) http.HandlerFunc {
+	handlerName := "MemoryInfoHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, r, http.StatusOK, GetMemoryInfo(cgroupRoot))
+	}
+}
+
+// ############# END INFO HANDLERS
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCgroupFiles creates a fake cgroup filesystem under root, files maps a relative path to its content
+func writeCgroupFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("unable to create %s : %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("unable to write %s : %v", path, err)
+		}
+	}
+}
+
+func TestGetMemoryInfo(t *testing.T) {
+	root := t.TempDir()
+	writeCgroupFiles(t, root, map[string]string{
+		"cgroup.controllers": "cpu memory", "memory.max": "1073741824\n", "memory.current": "52428800\n"})
+	runtime.GC()
+	info := GetMemoryInfo(root)
+	assert.Greater(t, info.HeapSysBytes, uint64(0))
+	assert.GreaterOrEqual(t, info.NumGC, uint32(1))
+	assert.NotEmpty(t, info.LastGC, "a gc was just forced")
+	assert.LessOrEqual(t, len(info.RecentPausesNs), maxRecentGcPauses)
+	if assert.NotNil(t, info.Cgroup) {
+		assert.Equal(t, int64(1073741824), info.Cgroup.LimitBytes)
+	}
+	assert.Greater(t, info.HeapToLimitPercent, 0.0)
+
+	info = GetMemoryInfo(t.TempDir())
+	assert.Nil(t, info.Cgroup, "no cgroup should be reported outside a container")
+	assert.Equal(t, 0.0, info.HeapToLimitPercent)
+}
+
+func TestGoHttpServerMemoryInfoHandler(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/info/memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var info map[string]interface{}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&info), "the output should be a valid json")
+	assert.Contains(t, info, "heap_alloc_bytes")
+	assert.Contains(t, info, "next_gc_bytes")
+
+	resp, err = http.Post(ts.URL+"/info/memory", MIMEAppJSON, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode, assertCorrectStatusCodeExpected)
+}
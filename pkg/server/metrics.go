@@ -0,0 +1,257 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+const MIMETextPlainPrometheus = "text/plain; version=0.0.4; " + charsetUTF8
+
+// defaultLatencyBuckets are the upper bounds in seconds of the request duration histogram
+var defaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type requestKey struct {
+	path   string
+	method string
+	code   int
+}
+
+type histogram struct {
+	counts []uint64 // one counter per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// statsQuantiles are the percentiles of the request latencies given by /stats and the Prometheus summary
+var statsQuantiles = []float64{0.5, 0.95, 0.99}
+
+// routeStats are the in-process statistics of one route path
+type routeStats struct {
+	inFlight     int64 // accessed atomically
+	clientErrors uint64
+	serverErrors uint64
+	latency      HdrHistogram
+}
+
+// Metrics is a minimal registry of http server metrics exposed in the Prometheus text format
+type Metrics struct {
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	durations map[string]*histogram
+	routes    map[string]*routeStats
+	buckets   []float64
+	inFlight  int64
+	startTime time.Time
+}
+
+// NewMetrics is a constructor for an empty Metrics registry
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests:  make(map[requestKey]uint64),
+		durations: make(map[string]*histogram),
+		routes:    make(map[string]*routeStats),
+		buckets:   defaultLatencyBuckets,
+		startTime: time.Now(),
+	}
+}
+
+// statusResponseWriter remembers the status code and the number of bytes sent by a handler
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap returns the original ResponseWriter, so http.ResponseController can reach its optional methods
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Observe records one request on the given route path
+func (m *Metrics) Observe(path, method string, code int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{path: path, method: method, code: code}]++
+	h, exist := m.durations[path]
+	if !exist {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.durations[path] = h
+	}
+	seconds := duration.Seconds()
+	for i, upperBound := range m.buckets {
+		if seconds <= upperBound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+	rs := m.route(path)
+	rs.latency.Record(duration)
+	switch {
+	case code >= 500:
+		rs.serverErrors++
+	case code >= 400:
+		rs.clientErrors++
+	}
+}
+
+// route returns the statistics of path, creating them when missing. m.mu must be held
+func (m *Metrics) route(path string) *routeStats {
+	rs, exist := m.routes[path]
+	if !exist {
+		rs = &routeStats{}
+		m.routes[path] = rs
+	}
+	return rs
+}
+
+// Instrument wraps the handler to count requests, in-flight requests and response latencies for the route path.
+// the route path is used as label instead of the url to keep the number of series bounded.
+func (m *Metrics) Instrument(path string, next http.Handler) http.Handler {
+	m.mu.Lock()
+	rs := m.route(path)
+	m.mu.Unlock()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&m.inFlight, 1)
+		defer atomic.AddInt64(&m.inFlight, -1)
+		atomic.AddInt64(&rs.inFlight, 1)
+		defer atomic.AddInt64(&rs.inFlight, -1)
+		start := time.Now()
+		sw := &statusResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		m.Observe(path, r.Method, sw.status, time.Since(start))
+	})
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Write writes all the metrics in the Prometheus text exposition format
+func (m *Metrics) Write(w io.Writer) {
+	m.mu.Lock()
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].path != keys[j].path {
+			return keys[i].path < keys[j].path
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].code < keys[j].code
+	})
+	fmt.Fprintln(w, "# HELP http_requests_total Total number of http requests by route path, method and status code.")
+	fmt.Fprintln(w, "# TYPE http_requests_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(w, "http_requests_total{path=%q,method=%q,code=\"%d\"} %d\n", k.path, k.method, k.code, m.requests[k])
+	}
+	paths := make([]string, 0, len(m.durations))
+	for p := range m.durations {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	fmt.Fprintln(w, "# HELP http_request_duration_seconds Latency of http requests by route path.")
+	fmt.Fprintln(w, "# TYPE http_request_duration_seconds histogram")
+	for _, p := range paths {
+		h := m.durations[p]
+		var cumulative uint64
+		for i, upperBound := range m.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "http_request_duration_seconds_bucket{path=%q,le=\"%s\"} %d\n", p, formatFloat(upperBound), cumulative)
+		}
+		fmt.Fprintf(w, "http_request_duration_seconds_bucket{path=%q,le=\"+Inf\"} %d\n", p, h.count)
+		fmt.Fprintf(w, "http_request_duration_seconds_sum{path=%q} %s\n", p, formatFloat(h.sum))
+		fmt.Fprintf(w, "http_request_duration_seconds_count{path=%q} %d\n", p, h.count)
+	}
+	fmt.Fprintln(w, "# HELP http_request_latency_seconds Percentiles of the latency of http requests by route path, since the start.")
+	fmt.Fprintln(w, "# TYPE http_request_latency_seconds summary")
+	for _, p := range paths {
+		rs := m.routes[p]
+		for _, q := range statsQuantiles {
+			fmt.Fprintf(w, "http_request_latency_seconds{path=%q,quantile=\"%s\"} %s\n", p, formatFloat(q), formatFloat(rs.latency.Quantile(q).Seconds()))
+		}
+		fmt.Fprintf(w, "http_request_latency_seconds_sum{path=%q} %s\n", p, formatFloat(rs.latency.Sum().Seconds()))
+		fmt.Fprintf(w, "http_request_latency_seconds_count{path=%q} %d\n", p, rs.latency.Count())
+	}
+	m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP http_requests_in_flight Number of http requests currently served.")
+	fmt.Fprintln(w, "# TYPE http_requests_in_flight gauge")
+	fmt.Fprintf(w, "http_requests_in_flight %d\n", atomic.LoadInt64(&m.inFlight))
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	gauges := []struct {
+		name  string
+		help  string
+		kind  string
+		value string
+	}{
+		{"go_goroutines", "Number of goroutines that currently exist.", "gauge", strconv.Itoa(runtime.NumGoroutine())},
+		{"go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", "gauge", strconv.FormatUint(mem.Alloc, 10)},
+		{"go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", "gauge", strconv.FormatUint(mem.HeapInuse, 10)},
+		{"go_memstats_heap_objects", "Number of allocated objects.", "gauge", strconv.FormatUint(mem.HeapObjects, 10)},
+		{"go_memstats_sys_bytes", "Number of bytes obtained from system.", "gauge", strconv.FormatUint(mem.Sys, 10)},
+		{"go_memstats_mallocs_total", "Total number of mallocs.", "counter", strconv.FormatUint(mem.Mallocs, 10)},
+		{"go_gc_cycles_total", "Number of completed GC cycles.", "counter", strconv.FormatUint(uint64(mem.NumGC), 10)},
+		{"go_gc_pause_seconds_total", "Total time spent in GC stop-the-world pauses.", "counter", formatFloat(float64(mem.PauseTotalNs) / 1e9)},
+		{"process_start_time_seconds", "Start time of the process since unix epoch in seconds.", "gauge", strconv.FormatInt(m.startTime.Unix(), 10)},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", g.name, g.help, g.name, g.kind, g.name, g.value)
+	}
+	fmt.Fprintln(w, "# HELP go_info Information about the Go environment.")
+	fmt.Fprintln(w, "# TYPE go_info gauge")
+	fmt.Fprintf(w, "go_info{version=%q} 1\n", runtime.Version())
+	fmt.Fprintln(w, "# HELP app_info Information about this application.")
+	fmt.Fprintln(w, "# TYPE app_info gauge")
+	build := info.GetBuildInfo()
+	fmt.Fprintf(w, "app_info{app=%q,version=%q,revision=%q,build_date=%q} 1\n", info.APP, build.Version, build.Revision, build.BuildDate)
+}
+
+//############# BEGIN METRICS HANDLERS
+
+// MetricsHandler returns the request and runtime metrics of the server in the Prometheus text format
+func (s *GoHttpServer) MetricsHandler() http.HandlerFunc {
+	handlerName := "MetricsHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		s.metrics.Write(w)
+		s.waiters.Write(w)
+	}
+}
+
+// ############# END METRICS HANDLERS
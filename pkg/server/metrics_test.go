@@ -0,0 +1,62 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoHttpServerMetricsHandler(t *testing.T) {
+	myServer := NewGoHttpServer(fmt.Sprintf(":%d", config.DefaultPort), getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	for _, path := range []string{"/health", "/health", "/a_funny_path_that_does_not_exist"} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	resp, err := http.Post(ts.URL+"/time", MIMEAppJSON, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	assert.Equal(t, MIMETextPlainPrometheus, resp.Header.Get(HeaderContentType))
+	received, _ := ioutil.ReadAll(resp.Body)
+	body := string(received)
+
+	tests := []struct {
+		name     string
+		wantBody string
+	}{
+		{name: "1: requests should be counted per route", wantBody: `http_requests_total{path="/health",method="GET",code="200"} 2`},
+		{name: "2: unknown path should be counted on the catch-all route", wantBody: `http_requests_total{path="/",method="GET",code="404"} 1`},
+		{name: "3: refused method should be counted with its status", wantBody: `http_requests_total{path="/time",method="POST",code="405"} 1`},
+		{name: "4: latencies should be in a histogram", wantBody: `http_request_duration_seconds_count{path="/health"} 2`},
+		{name: "5: +Inf bucket should hold all requests", wantBody: `http_request_duration_seconds_bucket{path="/health",le="+Inf"} 2`},
+		{name: "6: the scrape itself should be in flight", wantBody: "http_requests_in_flight 1"},
+		{name: "7: go runtime stats should be exposed", wantBody: "# TYPE go_goroutines gauge"},
+		{name: "8: latency percentiles should be in a summary", wantBody: `http_request_latency_seconds_count{path="/health"} 2`},
+		{name: "9: app version should be exposed", wantBody: fmt.Sprintf(`app_info{app=%q,version=%q,revision=`, info.APP, info.VERSION)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Contains(t, body, tt.wantBody, "Response should contain what was expected.")
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+)
+
+// Middleware wraps an http.Handler to add a behaviour shared by many routes
+type Middleware func(http.Handler) http.Handler
+
+// Chain returns the handler wrapped by all the middlewares, the first one being the outermost,
+// so it sees the request first and the response last.
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// Use adds middlewares applied to every request received by the server, before the routing is done
+func (s *GoHttpServer) Use(middlewares ...Middleware) {
+	s.middlewares = append(s.middlewares, middlewares...)
+	s.httpServer.Handler = Chain(s.router, s.middlewares...)
+}
+
+// Handler returns all the routes of the server wrapped by the middlewares given to Use,
+// so they can be mounted in the mux of another server
+func (s *GoHttpServer) Handler() http.Handler {
+	return s.httpServer.Handler
+}
+
+// instrument is the Middleware collecting the metrics of the route
+func (s *GoHttpServer) instrument(route string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return s.metrics.Instrument(route, next)
+	}
+}
+
+// logRequests is the Middleware logging the status and duration of each request of the route,
+// the requests still running are counted by route in /stats
+func (s *GoHttpServer) logRequests(route string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			if sw.status == 0 {
+				sw.status = http.StatusOK
+			}
+			s.logRequestDone(route, r, sw.status, time.Since(start))
+		})
+	}
+}
+
+// allowMethods is the Middleware answering 405 with an Allow header to the requests using another http method.
+// like the method patterns of http.ServeMux, HEAD is accepted with GET, the server drops the body of the answer
+func (s *GoHttpServer) allowMethods(methods ...string) Middleware {
+	if slices.Contains(methods, http.MethodGet) && !slices.Contains(methods, http.MethodHead) {
+		methods = append(slices.Clip(methods), http.MethodHead)
+	}
+	allowed := strings.Join(methods, ", ")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, m := range methods {
+				if r.Method == m {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			s.logMethodNotAllowed(r.URL.Path, r)
+			w.Header().Set("Allow", allowed)
+			http.Error(w, httpErrMethodNotAllow, http.StatusMethodNotAllowed)
+		})
+	}
+}
+
+// contentType is the Middleware setting the Content-Type header of the response, the handler can still override it
+func contentType(mimeType string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HeaderContentType, mimeType)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireFeature is the Middleware answering 404 while the feature is disabled in the active configuration,
+// so the routes of the features that can be enabled by a configuration reload are always registered
+func (s *GoHttpServer) requireFeature(enabled func(config.Config) bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !enabled(s.settings.Current()) {
+				s.notFound(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
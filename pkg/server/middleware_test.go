@@ -0,0 +1,119 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// tagMiddleware appends its name to the X-Trace header before and after calling the next handler
+func tagMiddleware(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Trace", name+"-in")
+			next.ServeHTTP(w, r)
+			w.Header().Add("X-Trace", name+"-out")
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("X-Trace", "handler")
+	}), tagMiddleware("first"), tagMiddleware("second"))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "first-in,second-in,handler,second-out,first-out", strings.Join(rec.Header().Values("X-Trace"), ","),
+		"the first middleware should be the outermost")
+}
+
+func TestGoHttpServerAllowMethods(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		myServer.allowMethods(http.MethodGet), contentType(MIMEAppJSONCharsetUTF8))
+	tests := []struct {
+		name           string
+		method         string
+		wantStatusCode int
+		wantAllow      string
+	}{
+		{name: "1: GET should be allowed", method: http.MethodGet, wantStatusCode: http.StatusOK},
+		{name: "2: HEAD should be allowed with GET", method: http.MethodHead, wantStatusCode: http.StatusOK},
+		{name: "3: POST should be refused with the allowed methods", method: http.MethodPost, wantStatusCode: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, "/", nil))
+			assert.Equal(t, tt.wantStatusCode, rec.Code)
+			assert.Equal(t, tt.wantAllow, rec.Header().Get("Allow"))
+			if tt.wantStatusCode == http.StatusOK {
+				assert.Equal(t, MIMEAppJSONCharsetUTF8, rec.Header().Get(HeaderContentType))
+			}
+		})
+	}
+}
+
+func TestGoHttpServerPathParams(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	myServer.handleRoute(ApiRoute{Path: "/items/{id}", Methods: []string{http.MethodGet}, Summary: "get an item",
+		Params: []ApiParam{{Name: "id", Type: "string", Description: "id of the item"}}},
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HeaderContentType, MIMEAppJSONCharsetUTF8)
+			w.Write([]byte(`{"id":"` + r.PathValue("id") + `"}`))
+		}))
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		wantStatusCode int
+		wantBody       string
+	}{
+		{name: "1: path parameter should be read by the handler", method: http.MethodGet, path: "/items/42", wantStatusCode: http.StatusOK, wantBody: `"id":"42"`},
+		{name: "2: path parameter should be kept under /api/v1", method: http.MethodGet, path: "/api/v1/items/42", wantStatusCode: http.StatusOK, wantBody: `"42"`},
+		{name: "3: other method should be refused", method: http.MethodDelete, path: "/items/42", wantStatusCode: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			myServer.router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			assert.Equal(t, tt.wantStatusCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+		})
+	}
+	for _, route := range myServer.apiRoutes {
+		if route.Path == "/items/{id}" {
+			params := route.operations(route.Path, false, nil, map[string]*OpenApiSchema{})["get"].Parameters
+			assert.Equal(t, "path", params[0].In, "a wildcard of the path should be a path parameter")
+			assert.True(t, params[0].Required)
+		}
+	}
+}
+
+func TestGoHttpServerUse(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	myServer.Use(tagMiddleware("global"))
+	rec := httptest.NewRecorder()
+	myServer.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "global-in", rec.Header().Get("X-Trace"), "global middlewares should see every request")
+
+	rec = httptest.NewRecorder()
+	myServer.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a_funny_path_that_does_not_exist", nil))
+	assert.Equal(t, "global-in", rec.Header().Get("X-Trace"), "global middlewares should also see unrouted requests")
+}
+
+func TestGoHttpServerHandler(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	myServer.Use(tagMiddleware("global"))
+	mux := http.NewServeMux()
+	mux.Handle("/info/", http.StripPrefix("/info", myServer.Handler()))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/info/health", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "the routes should be reachable from another mux")
+	assert.Equal(t, "global-in", rec.Header().Get("X-Trace"), "the global middlewares should be applied")
+}
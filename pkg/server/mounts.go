@@ -0,0 +1,165 @@
+package server
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	atomicWriterDataDir   = "..data" // symlink of the kubelet to the current revision of a configMap or secret volume
+	maxMountedFiles       = 1000     // files listed per volume
+	maxMountedContentSize = 64 << 10 // bigger configMap files are listed without their content
+)
+
+// MountedFile is a file of a configMap, secret, projected or downwardAPI volume
+type MountedFile struct {
+	Path      string    `json:"path"` // relative to the mount point, the key of the configMap or secret
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mod_time"`
+	Mode      string    `json:"mode"`
+	Content   *string   `json:"content,omitempty"` // only for the configMap volumes, when contents=true is allowed
+	Truncated bool      `json:"truncated,omitempty"`
+}
+
+// MountedVolume is a configMap, secret, projected or downwardAPI volume of the pod
+type MountedVolume struct {
+	MountPoint string        `json:"mount_point"`
+	VolumeKind string        `json:"volume_kind"`
+	VolumeName string        `json:"volume_name,omitempty"`
+	ReadOnly   bool          `json:"read_only"`
+	Detection  string        `json:"detection"`          // kubelet path, or atomic writer when only the ..data link revealed the volume
+	Revision   string        `json:"revision,omitempty"` // directory of the current revision, it changes with each update of the kubelet
+	Redacted   bool          `json:"redacted"`           // the contents of the files are not shown
+	Files      []MountedFile `json:"files"`
+}
+
+// MountsReport lists the configuration volumes of the pod
+type MountsReport struct {
+	Volumes  []MountedVolume `json:"volumes"`
+	Warnings []string        `json:"warnings,omitempty"`
+	Errors   []string        `json:"errors,omitempty"` // problems met while collecting the information
+}
+
+// configVolumeKinds are the pod volumes holding configuration written by the kubelet
+var configVolumeKinds = map[string]bool{"configMap": true, "secret": true, "projected": true, "downwardAPI": true}
+
+// atomicWriterRevision returns the revision directory of a volume written by the kubelet, empty when dir is not one
+func atomicWriterRevision(dir string) string {
+	target, err := os.Readlink(filepath.Join(dir, atomicWriterDataDir))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// listMountedFiles returns the files of the volume mounted on dir, without the hidden revisions of the kubelet.
+// the content of the files is read when withContent is true
+func listMountedFiles(dir string, withContent bool) ([]MountedFile, error) {
+	files := []MountedFile{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), "..") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// the keys are links to the current revision, Stat follows them
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if len(files) >= maxMountedFiles {
+			return filepath.SkipAll
+		}
+		rel, _ := filepath.Rel(dir, path)
+		file := MountedFile{Path: rel, Size: info.Size(), ModTime: info.ModTime(), Mode: info.Mode().Perm().String()}
+		if withContent {
+			if file.Size > maxMountedContentSize {
+				file.Truncated = true
+			} else if content, err := os.ReadFile(path); err == nil {
+				text := string(content)
+				file.Content = &text
+			}
+		}
+		files = append(files, file)
+		return nil
+	})
+	return files, err
+}
+
+// GetMountsReport returns the configMap, secret, projected and downwardAPI volumes found in the mountinfo file
+// at path with their files. the kind of a volume is given by the kubelet path of its root, or guessed from the
+// ..data link of the kubelet : a tmpfs is a secret, the configMaps are written on the disk of the node.
+// the contents of the configMap files are added when withContent is true, the other kinds may hold secrets
+// and are never shown
+func GetMountsReport(mountInfoPath string, withContent bool) MountsReport {
+	report := MountsReport{Volumes: []MountedVolume{}}
+	mounts, err := ReadMountInfo(mountInfoPath)
+	if err != nil {
+		report.Errors = append(report.Errors, "mountinfo: "+err.Error())
+		return report
+	}
+	for _, m := range mounts {
+		if pseudoFsTypes[m.FsType] {
+			continue
+		}
+		volume := MountedVolume{MountPoint: m.MountPoint, VolumeKind: m.VolumeKind, VolumeName: m.VolumeName, ReadOnly: m.ReadOnly, Detection: "kubelet path"}
+		volume.Revision = atomicWriterRevision(m.MountPoint)
+		if !configVolumeKinds[volume.VolumeKind] {
+			if m.VolumeKind != "" || volume.Revision == "" {
+				continue
+			}
+			volume.Detection = "atomic writer"
+			volume.VolumeKind = "configMap"
+			if m.FsType == "tmpfs" {
+				volume.VolumeKind = "secret"
+			}
+		}
+		showContent := withContent && volume.VolumeKind == "configMap"
+		volume.Redacted = !showContent
+		if volume.Files, err = listMountedFiles(m.MountPoint, showContent); err != nil {
+			if !os.IsPermission(err) && !os.IsNotExist(err) {
+				report.Errors = append(report.Errors, fmt.Sprintf("list %s: %v", m.MountPoint, err))
+			}
+			continue
+		}
+		if len(volume.Files) >= maxMountedFiles {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s volume mounted on %s has more than %d files, the list is truncated",
+				volume.VolumeKind, volume.MountPoint, maxMountedFiles))
+		}
+		report.Volumes = append(report.Volumes, volume)
+	}
+	return report
+}
+
+//############# BEGIN K8S HANDLERS
+
+// MountsHandler returns the configuration volumes of the pod with their files, the contents=true parameter
+// adds the content of the configMap files when mounts_contents allows it
+func (s *GoHttpServer) MountsHandler(mountInfoPath string) http.HandlerFunc {
+	handlerName := "MountsHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		contents, _ := strconv.ParseBool(r.URL.Query().Get("contents"))
+		allowed := s.settings.Current().MountsContents
+		report := GetMountsReport(mountInfoPath, contents && allowed)
+		if contents && !allowed {
+			report.Warnings = append(report.Warnings, "the contents are redacted, set MOUNTS_CONTENTS=true to show the configMap files")
+		}
+		s.render(w, r, http.StatusOK, report)
+	}
+}
+
+// ############# END K8S HANDLERS
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeAtomicVolume writes files in dir like the kubelet does, in a revision directory linked by ..data
+func writeAtomicVolume(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	revision := "..2024_03_05_12_00_00.000000001"
+	if err := os.MkdirAll(filepath.Join(dir, revision), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(revision, filepath.Join(dir, atomicWriterDataDir)); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, revision, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join(atomicWriterDataDir, name), filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// writeTestMounts returns the path of a mountinfo file of a configMap, a secret, a guessed configMap and an emptyDir
+func writeTestMounts(t *testing.T) (string, map[string]string) {
+	t.Helper()
+	root := t.TempDir()
+	dirs := map[string]string{}
+	for _, name := range []string{"settings", "token", "guessed", "cache"} {
+		dirs[name] = filepath.Join(root, name)
+	}
+	writeAtomicVolume(t, dirs["settings"], map[string]string{"app.yaml": "level: debug\n"})
+	writeAtomicVolume(t, dirs["token"], map[string]string{"token": "secret-value"})
+	writeAtomicVolume(t, dirs["guessed"], map[string]string{"feature": "on"})
+	if err := os.MkdirAll(dirs["cache"], 0755); err != nil {
+		t.Fatal(err)
+	}
+	mountInfo := fmt.Sprintf(`22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+25 22 8:1 /var/lib/kubelet/pods/0a1b/volumes/kubernetes.io~configmap/settings %s ro,relatime - ext4 /dev/sda1 rw
+26 22 0:45 /var/lib/kubelet/pods/0a1b/volumes/kubernetes.io~secret/token %s ro,relatime - tmpfs tmpfs rw
+27 22 8:1 /somewhere %s ro,relatime - ext4 /dev/sda1 rw
+28 22 8:1 /var/lib/kubelet/pods/0a1b/volumes/kubernetes.io~empty-dir/cache %s rw,relatime - ext4 /dev/sda1 rw
+`, dirs["settings"], dirs["token"], dirs["guessed"], dirs["cache"])
+	path := filepath.Join(root, "mountinfo")
+	if err := os.WriteFile(path, []byte(mountInfo), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, dirs
+}
+
+func TestGetMountsReport(t *testing.T) {
+	path, dirs := writeTestMounts(t)
+	report := GetMountsReport(path, true)
+	assert.Empty(t, report.Errors)
+	if !assert.Len(t, report.Volumes, 3, "the emptyDir and the root filesystem should be skipped") {
+		return
+	}
+	tests := []struct {
+		name          string
+		volume        MountedVolume
+		wantMount     string
+		wantKind      string
+		wantDetection string
+		wantContent   bool
+	}{
+		{name: "1: a configMap should show its contents when asked", volume: report.Volumes[0], wantMount: dirs["settings"], wantKind: "configMap", wantDetection: "kubelet path", wantContent: true},
+		{name: "2: a secret should never show its contents", volume: report.Volumes[1], wantMount: dirs["token"], wantKind: "secret", wantDetection: "kubelet path"},
+		{name: "3: a volume with a ..data link should be guessed", volume: report.Volumes[2], wantMount: dirs["guessed"], wantKind: "configMap", wantDetection: "atomic writer", wantContent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantMount, tt.volume.MountPoint)
+			assert.Equal(t, tt.wantKind, tt.volume.VolumeKind)
+			assert.Equal(t, tt.wantDetection, tt.volume.Detection)
+			assert.Equal(t, "..2024_03_05_12_00_00.000000001", tt.volume.Revision)
+			assert.Equal(t, !tt.wantContent, tt.volume.Redacted)
+			if assert.Len(t, tt.volume.Files, 1, "the hidden revision of the kubelet should not be listed") {
+				assert.Equal(t, tt.wantContent, tt.volume.Files[0].Content != nil)
+			}
+		})
+	}
+	assert.Equal(t, "app.yaml", report.Volumes[0].Files[0].Path)
+	assert.Equal(t, int64(len("level: debug\n")), report.Volumes[0].Files[0].Size)
+
+	report = GetMountsReport(path, false)
+	for _, volume := range report.Volumes {
+		assert.True(t, volume.Redacted, "the contents should be redacted by default")
+	}
+}
+
+func TestGoHttpServerMountsHandler(t *testing.T) {
+	path, _ := writeTestMounts(t)
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.MountsHandler(path))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "?contents=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var report MountsReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("the output should be a valid json : %v", err)
+	}
+	if assert.NotEmpty(t, report.Volumes) {
+		assert.True(t, report.Volumes[0].Redacted, "the contents should need MOUNTS_CONTENTS")
+	}
+	assert.Len(t, report.Warnings, 1)
+}
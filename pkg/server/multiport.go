@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+)
+
+// extraTlsConfig returns the tls configuration of an additional address of EXTRA_LISTEN, nil when it stays in
+// plain http. the address uses the certificates of the main port, or the tls.crt and tls.key of its own directory
+// which are watched like the main ones
+func (s *GoHttpServer) extraTlsConfig(ctx context.Context, tlsOn bool, certDir string) (*tls.Config, error) {
+	if !tlsOn {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.httpServer.TLSConfig != nil {
+		// keeps the client certificates policy of TLS_CLIENT_AUTH
+		tlsConfig = s.httpServer.TLSConfig.Clone()
+	}
+	if certDir != "" {
+		certs, err := NewCertReloader(filepath.Join(certDir, "tls.crt"), filepath.Join(certDir, "tls.key"), s.logger)
+		if err != nil {
+			return nil, err
+		}
+		go certs.Watch(ctx, defaultCertReloadInterval)
+		tlsConfig.GetCertificate = certs.GetCertificate
+	} else if s.certs == nil {
+		return nil, errors.New("tls needs the certificates of TLS_CERT_FILE and TLS_KEY_FILE, or a directory with its own")
+	}
+	tlsConfig.NextProtos = []string{"http/1.1"}
+	if s.httpServer.Protocols.HTTP2() {
+		tlsConfig.NextProtos = append([]string{"h2"}, tlsConfig.NextProtos...)
+	}
+	return tlsConfig, nil
+}
+
+// listenExtra opens the additional addresses of EXTRA_LISTEN, served by the main http server so that they share
+// its handlers and its graceful shutdown. it emulates the workloads exposing several container ports, to check
+// the port mappings of the services
+func (s *GoHttpServer) listenExtra(ctx context.Context) ([]net.Listener, error) {
+	var listeners []net.Listener
+	specs, _ := config.ParseListenList(s.extraListen) // validated with the configuration
+	for _, spec := range specs {
+		tlsConfig, err := s.extraTlsConfig(ctx, spec.Tls, spec.CertDir)
+		if err == nil {
+			var ln net.Listener
+			if ln, err = net.Listen("tcp", spec.Address); err == nil {
+				if s.proxyProtocol {
+					ln = NewProxyProtoListener(ln, s.trustedProxies)
+				}
+				if tlsConfig != nil {
+					ln = tls.NewListener(ln, tlsConfig)
+				}
+				listeners = append(listeners, ln)
+				continue
+			}
+		}
+		for _, ln := range listeners {
+			ln.Close()
+		}
+		return nil, fmt.Errorf("listening on %s: %w", spec.Address, err)
+	}
+	return listeners, nil
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoHttpServerExtraListen(t *testing.T) {
+	certDir := t.TempDir()
+	writeTestKeyPair(t, filepath.Join(certDir, "tls.crt"), filepath.Join(certDir, "tls.key"), "extra-port")
+	mainAddress, plainAddress, tlsAddress := freeAddress(t), freeAddress(t), freeAddress(t)
+	t.Setenv("EXTRA_LISTEN", plainAddress+","+tlsAddress+"="+certDir)
+	t.Setenv("PRE_STOP_DELAY_SECONDS", "0")
+	myServer := NewGoHttpServer(mainAddress, getTestLogger())
+	stopped := make(chan error, 1)
+	go func() { stopped <- myServer.StartServer() }()
+	defer func() {
+		myServer.Stop()
+		select {
+		case err := <-stopped:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("StartServer should return after Stop")
+		}
+	}()
+	WaitForHttpServer("http://"+mainAddress+"/health", 50*time.Millisecond, 20)
+
+	tlsClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, ForceAttemptHTTP2: true}}
+	tests := []struct {
+		name         string
+		url          string
+		client       *http.Client
+		wantProtocol string
+		wantTls      bool
+	}{
+		{name: "1: main port should answer", url: "http://" + mainAddress + "/echo", client: http.DefaultClient, wantProtocol: "http/1.1"},
+		{name: "2: extra plain port should answer", url: "http://" + plainAddress + "/echo", client: http.DefaultClient, wantProtocol: "http/1.1"},
+		{name: "3: extra tls port should use its own certificate", url: "https://" + tlsAddress + "/echo", client: tlsClient, wantProtocol: "h2", wantTls: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.client.Get(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+			var echo EchoInfo
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&echo))
+			assert.Equal(t, tt.wantProtocol, echo.Protocol)
+			assert.Equal(t, tt.wantTls, resp.TLS != nil)
+			if tt.wantTls {
+				assert.Equal(t, "extra-port", resp.TLS.PeerCertificates[0].Subject.CommonName)
+			}
+		})
+	}
+}
+
+func TestGoHttpServerExtraListenTlsWithoutCertificates(t *testing.T) {
+	mainAddress := freeAddress(t)
+	t.Setenv("EXTRA_LISTEN", freeAddress(t)+"=tls")
+	myServer := NewGoHttpServer(mainAddress, getTestLogger())
+	err := myServer.StartServer()
+	if assert.Error(t, err, "tls with the certificates of a plain http main port should fail") {
+		assert.Contains(t, err.Error(), "TLS_CERT_FILE")
+	}
+	ln, err := net.Listen("tcp", mainAddress)
+	if assert.NoError(t, err, "the main listener should be closed when an extra address fails") {
+		ln.Close()
+	}
+}
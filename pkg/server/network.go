@@ -0,0 +1,189 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	defaultProcNetDir     = "/proc/net"
+	defaultResolvConfPath = "/etc/resolv.conf"
+)
+
+// NetInterface describes one network interface of the container
+type NetInterface struct {
+	Name         string   `json:"name"`
+	Index        int      `json:"index"`
+	Mtu          int      `json:"mtu"`
+	HardwareAddr string   `json:"hardware_addr,omitempty"`
+	Flags        string   `json:"flags"`
+	Addresses    []string `json:"addresses"` // ip addresses in CIDR notation
+}
+
+// DefaultRoute is the gateway used for the destinations without a more specific route
+type DefaultRoute struct {
+	Gateway   string `json:"gateway"`
+	Interface string `json:"interface"`
+}
+
+// ResolvConf is the dns resolver configuration parsed from /etc/resolv.conf
+type ResolvConf struct {
+	Nameservers []string `json:"nameservers"`
+	Search      []string `json:"search"`
+	Options     []string `json:"options"`
+}
+
+// NetworkInfo helps to debug CNI and DNS issues from inside the pod
+type NetworkInfo struct {
+	Interfaces     []NetInterface `json:"interfaces"`
+	DefaultRoute   *DefaultRoute  `json:"default_route,omitempty"`    // ipv4 default route, omitted when there is none
+	DefaultRouteV6 *DefaultRoute  `json:"default_route_v6,omitempty"` // ipv6 default route, omitted when there is none
+	Resolv         *ResolvConf    `json:"resolv_conf,omitempty"`      // omitted when resolv.conf is not readable
+	Errors         []string       `json:"errors,omitempty"`           // problems met while collecting the information
+}
+
+// GetNetInterfaces returns all the network interfaces with their addresses
+func GetNetInterfaces() ([]NetInterface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]NetInterface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		ni := NetInterface{
+			Name:         iface.Name,
+			Index:        iface.Index,
+			Mtu:          iface.MTU,
+			HardwareAddr: iface.HardwareAddr.String(),
+			Flags:        iface.Flags.String(),
+			Addresses:    []string{},
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ni.Addresses = append(ni.Addresses, addr.String())
+		}
+		res = append(res, ni)
+	}
+	return res, nil
+}
+
+// ReadDefaultRoute returns the ipv4 default route found in the /proc/net/route file at path, nil when there is none.
+// addresses in this file are hexadecimal in host byte order, so little endian on the usual architectures
+func ReadDefaultRoute(path string) (*DefaultRoute, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // skip the header line
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		gw, err := hex.DecodeString(fields[2])
+		if err != nil || len(gw) != net.IPv4len {
+			continue
+		}
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(gw))
+		return &DefaultRoute{Gateway: ip.String(), Interface: fields[0]}, nil
+	}
+	return nil, scanner.Err()
+}
+
+// ReadDefaultRouteV6 returns the ipv6 default route found in the /proc/net/ipv6_route file at path, nil when there is none
+func ReadDefaultRouteV6(path string) (*DefaultRoute, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// destination, prefix length, source, source prefix length, next hop, metric, refcount, use, flags, interface
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[1] != "00" || fields[4] == strings.Repeat("0", 32) || fields[9] == "lo" {
+			continue
+		}
+		gw, err := hex.DecodeString(fields[4])
+		if err != nil || len(gw) != net.IPv6len {
+			continue
+		}
+		return &DefaultRoute{Gateway: net.IP(gw).String(), Interface: fields[9]}, nil
+	}
+	return nil, scanner.Err()
+}
+
+// ReadResolvConf returns the nameservers, search domains and options found in the resolv.conf file at path
+func ReadResolvConf(path string) (*ResolvConf, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rc := ResolvConf{Nameservers: []string{}, Search: []string{}, Options: []string{}}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			rc.Nameservers = append(rc.Nameservers, fields[1])
+		case "search", "domain":
+			// the last search or domain line wins, like in the glibc resolver
+			rc.Search = fields[1:]
+		case "options":
+			rc.Options = append(rc.Options, fields[1:]...)
+		}
+	}
+	return &rc, scanner.Err()
+}
+
+// GetNetworkInfo collects the interfaces, the default routes found in procNetDir and the resolver config at resolvConfPath,
+// a missing piece of information is reported in Errors instead of failing the whole request
+func GetNetworkInfo(procNetDir, resolvConfPath string) NetworkInfo {
+	info := NetworkInfo{Interfaces: []NetInterface{}}
+	var err error
+	if ifaces, err := GetNetInterfaces(); err != nil {
+		info.Errors = append(info.Errors, "interfaces: "+err.Error())
+	} else {
+		info.Interfaces = ifaces
+	}
+	if info.DefaultRoute, err = ReadDefaultRoute(filepath.Join(procNetDir, "route")); err != nil {
+		info.Errors = append(info.Errors, "route: "+err.Error())
+	}
+	if info.DefaultRouteV6, err = ReadDefaultRouteV6(filepath.Join(procNetDir, "ipv6_route")); err != nil && !os.IsNotExist(err) {
+		// ipv6_route does not exist when ipv6 is disabled, which is not an error
+		info.Errors = append(info.Errors, "ipv6_route: "+err.Error())
+	}
+	if info.Resolv, err = ReadResolvConf(resolvConfPath); err != nil {
+		info.Errors = append(info.Errors, "resolv.conf: "+err.Error())
+	}
+	return info
+}
+
+//############# BEGIN INFO HANDLERS
+
+// NetworkInfoHandler returns the network interfaces, default routes and dns resolver config of the container
+func (s *GoHttpServer) NetworkInfoHandler(procNetDir, resolvConfPath string) http.HandlerFunc {
+	handlerName := "NetworkInfoHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, r, http.StatusOK, GetNetworkInfo(procNetDir, resolvConfPath))
+	}
+}
+
+// ############# END INFO HANDLERS
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const testProcNetRoute = `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	000200C0	00000000	0001	0	0	0	00FFFFFF	0	0	0
+eth0	00000000	010200C0	0003	0	0	0	00000000	0	0	0
+`
+
+const testProcNetIpv6Route = `fd000000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 fd000000000000000000000000000001 00000400 00000001 00000000 00000003     eth0
+`
+
+const testResolvConf = `# generated by kubelet
+search default.svc.cluster.local svc.cluster.local cluster.local
+nameserver 10.96.0.10
+; a comment
+nameserver 10.96.0.11
+options ndots:5 timeout:2
+`
+
+// writeNetFiles creates a fake /proc/net directory and resolv.conf, returning their paths
+func writeNetFiles(t *testing.T, withIpv6 bool) (string, string) {
+	dir := t.TempDir()
+	files := map[string]string{"route": testProcNetRoute, "resolv.conf": testResolvConf}
+	if withIpv6 {
+		files["ipv6_route"] = testProcNetIpv6Route
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, filepath.Join(dir, "resolv.conf")
+}
+
+func TestGetNetworkInfo(t *testing.T) {
+	procNetDir, resolvConf := writeNetFiles(t, true)
+	info := GetNetworkInfo(procNetDir, resolvConf)
+	assert.Empty(t, info.Errors)
+	assert.Equal(t, &DefaultRoute{Gateway: "192.0.2.1", Interface: "eth0"}, info.DefaultRoute)
+	assert.Equal(t, &DefaultRoute{Gateway: "fd00::1", Interface: "eth0"}, info.DefaultRouteV6, "the loopback unreachable route should be skipped")
+	assert.Equal(t, &ResolvConf{
+		Nameservers: []string{"10.96.0.10", "10.96.0.11"},
+		Search:      []string{"default.svc.cluster.local", "svc.cluster.local", "cluster.local"},
+		Options:     []string{"ndots:5", "timeout:2"},
+	}, info.Resolv)
+	assert.NotEmpty(t, info.Interfaces, "at least the loopback interface should be listed")
+
+	procNetDir, _ = writeNetFiles(t, false)
+	info = GetNetworkInfo(procNetDir, filepath.Join(procNetDir, "missing.conf"))
+	assert.Nil(t, info.DefaultRouteV6)
+	assert.Nil(t, info.Resolv)
+	assert.Len(t, info.Errors, 1, "only the missing resolv.conf should be reported, ipv6 may be disabled")
+}
+
+func TestGoHttpServerNetworkInfoHandler(t *testing.T) {
+	procNetDir, resolvConf := writeNetFiles(t, false)
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(Chain(myServer.NetworkInfoHandler(procNetDir, resolvConf), myServer.allowMethods(http.MethodGet)))
+	defer ts.Close()
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var info NetworkInfo
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&info), "the output should be a valid json")
+	assert.Equal(t, "192.0.2.1", info.DefaultRoute.Gateway)
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+)
+
+// NotFoundError is the answer of an unknown path for the api clients
+type NotFoundError struct {
+	Status    int    `json:"status"`
+	Error     string `json:"error"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	RequestId string `json:"request_id,omitempty"`
+}
+
+// notFound answers 404 for the request r : a page for the browsers and an error object in json, yaml or xml for the
+// other clients. the unmatched paths are logged at not_found_log_level, debug by default since scanners send many
+func (s *GoHttpServer) notFound(w http.ResponseWriter, r *http.Request) {
+	config := s.settings.Current()
+	s.logger.Log(r.Context(), config.NotFoundLevel(), "path not found", "method", r.Method, "path", r.URL.Path,
+		"remote_addr", r.RemoteAddr, "user_agent", r.UserAgent())
+	format, _ := responseFormat(r, formatJson, formatYaml, formatXml, formatHtml)
+	if format != formatHtml {
+		s.render(w, r, http.StatusNotFound, NotFoundError{
+			Status:    http.StatusNotFound,
+			Error:     http.StatusText(http.StatusNotFound),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			RequestId: RequestIdFromContext(r.Context()),
+		})
+		return
+	}
+	w.Header().Set(HeaderContentType, MIMETextHTMLCharsetUTF8)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprintf(w, "%s\n<body><div class=\"container\"><h3>%s</h3><p>%s <code>%s</code> does not exist on this server.</p>"+
+		"<p><a class=\"button\" href=\"%s\">runtime information</a></p></div></body></html>",
+		getHtmlHeader(defaultNotFound), defaultNotFound, html.EscapeString(r.Method), html.EscapeString(r.URL.Path), defaultServerPath)
+}
+
+// NotFoundHandler returns the handler of the paths matching no route, registered on the catch-all / of each router
+func (s *GoHttpServer) NotFoundHandler() http.HandlerFunc {
+	handlerName := "NotFoundHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return s.notFound
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoHttpServerNotFound(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		path            string
+		accept          string
+		wantContentType string
+		wantBody        string
+		wantNotInBody   string
+	}{
+		{name: "1: api client should get a json error", path: "/nowhere", accept: "application/json",
+			wantContentType: MIMEAppJSONCharsetUTF8, wantBody: `"path":"/nowhere"`},
+		{name: "2: client without preference should get a json error", path: "/nowhere", accept: "*/*",
+			wantContentType: MIMEAppJSONCharsetUTF8, wantBody: `"error":"Not Found"`},
+		{name: "3: browser should get a page with the escaped path", path: "/%3Cscript%3E", accept: "text/html,*/*;q=0.8",
+			wantContentType: MIMETextHTMLCharsetUTF8, wantBody: "<code>/&lt;script&gt;</code>", wantNotInBody: "<script>"},
+		{name: "4: yaml client should get a yaml error", path: "/nowhere", accept: "application/yaml",
+			wantContentType: MIMEAppYAMLCharsetUTF8, wantBody: `path: "/nowhere"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+tt.path, nil)
+			req.Header.Set("Accept", tt.accept)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode, assertCorrectStatusCodeExpected)
+			assert.Equal(t, tt.wantContentType, resp.Header.Get(HeaderContentType))
+			body, _ := io.ReadAll(resp.Body)
+			assert.Contains(t, string(body), tt.wantBody)
+			if tt.wantNotInBody != "" {
+				assert.NotContains(t, string(body), tt.wantNotInBody)
+			}
+		})
+	}
+}
+
+func TestGoHttpServerNotFoundOnAdminPort(t *testing.T) {
+	settings := config.DefaultConfig()
+	settings.AdminPort = 9099
+	myServer := NewGoHttpServerWithConfig(config.DefaultListenIp+":0", settings, getTestLogger())
+	ts := httptest.NewServer(myServer.adminRouter)
+	defer ts.Close()
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/nowhere", nil)
+	req.Header.Set("Accept", MIMEAppJSON)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var notFound NotFoundError
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&notFound), "the admin port should answer the json error too")
+	assert.Equal(t, "/nowhere", notFound.Path)
+}
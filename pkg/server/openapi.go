@@ -0,0 +1,370 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+const (
+	openApiVersion   = "3.0.3"
+	swaggerUiVersion = "5.17.14"
+	swaggerUiPage    = `<!DOCTYPE html><html lang="en"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>` + info.APP + ` API</title>` +
+		`<link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/swagger-ui/` + swaggerUiVersion + `/swagger-ui.min.css"/></head>` +
+		`<body><div id="swagger-ui"></div><script src="https://cdnjs.cloudflare.com/ajax/libs/swagger-ui/` + swaggerUiVersion + `/swagger-ui-bundle.min.js"></script>` +
+		`<script>window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});</script></body></html>`
+)
+
+// allHttpMethods are the methods documented for the routes accepting any of them, like /echo
+var allHttpMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// ApiParam is a query parameter of a route
+type ApiParam struct {
+	Name        string // a wildcard {name} of the path like /requests/{id} is a path parameter, read with r.PathValue
+	Type        string // integer, number, string or boolean
+	Description string
+	Required    bool
+}
+
+// ApiRoute is a route registered with handleRoute, with what /openapi.json tells about it
+type ApiRoute struct {
+	Path        string
+	Methods     []string // http methods accepted, all of them when empty
+	Summary     string
+	Tag         string
+	Params      []ApiParam
+	Body        interface{} // zero value of the type of the json request body, nil without body
+	Response    interface{} // zero value of the type of the json answer, nil when the answer is not json
+	ContentType string      // type of the answer when it is not json, like text/html
+	Auth        bool        // the route needs the credentials of AUTH_MODE or API_TOKEN, handleRoute adds authenticate and requireAuth
+	Admin       bool        // the route is served by the admin port when there is one, it has no /api/v1 path
+	Raw         bool        // the answer is not wrapped in an ApiEnvelope under /api/v1, like the websocket stream
+	Privileged  bool        // the route changes the state of the server, it needs credentials on the main and the admin port
+}
+
+// rawInV1 returns true when the /api/v1 path of the route answers like the legacy one, without ApiEnvelope
+func (route ApiRoute) rawInV1() bool {
+	return route.Raw || route.Response == nil
+}
+
+// (*GoHttpServer) handleRoute registers the handler like handle or handleAdmin, with the allowMethods Middleware
+// of route.Methods, and records the route for /openapi.json so that the document follows the real routes.
+// the routes of the main port are also registered under /api/v1, the legacy path staying as an alias
+func (s *GoHttpServer) handleRoute(route ApiRoute, handler http.Handler, middlewares ...Middleware) {
+	if len(route.Methods) > 0 {
+		middlewares = append([]Middleware{s.allowMethods(route.Methods...)}, middlewares...)
+	}
+	if route.Auth {
+		middlewares = append(middlewares, s.authenticate(), s.requireAuth())
+	}
+	if route.Privileged {
+		middlewares = append(middlewares, s.requireCredentials())
+	}
+	if route.Admin {
+		s.handleAdmin(route.Path, handler, middlewares...)
+	} else {
+		s.handle(route.Path, handler, middlewares...)
+		v1 := append([]Middleware{s.versioned(route.Path, route.rawInV1())}, middlewares...)
+		s.handle(versionedPath(route.Path), handler, v1...)
+	}
+	s.apiRoutes = append(s.apiRoutes, route)
+}
+
+// OpenApiSchema is a json schema of the OpenAPI document, the named structs are in the components
+type OpenApiSchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Items                *OpenApiSchema            `json:"items,omitempty"`
+	Properties           map[string]*OpenApiSchema `json:"properties,omitempty"`
+	AdditionalProperties *OpenApiSchema            `json:"additionalProperties,omitempty"`
+}
+
+type OpenApiParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *OpenApiSchema `json:"schema"`
+}
+
+type OpenApiMediaType struct {
+	Schema *OpenApiSchema `json:"schema"`
+}
+
+type OpenApiBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]OpenApiMediaType `json:"content"`
+}
+
+type OpenApiResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenApiMediaType `json:"content,omitempty"`
+}
+
+type OpenApiOperation struct {
+	OperationId string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Deprecated  bool                       `json:"deprecated,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []OpenApiParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenApiBody               `json:"requestBody,omitempty"`
+	Responses   map[string]OpenApiResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+}
+
+type OpenApiSecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+type OpenApiComponents struct {
+	Schemas         map[string]*OpenApiSchema        `json:"schemas"`
+	SecuritySchemes map[string]OpenApiSecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type OpenApiInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenApiDocument is the OpenAPI 3 description of the http api, the json encoding sorts the maps so it is stable
+type OpenApiDocument struct {
+	OpenApi    string                                  `json:"openapi"`
+	Info       OpenApiInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenApiOperation `json:"paths"`
+	Components OpenApiComponents                       `json:"components"`
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaOf returns the schema of the json encoding of t, the named structs are added to schemas and referenced
+func schemaOf(t reflect.Type, schemas map[string]*OpenApiSchema) *OpenApiSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &OpenApiSchema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &OpenApiSchema{Type: "integer", Format: "int64"} // nanoseconds
+	case rawMessageType:
+		return &OpenApiSchema{Type: "object"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &OpenApiSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &OpenApiSchema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &OpenApiSchema{Type: "integer", Format: "int32"}
+	case reflect.Float32, reflect.Float64:
+		return &OpenApiSchema{Type: "number"}
+	case reflect.String:
+		return &OpenApiSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &OpenApiSchema{Type: "string", Format: "byte"}
+		}
+		return &OpenApiSchema{Type: "array", Items: schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return &OpenApiSchema{Type: "object", AdditionalProperties: schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		if _, found := schemas[t.Name()]; !found {
+			// registered before its fields so that a recursive type ends on its reference
+			schemas[t.Name()] = &OpenApiSchema{}
+			*schemas[t.Name()] = *structSchema(t, schemas)
+		}
+		return &OpenApiSchema{Ref: "#/components/schemas/" + t.Name()}
+	}
+	// an interface{} can be anything
+	return &OpenApiSchema{}
+}
+
+// structSchema returns the object schema of the exported fields of t, named as encoding/json does
+func structSchema(t reflect.Type, schemas map[string]*OpenApiSchema) *OpenApiSchema {
+	schema := &OpenApiSchema{Type: "object", Properties: make(map[string]*OpenApiSchema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for n, p := range structSchema(embedded, schemas).Properties {
+					schema.Properties[n] = p
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = schemaOf(field.Type, schemas)
+	}
+	return schema
+}
+
+// operationId returns a name like getK8sPod for the GET of /k8s/pod
+func operationId(method, path string) string {
+	id := strings.ToLower(method)
+	if path == "/" {
+		return id + "Root"
+	}
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '-' || r == '_' || r == '.' }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+// envelopeSchema returns the schema of an ApiEnvelope with data of the given schema
+func envelopeSchema(data *OpenApiSchema, schemas map[string]*OpenApiSchema) *OpenApiSchema {
+	return &OpenApiSchema{Type: "object", Properties: map[string]*OpenApiSchema{
+		"api_version": {Type: "string"},
+		"status":      {Type: "string"},
+		"data":        data,
+		"error":       schemaOf(reflect.TypeOf(ApiError{}), schemas),
+	}}
+}
+
+// operations returns the OpenAPI operations of the methods of the route served at path, with the answer in
+// an ApiEnvelope when enveloped is true
+func (route ApiRoute) operations(path string, enveloped bool, security []map[string][]string, schemas map[string]*OpenApiSchema) map[string]*OpenApiOperation {
+	methods := route.Methods
+	if len(methods) == 0 {
+		methods = allHttpMethods
+	}
+	operations := make(map[string]*OpenApiOperation)
+	for _, method := range methods {
+		op := &OpenApiOperation{
+			OperationId: operationId(method, path),
+			Summary:     route.Summary,
+			Responses:   make(map[string]OpenApiResponse),
+		}
+		if route.Tag != "" {
+			op.Tags = []string{route.Tag}
+		}
+		for _, p := range route.Params {
+			param := OpenApiParameter{Name: p.Name, In: "query", Description: p.Description, Required: p.Required,
+				Schema: &OpenApiSchema{Type: p.Type}}
+			if strings.Contains(route.Path, "{"+p.Name+"}") {
+				param.In, param.Required = "path", true
+			}
+			op.Parameters = append(op.Parameters, param)
+		}
+		if route.Body != nil && method != http.MethodGet {
+			op.RequestBody = &OpenApiBody{Required: true, Content: map[string]OpenApiMediaType{
+				MIMEAppJSON: {Schema: schemaOf(reflect.TypeOf(route.Body), schemas)}}}
+		}
+		ok := OpenApiResponse{Description: "OK"}
+		switch {
+		case route.Response != nil && enveloped:
+			ok.Content = map[string]OpenApiMediaType{MIMEAppJSON: {Schema: envelopeSchema(schemaOf(reflect.TypeOf(route.Response), schemas), schemas)}}
+		case route.Response != nil:
+			ok.Content = map[string]OpenApiMediaType{MIMEAppJSON: {Schema: schemaOf(reflect.TypeOf(route.Response), schemas)}}
+		case route.ContentType != "":
+			ok.Content = map[string]OpenApiMediaType{route.ContentType: {Schema: &OpenApiSchema{Type: "string"}}}
+		}
+		op.Responses["200"] = ok
+		errorResponse := func(description string) OpenApiResponse {
+			if !enveloped {
+				return OpenApiResponse{Description: description}
+			}
+			return OpenApiResponse{Description: description, Content: map[string]OpenApiMediaType{
+				MIMEAppJSON: {Schema: envelopeSchema(&OpenApiSchema{}, schemas)}}}
+		}
+		if len(route.Params) > 0 || op.RequestBody != nil {
+			op.Responses["400"] = errorResponse("invalid parameter")
+		}
+		if route.Auth && security != nil {
+			op.Security = security
+			op.Responses["401"] = errorResponse("missing or invalid credentials")
+		}
+		operations[strings.ToLower(method)] = op
+	}
+	return operations
+}
+
+// OpenApi returns the OpenAPI document of the routes registered with handleRoute on the main port, the legacy
+// paths are deprecated in favor of their /api/v1 paths
+func (s *GoHttpServer) OpenApi() OpenApiDocument {
+	doc := OpenApiDocument{
+		OpenApi:    openApiVersion,
+		Info:       OpenApiInfo{Title: info.APP, Version: info.VERSION},
+		Paths:      make(map[string]map[string]*OpenApiOperation),
+		Components: OpenApiComponents{Schemas: make(map[string]*OpenApiSchema)},
+	}
+	// each scheme accepted by the Auth routes is an alternative of their security requirement
+	var security []map[string][]string
+	schemes := make(map[string]OpenApiSecurityScheme)
+	if s.auth.Mode == authModeBasic || s.auth.Mode == authModeBearer {
+		schemes[s.auth.Mode] = OpenApiSecurityScheme{Type: "http", Scheme: s.auth.Mode}
+		security = append(security, map[string][]string{s.auth.Mode: {}})
+	}
+	if s.apiToken != "" {
+		schemes["api_token"] = OpenApiSecurityScheme{Type: "http", Scheme: authModeBearer}
+		schemes[accessTokenQueryParam] = OpenApiSecurityScheme{Type: "apiKey", In: "query", Name: accessTokenQueryParam}
+		security = append(security, map[string][]string{"api_token": {}}, map[string][]string{accessTokenQueryParam: {}})
+	}
+	if len(schemes) > 0 {
+		doc.Components.SecuritySchemes = schemes
+	}
+	for _, route := range s.apiRoutes {
+		if route.Admin {
+			// not in the document of the main port when they are served by the admin port
+			if s.adminRouter == nil {
+				doc.Paths[route.Path] = route.operations(route.Path, false, security, doc.Components.Schemas)
+			}
+			continue
+		}
+		legacy := route.operations(route.Path, false, security, doc.Components.Schemas)
+		for _, op := range legacy {
+			op.Deprecated = true
+		}
+		doc.Paths[route.Path] = legacy
+		doc.Paths[versionedPath(route.Path)] = route.operations(versionedPath(route.Path), !route.rawInV1(), security, doc.Components.Schemas)
+	}
+	return doc
+}
+
+// OpenApiHandler returns the OpenAPI 3 document of the http api
+func (s *GoHttpServer) OpenApiHandler() http.HandlerFunc {
+	handlerName := "OpenApiHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.jsonResponseWithStatus(w, r, http.StatusOK, s.OpenApi())
+	}
+}
+
+// DocsHandler returns the Swagger UI page showing /openapi.json
+func (s *GoHttpServer) DocsHandler() http.HandlerFunc {
+	handlerName := "DocsHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, "text/html; "+charsetUTF8)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(swaggerUiPage))
+	}
+}
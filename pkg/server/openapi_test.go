@@ -0,0 +1,188 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
+)
+
+type openApiTestNode struct {
+	Name     string             `json:"name"`
+	Children []*openApiTestNode `json:"children,omitempty"`
+	Ignored  string             `json:"-"`
+	hidden   string
+}
+
+type openApiTestEmbedded struct {
+	Id int64 `json:"id"`
+}
+
+type openApiTestItem struct {
+	openApiTestEmbedded
+	Created time.Time         `json:"created"`
+	Timeout time.Duration     `json:"timeout"`
+	Labels  map[string]string `json:"labels"`
+	Data    []byte            `json:"data"`
+	Raw     json.RawMessage   `json:"raw"`
+	Any     interface{}       `json:"any"`
+	Root    *openApiTestNode  `json:"root"`
+	Ratio   float64
+}
+
+func TestSchemaOf(t *testing.T) {
+	schemas := make(map[string]*OpenApiSchema)
+	schema := schemaOf(reflect.TypeOf(openApiTestItem{}), schemas)
+	assert.Equal(t, &OpenApiSchema{Ref: "#/components/schemas/openApiTestItem"}, schema)
+	item := schemas["openApiTestItem"]
+	if !assert.NotNil(t, item) {
+		return
+	}
+	assert.Equal(t, "object", item.Type)
+	assert.Equal(t, &OpenApiSchema{Type: "integer", Format: "int64"}, item.Properties["id"], "the fields of the embedded struct should be flattened")
+	assert.Equal(t, &OpenApiSchema{Type: "string", Format: "date-time"}, item.Properties["created"])
+	assert.Equal(t, &OpenApiSchema{Type: "integer", Format: "int64"}, item.Properties["timeout"])
+	assert.Equal(t, &OpenApiSchema{Type: "object", AdditionalProperties: &OpenApiSchema{Type: "string"}}, item.Properties["labels"])
+	assert.Equal(t, &OpenApiSchema{Type: "string", Format: "byte"}, item.Properties["data"])
+	assert.Equal(t, &OpenApiSchema{Type: "object"}, item.Properties["raw"])
+	assert.Equal(t, &OpenApiSchema{}, item.Properties["any"])
+	assert.Equal(t, &OpenApiSchema{Type: "number"}, item.Properties["Ratio"], "a field without json tag should keep its name")
+	assert.Equal(t, &OpenApiSchema{Ref: "#/components/schemas/openApiTestNode"}, item.Properties["root"])
+
+	node := schemas["openApiTestNode"]
+	if assert.NotNil(t, node) {
+		assert.Len(t, node.Properties, 2, "the ignored and unexported fields should be skipped")
+		assert.Equal(t, &OpenApiSchema{Type: "array", Items: &OpenApiSchema{Ref: "#/components/schemas/openApiTestNode"}},
+			node.Properties["children"], "a recursive type should end on its reference")
+	}
+}
+
+func TestOperationId(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{method: http.MethodGet, path: "/", want: "getRoot"},
+		{method: http.MethodGet, path: "/time", want: "getTime"},
+		{method: http.MethodPost, path: "/chaos/error", want: "postChaosError"},
+		{method: http.MethodGet, path: "/openapi.json", want: "getOpenapiJson"},
+		{method: http.MethodGet, path: "/k8s/pod", want: "getK8sPod"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, operationId(tt.method, tt.path))
+	}
+}
+
+// collectRefs returns all the references used by schema
+func collectRefs(schema *OpenApiSchema, refs map[string]bool) {
+	if schema == nil {
+		return
+	}
+	if schema.Ref != "" {
+		refs[strings.TrimPrefix(schema.Ref, "#/components/schemas/")] = true
+	}
+	collectRefs(schema.Items, refs)
+	collectRefs(schema.AdditionalProperties, refs)
+	for _, p := range schema.Properties {
+		collectRefs(p, refs)
+	}
+}
+
+func TestGoHttpServerOpenApi(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	doc := myServer.OpenApi()
+	assert.Equal(t, openApiVersion, doc.OpenApi)
+	assert.Equal(t, info.VERSION, doc.Info.Version)
+	for _, route := range myServer.apiRoutes {
+		assert.Contains(t, doc.Paths, route.Path, "every registered route should be documented")
+	}
+	for _, path := range []string{"/", "/time", "/health", "/metrics", "/openapi.json", "/docs"} {
+		assert.Contains(t, doc.Paths, path)
+	}
+	assert.Len(t, doc.Paths["/echo"], len(allHttpMethods), "a route without methods should document all of them")
+	assert.Len(t, doc.Paths["/chaos/error"], 2)
+	wait := doc.Paths["/wait"]["get"]
+	if assert.NotNil(t, wait) {
+		assert.Len(t, wait.Parameters, 2)
+		assert.Equal(t, "#/components/schemas/waitResult", wait.Responses["200"].Content[MIMEAppJSON].Schema.Ref)
+		assert.Nil(t, wait.Security, "there should be no security without AUTH_MODE")
+	}
+
+	refs := make(map[string]bool)
+	for _, operations := range doc.Paths {
+		for _, op := range operations {
+			for _, resp := range op.Responses {
+				for _, media := range resp.Content {
+					collectRefs(media.Schema, refs)
+				}
+			}
+		}
+	}
+	for _, schema := range doc.Components.Schemas {
+		collectRefs(schema, refs)
+	}
+	for name := range refs {
+		assert.Contains(t, doc.Components.Schemas, name, "every reference should be in the components")
+	}
+
+	myServer.UseAuth(AuthConfig{Mode: authModeBearer, Token: "secret"})
+	doc = myServer.OpenApi()
+	assert.Equal(t, map[string]OpenApiSecurityScheme{authModeBearer: {Type: "http", Scheme: "bearer"}}, doc.Components.SecuritySchemes)
+	assert.Equal(t, []map[string][]string{{authModeBearer: {}}}, doc.Paths["/config"]["get"].Security)
+	assert.Contains(t, doc.Paths["/config"]["get"].Responses, "401")
+	assert.Nil(t, doc.Paths["/time"]["get"].Security, "the public routes should not need credentials")
+
+	myServer.apiToken = "s3cret"
+	doc = myServer.OpenApi()
+	assert.Equal(t, OpenApiSecurityScheme{Type: "apiKey", In: "query", Name: accessTokenQueryParam}, doc.Components.SecuritySchemes[accessTokenQueryParam])
+	assert.Equal(t, []map[string][]string{{authModeBearer: {}}, {"api_token": {}}, {accessTokenQueryParam: {}}}, doc.Paths["/config"]["get"].Security,
+		"the API_TOKEN bearer and the access_token should be alternatives to the AUTH_MODE credentials")
+
+	t.Setenv("ADMIN_PORT", "8081")
+	myServer = NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	doc = myServer.OpenApi()
+	assert.NotContains(t, doc.Paths, "/health", "the routes of the admin port should not be in the document of the main port")
+	assert.Contains(t, doc.Paths, "/time")
+}
+
+func TestGoHttpServerOpenApiHandlers(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var doc map[string]interface{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+	assert.Equal(t, openApiVersion, doc["openapi"])
+
+	resp, err = http.Get(ts.URL + "/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(resp.Header.Get(HeaderContentType), "text/html"))
+	assert.Contains(t, string(body), `url: "/openapi.json"`)
+
+	resp, err = http.Post(ts.URL+"/openapi.json", MIMEAppJSON, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode, "the methods of the route should be enforced")
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+)
+
+const (
+	pprofPathPrefix            = "/debug/pprof/"
+	defaultPprofProfileSeconds = 30 // default of the seconds parameter of net/http/pprof Profile
+	defaultPprofTraceSeconds   = 1  // default of the seconds parameter of net/http/pprof Trace
+	maxPprofSeconds            = 60 // longest profile or trace, so a request cannot hold a connection and the profiler for ever
+)
+
+// newPprofMux returns a mux serving the net/http/pprof handlers under /debug/pprof/
+func newPprofMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pprofPathPrefix, pprof.Index)
+	mux.HandleFunc(pprofPathPrefix+"cmdline", pprof.Cmdline)
+	mux.Handle(pprofPathPrefix+"profile", extendPprofDeadline(defaultPprofProfileSeconds, pprof.Profile))
+	mux.HandleFunc(pprofPathPrefix+"symbol", pprof.Symbol)
+	mux.Handle(pprofPathPrefix+"trace", extendPprofDeadline(defaultPprofTraceSeconds, pprof.Trace))
+	return mux
+}
+
+// extendPprofDeadline lets the cpu profile and the trace run during their seconds parameter on the main or the admin
+// port, by extending the write deadline like the load handlers. net/http/pprof refuses a duration longer than the
+// WriteTimeout of the server found in the request context, so the handler is given a server without WriteTimeout.
+// a duration above maxPprofSeconds is refused with a 400
+func extendPprofDeadline(defaultSeconds float64, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seconds, err := strconv.ParseFloat(r.URL.Query().Get("seconds"), 64)
+		if err != nil || seconds <= 0 {
+			seconds = defaultSeconds
+		}
+		// written negated so that NaN is refused too
+		if !(seconds <= maxPprofSeconds) {
+			http.Error(w, fmt.Sprintf("ERROR: parameter seconds should be at most %d", maxPprofSeconds), http.StatusBadRequest)
+			return
+		}
+		extendWriteDeadline(w, time.Duration(seconds*float64(time.Second)))
+		if srv, ok := r.Context().Value(http.ServerContextKey).(*http.Server); ok && srv.WriteTimeout > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), http.ServerContextKey, &http.Server{Addr: srv.Addr}))
+		}
+		next(w, r)
+	})
+}
+
+// (*GoHttpServer) pprofHandler returns the pprof mux of PPROF_PORT with the middlewares of the pprof route of the main
+// port, so the dedicated port applies IP_ALLOWLIST and IP_DENYLIST and needs the credentials of AUTH_MODE or API_TOKEN
+func (s *GoHttpServer) pprofHandler() http.Handler {
+	middlewares := []Middleware{s.authenticate(), s.requireAuth(), s.requireCredentials()}
+	if s.ipAccess != nil {
+		middlewares = append([]Middleware{s.checkIpAccess()}, middlewares...)
+	}
+	if len(s.trustedProxies) > 0 {
+		middlewares = append([]Middleware{s.resolveRealIp()}, middlewares...)
+	}
+	return Chain(newPprofMux(), middlewares...)
+}
+
+// newPprofServer returns an http server for the pprof endpoints only. there is no WriteTimeout,
+// so that cpu profiles and traces can be longer than the one of the main server.
+func newPprofServer(listenAddress string, handler http.Handler, logger *slog.Logger) *http.Server {
+	return &http.Server{
+		Addr:        listenAddress,
+		Handler:     handler,
+		ErrorLog:    slog.NewLogLogger(logger.Handler(), slog.LevelError),
+		ReadTimeout: config.DefaultReadTimeout,
+		IdleTimeout: config.DefaultIdleTimeout,
+	}
+}
+
+// startPprofServer starts the dedicated pprof listener in his own goroutine
+func (s *GoHttpServer) startPprofServer() {
+	go func() {
+		s.logger.Info("Starting pprof server", "url", fmt.Sprintf("http://%s%s", s.pprofServer.Addr, pprofPathPrefix))
+		if err := s.pprofServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.logger.Error("pprof server stopped", "address", s.pprofServer.Addr, "error", err)
+		}
+	}()
+}
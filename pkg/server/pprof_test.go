@@ -0,0 +1,111 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoHttpServerPprof(t *testing.T) {
+	getStatus := func(router http.Handler, path string) (int, string) {
+		ts := httptest.NewServer(router)
+		defer ts.Close()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+os.Getenv("API_TOKEN"))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request on %s failed : %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	_, body := getStatus(myServer.router, pprofPathPrefix)
+	assert.NotContains(t, body, "Types of profiles available", "pprof should not be served when disabled")
+
+	t.Setenv("ENABLE_PPROF", "true")
+	myServer = NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	status, body := getStatus(myServer.router, pprofPathPrefix)
+	assert.Equal(t, http.StatusForbidden, status, "pprof on the main port should be refused without credentials")
+	assert.NotContains(t, body, "Types of profiles available")
+
+	t.Setenv("IP_DENYLIST", "127.0.0.1/32")
+	t.Setenv("API_TOKEN", "pprof-test-token")
+	myServer = NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	status, _ = getStatus(myServer.router, pprofPathPrefix)
+	assert.Equal(t, http.StatusForbidden, status, "pprof on the main port should apply IP_DENYLIST")
+
+	t.Setenv("IP_DENYLIST", "")
+	myServer = NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	status, body = getStatus(myServer.router, pprofPathPrefix)
+	assert.Equal(t, http.StatusOK, status, "pprof on the main port should accept the API_TOKEN bearer")
+	assert.Contains(t, body, "Types of profiles available")
+	status, _ = getStatus(myServer.router, pprofPathPrefix+"heap")
+	assert.Equal(t, http.StatusOK, status)
+
+	t.Setenv("PPROF_PORT", "6060")
+	myServer = NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	_, body = getStatus(myServer.router, pprofPathPrefix)
+	assert.NotContains(t, body, "Types of profiles available", "pprof should only be on the dedicated port")
+	if assert.NotNil(t, myServer.pprofServer) {
+		status, body = getStatus(myServer.pprofServer.Handler, pprofPathPrefix)
+		assert.Equal(t, http.StatusOK, status, "pprof on the dedicated port should accept the API_TOKEN bearer")
+		assert.Contains(t, body, "Types of profiles available")
+		rec := httptest.NewRecorder()
+		myServer.pprofServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, pprofPathPrefix, nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "pprof on the dedicated port should be refused without the API_TOKEN bearer")
+	}
+
+	t.Setenv("IP_DENYLIST", "127.0.0.1/32")
+	myServer = NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	status, _ = getStatus(myServer.pprofServer.Handler, pprofPathPrefix)
+	assert.Equal(t, http.StatusForbidden, status, "pprof on the dedicated port should apply IP_DENYLIST")
+
+	t.Setenv("IP_DENYLIST", "")
+	t.Setenv("API_TOKEN", "")
+	myServer = NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	status, body = getStatus(myServer.pprofServer.Handler, pprofPathPrefix)
+	assert.Equal(t, http.StatusForbidden, status, "pprof on the dedicated port should be refused without credentials")
+	assert.NotContains(t, body, "Types of profiles available")
+}
+
+func TestPprofProfileLongerThanWriteTimeout(t *testing.T) {
+	ts := httptest.NewUnstartedServer(newPprofMux())
+	ts.Config.WriteTimeout = time.Second
+	ts.Start()
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + pprofPathPrefix + "profile?seconds=2")
+	if err != nil {
+		t.Fatalf("request of the cpu profile failed : %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err, "the profile should be written after the WriteTimeout of the server")
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	assert.NotEmpty(t, body)
+}
+
+func TestPprofSecondsTooLong(t *testing.T) {
+	ts := httptest.NewServer(newPprofMux())
+	defer ts.Close()
+	for _, path := range []string{"profile?seconds=1e9", "profile?seconds=61", "trace?seconds=NaN", "trace?seconds=Inf"} {
+		t.Run(path, func(t *testing.T) {
+			start := time.Now()
+			resp, err := http.Get(ts.URL + pprofPathPrefix + path)
+			if err != nil {
+				t.Fatalf("request of %s failed : %v", path, err)
+			}
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "a duration above maxPprofSeconds should be refused")
+			assert.Less(t, time.Since(start), time.Second, "the profile should not be started")
+		})
+	}
+}
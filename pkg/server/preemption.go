@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultPreemptionInterval is how often the notice is polled, aws gives two minutes and gcp 30 seconds before the end
+const defaultPreemptionInterval = 5 * time.Second
+
+// PreemptionState is the last answer of the metadata service about the interruption of this spot or preemptible instance
+type PreemptionState struct {
+	Provider  string     `json:"provider"`
+	Supported bool       `json:"supported"` // false when the cloud has no preemption notice we know how to read
+	Preempted bool       `json:"preempted"` // the instance is going to be stopped or terminated
+	Action    string     `json:"action,omitempty"`
+	Time      *time.Time `json:"time,omitempty"` // when the action will happen, only given by aws
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// SpotWatcher polls the metadata service for the interruption notice of a spot instance on aws or the preempted flag
+// of a spot or preemptible vm on gcp
+type SpotWatcher struct {
+	detector *CloudDetector
+	logger   *slog.Logger
+	now      func() time.Time // time.Now, replaced in tests
+	mu       sync.RWMutex
+	state    PreemptionState
+}
+
+// NewSpotWatcher is a constructor for a SpotWatcher using the metadata services found by detector
+func NewSpotWatcher(detector *CloudDetector, logger *slog.Logger) *SpotWatcher {
+	return &SpotWatcher{detector: detector, logger: logger, now: time.Now}
+}
+
+// State returns the last known preemption state
+func (sw *SpotWatcher) State() PreemptionState {
+	sw.mu.RLock()
+	defer sw.mu.RUnlock()
+	return sw.state
+}
+
+// Poll asks the metadata service once and returns the new state
+func (sw *SpotWatcher) Poll(ctx context.Context) PreemptionState {
+	provider := sw.detector.Detect().Provider
+	state := PreemptionState{Provider: provider, Supported: provider == cloudProviderAws || provider == cloudProviderGcp}
+	if state.Supported {
+		var err error
+		switch provider {
+		case cloudProviderAws:
+			err = sw.pollAws(ctx, &state)
+		case cloudProviderGcp:
+			err = sw.pollGcp(ctx, &state)
+		}
+		now := sw.now().UTC()
+		state.CheckedAt = &now
+		if err != nil {
+			state.Error = err.Error()
+		}
+	}
+	sw.mu.Lock()
+	previous := sw.state
+	if state.Error != "" {
+		// keep the last notice, a metadata service briefly unavailable does not cancel it
+		state.Preempted, state.Action, state.Time = previous.Preempted, previous.Action, previous.Time
+	}
+	sw.state = state
+	sw.mu.Unlock()
+	if state.Preempted && !previous.Preempted {
+		sw.logger.Warn("preemption notice received, this instance is going to be stopped", "provider", provider, "action", state.Action, "time", state.Time)
+	}
+	return state
+}
+
+// pollAws reads the spot instance-action, which is absent (404) until the instance is going to be interrupted
+func (sw *SpotWatcher) pollAws(ctx context.Context, state *PreemptionState) error {
+	headers, err := sw.detector.awsHeaders(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := sw.detector.get(ctx, http.MethodGet, "/latest/meta-data/spot/instance-action", headers)
+	if err != nil {
+		if errors.Is(err, errCloudMetadataNotFound) {
+			return nil
+		}
+		return err
+	}
+	var notice struct {
+		Action string    `json:"action"`
+		Time   time.Time `json:"time"`
+	}
+	if err := json.Unmarshal(body, &notice); err != nil {
+		return err
+	}
+	state.Preempted, state.Action, state.Time = true, notice.Action, &notice.Time
+	return nil
+}
+
+// pollGcp reads the preempted flag of the instance, TRUE when it is going to be stopped
+func (sw *SpotWatcher) pollGcp(ctx context.Context, state *PreemptionState) error {
+	body, err := sw.detector.get(ctx, http.MethodGet, "/computeMetadata/v1/instance/preempted", gcpHeaders)
+	if err != nil {
+		return err
+	}
+	switch strings.TrimSpace(string(body)) {
+	case "TRUE":
+		state.Preempted, state.Action = true, "stop"
+	case "FALSE":
+	default:
+		return errors.New("unexpected preempted value " + strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Watch polls the preemption notice every interval until ctx is done, it stops at once when the cloud has none
+func (sw *SpotWatcher) Watch(ctx context.Context, interval time.Duration) {
+	state := sw.Poll(ctx)
+	if !state.Supported {
+		sw.logger.Info("NOTICE: no preemption notice on this cloud, the watcher is stopped", "provider", state.Provider)
+		return
+	}
+	sw.logger.Info("Watching the preemption notice", "provider", state.Provider, "interval", interval.String())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sw.Poll(ctx)
+		}
+	}
+}
+
+// PreemptionCheck is a readiness check failing when the instance is going to be preempted,
+// so that the traffic moves to the other pods before the node disappears
+type PreemptionCheck struct {
+	Watcher *SpotWatcher
+}
+
+func (c *PreemptionCheck) Name() string { return "preemption" }
+func (c *PreemptionCheck) Type() string { return "cloud" }
+
+func (c *PreemptionCheck) Check(_ context.Context) error {
+	if state := c.Watcher.State(); state.Preempted {
+		return errors.New("the instance is going to be preempted, action " + state.Action)
+	}
+	return nil
+}
+
+// PreemptionHandler returns the last known preemption state of the instance
+func (s *GoHttpServer) PreemptionHandler(watcher *SpotWatcher) http.HandlerFunc {
+	handlerName := "PreemptionHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, r, http.StatusOK, watcher.State())
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpotWatcherPoll(t *testing.T) {
+	var notice atomic.Value
+	notice.Store("")
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/api/token":
+			fmt.Fprint(w, "aws-session-token")
+		case "/latest/dynamic/instance-identity/document":
+			fmt.Fprint(w, `{"region":"eu-central-2","instanceId":"i-0123456789abcdef0"}`)
+		case "/latest/meta-data/spot/instance-action":
+			if n := notice.Load().(string); n != "" {
+				fmt.Fprint(w, n)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer api.Close()
+	sw := NewSpotWatcher(NewCloudDetector(api.URL, time.Second), getTestLogger())
+	check := &PreemptionCheck{Watcher: sw}
+
+	state := sw.Poll(context.Background())
+	assert.Equal(t, cloudProviderAws, state.Provider)
+	assert.True(t, state.Supported)
+	assert.False(t, state.Preempted, "there should be no notice while instance-action is absent")
+	assert.NoError(t, check.Check(context.Background()))
+
+	notice.Store(`{"action":"terminate","time":"2024-03-05T12:02:00Z"}`)
+	state = sw.Poll(context.Background())
+	assert.True(t, state.Preempted)
+	assert.Equal(t, "terminate", state.Action)
+	if assert.NotNil(t, state.Time) {
+		assert.Equal(t, time.Date(2024, time.March, 5, 12, 2, 0, 0, time.UTC), *state.Time)
+	}
+	assert.Error(t, check.Check(context.Background()), "readiness should fail when the instance is going to be preempted")
+
+	notice.Store(`not json`)
+	state = sw.Poll(context.Background())
+	assert.NotEmpty(t, state.Error)
+	assert.True(t, state.Preempted, "an error of the metadata service should not cancel the notice")
+}
+
+func TestSpotWatcherGcp(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/computeMetadata/v1/instance/":
+			fmt.Fprint(w, `{"id":1,"zone":"projects/1/zones/europe-west6-a"}`)
+		case "/computeMetadata/v1/instance/preempted":
+			fmt.Fprint(w, "TRUE")
+		}
+	}))
+	defer api.Close()
+	state := NewSpotWatcher(NewCloudDetector(api.URL, time.Second), getTestLogger()).Poll(context.Background())
+	assert.Equal(t, cloudProviderGcp, state.Provider)
+	assert.True(t, state.Preempted)
+	assert.Equal(t, "stop", state.Action)
+}
+
+func TestSpotWatcherUnsupported(t *testing.T) {
+	api := httptest.NewServer(http.NotFoundHandler())
+	defer api.Close()
+	sw := NewSpotWatcher(NewCloudDetector(api.URL, time.Second), getTestLogger())
+	done := make(chan struct{})
+	go func() {
+		sw.Watch(context.Background(), time.Hour)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch should stop when the cloud has no preemption notice")
+	}
+	assert.False(t, sw.State().Supported)
+	assert.Equal(t, cloudProviderNone, sw.State().Provider)
+}
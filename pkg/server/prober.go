@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProbeTimeout = 5 * time.Second
+	defaultProbeHistory = 60 // results kept by target for /probe/status, ten minutes at the default interval
+)
+
+// ProbeResult is one request of the prober to a target
+type ProbeResult struct {
+	Time      time.Time `json:"time"`
+	Status    int       `json:"status,omitempty"` // omitted when the request failed
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Ok returns true when the target answered with a 2xx or 3xx status
+func (r ProbeResult) Ok() bool {
+	return r.Error == "" && r.Status >= http.StatusOK && r.Status < http.StatusBadRequest
+}
+
+// ProbeTargetStatus is the state of a target of the prober with its latest results
+type ProbeTargetStatus struct {
+	Url          string        `json:"url"`
+	Up           bool          `json:"up"` // the last request succeeded
+	Checks       int           `json:"checks"`
+	Failures     int           `json:"failures"`
+	SuccessRatio float64       `json:"success_ratio"`            // of the results of the history
+	AvgLatencyMs float64       `json:"avg_latency_ms,omitempty"` // of the successful results of the history
+	MaxLatencyMs float64       `json:"max_latency_ms,omitempty"` // of the successful results of the history
+	LastChange   *time.Time    `json:"last_change,omitempty"`    // when the target went up or down
+	History      []ProbeResult `json:"history"`                  // oldest first
+	LastError    string        `json:"last_error,omitempty"`     // error of the last failed request
+	LastStatus   int           `json:"last_status,omitempty"`    // status of the last request
+}
+
+// ProbeStatusReport is the answer of /probe/status and /probe
+type ProbeStatusReport struct {
+	IntervalSeconds float64             `json:"interval_seconds"`
+	Rounds          int                 `json:"rounds"` // polls of all the targets since the start
+	Up              int                 `json:"up"`
+	Down            int                 `json:"down"`
+	Targets         []ProbeTargetStatus `json:"targets"`
+}
+
+// probeTarget is what the Prober remembers of a target between two polls
+type probeTarget struct {
+	ProbeTargetStatus
+	polled bool
+}
+
+// Prober polls a list of urls and keeps their status and latency history, turning the pod into a small blackbox
+// prober for smoke tests
+type Prober struct {
+	client     *http.Client
+	logger     *slog.Logger
+	maxHistory int
+	now        func() time.Time // time.Now, replaced in tests
+	mu         sync.RWMutex
+	interval   time.Duration
+	rounds     int
+	targets    []*probeTarget
+}
+
+// NewProber is a constructor for a Prober of urls, each request is abandoned after timeout
+func NewProber(urls []string, timeout time.Duration, logger *slog.Logger) *Prober {
+	p := &Prober{client: &http.Client{Timeout: timeout}, logger: logger, maxHistory: defaultProbeHistory, now: time.Now}
+	for _, u := range urls {
+		p.targets = append(p.targets, &probeTarget{ProbeTargetStatus: ProbeTargetStatus{Url: u, History: []ProbeResult{}}})
+	}
+	return p
+}
+
+// probe requests the url once and returns the result
+func (p *Prober) probe(ctx context.Context, url string) ProbeResult {
+	result := ProbeResult{Time: p.now().UTC()}
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err == nil {
+		req.Header.Set("User-Agent", "go-cloud-k8s-info-prober")
+		var resp *http.Response
+		if resp, err = p.client.Do(req); err == nil {
+			result.Status = resp.StatusCode
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}
+	result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// record adds the result to the history of target, it must be called with the lock held
+func (p *Prober) record(target *probeTarget, result ProbeResult) {
+	up := result.Ok()
+	if target.polled && up != target.Up {
+		changed := result.Time
+		target.LastChange = &changed
+		if up {
+			p.logger.Info("probe target is up", "url", target.Url, "status", result.Status)
+		} else {
+			p.logger.Warn("probe target is down", "url", target.Url, "status", result.Status, "error", result.Error)
+		}
+	}
+	target.polled, target.Up, target.LastStatus = true, up, result.Status
+	target.Checks++
+	if !up {
+		target.Failures++
+		target.LastError = result.Error
+	}
+	target.History = append(target.History, result)
+	if len(target.History) > p.maxHistory {
+		target.History = target.History[len(target.History)-p.maxHistory:]
+	}
+	successes, total := 0, 0.0
+	target.MaxLatencyMs = 0
+	for _, r := range target.History {
+		if r.Ok() {
+			successes++
+			total += r.LatencyMs
+			target.MaxLatencyMs = max(target.MaxLatencyMs, r.LatencyMs)
+		}
+	}
+	target.SuccessRatio = float64(successes) / float64(len(target.History))
+	target.AvgLatencyMs = 0
+	if successes > 0 {
+		target.AvgLatencyMs = total / float64(successes)
+	}
+}
+
+// Poll requests all the targets at the same time and records their results
+func (p *Prober) Poll(ctx context.Context) {
+	results := make([]ProbeResult, len(p.targets))
+	var wg sync.WaitGroup
+	for i, target := range p.targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = p.probe(ctx, target.Url)
+		}()
+	}
+	wg.Wait()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rounds++
+	for i, target := range p.targets {
+		p.record(target, results[i])
+	}
+}
+
+// Status returns the state of the targets with their history
+func (p *Prober) Status() ProbeStatusReport {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	report := ProbeStatusReport{IntervalSeconds: p.interval.Seconds(), Rounds: p.rounds, Targets: []ProbeTargetStatus{}}
+	for _, target := range p.targets {
+		status := target.ProbeTargetStatus
+		status.History = slices.Clone(target.History)
+		report.Targets = append(report.Targets, status)
+		if !target.polled {
+			continue
+		}
+		if target.Up {
+			report.Up++
+		} else {
+			report.Down++
+		}
+	}
+	return report
+}
+
+// Run polls the targets every interval until ctx is done
+func (p *Prober) Run(ctx context.Context, interval time.Duration) {
+	p.mu.Lock()
+	p.interval = interval
+	p.mu.Unlock()
+	p.logger.Info("Probing the targets", "targets", len(p.targets), "interval", interval.String())
+	p.Poll(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Poll(ctx)
+		}
+	}
+}
+
+//############# BEGIN INFO HANDLERS
+
+// ProbeStatusHandler returns the status and latency history of the targets of PROBE_TARGETS
+func (s *GoHttpServer) ProbeStatusHandler(prober *Prober) http.HandlerFunc {
+	handlerName := "ProbeStatusHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, r, http.StatusOK, prober.Status())
+	}
+}
+
+// ProbeNowHandler polls the targets of PROBE_TARGETS right away, without waiting for the next interval
+func (s *GoHttpServer) ProbeNowHandler(prober *Prober) http.HandlerFunc {
+	handlerName := "ProbeNowHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		prober.Poll(r.Context())
+		s.render(w, r, http.StatusOK, prober.Status())
+	}
+}
+
+// ############# END INFO HANDLERS
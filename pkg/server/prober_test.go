@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProber(t *testing.T) {
+	var failing atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/redirect":
+			w.WriteHeader(http.StatusNotModified)
+		case failing.Load():
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	prober := NewProber([]string{ts.URL + "/api", ts.URL + "/redirect", closed.URL}, time.Second, getTestLogger())
+	prober.maxHistory = 3
+	before := prober.Status()
+	assert.Equal(t, 0, before.Up+before.Down, "the targets should not be counted before the first poll")
+
+	prober.Poll(context.Background())
+	failing.Store(true)
+	prober.Poll(context.Background())
+	prober.Poll(context.Background())
+	failing.Store(false)
+	prober.Poll(context.Background())
+	report := prober.Status()
+	assert.Equal(t, 4, report.Rounds)
+	assert.Equal(t, 2, report.Up)
+	assert.Equal(t, 1, report.Down)
+	if !assert.Len(t, report.Targets, 3) {
+		return
+	}
+	tests := []struct {
+		name         string
+		target       ProbeTargetStatus
+		wantUp       bool
+		wantFailures int
+		wantRatio    float64
+		wantChange   bool
+		wantError    bool
+	}{
+		{name: "1: a target back up should keep its failures", target: report.Targets[0], wantUp: true, wantFailures: 2, wantRatio: 1.0 / 3, wantChange: true},
+		{name: "2: a 304 should be up", target: report.Targets[1], wantUp: true, wantRatio: 1},
+		{name: "3: a closed port should be down with its error", target: report.Targets[2], wantFailures: 4, wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantUp, tt.target.Up)
+			assert.Equal(t, 4, tt.target.Checks)
+			assert.Equal(t, tt.wantFailures, tt.target.Failures)
+			assert.Len(t, tt.target.History, 3, "the history should be truncated to maxHistory")
+			assert.Equal(t, tt.wantRatio, tt.target.SuccessRatio)
+			assert.Equal(t, tt.wantChange, tt.target.LastChange != nil)
+			assert.Equal(t, tt.wantError, tt.target.LastError != "")
+			if tt.wantUp {
+				assert.Greater(t, tt.target.AvgLatencyMs, 0.0)
+				assert.GreaterOrEqual(t, tt.target.MaxLatencyMs, tt.target.AvgLatencyMs)
+			}
+		})
+	}
+}
+
+func TestGoHttpServerProbeRoutes(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	settings := config.DefaultConfig()
+	settings.ProbeTargets = target.URL
+	myServer := NewGoHttpServerWithConfig(config.DefaultListenIp+":0", settings, getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantRounds int
+	}{
+		{name: "1: /probe/status should list the targets before the first poll", method: http.MethodGet, path: "/probe/status", wantStatus: http.StatusOK},
+		{name: "2: POST /probe should poll right away", method: http.MethodPost, path: "/probe", wantStatus: http.StatusOK, wantRounds: 1},
+		{name: "3: GET /probe should not be allowed", method: http.MethodGet, path: "/probe", wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, ts.URL+tt.path, nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatus, resp.StatusCode, assertCorrectStatusCodeExpected)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var report ProbeStatusReport
+			if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+				t.Fatalf("the output should be a valid json : %v", err)
+			}
+			assert.Equal(t, tt.wantRounds, report.Rounds)
+			if assert.Len(t, report.Targets, 1) {
+				assert.Equal(t, target.URL, report.Targets[0].Url)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	probeStateUp   = "up"
+	probeStateDown = "down"
+)
+
+// ProbeToggleReport is the answer of the /admin/health and /admin/readiness toggles
+type ProbeToggleReport struct {
+	Probe string `json:"probe"` // health or readiness
+	State string `json:"state"` // up or down
+}
+
+// ProbeToggle is a check failing while it is switched down by an administrator, to see how k8s reacts
+// to a failing probe without breaking the server. it is added to the checks of its runner on the first switch,
+// so the probe reports are unchanged as long as nobody uses it
+type ProbeToggle struct {
+	probe    string
+	runner   *ReadinessRunner
+	register sync.Once
+	down     int32 // set to 1 while the probe is forced down, accessed atomically
+}
+
+// NewProbeToggle is a constructor for a ProbeToggle of probe run by runner, which starts up
+func NewProbeToggle(probe string, runner *ReadinessRunner) *ProbeToggle {
+	return &ProbeToggle{probe: probe, runner: runner}
+}
+
+func (c *ProbeToggle) Name() string { return "admin_toggle" }
+func (c *ProbeToggle) Type() string { return "admin" }
+
+func (c *ProbeToggle) Check(_ context.Context) error {
+	if c.Down() {
+		return fmt.Errorf("forced down by POST /admin/%s?state=down", c.probe)
+	}
+	return nil
+}
+
+// SetDown forces the check to fail when down is true, and lets it succeed otherwise
+func (c *ProbeToggle) SetDown(down bool) {
+	c.register.Do(func() { c.runner.Register(c) })
+	var val int32
+	if down {
+		val = 1
+	}
+	atomic.StoreInt32(&c.down, val)
+}
+
+// Down returns true while the check is forced to fail
+func (c *ProbeToggle) Down() bool {
+	return atomic.LoadInt32(&c.down) == 1
+}
+
+// Report returns the current state of the toggle
+func (c *ProbeToggle) Report() ProbeToggleReport {
+	report := ProbeToggleReport{Probe: c.probe, State: probeStateUp}
+	if c.Down() {
+		report.State = probeStateDown
+	}
+	return report
+}
+
+//############# BEGIN ADMIN HANDLERS
+
+// ProbeToggleHandler switches the probe of toggle down or up with the state parameter, /health or /readiness
+// answer 503 as long as it is down
+func (s *GoHttpServer) ProbeToggleHandler(toggle *ProbeToggle) http.HandlerFunc {
+	handlerName := "ProbeToggleHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		state := r.URL.Query().Get("state")
+		if state != probeStateUp && state != probeStateDown {
+			http.Error(w, "ERROR: parameter state should be up or down", http.StatusBadRequest)
+			return
+		}
+		toggle.SetDown(state == probeStateDown)
+		s.audit("probe switched "+state, r, "probe", toggle.probe)
+		if state == probeStateDown {
+			s.lifecycleEvent(k8sEventTypeWarning, "ProbeSwitchedDown", fmt.Sprintf("the %s probe was switched down by /admin/%s, it fails until switched up", toggle.probe, toggle.probe))
+		} else {
+			s.lifecycleEvent(k8sEventTypeNormal, "ProbeSwitchedUp", fmt.Sprintf("the %s probe was switched up by /admin/%s", toggle.probe, toggle.probe))
+		}
+		s.render(w, r, http.StatusOK, toggle.Report())
+	}
+}
+
+// ############# END ADMIN HANDLERS
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoHttpServerProbeToggleHandler(t *testing.T) {
+	t.Setenv("API_TOKEN", "s3cret")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		wantStatusCode int
+		wantState      string
+	}{
+		{name: "1: /health should start alive", method: http.MethodGet, url: "/health", wantStatusCode: http.StatusOK},
+		{name: "2: POST /admin/health down should switch it", method: http.MethodPost, url: "/admin/health?state=down", wantStatusCode: http.StatusOK, wantState: probeStateDown},
+		{name: "3: /health should fail while down", method: http.MethodGet, url: "/health", wantStatusCode: http.StatusServiceUnavailable},
+		{name: "4: /readiness should not be affected by /admin/health", method: http.MethodGet, url: "/readiness", wantStatusCode: http.StatusOK},
+		{name: "5: POST /admin/health up should restore it", method: http.MethodPost, url: "/admin/health?state=up", wantStatusCode: http.StatusOK, wantState: probeStateUp},
+		{name: "6: /health should be alive again", method: http.MethodGet, url: "/health", wantStatusCode: http.StatusOK},
+		{name: "7: POST /admin/readiness down should switch it", method: http.MethodPost, url: "/admin/readiness?state=down", wantStatusCode: http.StatusOK, wantState: probeStateDown},
+		{name: "8: /readiness should fail while down", method: http.MethodGet, url: "/readiness", wantStatusCode: http.StatusServiceUnavailable},
+		{name: "9: unknown state should be a bad request", method: http.MethodPost, url: "/admin/readiness?state=sideways", wantStatusCode: http.StatusBadRequest},
+		{name: "10: GET should not be allowed", method: http.MethodGet, url: "/admin/readiness?state=up", wantStatusCode: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer s3cret")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode, assertCorrectStatusCodeExpected)
+			if tt.wantState != "" {
+				var report ProbeToggleReport
+				assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report), "the output should be a valid json")
+				assert.Equal(t, tt.wantState, report.State)
+			}
+		})
+	}
+}
+
+func TestGoHttpServerProbeToggleCredentials(t *testing.T) {
+	tests := []struct {
+		name           string
+		env            map[string]string
+		auth           *AuthConfig
+		authorization  string
+		adminPort      bool
+		wantStatusCode int
+	}{
+		{name: "1: an anonymous POST should be forbidden with the default config", wantStatusCode: http.StatusForbidden},
+		{name: "2: an anonymous POST should be unauthorized with API_TOKEN", env: map[string]string{"API_TOKEN": "s3cret"}, wantStatusCode: http.StatusUnauthorized},
+		{name: "3: a wrong bearer should be unauthorized with API_TOKEN", env: map[string]string{"API_TOKEN": "s3cret"}, authorization: "Bearer guess", wantStatusCode: http.StatusUnauthorized},
+		{name: "4: the API_TOKEN bearer should be accepted", env: map[string]string{"API_TOKEN": "s3cret"}, authorization: "Bearer s3cret", wantStatusCode: http.StatusOK},
+		{name: "5: the credentials of AUTH_MODE should be accepted", auth: &AuthConfig{Mode: authModeBearer, Token: "t0ken"}, authorization: "Bearer t0ken", wantStatusCode: http.StatusOK},
+		{name: "6: an anonymous POST should be unauthorized with AUTH_MODE", auth: &AuthConfig{Mode: authModeBearer, Token: "t0ken"}, wantStatusCode: http.StatusUnauthorized},
+		{name: "7: an anonymous POST on ADMIN_PORT should be forbidden", env: map[string]string{"ADMIN_PORT": "8081"}, adminPort: true, wantStatusCode: http.StatusForbidden},
+		{name: "8: the API_TOKEN bearer should be accepted on ADMIN_PORT", env: map[string]string{"ADMIN_PORT": "8081", "API_TOKEN": "s3cret"}, authorization: "Bearer s3cret", adminPort: true, wantStatusCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, val := range tt.env {
+				t.Setenv(name, val)
+			}
+			myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+			if tt.auth != nil {
+				myServer.UseAuth(*tt.auth)
+			}
+			var handler http.Handler = myServer.router
+			if tt.adminPort {
+				handler = myServer.adminServer.Handler
+			}
+			for _, path := range []string{"/admin/readiness?state=down", "/admin/health?state=down"} {
+				req := httptest.NewRequest(http.MethodPost, path, nil)
+				if tt.authorization != "" {
+					req.Header.Set("Authorization", tt.authorization)
+				}
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				assert.Equal(t, tt.wantStatusCode, rec.Code, "POST %s", path)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readiness", nil))
+			if tt.wantStatusCode == http.StatusOK {
+				assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "/readiness should follow the toggle")
+			} else {
+				assert.Equal(t, http.StatusOK, rec.Code, "a refused POST should not pull the pod out of its Service")
+			}
+		})
+	}
+}
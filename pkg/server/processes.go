@@ -0,0 +1,177 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultProcDir    = "/proc"
+	maxProcessCmdline = 4096 // longer command lines are truncated
+)
+
+// processStateNames are the states of the third field of /proc/[pid]/stat
+var processStateNames = map[string]string{
+	"R": "running", "S": "sleeping", "D": "disk sleep", "Z": "zombie", "T": "stopped", "t": "tracing stop",
+	"X": "dead", "I": "idle", "P": "parked", "W": "paging",
+}
+
+// ProcessInfo is a process visible in the pid namespace of the container
+type ProcessInfo struct {
+	Pid        int       `json:"pid"`
+	PPid       int       `json:"ppid"`
+	Command    string    `json:"command"`           // name of the executable, truncated to 15 characters by the kernel
+	Cmdline    string    `json:"cmdline,omitempty"` // empty for the zombies and the kernel threads
+	State      string    `json:"state"`
+	Threads    int       `json:"threads"`
+	RssBytes   int64     `json:"rss_bytes"`
+	CpuSeconds float64   `json:"cpu_seconds"` // user + system time
+	StartTime  time.Time `json:"start_time"`
+	Self       bool      `json:"self,omitempty"` // this server
+}
+
+// ProcessList is the list of the processes of /info/processes
+type ProcessList struct {
+	Count           int           `json:"count"`
+	Zombies         int           `json:"zombies"`
+	SharedNamespace bool          `json:"shared_process_namespace"` // pid 1 is the pause container, the processes of the sidecars are visible
+	Processes       []ProcessInfo `json:"processes"`                // sorted by pid
+	Warnings        []string      `json:"warnings,omitempty"`
+	Errors          []string      `json:"errors,omitempty"` // problems met while collecting the information
+}
+
+// readBootTime returns the boot time of the node, the btime line of the /proc/stat file at path
+func readBootTime(path string) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if val, found := strings.CutPrefix(scanner.Text(), "btime "); found {
+			seconds, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(seconds, 0).UTC(), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, fmt.Errorf("no btime in %s", path)
+}
+
+// readProcessStat returns the process described by the stat file of the /proc/[pid] directory at dir,
+// the start time is given in clock ticks after bootTime
+func readProcessStat(dir string, bootTime time.Time) (ProcessInfo, error) {
+	content, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return ProcessInfo{}, err
+	}
+	// the command name in field 2 may contain spaces and parentheses, it ends at the last closing one
+	stat := string(content)
+	open, closing := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+	if open < 0 || closing < open {
+		return ProcessInfo{}, fmt.Errorf("unexpected content in %s/stat", dir)
+	}
+	fields := strings.Fields(stat[closing+1:])
+	if len(fields) < 22 {
+		return ProcessInfo{}, fmt.Errorf("unexpected content in %s/stat", dir)
+	}
+	p := ProcessInfo{Command: stat[open+1 : closing], State: fields[0]}
+	if name, exist := processStateNames[fields[0]]; exist {
+		p.State = name
+	}
+	var numbers [22]int64
+	for _, i := range []int{1, 11, 12, 17, 19, 21} {
+		if numbers[i], err = strconv.ParseInt(fields[i], 10, 64); err != nil {
+			return ProcessInfo{}, fmt.Errorf("unexpected content in %s/stat: %w", dir, err)
+		}
+	}
+	p.Pid, _ = strconv.Atoi(strings.TrimSpace(stat[:open]))
+	p.PPid, p.Threads = int(numbers[1]), int(numbers[17])
+	p.CpuSeconds = float64(numbers[11]+numbers[12]) / procClockTicks
+	p.StartTime = bootTime.Add(time.Duration(numbers[19]) * time.Second / procClockTicks)
+	p.RssBytes = numbers[21] * int64(os.Getpagesize())
+	return p, nil
+}
+
+// readProcessCmdline returns the arguments of the /proc/[pid] directory at dir separated by spaces
+func readProcessCmdline(dir string) string {
+	content, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+	if err != nil {
+		return ""
+	}
+	if len(content) > maxProcessCmdline {
+		content = content[:maxProcessCmdline]
+	}
+	return strings.TrimSpace(strings.ReplaceAll(string(content), "\x00", " "))
+}
+
+// GetProcessList returns the processes found in procDir, the mount point of the proc filesystem. without
+// shareProcessNamespace in the pod spec only the processes of this container are visible
+func GetProcessList(procDir string) ProcessList {
+	list := ProcessList{Processes: []ProcessInfo{}}
+	bootTime, err := readBootTime(filepath.Join(procDir, "stat"))
+	if err != nil {
+		list.Errors = append(list.Errors, "boot time: "+err.Error())
+	}
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		list.Errors = append(list.Errors, "list processes: "+err.Error())
+		return list
+	}
+	self := os.Getpid()
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil || !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(procDir, entry.Name())
+		p, err := readProcessStat(dir, bootTime)
+		if err != nil {
+			// the process may have exited since the directory was listed
+			if !os.IsNotExist(err) {
+				list.Errors = append(list.Errors, err.Error())
+			}
+			continue
+		}
+		p.Cmdline = readProcessCmdline(dir)
+		p.Self = p.Pid == self
+		if p.State == "zombie" {
+			list.Zombies++
+		}
+		list.Processes = append(list.Processes, p)
+	}
+	slices.SortFunc(list.Processes, func(a, b ProcessInfo) int { return a.Pid - b.Pid })
+	list.Count = len(list.Processes)
+	if len(list.Processes) > 0 && list.Processes[0].Pid == 1 && list.Processes[0].Command == "pause" {
+		list.SharedNamespace = true
+	}
+	if list.Zombies > 0 {
+		list.Warnings = append(list.Warnings, fmt.Sprintf("%d zombie processes were not reaped by their parent, "+
+			"a pid 1 which is not an init like tini or the pause container of shareProcessNamespace does not reap the orphans", list.Zombies))
+	}
+	return list
+}
+
+//############# BEGIN INFO HANDLERS
+
+// ProcessesHandler returns the processes visible in the pod, the sidecars too with shareProcessNamespace
+func (s *GoHttpServer) ProcessesHandler(procDir string) http.HandlerFunc {
+	handlerName := "ProcessesHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, r, http.StatusOK, GetProcessList(procDir))
+	}
+}
+
+// ############# END INFO HANDLERS
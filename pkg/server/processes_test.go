@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestProcDir returns a proc directory with the pause container, a server, a sidecar and a zombie
+func writeTestProcDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"stat":      "cpu  10 0 10 100 0 0 0 0 0 0\nbtime 1709640000\nprocesses 42\n",
+		"1/stat":    "1 (pause) S 0 1 1 0 -1 4194560 100 0 0 0 2 3 0 0 20 0 1 0 500 1024000 10 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n",
+		"1/cmdline": "/pause\x00",
+		"7/stat":    "7 (go info) S 0 7 7 0 -1 4194560 100 0 0 0 150 50 0 0 20 0 8 0 1000 1024000 2560 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n",
+		"7/cmdline": "/goInfoServer\x00-port\x008080\x00",
+		"12/stat":   "12 (envoy) S 0 12 12 0 -1 4194560 100 0 0 0 10 10 0 0 20 0 4 0 1200 1024000 5120 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n",
+		"30/stat":   "30 (sh) Z 7 30 7 0 -1 4194560 100 0 0 0 0 0 0 0 20 0 1 0 3000 0 0 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n",
+		"self/stat": "not a process",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestGetProcessList(t *testing.T) {
+	list := GetProcessList(writeTestProcDir(t))
+	assert.Empty(t, list.Errors)
+	if !assert.Len(t, list.Processes, 4, "only the pid directories should be listed") {
+		return
+	}
+	bootTime := time.Unix(1709640000, 0).UTC()
+	pageSize := int64(os.Getpagesize())
+	tests := []struct {
+		name        string
+		process     ProcessInfo
+		wantPid     int
+		wantCommand string
+		wantCmdline string
+		wantState   string
+		wantRss     int64
+		wantCpu     float64
+		wantStart   time.Time
+	}{
+		{name: "1: the pause container should be pid 1", process: list.Processes[0], wantPid: 1, wantCommand: "pause", wantCmdline: "/pause",
+			wantState: "sleeping", wantRss: 10 * pageSize, wantCpu: 0.05, wantStart: bootTime.Add(5 * time.Second)},
+		{name: "2: a command with a space should be read", process: list.Processes[1], wantPid: 7, wantCommand: "go info", wantCmdline: "/goInfoServer -port 8080",
+			wantState: "sleeping", wantRss: 2560 * pageSize, wantCpu: 2, wantStart: bootTime.Add(10 * time.Second)},
+		{name: "3: a sidecar should be listed", process: list.Processes[2], wantPid: 12, wantCommand: "envoy",
+			wantState: "sleeping", wantRss: 5120 * pageSize, wantCpu: 0.2, wantStart: bootTime.Add(12 * time.Second)},
+		{name: "4: a zombie should be listed without cmdline", process: list.Processes[3], wantPid: 30, wantCommand: "sh",
+			wantState: "zombie", wantStart: bootTime.Add(30 * time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantPid, tt.process.Pid)
+			assert.Equal(t, tt.wantCommand, tt.process.Command)
+			assert.Equal(t, tt.wantCmdline, tt.process.Cmdline)
+			assert.Equal(t, tt.wantState, tt.process.State)
+			assert.Equal(t, tt.wantRss, tt.process.RssBytes)
+			assert.Equal(t, tt.wantCpu, tt.process.CpuSeconds)
+			assert.Equal(t, tt.wantStart, tt.process.StartTime)
+		})
+	}
+	assert.Equal(t, 7, list.Processes[3].PPid)
+	assert.Equal(t, 8, list.Processes[1].Threads)
+	assert.True(t, list.SharedNamespace, "pid 1 pause should reveal shareProcessNamespace")
+	assert.Equal(t, 1, list.Zombies)
+	assert.Len(t, list.Warnings, 1)
+}
+
+func TestGoHttpServerProcessesHandler(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.ProcessesHandler(defaultProcDir))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var list ProcessList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("the output should be a valid json : %v", err)
+	}
+	if _, err := os.Stat(defaultProcDir + "/self/stat"); err != nil {
+		t.Skip("no proc filesystem")
+	}
+	self := false
+	for _, p := range list.Processes {
+		self = self || (p.Self && p.Pid == os.Getpid())
+	}
+	assert.True(t, self, "the test process should be listed")
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+const (
+	defaultProxyPreviewBytes = 4 << 10
+	maxProxyPreviewBytes     = 64 << 10
+	maxProxyBodyBytes        = 10 << 20 // the body is read up to this size to measure it, the rest is not downloaded
+)
+
+// ProxyTiming splits the duration of a request made by /proxy, in milliseconds since its start
+type ProxyTiming struct {
+	DnsMs       float64 `json:"dns_ms"`
+	ConnectMs   float64 `json:"connect_ms"`
+	TlsMs       float64 `json:"tls_ms,omitempty"`
+	FirstByteMs float64 `json:"first_byte_ms"`
+	TotalMs     float64 `json:"total_ms"` // until the end of the body, or of the part read
+}
+
+// ProxyReport is the result of a request made by /proxy from inside the pod
+type ProxyReport struct {
+	Url           string              `json:"url"`
+	ResolvedIp    string              `json:"resolved_ip,omitempty"`
+	Success       bool                `json:"success"` // true when a response was received, whatever its status
+	StatusCode    int                 `json:"status_code,omitempty"`
+	Status        string              `json:"status,omitempty"`
+	Proto         string              `json:"proto,omitempty"`
+	Headers       map[string][]string `json:"headers,omitempty"`
+	Timing        ProxyTiming         `json:"timing"`
+	BodyBytes     int64               `json:"body_bytes"`               // size of the body read, at most 10 MiB
+	BodyTooLarge  bool                `json:"body_too_large,omitempty"` // true when the body was longer than what was read
+	BodyPreview   string              `json:"body_preview"`             // utf8 start of the body, or base64 when BodyEncoding is base64
+	BodyEncoding  string              `json:"body_encoding"`            // text or base64
+	BodyTruncated bool                `json:"body_truncated"`           // true when the body is longer than the preview
+	Tls           *TlsConnInfo        `json:"tls,omitempty"`
+	Error         string              `json:"error,omitempty"`
+}
+
+// bodyPreview returns the start of body as text, or in base64 when it is not utf8. a rune cut by the truncation is
+// dropped so that a text body stays text
+func bodyPreview(body []byte, truncated bool) (string, string) {
+	if truncated {
+		for i := 0; i < utf8.UTFMax-1 && len(body) > 0 && !utf8.Valid(body); i++ {
+			body = body[:len(body)-1]
+		}
+	}
+	if utf8.Valid(body) {
+		return string(body), "text"
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// Fetch sends a GET to target given as an http or https url and reports the response with the first previewBytes of
+// its body, redirects are not followed
+func (c *Connector) Fetch(ctx context.Context, target string, previewBytes int) (ProxyReport, error) {
+	report := ProxyReport{Url: target, BodyEncoding: "text"}
+	u, err := url.Parse(target)
+	if err != nil {
+		return report, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return report, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	start := time.Now()
+	sinceStart := func() float64 { return float64(time.Since(start).Microseconds()) / 1000 }
+	ip, err := c.resolve(ctx, u.Hostname())
+	if errors.Is(err, errConnectNotAllowed) {
+		return report, err
+	}
+	report.Timing.DnsMs = sinceStart()
+	if err != nil {
+		report.Error = err.Error()
+		return report, nil
+	}
+	report.ResolvedIp = ip.String()
+	trace := &httptrace.ClientTrace{
+		ConnectDone:          func(_, _ string, _ error) { report.Timing.ConnectMs = sinceStart() },
+		TLSHandshakeDone:     func(_ tls.ConnectionState, _ error) { report.Timing.TlsMs = sinceStart() },
+		GotFirstResponseByte: func() { report.Timing.FirstByteMs = sinceStart() },
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, target, nil)
+	if err != nil {
+		return report, err
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("%s/%s", info.APP, info.VERSION))
+	InjectTraceContext(ctx, req)
+	InjectRequestId(ctx, req)
+	resp, err := pinnedHttpClient(report.ResolvedIp).Do(req)
+	if err != nil {
+		report.Timing.TotalMs = sinceStart()
+		report.Error = err.Error()
+		return report, nil
+	}
+	defer resp.Body.Close()
+	report.Success = true
+	report.StatusCode = resp.StatusCode
+	report.Status = resp.Status
+	report.Proto = resp.Proto
+	report.Headers = resp.Header
+	if resp.TLS != nil {
+		report.Tls = NewTlsConnInfo(resp.TLS)
+	}
+	preview := make([]byte, previewBytes+1)
+	n, err := io.ReadFull(resp.Body, preview)
+	if err == nil || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		var rest int64
+		rest, err = io.Copy(io.Discard, io.LimitReader(resp.Body, maxProxyBodyBytes-int64(n)+1))
+		report.BodyBytes = int64(n) + rest
+		if report.BodyBytes > maxProxyBodyBytes {
+			report.BodyBytes = maxProxyBodyBytes
+			report.BodyTooLarge = true
+		}
+	}
+	report.Timing.TotalMs = sinceStart()
+	if err != nil {
+		report.Error = "reading the body: " + err.Error()
+	}
+	report.BodyTruncated = n > previewBytes
+	report.BodyPreview, report.BodyEncoding = bodyPreview(preview[:min(n, previewBytes)], report.BodyTruncated)
+	return report, nil
+}
+
+//############# BEGIN PROXY HANDLERS
+
+// ProxyHandler GETs the url parameter from inside the pod and returns the status, headers, timing and the start of
+// the body, to verify the NetworkPolicies and the reachability of the Services. preview is the number of body bytes
+// returned and timeout a duration like 2s
+func (s *GoHttpServer) ProxyHandler(connector *Connector) http.HandlerFunc {
+	handlerName := "ProxyHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("url") == "" {
+			http.Error(w, "ERROR: an url parameter is required", http.StatusBadRequest)
+			return
+		}
+		previewBytes, err := parseIntParam(r, "preview", defaultProxyPreviewBytes, 0, maxProxyPreviewBytes)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		timeout, err := parseTimeoutParam(r)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		report, err := connector.Fetch(ctx, query.Get("url"), previewBytes)
+		if errors.Is(err, errConnectNotAllowed) {
+			s.audit("proxy denied, target not allowed", r, "url", report.Url)
+			http.Error(w, "ERROR: "+err.Error(), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			report.Error = err.Error()
+			s.render(w, r, http.StatusBadRequest, report)
+			return
+		}
+		s.render(w, r, http.StatusOK, report)
+	}
+}
+
+// ############# END PROXY HANDLERS
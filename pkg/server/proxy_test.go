@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyPreview(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         []byte
+		truncated    bool
+		wantPreview  string
+		wantEncoding string
+	}{
+		{name: "1: text should stay text", body: []byte("hello"), wantPreview: "hello", wantEncoding: "text"},
+		{name: "2: rune cut by the truncation should be dropped", body: []byte("caf\xc3"), truncated: true, wantPreview: "caf", wantEncoding: "text"},
+		{name: "3: binary should be base64", body: []byte{0xff, 0xfe, 0x00}, wantPreview: "//4A", wantEncoding: "base64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preview, encoding := bodyPreview(tt.body, tt.truncated)
+			assert.Equal(t, tt.wantPreview, preview)
+			assert.Equal(t, tt.wantEncoding, encoding)
+		})
+	}
+}
+
+func TestGoHttpServerProxyHandler(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Target", "yes")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	defer target.Close()
+	t.Setenv("CONNECT_ALLOWLIST", "127.0.0.1")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name           string
+		query          string
+		wantStatusCode int
+		wantPreview    string
+		wantTruncated  bool
+	}{
+		{name: "1: url should be fetched with its body", query: "url=" + target.URL, wantStatusCode: http.StatusOK, wantPreview: strings.Repeat("a", 100)},
+		{name: "2: preview should truncate the body", query: "preview=10&url=" + target.URL, wantStatusCode: http.StatusOK, wantPreview: "aaaaaaaaaa", wantTruncated: true},
+		{name: "3: url outside the allowlist should be forbidden", query: "url=http://10.1.2.3/", wantStatusCode: http.StatusForbidden},
+		{name: "4: missing url should be a bad request", query: "", wantStatusCode: http.StatusBadRequest},
+		{name: "5: unsupported scheme should be a bad request", query: "url=file:///etc/passwd", wantStatusCode: http.StatusBadRequest},
+		{name: "6: too big preview should be a bad request", query: "preview=1000000&url=" + target.URL, wantStatusCode: http.StatusBadRequest},
+		{name: "7: invalid timeout should be a bad request", query: "timeout=1h&url=" + target.URL, wantStatusCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + "/proxy?" + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode, assertCorrectStatusCodeExpected)
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+			var report ProxyReport
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report), "the output should be a valid json")
+			assert.True(t, report.Success, "error : %s", report.Error)
+			assert.Equal(t, http.StatusAccepted, report.StatusCode)
+			assert.Equal(t, []string{"yes"}, report.Headers["X-Target"])
+			assert.Equal(t, int64(100), report.BodyBytes)
+			assert.Equal(t, tt.wantPreview, report.BodyPreview)
+			assert.Equal(t, tt.wantTruncated, report.BodyTruncated)
+			assert.GreaterOrEqual(t, report.Timing.TotalMs, report.Timing.FirstByteMs)
+		})
+	}
+}
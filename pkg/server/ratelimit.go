@@ -0,0 +1,127 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRateLimitMaxClients = 10000 // number of client buckets kept, the least recently seen client is forgotten above
+)
+
+// rateLimitExemptPaths are the probes of the kubelet, which all come from the ip of the node, and the scrapes of
+// Prometheus, which would leave gaps in the metrics. they must never get a 429
+var rateLimitExemptPaths = []string{"/health", "/readiness", "/started", "/metrics"}
+
+// tokenBucket is the bucket of a client, chained from the most to the least recently seen client
+type tokenBucket struct {
+	client string
+	tokens float64
+	last   time.Time
+	newer  *tokenBucket
+	older  *tokenBucket
+}
+
+// RateLimiter is a token bucket per client : each client may send burst requests at once, then rps requests per second
+type RateLimiter struct {
+	rps        float64
+	burst      float64
+	maxClients int
+	now        func() time.Time // time.Now, replaced in tests
+	mu         sync.Mutex
+	buckets    map[string]*tokenBucket
+	newest     *tokenBucket
+	oldest     *tokenBucket
+}
+
+// NewRateLimiter is a constructor for a RateLimiter allowing rps requests per second with bursts of burst requests
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rps:        rps,
+		burst:      float64(burst),
+		maxClients: defaultRateLimitMaxClients,
+		now:        time.Now,
+		buckets:    make(map[string]*tokenBucket),
+	}
+}
+
+// refill adds the tokens earned since the last request of the bucket
+func (rl *RateLimiter) refill(b *tokenBucket, now time.Time) {
+	b.tokens = math.Min(rl.burst, b.tokens+now.Sub(b.last).Seconds()*rl.rps)
+	b.last = now
+}
+
+// unlink removes b from the chain of the buckets
+func (rl *RateLimiter) unlink(b *tokenBucket) {
+	if b.newer != nil {
+		b.newer.older = b.older
+	} else {
+		rl.newest = b.older
+	}
+	if b.older != nil {
+		b.older.newer = b.newer
+	} else {
+		rl.oldest = b.newer
+	}
+	b.newer, b.older = nil, nil
+}
+
+// pushNewest puts b at the head of the chain of the buckets
+func (rl *RateLimiter) pushNewest(b *tokenBucket) {
+	b.older = rl.newest
+	if rl.newest != nil {
+		rl.newest.newer = b
+	}
+	rl.newest = b
+	if rl.oldest == nil {
+		rl.oldest = b
+	}
+}
+
+// Allow takes a token from the bucket of client, when it is empty it returns false and the time until the next token.
+// above maxClients the bucket of the least recently seen client is forgotten, so the memory stays bounded whatever
+// the number of clients, a client idle for that long has usually a full bucket again anyway
+func (rl *RateLimiter) Allow(client string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := rl.now()
+	b, exist := rl.buckets[client]
+	if exist {
+		rl.unlink(b)
+	} else {
+		if len(rl.buckets) >= rl.maxClients {
+			oldest := rl.oldest
+			rl.unlink(oldest)
+			delete(rl.buckets, oldest.client)
+		}
+		b = &tokenBucket{client: client, tokens: rl.burst, last: now}
+		rl.buckets[client] = b
+	}
+	rl.pushNewest(b)
+	rl.refill(b, now)
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rl.rps * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// limitRate is the Middleware answering 429 with a Retry-After header to the clients sending too many requests
+func (s *GoHttpServer) limitRate() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := ParseRemoteAddr(r.RemoteAddr).RemoteIp
+			allowed, retryAfter := s.rateLimiter.Allow(client)
+			if !allowed {
+				s.logger.WarnContext(r.Context(), "rate limit exceeded", "path", r.URL.Path, "client_ip", client)
+				w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(retryAfter.Seconds()))))
+				http.Error(w, "ERROR: too many requests, retry later", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	rl := NewRateLimiter(2, 3)
+	rl.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		allowed, _ := rl.Allow("192.0.2.1")
+		assert.True(t, allowed, "request %d of the burst should be allowed", i+1)
+	}
+	allowed, retryAfter := rl.Allow("192.0.2.1")
+	assert.False(t, allowed, "the request after the burst should be refused")
+	assert.Equal(t, 500*time.Millisecond, retryAfter, "a token should come back after 1/rps seconds")
+	allowed, _ = rl.Allow("192.0.2.2")
+	assert.True(t, allowed, "each client should have its own bucket")
+
+	now = now.Add(500 * time.Millisecond)
+	allowed, _ = rl.Allow("192.0.2.1")
+	assert.True(t, allowed, "the bucket should be refilled at rps")
+	allowed, _ = rl.Allow("192.0.2.1")
+	assert.False(t, allowed)
+}
+
+func TestRateLimiterMaxClients(t *testing.T) {
+	now := time.Now()
+	rl := NewRateLimiter(1, 1)
+	rl.now = func() time.Time { return now }
+	rl.maxClients = 2
+	rl.Allow("192.0.2.1")
+	rl.Allow("192.0.2.2")
+	rl.Allow("192.0.2.1")
+	allowed, _ := rl.Allow("192.0.2.3")
+	assert.True(t, allowed, "a new client should be allowed when the buckets are not full")
+	assert.Len(t, rl.buckets, 2, "the number of buckets should never be above maxClients")
+	assert.Equal(t, "192.0.2.3", rl.newest.client)
+	assert.Equal(t, "192.0.2.1", rl.oldest.client)
+	assert.NotContains(t, rl.buckets, "192.0.2.2", "the least recently seen client should be forgotten")
+	allowed, _ = rl.Allow("192.0.2.1")
+	assert.False(t, allowed, "the bucket of a recent client should be kept")
+}
+
+func TestGoHttpServerRateLimit(t *testing.T) {
+	t.Setenv("RATE_LIMIT_RPS", "0.5")
+	t.Setenv("RATE_LIMIT_BURST", "2")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	var statuses []int
+	var retryAfter string
+	for i := 0; i < 3; i++ {
+		resp, err := http.Get(ts.URL + "/time")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		statuses = append(statuses, resp.StatusCode)
+		retryAfter = resp.Header.Get("Retry-After")
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, statuses)
+	assert.Equal(t, "2", retryAfter)
+
+	for _, path := range rateLimitExemptPaths {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		assert.NotEqual(t, http.StatusTooManyRequests, resp.StatusCode, "the probe %s should not be rate limited", path)
+	}
+	for i := 0; i < 3; i++ {
+		resp, err := http.Get(ts.URL + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "the scrapes of /metrics should not be rate limited")
+	}
+}
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+)
+
+const (
+	defaultReadinessCheckTimeout = 2 * time.Second // max time of one readiness check
+	readinessStatusReady         = "ready"
+	readinessStatusNotReady      = "not_ready"
+	readinessStatusDraining      = "draining"
+	checkStatusUp                = "up"
+	checkStatusDown              = "down"
+)
+
+// HealthChecker is one verification that must succeed for this server to be ready to receive traffic
+type HealthChecker interface {
+	Name() string
+	Type() string
+	Check(ctx context.Context) error
+}
+
+// TcpCheck succeeds when a tcp connection can be opened to Address (host:port)
+type TcpCheck struct {
+	CheckName string
+	Address   string
+}
+
+func (c *TcpCheck) Name() string { return c.CheckName }
+func (c *TcpCheck) Type() string { return "tcp" }
+
+func (c *TcpCheck) Check(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.Address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// HttpCheck succeeds when a GET on Url answers with a status code below 400
+type HttpCheck struct {
+	CheckName string
+	Url       string
+}
+
+func (c *HttpCheck) Name() string { return c.CheckName }
+func (c *HttpCheck) Type() string { return "http" }
+
+func (c *HttpCheck) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.Url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("http status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// DnsCheck succeeds when Host resolves to at least one address
+type DnsCheck struct {
+	CheckName string
+	Host      string
+	resolver  *net.Resolver
+}
+
+func (c *DnsCheck) Name() string { return c.CheckName }
+func (c *DnsCheck) Type() string { return "dns" }
+
+func (c *DnsCheck) Check(ctx context.Context) error {
+	resolver := c.resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupHost(ctx, c.Host)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("no address found for %s", c.Host)
+	}
+	return nil
+}
+
+// FileCheck succeeds when Path exists, typically a mounted secret or a file written by an init container
+type FileCheck struct {
+	CheckName string
+	Path      string
+}
+
+func (c *FileCheck) Name() string { return c.CheckName }
+func (c *FileCheck) Type() string { return "file" }
+
+func (c *FileCheck) Check(_ context.Context) error {
+	_, err := os.Stat(c.Path)
+	return err
+}
+
+// readinessCheckConfig is the json description of one check :
+//
+//	{"name": "db", "type": "tcp", "target": "postgres:5432"}
+type readinessCheckConfig struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`   // tcp, http, dns or file
+	Target string `json:"target"` // host:port, url, host name or path depending on type
+}
+
+// ParseReadinessChecks returns the checks described in a json array of {"name","type","target"} objects.
+// all invalid entries are reported together in the returned error.
+func ParseReadinessChecks(data []byte) ([]HealthChecker, error) {
+	var configs []readinessCheckConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, err
+	}
+	var checks []HealthChecker
+	var invalid []string
+	for i, c := range configs {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("%s-%d", c.Type, i)
+		}
+		if c.Target == "" {
+			invalid = append(invalid, fmt.Sprintf("%q (missing target)", name))
+			continue
+		}
+		switch c.Type {
+		case "tcp":
+			if _, _, err := net.SplitHostPort(c.Target); err != nil {
+				invalid = append(invalid, fmt.Sprintf("%q (tcp target needs host:port)", name))
+				continue
+			}
+			checks = append(checks, &TcpCheck{CheckName: name, Address: c.Target})
+		case "http", "https":
+			if !strings.HasPrefix(c.Target, "http://") && !strings.HasPrefix(c.Target, "https://") {
+				invalid = append(invalid, fmt.Sprintf("%q (http target should be an http(s) url)", name))
+				continue
+			}
+			checks = append(checks, &HttpCheck{CheckName: name, Url: c.Target})
+		case "dns":
+			checks = append(checks, &DnsCheck{CheckName: name, Host: c.Target})
+		case "file":
+			checks = append(checks, &FileCheck{CheckName: name, Path: c.Target})
+		default:
+			invalid = append(invalid, fmt.Sprintf("%q (type should be tcp, http, dns or file)", name))
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, errors.New(strings.Join(invalid, ", "))
+	}
+	return checks, nil
+}
+
+// GetReadinessChecksFromConfig returns the readiness checks given by the settings :
+//
+//	readiness_checks : json array like [{"name":"db","type":"tcp","target":"postgres:5432"}]
+//	readiness_checks_file : path of a file containing the same json array (used when readiness_checks is empty)
+//
+// when both are empty there is no check and the server is always ready
+func GetReadinessChecksFromConfig(settings config.Config) ([]HealthChecker, error) {
+	data := []byte(settings.ReadinessChecks)
+	if len(data) == 0 {
+		if settings.ReadinessFile == "" {
+			return nil, nil
+		}
+		var err error
+		data, err = os.ReadFile(settings.ReadinessFile)
+		if err != nil {
+			return nil, &config.ErrorConfig{Err: err, Msg: "ERROR: CONFIG readiness_checks_file (env READINESS_CHECKS_FILE) cannot be read"}
+		}
+	}
+	checks, err := ParseReadinessChecks(data)
+	if err != nil {
+		return nil, &config.ErrorConfig{Err: err, Msg: "ERROR: CONFIG readiness_checks (env READINESS_CHECKS) should be a json array of valid checks"}
+	}
+	return checks, nil
+}
+
+// CheckResult is the outcome of one HealthChecker
+type CheckResult struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// ReadinessReport is the outcome of all the registered checks
+type ReadinessReport struct {
+	Status       string             `json:"status"`
+	Checks       []CheckResult      `json:"checks"`
+	Dependencies []DependencyStatus `json:"dependencies,omitempty"` // of WAIT_FOR, while they are not all up
+}
+
+// ReadinessRunner runs all the registered HealthChecker concurrently, each one limited by timeout
+type ReadinessRunner struct {
+	mu       sync.RWMutex
+	checks   []HealthChecker
+	timeout  time.Duration
+	draining int32 // set to 1 when the server is shutting down, accessed atomically
+}
+
+// NewReadinessRunner is a constructor for a ReadinessRunner without any check
+func NewReadinessRunner(timeout time.Duration) *ReadinessRunner {
+	return &ReadinessRunner{timeout: timeout}
+}
+
+// Register adds checks to the runner
+func (rr *ReadinessRunner) Register(checks ...HealthChecker) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.checks = append(rr.checks, checks...)
+}
+
+// UseReadinessChecks adds checks to the ones run by /readiness
+func (s *GoHttpServer) UseReadinessChecks(checks ...HealthChecker) {
+	s.readiness.Register(checks...)
+}
+
+// StartDraining makes every following Run report the server as not ready, without running the checks
+func (rr *ReadinessRunner) StartDraining() {
+	atomic.StoreInt32(&rr.draining, 1)
+}
+
+// Draining returns true when the server is shutting down
+func (rr *ReadinessRunner) Draining() bool {
+	return atomic.LoadInt32(&rr.draining) == 1
+}
+
+// Run executes every check and returns a report, the status is ready only when all checks succeed
+func (rr *ReadinessRunner) Run(ctx context.Context) ReadinessReport {
+	if rr.Draining() {
+		return ReadinessReport{Status: readinessStatusDraining, Checks: []CheckResult{}}
+	}
+	rr.mu.RLock()
+	checks := make([]HealthChecker, len(rr.checks))
+	copy(checks, rr.checks)
+	rr.mu.RUnlock()
+
+	report := ReadinessReport{Status: readinessStatusReady, Checks: make([]CheckResult, len(checks))}
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c HealthChecker) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, rr.timeout)
+			defer cancel()
+			start := time.Now()
+			err := c.Check(checkCtx)
+			res := CheckResult{Name: c.Name(), Type: c.Type(), Status: checkStatusUp, DurationMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				res.Status = checkStatusDown
+				res.Error = err.Error()
+			}
+			report.Checks[i] = res
+		}(i, c)
+	}
+	wg.Wait()
+	for _, res := range report.Checks {
+		if res.Status != checkStatusUp {
+			report.Status = readinessStatusNotReady
+		}
+	}
+	return report
+}
+
+// failedChecks returns the names of the checks of report which are not up, with their error
+func failedChecks(report ReadinessReport) string {
+	var failed []string
+	for _, res := range report.Checks {
+		if res.Status != checkStatusUp {
+			failed = append(failed, fmt.Sprintf("%s (%s)", res.Name, res.Error))
+		}
+	}
+	return strings.Join(failed, ", ")
+}
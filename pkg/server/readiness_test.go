@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCheck is a HealthChecker returning a fixed error
+type fakeCheck struct {
+	name string
+	err  error
+}
+
+func (c *fakeCheck) Name() string                  { return c.name }
+func (c *fakeCheck) Type() string                  { return "fake" }
+func (c *fakeCheck) Check(_ context.Context) error { return c.err }
+
+func TestParseReadinessChecks(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		wantChecks  int
+		wantErr     bool
+		wantErrText []string
+	}{
+		{name: "1: empty array should return no check", config: `[]`, wantChecks: 0},
+		{name: "2: all types should be accepted", config: `[{"name":"db","type":"tcp","target":"postgres:5432"},
+			{"type":"http","target":"http://auth:8080/health"},{"type":"dns","target":"auth"},{"type":"file","target":"/tmp/ready"}]`, wantChecks: 4},
+		{name: "3: invalid json should be an error", config: `{"type":"tcp"`, wantErr: true},
+		{name: "4: every invalid entry should be reported at once", config: `[{"name":"db","type":"tcp","target":"postgres"},
+			{"name":"cache","type":"udp","target":"redis:6379"},{"name":"secret","type":"file"}]`, wantErr: true,
+			wantErrText: []string{"db", "cache", "secret"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks, err := ParseReadinessChecks([]byte(tt.config))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseReadinessChecks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, text := range tt.wantErrText {
+				assert.Contains(t, err.Error(), text, "error should list every invalid entry")
+			}
+			assert.Equal(t, tt.wantChecks, len(checks))
+		})
+	}
+}
+
+func TestGetReadinessChecksFromConfig(t *testing.T) {
+	checksFile := filepath.Join(t.TempDir(), "checks.json")
+	if err := os.WriteFile(checksFile, []byte(`[{"name":"db","type":"tcp","target":"postgres:5432"},{"name":"cfg","type":"file","target":"/etc/app"}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		checks     string
+		file       string
+		wantChecks int
+		wantErr    bool
+	}{
+		{name: "1: no setting should give no check"},
+		{name: "2: readiness_checks should be parsed", checks: `[{"name":"dns","type":"dns","target":"kubernetes.default"}]`, file: checksFile, wantChecks: 1},
+		{name: "3: readiness_checks_file should be read when readiness_checks is empty", file: checksFile, wantChecks: 2},
+		{name: "4: a missing readiness_checks_file should be an error", file: filepath.Join(t.TempDir(), "missing.json"), wantErr: true},
+		{name: "5: invalid checks should be an error", checks: `[{"name":"db","type":"ftp","target":"files:21"}]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.DefaultConfig()
+			settings.ReadinessChecks, settings.ReadinessFile = tt.checks, tt.file
+			checks, err := GetReadinessChecksFromConfig(settings)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetReadinessChecksFromConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Len(t, checks, tt.wantChecks)
+		})
+	}
+}
+
+func TestHealthCheckers(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	existingFile := filepath.Join(t.TempDir(), "ready")
+	os.WriteFile(existingFile, []byte("ok"), 0600)
+
+	tests := []struct {
+		name    string
+		check   HealthChecker
+		wantErr bool
+	}{
+		{name: "1: tcp check on a listening address should succeed", check: &TcpCheck{Address: healthy.Listener.Addr().String()}},
+		{name: "2: tcp check on a closed port should fail", check: &TcpCheck{Address: freeAddress(t)}, wantErr: true},
+		{name: "3: http check on a healthy url should succeed", check: &HttpCheck{Url: healthy.URL}},
+		{name: "4: http check answering 500 should fail", check: &HttpCheck{Url: failing.URL}, wantErr: true},
+		{name: "5: dns check on localhost should succeed", check: &DnsCheck{Host: "localhost"}},
+		{name: "6: file check on an existing file should succeed", check: &FileCheck{Path: existingFile}},
+		{name: "7: file check on a missing file should fail", check: &FileCheck{Path: existingFile + ".missing"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			err := tt.check.Check(ctx)
+			assert.Equal(t, tt.wantErr, err != nil, "unexpected error : %v", err)
+		})
+	}
+}
+
+func TestGoHttpServerReadinessChecks(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.ReadinessHandler())
+	defer ts.Close()
+
+	getReport := func() (int, ReadinessReport) {
+		resp, err := http.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("readiness request failed : %v", err)
+		}
+		defer resp.Body.Close()
+		var report ReadinessReport
+		if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+			t.Fatalf("readiness response is not json : %v", err)
+		}
+		return resp.StatusCode, report
+	}
+
+	status, report := getReport()
+	assert.Equal(t, http.StatusOK, status, "without checks the server should be ready")
+	assert.Equal(t, readinessStatusReady, report.Status)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen : %v", err)
+	}
+	defer l.Close()
+	myServer.readiness.Register(&TcpCheck{CheckName: "listener", Address: l.Addr().String()})
+	status, report = getReport()
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1, len(report.Checks))
+	assert.Equal(t, checkStatusUp, report.Checks[0].Status)
+
+	myServer.readiness.Register(&fakeCheck{name: "broken", err: errors.New("db is down")})
+	status, report = getReport()
+	assert.Equal(t, http.StatusServiceUnavailable, status, "one failing check should make the server not ready")
+	assert.Equal(t, readinessStatusNotReady, report.Status)
+	assert.Equal(t, checkStatusDown, report.Checks[1].Status)
+	assert.Equal(t, "db is down", report.Checks[1].Error)
+}
+
+func TestDrainAndShutdown(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen : %v", err)
+	}
+	go myServer.httpServer.Serve(l)
+	readinessUrl := "http://" + l.Addr().String() + "/readiness"
+
+	resp, err := http.Get(readinessUrl)
+	if err != nil {
+		t.Fatalf("readiness request failed : %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	done := make(chan struct{})
+	go func() {
+		drainAndShutdown(&myServer.httpServer, getTestLogger(), myServer.readiness, nil, syscall.SIGTERM, 500*time.Millisecond, 100*time.Millisecond)
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// during the pre-stop delay the server still answers, but is not ready anymore
+	resp, err = http.Get(readinessUrl)
+	if err != nil {
+		t.Fatalf("server should still serve during the pre-stop delay : %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("drainAndShutdown() did not return")
+	}
+	_, err = http.Get(readinessUrl)
+	assert.Error(t, err, "server should be stopped after the drain")
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultProxyHeaderTimeout = 5 * time.Second // max time to receive the PROXY protocol header
+
+// proxyV2Signature starts the binary header of the version 2 of the PROXY protocol
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+type proxyAddrContextKey struct{}
+
+// inNets returns true when ip is in one of the ranges
+func inNets(ip net.IP, nets []*net.IPNet) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveClientIp returns the ip of the client of r. when the request comes from a trusted proxy, it is the last
+// address of X-Forwarded-For that is not itself a trusted proxy, since the addresses on its left may be forged
+// by the client, or the X-Real-IP header when there is no X-Forwarded-For
+func ResolveClientIp(r *http.Request, trustedProxies []*net.IPNet) string {
+	remoteIp := ParseRemoteAddr(r.RemoteAddr).RemoteIp
+	ip := net.ParseIP(remoteIp)
+	if ip == nil || !inNets(ip, trustedProxies) {
+		return remoteIp
+	}
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		if realIp := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); realIp != nil {
+			return realIp.String()
+		}
+		return remoteIp
+	}
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		remoteIp = hop.String()
+		if !inNets(hop, trustedProxies) {
+			break
+		}
+	}
+	return remoteIp
+}
+
+// ProxyAddrFromContext returns the address of the proxy which forwarded the request, empty when it came directly
+func ProxyAddrFromContext(ctx context.Context) string {
+	addr, _ := ctx.Value(proxyAddrContextKey{}).(string)
+	return addr
+}
+
+// resolveRealIp is the Middleware replacing the RemoteAddr of the requests forwarded by a trusted proxy with the
+// address of the real client, the address of the proxy stays available with ProxyAddrFromContext
+func (s *GoHttpServer) resolveRealIp() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientIp := ResolveClientIp(r, s.trustedProxies)
+			if clientIp != "" && clientIp != ParseRemoteAddr(r.RemoteAddr).RemoteIp {
+				ctx := context.WithValue(r.Context(), proxyAddrContextKey{}, r.RemoteAddr)
+				r = r.WithContext(ctx)
+				// the port of the client is unknown, 0 tells it is not the one of the tcp connection
+				r.RemoteAddr = net.JoinHostPort(clientIp, "0")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// readProxyHeader reads the version 1 or 2 PROXY protocol header at the start of a connection and returns the address
+// of the client it contains, or nil when there is no header or the proxy connection is not relayed (UNKNOWN or LOCAL)
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	if start, err := r.Peek(len(proxyV2Signature)); err == nil && bytes.Equal(start, proxyV2Signature) {
+		return readProxyHeaderV2(r)
+	}
+	if start, err := r.Peek(6); err != nil || string(start) != "PROXY " {
+		return nil, nil
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) > 107 || !strings.HasSuffix(line, "\r\n") {
+		return nil, errors.New("invalid PROXY protocol v1 header")
+	}
+	fields := strings.Fields(line)
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid PROXY protocol v1 header %q", strings.TrimSpace(line))
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.Atoi(fields[4])
+	if ip == nil || err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid PROXY protocol v1 source %s:%s", fields[2], fields[4])
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// readProxyHeaderV2 reads the binary header of the version 2 of the PROXY protocol
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	versionCommand, family := header[12], header[13]
+	length := int(binary.BigEndian.Uint16(header[14:16]))
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	if versionCommand>>4 != 2 {
+		return nil, errors.New("invalid PROXY protocol v2 version")
+	}
+	if versionCommand&0x0f == 0 {
+		// LOCAL command, the connection was opened by the proxy itself, like for its health checks
+		return nil, nil
+	}
+	switch family {
+	case 0x11: // tcp over ipv4
+		if length < 12 {
+			return nil, errors.New("truncated PROXY protocol v2 ipv4 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 0x21: // tcp over ipv6
+		if length < 36 {
+			return nil, errors.New("truncated PROXY protocol v2 ipv6 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	}
+	return nil, nil
+}
+
+// proxyConn is a connection starting with a PROXY protocol header, the header is read at the first use of the connection
+// so that a slow client cannot block the accept loop
+type proxyConn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	once    sync.Once
+	remote  net.Addr
+	err     error
+}
+
+func (c *proxyConn) readHeader() {
+	c.once.Do(func() {
+		c.remote = c.Conn.RemoteAddr()
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		addr, err := readProxyHeader(c.reader)
+		_ = c.Conn.SetReadDeadline(time.Time{})
+		if err != nil {
+			c.err = err
+			return
+		}
+		if addr != nil {
+			c.remote = addr
+		}
+	})
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the address of the client given by the PROXY protocol header
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	return c.remote
+}
+
+// proxyProtoListener accepts the PROXY protocol header on the connections coming from a trusted proxy,
+// the other connections like the kubelet probes are used as they are
+type proxyProtoListener struct {
+	net.Listener
+	trustedProxies []*net.IPNet
+	timeout        time.Duration
+}
+
+// NewProxyProtoListener returns a listener reading the PROXY protocol header of the connections of the trusted proxies
+func NewProxyProtoListener(ln net.Listener, trustedProxies []*net.IPNet) net.Listener {
+	return &proxyProtoListener{Listener: ln, trustedProxies: trustedProxies, timeout: defaultProxyHeaderTimeout}
+}
+
+func (l *proxyProtoListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok || !inNets(tcpAddr.IP, l.trustedProxies) {
+		return conn, nil
+	}
+	return &proxyConn{Conn: conn, reader: bufio.NewReader(conn), timeout: l.timeout}, nil
+}
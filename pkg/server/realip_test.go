@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveClientIp(t *testing.T) {
+	trusted, err := config.ParseCidrList("10.0.0.0/8, 192.0.2.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		realIp     string
+		want       string
+	}{
+		{name: "1: direct client should use RemoteAddr", remoteAddr: "203.0.113.7:4000", want: "203.0.113.7"},
+		{name: "2: X-Forwarded-For of an untrusted client should be ignored", remoteAddr: "203.0.113.7:4000", forwarded: "198.51.100.1", want: "203.0.113.7"},
+		{name: "3: trusted proxy should give the client", remoteAddr: "10.1.2.3:4000", forwarded: "198.51.100.1", want: "198.51.100.1"},
+		{name: "4: forged addresses on the left should be ignored", remoteAddr: "10.1.2.3:4000", forwarded: "1.1.1.1, 198.51.100.1, 192.0.2.5", want: "198.51.100.1"},
+		{name: "5: trusted proxy without header should use RemoteAddr", remoteAddr: "10.1.2.3:4000", want: "10.1.2.3"},
+		{name: "6: trusted proxy should give the client in X-Real-IP", remoteAddr: "10.1.2.3:4000", realIp: "198.51.100.2", want: "198.51.100.2"},
+		{name: "7: X-Forwarded-For should win over X-Real-IP", remoteAddr: "10.1.2.3:4000", forwarded: "198.51.100.1", realIp: "198.51.100.2", want: "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIp != "" {
+				r.Header.Set("X-Real-IP", tt.realIp)
+			}
+			assert.Equal(t, tt.want, ResolveClientIp(r, trusted))
+		})
+	}
+	_, err = config.ParseCidrList("10.0.0.0/33")
+	assert.Error(t, err)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	v2Ipv4 := append(append([]byte{}, proxyV2Signature...), 0x21, 0x11, 0, 12,
+		198, 51, 100, 1, 10, 0, 0, 1, 0x9c, 0x40, 0, 80)
+	v2Local := append(append([]byte{}, proxyV2Signature...), 0x20, 0x00, 0, 0)
+	tests := []struct {
+		name     string
+		input    string
+		wantAddr string
+		wantErr  bool
+		wantRest string
+	}{
+		{name: "1: v1 tcp4 header", input: "PROXY TCP4 198.51.100.1 10.0.0.1 40000 80\r\nGET /", wantAddr: "198.51.100.1:40000", wantRest: "GET /"},
+		{name: "2: v1 tcp6 header", input: "PROXY TCP6 2001:db8::1 2001:db8::2 40000 80\r\nGET /", wantAddr: "[2001:db8::1]:40000", wantRest: "GET /"},
+		{name: "3: v1 unknown header", input: "PROXY UNKNOWN\r\nGET /", wantRest: "GET /"},
+		{name: "4: v1 invalid header", input: "PROXY TCP4 nowhere\r\nGET /", wantErr: true},
+		{name: "5: v2 tcp4 header", input: string(v2Ipv4) + "GET /", wantAddr: "198.51.100.1:40000", wantRest: "GET /"},
+		{name: "6: v2 local header", input: string(v2Local) + "GET /", wantRest: "GET /"},
+		{name: "7: no header", input: "POST / HTTP/1.1", wantRest: "POST / HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tt.input))
+			addr, err := readProxyHeader(r)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.wantAddr == "" {
+				assert.Nil(t, addr)
+			} else if assert.NotNil(t, addr) {
+				assert.Equal(t, tt.wantAddr, addr.String())
+			}
+			rest, _ := io.ReadAll(r)
+			assert.Equal(t, tt.wantRest, string(rest), "the request after the header should be left unread")
+		})
+	}
+}
+
+func TestProxyProtoListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted, _ := config.ParseCidrList("127.0.0.1")
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.RemoteAddr)
+	})}
+	go srv.Serve(NewProxyProtoListener(ln, trusted))
+	defer srv.Close()
+
+	send := func(request string) string {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		io.WriteString(conn, request)
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	assert.Equal(t, "198.51.100.1:40000", send("PROXY TCP4 198.51.100.1 127.0.0.1 40000 80\r\nGET / HTTP/1.1\r\nHost: test\r\n\r\n"))
+	assert.True(t, strings.HasPrefix(send("GET / HTTP/1.1\r\nHost: test\r\n\r\n"), "127.0.0.1:"),
+		"a connection without header like a kubelet probe should keep its address")
+}
+
+func TestGoHttpServerRealIp(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "127.0.0.0/8,::1")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/", nil)
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var info info.RuntimeInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatalf("the output should be a valid json : %v", err)
+	}
+	assert.Equal(t, "198.51.100.1", info.RemoteIp, "the client behind the trusted proxy should be shown")
+	assert.NotEmpty(t, info.ProxyAddr, "the address of the proxy should be shown")
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultUpgradeTimeout = 30 * time.Second // time given to the new binary to listen before the upgrade is abandoned
+	envUpgradeReadyFd     = "UPGRADE_READY_FD"
+	upgradeReadyFd        = listenFdsStart + 1 // the listener is passed on fd 3 and the readiness pipe on fd 4
+)
+
+// ErrUpgradeUnsupported is returned when the server has listeners that cannot be handed to the new binary
+var ErrUpgradeUnsupported = errors.New("binary reload only hands off the main tcp listener")
+
+// upgradeEnv returns environ for the new binary, with the socket activation variables giving it the listener on fd 3
+// and the descriptor of the pipe where it tells it is ready. LISTEN_PID is left out since the pid is not known before exec
+func upgradeEnv(environ []string) []string {
+	env := make([]string, 0, len(environ)+3)
+	for _, v := range environ {
+		name, _, _ := strings.Cut(v, "=")
+		switch name {
+		case "LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES", envUpgradeReadyFd:
+			continue
+		}
+		env = append(env, v)
+	}
+	return append(env, "LISTEN_FDS=1", "LISTEN_FDNAMES=http", envUpgradeReadyFd+"="+strconv.Itoa(upgradeReadyFd))
+}
+
+// startUpgradedProcess starts the binary at path with args, sharing the listener ln, and waits until the new process
+// tells it listens or timeout expires. both processes accept on the same socket, no connection is refused in between.
+// the new process is killed when it exits or does not get ready in time, this one keeps serving
+func startUpgradedProcess(ln net.Listener, path string, args []string, timeout time.Duration) (*os.Process, error) {
+	tcpLn, ok := ln.(*net.TCPListener)
+	if !ok {
+		return nil, fmt.Errorf("%w, got a %T", ErrUpgradeUnsupported, ln)
+	}
+	// File returns a duplicate of the descriptor, in blocking mode, that the new process receives
+	lnFile, err := tcpLn.File()
+	if err != nil {
+		return nil, fmt.Errorf("getting the listener descriptor: %w", err)
+	}
+	defer lnFile.Close()
+	ready, readyWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer ready.Close()
+	cmd := exec.Command(path, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = upgradeEnv(os.Environ())
+	cmd.ExtraFiles = []*os.File{lnFile, readyWriter}
+	err = cmd.Start()
+	// only the new process keeps the write end, so the read below ends with EOF if it exits before being ready
+	readyWriter.Close()
+	if err != nil {
+		return nil, fmt.Errorf("starting %s: %w", path, err)
+	}
+	ready.SetReadDeadline(time.Now().Add(timeout))
+	if _, err := io.ReadFull(ready, make([]byte, 1)); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s exited before listening", path)
+		}
+		return nil, fmt.Errorf("%s did not listen in %s: %w", path, timeout, err)
+	}
+	return cmd.Process, nil
+}
+
+// notifyUpgradeReady tells the process which started this one with a binary reload that the server listens, it
+// returns false when the server was not started by a binary reload
+func notifyUpgradeReady() bool {
+	fd, found := os.LookupEnv(envUpgradeReadyFd)
+	os.Unsetenv(envUpgradeReadyFd)
+	n, err := strconv.Atoi(fd)
+	if !found || err != nil || n < upgradeReadyFd {
+		return false
+	}
+	f := os.NewFile(uintptr(n), "upgrade-ready")
+	defer f.Close()
+	_, err = f.Write([]byte{1})
+	return err == nil
+}
+
+// upgradeUnsupported returns why the listeners of the server cannot be handed to a new binary, nil when they can
+func (s *GoHttpServer) upgradeUnsupported(ln net.Listener) error {
+	switch {
+	case ln == nil || s.socketPath != "":
+		return fmt.Errorf("%w, not the unix socket of LISTEN_SOCKET", ErrUpgradeUnsupported)
+	case s.extraListen != "":
+		return fmt.Errorf("%w, not the addresses of EXTRA_LISTEN", ErrUpgradeUnsupported)
+	case s.pprofServer != nil || s.adminServer != nil || s.grpcAddress != "" || s.http3Address != "":
+		return fmt.Errorf("%w, not the ports of PPROF_PORT, ADMIN_PORT, GRPC_PORT or HTTP3_PORT", ErrUpgradeUnsupported)
+	}
+	return nil
+}
+
+// handleUpgrades starts the new binary on ln at each signal of upgrades, once it listens this server drains its
+// connections and stops like on SIGINT, leaving the listener to the new process
+func (s *GoHttpServer) handleUpgrades(upgrades <-chan os.Signal, done <-chan struct{}, ln net.Listener) {
+	for {
+		select {
+		case <-done:
+			return
+		case sig := <-upgrades:
+			if err := s.upgradeUnsupported(ln); err != nil {
+				s.logger.Error("binary reload is not possible, the server keeps running", "signal", sig.String(), "error", err)
+				continue
+			}
+			path, err := os.Executable()
+			if err != nil {
+				s.logger.Error("binary reload is not possible, the server keeps running", "signal", sig.String(), "error", err)
+				continue
+			}
+			s.logger.Info("binary reload requested, starting the new binary", "signal", sig.String(), "path", path)
+			process, err := startUpgradedProcess(ln, path, os.Args[1:], defaultUpgradeTimeout)
+			if err != nil {
+				s.logger.Error("binary reload failed, the server keeps running", "path", path, "error", err)
+				continue
+			}
+			s.logger.Info("new binary is listening, draining the connections of this process", "new_pid", process.Pid)
+			s.upgradedPid.Store(int64(process.Pid))
+			s.Stop()
+			return
+		}
+	}
+}
+
+// writePidFile writes the pid of this process to path, the new binary overwrites it once it listens
+func writePidFile(path string) error {
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
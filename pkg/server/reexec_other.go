@@ -0,0 +1,14 @@
+//go:build !unix
+
+package server
+
+import (
+	"log/slog"
+	"os"
+)
+
+// upgradeSignals is empty, there is no SIGUSR2 to start a binary reload outside unix
+var upgradeSignals []os.Signal
+
+// superviseUpgrades has nothing to do, pid 1 only exists on unix
+func superviseUpgrades(_ *slog.Logger) {}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const envUpgradeHelper = "GO_INFO_UPGRADE_HELPER"
+
+// TestUpgradeHelperProcess is the new binary started by TestStartUpgradedProcess, it answers its pid on the inherited listener
+func TestUpgradeHelperProcess(t *testing.T) {
+	mode := os.Getenv(envUpgradeHelper)
+	if mode == "" {
+		t.Skip("only run as the new binary of TestStartUpgradedProcess")
+	}
+	switch mode {
+	case "exit":
+		os.Exit(3)
+	case "hang":
+		time.Sleep(time.Minute)
+	}
+	listeners, err := inheritedListeners(os.LookupEnv, os.Getpid(), listenFdsStart)
+	if err != nil || len(listeners) != 1 {
+		os.Exit(4)
+	}
+	if !notifyUpgradeReady() {
+		os.Exit(5)
+	}
+	conn, err := listeners[0].Accept()
+	if err != nil {
+		os.Exit(6)
+	}
+	conn.Write([]byte(strconv.Itoa(os.Getpid()) + "\n"))
+	conn.Close()
+	os.Exit(0)
+}
+
+func TestUpgradeEnv(t *testing.T) {
+	env := upgradeEnv([]string{"PATH=/bin", "LISTEN_PID=12", "LISTEN_FDS=2", "UPGRADE_READY_FD=9", "PORT=8080"})
+	assert.Equal(t, []string{"PATH=/bin", "PORT=8080", "LISTEN_FDS=1", "LISTEN_FDNAMES=http", "UPGRADE_READY_FD=4"}, env)
+}
+
+func TestStartUpgradedProcess(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		timeout time.Duration
+		wantErr bool
+	}{
+		{name: "1: a new binary listening should take the connections", mode: "serve", timeout: 10 * time.Second},
+		{name: "2: a new binary exiting before listening should be an error", mode: "exit", timeout: 10 * time.Second, wantErr: true},
+		{name: "3: a new binary never listening should be an error after the timeout", mode: "hang", timeout: 200 * time.Millisecond, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envUpgradeHelper, tt.mode)
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			process, err := startUpgradedProcess(ln, os.Args[0], []string{"-test.run=^TestUpgradeHelperProcess$"}, tt.timeout)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer process.Wait()
+			// once the listener of this process is closed, only the new one accepts on the shared socket
+			address := ln.Addr().String()
+			ln.Close()
+			conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+			if !assert.NoError(t, err, "the socket should stay open after the listener of the old process is closed") {
+				return
+			}
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			line, err := bufio.NewReader(conn).ReadString('\n')
+			assert.NoError(t, err)
+			assert.Equal(t, strconv.Itoa(process.Pid)+"\n", line, "the connection should be accepted by the new process")
+		})
+	}
+}
+
+func TestUpgradeUnsupported(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	tests := []struct {
+		name    string
+		setup   func(c *config.Config)
+		ln      net.Listener
+		wantErr bool
+	}{
+		{name: "1: the main listener alone should be handed off", setup: func(c *config.Config) {}, ln: ln},
+		{name: "2: a unix socket only server should not be reloaded", setup: func(c *config.Config) {}, ln: nil, wantErr: true},
+		{name: "3: extra addresses should prevent the reload", setup: func(c *config.Config) { c.ExtraListen = "127.0.0.1:0" }, ln: ln, wantErr: true},
+		{name: "4: an admin port should prevent the reload", setup: func(c *config.Config) { c.AdminPort = 9999 }, ln: ln, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.DefaultConfig()
+			tt.setup(&settings)
+			myServer := NewGoHttpServerWithConfig(config.DefaultListenIp+":0", settings, getTestLogger())
+			err := myServer.upgradeUnsupported(tt.ln)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrUpgradeUnsupported)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+//go:build unix
+
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// upgradeSignals start a binary reload when BINARY_RELOAD is true
+var upgradeSignals = []os.Signal{syscall.SIGUSR2}
+
+// superviseUpgrades keeps pid 1 of a container alive after a binary reload, since the container stops with it. the
+// processes of the new binaries are reparented to pid 1, the signals are forwarded to them and they are reaped until
+// none remains
+func superviseUpgrades(logger *slog.Logger) {
+	if os.Getpid() != 1 {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			// pid -1 sends to every process of the pid namespace but pid 1 itself
+			syscall.Kill(-1, sig.(syscall.Signal))
+		}
+	}()
+	logger.Info("pid 1 stays as supervisor of the new binary")
+	for {
+		var status syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &status, 0, nil)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			// ECHILD, the last server has stopped
+			return
+		}
+		logger.Info("supervised process exited", "pid", pid, "exit_code", status.ExitStatus())
+	}
+}
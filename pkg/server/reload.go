@@ -0,0 +1,219 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+)
+
+// defaultConfigReloadInterval is the polling period of the config file, only used when its directory cannot be watched
+const defaultConfigReloadInterval = 10 * time.Second
+
+// ConfigReloader keeps the active configuration and loads it again on SIGHUP or when the config file changes.
+// only the settings tagged reload:"true" are applied while running, the others keep their value until a restart.
+// the directory of the file is watched with fsnotify, this sees the symlink swap of a mounted configmap as it happens.
+type ConfigReloader struct {
+	args      []string       // command line flags, applied again at each reload
+	level     *slog.LevelVar // level of the logger, nil when it cannot be changed
+	logger    *slog.Logger
+	mu        sync.RWMutex
+	current   config.Config
+	modTime   time.Time // modification time of the config file at the last load
+	loadedAt  time.Time
+	reloads   int
+	lastError string
+}
+
+// NewConfigReloader is a constructor for a ConfigReloader starting with the given validated configuration
+func NewConfigReloader(config config.Config, logger *slog.Logger) *ConfigReloader {
+	cr := &ConfigReloader{logger: logger, current: config, loadedAt: time.Now()}
+	if config.File() != "" {
+		if info, err := os.Stat(config.File()); err == nil {
+			cr.modTime = info.ModTime()
+		}
+	}
+	return cr
+}
+
+// Current returns the active configuration
+func (cr *ConfigReloader) Current() config.Config {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.current
+}
+
+// Reload loads the configuration again from the file, the env variables and the flags, and applies the settings
+// that can be changed while running. it returns the keys of the settings changed, on error the active configuration is kept
+func (cr *ConfigReloader) Reload() ([]string, error) {
+	next, err := config.LoadConfig(cr.args)
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if err == nil {
+		if ignored := next.KeepStatic(cr.current); len(ignored) > 0 {
+			cr.logger.Warn("configuration changes ignored, a restart is needed to apply them", "settings", ignored)
+		}
+		err = next.Validate()
+	}
+	if err != nil {
+		cr.lastError = err.Error()
+		return nil, err
+	}
+	changed := cr.current.Changed(next)
+	cr.current = next
+	cr.loadedAt = time.Now()
+	cr.reloads++
+	cr.lastError = ""
+	if cr.level != nil {
+		cr.level.Set(next.Level())
+	}
+	return changed, nil
+}
+
+// fileChanged returns true when the config file was modified since the last check
+func (cr *ConfigReloader) fileChanged() bool {
+	config := cr.Current()
+	file := config.File()
+	if file == "" {
+		return false
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return false
+	}
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if info.ModTime().Equal(cr.modTime) {
+		return false
+	}
+	cr.modTime = info.ModTime()
+	return true
+}
+
+// reloadAndLog reloads the configuration and logs the result
+func (cr *ConfigReloader) reloadAndLog(reason string) {
+	changed, err := cr.Reload()
+	if err != nil {
+		cr.logger.Error("configuration reload failed, keeping the active configuration", "reason", reason, "error", err)
+		return
+	}
+	cr.logger.Info("configuration reloaded", "reason", reason, "changed", changed)
+}
+
+// Watch reloads the configuration on SIGHUP, and when the config file changes, until ctx is done. the events of the
+// directory of the file are filtered on its modification time, when the directory cannot be watched the file is
+// checked every interval instead
+func (cr *ConfigReloader) Watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	var events <-chan fsnotify.Event
+	var watchErrors <-chan error
+	var ticks <-chan time.Time
+	config := cr.Current()
+	if file := config.File(); file != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err == nil {
+			if err = watcher.Add(filepath.Dir(file)); err != nil {
+				watcher.Close()
+			}
+		}
+		if err == nil {
+			defer watcher.Close()
+			events, watchErrors = watcher.Events, watcher.Errors
+		} else {
+			cr.logger.Warn("unable to watch the config file, polling it", "file", file, "interval", interval.String(), "error", err)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			ticks = ticker.C
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			cr.reloadAndLog("SIGHUP received")
+		case <-events:
+			if cr.fileChanged() {
+				cr.reloadAndLog("config file changed")
+			}
+		case err := <-watchErrors:
+			cr.logger.Warn("config file watch error", "error", err)
+		case <-ticks:
+			if cr.fileChanged() {
+				cr.reloadAndLog("config file changed")
+			}
+		}
+	}
+}
+
+// UseConfigReload makes the server reload its configuration while running, using the flags in args
+// and changing the level of its logger
+func (s *GoHttpServer) UseConfigReload(args []string, level *slog.LevelVar) {
+	s.settings.args = args
+	s.settings.level = level
+	s.configReload = true
+}
+
+// ConfigSetting is the active value of one setting shown by /config
+type ConfigSetting struct {
+	Value      string `json:"value"`
+	Source     string `json:"source"` // default, file, env or flag
+	Env        string `json:"env"`
+	Reloadable bool   `json:"reloadable"`
+}
+
+// ConfigReport is the active configuration shown by /config
+type ConfigReport struct {
+	File            string                   `json:"file,omitempty"`
+	LoadedAt        time.Time                `json:"loaded_at"`
+	Reloads         int                      `json:"reloads"`
+	LastReloadError string                   `json:"last_reload_error,omitempty"`
+	Settings        map[string]ConfigSetting `json:"settings"`
+}
+
+// Report returns the active configuration, masking the values of the secret settings and of the settings whose env
+// variable is sensitive for redactor
+func (cr *ConfigReloader) Report(redactor *EnvRedactor) ConfigReport {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	current := cr.current
+	report := ConfigReport{
+		File:            current.File(),
+		LoadedAt:        cr.loadedAt,
+		Reloads:         cr.reloads,
+		LastReloadError: cr.lastError,
+		Settings:        make(map[string]ConfigSetting),
+	}
+	for _, setting := range current.Settings() {
+		value := fmt.Sprintf("%v", setting.Value)
+		if setting.Secret || redactor.Masked(setting.Env) {
+			value = redactedValue
+		}
+		report.Settings[setting.Key] = ConfigSetting{Value: value, Source: current.Source(setting.Key), Env: setting.Env, Reloadable: setting.Reloadable}
+	}
+	return report
+}
+
+//############# BEGIN CONFIG HANDLERS
+
+// ConfigHandler shows the active configuration with the source of each setting
+func (s *GoHttpServer) ConfigHandler() http.HandlerFunc {
+	handlerName := "ConfigHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, r, http.StatusOK, s.settings.Report(s.envRedactor))
+	}
+}
+
+// ############# END CONFIG HANDLERS
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigReloaderReload(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(content string, modTime time.Time) {
+		if err := os.WriteFile(configFile, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(configFile, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+	writeConfig("port: 9090\nlog_level: info\n", start)
+	args := []string{"-config", configFile}
+	config, err := config.LoadConfig(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var level slog.LevelVar
+	cr := NewConfigReloader(config, getTestLogger())
+	cr.args = args
+	cr.level = &level
+	assert.False(t, cr.fileChanged(), "the file should not be seen as changed before it is modified")
+
+	writeConfig("port: 9191\nlog_level: debug\nwait_default: 1s\n", start.Add(time.Minute))
+	assert.True(t, cr.fileChanged())
+	changed, err := cr.Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"log_level", "wait_default"}, changed)
+	current := cr.Current()
+	assert.Equal(t, 9090, current.Port, "the port cannot be changed without a restart")
+	assert.Equal(t, "debug", current.LogLevel)
+	assert.Equal(t, slog.LevelDebug, level.Level(), "the level of the logger should follow the configuration")
+	assert.Equal(t, "file", current.Source("wait_default"))
+
+	writeConfig("log_level: loud\n", start.Add(2*time.Minute))
+	_, err = cr.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, "debug", cr.Current().LogLevel, "an invalid configuration should not be applied")
+	assert.NotEmpty(t, cr.Report(&EnvRedactor{}).LastReloadError)
+}
+
+func TestConfigReloaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	// a mounted configmap: the file is a symlink to ..data, a symlink swapped to a new directory on each update
+	writeVersion := func(version, content string) {
+		if err := os.MkdirAll(filepath.Join(dir, version), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, version, "config.yaml"), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(version, filepath.Join(dir, "..data_tmp")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeVersion("..v1", "log_level: info\n")
+	if err := os.Symlink(filepath.Join("..data", "config.yaml"), configFile); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-config", configFile}
+	config, err := config.LoadConfig(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr := NewConfigReloader(config, getTestLogger())
+	cr.args = args
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cr.Watch(ctx, time.Hour)
+	time.Sleep(100 * time.Millisecond)
+
+	writeVersion("..v2", "log_level: debug\n")
+	assert.Eventually(t, func() bool { return cr.Current().LogLevel == "debug" }, 5*time.Second, 20*time.Millisecond,
+		"the symlink swap should be applied without waiting for the polling interval")
+}
+
+func TestGoHttpServerConfigHandler(t *testing.T) {
+	t.Setenv("ENV_VAR_REDACT_PATTERNS", "^PPROF_PORT$")
+	t.Setenv("WAIT_MAX_SECONDS", "6")
+	t.Setenv("API_TOKEN", "s3cret")
+	t.Setenv("WEBHOOK_URLS", "https://hooks.example.com/s3cret")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "the configuration should need the API_TOKEN bearer")
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/config", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var report ConfigReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("the output should be a valid json : %v", err)
+	}
+	assert.Equal(t, ConfigSetting{Value: "6s", Source: "env", Env: "WAIT_MAX_SECONDS", Reloadable: true}, report.Settings["wait_max"])
+	assert.Equal(t, ConfigSetting{Value: "8080", Source: "default", Env: "PORT"}, report.Settings["port"])
+	assert.Equal(t, redactedValue, report.Settings["pprof_port"].Value, "sensitive settings should be masked")
+	assert.Equal(t, ConfigSetting{Value: redactedValue, Source: "env", Env: "API_TOKEN"}, report.Settings["api_token"], "secret settings should be masked")
+	assert.Equal(t, ConfigSetting{Value: redactedValue, Source: "env", Env: "WEBHOOK_URLS"}, report.Settings["webhook_urls"], "secret settings should be masked")
+}
+
+func TestGoHttpServerFeatureToggleReload(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+	getStatus := func() int {
+		resp, err := http.Get(ts.URL + "/chaos/error?code=503")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusNotFound, getStatus(), "chaos endpoints should not exist without ENABLE_CHAOS")
+	t.Setenv("ENABLE_CHAOS", "true")
+	changed, err := myServer.settings.Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"enable_chaos"}, changed)
+	assert.Equal(t, http.StatusServiceUnavailable, getStatus(), "chaos endpoints should be enabled by the reload")
+}
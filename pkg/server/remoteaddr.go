@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReverseDnsTimeout    = 500 * time.Millisecond // max time for one reverse dns lookup
+	defaultReverseDnsCacheTtl   = 5 * time.Minute        // how long a reverse dns result is kept
+	defaultReverseDnsMaxEntries = 4096                   // max number of ip kept in the reverse dns cache
+)
+
+// RemoteAddress is the structured form of http.Request.RemoteAddr
+type RemoteAddress struct {
+	RemoteAddr      string `json:"remote_addr"`          // remote client ip address as received ip:port
+	RemoteIp        string `json:"remote_ip"`            // remote client ip without port
+	RemotePort      int    `json:"remote_port"`          // remote client port (0 if unknown)
+	RemoteIpVersion int    `json:"remote_ip_version"`    // 4 or 6, 0 for unix socket or unparsable address
+	RemotePtr       string `json:"remote_ptr,omitempty"` // reverse dns name of remote ip, only when asked with ?rdns=true
+}
+
+// ParseRemoteAddr splits an ip:port or [ipv6]:port address in its parts,
+// it never fails : an empty (unix socket) or malformed address just gives empty parts.
+func ParseRemoteAddr(addr string) RemoteAddress {
+	res := RemoteAddress{RemoteAddr: addr}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		// maybe just an ip without port
+		host = strings.Trim(addr, "[]")
+		port = ""
+	}
+	// remove ipv6 zone like in fe80::1%eth0
+	ip := net.ParseIP(strings.SplitN(host, "%", 2)[0])
+	if ip == nil {
+		return res
+	}
+	res.RemoteIp = host
+	if p, err := strconv.Atoi(port); err == nil && p >= 0 && p <= 65535 {
+		res.RemotePort = p
+	}
+	if ip.To4() != nil {
+		res.RemoteIpVersion = 4
+	} else {
+		res.RemoteIpVersion = 6
+	}
+	return res
+}
+
+type reverseDnsEntry struct {
+	name      string
+	expiresAt time.Time
+}
+
+// ReverseDnsCache resolves ip addresses to names with a timeout and keeps the results for a while
+type ReverseDnsCache struct {
+	mu         sync.Mutex
+	entries    map[string]reverseDnsEntry
+	ttl        time.Duration
+	timeout    time.Duration
+	maxEntries int
+	lookupAddr func(ctx context.Context, addr string) ([]string, error)
+	now        func() time.Time
+}
+
+// NewReverseDnsCache is a constructor for a ReverseDnsCache using the given lookup function, usually net.DefaultResolver.LookupAddr
+func NewReverseDnsCache(lookupAddr func(ctx context.Context, addr string) ([]string, error)) *ReverseDnsCache {
+	return &ReverseDnsCache{
+		entries:    make(map[string]reverseDnsEntry),
+		ttl:        defaultReverseDnsCacheTtl,
+		timeout:    defaultReverseDnsTimeout,
+		maxEntries: defaultReverseDnsMaxEntries,
+		lookupAddr: lookupAddr,
+		now:        time.Now,
+	}
+}
+
+// Lookup returns the first name of the ip, or an empty string if there is none or the lookup failed.
+// failures are cached too, so a slow dns does not slow down every request. the lookup is detached from the
+// cancellation of ctx, a client closing its connection must not leave a failure in the cache.
+func (c *ReverseDnsCache) Lookup(ctx context.Context, ip string) string {
+	if ip == "" {
+		return ""
+	}
+	c.mu.Lock()
+	entry, exist := c.entries[ip]
+	c.mu.Unlock()
+	if exist && c.now().Before(entry.expiresAt) {
+		return entry.name
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+	name := ""
+	names, err := c.lookupAddr(ctx, ip)
+	if errors.Is(err, context.Canceled) {
+		return ""
+	}
+	if err == nil && len(names) > 0 {
+		name = strings.TrimSuffix(names[0], ".")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.maxEntries {
+		now := c.now()
+		for k, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	if len(c.entries) < c.maxEntries {
+		c.entries[ip] = reverseDnsEntry{name: name, expiresAt: c.now().Add(c.ttl)}
+	}
+	return name
+}
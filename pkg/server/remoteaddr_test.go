@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRemoteAddr(t *testing.T) {
+	tests := []struct {
+		name string
+		addr string
+		want RemoteAddress
+	}{
+		{name: "1: ipv4 with port", addr: "127.0.0.1:56670",
+			want: RemoteAddress{RemoteAddr: "127.0.0.1:56670", RemoteIp: "127.0.0.1", RemotePort: 56670, RemoteIpVersion: 4}},
+		{name: "2: ipv6 with port", addr: "[::1]:54321",
+			want: RemoteAddress{RemoteAddr: "[::1]:54321", RemoteIp: "::1", RemotePort: 54321, RemoteIpVersion: 6}},
+		{name: "3: ipv6 with zone", addr: "[fe80::1%eth0]:80",
+			want: RemoteAddress{RemoteAddr: "[fe80::1%eth0]:80", RemoteIp: "fe80::1%eth0", RemotePort: 80, RemoteIpVersion: 6}},
+		{name: "4: ipv4 without port", addr: "10.0.0.1",
+			want: RemoteAddress{RemoteAddr: "10.0.0.1", RemoteIp: "10.0.0.1", RemoteIpVersion: 4}},
+		{name: "5: unix socket gives an empty address", addr: "",
+			want: RemoteAddress{}},
+		{name: "6: unix socket abstract name", addr: "@",
+			want: RemoteAddress{RemoteAddr: "@"}},
+		{name: "7: malformed address", addr: "not-an-ip:port:42",
+			want: RemoteAddress{RemoteAddr: "not-an-ip:port:42"}},
+		{name: "8: invalid port is ignored", addr: "127.0.0.1:99999",
+			want: RemoteAddress{RemoteAddr: "127.0.0.1:99999", RemoteIp: "127.0.0.1", RemoteIpVersion: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseRemoteAddr(tt.addr))
+		})
+	}
+}
+
+func TestReverseDnsCacheLookup(t *testing.T) {
+	calls := 0
+	now := time.Now()
+	c := NewReverseDnsCache(func(ctx context.Context, addr string) ([]string, error) {
+		calls++
+		if addr == "10.0.0.1" {
+			return nil, errors.New("no such host")
+		}
+		return []string{"localhost."}, nil
+	})
+	c.now = func() time.Time { return now }
+
+	assert.Equal(t, "localhost", c.Lookup(context.Background(), "127.0.0.1"), "trailing dot should be removed")
+	assert.Equal(t, "localhost", c.Lookup(context.Background(), "127.0.0.1"))
+	assert.Equal(t, 1, calls, "second lookup should come from the cache")
+
+	assert.Equal(t, "", c.Lookup(context.Background(), "10.0.0.1"), "failed lookup should give an empty name")
+	assert.Equal(t, "", c.Lookup(context.Background(), "10.0.0.1"))
+	assert.Equal(t, 2, calls, "failed lookup should be cached too")
+
+	assert.Equal(t, "", c.Lookup(context.Background(), ""), "empty ip should not be resolved")
+	assert.Equal(t, 2, calls)
+
+	now = now.Add(defaultReverseDnsCacheTtl + time.Second)
+	assert.Equal(t, "localhost", c.Lookup(context.Background(), "127.0.0.1"))
+	assert.Equal(t, 3, calls, "expired entry should be resolved again")
+}
+
+func TestReverseDnsCacheTimeout(t *testing.T) {
+	c := NewReverseDnsCache(func(ctx context.Context, addr string) ([]string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	c.timeout = 20 * time.Millisecond
+	start := time.Now()
+	assert.Equal(t, "", c.Lookup(context.Background(), "127.0.0.1"))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second), "lookup should be capped by the timeout")
+}
+
+func TestReverseDnsCacheCanceledRequest(t *testing.T) {
+	var lookupErr error
+	c := NewReverseDnsCache(func(ctx context.Context, addr string) ([]string, error) {
+		assert.NoError(t, ctx.Err(), "the lookup should not see the cancellation of the request")
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		return []string{"localhost."}, nil
+	})
+	requestCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, "localhost", c.Lookup(requestCtx, "127.0.0.1"), "the lookup should succeed after the client left")
+
+	lookupErr = context.Canceled
+	assert.Equal(t, "", c.Lookup(context.Background(), "10.0.0.1"))
+	lookupErr = nil
+	assert.Equal(t, "localhost", c.Lookup(context.Background(), "10.0.0.1"), "a canceled lookup should not be cached")
+}
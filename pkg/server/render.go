@@ -0,0 +1,426 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const MIMETextHTMLCharsetUTF8 = "text/html; " + charsetUTF8
+
+// acceptQuality returns the quality factor given by the Accept header to the mime type offer,
+// using the most specific media range that matches it (type/subtype, then type/*, then */*)
+func acceptQuality(accept string, offer string) float64 {
+	offerType, _, _ := strings.Cut(offer, "/")
+	best, bestSpecificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		mediaRange = strings.ToLower(strings.TrimSpace(mediaRange))
+		specificity := -1
+		switch {
+		case mediaRange == offer:
+			specificity = 2
+		case mediaRange == offerType+"/*":
+			specificity = 1
+		case mediaRange == "*/*":
+			specificity = 0
+		}
+		if specificity < bestSpecificity || specificity < 0 {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, val, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.TrimSpace(key) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		best, bestSpecificity = q, specificity
+	}
+	return best
+}
+
+// negotiateContentType returns the offer preferred by the Accept header, the first offer wins in case of a tie
+// and when the header is empty. an empty string is returned when no offer is acceptable.
+func negotiateContentType(accept string, offers ...string) string {
+	if strings.TrimSpace(accept) == "" && len(offers) > 0 {
+		return offers[0]
+	}
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+const (
+	formatJson             = "json"
+	formatYaml             = "yaml"
+	formatXml              = "xml"
+	formatHtml             = "html"
+	formatQueryParam       = "format"
+	MIMEAppYAMLCharsetUTF8 = "application/yaml; " + charsetUTF8
+	MIMEAppXMLCharsetUTF8  = "application/xml; " + charsetUTF8
+)
+
+// formatMimeTypes are the mime types accepted for each output format in the Accept header, the first one is sent
+var formatMimeTypes = map[string][]string{
+	formatJson: {MIMEAppJSON},
+	formatYaml: {"application/yaml", "application/x-yaml", "text/yaml"},
+	formatXml:  {"application/xml", "text/xml"},
+	formatHtml: {"text/html"},
+}
+
+// responseFormat returns the output format chosen by the client among formats, with the format query parameter
+// or else with the Accept header. json is used when nothing matches, also when html wins but is not offered,
+// so that browsers get json rather than xml on the endpoints without an html view.
+func responseFormat(r *http.Request, formats ...string) (string, error) {
+	if format := strings.ToLower(r.URL.Query().Get(formatQueryParam)); format != "" {
+		for _, f := range formats {
+			if f == format {
+				return format, nil
+			}
+		}
+		return "", fmt.Errorf("unsupported format %q, should be one of %s", format, strings.Join(formats, ", "))
+	}
+	offers := []string{MIMEAppJSON}
+	for _, f := range append([]string{formatHtml}, formats...) {
+		if f != formatJson {
+			offers = append(offers, formatMimeTypes[f]...)
+		}
+	}
+	chosen := negotiateContentType(r.Header.Get("Accept"), offers...)
+	for _, f := range formats {
+		for _, mimeType := range formatMimeTypes[f] {
+			if mimeType == chosen {
+				return f, nil
+			}
+		}
+	}
+	return formatJson, nil
+}
+
+// render sends the result in the format chosen by the client : json (the default), yaml or xml
+func (s *GoHttpServer) render(w http.ResponseWriter, r *http.Request, status int, result interface{}) {
+	format, err := responseFormat(r, formatJson, formatYaml, formatXml)
+	if err != nil {
+		http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var body []byte
+	var mimeType string
+	switch format {
+	case formatYaml:
+		body, err = MarshalYaml(result)
+		mimeType = MIMEAppYAMLCharsetUTF8
+	case formatXml:
+		body, err = MarshalXml(result)
+		mimeType = MIMEAppXMLCharsetUTF8
+	default:
+		s.jsonResponseWithStatus(w, r, status, result)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		s.logger.Error("marshal failed", "format", format, "error", err)
+		return
+	}
+	w.Header().Set(HeaderContentType, mimeType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// the values to render are first converted to a tree keeping the order of the struct fields and the json names :
+// a scalar holds the json encoding of a leaf value, a list a slice and a mapping a struct or a map.
+type scalar string
+type list []interface{}
+type mapping []mappingField
+type mappingField struct {
+	key   string
+	value interface{}
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+// toTree converts v following the rules of encoding/json : json tags, omitempty, embedded structs and json.Marshaler
+func toTree(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return scalar("null"), nil
+	}
+	if v.Type().Implements(jsonMarshalerType) && !(v.Kind() == reflect.Pointer && v.IsNil()) {
+		raw, err := json.Marshal(v.Interface())
+		if err != nil {
+			return nil, err
+		}
+		var decoded interface{}
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		if err := decoder.Decode(&decoded); err != nil {
+			return nil, err
+		}
+		return toTree(reflect.ValueOf(decoded))
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return scalar("null"), nil
+		}
+		return toTree(v.Elem())
+	case reflect.Struct:
+		return structToTree(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return scalar("null"), nil
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface()) })
+		res := mapping{}
+		for _, k := range keys {
+			child, err := toTree(v.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, mappingField{key: fmt.Sprint(k.Interface()), value: child})
+		}
+		return res, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return scalar("null"), nil
+		}
+		res := list{}
+		for i := 0; i < v.Len(); i++ {
+			child, err := toTree(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, child)
+		}
+		return res, nil
+	}
+	return jsonScalar(v.Interface())
+}
+
+// jsonScalar returns the json encoding of a leaf value, without the html escaping done by json.Marshal
+func jsonScalar(v interface{}) (scalar, error) {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+	return scalar(bytes.TrimSuffix(b.Bytes(), []byte("\n"))), nil
+}
+
+func structToTree(v reflect.Value) (mapping, error) {
+	res := mapping{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fv := v.Field(i)
+		if sf.Anonymous && name == "" && fv.Kind() == reflect.Struct {
+			embedded, err := structToTree(fv)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, embedded...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if strings.Contains(opts, "omitempty") && isEmptyValue(fv) {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		child, err := toTree(fv)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, mappingField{key: name, value: child})
+	}
+	return res, nil
+}
+
+var plainYamlKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// yamlReservedWords are read as booleans or null by yaml 1.1 parsers when they are not quoted
+var yamlReservedWords = map[string]bool{"y": true, "n": true, "yes": true, "no": true, "true": true, "false": true,
+	"on": true, "off": true, "null": true}
+
+func writeYaml(b *bytes.Buffer, node interface{}, indent int) {
+	pad := strings.Repeat(" ", indent)
+	switch n := node.(type) {
+	case mapping:
+		for _, f := range n {
+			key := f.key
+			if !plainYamlKey.MatchString(key) || yamlReservedWords[strings.ToLower(key)] {
+				quoted, _ := jsonScalar(key)
+				key = string(quoted)
+			}
+			b.WriteString(pad + key + ":")
+			writeYamlValue(b, f.value, indent+2)
+		}
+	case list:
+		for _, item := range n {
+			b.WriteString(pad + "-")
+			writeYamlValue(b, item, indent+2)
+		}
+	}
+}
+
+// writeYamlValue writes the value following a "key:" or a "-", inline for scalars and empty collections
+func writeYamlValue(b *bytes.Buffer, node interface{}, indent int) {
+	switch n := node.(type) {
+	case scalar:
+		// a json scalar is also a valid yaml flow scalar
+		b.WriteString(" " + string(n) + "\n")
+	case mapping:
+		if len(n) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYaml(b, n, indent)
+	case list:
+		if len(n) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYaml(b, n, indent)
+	}
+}
+
+// MarshalYaml returns the yaml encoding of v, using the same field names as the json encoding
+func MarshalYaml(v interface{}) ([]byte, error) {
+	tree, err := toTree(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	switch tree.(type) {
+	case scalar:
+		writeYamlValue(&b, tree, 0)
+		return bytes.TrimLeft(b.Bytes(), " "), nil
+	}
+	if m, ok := tree.(mapping); ok && len(m) == 0 {
+		return []byte("{}\n"), nil
+	}
+	if l, ok := tree.(list); ok && len(l) == 0 {
+		return []byte("[]\n"), nil
+	}
+	writeYaml(&b, tree, 0)
+	return b.Bytes(), nil
+}
+
+var xmlName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+func writeXml(b *bytes.Buffer, name string, node interface{}, indent int) {
+	pad := strings.Repeat("  ", indent)
+	open, closing := name, name
+	if !xmlName.MatchString(name) || strings.HasPrefix(strings.ToLower(name), "xml") {
+		var key bytes.Buffer
+		xml.EscapeText(&key, []byte(name))
+		open, closing = fmt.Sprintf("item key=\"%s\"", key.String()), "item"
+	}
+	switch n := node.(type) {
+	case scalar:
+		if n == "null" {
+			fmt.Fprintf(b, "%s<%s/>\n", pad, open)
+			return
+		}
+		text := string(n)
+		var s string
+		if json.Unmarshal([]byte(n), &s) == nil {
+			text = s
+		}
+		fmt.Fprintf(b, "%s<%s>", pad, open)
+		xml.EscapeText(b, []byte(text))
+		fmt.Fprintf(b, "</%s>\n", closing)
+	case mapping:
+		fmt.Fprintf(b, "%s<%s>\n", pad, open)
+		for _, f := range n {
+			writeXml(b, f.key, f.value, indent+1)
+		}
+		fmt.Fprintf(b, "%s</%s>\n", pad, closing)
+	case list:
+		fmt.Fprintf(b, "%s<%s>\n", pad, open)
+		for _, item := range n {
+			writeXml(b, "item", item, indent+1)
+		}
+		fmt.Fprintf(b, "%s</%s>\n", pad, closing)
+	}
+}
+
+// MarshalXml returns the xml encoding of v inside a response element, using the json field names as element names.
+// map keys that are not valid xml names are written as <item key="...">, list elements as <item>.
+func MarshalXml(v interface{}) ([]byte, error) {
+	tree, err := toTree(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	writeXml(&b, "response", tree, 0)
+	return b.Bytes(), nil
+}
+
+const prettyQueryParam = "pretty"
+
+// wantsPrettyJson tells if the pretty query parameter of r asks for indented json, like pretty=true or pretty=1
+func wantsPrettyJson(r *http.Request) bool {
+	pretty, _ := strconv.ParseBool(r.URL.Query().Get(prettyQueryParam))
+	return pretty
+}
+
+// statusOnWrite sends the status with the first write, so that nothing is sent when the answer fails to be encoded
+type statusOnWrite struct {
+	http.ResponseWriter
+	status  int
+	written bool
+}
+
+func (w *statusOnWrite) Write(b []byte) (int, error) {
+	if !w.written {
+		w.written = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	return w.ResponseWriter.Write(b)
+}
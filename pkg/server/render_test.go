@@ -0,0 +1,197 @@
+package server
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{MIMEAppJSON, "text/html"}
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{name: "1: no Accept header should return the first offer", accept: "", want: MIMEAppJSON},
+		{name: "2: curl default */* should return the first offer", accept: "*/*", want: MIMEAppJSON},
+		{name: "3: browser Accept should return html", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,*/*;q=0.8", want: "text/html"},
+		{name: "4: explicit json should return json", accept: "application/json", want: MIMEAppJSON},
+		{name: "5: quality factors should be honoured", accept: "text/html;q=0.5, application/json;q=0.9", want: MIMEAppJSON},
+		{name: "6: type wildcard should match", accept: "text/*", want: "text/html"},
+		{name: "7: the most specific range should win over a wildcard", accept: "text/*;q=1, text/html;q=0.1, */*;q=0.5", want: MIMEAppJSON},
+		{name: "8: nothing acceptable should return an empty string", accept: "image/png", want: ""},
+		{name: "9: q=0 should refuse the offer", accept: "application/json;q=0, */*", want: "text/html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, negotiateContentType(tt.accept, offers...))
+		})
+	}
+}
+
+type renderEmbedded struct {
+	Kind string `json:"kind"`
+}
+
+type renderSample struct {
+	renderEmbedded
+	Name     string              `json:"name"`
+	Count    int                 `json:"count"`
+	Ratio    float64             `json:"ratio,omitempty"`
+	Enabled  bool                `json:"enabled"`
+	Tags     []string            `json:"tags"`
+	Labels   map[string]string   `json:"labels"`
+	Nested   *renderEmbedded     `json:"nested,omitempty"`
+	Headers  map[string][]string `json:"headers"`
+	Raw      json.RawMessage     `json:"raw"`
+	Secret   string              `json:"-"`
+	internal string
+}
+
+func newRenderSample() renderSample {
+	return renderSample{
+		renderEmbedded: renderEmbedded{Kind: "sample"},
+		Name:           "line 1\nline <2> & \"3\"",
+		Count:          42,
+		Enabled:        true,
+		Tags:           []string{"a", "b"},
+		Labels:         map[string]string{"zone": "b", "app": "info", "on": "x", "2nd key": "y"},
+		Headers:        map[string][]string{},
+		Raw:            json.RawMessage(`{"z":1,"a":[true,null]}`),
+		Secret:         "hidden",
+		internal:       "hidden",
+	}
+}
+
+func TestMarshalYaml(t *testing.T) {
+	got, err := MarshalYaml(newRenderSample())
+	assert.NoError(t, err)
+	want := `kind: "sample"
+name: "line 1\nline <2> & \"3\""
+count: 42
+enabled: true
+tags:
+  - "a"
+  - "b"
+labels:
+  "2nd key": "y"
+  app: "info"
+  "on": "x"
+  zone: "b"
+headers: {}
+raw:
+  a:
+    - true
+    - null
+  z: 1
+`
+	assert.Equal(t, want, string(got))
+
+	got, err = MarshalYaml([]int{})
+	assert.NoError(t, err)
+	assert.Equal(t, "[]\n", string(got))
+	got, err = MarshalYaml("alone")
+	assert.NoError(t, err)
+	assert.Equal(t, "\"alone\"\n", string(got))
+}
+
+func TestMarshalXml(t *testing.T) {
+	got, err := MarshalXml(newRenderSample())
+	assert.NoError(t, err)
+	body := string(got)
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		"<response>\n  <kind>sample</kind>",
+		"<name>line 1&#xA;line &lt;2&gt; &amp; &#34;3&#34;</name>",
+		"<count>42</count>",
+		"<tags>\n    <item>a</item>\n    <item>b</item>\n  </tags>",
+		`<item key="2nd key">y</item>`,
+		"<headers>\n  </headers>",
+		"<raw>\n    <a>\n      <item>true</item>\n      <item/>\n    </a>\n    <z>1</z>\n  </raw>",
+	} {
+		assert.Contains(t, body, want)
+	}
+	assert.NotContains(t, body, "hidden")
+	assert.NotContains(t, body, "ratio", "omitempty fields should be skipped")
+	var decoded struct {
+		Count int    `xml:"count"`
+		Kind  string `xml:"kind"`
+	}
+	assert.NoError(t, xml.Unmarshal(got, &decoded), "the output should be valid xml")
+	assert.Equal(t, 42, decoded.Count)
+}
+
+func TestGoHttpServerRender(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		url             string
+		accept          string
+		wantStatusCode  int
+		wantContentType string
+		wantBody        string
+	}{
+		{name: "1: json should stay the default", url: "/info/memory", wantStatusCode: http.StatusOK, wantContentType: MIMEAppJSONCharsetUTF8, wantBody: `"heap_alloc_bytes":`},
+		{name: "2: format=yaml should return yaml", url: "/info/memory?format=yaml", wantStatusCode: http.StatusOK, wantContentType: MIMEAppYAMLCharsetUTF8, wantBody: "\nheap_sys_bytes: "},
+		{name: "3: format=xml should return xml", url: "/readiness?format=xml", wantStatusCode: http.StatusOK, wantContentType: MIMEAppXMLCharsetUTF8, wantBody: "<status>ready</status>"},
+		{name: "4: Accept yaml should return yaml", url: "/", accept: "application/x-yaml", wantStatusCode: http.StatusOK, wantContentType: MIMEAppYAMLCharsetUTF8, wantBody: "appname: \"" + info.APP + "\""},
+		{name: "5: browser Accept should get json on endpoints without html", url: "/info/memory", accept: "text/html,application/xml;q=0.9,*/*;q=0.8",
+			wantStatusCode: http.StatusOK, wantContentType: MIMEAppJSONCharsetUTF8, wantBody: `"heap_alloc_bytes":`},
+		{name: "6: format=html should return the dashboard on the default handler", url: "/?format=html", wantStatusCode: http.StatusOK, wantContentType: MIMETextHTMLCharsetUTF8, wantBody: "<h3>" + info.APP},
+		{name: "7: unknown format should be a bad request", url: "/info/memory?format=toml", wantStatusCode: http.StatusBadRequest, wantBody: "unsupported format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+tt.url, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode, assertCorrectStatusCodeExpected)
+			if tt.wantContentType != "" {
+				assert.Equal(t, tt.wantContentType, resp.Header.Get(HeaderContentType))
+			}
+			body, _ := io.ReadAll(resp.Body)
+			assert.Contains(t, string(body), tt.wantBody)
+		})
+	}
+}
+
+func TestGoHttpServerJsonResponsePretty(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	tests := []struct {
+		name       string
+		url        string
+		result     interface{}
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "1: default should be compact json", url: "/x", result: map[string]int{"a": 1}, wantStatus: http.StatusCreated, wantBody: "{\"a\":1}\n"},
+		{name: "2: pretty=true should indent the json", url: "/x?pretty=true", result: map[string]int{"a": 1}, wantStatus: http.StatusCreated, wantBody: "{\n  \"a\": 1\n}\n"},
+		{name: "3: pretty=false should be compact json", url: "/x?pretty=false", result: map[string]int{"a": 1}, wantStatus: http.StatusCreated, wantBody: "{\"a\":1}\n"},
+		{name: "4: result failing to encode should be an internal error", url: "/x", result: map[string]interface{}{"f": func() {}}, wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			myServer.jsonResponseWithStatus(rec, httptest.NewRequest(http.MethodPost, tt.url, nil), tt.wantStatus, tt.result)
+			assert.Equal(t, tt.wantStatus, rec.Code, assertCorrectStatusCodeExpected)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+)
+
+// renderTemplateFuncs are the functions available in the templates of /render, besides the go template builtins
+var renderTemplateFuncs = map[string]interface{}{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join":  strings.Join,
+	"trim":  strings.TrimSpace,
+}
+
+// RenderTemplate is the template configured by the operator to shape the answer of /render
+type RenderTemplate struct {
+	ContentType string
+	tmpl        interface {
+		Execute(w io.Writer, data interface{}) error
+	}
+}
+
+// NewRenderTemplate parses text as the template of /render. an html content type uses html/template, so that
+// the values coming from the request like the headers are escaped
+func NewRenderTemplate(text, contentType string) (*RenderTemplate, error) {
+	rt := RenderTemplate{ContentType: contentType}
+	var err error
+	if strings.HasPrefix(contentType, "text/html") {
+		rt.tmpl, err = htmltemplate.New("render").Funcs(renderTemplateFuncs).Parse(text)
+	} else {
+		rt.tmpl, err = template.New("render").Funcs(renderTemplateFuncs).Parse(text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rt, nil
+}
+
+// GetRenderTemplateFromConfig returns the template of /render read from the file of the render_template_file setting,
+// executed with the RuntimeInfo of / and answered with the render_content_type setting.
+// it returns nil when render_template_file is empty. the template never comes from the request
+func GetRenderTemplateFromConfig(settings config.Config) (*RenderTemplate, error) {
+	if settings.RenderTemplate == "" {
+		return nil, nil
+	}
+	text, err := os.ReadFile(settings.RenderTemplate)
+	if err != nil {
+		return nil, &config.ErrorConfig{
+			Err: err,
+			Msg: "ERROR: CONFIG render_template_file (env RENDER_TEMPLATE_FILE) should be the path of a readable file",
+		}
+	}
+	rt, err := NewRenderTemplate(string(text), settings.RenderType)
+	if err != nil {
+		return nil, &config.ErrorConfig{
+			Err: err,
+			Msg: fmt.Sprintf("ERROR: CONFIG render_template_file (env RENDER_TEMPLATE_FILE) %s should contain a valid go template", settings.RenderTemplate),
+		}
+	}
+	return rt, nil
+}
+
+//############# BEGIN RENDER HANDLERS
+
+// RenderHandler answers the RuntimeInfo of / formatted by the template of RENDER_TEMPLATE_FILE,
+// for the scrapers expecting a payload of their own shape
+func (s *GoHttpServer) RenderHandler(rt *RenderTemplate) http.HandlerFunc {
+	handlerName := "RenderHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	base := s.baseRuntimeInfo()
+	return func(w http.ResponseWriter, r *http.Request) {
+		var out bytes.Buffer
+		if err := rt.tmpl.Execute(&out, s.requestRuntimeInfo(base, r)); err != nil {
+			s.logger.Error("render template failed", "handler", handlerName, "error", err)
+			http.Error(w, "ERROR: the render template failed : "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(HeaderContentType, rt.ContentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+		w.Write(out.Bytes())
+	}
+}
+
+// ############# END RENDER HANDLERS
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// defaultRenderContentType is the render_content_type of the default configuration
+var defaultRenderContentType = config.DefaultConfig().RenderType
+
+func TestGetRenderTemplateFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.tmpl")
+	invalid := filepath.Join(dir, "invalid.tmpl")
+	assert.Nil(t, os.WriteFile(valid, []byte("{{ .Hostname }}"), 0o600))
+	assert.Nil(t, os.WriteFile(invalid, []byte("{{ .Hostname "), 0o600))
+	tests := []struct {
+		name            string
+		file            string
+		contentType     string
+		wantNil         bool
+		wantContentType string
+		wantError       bool
+	}{
+		{name: "1: no render_template_file should disable /render", wantNil: true},
+		{name: "2: valid template should default to text/plain", file: valid, wantContentType: defaultRenderContentType},
+		{name: "3: render_content_type should be kept", file: valid, contentType: "text/html", wantContentType: "text/html"},
+		{name: "4: missing file should be an error", file: filepath.Join(dir, "missing.tmpl"), wantNil: true, wantError: true},
+		{name: "5: invalid template should be an error", file: invalid, wantNil: true, wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.DefaultConfig()
+			settings.RenderTemplate = tt.file
+			if tt.contentType != "" {
+				settings.RenderType = tt.contentType
+			}
+			got, err := GetRenderTemplateFromConfig(settings)
+			assert.Equal(t, tt.wantError, err != nil)
+			if err != nil {
+				_, isConfigError := err.(*config.ErrorConfig)
+				assert.True(t, isConfigError, "the error should be a config.ErrorConfig")
+			}
+			assert.Equal(t, tt.wantNil, got == nil)
+			if got != nil {
+				assert.Equal(t, tt.wantContentType, got.ContentType)
+			}
+		})
+	}
+}
+
+func TestGoHttpServerRenderHandler(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	tests := []struct {
+		name        string
+		template    string
+		contentType string
+		wantStatus  int
+		wantBody    string
+	}{
+		{name: "1: text template should get the name parameter", template: "name={{ .ParamName | upper }}", contentType: defaultRenderContentType,
+			wantStatus: http.StatusOK, wantBody: "name=<B>"},
+		{name: "2: html template should escape the request values", template: "<p>{{ .ParamName }}</p>", contentType: "text/html; " + charsetUTF8,
+			wantStatus: http.StatusOK, wantBody: "<p>&lt;b&gt;</p>"},
+		{name: "3: failing template should be an internal error", template: "{{ index .Headers \"X-Missing\" 3 }}", contentType: defaultRenderContentType,
+			wantStatus: http.StatusInternalServerError, wantBody: "ERROR: the render template failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt, err := NewRenderTemplate(tt.template, tt.contentType)
+			if err != nil {
+				t.Fatalf("NewRenderTemplate() error = %v", err)
+			}
+			rec := httptest.NewRecorder()
+			myServer.RenderHandler(rt)(rec, httptest.NewRequest(http.MethodGet, "/render?name=%3Cb%3E", nil))
+			assert.Equal(t, tt.wantStatus, rec.Code, assertCorrectStatusCodeExpected)
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, tt.contentType, rec.Header().Get(HeaderContentType))
+			}
+		})
+	}
+}
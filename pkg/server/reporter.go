@@ -0,0 +1,140 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+const (
+	defaultReportTimeout    = 10 * time.Second // max time of one POST to the collector
+	defaultReportAttempts   = 4                // POSTs of a report before it is abandoned until the next interval
+	defaultReportBackoffMin = time.Second
+	defaultReportBackoffMax = 15 * time.Second
+)
+
+// errReportRejected is returned when the collector refuses a report with a 4xx status, it is not retried
+var errReportRejected = errors.New("report rejected by the collector")
+
+// ReportConfig is the push mode sending the RuntimeInfo to a collector, for the clusters that cannot be scraped
+type ReportConfig struct {
+	Url      string        // endpoint of the collector receiving a POST of the RuntimeInfo
+	Interval time.Duration // time between two reports
+	Token    string        // sent in the Authorization: Bearer header when not empty
+}
+
+// GetReportConfigFromConfig returns the push mode configuration given by the validated report_url, report_interval
+// and report_token settings, nil is returned when report_url is empty
+func GetReportConfigFromConfig(settings config.Config) *ReportConfig {
+	if settings.ReportUrl == "" {
+		return nil
+	}
+	return &ReportConfig{Url: settings.ReportUrl, Interval: settings.ReportInterval, Token: settings.ReportToken}
+}
+
+// Reporter posts a snapshot of the RuntimeInfo to a collector every interval, retrying with exponential backoff
+// and jitter when the collector is not reachable or answers with a 5xx or 429 status
+type Reporter struct {
+	config     ReportConfig
+	logger     *slog.Logger
+	httpClient *http.Client
+	snapshot   func() info.RuntimeInfo
+	backoffMin time.Duration
+	backoffMax time.Duration
+	attempts   int
+	sequence   int
+}
+
+// NewReporter is a constructor for a Reporter sending the RuntimeInfo returned by snapshot
+func NewReporter(config ReportConfig, snapshot func() info.RuntimeInfo, logger *slog.Logger) *Reporter {
+	return &Reporter{
+		config:     config,
+		logger:     logger,
+		httpClient: &http.Client{Timeout: defaultReportTimeout},
+		snapshot:   snapshot,
+		backoffMin: defaultReportBackoffMin,
+		backoffMax: defaultReportBackoffMax,
+		attempts:   defaultReportAttempts,
+	}
+}
+
+// post sends body once, the error wraps errReportRejected when retrying is useless
+func (rp *Reporter) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rp.config.Url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w : %v", errReportRejected, err)
+	}
+	req.Header.Set("Content-Type", MIMEAppJSONCharsetUTF8)
+	req.Header.Set("User-Agent", info.APP+"/"+info.VERSION)
+	req.Header.Set("X-Report-Sequence", strconv.Itoa(rp.sequence))
+	if rp.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+rp.config.Token)
+	}
+	resp, err := rp.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	switch {
+	case resp.StatusCode < http.StatusMultipleChoices:
+		return nil
+	case resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("collector answered %s", resp.Status)
+	}
+	return fmt.Errorf("%w : collector answered %s", errReportRejected, resp.Status)
+}
+
+// Report sends one snapshot, retried until it is accepted, refused or the attempts are exhausted
+func (rp *Reporter) Report(ctx context.Context) error {
+	rp.sequence++
+	body, err := json.Marshal(rp.snapshot())
+	if err != nil {
+		return err
+	}
+	return retryWithBackoff(ctx, rp.backoffMin, rp.backoffMax, func(attempt int) (bool, error) {
+		err := rp.post(ctx, body)
+		return err == nil || errors.Is(err, errReportRejected) || attempt >= rp.attempts, err
+	}, func(attempt int, err error, sleep time.Duration) {
+		rp.logger.Debug("report failed, retrying", "url", rp.config.Url, "attempt", attempt, "error", err, "next_try_in", sleep)
+	})
+}
+
+// Run sends a report right away and then every interval until ctx is done
+func (rp *Reporter) Run(ctx context.Context) {
+	rp.logger.Info("Reporting to the collector", "url", rp.config.Url, "interval", rp.config.Interval.String())
+	ticker := time.NewTicker(rp.config.Interval)
+	defer ticker.Stop()
+	for {
+		if err := rp.Report(ctx); err != nil && ctx.Err() == nil {
+			rp.logger.Warn("report not delivered to the collector", "url", rp.config.Url, "sequence", rp.sequence, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// UseReporter starts pushing the RuntimeInfo of / to the collector of config when the server starts
+func (s *GoHttpServer) UseReporter(config ReportConfig) {
+	s.reporter = NewReporter(config, s.reportRuntimeInfo, s.logger)
+}
+
+// reportRuntimeInfo returns the RuntimeInfo of / without the fields of a request
+func (s *GoHttpServer) reportRuntimeInfo() info.RuntimeInfo {
+	data := s.baseRuntimeInfo()
+	data.Message, data.Banner = s.banner.Expand(s.settings.Current(), NewBannerVars(data))
+	return data
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetReportConfigFromConfig(t *testing.T) {
+	settings := config.DefaultConfig()
+	assert.Nil(t, GetReportConfigFromConfig(settings), "no report_url should disable the push mode")
+	settings.ReportUrl, settings.ReportInterval, settings.ReportToken = "http://collector", 15*time.Second, "s3cret"
+	assert.Equal(t, &ReportConfig{Url: "http://collector", Interval: 15 * time.Second, Token: "s3cret"}, GetReportConfigFromConfig(settings))
+}
+
+// fakeCollector answers the reports with the statuses in order, then with 200
+type fakeCollector struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   []info.RuntimeInfo
+}
+
+func (fc *fakeCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body info.RuntimeInfo
+	json.NewDecoder(r.Body).Decode(&body)
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.requests, fc.bodies = append(fc.requests, r), append(fc.bodies, body)
+	if len(fc.statuses) > 0 {
+		w.WriteHeader(fc.statuses[0])
+		fc.statuses = fc.statuses[1:]
+	}
+}
+
+func TestReporter(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantRequests int
+		wantErr      bool
+		wantRejected bool
+	}{
+		{name: "1: an accepted report should be sent once", wantRequests: 1},
+		{name: "2: a 503 should be retried until accepted", statuses: []int{503, 503}, wantRequests: 3},
+		{name: "3: a 429 should be retried", statuses: []int{429}, wantRequests: 2},
+		{name: "4: a 401 should not be retried", statuses: []int{401}, wantRequests: 1, wantErr: true, wantRejected: true},
+		{name: "5: the report should be abandoned after the attempts", statuses: []int{500, 500, 500, 500, 500}, wantRequests: defaultReportAttempts, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := &fakeCollector{statuses: tt.statuses}
+			ts := httptest.NewServer(collector)
+			defer ts.Close()
+			snapshot := func() info.RuntimeInfo { return info.RuntimeInfo{Hostname: "pod-1", Appname: info.APP} }
+			reporter := NewReporter(ReportConfig{Url: ts.URL, Interval: time.Minute, Token: "s3cret"}, snapshot, getTestLogger())
+			reporter.backoffMin, reporter.backoffMax = time.Millisecond, 4*time.Millisecond
+			err := reporter.Report(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			if tt.wantRejected {
+				assert.ErrorIs(t, err, errReportRejected)
+			}
+			collector.mu.Lock()
+			defer collector.mu.Unlock()
+			if !assert.Len(t, collector.requests, tt.wantRequests) {
+				return
+			}
+			req := collector.requests[0]
+			assert.Equal(t, http.MethodPost, req.Method)
+			assert.Equal(t, "Bearer s3cret", req.Header.Get("Authorization"))
+			assert.Equal(t, "1", req.Header.Get("X-Report-Sequence"))
+			assert.Equal(t, "pod-1", collector.bodies[0].Hostname)
+		})
+	}
+}
+
+func TestGoHttpServerReporter(t *testing.T) {
+	collector := &fakeCollector{}
+	ts := httptest.NewServer(collector)
+	defer ts.Close()
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	myServer.UseReporter(ReportConfig{Url: ts.URL, Interval: time.Minute})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		myServer.reporter.Run(ctx)
+		close(done)
+	}()
+	sent := func() int {
+		collector.mu.Lock()
+		defer collector.mu.Unlock()
+		return len(collector.requests)
+	}
+	for deadline := time.Now().Add(5 * time.Second); sent() == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if !assert.Len(t, collector.requests, 1, "the first report should be sent when the reporter starts") {
+		return
+	}
+	assert.Equal(t, info.APP, collector.bodies[0].Appname)
+	assert.Equal(t, os.Getpid(), collector.bodies[0].Pid)
+	assert.Empty(t, collector.requests[0].Header.Get("Authorization"), "no token should be sent without REPORT_TOKEN")
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	HeaderRequestId     = "X-Request-ID"
+	maxRequestIdLength  = 128
+	requestIdLogAttrKey = "request_id"
+)
+
+type requestIdContextKey struct{}
+
+// NewRequestId returns a random version 4 uuid
+func NewRequestId() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err) // crypto/rand never fails on the supported platforms
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// validRequestId returns true when id received from a client can be reused : not too long and only made of
+// letters, digits and the separators - _ . : so it cannot inject anything in the logs or the headers
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// WithRequestId returns a copy of ctx carrying the request id
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdContextKey{}, id)
+}
+
+// RequestIdFromContext returns the request id carried by ctx, empty when there is none
+func RequestIdFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdContextKey{}).(string)
+	return id
+}
+
+// InjectRequestId adds the X-Request-ID header of the request id in ctx to an outgoing request
+func InjectRequestId(ctx context.Context, req *http.Request) {
+	if id := RequestIdFromContext(ctx); id != "" {
+		req.Header.Set(HeaderRequestId, id)
+	}
+}
+
+// requestIds is the Middleware giving each request an id, the one of the X-Request-ID header when it is valid
+// or a new uuid, available in the request context and sent back in the X-Request-ID response header
+func (s *GoHttpServer) requestIds() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(HeaderRequestId)
+			if !validRequestId(id) {
+				id = NewRequestId()
+			}
+			w.Header().Set(HeaderRequestId, id)
+			next.ServeHTTP(w, r.WithContext(WithRequestId(r.Context(), id)))
+		})
+	}
+}
+
+// requestIdLogHandler is a slog.Handler adding the request id of the context to the records logged with the
+// Context variants of the logger methods, like logger.InfoContext(r.Context(), ...)
+type requestIdLogHandler struct {
+	slog.Handler
+}
+
+func (h requestIdLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestIdFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String(requestIdLogAttrKey, id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h requestIdLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIdLogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIdLogHandler) WithGroup(name string) slog.Handler {
+	return requestIdLogHandler{h.Handler.WithGroup(name)}
+}
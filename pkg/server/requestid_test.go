@@ -0,0 +1,95 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRequestId(t *testing.T) {
+	uuidV4 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	id := NewRequestId()
+	assert.True(t, uuidV4.MatchString(id), "%s should be a version 4 uuid", id)
+	assert.NotEqual(t, id, NewRequestId(), "each request id should be unique")
+}
+
+func TestValidRequestId(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{id: "0b7e8ac4-9d4a-4c58-8d7b-3d0e0c5a1f20", want: true},
+		{id: "frontend:1234.abc_def", want: true},
+		{id: "", want: false},
+		{id: "with space", want: false},
+		{id: "line\nbreak", want: false},
+		{id: strings.Repeat("a", maxRequestIdLength+1), want: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, validRequestId(tt.id), "validRequestId(%q)", tt.id)
+	}
+}
+
+func TestGoHttpServerRequestIds(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	get := func(id string) (*http.Response, info.RuntimeInfo) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/", nil)
+		if id != "" {
+			req.Header.Set(HeaderRequestId, id)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var info info.RuntimeInfo
+		if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+			t.Fatalf("the output should be a valid json : %v", err)
+		}
+		return resp, info
+	}
+	resp, info := get("frontend-42")
+	assert.Equal(t, "frontend-42", resp.Header.Get(HeaderRequestId), "a valid incoming request id should be kept")
+	assert.Equal(t, "frontend-42", info.RequestId)
+
+	resp, info = get("bad id")
+	generated := resp.Header.Get(HeaderRequestId)
+	assert.NotEqual(t, "bad id", generated, "an invalid incoming request id should be replaced")
+	assert.Len(t, generated, 36)
+	assert.Equal(t, generated, info.RequestId)
+}
+
+func TestRequestIdLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(requestIdLogHandler{slog.NewJSONHandler(&buf, nil)}).With("handler", "test")
+	logger.InfoContext(WithRequestId(context.Background(), "abc-123"), "request served")
+	logger.InfoContext(context.Background(), "startup")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.Contains(t, lines[0], `"request_id":"abc-123"`)
+		assert.NotContains(t, lines[1], "request_id")
+	}
+}
+
+func TestTokenErrorRequestId(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(WithRequestId(r.Context(), "abc-123"))
+	w := httptest.NewRecorder()
+	myServer.tokenError(w, r, tokenErrUnauthorized)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.JSONEq(t, `{"error":"`+tokenErrUnauthorized+`","request_id":"abc-123"}`, w.Body.String())
+}
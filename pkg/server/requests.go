@@ -0,0 +1,202 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultRequestsPageSize = 50
+
+// requestHistoryHeaders are the request headers kept in the history, the credentials like Authorization or Cookie
+// are never recorded
+var requestHistoryHeaders = []string{"User-Agent", "Referer", "Content-Type", "Accept", "X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host"}
+
+// RequestRecord is one request served by this server, as kept in the history
+type RequestRecord struct {
+	Time       time.Time         `json:"time"`
+	Hostname   string            `json:"hostname,omitempty"` // pod which served the request, only given by /requests/query
+	Method     string            `json:"method"`
+	Path       string            `json:"path"` // without the query, which may carry an access_token
+	Status     int               `json:"status"`
+	Bytes      int               `json:"bytes"`
+	DurationMs float64           `json:"duration_ms"`
+	ClientIp   string            `json:"client_ip"`
+	RequestId  string            `json:"request_id,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+}
+
+// RequestFilter selects the records returned by /requests, the zero value matches all of them
+type RequestFilter struct {
+	Method      string
+	PathPrefix  string
+	StatusClass int // 2 for the 2xx, 5 for the 5xx, 0 for any
+	Status      int // exact status, 0 for any
+	ClientIp    string
+	MinDuration time.Duration
+}
+
+// Match returns true when rec is selected by the filter
+func (f RequestFilter) Match(rec RequestRecord) bool {
+	return (f.Method == "" || strings.EqualFold(f.Method, rec.Method)) &&
+		strings.HasPrefix(rec.Path, f.PathPrefix) &&
+		(f.StatusClass == 0 || rec.Status/100 == f.StatusClass) &&
+		(f.Status == 0 || rec.Status == f.Status) &&
+		(f.ClientIp == "" || f.ClientIp == rec.ClientIp) &&
+		rec.DurationMs >= float64(f.MinDuration.Microseconds())/1000
+}
+
+// RequestsReport is a page of the request history returned by /requests, the newest request first
+type RequestsReport struct {
+	Capacity int             `json:"capacity"` // number of requests kept in memory
+	Recorded uint64          `json:"recorded"` // number of requests recorded since the start
+	Matched  int             `json:"matched"`  // number of the kept requests matching the filter
+	Offset   int             `json:"offset"`
+	Limit    int             `json:"limit"`
+	Requests []RequestRecord `json:"requests"`
+}
+
+// RequestHistory keeps the last requests served in a ring buffer of fixed capacity
+type RequestHistory struct {
+	mu       sync.Mutex
+	records  []RequestRecord
+	next     int    // index of the slot written by the next Add
+	recorded uint64 // number of Add since the start
+}
+
+// NewRequestHistory is a constructor for a RequestHistory keeping the last capacity requests
+func NewRequestHistory(capacity int) *RequestHistory {
+	return &RequestHistory{records: make([]RequestRecord, 0, capacity)}
+}
+
+// Capacity returns the number of requests kept
+func (rh *RequestHistory) Capacity() int {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	return cap(rh.records)
+}
+
+// Add records rec, overwriting the oldest record when the history is full
+func (rh *RequestHistory) Add(rec RequestRecord) {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	if len(rh.records) < cap(rh.records) {
+		rh.records = append(rh.records, rec)
+	} else {
+		rh.records[rh.next] = rec
+	}
+	rh.next = (rh.next + 1) % cap(rh.records)
+	rh.recorded++
+}
+
+// Query returns at most limit records matching filter, newest first, after skipping the offset first ones
+func (rh *RequestHistory) Query(filter RequestFilter, offset, limit int) RequestsReport {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	report := RequestsReport{Capacity: cap(rh.records), Recorded: rh.recorded, Offset: offset, Limit: limit, Requests: []RequestRecord{}}
+	for i := 1; i <= len(rh.records); i++ {
+		rec := rh.records[(rh.next-i+len(rh.records))%len(rh.records)]
+		if !filter.Match(rec) {
+			continue
+		}
+		if report.Matched >= offset && len(report.Requests) < limit {
+			report.Requests = append(report.Requests, rec)
+		}
+		report.Matched++
+	}
+	return report
+}
+
+// recordRequests is the Middleware adding each request served to the history and to the request store
+func (s *GoHttpServer) recordRequests() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			if sw.status == 0 {
+				sw.status = http.StatusOK
+			}
+			rec := RequestRecord{
+				Time:       start.UTC(),
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Status:     sw.status,
+				Bytes:      sw.bytes,
+				DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+				ClientIp:   ParseRemoteAddr(r.RemoteAddr).RemoteIp,
+				RequestId:  RequestIdFromContext(r.Context()),
+			}
+			for _, name := range requestHistoryHeaders {
+				if val := r.Header.Get(name); val != "" {
+					if rec.Headers == nil {
+						rec.Headers = make(map[string]string)
+					}
+					rec.Headers[name] = val
+				}
+			}
+			if s.history != nil {
+				s.history.Add(rec)
+			}
+			if s.requestStore != nil {
+				s.requestStore.record(rec)
+			}
+		})
+	}
+}
+
+// parseRequestFilter returns the filter given by the query parameters of /requests
+func parseRequestFilter(r *http.Request) (RequestFilter, error) {
+	query := r.URL.Query()
+	filter := RequestFilter{Method: query.Get("method"), PathPrefix: query.Get("path"), ClientIp: query.Get("ip")}
+	if val := query.Get("status"); val != "" {
+		if len(val) == 3 && strings.HasSuffix(strings.ToLower(val), "xx") && val[0] >= '1' && val[0] <= '5' {
+			filter.StatusClass = int(val[0] - '0')
+		} else if code, err := strconv.Atoi(val); err == nil && code >= 100 && code <= 599 {
+			filter.Status = code
+		} else {
+			return filter, fmt.Errorf("parameter status should be an http status like 404 or a class like 5xx, got %q", val)
+		}
+	}
+	if val := query.Get("min_duration"); val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil || d < 0 {
+			return filter, fmt.Errorf("parameter min_duration should be a duration like 250ms, got %q", val)
+		}
+		filter.MinDuration = d
+	}
+	return filter, nil
+}
+
+//############# BEGIN REQUESTS HANDLERS
+
+// RequestsHandler returns the last requests served by this server, newest first, filtered by the method, path
+// prefix, status, ip and min_duration parameters and paginated with offset and limit
+func (s *GoHttpServer) RequestsHandler(history *RequestHistory) http.HandlerFunc {
+	handlerName := "RequestsHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseRequestFilter(r)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		capacity := history.Capacity()
+		offset, err := parseIntParam(r, "offset", 0, 0, capacity)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit, err := parseIntParam(r, "limit", min(defaultRequestsPageSize, capacity), 1, capacity)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.render(w, r, http.StatusOK, history.Query(filter, offset, limit))
+	}
+}
+
+// ############# END REQUESTS HANDLERS
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestHistoryQuery(t *testing.T) {
+	history := NewRequestHistory(3)
+	for i, status := range []int{200, 404, 500, 200} {
+		history.Add(RequestRecord{Method: http.MethodGet, Path: "/p" + string(rune('a'+i)), Status: status, DurationMs: float64(i * 100), ClientIp: "10.0.0.1"})
+	}
+	tests := []struct {
+		name        string
+		filter      RequestFilter
+		offset      int
+		limit       int
+		wantPaths   []string
+		wantMatched int
+	}{
+		{name: "1: empty filter should return the kept requests newest first", limit: 10, wantPaths: []string{"/pd", "/pc", "/pb"}, wantMatched: 3},
+		{name: "2: limit and offset should paginate", offset: 1, limit: 1, wantPaths: []string{"/pc"}, wantMatched: 3},
+		{name: "3: status class should filter", filter: RequestFilter{StatusClass: 4}, limit: 10, wantPaths: []string{"/pb"}, wantMatched: 1},
+		{name: "4: exact status should filter", filter: RequestFilter{Status: 500}, limit: 10, wantPaths: []string{"/pc"}, wantMatched: 1},
+		{name: "5: min duration should filter", filter: RequestFilter{MinDuration: 250 * time.Millisecond}, limit: 10, wantPaths: []string{"/pd"}, wantMatched: 1},
+		{name: "6: unknown ip should match nothing", filter: RequestFilter{ClientIp: "10.0.0.2"}, limit: 10, wantPaths: []string{}, wantMatched: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := history.Query(tt.filter, tt.offset, tt.limit)
+			paths := []string{}
+			for _, rec := range report.Requests {
+				paths = append(paths, rec.Path)
+			}
+			assert.Equal(t, tt.wantPaths, paths)
+			assert.Equal(t, tt.wantMatched, report.Matched)
+			assert.Equal(t, uint64(4), report.Recorded, "the overwritten requests should stay counted")
+			assert.Equal(t, 3, report.Capacity)
+		})
+	}
+}
+
+func TestGoHttpServerRequestsHandler(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/time?secret=1", nil)
+	req.Header.Set("Authorization", "Bearer hidden")
+	req.Header.Set("User-Agent", "history-test")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	tests := []struct {
+		name           string
+		url            string
+		wantStatusCode int
+		wantCount      int
+	}{
+		{name: "1: path filter should return the request", url: "/requests?path=/time&status=2xx", wantStatusCode: http.StatusOK, wantCount: 1},
+		{name: "2: status filter should exclude the request", url: "/requests?path=/time&status=404", wantStatusCode: http.StatusOK, wantCount: 0},
+		{name: "3: invalid status should be a bad request", url: "/requests?status=6xx", wantStatusCode: http.StatusBadRequest},
+		{name: "4: invalid min_duration should be a bad request", url: "/requests?min_duration=long", wantStatusCode: http.StatusBadRequest},
+		{name: "5: limit above the capacity should be a bad request", url: "/requests?limit=100000", wantStatusCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode, assertCorrectStatusCodeExpected)
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+			var report RequestsReport
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report), "the output should be a valid json")
+			if assert.Len(t, report.Requests, tt.wantCount) && tt.wantCount > 0 {
+				rec := report.Requests[0]
+				assert.Equal(t, "/time", rec.Path, "the query should not be recorded")
+				assert.Equal(t, "history-test", rec.Headers["User-Agent"])
+				assert.NotContains(t, rec.Headers, "Authorization", "the credentials should not be recorded")
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRequestStoreFromConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		retention time.Duration
+		want      *RequestStoreConfig
+	}{
+		{name: "1: no database_url should keep the requests in memory only", retention: time.Hour},
+		{name: "2: postgres url should be given as is to the driver", url: "postgres://go:secret@db:5432/info?sslmode=disable", retention: time.Hour,
+			want: &RequestStoreConfig{Dialect: "postgres", Dsn: "postgres://go:secret@db:5432/info?sslmode=disable", Retention: time.Hour}},
+		{name: "3: sqlite url should give the path of the file", url: "sqlite:///data/requests.db", retention: 72 * time.Hour,
+			want: &RequestStoreConfig{Dialect: "sqlite", Dsn: "/data/requests.db", Retention: 72 * time.Hour}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.DefaultConfig()
+			settings.DatabaseUrl, settings.StoreRetention = tt.url, tt.retention
+			assert.Equal(t, tt.want, GetRequestStoreFromConfig(settings))
+		})
+	}
+}
+
+func TestOpenRequestStoreUnsupportedDialect(t *testing.T) {
+	_, err := OpenRequestStore(context.Background(), RequestStoreConfig{Dialect: "mysql", Dsn: "go:secret@tcp(db)/info"}, getTestLogger())
+	assert.ErrorContains(t, err, "unsupported request store dialect")
+}
+
+func TestRequestStoreSqlite(t *testing.T) {
+	ctx := context.Background()
+	store, err := OpenRequestStore(ctx, RequestStoreConfig{Dialect: "sqlite", Dsn: t.TempDir() + "/requests.db", Retention: time.Hour}, getTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	store.record(RequestRecord{Time: now.Add(-2 * time.Hour), Method: "GET", Path: "/time", Status: 200, Bytes: 10, ClientIp: "192.0.2.1"})
+	store.record(RequestRecord{Time: now.Add(-time.Minute), Method: "GET", Path: "/headers", Status: 200, Bytes: 20, DurationMs: 1.5,
+		ClientIp: "192.0.2.2", RequestId: "req-1", Headers: map[string]string{"User-Agent": "curl/8.0"}})
+	store.record(RequestRecord{Time: now, Method: "POST", Path: "/hello_world", Status: 404, ClientIp: "192.0.2.3"})
+	assert.NoError(t, store.Flush(ctx))
+
+	all, err := store.Query(ctx, RequestStoreQuery{From: now.Add(-3 * time.Hour), To: now, Limit: 10})
+	if assert.NoError(t, err) && assert.Len(t, all, 3) {
+		assert.Equal(t, "/hello_world", all[0].Path, "the newest record should be first")
+		assert.Equal(t, RequestRecord{Time: now.Add(-time.Minute), Hostname: store.hostname, Method: "GET", Path: "/headers", Status: 200, Bytes: 20,
+			DurationMs: 1.5, ClientIp: "192.0.2.2", RequestId: "req-1", Headers: map[string]string{"User-Agent": "curl/8.0"}}, all[1])
+	}
+	filtered, err := store.Query(ctx, RequestStoreQuery{From: now.Add(-time.Hour), To: now, Method: "get", PathPrefix: "/h", Limit: 10})
+	if assert.NoError(t, err) && assert.Len(t, filtered, 1) {
+		assert.Equal(t, "req-1", filtered[0].RequestId)
+	}
+	paged, err := store.Query(ctx, RequestStoreQuery{From: now.Add(-3 * time.Hour), To: now, Status: 200, Offset: 1, Limit: 1})
+	if assert.NoError(t, err) && assert.Len(t, paged, 1) {
+		assert.Equal(t, "/time", paged[0].Path)
+	}
+
+	deleted, err := store.Purge(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted, "the record older than the retention should be deleted")
+}
+
+func TestBuildRequestQuery(t *testing.T) {
+	from := time.UnixMilli(1000)
+	to := time.UnixMilli(2000)
+	tests := []struct {
+		name      string
+		dialect   string
+		query     RequestStoreQuery
+		wantWhere string
+		wantArgs  []interface{}
+	}{
+		{name: "1: time range alone should be filtered", dialect: "sqlite", query: RequestStoreQuery{From: from, To: to, Limit: 10},
+			wantWhere: "WHERE time_ms >= ? AND time_ms <= ? ORDER BY", wantArgs: []interface{}{int64(1000), int64(2000), 10, 0}},
+		{name: "2: every filter should be added in postgres placeholders", dialect: "postgres",
+			query:     RequestStoreQuery{From: from, To: to, Method: "get", PathPrefix: "/info_%", Status: 404, Hostname: "pod-1", Offset: 5, Limit: 10},
+			wantWhere: `WHERE time_ms >= $1 AND time_ms <= $2 AND method = $3 AND path LIKE $4 ESCAPE '\' AND status = $5 AND hostname = $6 ORDER BY`,
+			wantArgs:  []interface{}{int64(1000), int64(2000), "GET", `/info\_\%%`, 404, "pod-1", 10, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := buildRequestQuery(tt.dialect, tt.query)
+			assert.Contains(t, query, tt.wantWhere)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+func TestGoHttpServerRequestsQueryHandlerParams(t *testing.T) {
+	myServer := &GoHttpServer{logger: getTestLogger()}
+	handler := myServer.RequestsQueryHandler(&RequestStore{dialect: "sqlite"})
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "1: invalid from should be a bad request", url: "/requests/query?from=yesterday"},
+		{name: "2: to before from should be a bad request", url: "/requests/query?from=1h&to=2h"},
+		{name: "3: invalid status should be a bad request", url: "/requests/query?status=42"},
+		{name: "4: too big limit should be a bad request", url: "/requests/query?limit=5000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			assert.Equal(t, http.StatusBadRequest, rec.Code, assertCorrectStatusCodeExpected)
+		})
+	}
+}
+
+func TestParseTimeParam(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	def := now.Add(-time.Hour)
+	for _, tt := range []struct {
+		name    string
+		val     string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "1: missing parameter should give the default", want: def},
+		{name: "2: RFC 3339 time should be parsed", val: "2024-05-01T10:30:00Z", want: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)},
+		{name: "3: duration should be before now", val: "30m", want: now.Add(-30 * time.Minute)},
+		{name: "4: negative duration should be an error", val: "-30m", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/requests/query?from="+tt.val, nil)
+			got, err := parseTimeParam(r, "from", now, def)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if err == nil {
+				assert.True(t, tt.want.Equal(got), "got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
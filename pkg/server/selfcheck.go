@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultCheckPaths are the probes requested by the check command, like the liveness and readiness probes of the kubelet
+var DefaultCheckPaths = []string{"/health", "/readiness"}
+
+// EndpointCheck is the answer of an endpoint of a running server to the check command
+type EndpointCheck struct {
+	Url      string        `json:"url"`
+	Status   int           `json:"status,omitempty"` // omitted when the request failed
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Ok returns true when the endpoint answered with a 2xx status, like a http probe of the kubelet
+func (c EndpointCheck) Ok() bool {
+	return c.Error == "" && c.Status >= http.StatusOK && c.Status < http.StatusMultipleChoices
+}
+
+// CheckEndpoints requests each path of paths on the server at baseUrl with client, and returns their answers in the
+// order of paths. a failing endpoint does not stop the checks of the next ones
+func CheckEndpoints(ctx context.Context, client *http.Client, baseUrl string, paths []string) []EndpointCheck {
+	checks := make([]EndpointCheck, 0, len(paths))
+	for _, path := range paths {
+		check := EndpointCheck{Url: strings.TrimSuffix(baseUrl, "/") + "/" + strings.TrimPrefix(path, "/")}
+		start := time.Now()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.Url, nil)
+		if err == nil {
+			var resp *http.Response
+			if resp, err = client.Do(req); err == nil {
+				check.Status = resp.StatusCode
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+		}
+		check.Duration = time.Since(start)
+		if err != nil {
+			check.Error = err.Error()
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
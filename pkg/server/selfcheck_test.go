@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckEndpoints(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		case "/readiness":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	client := &http.Client{Timeout: 2 * time.Second}
+	checks := CheckEndpoints(context.Background(), client, ts.URL+"/", []string{"health", "/readiness", "/missing"})
+	if !assert.Len(t, checks, 3, "every path should be checked even after a failure") {
+		return
+	}
+	tests := []struct {
+		name       string
+		check      EndpointCheck
+		wantUrl    string
+		wantStatus int
+		wantOk     bool
+	}{
+		{name: "1: a 200 should be ok", check: checks[0], wantUrl: ts.URL + "/health", wantStatus: http.StatusOK, wantOk: true},
+		{name: "2: a 503 should fail", check: checks[1], wantUrl: ts.URL + "/readiness", wantStatus: http.StatusServiceUnavailable},
+		{name: "3: a 404 should fail", check: checks[2], wantUrl: ts.URL + "/missing", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantUrl, tt.check.Url)
+			assert.Equal(t, tt.wantStatus, tt.check.Status)
+			assert.Equal(t, tt.wantOk, tt.check.Ok())
+			assert.Empty(t, tt.check.Error)
+		})
+	}
+	ts.Close()
+	down := CheckEndpoints(context.Background(), client, ts.URL, DefaultCheckPaths)
+	if assert.Len(t, down, 2) {
+		assert.NotEmpty(t, down[0].Error, "a server which is down should be reported")
+		assert.False(t, down[0].Ok())
+	}
+}
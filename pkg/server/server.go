@@ -0,0 +1,1173 @@
+// Package server contains GoHttpServer and the handlers of the go-cloud-k8s-info endpoints, it can be embedded in other programs
+// which mount the exported handler constructors on their own mux. the admin handlers like ProbeToggleHandler,
+// AdminReadyHandler and AdminGcHandler change the state of the server and do not check any credentials themselves,
+// the mux mounting them must protect them like handleRoute does with requireCredentials
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+const (
+	defaultProtocol        = "http"
+	defaultServerPath      = "/"
+	defaultNotFound        = "🤔 ℍ𝕞𝕞... 𝕤𝕠𝕣𝕣𝕪 :【𝟜𝟘𝟜 : ℙ𝕒𝕘𝕖 ℕ𝕠𝕥 𝔽𝕠𝕦𝕟𝕕】🕳️ 🔥"
+	htmlHeaderStart        = `<!DOCTYPE html><html lang="en"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/skeleton/2.0.4/skeleton.min.css"/>`
+	charsetUTF8            = "charset=UTF-8"
+	MIMEAppJSON            = "application/json"
+	MIMEAppJSONCharsetUTF8 = MIMEAppJSON + "; " + charsetUTF8
+	HeaderContentType      = "Content-Type"
+	httpErrMethodNotAllow  = "ERROR: Http method not allowed"
+	initCallMsg            = "INITIAL CALL TO handler constructor"
+)
+
+func getHtmlHeader(title string) string {
+	return fmt.Sprintf("%s<title>%s</title></head>", htmlHeaderStart, title)
+}
+
+func getHtmlPage(title string) string {
+	return getHtmlHeader(title) +
+		fmt.Sprintf("\n<body><div class=\"container\"><h3>%s</h3></div></body></html>", title)
+}
+
+// WaitForHttpServer attempts to establish a TCP connection to listenAddress
+// in a given amount of time. It returns upon a successful connection;
+// otherwise exits with an error.
+func WaitForHttpServer(listenAddress string, waitDuration time.Duration, numRetries int) {
+	httpClient := http.Client{
+		Timeout: 5 * time.Second,
+	}
+	for i := 0; i < numRetries; i++ {
+		//conn, err := net.DialTimeout("tcp", listenAddress, dialTimeout)
+		resp, err := httpClient.Get(listenAddress)
+		if err != nil {
+			fmt.Printf("\n[%d] Cannot make http get %s: %v\n", i, listenAddress, err)
+			time.Sleep(waitDuration)
+			continue
+		}
+		// All seems is good
+		fmt.Printf("OK: Server responded after %d retries, with status code %d ", i, resp.StatusCode)
+		return
+	}
+	log.Fatalf("Server %s not ready up after %d attempts", listenAddress, numRetries)
+}
+
+// waitForShutdown will wait for interrupt signal SIGINT or SIGTERM and gracefully shutdown the server after secondsToWait seconds.
+// it returns the error of serveErrors instead when the server stops by itself before receiving a signal.
+func waitForShutdown(srv *http.Server, logger *slog.Logger, readiness *ReadinessRunner, hooks func() []ShutdownHook, interrupts <-chan os.Signal, serveErrors <-chan error, preStopDelay, secondsToWait time.Duration) error {
+	// Block until a signal is received.
+	// wait for SIGINT (interrupt) 	: ctrl + C keypress, or in a shell : kill -SIGINT processId
+	select {
+	case sig := <-interrupts:
+		drainAndShutdown(srv, logger, readiness, hooks(), sig, preStopDelay, secondsToWait)
+		logger.Info("Server gracefully stopped")
+		return nil
+	case err := <-serveErrors:
+		logger.Error("Server stopped unexpectedly", "address", srv.Addr, "error", err)
+		hooksCtx, cancelHooks := context.WithTimeout(context.Background(), secondsToWait)
+		defer cancelHooks()
+		runShutdownHooks(hooksCtx, logger, hooks())
+		return err
+	}
+}
+
+// drainAndShutdown stops the server after a signal. on SIGTERM, sent by the kubelet before killing the pod, the readiness
+// is flipped to 503 first and the server keeps serving during preStopDelay, so the endpoint controller has time to remove
+// the pod from the services before we stop accepting connections. SIGINT shuts down immediately.
+// the shutdown hooks run after, with their own secondsToWait so that a slow request does not shorten the cleanup.
+func drainAndShutdown(srv *http.Server, logger *slog.Logger, readiness *ReadinessRunner, hooks []ShutdownHook, sig os.Signal, preStopDelay, secondsToWait time.Duration) {
+	readiness.StartDraining()
+	if sig == syscall.SIGTERM && preStopDelay > 0 {
+		logger.Info("SIGTERM received, readiness is now failing, draining before shutdown", "pre_stop_delay_seconds", preStopDelay.Seconds())
+		time.Sleep(preStopDelay)
+	}
+	logger.Info("interrupt signal received, about to shut down server", "signal", sig.String(), "max_wait_seconds", secondsToWait.Seconds())
+
+	// create a deadline to wait for.
+	ctx, cancel := context.WithTimeout(context.Background(), secondsToWait)
+	defer cancel()
+	// gracefully shuts down the server without interrupting any active connections
+	// as long as the actives connections last less than shutDownTimeout
+	// https://pkg.go.dev/net/http#Server.Shutdown
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("Problem doing Shutdown", "error", err)
+	}
+	if len(hooks) > 0 {
+		hooksCtx, cancelHooks := context.WithTimeout(context.Background(), secondsToWait)
+		defer cancelHooks()
+		runShutdownHooks(hooksCtx, logger, hooks)
+	}
+}
+
+// GoHttpServer is a struct type to store information related to all handlers of web server
+type GoHttpServer struct {
+	listenAddress   string
+	middlewares     []Middleware // applied to every request before the routing
+	logger          *slog.Logger
+	router          *http.ServeMux
+	startTime       time.Time
+	httpServer      http.Server
+	apiToken        string            // bearer token needed to request an access_token, protection is disabled when empty
+	tokens          *AccessTokenStore // one-shot download tokens
+	rdns            *ReverseDnsCache  // reverse dns names of clients
+	metrics         *Metrics          // prometheus metrics of all routes
+	k8s             *K8sClient        // k8s api client, nil when not running in a pod with a service account
+	certs           *CertReloader     // tls certificates, nil when serving plain http
+	readiness       *ReadinessRunner  // checks run by /readiness
+	liveness        *ReadinessRunner  // self checks run by /health
+	preStopDelay    time.Duration     // time to keep serving after SIGTERM while readiness fails
+	startup         *StartupGate      // simulated initialization reported by /started
+	healthToggle    *ProbeToggle      // liveness check switched by /admin/health
+	readyToggle     *ProbeToggle      // readiness check switched by /admin/readiness
+	history         *RequestHistory   // last requests served, shown by /requests, nil when disabled
+	requestStore    *RequestStore     // database persisting the requests served, nil without DATABASE_URL
+	renderTemplate  *RenderTemplate   // template of /render, nil without RENDER_TEMPLATE_FILE
+	banner          *Banner           // INFO_MESSAGE and BANNER_FILE shown by / and the dashboard
+	gc              *GcTuner          // gc parameters and heap ballast changed by /admin/gc
+	waiters         WaiterPool        // requests sleeping in /wait
+	instance        *InstanceInfo     // DEPLOY_TRACK and COLOR sent in X-Instance-Info and the json answers, nil when not set
+	envelope        SourceEnvelope    // source of the json answers wrapped when response_envelope is true
+	shutdownTimeout time.Duration     // maximum time to wait for the active requests on shutdown
+	pprofEnabled    bool              // profiling endpoints are mounted under /debug/pprof/
+	pprofServer     *http.Server      // dedicated listener for pprof, nil when pprof is served by the main router
+	adminRouter     *http.ServeMux    // routes of the admin port, nil when they are served by the main router
+	adminServer     *http.Server      // internal listener for health, readiness, metrics and pprof, nil without ADMIN_PORT
+	grpcAddress     string            // tcp address of the grpc services, empty without GRPC_PORT
+	grpcServer      *GrpcServer       // grpc listener, nil until started
+	socketPath      string            // unix socket served besides the tcp port, empty without LISTEN_SOCKET
+	socketMode      os.FileMode       // permissions of the unix socket
+	socketOnly      bool              // the tcp port is not opened, only the unix socket
+	extraListen     string            // EXTRA_LISTEN, additional addresses served by the handlers of the main port
+	http3Address    string            // udp address of the HTTP/3 listener advertised with Alt-Svc, empty without HTTP3_PORT
+	http3Server     Http3Server       // HTTP/3 listener, nil until started with TLS
+	apiRoutes       []ApiRoute        // routes registered with handleRoute, described by /openapi.json
+	hooksMu         sync.Mutex        // protects shutdownHooks
+	shutdownHooks   []ShutdownHook    // cleanup functions run by the graceful shutdown, registered with OnShutdown
+	tracer          *Tracer           // exports the spans of the requests, nil when tracing is not configured
+	reporter        *Reporter         // pushes the RuntimeInfo to the collector of REPORT_URL, nil without UseReporter
+	envRedactor     *EnvRedactor      // masks the secrets in the env variables shown to the clients
+	auth            AuthConfig        // credentials protecting the info routes, set by UseAuth
+	jwt             *JwtValidator     // validates the bearer jwt when the auth mode is jwt, nil otherwise
+	dnsResolver     *net.Resolver     // resolver used by /dns
+	dnsServer       string            // address of the dns server used by /dns, system when using resolv.conf
+	connector       *Connector        // outbound connections of /connect, /certcheck and /proxy, nil when CONNECT_ALLOWLIST is empty
+	settings        *ConfigReloader   // active configuration, reloaded while running when configReload is true
+	configReload    bool              // reload the configuration on SIGHUP or when its file changes
+	binaryReload    bool              // start the new binary on the main listener on SIGUSR2, BINARY_RELOAD
+	pidFile         string            // PID_FILE, where the pid of the serving process is written
+	upgradedPid     atomic.Int64      // pid of the new binary after a binary reload, 0 before
+	accessLog       *AccessLogger     // one line per request in the Apache format, nil when ACCESS_LOG is empty
+	compression     *Compression      // gzip or deflate compression of the responses, nil when disabled
+	rateLimiter     *RateLimiter      // requests allowed per client ip, nil when RATE_LIMIT_RPS is 0
+	trustedProxies  []*net.IPNet      // proxies allowed to give the client ip in X-Forwarded-For or the PROXY protocol
+	ipAccess        *IpAccessList     // client ips allowed on the main port, nil without IP_ALLOWLIST and IP_DENYLIST
+	proxyProtocol   bool              // read the PROXY protocol header of the connections of the trusted proxies
+	cloud           *CloudDetector    // cloud provider of the instance, from the metadata services
+	leader          *LeaderElector    // leader election among the replicas, nil without LEADER_ELECTION or outside k8s
+	configMaps      *ConfigMapWatcher // updates of the configMap volumes of /k8s/configmap-events, nil without CONFIGMAP_WATCH
+	prober          *Prober           // status of the urls of /probe/status, nil without PROBE_TARGETS
+	probeInterval   time.Duration
+	uploads         *UploadStore      // files of /upload, nil without UPLOAD_DIR
+	k8sEvents       *K8sEventRecorder // k8s Events attached to the pod, nil outside k8s or when K8S_EVENTS is false
+	webhooks        *WebhookNotifier  // lifecycle events posted to WEBHOOK_URLS, nil without UseWebhooks
+	dependencies    *DependencyWaiter // dependencies of WAIT_FOR, nil without UseDependencyWaiter
+	lastReadiness   atomic.Value      // status of the previous /readiness, to notify its flips
+	preemption      *SpotWatcher      // spot or preemptible instance notice, nil when PREEMPTION_WATCH is false
+	chaos           *Chaos            // failures injected by /chaos, only reachable when enable_chaos is true
+	load            *LoadGenerator    // cpu and memory load of /load, only reachable when enable_load is true
+	diskBench       *DiskBenchmark    // storage benchmark of /bench/disk, only reachable when enable_load is true
+	netBench        *NetBenchmark     // network benchmark of /bench/net, nil when CONNECT_ALLOWLIST is empty
+	cluster         *ClusterInspector // sibling pods of /cluster, nil when CLUSTER_SERVICE is empty
+	interrupts      chan os.Signal    // SIGINT and SIGTERM, or Stop, start the graceful shutdown of StartServer
+}
+
+// NewGoHttpServer is a constructor that initializes the server mux (routes) and all fields of the  GoHttpServer type,
+// with the configuration given by the env variables
+func NewGoHttpServer(listenAddress string, logger *slog.Logger) *GoHttpServer {
+	settings, err := config.GetConfigFromEnv()
+	if err != nil {
+		logger.Error("GetConfigFromEnv() returned an error, using the default configuration", "error", err)
+		settings = config.DefaultConfig()
+	}
+	return NewGoHttpServerWithConfig(listenAddress, settings, logger)
+}
+
+// NewGoHttpServerWithConfig is a constructor that initializes the server mux (routes) and all fields of the GoHttpServer type
+// with the given validated configuration
+func NewGoHttpServerWithConfig(listenAddress string, config config.Config, logger *slog.Logger) *GoHttpServer {
+	myServerMux := http.NewServeMux()
+	var tracer *Tracer
+	if otlpConfig := GetOtlpConfigFromConfig(config); otlpConfig != nil {
+		var err error
+		if tracer, err = NewTracer(*otlpConfig); err != nil {
+			logger.Error("NewTracer() returned an error, tracing is disabled", "error", err, "endpoint", otlpConfig.Endpoint)
+		}
+	}
+	envRedactor, err := GetEnvRedactorFromConfig(config)
+	if err != nil {
+		logger.Error("GetEnvRedactorFromConfig() returned an error, using the default redaction", "error", err)
+	}
+	dnsResolver, dnsServer := GetDnsResolverFromConfig(config)
+	connectAllowlist, err := GetConnectAllowlistFromConfig(config)
+	if err != nil {
+		logger.Error("GetConnectAllowlistFromConfig() returned an error, /connect is disabled", "error", err)
+	}
+	renderTemplate, err := GetRenderTemplateFromConfig(config)
+	if err != nil {
+		logger.Error("GetRenderTemplateFromConfig() returned an error, /render is disabled", "error", err)
+	}
+	k8sClient, err := NewK8sClientInCluster(info.DefaultK8sServiceAccountPath)
+	if err != nil {
+		logger.Info("NOTICE: k8s api client not available, /k8s endpoints are disabled", "error", err)
+	}
+	myServer := GoHttpServer{
+		listenAddress: listenAddress,
+		logger:        logger,
+		router:        myServerMux,
+		startTime:     time.Now(),
+		httpServer: http.Server{
+			Addr:         listenAddress,                                        // configure the bind address
+			Handler:      myServerMux,                                          // set the http mux
+			ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError), // set the logger for the server
+			ReadTimeout:  config.ReadTimeout,                                   // max time to read request from the client
+			WriteTimeout: config.WriteTimeout,                                  // max time to write response to the client
+			IdleTimeout:  config.IdleTimeout,                                   // max time for connections using TCP Keep-Alive
+			Protocols:    newProtocols(config.Http2),                           // HTTP/2 over TLS and h2c
+		},
+		apiToken:        config.ApiToken,
+		tokens:          NewAccessTokenStore(config.AccessTokenTtl, defaultAccessTokenMaxStored),
+		rdns:            NewReverseDnsCache(net.DefaultResolver.LookupAddr),
+		metrics:         NewMetrics(),
+		k8s:             k8sClient,
+		readiness:       NewReadinessRunner(defaultReadinessCheckTimeout),
+		liveness:        NewReadinessRunner(defaultLivenessCheckTimeout),
+		preStopDelay:    config.PreStopDelay,
+		shutdownTimeout: config.ShutdownTimeout,
+		settings:        NewConfigReloader(config, logger),
+		cloud:           NewCloudDetector(defaultCloudMetadataUrl, defaultCloudProbeTimeout),
+		chaos:           NewChaos(os.Exit),
+		load:            NewLoadGenerator(runtime.NumCPU(), defaultProcSelfStat, info.DefaultCgroupRoot),
+		diskBench:       NewDiskBenchmark(maxDiskBenchBytes),
+		renderTemplate:  renderTemplate,
+		banner:          NewBanner(logger),
+		gc:              NewGcTuner(config.HeapBallastMb),
+		pprofEnabled:    config.EnablePprof,
+		tracer:          tracer,
+		envRedactor:     envRedactor,
+		dnsResolver:     dnsResolver,
+		dnsServer:       dnsServer,
+		interrupts:      make(chan os.Signal, 1),
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		myServer.instance = NewInstanceInfo(config, hostname)
+		myServer.envelope = NewSourceEnvelope(myServer.instance, hostname, info.GetK8sDownwardInfo(os.LookupEnv, info.DefaultK8sServiceAccountPath))
+	}
+	if !connectAllowlist.Empty() {
+		myServer.connector = NewConnector(connectAllowlist, dnsResolver)
+		myServer.netBench = NewNetBenchmark(myServer.connector, maxNetBenchBytes)
+	}
+	if clusterDiscovery := GetClusterDiscoveryFromConfig(config); clusterDiscovery != nil {
+		if myServer.cluster, err = NewClusterInspector(*clusterDiscovery, dnsResolver, k8sClient); err != nil {
+			logger.Error("NewClusterInspector() returned an error, /cluster is disabled", "error", err)
+		}
+	}
+	myServer.trustedProxies = config.TrustedProxyNets()
+	myServer.ipAccess = NewIpAccessList(config.IpAccessNets())
+	myServer.proxyProtocol = config.ProxyProtocol
+	if config.RateLimitRps > 0 {
+		myServer.rateLimiter = NewRateLimiter(config.RateLimitRps, config.RateLimitBurst)
+	}
+	if config.RequestHistory > 0 {
+		myServer.history = NewRequestHistory(config.RequestHistory)
+	}
+	if requestStoreConfig := GetRequestStoreFromConfig(config); requestStoreConfig != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), config.ReadTimeout)
+		myServer.requestStore, err = OpenRequestStore(ctx, *requestStoreConfig, logger)
+		cancel()
+		if err != nil {
+			logger.Error("OpenRequestStore() returned an error, /requests/query is disabled", "error", err, "dialect", requestStoreConfig.Dialect)
+		} else {
+			// the last records are written before exiting
+			myServer.OnShutdown(myServer.requestStore.Flush)
+		}
+	}
+	if config.Compression {
+		myServer.compression = NewCompression(config.CompressMin, config.CompressTypes)
+	}
+	if config.AccessLog != "" {
+		w, err := OpenAccessLog(config.AccessLog)
+		if err != nil {
+			logger.Error("OpenAccessLog() returned an error, the access log is disabled", "error", err, "access_log", config.AccessLog)
+		} else {
+			myServer.accessLog = NewAccessLogger(w, config.AccessLogFormat)
+		}
+	}
+	if config.PprofAddress() != "" {
+		myServer.pprofServer = newPprofServer(config.PprofAddress(), myServer.pprofHandler(), logger)
+	}
+	if config.AdminAddress() != "" {
+		myServer.adminRouter = http.NewServeMux()
+		myServer.adminServer = newAdminServer(config.AdminAddress(), myServer.adminRouter, logger)
+	}
+	myServer.grpcAddress = config.GrpcAddress()
+	myServer.http3Address = config.Http3Address()
+	myServer.socketPath, myServer.socketMode, myServer.socketOnly = config.ListenSocket, config.SocketFileMode(), config.SocketOnly
+	myServer.extraListen = config.ExtraListen
+	myServer.binaryReload, myServer.pidFile = config.BinaryReload, config.PidFile
+	myServer.healthToggle = NewProbeToggle("health", myServer.liveness)
+	myServer.readyToggle = NewProbeToggle("readiness", myServer.readiness)
+	myServer.startup = NewStartupGate(myServer.startTime, config.StartupDelay)
+	if config.StartupDelay > 0 {
+		myServer.readiness.Register(&StartupCheck{Gate: myServer.startup})
+	}
+	if config.LeaderElection != "" {
+		if k8sClient == nil {
+			logger.Warn("leader election needs the k8s api, /leader is disabled", "lease", config.LeaderElection)
+		} else {
+			myServer.leader = NewLeaderElector(k8sClient, config.LeaderElection, GetPodName(), config.LeaderLease, logger)
+		}
+	}
+	if config.K8sEvents && k8sClient != nil {
+		myServer.k8sEvents = NewK8sEventRecorder(k8sClient, GetPodName(), logger)
+		myServer.OnShutdown(myServer.k8sEvents.Stop("ShuttingDown", "the server stopped accepting requests after a signal"))
+	}
+	if paths := config.ConfigMapPaths(); len(paths) > 0 {
+		myServer.configMaps = NewConfigMapWatcher(paths, defaultMountInfoPath, logger)
+	}
+	if urls := config.ProbeUrls(); len(urls) > 0 {
+		myServer.prober, myServer.probeInterval = NewProber(urls, defaultProbeTimeout, logger), config.ProbeInterval
+	}
+	if config.UploadDir != "" {
+		if myServer.uploads, err = NewUploadStore(config.UploadDir, config.UploadTtl, int64(config.UploadMaxMb)<<20, int64(config.UploadQuotaMb)<<20, logger); err != nil {
+			logger.Error("NewUploadStore() returned an error, /upload is disabled", "error", err, "dir", config.UploadDir)
+		}
+	}
+	if config.PreemptionWatch {
+		myServer.preemption = NewSpotWatcher(myServer.cloud, logger)
+		if config.PreemptionReady {
+			myServer.readiness.Register(&PreemptionCheck{Watcher: myServer.preemption})
+		}
+	}
+	if tracer != nil {
+		// the spans of the last requests are exported before exiting
+		myServer.OnShutdown(tracer.Shutdown)
+	}
+	myServer.liveness.Register(myServer.livenessChecks(config)...)
+	// AUTH_MODE is known before the routes, POST /token is only registered when there are credentials to check
+	myServer.UseAuth(GetAuthConfigFromConfig(config))
+	myServer.routes()
+
+	return &myServer
+}
+
+// (*GoHttpServer) handle registers the handler for the path, wrapped by the tracing, metrics and logging middlewares
+// followed by the given route specific middlewares
+func (s *GoHttpServer) handle(path string, handler http.Handler, middlewares ...Middleware) {
+	s.handleOn(s.router, path, handler, middlewares...)
+}
+
+// (*GoHttpServer) handleOn registers the handler for the path on mux, with the same middlewares as handle
+func (s *GoHttpServer) handleOn(mux *http.ServeMux, path string, handler http.Handler, middlewares ...Middleware) {
+	middlewares = append([]Middleware{s.injectFaults()}, middlewares...)
+	if s.http3Address != "" && mux == s.router {
+		middlewares = append([]Middleware{s.advertiseHttp3()}, middlewares...)
+	}
+	if s.rateLimiter != nil && !slices.Contains(rateLimitExemptPaths, path) {
+		middlewares = append([]Middleware{s.limitRate()}, middlewares...)
+	}
+	middlewares = append([]Middleware{s.traceRequests(path), s.instrument(path), s.logRequests(path)}, middlewares...)
+	if s.compression != nil {
+		middlewares = append([]Middleware{s.compress()}, middlewares...)
+	}
+	if s.accessLog != nil {
+		middlewares = append([]Middleware{s.logAccess()}, middlewares...)
+	}
+	if s.history != nil || s.requestStore != nil {
+		middlewares = append([]Middleware{s.recordRequests()}, middlewares...)
+	}
+	middlewares = append([]Middleware{s.requestIds()}, middlewares...)
+	if s.instance != nil {
+		// the instance is added to the answer of the handler, before the compression and the envelope of /api/v1
+		middlewares = append([]Middleware{s.instanceHeader()}, append(middlewares, s.instanceBody())...)
+	}
+	if s.ipAccess != nil && mux == s.router {
+		// the admin port is internal, the probes of the kubelet reaching it are never refused
+		middlewares = append([]Middleware{s.checkIpAccess()}, middlewares...)
+	}
+	if len(s.trustedProxies) > 0 {
+		middlewares = append([]Middleware{s.resolveRealIp()}, middlewares...)
+	}
+	mux.Handle(path, Chain(handler, middlewares...))
+}
+
+// (*GoHttpServer) routes initializes all the handlers paths of this web server, it is called inside the NewGoHttpServer constructor
+func (s *GoHttpServer) routes() {
+	get := []string{http.MethodGet}
+	getOrPost := []string{http.MethodGet, http.MethodPost}
+	asJson := contentType(MIMEAppJSONCharsetUTF8)
+	interval := ApiParam{Name: "interval", Type: "integer", Description: "seconds between two stats, from 1 to 60, 2 by default"}
+	s.handleRoute(ApiRoute{Path: "/", Methods: get, Tag: "info", Auth: true, Response: info.RuntimeInfo{},
+		Summary: "runtime, os, k8s and request information of this server",
+		Params: []ApiParam{
+			{Name: "name", Type: "string", Description: "value returned in param_name"},
+			{Name: "rdns", Type: "boolean", Description: "lookup the reverse dns name of the client"},
+		}}, s.RuntimeInfoHandler())
+	if s.adminRouter != nil {
+		// the route of / is the catch-all of the main router, the admin router needs its own
+		s.handleAdmin(defaultServerPath, s.NotFoundHandler())
+	}
+	if s.renderTemplate != nil {
+		s.handleRoute(ApiRoute{Path: "/render", Methods: get, Tag: "info", Auth: true, ContentType: s.renderTemplate.ContentType,
+			Summary: "runtime information formatted by the template of RENDER_TEMPLATE_FILE",
+			Params: []ApiParam{
+				{Name: "name", Type: "string", Description: "value given to the template in ParamName"},
+			}}, s.RenderHandler(s.renderTemplate))
+	}
+	s.handleRoute(ApiRoute{Path: "/time", Methods: get, Tag: "info", Summary: "current time of the server", Response: TimeResponse{},
+		Params: []ApiParam{
+			{Name: "tz", Type: "string", Description: "time zone like Europe/Zurich, the local zone of the container by default"},
+			{Name: "format", Type: "string", Description: "format of the time field : unix, rfc3339 (default) or rfc1123"},
+		}}, s.TimeHandler(), asJson)
+	s.handleRoute(ApiRoute{Path: "/time/diagnostics", Methods: get, Tag: "info", Auth: true, Response: TimeDiagnostics{},
+		Summary: "wall and monotonic clocks, time zone database and clock offset measured with NTP_SERVER"}, s.TimeDiagnosticsHandler())
+	s.handleRoute(ApiRoute{Path: "/wait", Methods: get, Tag: "test", Summary: "answers after a delay", Response: waitResult{},
+		Params: []ApiParam{
+			{Name: "seconds", Type: "number", Description: "seconds to wait, wait_default by default"},
+			{Name: "jitter", Type: "number", Description: "random seconds added to or removed from the wait"},
+		}}, s.WaitHandler(s.settings.Current), asJson)
+	s.handleRoute(ApiRoute{Path: "/readiness", Methods: get, Tag: "probes", Admin: true, Response: ReadinessReport{},
+		Summary: "readiness checks, 503 when one fails or while draining"}, s.ReadinessHandler())
+	s.handleRoute(ApiRoute{Path: "/dependencies", Methods: get, Tag: "probes", Admin: true, Response: DependencyReport{},
+		Summary: "state of the dependencies of WAIT_FOR, 503 until all are up"}, s.DependenciesHandler())
+	s.handleRoute(ApiRoute{Path: "/started", Methods: get, Tag: "probes", Admin: true, Response: StartupReport{},
+		Summary: "startup probe, 503 during the warm-up of startup_delay"}, s.StartedHandler(s.startup))
+	s.handleRoute(ApiRoute{Path: "/admin/ready", Methods: []string{http.MethodPost}, Tag: "probes", Auth: true, Admin: true, Privileged: true, Response: StartupReport{},
+		Summary: "ends the warm-up, /started succeeds from now on"}, s.AdminReadyHandler(s.startup))
+	s.handleRoute(ApiRoute{Path: "/health", Methods: get, Tag: "probes", Admin: true, Response: ReadinessReport{},
+		Summary: "liveness self checks, 503 when one fails"}, s.HealthHandler())
+	state := ApiParam{Name: "state", Type: "string", Description: "down to make the probe fail, up to restore it", Required: true}
+	s.handleRoute(ApiRoute{Path: "/admin/health", Methods: []string{http.MethodPost}, Tag: "probes", Auth: true, Admin: true, Privileged: true, Response: ProbeToggleReport{},
+		Params: []ApiParam{state}, Summary: "forces /health to fail or restores it"}, s.ProbeToggleHandler(s.healthToggle))
+	s.handleRoute(ApiRoute{Path: "/admin/readiness", Methods: []string{http.MethodPost}, Tag: "probes", Auth: true, Admin: true, Privileged: true, Response: ProbeToggleReport{},
+		Params: []ApiParam{state}, Summary: "forces /readiness to fail or restores it"}, s.ProbeToggleHandler(s.readyToggle))
+	s.handleRoute(ApiRoute{Path: "/buildinfo", Methods: get, Tag: "info", Response: info.BuildInfo{},
+		Summary: "version, git commit and go toolchain of the binary"}, s.BuildInfoHandler())
+	s.handleRoute(ApiRoute{Path: "/metrics", Methods: get, Tag: "probes", Admin: true, ContentType: "text/plain",
+		Summary: "prometheus metrics"}, s.MetricsHandler(), contentType(MIMETextPlainPrometheus))
+	s.handleRoute(ApiRoute{Path: "/stats", Methods: get, Tag: "probes", Admin: true, Response: StatsReport{},
+		Summary: "count, errors and latency percentiles of each route"}, s.StatsHandler())
+	s.handleRoute(ApiRoute{Path: "/echo", Tag: "test", Response: EchoInfo{},
+		Summary: "returns the request as received, whatever its method",
+		Params:  []ApiParam{{Name: "rdns", Type: "boolean", Description: "lookup the reverse dns name of the client"}}}, s.EchoHandler(defaultEchoMaxBodyBytes))
+	s.handleRoute(ApiRoute{Path: "/headers", Methods: get, Tag: "test", Response: HeadersReport{},
+		Summary: "request headers with the proxies, scheme and host they reveal"}, s.HeadersHandler())
+	s.handleRoute(ApiRoute{Path: "/generate", Methods: get, Tag: "test", Auth: true, ContentType: MIMEAppOctetStream,
+		Summary: "synthetic payload of the requested size, to test the body size limits of the ingress",
+		Params: []ApiParam{
+			{Name: "size", Type: "string", Description: "size of the payload like 1M, 1M by default and 1G at most"},
+			{Name: "type", Type: "string", Description: "json (default), text or binary"},
+			{Name: "chunked", Type: "boolean", Description: "chunked transfer encoding instead of a Content-Length"},
+			{Name: "rate", Type: "string", Description: "bytes sent per second like 100K, as fast as possible by default"},
+		}}, s.GenerateHandler())
+	s.handleRoute(ApiRoute{Path: "/sink", Methods: []string{http.MethodPost, http.MethodPut}, Tag: "test", Auth: true, Response: SinkReport{},
+		Summary: "discards the body and reports its size, throughput and sha256, the counterpart of /generate",
+		Params: []ApiParam{
+			{Name: "max", Type: "string", Description: "bytes accepted before answering 413 like 10M, 1G by default and at most"},
+		}}, s.SinkHandler())
+	if s.uploads != nil {
+		s.handleRoute(ApiRoute{Path: "/upload", Methods: []string{http.MethodPost}, Tag: "test", Auth: true, Response: UploadReport{},
+			Summary: "stores the files of a multipart form in UPLOAD_DIR until UPLOAD_TTL and echoes the other fields"}, s.UploadHandler(s.uploads))
+		s.handleRoute(ApiRoute{Path: "/upload/{id}", Methods: get, Tag: "test", Auth: true, ContentType: MIMEAppOctetStream,
+			Summary: "content of an uploaded file",
+			Params:  []ApiParam{{Name: "id", Type: "string", Description: "id of the file given by POST /upload", Required: true}},
+		}, s.UploadFileHandler(s.uploads))
+	}
+	s.handleRoute(ApiRoute{Path: "/whoami", Methods: get, Tag: "test", Auth: true, Response: WhoamiInfo{},
+		Summary: "identity of the caller : ip chain, tls client certificate, basic auth user, jwt claims and mesh identity"}, s.WhoamiHandler())
+	s.handleRoute(ApiRoute{Path: "/tls", Methods: get, Tag: "network", Response: TlsReport{},
+		Summary: "negotiated tls parameters and client certificate chain, only over https"}, s.TlsHandler())
+	s.handleRoute(ApiRoute{Path: "/dns", Methods: get, Tag: "network", Auth: true, Response: DnsReport{},
+		Summary: "dns lookups from inside the pod",
+		Params: []ApiParam{
+			{Name: "host", Type: "string", Description: "name to resolve", Required: true},
+			{Name: "type", Type: "string", Description: "comma separated record types among A, AAAA, CNAME and SRV"},
+		}}, s.DnsHandler(s.dnsResolver, s.dnsServer))
+	s.handleRoute(ApiRoute{Path: "/info/memory", Methods: get, Tag: "info", Auth: true, Response: MemoryInfo{},
+		Summary: "go runtime and cgroup memory statistics"}, s.MemoryInfoHandler(info.DefaultCgroupRoot))
+	s.handleRoute(ApiRoute{Path: "/info/gc", Methods: get, Tag: "info", Auth: true, Response: GcReport{},
+		Summary: "parameters of the garbage collector, GOGC, GOMEMLIMIT and heap ballast"}, s.GcHandler(s.gc))
+	s.handleRoute(ApiRoute{Path: "/admin/gc", Methods: []string{http.MethodPost}, Tag: "info", Auth: true, Admin: true, Privileged: true, Response: GcReport{},
+		Summary: "changes the parameters of the garbage collector while running",
+		Params: []ApiParam{
+			{Name: "gogc", Type: "string", Description: "heap growth in percent triggering a gc, or off"},
+			{Name: "memory_limit_mb", Type: "string", Description: "soft memory limit in megabytes, or off"},
+			{Name: "ballast_mb", Type: "integer", Description: "size of the heap ballast, 0 to remove it"},
+			{Name: "run", Type: "boolean", Description: "run a gc now"},
+		}}, s.AdminGcHandler(s.gc))
+	s.handleRoute(ApiRoute{Path: "/info/network", Methods: get, Tag: "network", Auth: true, Response: NetworkInfo{},
+		Summary: "network interfaces, routes and dns configuration"}, s.NetworkInfoHandler(defaultProcNetDir, defaultResolvConfPath))
+	s.handleRoute(ApiRoute{Path: "/info/sockets", Methods: get, Tag: "network", Auth: true, Response: SocketsReport{},
+		Summary: "listening tcp sockets and established connections of the network namespace of the pod"}, s.SocketsHandler(defaultProcNetDir, defaultProcSelfFd))
+	s.handleRoute(ApiRoute{Path: "/info/filesystem", Methods: get, Tag: "info", Auth: true, Response: FilesystemInfo{},
+		Summary: "mounts of the container with their usage, highlighting the pod volumes",
+		Params: []ApiParam{
+			{Name: "all", Type: "boolean", Description: "true to add the pseudo filesystems like proc or cgroup"},
+		}}, s.FilesystemInfoHandler(defaultMountInfoPath))
+	s.handleRoute(ApiRoute{Path: "/info/processes", Methods: get, Tag: "info", Auth: true, Response: ProcessList{},
+		Summary: "processes visible in the pod with their rss, cpu time and start time, the sidecars too with shareProcessNamespace"}, s.ProcessesHandler(defaultProcDir))
+	s.handleRoute(ApiRoute{Path: "/info/limits", Methods: get, Tag: "info", Auth: true, Response: LimitsInfo{},
+		Summary: "rlimits of the process, kernel parameters like somaxconn or file-max and transparent huge pages"}, s.LimitsHandler(defaultProcSelfLimits, defaultProcDir, defaultSysDir))
+	s.handleRoute(ApiRoute{Path: "/info/fds", Methods: get, Tag: "info", Auth: true, Response: FdReport{},
+		Summary: "open file descriptors of the process by type, compared to the limit of open files"}, s.FdsHandler(defaultProcSelfFd, defaultProcSelfLimits))
+	s.handleRoute(ApiRoute{Path: "/k8s/mounts", Methods: get, Tag: "k8s", Auth: true, Response: MountsReport{},
+		Summary: "configMap, secret, projected and downwardAPI volumes with their files, the contents are redacted",
+		Params: []ApiParam{
+			{Name: "contents", Type: "boolean", Description: "true to add the content of the configMap files when MOUNTS_CONTENTS allows it, never the secrets"},
+		}}, s.MountsHandler(defaultMountInfoPath))
+	if s.configMaps != nil {
+		s.handleRoute(ApiRoute{Path: "/k8s/configmap-events", Methods: get, Tag: "k8s", Auth: true, Response: ConfigMapEventsReport{},
+			Summary: "timeline of the updates of the configMap volumes of CONFIGMAP_WATCH"}, s.ConfigMapEventsHandler(s.configMaps))
+	}
+	if s.prober != nil {
+		s.handleRoute(ApiRoute{Path: "/probe/status", Methods: get, Tag: "test", Auth: true, Response: ProbeStatusReport{},
+			Summary: "status and latency history of the urls of PROBE_TARGETS"}, s.ProbeStatusHandler(s.prober))
+		s.handleRoute(ApiRoute{Path: "/probe", Methods: []string{http.MethodPost}, Tag: "test", Auth: true, Response: ProbeStatusReport{},
+			Summary: "polls the urls of PROBE_TARGETS right away"}, s.ProbeNowHandler(s.prober))
+	}
+	s.handleRoute(ApiRoute{Path: "/config", Methods: get, Tag: "info", Auth: true, Response: ConfigReport{},
+		Summary: "active configuration"}, s.ConfigHandler())
+	s.handleRoute(ApiRoute{Path: "/ws/stats", Methods: get, Tag: "stats", Auth: true, Raw: true, Response: LiveStats{}, Params: []ApiParam{interval},
+		Summary: "websocket pushing the runtime and memory stats"}, s.WsStatsHandler(s.settings.Current, defaultWsPingInterval))
+	s.handleRoute(ApiRoute{Path: "/events/stats", Methods: get, Tag: "stats", Auth: true, ContentType: MIMETextEventStream, Params: []ApiParam{interval},
+		Summary: "server-sent events with the runtime and memory stats"}, s.SseStatsHandler())
+	if s.history != nil {
+		s.handleRoute(ApiRoute{Path: "/requests", Methods: get, Tag: "stats", Auth: true, Response: RequestsReport{},
+			Summary: "last requests served by this server, newest first",
+			Params: []ApiParam{
+				{Name: "method", Type: "string", Description: "http method of the requests"},
+				{Name: "path", Type: "string", Description: "prefix of the path of the requests"},
+				{Name: "status", Type: "string", Description: "http status like 404 or class like 5xx"},
+				{Name: "ip", Type: "string", Description: "ip address of the client"},
+				{Name: "min_duration", Type: "string", Description: "minimum duration like 250ms"},
+				{Name: "offset", Type: "integer", Description: "number of matching requests skipped"},
+				{Name: "limit", Type: "integer", Description: "maximum number of requests returned, 50 by default"},
+			}}, s.RequestsHandler(s.history))
+	}
+	if s.requestStore != nil {
+		s.handleRoute(ApiRoute{Path: "/requests/query", Methods: get, Tag: "stats", Auth: true, Response: RequestQueryReport{},
+			Summary: "requests persisted in the database, newest first",
+			Params: []ApiParam{
+				{Name: "from", Type: "string", Description: "RFC 3339 time or duration before now like 30m, 1h by default"},
+				{Name: "to", Type: "string", Description: "RFC 3339 time or duration before now, now by default"},
+				{Name: "method", Type: "string", Description: "http method of the requests"},
+				{Name: "path", Type: "string", Description: "prefix of the path of the requests"},
+				{Name: "status", Type: "integer", Description: "http status of the requests"},
+				{Name: "hostname", Type: "string", Description: "pod which served the requests"},
+				{Name: "offset", Type: "integer", Description: "number of matching requests skipped"},
+				{Name: "limit", Type: "integer", Description: "maximum number of requests returned, 100 by default"},
+			}}, s.RequestsQueryHandler(s.requestStore))
+	}
+	s.handleRoute(ApiRoute{Path: "/cloud", Methods: get, Tag: "cloud", Auth: true, Response: CloudInfo{},
+		Summary: "cloud provider, region, zone and instance"}, s.CloudInfoHandler(s.cloud))
+	if s.preemption != nil {
+		s.handleRoute(ApiRoute{Path: "/cloud/preemption", Methods: get, Tag: "cloud", Auth: true, Response: PreemptionState{},
+			Summary: "spot or preemptible instance interruption notice"}, s.PreemptionHandler(s.preemption))
+	}
+	if s.auth.enabled() || s.apiToken != "" {
+		s.handleRoute(ApiRoute{Path: "/token", Methods: []string{http.MethodPost}, Tag: "auth", Auth: true, Body: tokenRequest{}, Response: tokenResponse{},
+			Summary: "single-use access_token for a path, needs the credentials of AUTH_MODE or the API_TOKEN bearer"}, s.TokenHandler())
+	}
+	if s.connector != nil {
+		s.handleRoute(ApiRoute{Path: "/connect", Methods: get, Tag: "network", Auth: true, Response: ConnectReport{},
+			Summary: "outbound tcp or http connection from inside the pod",
+			Params: []ApiParam{
+				{Name: "target", Type: "string", Description: "host:port to dial in tcp"},
+				{Name: "url", Type: "string", Description: "url to GET"},
+				{Name: "timeout", Type: "string", Description: "duration like 2s"},
+			}}, s.ConnectHandler(s.connector))
+		s.handleRoute(ApiRoute{Path: "/certcheck", Methods: get, Tag: "network", Auth: true, Response: CertCheckReport{},
+			Summary: "certificate chain of a tls server and its validation against the CA bundle of the pod",
+			Params: []ApiParam{
+				{Name: "host", Type: "string", Description: "host:port of the tls server", Required: true},
+				{Name: "servername", Type: "string", Description: "SNI and name to verify, the host by default"},
+				{Name: "timeout", Type: "string", Description: "duration like 2s"},
+			}}, s.CertCheckHandler(s.connector))
+		s.handleRoute(ApiRoute{Path: "/proxy", Methods: get, Tag: "network", Auth: true, Response: ProxyReport{},
+			Summary: "status, headers, timing and body preview of an url fetched from inside the pod",
+			Params: []ApiParam{
+				{Name: "url", Type: "string", Description: "http or https url to GET", Required: true},
+				{Name: "preview", Type: "integer", Description: "bytes of the body returned, 4096 by default"},
+				{Name: "timeout", Type: "string", Description: "duration like 2s"},
+			}}, s.ProxyHandler(s.connector))
+	}
+	if s.settings.Current().EnableChaos {
+		s.logger.Warn("chaos endpoints are enabled, any authorized client can crash this server", "path", "/chaos/")
+	}
+	chaos := s.requireFeature(func(c config.Config) bool { return c.EnableChaos })
+	s.handleRoute(ApiRoute{Path: "/chaos/error", Methods: getOrPost, Tag: "chaos", Auth: true, Response: chaosResponse{},
+		Summary: "answers with the given http status",
+		Params:  []ApiParam{{Name: "code", Type: "integer", Description: "http status from 400 to 599, 500 by default"}},
+	}, s.ChaosErrorHandler(), chaos)
+	s.handleRoute(ApiRoute{Path: "/chaos/crash", Methods: getOrPost, Tag: "chaos", Auth: true, Response: chaosResponse{},
+		Summary: "exits the process",
+		Params: []ApiParam{
+			{Name: "exit", Type: "integer", Description: "exit code, 1 by default"},
+			{Name: "delay", Type: "string", Description: "duration before the exit, 1s by default"},
+		}}, s.ChaosCrashHandler(s.chaos), chaos)
+	s.handleRoute(ApiRoute{Path: "/chaos/hang", Methods: getOrPost, Tag: "chaos", Auth: true,
+		Summary: "never answers"}, s.ChaosHangHandler(), chaos)
+	s.handleRoute(ApiRoute{Path: "/chaos/oom", Methods: getOrPost, Tag: "chaos", Auth: true, Response: chaosResponse{},
+		Summary: "allocates memory until the container is killed"}, s.ChaosOomHandler(s.chaos), chaos)
+	load := s.requireFeature(func(c config.Config) bool { return c.EnableLoad })
+	s.handleRoute(ApiRoute{Path: "/load/cpu", Methods: getOrPost, Tag: "load", Auth: true, Response: CpuLoadReport{},
+		Summary: "keeps cpu cores busy",
+		Params: []ApiParam{
+			{Name: "cores", Type: "integer", Description: "number of busy cores"},
+			{Name: "seconds", Type: "integer", Description: "duration of the load"},
+		}}, s.CpuLoadHandler(s.load), load)
+	s.handleRoute(ApiRoute{Path: "/load/memory", Methods: getOrPost, Tag: "load", Auth: true, Response: MemoryLoadReport{},
+		Summary: "allocates and holds memory",
+		Params: []ApiParam{
+			{Name: "mb", Type: "integer", Description: "megabytes to allocate", Required: true},
+			{Name: "hold", Type: "string", Description: "duration like 60s"},
+			{Name: "force", Type: "boolean", Description: "allocate even above the cgroup memory limit"},
+		}}, s.MemoryLoadHandler(s.load), load)
+	s.handleRoute(ApiRoute{Path: "/bench/disk", Methods: getOrPost, Tag: "load", Auth: true, Response: DiskBenchReport{},
+		Summary: "sequential and random write-read benchmark of a mount",
+		Params: []ApiParam{
+			{Name: "path", Type: "string", Description: "directory of the mount to benchmark", Required: true},
+			{Name: "size", Type: "string", Description: "size of the benchmark file like 100M, 64M by default"},
+		}}, s.DiskBenchHandler(s.diskBench), load)
+	s.handleRoute(ApiRoute{Path: netBenchSinkPath, Methods: getOrPost, Tag: "load", Auth: true, Response: NetBenchSinkReport{},
+		Summary: "discards the body and reports the throughput, the server side of /bench/net"}, s.NetBenchSinkHandler(), load)
+	if s.netBench != nil {
+		s.handleRoute(ApiRoute{Path: "/bench/net", Methods: getOrPost, Tag: "load", Auth: true, Response: NetBenchReport{},
+			Summary: "latency and throughput to another go-info-server",
+			Params: []ApiParam{
+				{Name: "peer", Type: "string", Description: "base url of the peer like http://10.0.1.5:8080", Required: true},
+				{Name: "size", Type: "string", Description: "bytes to upload like 100M, 64M by default"},
+				{Name: "pings", Type: "integer", Description: "number of round trips measured, 10 by default"},
+			}}, s.NetBenchHandler(s.netBench), load)
+	}
+	if s.cluster != nil {
+		s.handleRoute(ApiRoute{Path: "/cluster", Methods: get, Tag: "k8s", Auth: true, Response: ClusterReport{},
+			Summary: "hostname, node, version and uptime of every pod of the deployment"}, s.ClusterHandler(s.cluster))
+	}
+	if s.k8s != nil {
+		s.handleRoute(ApiRoute{Path: "/k8s/pod", Methods: get, Tag: "k8s", Auth: true, Response: json.RawMessage{},
+			Summary: "pod object of this server from the k8s api"}, s.K8sPodHandler())
+		s.handleRoute(ApiRoute{Path: "/k8s/node", Methods: get, Tag: "k8s", Auth: true, Response: K8sNodeInfo{},
+			Summary: "node running this pod"}, s.K8sNodeHandler())
+		s.handleRoute(ApiRoute{Path: "/k8s/namespace", Methods: get, Tag: "k8s", Auth: true, Response: K8sNamespaceSummary{},
+			Summary: "deployments, pods and services of the namespace"}, s.K8sNamespaceHandler())
+		if s.leader != nil {
+			s.handleRoute(ApiRoute{Path: "/leader", Methods: get, Tag: "k8s", Auth: true, Response: LeaderReport{},
+				Summary: "leader of the replicas elected with the Lease of LEADER_ELECTION, and the transitions seen"}, s.LeaderHandler(s.leader))
+		}
+		s.handleRoute(ApiRoute{Path: "/k8s/identity", Methods: get, Tag: "k8s", Auth: true, Response: K8sIdentity{},
+			Summary: "service account token claims and permissions"}, s.K8sIdentityHandler())
+	}
+	s.handleRoute(ApiRoute{Path: "/openapi.json", Methods: get, Tag: "docs", Raw: true, Response: json.RawMessage{},
+		Summary: "this OpenAPI document"}, s.OpenApiHandler())
+	s.handleRoute(ApiRoute{Path: "/docs", Methods: get, Tag: "docs", ContentType: "text/html",
+		Summary: "Swagger UI of this OpenAPI document"}, s.DocsHandler())
+	if s.pprofEnabled && s.pprofServer == nil {
+		if s.adminRouter == nil {
+			s.logger.Warn("pprof endpoints are exposed on the main port, they need credentials", "path", pprofPathPrefix)
+		}
+		s.handleRoute(ApiRoute{Path: pprofPathPrefix, Methods: []string{http.MethodGet, http.MethodPost}, Tag: "debug", Auth: true, Admin: true, Privileged: true, ContentType: "text/html",
+			Summary: "net/http/pprof profiles of the server, cpu profile and trace can last longer than the write timeout, 60 seconds at most"},
+			newPprofMux())
+	}
+}
+
+// StartServer waits for the dependencies given to UseDependencyWaiter, listens on the address of the server and on its
+// unix socket, starts the side listeners and the background goroutines, then serves until SIGINT, SIGTERM or Stop and
+// shuts down gracefully. the handlers are already registered by the constructor, it returns the error that stopped the
+// server, a *config.ErrorConfig when the dependencies are not up in time, nil after a graceful shutdown
+func (s *GoHttpServer) StartServer() error {
+	signal.Notify(s.interrupts, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(s.interrupts)
+	defer s.closeSideServers()
+	if s.adminServer != nil {
+		// the admin port is bound first, its /readiness and /dependencies show the progress of the wait
+		s.startAdminServer()
+	}
+	if s.dependencies != nil {
+		if stopped, err := s.waitForDependencies(); stopped || err != nil {
+			return err
+		}
+	}
+	var ln, socketLn net.Listener
+	var err error
+	if !s.socketOnly {
+		if ln, err = s.takeInheritedListener(); err != nil {
+			s.logger.Error("Could not use the socket activated listener", "error", err)
+			return fmt.Errorf("socket activation: %w", err)
+		}
+		if ln != nil {
+			s.logger.Info("Using the socket activated listener", "address", ln.Addr().String())
+		} else if ln, err = net.Listen("tcp", s.httpServer.Addr); err != nil {
+			s.logger.Error("Could not listen", "address", s.listenAddress, "error", err)
+			return fmt.Errorf("listening on %s: %w", s.httpServer.Addr, err)
+		}
+	}
+	if s.socketPath != "" {
+		if socketLn, err = listenUnixSocket(s.socketPath, s.socketMode); err != nil {
+			if ln != nil {
+				ln.Close()
+			}
+			s.logger.Error("Could not listen", "socket", s.socketPath, "error", err)
+			return fmt.Errorf("listening on %s: %w", s.socketPath, err)
+		}
+	}
+	// the background goroutines stop when the server does, so it can be started again in the same process
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	protocol := defaultProtocol
+	if s.certs != nil {
+		protocol = "https"
+		go s.certs.Watch(ctx, defaultCertReloadInterval)
+	}
+	if s.pprofServer != nil {
+		s.startPprofServer()
+	}
+	if s.grpcAddress != "" {
+		s.startGrpcServer(ctx)
+	}
+	if s.http3Address != "" {
+		if err := s.startHttp3Server(); err != nil {
+			s.logger.Error("startHttp3Server() returned an error, HTTP/3 is disabled", "address", s.http3Address, "error", err)
+		}
+	}
+	if s.preemption != nil {
+		go s.preemption.Watch(ctx, defaultPreemptionInterval)
+	}
+	if s.leader != nil {
+		go s.leader.Run(ctx)
+	}
+	if s.configMaps != nil {
+		go s.configMaps.Watch(ctx, defaultConfigMapWatchInterval)
+	}
+	if s.prober != nil {
+		go s.prober.Run(ctx, s.probeInterval)
+	}
+	if s.uploads != nil {
+		go s.uploads.Run(ctx, defaultUploadCleanupInterval)
+	}
+	if s.k8sEvents != nil {
+		go s.k8sEvents.Run(ctx)
+	}
+	if s.webhooks != nil {
+		go s.webhooks.Run(ctx)
+	}
+	if s.configReload {
+		go s.settings.Watch(ctx, defaultConfigReloadInterval)
+	}
+	if s.tracer != nil {
+		s.logger.Info("Exporting traces", "endpoint", s.tracer.config.Endpoint, "protocol", s.tracer.config.Protocol,
+			"service_name", s.tracer.config.ServiceName, "sampler", s.tracer.config.Sampler)
+	}
+	if s.reporter != nil {
+		go s.reporter.Run(ctx)
+	}
+	if s.requestStore != nil {
+		go s.requestStore.Run(ctx, defaultRequestStoreFlushInterval)
+	}
+	extraListeners, err := s.listenExtra(ctx)
+	if err != nil {
+		for _, l := range []net.Listener{ln, socketLn} {
+			if l != nil {
+				l.Close()
+			}
+		}
+		s.logger.Error("Could not listen on the extra addresses", "error", err)
+		return err
+	}
+	// Starting the web server in his own goroutine, and the unix socket and each extra address in another one
+	serveErrors := make(chan error, 2+len(extraListeners))
+	for _, extraLn := range extraListeners {
+		go func() {
+			s.logger.Info("Starting http server", "address", extraLn.Addr().String(), "extra", true)
+			if err := s.httpServer.Serve(extraLn); err != nil && err != http.ErrServerClosed {
+				serveErrors <- err
+			}
+		}()
+	}
+	if socketLn != nil {
+		go func() {
+			s.logger.Info("Starting http server", "socket", s.socketPath, "mode", s.socketMode.String())
+			// the socket stays in plain http, it does not leave the pod. Shutdown closes it with the tcp listener
+			if err := s.httpServer.Serve(socketLn); err != nil && err != http.ErrServerClosed {
+				serveErrors <- err
+			}
+		}()
+	}
+	handoff := ln // ln is wrapped by the proxy protocol in the serving goroutine, the new binary needs the socket
+	if ln != nil {
+		go func() {
+			s.logger.Info("Starting http server", "url", fmt.Sprintf("%s://%s/", protocol, s.listenAddress), "http2", s.httpServer.Protocols.HTTP2())
+			if s.proxyProtocol {
+				ln = NewProxyProtoListener(ln, s.trustedProxies)
+			}
+			var err error
+			if s.certs != nil {
+				// cert and key files are empty because they are given by TLSConfig.GetCertificate
+				err = s.httpServer.ServeTLS(ln, "", "")
+			} else {
+				err = s.httpServer.Serve(ln)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				serveErrors <- err
+			}
+		}()
+		s.logger.Info("Server listening", "address", ln.Addr().String(), "pid", os.Getpid())
+	} else {
+		s.logger.Info("Server listening", "socket", s.socketPath, "pid", os.Getpid())
+	}
+	s.lifecycleEvent(k8sEventTypeNormal, "Started", fmt.Sprintf("%s %s listening on %s", info.APP, info.VERSION, s.listenAddress))
+	if notifyUpgradeReady() {
+		s.logger.Info("Binary reload completed, the previous process is draining its connections", "parent_pid", os.Getppid())
+	}
+	if s.pidFile != "" {
+		if err := writePidFile(s.pidFile); err != nil {
+			s.logger.Warn("Could not write the pid file", "path", s.pidFile, "error", err)
+		}
+		defer func() {
+			// after a binary reload the file holds the pid of the new process
+			if s.upgradedPid.Load() == 0 {
+				os.Remove(s.pidFile)
+			}
+		}()
+	}
+	s.upgradedPid.Store(0)
+	if s.binaryReload && len(upgradeSignals) > 0 {
+		upgrades := make(chan os.Signal, 1)
+		signal.Notify(upgrades, upgradeSignals...)
+		defer signal.Stop(upgrades)
+		go s.handleUpgrades(upgrades, ctx.Done(), handoff)
+	}
+
+	// Graceful Shutdown on SIGINT (interrupt)
+	err = waitForShutdown(&s.httpServer, s.logger, s.readiness, s.registeredShutdownHooks, s.interrupts, serveErrors, s.preStopDelay, s.shutdownTimeout)
+	if s.upgradedPid.Load() != 0 {
+		superviseUpgrades(s.logger)
+	}
+	return err
+}
+
+// Stop starts the graceful shutdown of a running StartServer, like a SIGINT would do
+func (s *GoHttpServer) Stop() {
+	select {
+	case s.interrupts <- os.Interrupt:
+	default: // a shutdown is already pending
+	}
+}
+
+// closeSideServers closes the pprof, admin, grpc and http3 listeners once the main server is stopped
+func (s *GoHttpServer) closeSideServers() {
+	for _, srv := range []*http.Server{s.pprofServer, s.adminServer} {
+		if srv != nil {
+			srv.Close()
+		}
+	}
+	if s.grpcServer != nil {
+		s.grpcServer.Stop()
+	}
+	if s.http3Server != nil {
+		s.http3Server.Close()
+	}
+}
+
+// (*GoHttpServer) jsonResponseWithStatus sends the result as json with the given http status code, compact unless
+// the pretty query parameter is true, or 304 when the If-None-Match header of a GET already has its ETag.
+// the result is the data of a SourceEnvelope when response_envelope is true
+func (s *GoHttpServer) jsonResponseWithStatus(w http.ResponseWriter, r *http.Request, status int, result interface{}) {
+	w.Header().Set(HeaderContentType, MIMEAppJSONCharsetUTF8)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// the ETag is the one of the result, the timestamp of the envelope changes on every answer
+	if s.notModified(w, r, status, result) {
+		return
+	}
+	if s.wantsSourceEnvelope(r) {
+		result = s.envelope.Wrap(result, time.Now())
+		w.Header().Set(HeaderResponseEnvelope, "source")
+	}
+	// the encoder writes to the ResponseWriter, the status is sent with the first bytes so a marshal error is still a 500
+	sw := &statusOnWrite{ResponseWriter: w, status: status}
+	encoder := json.NewEncoder(sw)
+	if wantsPrettyJson(r) {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(result); err != nil {
+		s.logger.Error("JSON marshal failed", "error", err)
+		if !sw.written {
+			w.Header().Del(HeaderResponseEnvelope)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+//############# BEGIN HANDLERS
+
+// ReadinessHandler runs all the registered readiness checks and answers 200 when all succeed, 503 otherwise
+func (s *GoHttpServer) ReadinessHandler() http.HandlerFunc {
+	handlerName := "ReadinessHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := s.readiness.Run(r.Context())
+		if dependencies := s.dependencyReport(); !dependencies.Ready {
+			report.Dependencies = dependencies.Dependencies
+		}
+		status := http.StatusOK
+		if report.Status != readinessStatusReady {
+			status = http.StatusServiceUnavailable
+			s.logger.Warn("readiness checks failed", "handler", handlerName, "checks", report.Checks)
+		}
+		// the shutdown is notified by its own event, a flip seen only by this probe is not
+		if previous := s.lastReadiness.Swap(report.Status); previous != nil && previous != report.Status && report.Status != readinessStatusDraining {
+			if report.Status == readinessStatusReady {
+				s.lifecycleEvent(k8sEventTypeNormal, "Ready", "the readiness checks succeed again")
+			} else {
+				s.lifecycleEvent(k8sEventTypeWarning, "NotReady", fmt.Sprintf("the readiness is %s : %s", report.Status, failedChecks(report)))
+			}
+		}
+		s.render(w, r, status, report)
+	}
+}
+
+// RuntimeInfoHandler answers the RuntimeInfo of the server and of the request, in json or as the html dashboard
+func (s *GoHttpServer) RuntimeInfoHandler() http.HandlerFunc {
+	handlerName := "RuntimeInfoHandler"
+
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	base := s.baseRuntimeInfo()
+	notFound := s.NotFoundHandler() // the route of / is the catch-all of the router
+	return func(w http.ResponseWriter, r *http.Request) {
+		remoteIp := r.RemoteAddr // ip address of the original request or the last proxy
+		requestedUrlPath := r.URL.Path
+		if len(strings.TrimSpace(requestedUrlPath)) == 0 || requestedUrlPath == defaultServerPath {
+			data := s.requestRuntimeInfo(base, r)
+			if format, _ := responseFormat(r, formatJson, formatYaml, formatXml, formatHtml); format == formatHtml {
+				s.renderDashboard(w, data)
+			} else {
+				s.render(w, r, http.StatusOK, data)
+			}
+			s.logger.Debug("SUCCESS", "handler", handlerName, "path", requestedUrlPath, "remote_addr", remoteIp)
+		} else {
+			notFound(w, r)
+		}
+	}
+}
+
+// (*GoHttpServer) baseRuntimeInfo returns the part of the RuntimeInfo which does not depend on the request,
+// computed once when the handlers are created
+func (s *GoHttpServer) baseRuntimeInfo() info.RuntimeInfo {
+	hostName, err := os.Hostname()
+	if err != nil {
+		s.logger.Error("os.Hostname() returned an error", "error", err)
+		hostName = "#unknown#"
+	}
+
+	osReleaseInfo, errConf := info.GetOsInfo()
+
+	if errConf.Err != nil {
+		switch errConf.Err.(type) {
+		case *fs.PathError:
+			s.logger.Info("NOTICE: GetOsInfo() did not find os-release", "error", errConf.Err)
+		default:
+			s.logger.Error("GetOsInfo() returned an error", "error", errConf.Err)
+		}
+	}
+
+	uptimeOS, err := info.GetOsUptime()
+	if err != nil {
+		s.logger.Error("GetOsUptime() returned an error", "error", err)
+	}
+	k8sVersion := ""
+	k8sCurrentNameSpace := ""
+	k8sUrl, err := info.GetKubernetesApiUrlFromEnv()
+	if err != nil {
+		s.logger.Info("NOTICE: GetKubernetesApiUrlFromEnv() returned an error", "error", err)
+	} else {
+		// here we can assume that we are inside a k8s container...
+		info, errConnInfo := info.GetKubernetesConnInfo(s.logger)
+		if errConnInfo.Err != nil {
+			s.logger.Error("GetKubernetesConnInfo() returned an error", "msg", errConnInfo.Msg, "error", errConnInfo.Err)
+		}
+		k8sVersion = info.Version
+		k8sCurrentNameSpace = info.CurrentNamespace
+	}
+
+	return info.RuntimeInfo{
+		Hostname:            hostName,
+		Pid:                 os.Getpid(),
+		PPid:                os.Getppid(),
+		Uid:                 os.Getuid(),
+		Appname:             info.APP,
+		Version:             info.VERSION,
+		Build:               info.GetBuildInfo(),
+		ParamName:           "_NO_PARAMETER_NAME_",
+		RemoteAddr:          "",
+		RequestId:           "",
+		GOOS:                runtime.GOOS,
+		GOARCH:              runtime.GOARCH,
+		Runtime:             runtime.Version(),
+		NumGoroutine:        strconv.FormatInt(int64(runtime.NumGoroutine()), 10),
+		OsReleaseName:       osReleaseInfo.Name,
+		OsReleaseVersion:    osReleaseInfo.Version,
+		OsReleaseVersionId:  osReleaseInfo.VersionId,
+		NumCPU:              strconv.FormatInt(int64(runtime.NumCPU()), 10),
+		GoMaxProcs:          runtime.GOMAXPROCS(0),
+		MaxProcs:            info.GetMaxProcsInfo(),
+		Cgroup:              info.GetCgroupLimits(info.DefaultCgroupRoot),
+		Uptime:              fmt.Sprintf("%s", time.Since(s.startTime)),
+		UptimeOs:            uptimeOS,
+		K8sApiUrl:           k8sUrl,
+		K8sVersion:          k8sVersion,
+		K8sCurrentNamespace: k8sCurrentNameSpace,
+		K8s:                 info.GetK8sDownwardInfo(os.LookupEnv, info.DefaultK8sServiceAccountPath),
+		EnvVars:             s.envRedactor.Redact(os.Environ()),
+		Headers:             map[string][]string{},
+	}
+}
+
+// (*GoHttpServer) requestRuntimeInfo returns a copy of base completed with the request r and the current uptime
+func (s *GoHttpServer) requestRuntimeInfo(data info.RuntimeInfo, r *http.Request) info.RuntimeInfo {
+	query := r.URL.Query()
+	nameValue := query.Get("name")
+	if nameValue != "" {
+		data.ParamName = nameValue
+	}
+	remote := ParseRemoteAddr(r.RemoteAddr)
+	if query.Get("rdns") == "true" {
+		remote.RemotePtr = s.rdns.Lookup(r.Context(), remote.RemoteIp)
+	}
+	data.RemoteAddr = remote.RemoteAddr
+	data.RemoteIp = remote.RemoteIp
+	data.RemotePort = remote.RemotePort
+	data.RemoteIpVersion = remote.RemoteIpVersion
+	data.RemotePtr = remote.RemotePtr
+	data.ProxyAddr = ProxyAddrFromContext(r.Context())
+	data.Headers = r.Header
+	data.Uptime = fmt.Sprintf("%s", time.Since(s.startTime))
+	uptimeOS, err := info.GetOsUptime()
+	if err != nil {
+		s.logger.Error("GetOsUptime() returned an error", "error", err)
+	}
+	data.UptimeOs = uptimeOS
+	data.GoMaxProcs = runtime.GOMAXPROCS(0)
+	data.RequestId = RequestIdFromContext(r.Context())
+	data.Message, data.Banner = s.banner.Expand(s.settings.Current(), NewBannerVars(data))
+	return data
+}
+
+// TimeHandler returns the current time of the server in the time zone of the tz parameter, formatted by the format parameter
+func (s *GoHttpServer) TimeHandler() http.HandlerFunc {
+	handlerName := "TimeHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		// the format param is the one of the time, so the answer is always json
+		res, err := GetTimeResponse(time.Now(), r.URL.Query().Get("tz"), r.URL.Query().Get("format"))
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.jsonResponseWithStatus(w, r, http.StatusOK, res)
+	}
+}
+
+type waitResult struct {
+	RequestedSeconds   float64 `json:"requested_seconds"`
+	JitterSeconds      float64 `json:"jitter_seconds"`
+	WaitedSeconds      float64 `json:"waited_seconds"`      // time really spent waiting
+	ClientDisconnected bool    `json:"client_disconnected"` // true when the client went away before the end of the wait
+	Waiters            int64   `json:"waiters"`             // requests waiting when this one started, itself included
+}
+
+// parseWaitParam returns the value in seconds of the query parameter name, or defaultValue when it is not given
+func parseWaitParam(r *http.Request, name string, defaultValue float64, max time.Duration) (float64, error) {
+	val := r.URL.Query().Get(name)
+	if val == "" {
+		return defaultValue, nil
+	}
+	seconds, err := strconv.ParseFloat(val, 64)
+	if err != nil || seconds < 0 || seconds > max.Seconds() {
+		return 0, fmt.Errorf("parameter %s should be a number of seconds between 0 and %v", name, max.Seconds())
+	}
+	return seconds, nil
+}
+
+// WaitHandler simulates a slow response, waiting ?seconds= (wait_default by default) plus or minus a random ?jitter=,
+// both bounded by wait_max of the configuration given by current. the wait stops early when the client disconnects,
+// and at most wait_max_concurrent requests wait at the same time, the others get a 503
+func (s *GoHttpServer) WaitHandler(current func() config.Config) http.HandlerFunc {
+	handlerName := "WaitHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		config := current()
+		maxWait := config.WaitMax
+		seconds, err := parseWaitParam(r, "seconds", config.WaitDefault.Seconds(), maxWait)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		jitter, err := parseWaitParam(r, "jitter", 0, maxWait)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		durationOfSleep := time.Duration((seconds + jitter*(2*rand.Float64()-1)) * float64(time.Second))
+		if durationOfSleep < 0 {
+			durationOfSleep = 0
+		} else if durationOfSleep > maxWait {
+			durationOfSleep = maxWait
+		}
+		if !s.waiters.Acquire(config.WaitMaxConc) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(seconds))+1))
+			http.Error(w, fmt.Sprintf("ERROR: already %d requests waiting, the maximum of wait_max_concurrent", config.WaitMaxConc),
+				http.StatusServiceUnavailable)
+			return
+		}
+		defer s.waiters.Release()
+		result := waitResult{RequestedSeconds: seconds, JitterSeconds: jitter, Waiters: s.waiters.Active()}
+		start := time.Now()
+		timer := time.NewTimer(durationOfSleep)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			result.ClientDisconnected = true
+		}
+		result.WaitedSeconds = time.Since(start).Seconds()
+		if result.ClientDisconnected {
+			s.logger.Info("client disconnected during wait", "handler", handlerName, "waited_seconds", result.WaitedSeconds)
+		}
+		s.render(w, r, http.StatusOK, result)
+	}
+}
+
+// ############# END HANDLERS
@@ -1,19 +1,23 @@
-package main
+package server
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"github.com/stretchr/testify/assert"
+	"io"
 	"io/ioutil"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
-	"sync"
 	"testing"
 	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
 )
 
 const (
@@ -48,6 +52,16 @@ const (
 }`
 )
 
+// getTestLogger returns a logger discarding everything, unless DEBUG is true
+func getTestLogger() *slog.Logger {
+	if DEBUG {
+		var level slog.LevelVar
+		level.Set(slog.LevelDebug)
+		return NewLogger(os.Stdout, config.LogFormatText, &level)
+	}
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
 type testStruct struct {
 	name           string
 	wantStatusCode int
@@ -56,134 +70,13 @@ type testStruct struct {
 	r              *http.Request
 }
 
-func TestErrorConfigError(t *testing.T) {
-	err := ErrorConfig{
-		err: errors.New("a brand ne error test"),
-		msg: "ERROR: This a test error.",
-	}
-	tests := []struct {
-		name string
-		e    ErrorConfig
-		want string
-	}{
-		{
-			name: "",
-			e:    err,
-			want: fmt.Sprintf("%s : %v", err.msg, err.err),
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			e := err
-			if got := e.Error(); got != tt.want {
-				t.Errorf("Error() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestGetPortFromEnv(t *testing.T) {
-	type args struct {
-		defaultPort int
-	}
-	tests := []struct {
-		name          string
-		args          args
-		envPORT       string
-		want          string
-		wantErr       bool
-		wantErrPrefix string
-	}{
-		{
-			name: "should return the default values when env variables are not set",
-			args: args{
-				defaultPort: defaultPort,
-			},
-			envPORT:       "",
-			want:          ":8080",
-			wantErr:       false,
-			wantErrPrefix: "",
-		},
-		{
-			name: "should return SERVERIP:PORT when env variables are set to valid values",
-			args: args{
-				defaultPort: 8080,
-			},
-			envPORT:       "3333",
-			want:          ":3333",
-			wantErr:       false,
-			wantErrPrefix: "",
-		},
-		{
-			name: "should return an empty string and report an error when PORT is not a number",
-			args: args{
-				defaultPort: 8080,
-			},
-			envPORT:       "aBigOne",
-			want:          "",
-			wantErr:       true,
-			wantErrPrefix: "ERROR: CONFIG ENV PORT should contain a valid integer.",
-		},
-		{
-			name: "should return an empty string and report an error when PORT is < 1",
-			args: args{
-				defaultPort: 8080,
-			},
-			envPORT:       "0",
-			want:          "",
-			wantErr:       true,
-			wantErrPrefix: "ERROR: CONFIG ENV PORT should contain an integer between 1 and 65535",
-		},
-		{
-			name: "should return an empty string and report an error when PORT is > 65535",
-			args: args{
-				defaultPort: 8080,
-			},
-			envPORT:       "70000",
-			want:          "",
-			wantErr:       true,
-			wantErrPrefix: "ERROR: CONFIG ENV PORT should contain an integer between 1 and 65535",
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if len(tt.envPORT) > 0 {
-				err := os.Setenv("PORT", tt.envPORT)
-				if err != nil {
-					t.Errorf("Unable to set env variable PORT")
-					return
-				}
-			}
-			got, err := GetPortFromEnv(tt.args.defaultPort)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("GetPortFromEnv() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if tt.wantErr {
-				// check that error contains the ERROR keyword
-				if strings.HasPrefix(err.Error(), "ERROR:") == false {
-					t.Errorf("GetPortFromEnv() error = %v, wantErrPrefix %v", err, tt.wantErrPrefix)
-				}
-			}
-			if got != tt.want {
-				t.Errorf("GetPortFromEnv() got = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
 func TestGoHttpServerMyDefaultHandler(t *testing.T) {
-	var l *log.Logger
 	var nameParameter string
-	listenAddr := fmt.Sprintf(":%d", defaultPort)
-	if DEBUG {
-		l = log.New(os.Stdout, fmt.Sprintf("HTTP_SERVER_%s ", APP), log.Ldate|log.Ltime|log.Lshortfile)
-	} else {
-		l = log.New(ioutil.Discard, APP, 0)
-	}
+	listenAddr := fmt.Sprintf(":%d", config.DefaultPort)
+	l := getTestLogger()
 
 	myServer := NewGoHttpServer(listenAddr, l)
-	ts := httptest.NewServer(myServer.getMyDefaultHandler())
+	ts := httptest.NewServer(myServer.router)
 	defer ts.Close()
 
 	newRequest := func(method, url string, body string) *http.Request {
@@ -198,7 +91,7 @@ func TestGoHttpServerMyDefaultHandler(t *testing.T) {
 		{
 			name:           "1: Get on default Server Path should return a valid json containing param value",
 			wantStatusCode: http.StatusOK,
-			wantBody:       `"param_name": "╚»☯💥⚡✌ℂ𝔾𝕀𝕃✌⚡💥☯«╝"`,
+			wantBody:       `"param_name":"╚»☯💥⚡✌ℂ𝔾𝕀𝕃✌⚡💥☯«╝"`,
 			paramKeyValues: map[string]string{"name": "╚»☯💥⚡✌ℂ𝔾𝕀𝕃✌⚡💥☯«╝"},
 			r:              newRequest(http.MethodGet, defaultServerPath, ""),
 		},
@@ -219,7 +112,7 @@ func TestGoHttpServerMyDefaultHandler(t *testing.T) {
 		{
 			name:           "4: Get on unhandled path should return an http 404 Not Found",
 			wantStatusCode: http.StatusNotFound,
-			wantBody:       defaultNotFound,
+			wantBody:       `"path":"/a_funny_path_that_does_not_exist"`,
 			paramKeyValues: make(map[string]string, 0),
 			r:              newRequest(http.MethodGet, "/a_funny_path_that_does_not_exist", ""),
 		},
@@ -249,7 +142,7 @@ func TestGoHttpServerMyDefaultHandler(t *testing.T) {
 			}
 			assert.Equal(t, tt.wantStatusCode, resp.StatusCode, assertCorrectStatusCodeExpected)
 			receivedJson, _ := ioutil.ReadAll(resp.Body)
-			rInfo := &RuntimeInfo{}
+			rInfo := &info.RuntimeInfo{}
 			if DEBUG {
 				fmt.Println("param name : % v", nameParameter)
 				fmt.Printf("WANTED   :%T - %#v\n", tt.wantBody, tt.wantBody)
@@ -266,8 +159,8 @@ func TestGoHttpServerMyDefaultHandler(t *testing.T) {
 }
 
 func TestGoHttpServerReadinessHandler(t *testing.T) {
-	myServer := NewGoHttpServer(fmt.Sprintf(":%d", defaultPort), log.New(ioutil.Discard, APP, 0))
-	ts := httptest.NewServer(myServer.getReadinessHandler())
+	myServer := NewGoHttpServer(fmt.Sprintf(":%d", config.DefaultPort), getTestLogger())
+	ts := httptest.NewServer(myServer.router)
 	defer ts.Close()
 
 	newRequest := func(method, url string, body string) *http.Request {
@@ -325,8 +218,8 @@ func printWantedReceived(tt testStruct, receivedJson []byte) {
 }
 
 func TestGoHttpServerHealthHandler(t *testing.T) {
-	myServer := NewGoHttpServer(fmt.Sprintf(":%d", defaultPort), log.New(ioutil.Discard, APP, 0))
-	ts := httptest.NewServer(myServer.getHealthHandler())
+	myServer := NewGoHttpServer(fmt.Sprintf(":%d", config.DefaultPort), getTestLogger())
+	ts := httptest.NewServer(myServer.router)
 	defer ts.Close()
 
 	newRequest := func(method, url string, body string) *http.Request {
@@ -380,11 +273,11 @@ func TestGoHttpServerHealthHandler(t *testing.T) {
 }
 
 func TestGoHttpServerTimeHandler(t *testing.T) {
-	myServer := NewGoHttpServer(fmt.Sprintf(":%d", defaultPort), log.New(ioutil.Discard, APP, 0))
-	ts := httptest.NewServer(myServer.getTimeHandler())
+	myServer := NewGoHttpServer(fmt.Sprintf(":%d", config.DefaultPort), getTestLogger())
+	ts := httptest.NewServer(myServer.router)
 	defer ts.Close()
 	now := time.Now()
-	expectedResult := fmt.Sprintf("{\"time\":\"%s\"}", now.Format(time.RFC3339))
+	expectedResult := fmt.Sprintf("{\"time\":\"%s\"", now.Format(time.RFC3339))
 
 	newRequest := func(method, url string, body string) *http.Request {
 		r, err := http.NewRequest(method, ts.URL+url, strings.NewReader(body))
@@ -436,11 +329,72 @@ func TestGoHttpServerTimeHandler(t *testing.T) {
 	}
 }
 
+func TestGoHttpServerHandlerInOwnMux(t *testing.T) {
+	myServer := NewGoHttpServer(fmt.Sprintf(":%d", config.DefaultPort), getTestLogger())
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/server-time", myServer.TimeHandler())
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/server-time?tz=UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var res TimeResponse
+	if assert.NoError(t, json.NewDecoder(resp.Body).Decode(&res)) {
+		assert.Equal(t, "UTC", res.TimeZone, "the handler should work outside the router of the server")
+	}
+}
+
+// getTestWaitConfig returns a configuration waiting 1 second by default and at most 2 seconds
+func getTestWaitConfig() config.Config {
+	config := config.DefaultConfig()
+	config.WaitDefault = time.Second
+	config.WaitMax = 2 * time.Second
+	return config
+}
+
+func TestGoHttpServerWaitHandlerClientDisconnected(t *testing.T) {
+	myServer := NewGoHttpServer(fmt.Sprintf(":%d", config.DefaultPort), getTestLogger())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r := httptest.NewRequest(http.MethodGet, "/wait?seconds=2", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	start := time.Now()
+	myServer.WaitHandler(getTestWaitConfig)(w, r)
+	assert.Less(t, time.Since(start), time.Second, "the wait should stop when the client disconnects")
+	var result waitResult
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &result), "the output should be a valid json")
+	assert.True(t, result.ClientDisconnected)
+	assert.Less(t, result.WaitedSeconds, 1.0)
+}
+
+func TestGoHttpServerWaitHandlerConcurrencyCap(t *testing.T) {
+	myServer := NewGoHttpServer(fmt.Sprintf(":%d", config.DefaultPort), getTestLogger())
+	current := func() config.Config {
+		config := getTestWaitConfig()
+		config.WaitMaxConc = 1
+		return config
+	}
+	assert.True(t, myServer.waiters.Acquire(1), "the first waiter should get the only place")
+	w := httptest.NewRecorder()
+	myServer.WaitHandler(current)(w, httptest.NewRequest(http.MethodGet, "/wait?seconds=0", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "a waiter above wait_max_concurrent should be refused")
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	myServer.waiters.Release()
+	w = httptest.NewRecorder()
+	myServer.WaitHandler(current)(w, httptest.NewRequest(http.MethodGet, "/wait?seconds=0", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "a waiter should be accepted once the place is released")
+	assert.Contains(t, w.Body.String(), `"waiters":1`)
+	assert.Equal(t, int64(0), myServer.waiters.Active(), "the place should be released at the end of the wait")
+}
+
 func TestGoHttpServerWaitHandler(t *testing.T) {
-	myServer := NewGoHttpServer(fmt.Sprintf(":%d", defaultPort), log.New(ioutil.Discard, APP, 0))
-	ts := httptest.NewServer(myServer.getWaitHandler(1))
+	myServer := NewGoHttpServer(fmt.Sprintf(":%d", config.DefaultPort), getTestLogger())
+	ts := httptest.NewServer(Chain(myServer.WaitHandler(getTestWaitConfig), myServer.allowMethods(http.MethodGet)))
 	defer ts.Close()
-	expectedResult := fmt.Sprintf("{\"waited\":\"%v seconds\"}", 1)
 
 	newRequest := func(method, url string, body string) *http.Request {
 		r, err := http.NewRequest(method, ts.URL+url, strings.NewReader(body))
@@ -454,12 +408,33 @@ func TestGoHttpServerWaitHandler(t *testing.T) {
 		{
 			name:           "1: Get on /wait should return Http Status Ok",
 			wantStatusCode: http.StatusOK,
-			wantBody:       expectedResult,
+			wantBody:       `"requested_seconds":1,`,
 			paramKeyValues: make(map[string]string, 0),
 			r:              newRequest(http.MethodGet, "/wait", ""),
 		},
 		{
-			name:           "2: Post on /wait should return an http error method not allowed ",
+			name:           "2: Get on /wait with seconds and jitter should return Http Status Ok",
+			wantStatusCode: http.StatusOK,
+			wantBody:       `"jitter_seconds":0.1,`,
+			paramKeyValues: make(map[string]string, 0),
+			r:              newRequest(http.MethodGet, "/wait?seconds=0.2&jitter=0.1", ""),
+		},
+		{
+			name:           "3: Get on /wait with seconds above the max should return Http Status Bad Request",
+			wantStatusCode: http.StatusBadRequest,
+			wantBody:       "parameter seconds should be",
+			paramKeyValues: make(map[string]string, 0),
+			r:              newRequest(http.MethodGet, "/wait?seconds=3", ""),
+		},
+		{
+			name:           "4: Get on /wait with a negative jitter should return Http Status Bad Request",
+			wantStatusCode: http.StatusBadRequest,
+			wantBody:       "parameter jitter should be",
+			paramKeyValues: make(map[string]string, 0),
+			r:              newRequest(http.MethodGet, "/wait?jitter=-1", ""),
+		},
+		{
+			name:           "5: Post on /wait should return an http error method not allowed ",
 			wantStatusCode: http.StatusMethodNotAllowed,
 			wantBody:       "",
 			paramKeyValues: make(map[string]string, 0),
@@ -492,42 +467,36 @@ func TestGoHttpServerWaitHandler(t *testing.T) {
 	}
 }
 
-func TestMainExecution(t *testing.T) {
-	listenAddr := fmt.Sprintf("%s://%s:%d%s", defaultProtocol, defaultServerIp, defaultPort, defaultServerPath)
-	err := os.Setenv("PORT", fmt.Sprintf("%d", defaultPort))
+func TestGoHttpServerStartServer(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
-		t.Errorf("Unable to set env variable PORT")
-		return
+		t.Fatalf("Cannot listen: %v", err)
 	}
-	// starting main in his own go routine
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		main()
-	}()
-	WaitForHttpServer(listenAddr, 1*time.Second, 10)
-
-	resp, err := http.Get(listenAddr)
-	if err != nil {
-		t.Fatalf("Cannot make http get: %v\n", err)
+	address := busy.Addr().String()
+	myServer := NewGoHttpServer(address, getTestLogger())
+	err = myServer.StartServer()
+	if assert.Error(t, err, "StartServer should return the listen error instead of exiting") {
+		assert.Contains(t, err.Error(), address)
 	}
-	defer resp.Body.Close()
-	assert.Equal(t, http.StatusOK, resp.StatusCode, "Should return an http status ok")
-
-	receivedJson, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		t.Fatalf("Error reading response body: %v\n", err)
+	busy.Close()
+
+	myServer = NewGoHttpServer(address, getTestLogger())
+	hookCalled := make(chan struct{}, 1)
+	myServer.OnShutdown(func(ctx context.Context) error {
+		hookCalled <- struct{}{}
+		return nil
+	})
+	stopped := make(chan error, 1)
+	go func() { stopped <- myServer.StartServer() }()
+	WaitForHttpServer("http://"+address+"/health", 50*time.Millisecond, 20)
+	myServer.Stop()
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err, "a graceful shutdown should not be an error")
+	case <-time.After(5 * time.Second):
+		t.Fatal("StartServer should return after Stop")
 	}
-	var decodedResponse OsInfo
-	err = json.Unmarshal(receivedJson, &decodedResponse)
-	assert.Nil(t, err, "the output should be a valid json")
-	if err != nil {
-		t.Fatalf("Cannot decode response <%p> from server. Err: %v", receivedJson, err)
-	}
-
-	// check that receivedJson contains the specified tt.wantBody substring . https://pkg.go.dev/github.com/stretchr/testify/assert#Contains
-	assert.Contains(t, string(receivedJson), fmt.Sprintf("\"appname\": \"%s\"", APP), "Response should contain the appname field.")
-	assert.Contains(t, string(receivedJson), "\"request_id\":", "Response should contain the request_id field.")
-
+	assert.Len(t, hookCalled, 1, "the shutdown hooks should run before StartServer returns")
+	_, err = http.Get("http://" + address + "/health")
+	assert.Error(t, err, "the server should not accept connections after StartServer returned")
 }
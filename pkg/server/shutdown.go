@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ShutdownHook is a cleanup function of a subsystem, like flushing the spans or closing a connection, run during
+// the graceful shutdown. it should return when ctx is done
+type ShutdownHook func(ctx context.Context) error
+
+// OnShutdown registers a hook run once the server does not accept requests anymore. the hooks run one at a time in
+// the reverse order of their registration, like deferred calls, and share the shutdown_timeout
+func (s *GoHttpServer) OnShutdown(hook ShutdownHook) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	s.shutdownHooks = append(s.shutdownHooks, hook)
+}
+
+// (*GoHttpServer) registeredShutdownHooks returns a copy of the hooks registered so far
+func (s *GoHttpServer) registeredShutdownHooks() []ShutdownHook {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	return append([]ShutdownHook(nil), s.shutdownHooks...)
+}
+
+// shutdownHookName returns the name of the function of hook, like (*Tracer).Flush-fm
+func shutdownHookName(hook ShutdownHook) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(hook).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	name := fn.Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	return strings.TrimPrefix(name, "server.")
+}
+
+// runShutdownHooks runs the hooks in the reverse order until ctx is done, a hook still running then is abandoned
+// and the next ones are given the expired ctx, so they can still do what does not need to wait
+func runShutdownHooks(ctx context.Context, logger *slog.Logger, hooks []ShutdownHook) {
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		name := shutdownHookName(hook)
+		start := time.Now()
+		done := make(chan error, 1)
+		go func() {
+			done <- hook(ctx)
+		}()
+		var err error
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		duration := time.Since(start)
+		if err != nil {
+			logger.Error("shutdown hook failed", "hook", name, "order", len(hooks)-i, "duration", duration.String(), "error", err)
+			continue
+		}
+		logger.Info("shutdown hook done", "hook", name, "order", len(hooks)-i, "duration", duration.String())
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunShutdownHooks(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	record := func(name string, err error) ShutdownHook {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return err
+		}
+	}
+	blocked := make(chan struct{})
+	defer close(blocked)
+	hooks := []ShutdownHook{
+		func(ctx context.Context) error {
+			// ignores ctx, it should be abandoned
+			<-blocked
+			return nil
+		},
+		record("first", nil),
+		record("failing", errors.New("broken")),
+		record("last", nil),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	runShutdownHooks(ctx, getTestLogger(), hooks)
+	assert.Less(t, time.Since(start), time.Second, "a hook ignoring ctx should not block the shutdown")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"last", "failing", "first"}, order, "the hooks should run in the reverse order, even after a failure")
+}
+
+func TestShutdownHookName(t *testing.T) {
+	tracer := &Tracer{}
+	assert.Equal(t, "(*Tracer).Shutdown-fm", shutdownHookName(tracer.Shutdown))
+	assert.Equal(t, "TestShutdownHookName.func1", shutdownHookName(func(ctx context.Context) error { return nil }))
+}
+
+func TestGoHttpServerOnShutdown(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	assert.Empty(t, myServer.registeredShutdownHooks(), "there should be no hook without tracing")
+	var calls []string
+	myServer.OnShutdown(func(ctx context.Context) error {
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline, "the hooks should be bounded")
+		calls = append(calls, "db")
+		return nil
+	})
+	myServer.OnShutdown(func(ctx context.Context) error {
+		calls = append(calls, "watcher")
+		return nil
+	})
+	myServer.httpServer.Addr = "127.0.0.1:0"
+	go myServer.httpServer.ListenAndServe()
+	time.Sleep(50 * time.Millisecond)
+	drainAndShutdown(&myServer.httpServer, getTestLogger(), myServer.readiness, myServer.registeredShutdownHooks(), syscall.SIGINT, 0, time.Second)
+	assert.Equal(t, []string{"watcher", "db"}, calls)
+	assert.Equal(t, http.ErrServerClosed, myServer.httpServer.ListenAndServe(), "the server should be stopped before the hooks")
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://127.0.0.1:4318")
+	myServer = NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	if hooks := myServer.registeredShutdownHooks(); assert.Len(t, hooks, 1, "the spans should be flushed on shutdown") {
+		assert.Equal(t, "(*Tracer).Shutdown-fm", shutdownHookName(hooks[0]))
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const maxSinkBytes = maxGenerateBytes // bodies bigger than this are refused with a 413
+
+// SinkReport is what /sink received of the request body, the counterpart of /generate for the uploads
+type SinkReport struct {
+	Bytes          int64   `json:"bytes"`
+	ContentLength  int64   `json:"content_length"` // announced by the client, -1 when the body was chunked
+	Chunked        bool    `json:"chunked"`
+	ContentType    string  `json:"content_type,omitempty"`
+	Seconds        float64 `json:"seconds"`
+	ThroughputMbps float64 `json:"throughput_mbps"` // in megabits per second, like the network links
+	Sha256         string  `json:"sha256"`          // hex checksum of the body, to compare with the one of the client
+	Hostname       string  `json:"hostname"`
+}
+
+// ReadSink consumes body, computing its size and its checksum while it streams
+func ReadSink(body io.Reader) (SinkReport, error) {
+	hash := sha256.New()
+	start := time.Now()
+	n, err := io.Copy(hash, body)
+	elapsed := time.Since(start)
+	return SinkReport{
+		Bytes:          n,
+		Seconds:        elapsed.Seconds(),
+		ThroughputMbps: megabitsPerSecond(n, elapsed),
+		Sha256:         hex.EncodeToString(hash.Sum(nil)),
+	}, err
+}
+
+//############# BEGIN INFO HANDLERS
+
+// SinkHandler reads and discards the request body, reporting the bytes received, the throughput and the sha256 of
+// the body, to test the uploads of the clients through the ingress. the max parameter (like 10M) lowers the size
+// accepted before answering 413, it is 1G at most
+func (s *GoHttpServer) SinkHandler() http.HandlerFunc {
+	handlerName := "SinkHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	hostname, _ := os.Hostname()
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := int64(maxSinkBytes)
+		if val := r.URL.Query().Get("max"); val != "" {
+			var err error
+			limit, err = parseByteSize(val)
+			if err != nil || limit > maxSinkBytes {
+				http.Error(w, fmt.Sprintf("ERROR: parameter max should be a size like 10M between 0 and %d bytes", maxSinkBytes), http.StatusBadRequest)
+				return
+			}
+		}
+		if r.ContentLength > limit {
+			http.Error(w, fmt.Sprintf("ERROR: the body of %d bytes is bigger than the %d bytes accepted", r.ContentLength, limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		// the error is ignored, when the connection does not support deadlines there is no timeout to extend
+		_ = http.NewResponseController(w).SetReadDeadline(time.Now().Add(maxLoadDuration))
+		report, err := ReadSink(http.MaxBytesReader(w, r.Body, limit))
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			http.Error(w, fmt.Sprintf("ERROR: the body is bigger than the %d bytes accepted", limit), http.StatusRequestEntityTooLarge)
+			return
+		case err != nil:
+			s.logger.DebugContext(r.Context(), "reading the body failed", "handler", handlerName, "bytes", report.Bytes, "error", err)
+			http.Error(w, "ERROR: reading the body failed : "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		report.ContentLength = r.ContentLength
+		report.Chunked = len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked"
+		report.ContentType = r.Header.Get(HeaderContentType)
+		report.Hostname = hostname
+		s.render(w, r, http.StatusOK, report)
+	}
+}
+
+// ############# END INFO HANDLERS
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadSink(t *testing.T) {
+	report, err := ReadSink(strings.NewReader("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), report.Bytes)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", report.Sha256)
+}
+
+// onlyReader hides the Len of a reader, so the client sends it chunked
+type onlyReader struct {
+	io.Reader
+}
+
+func TestGoHttpServerSinkHandler(t *testing.T) {
+	ts := httptest.NewServer(NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger()).router)
+	defer ts.Close()
+	body := strings.Repeat("upload me ", 10000)
+	sum := sha256.Sum256([]byte(body))
+	tests := []struct {
+		name        string
+		method      string
+		query       string
+		chunked     bool
+		wantStatus  int
+		wantChunked bool
+	}{
+		{name: "1: a body with a Content-Length should be received", method: http.MethodPost, wantStatus: http.StatusOK},
+		{name: "2: a chunked body should be received", method: http.MethodPost, chunked: true, wantStatus: http.StatusOK, wantChunked: true},
+		{name: "3: a PUT should be received", method: http.MethodPut, wantStatus: http.StatusOK},
+		{name: "4: a Content-Length above max should be refused at once", method: http.MethodPost, query: "?max=10K", wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "5: a chunked body above max should be refused", method: http.MethodPost, query: "?max=10K", chunked: true, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "6: an invalid max should be refused", method: http.MethodPost, query: "?max=2G", wantStatus: http.StatusBadRequest},
+		{name: "7: a GET should not be allowed", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reader io.Reader = strings.NewReader(body)
+			if tt.chunked {
+				reader = onlyReader{reader}
+			}
+			req, _ := http.NewRequest(tt.method, ts.URL+"/sink"+tt.query, reader)
+			req.Header.Set(HeaderContentType, "text/plain")
+			resp, err := http.DefaultClient.Do(req)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer resp.Body.Close()
+			if !assert.Equal(t, tt.wantStatus, resp.StatusCode) || tt.wantStatus != http.StatusOK {
+				return
+			}
+			var report SinkReport
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+			assert.Equal(t, int64(len(body)), report.Bytes)
+			assert.Equal(t, hex.EncodeToString(sum[:]), report.Sha256)
+			assert.Equal(t, tt.wantChunked, report.Chunked)
+			if tt.wantChunked {
+				assert.Equal(t, int64(-1), report.ContentLength)
+			} else {
+				assert.Equal(t, int64(len(body)), report.ContentLength)
+			}
+			assert.Equal(t, "text/plain", report.ContentType)
+			assert.NotEmpty(t, report.Hostname)
+		})
+	}
+}
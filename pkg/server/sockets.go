@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const maxListedConnections = 1000 // established connections detailed by /info/sockets, the counts include all of them
+
+// tcpStates are the states of the st column of /proc/net/tcp, from include/net/tcp_states.h
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED", "02": "SYN_SENT", "03": "SYN_RECV", "04": "FIN_WAIT1", "05": "FIN_WAIT2", "06": "TIME_WAIT",
+	"07": "CLOSE", "08": "CLOSE_WAIT", "09": "LAST_ACK", "0A": "LISTEN", "0B": "CLOSING", "0C": "NEW_SYN_RECV",
+}
+
+// SocketInfo is a tcp socket of the network namespace of the pod
+type SocketInfo struct {
+	Protocol      string `json:"protocol"` // tcp or tcp6
+	LocalAddress  string `json:"local_address"`
+	RemoteAddress string `json:"remote_address,omitempty"` // omitted for the listening sockets
+	State         string `json:"state"`
+	TxQueue       int64  `json:"tx_queue"`
+	RxQueue       int64  `json:"rx_queue"` // for a listening socket, the connections waiting to be accepted
+	Uid           int    `json:"uid"`
+	Inode         string `json:"inode"`
+	Self          bool   `json:"self,omitempty"` // socket of this server, the others belong to the sidecars or the kernel
+}
+
+// SocketsReport is the answer of /info/sockets
+type SocketsReport struct {
+	States      map[string]int `json:"states"` // number of sockets in each state
+	Listening   []SocketInfo   `json:"listening"`
+	Established []SocketInfo   `json:"established"`
+	Truncated   bool           `json:"truncated,omitempty"` // more than 1000 established connections
+	Errors      []string       `json:"errors,omitempty"`    // problems met while collecting the information
+}
+
+// decodeProcNetAddress decodes an address of /proc/net/tcp like 0100007F:1F90, the ip is made of 32 bits words
+// in host byte order, so little endian on the usual architectures, the port is big endian
+func decodeProcNetAddress(address string) (string, error) {
+	hexIp, hexPort, found := strings.Cut(address, ":")
+	if !found {
+		return "", fmt.Errorf("invalid address %q", address)
+	}
+	raw, err := hex.DecodeString(hexIp)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return "", fmt.Errorf("invalid address %q", address)
+	}
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return "", fmt.Errorf("invalid port in address %q", address)
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		binary.BigEndian.PutUint32(ip[i:], binary.LittleEndian.Uint32(raw[i:]))
+	}
+	return net.JoinHostPort(ip.String(), strconv.FormatUint(port, 10)), nil
+}
+
+// ReadProcNetTcp returns the sockets of the /proc/net/tcp or tcp6 file at path, labelled with protocol
+func ReadProcNetTcp(path, protocol string) ([]SocketInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var sockets []SocketInfo
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // skip the header line
+	for scanner.Scan() {
+		// sl, local_address, rem_address, st, tx_queue:rx_queue, tr:tm->when, retrnsmt, uid, timeout, inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		socket := SocketInfo{Protocol: protocol, State: tcpStates[fields[3]], Inode: fields[9]}
+		if socket.State == "" {
+			socket.State = fields[3]
+		}
+		if socket.LocalAddress, err = decodeProcNetAddress(fields[1]); err != nil {
+			return nil, err
+		}
+		if socket.State != "LISTEN" {
+			if socket.RemoteAddress, err = decodeProcNetAddress(fields[2]); err != nil {
+				return nil, err
+			}
+		}
+		tx, rx, _ := strings.Cut(fields[4], ":")
+		socket.TxQueue, _ = strconv.ParseInt(tx, 16, 64)
+		socket.RxQueue, _ = strconv.ParseInt(rx, 16, 64)
+		socket.Uid, _ = strconv.Atoi(fields[7])
+		sockets = append(sockets, socket)
+	}
+	return sockets, scanner.Err()
+}
+
+// GetSocketsReport returns the tcp sockets of the tcp and tcp6 files of procNetDir, the ones of this server are
+// found with the socket inodes of the fd directory at fdDir
+func GetSocketsReport(procNetDir, fdDir string) SocketsReport {
+	report := SocketsReport{States: map[string]int{}, Listening: []SocketInfo{}, Established: []SocketInfo{}}
+	own := socketInodes(fdDir)
+	for _, protocol := range []string{"tcp", "tcp6"} {
+		sockets, err := ReadProcNetTcp(filepath.Join(procNetDir, protocol), protocol)
+		if err != nil {
+			// tcp6 is missing when ipv6 is disabled
+			if !os.IsNotExist(err) || protocol == "tcp" {
+				report.Errors = append(report.Errors, protocol+": "+err.Error())
+			}
+			continue
+		}
+		for _, socket := range sockets {
+			socket.Self = own[socket.Inode]
+			report.States[socket.State]++
+			switch {
+			case socket.State == "LISTEN":
+				report.Listening = append(report.Listening, socket)
+			case socket.State != "ESTABLISHED":
+			case len(report.Established) < maxListedConnections:
+				report.Established = append(report.Established, socket)
+			default:
+				report.Truncated = true
+			}
+		}
+	}
+	return report
+}
+
+//############# BEGIN INFO HANDLERS
+
+// SocketsHandler returns the listening sockets and the established connections of the network namespace of the pod
+func (s *GoHttpServer) SocketsHandler(procNetDir, fdDir string) http.HandlerFunc {
+	handlerName := "SocketsHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, r, http.StatusOK, GetSocketsReport(procNetDir, fdDir))
+	}
+}
+
+// ############# END INFO HANDLERS
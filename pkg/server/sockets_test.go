@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const testProcNetTcp = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:1F90 00000000:0000 0A 00000000:00000003 00:00000000 00000000  1000        0 1114 1 00000000d787fb20 100 0 0 10 0
+   1: 0100007F:3A98 00000000:0000 0A 00000000:00000000 00:00000000 00000000 65534        0 2001 1 00000000d787fb20 100 0 0 10 0
+   2: 0A2A0105:1F90 0A2A0001:C350 01 00000010:00000000 02:000AFC7E 00000000  1000        0 1120 2 00000000a7f8a22c 20 4 30 10 -1
+   3: 0A2A0105:1F90 0A2A0001:C352 06 00000000:00000000 03:0000136E 00000000     0        0 0 3 00000000378d3cd0
+`
+
+const testProcNetTcp6 = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:1F91 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 1130 1 0000000000000000 100 0 0 10 0
+   1: 0000000000000000FFFF00000100007F:1F90 0000000000000000FFFF00000100007F:E45C 01 00000000:00000000 00:00000000 00000000  1000        0 1131 1 0000000000000000 20 4 30 10 -1
+`
+
+func TestDecodeProcNetAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string
+		wantErr bool
+	}{
+		{name: "1: an ipv4 address should be little endian", address: "0100007F:1F90", want: "127.0.0.1:8080"},
+		{name: "2: an ipv6 address should be made of little endian words", address: "B80D0120000000000000000001000000:0050", want: "[2001:db8::1]:80"},
+		{name: "3: an ipv4 mapped address should be shown as ipv4", address: "0000000000000000FFFF00000100007F:1F90", want: "127.0.0.1:8080"},
+		{name: "4: an address without port should be an error", address: "0100007F", wantErr: true},
+		{name: "5: an address of the wrong size should be an error", address: "01007F:1F90", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeProcNetAddress(tt.address)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// writeTestProcNet returns a proc net directory with a tcp and a tcp6 file
+func writeTestProcNet(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range map[string]string{"tcp": testProcNetTcp, "tcp6": testProcNetTcp6} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestGetSocketsReport(t *testing.T) {
+	fdDir := writeTestFdDir(t, map[string]string{"3": "socket:[1114]", "4": "socket:[1120]"})
+	report := GetSocketsReport(writeTestProcNet(t), fdDir)
+	assert.Empty(t, report.Errors)
+	assert.Equal(t, map[string]int{"LISTEN": 3, "ESTABLISHED": 2, "TIME_WAIT": 1}, report.States)
+	if !assert.Len(t, report.Listening, 3) || !assert.Len(t, report.Established, 2) {
+		return
+	}
+	tests := []struct {
+		name string
+		got  SocketInfo
+		want SocketInfo
+	}{
+		{name: "1: a listening socket of the server should be marked", got: report.Listening[0],
+			want: SocketInfo{Protocol: "tcp", LocalAddress: "0.0.0.0:8080", State: "LISTEN", RxQueue: 3, Uid: 1000, Inode: "1114", Self: true}},
+		{name: "2: a listening socket of a sidecar should not be marked", got: report.Listening[1],
+			want: SocketInfo{Protocol: "tcp", LocalAddress: "127.0.0.1:15000", State: "LISTEN", Uid: 65534, Inode: "2001"}},
+		{name: "3: an established connection should give the remote address", got: report.Established[0],
+			want: SocketInfo{Protocol: "tcp", LocalAddress: "5.1.42.10:8080", RemoteAddress: "1.0.42.10:50000", State: "ESTABLISHED", TxQueue: 16, Uid: 1000, Inode: "1120", Self: true}},
+		{name: "4: a tcp6 socket should be read", got: report.Listening[2],
+			want: SocketInfo{Protocol: "tcp6", LocalAddress: "[::]:8081", State: "LISTEN", Uid: 1000, Inode: "1130"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.got)
+		})
+	}
+}
+
+func TestGoHttpServerSocketsHandler(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.SocketsHandler(defaultProcNetDir, defaultProcSelfFd))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var report SocketsReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("the output should be a valid json : %v", err)
+	}
+	if _, err := os.Stat(defaultProcNetDir + "/tcp"); err != nil {
+		t.Skip("no proc filesystem")
+	}
+	found := false
+	for _, socket := range report.Listening {
+		found = found || (socket.LocalAddress == ln.Addr().String() && socket.Self)
+	}
+	assert.True(t, found, "the listener of the test should be found")
+}
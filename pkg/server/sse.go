@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+const MIMETextEventStream = "text/event-stream"
+
+// SseStatsHandler streams a LiveStats every interval seconds as Server-Sent Events until the client disconnects,
+// curl -N is enough to follow them
+func (s *GoHttpServer) SseStatsHandler() http.HandlerFunc {
+	handlerName := "SseStatsHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	hostname, _ := os.Hostname()
+	return func(w http.ResponseWriter, r *http.Request) {
+		seconds, err := parseIntParam(r, "interval", int(defaultStatsInterval.Seconds()), 1, maxStatsInterval)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		interval := time.Duration(seconds) * time.Second
+		rc := http.NewResponseController(w)
+		w.Header().Set(HeaderContentType, MIMETextEventStream)
+		w.Header().Set("Cache-Control", "no-cache")
+		// tells nginx not to buffer the stream
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for id := 1; ; id++ {
+			data, err := json.Marshal(s.liveStats(hostname))
+			if err != nil {
+				s.logger.ErrorContext(r.Context(), "JSON marshal failed", "handler", handlerName, "error", err)
+				return
+			}
+			extendWriteDeadline(w, interval)
+			if _, err := fmt.Fprintf(w, "event: stats\nid: %d\ndata: %s\n\n", id, data); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				s.logger.ErrorContext(r.Context(), "the response cannot be streamed", "handler", handlerName, "error", err)
+				return
+			}
+			select {
+			case <-r.Context().Done():
+				s.logger.DebugContext(r.Context(), "event stream closed by the client", "handler", handlerName, "events", id)
+				return
+			case <-ticker.C:
+			}
+		}
+	}
+}
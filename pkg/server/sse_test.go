@@ -0,0 +1,54 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoHttpServerSseStats(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/events/stats?interval=1", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, MIMETextEventStream, resp.Header.Get(HeaderContentType))
+
+	var ids []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && len(ids) < 2 {
+		line := scanner.Text()
+		if id, found := strings.CutPrefix(line, "id: "); found {
+			ids = append(ids, id)
+		}
+		if data, found := strings.CutPrefix(line, "data: "); found {
+			var stats LiveStats
+			assert.NoError(t, json.Unmarshal([]byte(data), &stats), "each event should contain a valid json")
+			assert.Greater(t, stats.NumGoroutine, 0)
+		}
+	}
+	assert.Equal(t, []string{"1", "2"}, ids, "the events should keep coming every interval")
+
+	resp, err = http.Get(ts.URL + "/events/stats?interval=61")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
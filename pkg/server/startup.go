@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	startupStatusStarted  = "started"
+	startupStatusStarting = "starting"
+)
+
+// StartupReport is the answer of /started
+type StartupReport struct {
+	Status           string  `json:"status"` // started or starting
+	WarmUpSeconds    float64 `json:"warm_up_seconds"`
+	ElapsedSeconds   float64 `json:"elapsed_seconds"`
+	RemainingSeconds float64 `json:"remaining_seconds"`
+	MarkedReady      bool    `json:"marked_ready"` // true when POST /admin/ready ended the warm-up
+}
+
+// StartupGate simulates the initialization of an application, it is started once warmUp has elapsed since start
+// or when MarkStarted is called, whichever comes first
+type StartupGate struct {
+	start  time.Time
+	warmUp time.Duration
+	now    func() time.Time // time.Now, replaced in tests
+	marked int32
+}
+
+// NewStartupGate is a constructor for a StartupGate, a zero warmUp gives a gate started at once
+func NewStartupGate(start time.Time, warmUp time.Duration) *StartupGate {
+	return &StartupGate{start: start, warmUp: warmUp, now: time.Now}
+}
+
+// MarkStarted ends the warm-up before its term
+func (sg *StartupGate) MarkStarted() {
+	atomic.StoreInt32(&sg.marked, 1)
+}
+
+// Started returns true once the initialization is complete
+func (sg *StartupGate) Started() bool {
+	return sg.Report().Status == startupStatusStarted
+}
+
+// Report returns the state of the initialization
+func (sg *StartupGate) Report() StartupReport {
+	elapsed := sg.now().Sub(sg.start)
+	report := StartupReport{
+		Status:         startupStatusStarting,
+		WarmUpSeconds:  sg.warmUp.Seconds(),
+		ElapsedSeconds: elapsed.Seconds(),
+		MarkedReady:    atomic.LoadInt32(&sg.marked) == 1,
+	}
+	if report.MarkedReady || elapsed >= sg.warmUp {
+		report.Status = startupStatusStarted
+	} else {
+		report.RemainingSeconds = (sg.warmUp - elapsed).Seconds()
+	}
+	return report
+}
+
+// StartupCheck is a readiness check failing during the warm-up, a pod without startupProbe does not receive traffic
+// before its initialization is complete either
+type StartupCheck struct {
+	Gate *StartupGate
+}
+
+func (c *StartupCheck) Name() string { return "startup" }
+func (c *StartupCheck) Type() string { return "runtime" }
+
+func (c *StartupCheck) Check(_ context.Context) error {
+	if !c.Gate.Started() {
+		return errors.New("the initialization is not complete")
+	}
+	return nil
+}
+
+//############# BEGIN STARTUP HANDLERS
+
+// StartedHandler answers 503 until the initialization is complete then 200, to test a startupProbe configuration
+func (s *GoHttpServer) StartedHandler(gate *StartupGate) http.HandlerFunc {
+	handlerName := "StartedHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := gate.Report()
+		status := http.StatusOK
+		if report.Status != startupStatusStarted {
+			status = http.StatusServiceUnavailable
+		}
+		s.render(w, r, status, report)
+	}
+}
+
+// AdminReadyHandler marks the initialization complete, so /started succeeds before the end of the warm-up
+func (s *GoHttpServer) AdminReadyHandler(gate *StartupGate) http.HandlerFunc {
+	handlerName := "AdminReadyHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !gate.Started() {
+			s.audit("initialization marked complete before the end of the warm-up", r)
+		}
+		gate.MarkStarted()
+		s.render(w, r, http.StatusOK, gate.Report())
+	}
+}
+
+// ############# END STARTUP HANDLERS
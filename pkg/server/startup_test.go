@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartupGateReport(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		warmUp        time.Duration
+		elapsed       time.Duration
+		mark          bool
+		wantStatus    string
+		wantRemaining float64
+	}{
+		{name: "1: zero warm-up should be started at once", wantStatus: startupStatusStarted},
+		{name: "2: during the warm-up should be starting", warmUp: 30 * time.Second, elapsed: 10 * time.Second, wantStatus: startupStatusStarting, wantRemaining: 20},
+		{name: "3: after the warm-up should be started", warmUp: 30 * time.Second, elapsed: 30 * time.Second, wantStatus: startupStatusStarted},
+		{name: "4: marked during the warm-up should be started", warmUp: 30 * time.Second, elapsed: time.Second, mark: true, wantStatus: startupStatusStarted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gate := NewStartupGate(start, tt.warmUp)
+			gate.now = func() time.Time { return start.Add(tt.elapsed) }
+			if tt.mark {
+				gate.MarkStarted()
+			}
+			report := gate.Report()
+			assert.Equal(t, tt.wantStatus, report.Status)
+			assert.Equal(t, tt.wantRemaining, report.RemainingSeconds)
+			assert.Equal(t, tt.mark, report.MarkedReady)
+			assert.Equal(t, tt.wantStatus == startupStatusStarted, gate.Started())
+			err := (&StartupCheck{Gate: gate}).Check(context.Background())
+			assert.Equal(t, tt.wantStatus == startupStatusStarted, err == nil, "the readiness check should fail during the warm-up")
+		})
+	}
+}
+
+func TestGoHttpServerStartedHandler(t *testing.T) {
+	t.Setenv("STARTUP_DELAY_SECONDS", "3600")
+	t.Setenv("API_TOKEN", "s3cret")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		anonymous      bool
+		wantStatusCode int
+		wantStatus     string
+	}{
+		{name: "1: /started during the warm-up should be unavailable", method: http.MethodGet, url: "/started", wantStatusCode: http.StatusServiceUnavailable, wantStatus: startupStatusStarting},
+		{name: "2: /readiness during the warm-up should be unavailable", method: http.MethodGet, url: "/readiness", wantStatusCode: http.StatusServiceUnavailable},
+		{name: "3: GET /admin/ready should not be allowed", method: http.MethodGet, url: "/admin/ready", wantStatusCode: http.StatusMethodNotAllowed},
+		{name: "4: POST /admin/ready without credentials should be refused", method: http.MethodPost, url: "/admin/ready", anonymous: true, wantStatusCode: http.StatusUnauthorized},
+		{name: "5: /started after a refused /admin/ready should still be unavailable", method: http.MethodGet, url: "/started", wantStatusCode: http.StatusServiceUnavailable, wantStatus: startupStatusStarting},
+		{name: "6: POST /admin/ready should end the warm-up", method: http.MethodPost, url: "/admin/ready", wantStatusCode: http.StatusOK, wantStatus: startupStatusStarted},
+		{name: "7: /started after /admin/ready should succeed", method: http.MethodGet, url: "/started", wantStatusCode: http.StatusOK, wantStatus: startupStatusStarted},
+		{name: "8: /readiness after /admin/ready should succeed", method: http.MethodGet, url: "/readiness", wantStatusCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.anonymous {
+				req.Header.Set("Authorization", "Bearer s3cret")
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode, assertCorrectStatusCodeExpected)
+			if tt.wantStatus != "" {
+				var report StartupReport
+				assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report), "the output should be a valid json")
+				assert.Equal(t, tt.wantStatus, report.Status)
+			}
+		})
+	}
+}
+
+func TestGoHttpServerAdminReadyOnAdminPort(t *testing.T) {
+	t.Setenv("STARTUP_DELAY_SECONDS", "3600")
+	t.Setenv("ADMIN_PORT", "8081")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	rec := httptest.NewRecorder()
+	myServer.adminServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/ready", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code, "POST /admin/ready without credentials should be refused on ADMIN_PORT")
+	assert.False(t, myServer.startup.Started(), "a refused /admin/ready should not end the warm-up")
+}
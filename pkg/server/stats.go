@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// RouteLatency gives the latency percentiles of the requests of a route, in milliseconds
+type RouteLatency struct {
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P95  float64 `json:"p95_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// RouteStats are the statistics of the requests served by a route since the start
+type RouteStats struct {
+	Path         string       `json:"path"`
+	Count        uint64       `json:"count"`
+	ClientErrors uint64       `json:"client_errors"` // 4xx answers
+	ServerErrors uint64       `json:"server_errors"` // 5xx answers
+	ErrorRate    float64      `json:"error_rate"`    // ratio of the 5xx answers
+	InFlight     int64        `json:"in_flight"`     // requests being served, a stuck handler shows here
+	Latency      RouteLatency `json:"latency"`
+}
+
+// StatsReport is the answer of /stats
+type StatsReport struct {
+	Since         time.Time    `json:"since"`
+	UptimeSeconds float64      `json:"uptime_seconds"`
+	Requests      uint64       `json:"requests"`
+	InFlight      int64        `json:"in_flight"`
+	Routes        []RouteStats `json:"routes"` // only the routes which received requests, sorted by path
+}
+
+// Stats returns the statistics of every route which received requests or is serving one
+func (m *Metrics) Stats() StatsReport {
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	report := StatsReport{
+		Since:         m.startTime.UTC(),
+		UptimeSeconds: time.Since(m.startTime).Seconds(),
+		InFlight:      atomic.LoadInt64(&m.inFlight),
+		Routes:        []RouteStats{},
+	}
+	m.mu.Lock()
+	for path, rs := range m.routes {
+		stats := RouteStats{
+			Path:         path,
+			Count:        rs.latency.Count(),
+			ClientErrors: rs.clientErrors,
+			ServerErrors: rs.serverErrors,
+			InFlight:     atomic.LoadInt64(&rs.inFlight),
+		}
+		if stats.Count == 0 && stats.InFlight == 0 {
+			continue
+		}
+		if stats.Count > 0 {
+			stats.ErrorRate = float64(stats.ServerErrors) / float64(stats.Count)
+			stats.Latency = RouteLatency{
+				Mean: ms(rs.latency.Sum() / time.Duration(stats.Count)),
+				P50:  ms(rs.latency.Quantile(0.5)),
+				P95:  ms(rs.latency.Quantile(0.95)),
+				P99:  ms(rs.latency.Quantile(0.99)),
+				Max:  ms(rs.latency.Max()),
+			}
+		}
+		report.Requests += stats.Count
+		report.Routes = append(report.Routes, stats)
+	}
+	m.mu.Unlock()
+	sort.Slice(report.Routes, func(i, j int) bool { return report.Routes[i].Path < report.Routes[j].Path })
+	return report
+}
+
+//############# BEGIN METRICS HANDLERS
+
+// StatsHandler returns the count, errors, in-flight requests and latency percentiles of each route
+func (s *GoHttpServer) StatsHandler() http.HandlerFunc {
+	handlerName := "StatsHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.render(w, r, http.StatusOK, s.metrics.Stats())
+	}
+}
+
+// ############# END METRICS HANDLERS
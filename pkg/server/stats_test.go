@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoHttpServerStatsHandler(t *testing.T) {
+	t.Setenv("ENABLE_CHAOS", "true")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+	for _, path := range []string{"/time", "/time", "/chaos/error?code=503", "/chaos/error?code=418"} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(ts.URL + "/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var report StatsReport
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report), "the output should be a valid json")
+	routes := make(map[string]RouteStats)
+	for _, rs := range report.Routes {
+		routes[rs.Path] = rs
+	}
+
+	tests := []struct {
+		name             string
+		path             string
+		wantCount        uint64
+		wantClientErrors uint64
+		wantServerErrors uint64
+		wantInFlight     int64
+	}{
+		{name: "1: successful requests should be counted", path: "/time", wantCount: 2},
+		{name: "2: errors should be counted by class", path: "/chaos/error", wantCount: 2, wantClientErrors: 1, wantServerErrors: 1},
+		{name: "3: the request for the stats should be in flight", path: "/stats", wantInFlight: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, found := routes[tt.path]
+			if !assert.True(t, found, "route %s should be reported", tt.path) {
+				return
+			}
+			assert.Equal(t, tt.wantCount, rs.Count)
+			assert.Equal(t, tt.wantClientErrors, rs.ClientErrors)
+			assert.Equal(t, tt.wantServerErrors, rs.ServerErrors)
+			assert.Equal(t, tt.wantInFlight, rs.InFlight)
+			if rs.Count > 0 {
+				assert.GreaterOrEqual(t, rs.Latency.Max, rs.Latency.P50)
+			}
+		})
+	}
+	_, found := routes["/readiness"]
+	assert.False(t, found, "the routes without request should not be reported")
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultNtpTimeout     = 2 * time.Second
+	defaultZoneInfoDir    = "/usr/share/zoneinfo"
+	defaultLocalTimePath  = "/etc/localtime"
+	ntpPacketSize         = 48
+	ntpEpochOffset        = 2208988800 // seconds from 1900-01-01, the epoch of ntp, to 1970-01-01
+	timeWarnOffset        = time.Second
+	timeWarnWallDrift     = time.Second // a wall clock moving away from the monotonic one since the start was stepped
+	timeZoneProbeLocation = "Europe/Zurich"
+)
+
+// timeFormats are the representations of the time param format of /time
+var timeFormats = map[string]func(t time.Time) string{
+	"rfc3339": func(t time.Time) string { return t.Format(time.RFC3339) },
+	"rfc1123": func(t time.Time) string { return t.Format(time.RFC1123) },
+	"unix":    func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) },
+}
+
+// tzdataEmbedded is set by tzdata.go when the binary is built with -tags timetzdata
+var tzdataEmbedded bool
+
+// ErrNtpKissOfDeath is returned when the ntp server answers with stratum 0, asking the client to go away
+var ErrNtpKissOfDeath = errors.New("ntp server sent a kiss of death")
+
+// NtpResult is the clock offset measured with an ntp server, like the one of ntpdate -q
+type NtpResult struct {
+	Server        string    `json:"server"`
+	Stratum       int       `json:"stratum"`
+	ReferenceId   string    `json:"reference_id"`
+	ServerTime    time.Time `json:"server_time"`
+	OffsetSeconds float64   `json:"offset_seconds"` // positive when the local clock is late
+	DelaySeconds  float64   `json:"delay_seconds"`  // round trip time to the server
+}
+
+// ntpTime converts a 64 bits ntp timestamp, seconds and fraction since 1900, to a time
+func ntpTime(b []byte) time.Time {
+	seconds, fraction := binary.BigEndian.Uint32(b), binary.BigEndian.Uint32(b[4:])
+	nanos := (int64(fraction) * 1e9) >> 32
+	return time.Unix(int64(seconds)-ntpEpochOffset, nanos).UTC()
+}
+
+// QueryNtp sends an SNTP v4 request to the udp address and returns the offset of the local clock, the offset is
+// ((t2 - t1) + (t3 - t4)) / 2 where t1 and t4 are the local times of the request and the answer, t2 and t3 the
+// times of the server
+func QueryNtp(ctx context.Context, address string, timeout time.Duration) (NtpResult, error) {
+	result := NtpResult{Server: address}
+	var dialer net.Dialer
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := dialer.DialContext(ctx, "udp", address)
+	if err != nil {
+		return result, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	request := make([]byte, ntpPacketSize)
+	request[0] = 0x23 // leap indicator 0, version 4, mode 3 client
+	t1 := time.Now()
+	if _, err := conn.Write(request); err != nil {
+		return result, err
+	}
+	answer := make([]byte, ntpPacketSize)
+	n, err := conn.Read(answer)
+	t4 := time.Now()
+	if err != nil {
+		return result, err
+	}
+	if n < ntpPacketSize || answer[0]&0x07 != 4 {
+		return result, fmt.Errorf("invalid ntp answer of %d bytes from %s", n, address)
+	}
+	result.Stratum = int(answer[1])
+	if result.Stratum == 0 {
+		return result, fmt.Errorf("%w : %s", ErrNtpKissOfDeath, strings.TrimRight(string(answer[12:16]), "\x00"))
+	}
+	if result.Stratum == 1 {
+		result.ReferenceId = strings.TrimRight(string(answer[12:16]), "\x00")
+	} else {
+		result.ReferenceId = net.IP(answer[12:16]).String()
+	}
+	t2, t3 := ntpTime(answer[32:40]), ntpTime(answer[40:48])
+	offset := (t2.Sub(t1) + t3.Sub(t4)) / 2
+	delay := t4.Sub(t1) - t3.Sub(t2)
+	result.ServerTime = t3
+	result.OffsetSeconds, result.DelaySeconds = offset.Seconds(), delay.Seconds()
+	return result, nil
+}
+
+// TimeZoneInfo tells which time zone the container uses and where the zone database comes from
+type TimeZoneInfo struct {
+	Local          string `json:"local"` // name of time.Local, Local when it was read from /etc/localtime
+	Abbreviation   string `json:"abbreviation"`
+	OffsetSeconds  int    `json:"offset_seconds"`
+	Detected       string `json:"detected"` // zone of TZ or of the /etc/localtime link, UTC without both
+	TzEnv          string `json:"tz_env,omitempty"`
+	LocalTimeFile  bool   `json:"localtime_file"` // /etc/localtime exists
+	ZoneInfoDir    bool   `json:"zoneinfo_dir"`   // the zone database of the system is installed
+	ZoneInfoEnv    string `json:"zoneinfo_env,omitempty"`
+	TzDataEmbedded bool   `json:"tzdata_embedded"` // the zone database is in the binary, built with -tags timetzdata
+	TzDataLoadable bool   `json:"tzdata_loadable"` // a named zone like Europe/Zurich can be loaded, from the system or the tzdata embedded in the binary
+}
+
+// TimeResponse is the answer of /time, the time is given in the time zone of the tz param, the local one by default
+type TimeResponse struct {
+	Time           string `json:"time"` // in the format of the format param, rfc3339 by default
+	TimeZone       string `json:"time_zone"`
+	Abbreviation   string `json:"abbreviation"`
+	OffsetSeconds  int    `json:"offset_seconds"`
+	Unix           int64  `json:"unix"`
+	UnixMilli      int64  `json:"unix_milli"`
+	Rfc3339        string `json:"rfc3339"`
+	Rfc1123        string `json:"rfc1123"`
+	Utc            string `json:"utc"`
+	LocalZone      string `json:"local_zone"` // zone of the container, from TZ or /etc/localtime
+	TzDataEmbedded bool   `json:"tzdata_embedded"`
+}
+
+// GetTimeResponse returns now in the zone named tz, the local zone when empty, with every representation and the time field in format
+func GetTimeResponse(now time.Time, tz, format string) (TimeResponse, error) {
+	if format == "" {
+		format = "rfc3339"
+	}
+	formatTime, exist := timeFormats[strings.ToLower(format)]
+	if !exist {
+		return TimeResponse{}, fmt.Errorf("parameter format should be one of unix, rfc3339 or rfc1123, got %q", format)
+	}
+	location := time.Local
+	if tz != "" {
+		var err error
+		if location, err = time.LoadLocation(tz); err != nil {
+			return TimeResponse{}, fmt.Errorf("parameter tz should be a time zone like Europe/Zurich, %w", err)
+		}
+	}
+	local := now.In(location)
+	res := TimeResponse{Time: formatTime(local), TimeZone: location.String(), Unix: now.Unix(), UnixMilli: now.UnixMilli(),
+		Rfc3339: local.Format(time.RFC3339), Rfc1123: local.Format(time.RFC1123), Utc: now.UTC().Format(time.RFC3339),
+		LocalZone: detectLocalZone(defaultLocalTimePath), TzDataEmbedded: tzdataEmbedded}
+	res.Abbreviation, res.OffsetSeconds = local.Zone()
+	return res, nil
+}
+
+// TimeDiagnostics is the answer of /time/diagnostics
+type TimeDiagnostics struct {
+	WallClock        time.Time    `json:"wall_clock"`
+	UnixNano         int64        `json:"unix_nano"`
+	StartTime        time.Time    `json:"start_time"`
+	MonotonicSeconds float64      `json:"monotonic_seconds"` // time since the start measured with the monotonic clock
+	WallSeconds      float64      `json:"wall_seconds"`      // time since the start measured with the wall clock
+	WallDriftSeconds float64      `json:"wall_drift_seconds"`
+	TimeZone         TimeZoneInfo `json:"time_zone"`
+	Ntp              *NtpResult   `json:"ntp,omitempty"` // omitted without NTP_SERVER
+	Warnings         []string     `json:"warnings,omitempty"`
+	Errors           []string     `json:"errors,omitempty"` // problems met while collecting the information
+}
+
+// detectLocalZone returns the name of the local zone given by the TZ environment variable or the target of the
+// /etc/localtime link at localTimePath, Local when it is a copy of a zone file and UTC when there is none
+func detectLocalZone(localTimePath string) string {
+	if tz, exist := os.LookupEnv("TZ"); exist {
+		if tz = strings.TrimPrefix(tz, ":"); tz != "" {
+			return tz
+		}
+		return "UTC"
+	}
+	target, err := os.Readlink(localTimePath)
+	if err == nil {
+		if _, zone, found := strings.Cut(target, "zoneinfo/"); found {
+			return zone
+		}
+	}
+	if _, err := os.Stat(localTimePath); err == nil {
+		return "Local"
+	}
+	return "UTC"
+}
+
+// GetTimeZoneInfo returns the local time zone at now and where the zone database is found
+func GetTimeZoneInfo(now time.Time) TimeZoneInfo {
+	tz := TimeZoneInfo{Local: time.Local.String(), TzEnv: os.Getenv("TZ"), ZoneInfoEnv: os.Getenv("ZONEINFO")}
+	tz.Abbreviation, tz.OffsetSeconds = now.In(time.Local).Zone()
+	tz.Detected, tz.TzDataEmbedded = detectLocalZone(defaultLocalTimePath), tzdataEmbedded
+	_, err := os.Stat(defaultLocalTimePath)
+	tz.LocalTimeFile = err == nil
+	_, err = os.Stat(defaultZoneInfoDir)
+	tz.ZoneInfoDir = err == nil
+	_, err = time.LoadLocation(timeZoneProbeLocation)
+	tz.TzDataLoadable = err == nil
+	return tz
+}
+
+// GetTimeDiagnostics compares the wall and monotonic clocks since startTime, and measures the offset of the clock
+// with the ntp server at ntpAddress when it is not empty
+func GetTimeDiagnostics(ctx context.Context, startTime time.Time, ntpAddress string) TimeDiagnostics {
+	now := time.Now()
+	diag := TimeDiagnostics{WallClock: now.UTC(), UnixNano: now.UnixNano(), StartTime: startTime.UTC()}
+	monotonic, wall := now.Sub(startTime), now.Round(0).Sub(startTime.Round(0))
+	diag.MonotonicSeconds, diag.WallSeconds, diag.WallDriftSeconds = monotonic.Seconds(), wall.Seconds(), (wall - monotonic).Seconds()
+	if (wall - monotonic).Abs() > timeWarnWallDrift {
+		diag.Warnings = append(diag.Warnings, fmt.Sprintf("the wall clock moved %.3fs away from the monotonic clock since the start, it was stepped", diag.WallDriftSeconds))
+	}
+	diag.TimeZone = GetTimeZoneInfo(now)
+	if !diag.TimeZone.TzDataLoadable {
+		diag.Warnings = append(diag.Warnings, "no time zone database, only UTC can be used : install tzdata in the image or build with -tags timetzdata")
+	}
+	if ntpAddress != "" {
+		res, err := QueryNtp(ctx, ntpAddress, defaultNtpTimeout)
+		if err != nil {
+			diag.Errors = append(diag.Errors, "ntp: "+err.Error())
+		} else {
+			diag.Ntp = &res
+			if math.Abs(res.OffsetSeconds) > timeWarnOffset.Seconds() {
+				diag.Warnings = append(diag.Warnings, fmt.Sprintf("the clock of the node is %.3fs away from %s, tokens and certificates may be seen as expired or not yet valid", res.OffsetSeconds, ntpAddress))
+			}
+		}
+	}
+	return diag
+}
+
+//############# BEGIN INFO HANDLERS
+
+// TimeDiagnosticsHandler returns the wall and monotonic clocks, the time zone and the ntp offset of NTP_SERVER
+func (s *GoHttpServer) TimeDiagnosticsHandler() http.HandlerFunc {
+	handlerName := "TimeDiagnosticsHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		current := s.settings.Current()
+		s.render(w, r, http.StatusOK, GetTimeDiagnostics(r.Context(), s.startTime, current.NtpAddress()))
+	}
+}
+
+// ############# END INFO HANDLERS
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// putNtpTime writes t as a 64 bits ntp timestamp in b
+func putNtpTime(b []byte, t time.Time) {
+	binary.BigEndian.PutUint32(b, uint32(t.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(b[4:], uint32((int64(t.Nanosecond())<<32)/1e9))
+}
+
+// newFakeNtpServer answers the ntp requests with a clock ahead of the local one by offset, with stratum
+func newFakeNtpServer(t *testing.T, offset time.Duration, stratum byte) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, ntpPacketSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < ntpPacketSize {
+				continue
+			}
+			answer := make([]byte, ntpPacketSize)
+			answer[0], answer[1] = 0x24, stratum // version 4, mode 4 server
+			copy(answer[12:16], "GPS\x00")
+			now := time.Now().Add(offset)
+			putNtpTime(answer[32:40], now)
+			putNtpTime(answer[40:48], now)
+			conn.WriteTo(answer, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestQueryNtp(t *testing.T) {
+	tests := []struct {
+		name       string
+		offset     time.Duration
+		stratum    byte
+		wantErr    error
+		wantOffset float64
+	}{
+		{name: "1: a clock in time should have a small offset", stratum: 1, wantOffset: 0},
+		{name: "2: a late local clock should have a positive offset", offset: 3 * time.Second, stratum: 1, wantOffset: 3},
+		{name: "3: a kiss of death should be an error", stratum: 0, wantErr: ErrNtpKissOfDeath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := newFakeNtpServer(t, tt.offset, tt.stratum)
+			res, err := QueryNtp(context.Background(), address, time.Second)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "GPS", res.ReferenceId)
+			assert.Less(t, res.OffsetSeconds-tt.wantOffset, 0.1)
+			assert.Greater(t, res.OffsetSeconds-tt.wantOffset, -0.1)
+			assert.GreaterOrEqual(t, res.DelaySeconds, 0.0)
+		})
+	}
+}
+
+func TestGetTimeDiagnostics(t *testing.T) {
+	address := newFakeNtpServer(t, 5*time.Second, 2)
+	start := time.Now().Add(-time.Minute)
+	diag := GetTimeDiagnostics(context.Background(), start, address)
+	assert.Empty(t, diag.Errors)
+	assert.Less(t, diag.WallDriftSeconds, 0.01, "the wall clock should follow the monotonic one")
+	assert.Greater(t, diag.MonotonicSeconds, 59.0)
+	if assert.NotNil(t, diag.Ntp) {
+		assert.Equal(t, 2, diag.Ntp.Stratum)
+		assert.Equal(t, "71.80.83.0", diag.Ntp.ReferenceId, "the reference of a stratum 2 server should be an ip")
+	}
+	assert.Contains(t, diag.Warnings[len(diag.Warnings)-1], "tokens and certificates")
+
+	diag = GetTimeDiagnostics(context.Background(), start, "")
+	assert.Nil(t, diag.Ntp, "the ntp server should only be queried when it is set")
+	assert.NotEmpty(t, diag.TimeZone.Local)
+}
+
+func TestGoHttpServerTimeDiagnosticsHandler(t *testing.T) {
+	t.Setenv("NTP_SERVER", newFakeNtpServer(t, 0, 1))
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.TimeDiagnosticsHandler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	var diag TimeDiagnostics
+	if err := json.NewDecoder(resp.Body).Decode(&diag); err != nil {
+		t.Fatalf("the output should be a valid json : %v", err)
+	}
+	assert.NotNil(t, diag.Ntp, "the ntp server of NTP_SERVER should be queried")
+}
+
+func TestGetTimeResponse(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Zurich"); err != nil {
+		t.Skip("no time zone database")
+	}
+	now := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		tz         string
+		format     string
+		wantErr    bool
+		wantTime   string
+		wantZone   string
+		wantOffset int
+	}{
+		{name: "1: a zone should give its local time in rfc3339", tz: "Europe/Zurich", wantTime: "2024-03-05T13:00:00+01:00", wantZone: "Europe/Zurich", wantOffset: 3600},
+		{name: "2: unix should give the seconds", tz: "UTC", format: "unix", wantTime: "1709640000", wantZone: "UTC"},
+		{name: "3: rfc1123 should give the zone abbreviation", tz: "Europe/Zurich", format: "RFC1123", wantTime: "Tue, 05 Mar 2024 13:00:00 CET", wantZone: "Europe/Zurich", wantOffset: 3600},
+		{name: "4: an unknown zone should be an error", tz: "Europe/Nowhere", wantErr: true},
+		{name: "5: an unknown format should be an error", format: "kitchen", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := GetTimeResponse(now, tt.tz, tt.format)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantTime, res.Time)
+			assert.Equal(t, tt.wantZone, res.TimeZone)
+			assert.Equal(t, tt.wantOffset, res.OffsetSeconds)
+			assert.Equal(t, int64(1709640000), res.Unix)
+			assert.Equal(t, "2024-03-05T12:00:00Z", res.Utc)
+		})
+	}
+}
+
+func TestDetectLocalZone(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "localtime")
+	if err := os.Symlink("/usr/share/zoneinfo/Europe/Zurich", link); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TZ", ":America/New_York")
+	assert.Equal(t, "America/New_York", detectLocalZone(link), "TZ should win")
+	os.Unsetenv("TZ")
+	assert.Equal(t, "Europe/Zurich", detectLocalZone(link), "the zone should be read in the link")
+	assert.Equal(t, "UTC", detectLocalZone(filepath.Join(dir, "missing")))
+}
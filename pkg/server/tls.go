@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+)
+
+const defaultCertReloadInterval = 30 * time.Second // how often the cert files are checked for a rotation
+
+// tlsClientAuthModes maps the values of TLS_CLIENT_AUTH to the client certificate policy of the handshake
+var tlsClientAuthModes = map[string]tls.ClientAuthType{
+	config.TlsClientAuthNone:          tls.NoClientCert,
+	config.TlsClientAuthRequest:       tls.RequestClientCert,
+	config.TlsClientAuthVerifyIfGiven: tls.VerifyClientCertIfGiven,
+	config.TlsClientAuthRequire:       tls.RequireAndVerifyClientCert,
+}
+
+// tlsClientAuthName returns the TLS_CLIENT_AUTH value of the client certificate policy mode
+func tlsClientAuthName(mode tls.ClientAuthType) string {
+	for name, m := range tlsClientAuthModes {
+		if m == mode {
+			return name
+		}
+	}
+	return mode.String()
+}
+
+// GetTlsClientAuthFromConfig returns how the client certificates are asked during the handshake, based on the
+// validated tls_client_auth setting, with the CAs read from the tls_client_ca_file setting, nil when it is empty.
+// with request the client certificates are accepted without verification, which is enough to inspect them with /tls
+func GetTlsClientAuthFromConfig(settings config.Config) (tls.ClientAuthType, *x509.CertPool, error) {
+	mode := tlsClientAuthModes[settings.TlsClientAuth]
+	if settings.TlsClientCa == "" {
+		return mode, nil, nil
+	}
+	pemCerts, err := os.ReadFile(settings.TlsClientCa)
+	if err != nil {
+		return tls.NoClientCert, nil, &config.ErrorConfig{
+			Err: err,
+			Msg: "ERROR: CONFIG tls_client_ca_file (env TLS_CLIENT_CA_FILE) cannot be read",
+		}
+	}
+	cas := x509.NewCertPool()
+	if !cas.AppendCertsFromPEM(pemCerts) {
+		return tls.NoClientCert, nil, &config.ErrorConfig{
+			Err: fmt.Errorf("no certificate found in %s", settings.TlsClientCa),
+			Msg: "ERROR: CONFIG tls_client_ca_file (env TLS_CLIENT_CA_FILE) should contain pem certificates",
+		}
+	}
+	return mode, cas, nil
+}
+
+// CertReloader keeps the current certificate loaded from certFile and keyFile, and loads it again when the files change.
+// the files are polled on their modification time, this works with the symlink swap done by cert-manager on mounted secrets.
+type CertReloader struct {
+	certFile    string
+	keyFile     string
+	logger      *slog.Logger
+	mu          sync.RWMutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// NewCertReloader is a constructor for a CertReloader, it returns an error when the initial certificate cannot be loaded
+func NewCertReloader(certFile, keyFile string, logger *slog.Logger) (*CertReloader, error) {
+	cr := &CertReloader{certFile: certFile, keyFile: keyFile, logger: logger}
+	if _, err := cr.ReloadIfChanged(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+func (cr *CertReloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(cr.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	keyInfo, err := os.Stat(cr.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+// ReloadIfChanged loads the key pair again when one of the files was modified since the last load and returns true
+// when a new certificate is now in use. on error the previous certificate is kept.
+func (cr *CertReloader) ReloadIfChanged() (bool, error) {
+	certModTime, keyModTime, err := cr.modTimes()
+	if err != nil {
+		return false, err
+	}
+	cr.mu.RLock()
+	unchanged := cr.cert != nil && certModTime.Equal(cr.certModTime) && keyModTime.Equal(cr.keyModTime)
+	cr.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return false, err
+	}
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.cert = &cert
+	cr.certModTime = certModTime
+	cr.keyModTime = keyModTime
+	return true, nil
+}
+
+// GetCertificate returns the current certificate, it is meant to be used as tls.Config.GetCertificate
+func (cr *CertReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert, nil
+}
+
+// Watch checks the cert files every interval until ctx is done, logging each rotation or failed reload
+func (cr *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := cr.ReloadIfChanged()
+			if err != nil {
+				cr.logger.Error("TLS certificate reload failed, keeping the previous one", "cert_file", cr.certFile, "error", err)
+				continue
+			}
+			if reloaded {
+				cr.logger.Info("TLS certificate reloaded", "cert_file", cr.certFile)
+			}
+		}
+	}
+}
+
+// UseTLS makes the server listen in HTTPS with the certificates given by the reloader
+func (s *GoHttpServer) UseTLS(cr *CertReloader) {
+	s.certs = cr
+	s.httpServer.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cr.GetCertificate,
+	}
+}
+
+// UseTlsClientAuth sets how the client certificates are asked during the handshake, cas verifies them when the mode needs it.
+// it must be called after UseTLS and does nothing when the server stays in plain http
+func (s *GoHttpServer) UseTlsClientAuth(mode tls.ClientAuthType, cas *x509.CertPool) {
+	if s.httpServer.TLSConfig == nil {
+		return
+	}
+	s.httpServer.TLSConfig.ClientAuth = mode
+	s.httpServer.TLSConfig.ClientCAs = cas
+}
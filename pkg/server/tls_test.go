@@ -0,0 +1,167 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestKeyPair writes a self-signed certificate for localhost with the given common name in certFile and keyFile
+func writeTestKeyPair(t *testing.T, certFile, keyFile, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key : %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unable to create certificate : %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unable to marshal key : %v", err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("unable to write cert : %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatalf("unable to write key : %v", err)
+	}
+}
+
+func currentCommonName(t *testing.T, cr *CertReloader) string {
+	cert, err := cr.GetCertificate(nil)
+	assert.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("unable to parse certificate : %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestGetTlsClientAuthFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	writeTestKeyPair(t, caFile, filepath.Join(dir, "ca.key"), "test ca")
+	notPem := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPem, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("unable to write file : %v", err)
+	}
+	tests := []struct {
+		name     string
+		mode     string
+		caFile   string
+		wantMode tls.ClientAuthType
+		wantCAs  bool
+		wantErr  bool
+	}{
+		{name: "1: none should not ask client certificates", mode: "none", wantMode: tls.NoClientCert},
+		{name: "2: request should not need a CA", mode: "request", wantMode: tls.RequestClientCert},
+		{name: "3: require with a CA should verify", mode: "require", caFile: caFile, wantMode: tls.RequireAndVerifyClientCert, wantCAs: true},
+		{name: "4: missing CA file should be an error", mode: "require", caFile: filepath.Join(dir, "missing.crt"), wantErr: true},
+		{name: "5: CA file without certificate should be an error", mode: "require", caFile: notPem, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.DefaultConfig()
+			settings.TlsClientAuth, settings.TlsClientCa = tt.mode, tt.caFile
+			mode, cas, err := GetTlsClientAuthFromConfig(settings)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetTlsClientAuthFromConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				assert.Equal(t, tt.wantMode, mode)
+				assert.Equal(t, tt.wantCAs, cas != nil)
+			}
+		})
+	}
+}
+
+func TestCertReloaderReloadIfChanged(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	_, err := NewCertReloader(certFile, keyFile, getTestLogger())
+	assert.Error(t, err, "missing files should be reported by the constructor")
+
+	writeTestKeyPair(t, certFile, keyFile, "first")
+	cr, err := NewCertReloader(certFile, keyFile, getTestLogger())
+	if err != nil {
+		t.Fatalf("NewCertReloader() error = %v", err)
+	}
+	assert.Equal(t, "first", currentCommonName(t, cr))
+
+	reloaded, err := cr.ReloadIfChanged()
+	assert.NoError(t, err)
+	assert.False(t, reloaded, "unchanged files should not be loaded again")
+
+	// a rotation, the mtime is moved forward because the test may run within the resolution of the file system clock
+	writeTestKeyPair(t, certFile, keyFile, "second")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+	reloaded, err = cr.ReloadIfChanged()
+	assert.NoError(t, err)
+	assert.True(t, reloaded, "rotated files should be loaded")
+	assert.Equal(t, "second", currentCommonName(t, cr))
+
+	// a broken rotation should keep the previous certificate
+	os.WriteFile(keyFile, []byte("not a key"), 0600)
+	later = later.Add(time.Minute)
+	os.Chtimes(keyFile, later, later)
+	reloaded, err = cr.ReloadIfChanged()
+	assert.Error(t, err)
+	assert.False(t, reloaded)
+	assert.Equal(t, "second", currentCommonName(t, cr))
+}
+
+func TestGoHttpServerUseTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeTestKeyPair(t, certFile, keyFile, "localhost")
+	cr, err := NewCertReloader(certFile, keyFile, getTestLogger())
+	if err != nil {
+		t.Fatalf("NewCertReloader() error = %v", err)
+	}
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	myServer.UseTLS(cr)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen : %v", err)
+	}
+	srv := &http.Server{Handler: myServer.router, TLSConfig: myServer.httpServer.TLSConfig}
+	go srv.ServeTLS(l, "", "")
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get("https://" + l.Addr().String() + "/health")
+	if err != nil {
+		t.Fatalf("https request failed : %v", err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "localhost", resp.TLS.PeerCertificates[0].Subject.CommonName)
+}
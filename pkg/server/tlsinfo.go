@@ -0,0 +1,96 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// CertificateDetails describes one x509 certificate of a chain
+type CertificateDetails struct {
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	SerialNumber       string    `json:"serial_number"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	DnsNames           []string  `json:"dns_names,omitempty"`
+	IpAddresses        []string  `json:"ip_addresses,omitempty"`
+	Uris               []string  `json:"uris,omitempty"` // spiffe ids of the mesh workloads are given here
+	EmailAddresses     []string  `json:"email_addresses,omitempty"`
+	IsCA               bool      `json:"is_ca"`
+	KeyAlgorithm       string    `json:"key_algorithm"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	Sha256Fingerprint  string    `json:"sha256_fingerprint"`
+}
+
+// NewCertificateDetails returns the description of cert
+func NewCertificateDetails(cert *x509.Certificate) CertificateDetails {
+	fingerprint := sha256.Sum256(cert.Raw)
+	details := CertificateDetails{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       cert.SerialNumber.String(),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		DnsNames:           cert.DNSNames,
+		EmailAddresses:     cert.EmailAddresses,
+		IsCA:               cert.IsCA,
+		KeyAlgorithm:       cert.PublicKeyAlgorithm.String(),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		Sha256Fingerprint:  hex.EncodeToString(fingerprint[:]),
+	}
+	for _, ip := range cert.IPAddresses {
+		details.IpAddresses = append(details.IpAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		details.Uris = append(details.Uris, uri.String())
+	}
+	return details
+}
+
+// TlsReport describes the tls connection of a request and the client certificate chain it presented
+type TlsReport struct {
+	TlsConnInfo
+	DidResume          bool                 `json:"did_resume"`
+	ClientAuth         string               `json:"client_auth"`          // TLS_CLIENT_AUTH mode of the server
+	ClientCertVerified bool                 `json:"client_cert_verified"` // true when the chain was verified with TLS_CLIENT_CA_FILE
+	ClientCertificates []CertificateDetails `json:"client_certificates,omitempty"`
+}
+
+// GetTlsReport returns the report of the tls connection state cs, for a server asking the client certificates with clientAuth
+func GetTlsReport(cs *tls.ConnectionState, clientAuth tls.ClientAuthType) TlsReport {
+	report := TlsReport{
+		TlsConnInfo:        *NewTlsConnInfo(cs),
+		DidResume:          cs.DidResume,
+		ClientAuth:         tlsClientAuthName(clientAuth),
+		ClientCertVerified: len(cs.VerifiedChains) > 0,
+	}
+	for _, cert := range cs.PeerCertificates {
+		report.ClientCertificates = append(report.ClientCertificates, NewCertificateDetails(cert))
+	}
+	return report
+}
+
+//############# BEGIN TLS HANDLERS
+
+// TlsHandler returns the negotiated tls parameters and the client certificate chain, to verify mtls from inside the pod
+func (s *GoHttpServer) TlsHandler() http.HandlerFunc {
+	handlerName := "TlsHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil {
+			http.Error(w, "ERROR: this request was not received over tls, it may be terminated before the pod", http.StatusBadRequest)
+			return
+		}
+		clientAuth := tls.NoClientCert
+		if s.httpServer.TLSConfig != nil {
+			clientAuth = s.httpServer.TLSConfig.ClientAuth
+		}
+		s.render(w, r, http.StatusOK, GetTlsReport(r.TLS, clientAuth))
+	}
+}
+
+// ############# END TLS HANDLERS
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoHttpServerTlsHandler(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeTestKeyPair(t, certFile, keyFile, "localhost")
+	clientCertFile := filepath.Join(dir, "client.crt")
+	clientKeyFile := filepath.Join(dir, "client.key")
+	writeTestKeyPair(t, clientCertFile, clientKeyFile, "test-client")
+	clientCert, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+	if err != nil {
+		t.Fatalf("unable to load client certificate : %v", err)
+	}
+	cr, err := NewCertReloader(certFile, keyFile, getTestLogger())
+	if err != nil {
+		t.Fatalf("NewCertReloader() error = %v", err)
+	}
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	myServer.UseTLS(cr)
+	myServer.UseTlsClientAuth(tls.RequestClientCert, nil)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen : %v", err)
+	}
+	srv := &http.Server{Handler: myServer.router, TLSConfig: myServer.httpServer.TLSConfig}
+	go srv.ServeTLS(l, "", "")
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		clientCerts []tls.Certificate
+		wantSubject string
+	}{
+		{name: "1: without client certificate the chain should be empty"},
+		{name: "2: the client certificate should be reported", clientCerts: []tls.Certificate{clientCert}, wantSubject: "CN=test-client"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+				ServerName:         "localhost",
+				Certificates:       tt.clientCerts,
+			}}}
+			resp, err := client.Get("https://" + l.Addr().String() + "/tls")
+			if err != nil {
+				t.Fatalf("https request failed : %v", err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+			var report TlsReport
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report), "the output should be a valid json")
+			assert.Equal(t, "request", report.ClientAuth)
+			assert.Equal(t, "localhost", report.ServerName)
+			assert.NotEmpty(t, report.Version)
+			assert.False(t, report.ClientCertVerified, "the client certificates are only requested")
+			if tt.wantSubject == "" {
+				assert.Empty(t, report.ClientCertificates)
+				return
+			}
+			if assert.Len(t, report.ClientCertificates, 1) {
+				cert := report.ClientCertificates[0]
+				assert.Equal(t, tt.wantSubject, cert.Subject)
+				assert.Equal(t, []string{"localhost"}, cert.DnsNames)
+				assert.Equal(t, "ECDSA", cert.KeyAlgorithm)
+				assert.Len(t, cert.Sha256Fingerprint, 64)
+			}
+		})
+	}
+}
+
+func TestGoHttpServerTlsHandlerPlainHttp(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, assertCorrectStatusCodeExpected)
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAccessTokenMaxStored = 1024 // max number of tokens kept in memory at the same time
+	accessTokenBytes            = 32   // 256-bit random tokens
+	accessTokenLogPrefixLen     = 8    // number of chars of a token that may appear in logs
+	accessTokenQueryParam       = "access_token"
+	tokenErrInvalid             = "token_invalid"
+	tokenErrExpired             = "token_expired"
+	tokenErrAlreadyUsed         = "token_already_used"
+	tokenErrPathMismatch        = "token_path_mismatch"
+	tokenErrUnauthorized        = "unauthorized"
+)
+
+var (
+	ErrTokenInvalid      = errors.New(tokenErrInvalid)
+	ErrTokenExpired      = errors.New(tokenErrExpired)
+	ErrTokenAlreadyUsed  = errors.New(tokenErrAlreadyUsed)
+	ErrTokenPathMismatch = errors.New(tokenErrPathMismatch)
+	ErrTokenStoreFull    = errors.New("token_store_full")
+)
+
+type accessToken struct {
+	path      string
+	expiresAt time.Time
+	used      bool
+}
+
+// AccessTokenStore keeps short-lived single-use tokens bound to one url path in a bounded in-memory map
+type AccessTokenStore struct {
+	mu        sync.Mutex
+	tokens    map[string]*accessToken
+	ttl       time.Duration
+	maxStored int
+	now       func() time.Time
+}
+
+// NewAccessTokenStore is a constructor for an AccessTokenStore using the given token lifetime and capacity
+func NewAccessTokenStore(ttl time.Duration, maxStored int) *AccessTokenStore {
+	return &AccessTokenStore{
+		tokens:    make(map[string]*accessToken),
+		ttl:       ttl,
+		maxStored: maxStored,
+		now:       time.Now,
+	}
+}
+
+// sweep removes all expired tokens, the caller must hold the lock
+func (ts *AccessTokenStore) sweep() {
+	now := ts.now()
+	for k, t := range ts.tokens {
+		if now.After(t.expiresAt) {
+			delete(ts.tokens, k)
+		}
+	}
+}
+
+// Issue creates a new random token valid once for the given path until the returned expiry time
+func (ts *AccessTokenStore) Issue(path string) (string, time.Time, error) {
+	buf := make([]byte, accessTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(buf)
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.sweep()
+	if len(ts.tokens) >= ts.maxStored {
+		return "", time.Time{}, ErrTokenStoreFull
+	}
+	expiresAt := ts.now().Add(ts.ttl)
+	ts.tokens[token] = &accessToken{path: path, expiresAt: expiresAt}
+	return token, expiresAt, nil
+}
+
+// Consume validates the token for the given path and invalidates it, so it can be used only once
+func (ts *AccessTokenStore) Consume(token, path string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t, exist := ts.tokens[token]
+	if !exist {
+		return ErrTokenInvalid
+	}
+	if ts.now().After(t.expiresAt) {
+		delete(ts.tokens, token)
+		return ErrTokenExpired
+	}
+	if t.used {
+		return ErrTokenAlreadyUsed
+	}
+	if t.path != path {
+		return ErrTokenPathMismatch
+	}
+	// keep the entry until expiry, so a replay can be reported as such
+	t.used = true
+	return nil
+}
+
+// Len returns the number of tokens currently stored
+func (ts *AccessTokenStore) Len() int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return len(ts.tokens)
+}
+
+// tokenPrefix returns a shortened version of a token that is safe to write in the logs
+func tokenPrefix(token string) string {
+	if len(token) <= accessTokenLogPrefixLen {
+		return token
+	}
+	return token[:accessTokenLogPrefixLen] + "..."
+}
+
+// audit writes a security relevant event to the log, flagged with the audit attribute
+func (s *GoHttpServer) audit(event string, r *http.Request, args ...interface{}) {
+	args = append([]interface{}{"audit", true, "path", r.URL.Path, "remote_ip", ParseRemoteAddr(r.RemoteAddr).RemoteIp}, args...)
+	s.logger.InfoContext(r.Context(), "AUDIT: "+event, args...)
+}
+
+// isAuthenticated returns true when the request carries the bearer token defined in env API_TOKEN
+func (s *GoHttpServer) isAuthenticated(r *http.Request) bool {
+	if s.apiToken == "" {
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	received := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(received), []byte(s.apiToken)) == 1
+}
+
+// tokenError sends a 401 json response containing a distinct error code
+func (s *GoHttpServer) tokenError(w http.ResponseWriter, r *http.Request, code string) {
+	body, _ := json.Marshal(struct {
+		Error     string `json:"error"`
+		RequestId string `json:"request_id,omitempty"`
+	}{Error: code, RequestId: RequestIdFromContext(r.Context())})
+	w.Header().Set(HeaderContentType, MIMEAppJSONCharsetUTF8)
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write(body)
+}
+
+// checkApiToken returns true when the request carries the API_TOKEN bearer or a valid access_token bound to its path,
+// consuming the access_token, otherwise the error code to send
+func (s *GoHttpServer) checkApiToken(r *http.Request) (bool, string) {
+	if s.isAuthenticated(r) {
+		return true, ""
+	}
+	token := r.URL.Query().Get(accessTokenQueryParam)
+	if token == "" {
+		s.audit("request denied, no credentials", r, "method", r.Method)
+		return false, tokenErrUnauthorized
+	}
+	if err := s.tokens.Consume(token, r.URL.Path); err != nil {
+		s.audit("access_token rejected", r, "token", tokenPrefix(token), "error", err)
+		return false, err.Error()
+	}
+	s.audit("access_token consumed", r, "token", tokenPrefix(token))
+	return true, ""
+}
+
+// requireAuth is the Middleware protecting a route, it accepts either the API_TOKEN bearer or a one-shot access_token
+// bound to the path, or the credentials of AUTH_MODE already checked by the authenticate Middleware. the accepted
+// requests are marked authenticated for requireCredentials. when API_TOKEN is not defined the route is left unprotected.
+func (s *GoHttpServer) requireAuth() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.apiToken == "" || isRequestAuthenticated(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if ok, code := s.checkApiToken(r); !ok {
+				s.tokenError(w, r, code)
+				return
+			}
+			next.ServeHTTP(w, withAuthenticated(r))
+		})
+	}
+}
+
+//############# BEGIN TOKEN HANDLERS
+
+type tokenRequest struct {
+	Path string `json:"path"`
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	Path        string `json:"path"`
+	ExpiresAt   string `json:"expires_at"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// TokenHandler issues a single-use, short-lived access_token for the path given in the json body, to a client with
+// the credentials of AUTH_MODE or the API_TOKEN bearer. an access_token can not be exchanged for another one
+func (s *GoHttpServer) TokenHandler() http.HandlerFunc {
+	handlerName := "TokenHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has(accessTokenQueryParam) || !(isRequestAuthenticated(r) || s.isAuthenticated(r)) {
+			s.audit("token issuance denied, not authenticated", r)
+			s.tokenError(w, r, tokenErrUnauthorized)
+			return
+		}
+		var req tokenRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil || !strings.HasPrefix(req.Path, "/") {
+			http.Error(w, "ERROR: body should be a json object with a path field starting with /", http.StatusBadRequest)
+			return
+		}
+		token, expiresAt, err := s.tokens.Issue(req.Path)
+		if err != nil {
+			s.logger.Error("unable to issue token", "handler", handlerName, "error", err)
+			http.Error(w, "ERROR: unable to issue token", http.StatusServiceUnavailable)
+			return
+		}
+		s.audit("access_token issued", r, "token", tokenPrefix(token), "token_path", req.Path, "expires_at", expiresAt.Format(time.RFC3339))
+		s.render(w, r, http.StatusOK, tokenResponse{
+			AccessToken: token,
+			Path:        req.Path,
+			ExpiresAt:   expiresAt.Format(time.RFC3339),
+			ExpiresIn:   int(s.tokens.ttl.Seconds()),
+		})
+	}
+}
+
+// ############# END TOKEN HANDLERS
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// defaultAccessTokenTtl is the access_token_ttl of the default configuration
+var defaultAccessTokenTtl = config.DefaultConfig().AccessTokenTtl
+
+func TestAccessTokenStore(t *testing.T) {
+	now := time.Date(2022, 8, 26, 10, 0, 0, 0, time.UTC)
+	ts := NewAccessTokenStore(defaultAccessTokenTtl, 2)
+	ts.now = func() time.Time { return now }
+
+	token, expiresAt, err := ts.Issue("/")
+	assert.Nil(t, err, "Issue should not return an error")
+	assert.Equal(t, accessTokenBytes*2, len(token), "token should be an hex encoded 256-bit value")
+	assert.Equal(t, now.Add(defaultAccessTokenTtl), expiresAt, "token should expire after the ttl")
+
+	tests := []struct {
+		name    string
+		token   string
+		path    string
+		advance time.Duration
+		wantErr error
+	}{
+		{name: "1: unknown token should be rejected", token: "deadbeef", path: "/", wantErr: ErrTokenInvalid},
+		{name: "2: token on another path should be rejected", token: token, path: "/time", wantErr: ErrTokenPathMismatch},
+		{name: "3: token on its path should be accepted once", token: token, path: "/", wantErr: nil},
+		{name: "4: token reuse should be rejected", token: token, path: "/", wantErr: ErrTokenAlreadyUsed},
+		{name: "5: token after expiry should be rejected", token: token, path: "/", advance: defaultAccessTokenTtl + time.Second, wantErr: ErrTokenExpired},
+		{name: "6: expired token should be forgotten", token: token, path: "/", wantErr: ErrTokenInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			assert.Equal(t, tt.wantErr, ts.Consume(tt.token, tt.path))
+		})
+	}
+}
+
+func TestAccessTokenStoreIsBounded(t *testing.T) {
+	now := time.Now()
+	ts := NewAccessTokenStore(defaultAccessTokenTtl, 2)
+	ts.now = func() time.Time { return now }
+	for i := 0; i < 2; i++ {
+		_, _, err := ts.Issue("/")
+		assert.Nil(t, err, "Issue should not return an error while store is not full")
+	}
+	_, _, err := ts.Issue("/")
+	assert.Equal(t, ErrTokenStoreFull, err, "Issue should fail when store is full")
+	now = now.Add(defaultAccessTokenTtl + time.Second)
+	_, _, err = ts.Issue("/")
+	assert.Nil(t, err, "expired tokens should be swept before issuing a new one")
+	assert.Equal(t, 1, ts.Len(), "only the new token should remain")
+}
+
+func TestGoHttpServerTokenHandler(t *testing.T) {
+	const apiToken = "a-very-secret-api-token"
+	t.Setenv("API_TOKEN", apiToken)
+	myServer := NewGoHttpServer(fmt.Sprintf(":%d", config.DefaultPort), getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	doRequest := func(method, url, bearer, body string) (int, string) {
+		r, err := http.NewRequest(method, ts.URL+url, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("### ERROR http.NewRequest %s on [%s] error is :%v\n", method, url, err)
+		}
+		if bearer != "" {
+			r.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		received, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(received)
+	}
+
+	status, body := doRequest(http.MethodPost, "/token", "", `{"path":"/"}`)
+	assert.Equal(t, http.StatusUnauthorized, status, "token issuance without credentials should be refused")
+	assert.Contains(t, body, tokenErrUnauthorized)
+
+	status, body = doRequest(http.MethodPost, "/token", apiToken, `{"path":"/"}`)
+	assert.Equal(t, http.StatusOK, status, assertCorrectStatusCodeExpected)
+	var issued tokenResponse
+	assert.Nil(t, json.Unmarshal([]byte(body), &issued), "the output should be a valid json")
+	assert.Equal(t, "/", issued.Path)
+	assert.Equal(t, int(defaultAccessTokenTtl.Seconds()), issued.ExpiresIn)
+
+	tests := []struct {
+		name           string
+		url            string
+		bearer         string
+		wantStatusCode int
+		wantBody       string
+	}{
+		{name: "1: Get / without credentials should be refused", url: "/", wantStatusCode: http.StatusUnauthorized, wantBody: tokenErrUnauthorized},
+		{name: "2: Get / with the api token should be accepted", url: "/", bearer: apiToken, wantStatusCode: http.StatusOK, wantBody: "\"appname\""},
+		{name: "3: Get / with the token should be accepted", url: "/?access_token=" + issued.AccessToken, wantStatusCode: http.StatusOK, wantBody: "\"appname\""},
+		{name: "4: Get / with the same token should be refused", url: "/?access_token=" + issued.AccessToken, wantStatusCode: http.StatusUnauthorized, wantBody: tokenErrAlreadyUsed},
+		{name: "5: Get / with an unknown token should be refused", url: "/?access_token=42", wantStatusCode: http.StatusUnauthorized, wantBody: tokenErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := doRequest(http.MethodGet, tt.url, tt.bearer, "")
+			assert.Equal(t, tt.wantStatusCode, status, assertCorrectStatusCodeExpected)
+			assert.Contains(t, body, tt.wantBody, "Response should contain what was expected.")
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	traceparentHeader        = "traceparent"
+	defaultOtlpFlushInterval = 5 * time.Second
+	defaultOtlpMaxBatch      = 512  // spans sent in one export request
+	defaultOtlpMaxQueue      = 2048 // spans kept in memory waiting for the export, newer spans are dropped above
+	otlpTracesPath           = "/v1/traces"
+)
+
+// InjectTraceContext adds the headers of the propagators of OTEL_PROPAGATORS, like traceparent, for the current
+// span in ctx to an outgoing request. nothing is added outside a traced request
+func InjectTraceContext(ctx context.Context, req *http.Request) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+}
+
+// OtlpConfig is the configuration of the OTLP exporter and of the OpenTelemetry sdk
+type OtlpConfig struct {
+	Endpoint    string            // full url of the traces endpoint with http/protobuf, url of the collector with grpc
+	Protocol    string            // http/protobuf or grpc
+	Headers     map[string]string // added to every export request, typically for authentication
+	ServiceName string
+	Sampler     string            // name of the sampler like parentbased_always_on
+	SamplerArg  float64           // ratio of the traceidratio samplers
+	Propagators []string          // tracecontext, baggage or none
+	Resource    map[string]string // attributes of the resource of the traces besides service.name and service.version
+}
+
+// GetOtlpConfigFromConfig returns the exporter configuration given by the validated settings, which are read from
+// the standard OpenTelemetry environment variables :
+//
+//	otlp_traces_endpoint (env OTEL_EXPORTER_OTLP_TRACES_ENDPOINT) : full url of the traces endpoint (has precedence)
+//	otlp_endpoint (env OTEL_EXPORTER_OTLP_ENDPOINT) : base url of the collector, /v1/traces is appended with http/protobuf
+//	otlp_headers (env OTEL_EXPORTER_OTLP_HEADERS) : comma separated list of key=value
+//	otlp_protocol (env OTEL_EXPORTER_OTLP_PROTOCOL) : http/protobuf or grpc
+//	otel_traces_sampler (env OTEL_TRACES_SAMPLER) and otel_traces_sampler_arg (env OTEL_TRACES_SAMPLER_ARG)
+//	otel_propagators (env OTEL_PROPAGATORS) : comma separated list of tracecontext, baggage or none
+//	otel_resource_attributes (env OTEL_RESOURCE_ATTRIBUTES) : comma separated list of key=value
+//	otel_service_name (env OTEL_SERVICE_NAME) : name of the service in the traces (APP when empty)
+//	otel_sdk_disabled (env OTEL_SDK_DISABLED) : true to disable tracing
+//
+// nil is returned when no endpoint is defined or when tracing is disabled
+func GetOtlpConfigFromConfig(settings config.Config) *OtlpConfig {
+	endpoint := settings.OtlpTraces
+	if endpoint == "" && settings.OtlpEndpoint != "" {
+		endpoint = settings.OtlpEndpoint
+		if settings.OtlpProtocol != config.OtlpProtocolGrpc {
+			endpoint = strings.TrimSuffix(endpoint, "/") + otlpTracesPath
+		}
+	}
+	if settings.OtelDisabled || endpoint == "" {
+		return nil
+	}
+	otlp := OtlpConfig{
+		Endpoint:    endpoint,
+		Protocol:    settings.OtlpProtocol,
+		Headers:     map[string]string{},
+		ServiceName: info.APP,
+		Sampler:     settings.OtelSampler,
+		SamplerArg:  1,
+		Propagators: config.SplitList(settings.OtelPropagators),
+		Resource:    map[string]string{},
+	}
+	if settings.OtelService != "" {
+		otlp.ServiceName = settings.OtelService
+	}
+	if ratio, err := strconv.ParseFloat(settings.OtelSamplerArg, 64); err == nil {
+		otlp.SamplerArg = ratio
+	}
+	for _, kv := range config.SplitList(settings.OtlpHeaders) {
+		key, value, _ := strings.Cut(kv, "=")
+		otlp.Headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	for _, kv := range config.SplitList(settings.OtelResource) {
+		key, value, _ := strings.Cut(kv, "=")
+		otlp.Resource[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return &otlp
+}
+
+// sampler returns the sampler of the sdk named by OTEL_TRACES_SAMPLER
+func (otlp OtlpConfig) sampler() sdktrace.Sampler {
+	name, parentBased := strings.CutPrefix(otlp.Sampler, "parentbased_")
+	var root sdktrace.Sampler
+	switch name {
+	case "always_off":
+		root = sdktrace.NeverSample()
+	case "traceidratio":
+		root = sdktrace.TraceIDRatioBased(otlp.SamplerArg)
+	default:
+		root = sdktrace.AlwaysSample()
+	}
+	if parentBased {
+		return sdktrace.ParentBased(root)
+	}
+	return root
+}
+
+// propagator returns the composite propagator of OTEL_PROPAGATORS, none propagates nothing
+func (otlp OtlpConfig) propagator() propagation.TextMapPropagator {
+	var propagators []propagation.TextMapPropagator
+	for _, name := range otlp.Propagators {
+		switch name {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...)
+}
+
+// resource returns the resource of the traces, service.name and service.version having precedence over the
+// attributes of OTEL_RESOURCE_ATTRIBUTES, which have precedence over the defaults of the sdk
+func (otlp OtlpConfig) resource() (*resource.Resource, error) {
+	attributes := make([]attribute.KeyValue, 0, len(otlp.Resource)+2)
+	for key, value := range otlp.Resource {
+		attributes = append(attributes, attribute.String(key, value))
+	}
+	attributes = append(attributes, attribute.String("service.name", otlp.ServiceName), attribute.String("service.version", info.VERSION))
+	return resource.Merge(resource.Default(), resource.NewSchemaless(attributes...))
+}
+
+// Tracer records the spans of the served requests with the OpenTelemetry sdk and exports them in batches to an
+// OTLP collector, over http/protobuf or grpc
+type Tracer struct {
+	config     OtlpConfig
+	provider   *sdktrace.TracerProvider
+	propagator propagation.TextMapPropagator
+}
+
+// NewTracer is a constructor for a Tracer exporting to the collector described in otlp. the propagator of otlp
+// becomes the global one of otel, used by InjectTraceContext
+func NewTracer(otlp OtlpConfig) (*Tracer, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	if otlp.Protocol == config.OtlpProtocolGrpc {
+		exporter, err = otlptracegrpc.New(context.Background(), otlptracegrpc.WithEndpointURL(otlp.Endpoint), otlptracegrpc.WithHeaders(otlp.Headers))
+	} else {
+		exporter, err = otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(otlp.Endpoint), otlptracehttp.WithHeaders(otlp.Headers))
+	}
+	if err != nil {
+		return nil, err
+	}
+	res, err := otlp.resource()
+	if err != nil {
+		return nil, err
+	}
+	t := &Tracer{
+		config: otlp,
+		provider: sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exporter,
+				sdktrace.WithBatchTimeout(defaultOtlpFlushInterval),
+				sdktrace.WithMaxExportBatchSize(defaultOtlpMaxBatch),
+				sdktrace.WithMaxQueueSize(defaultOtlpMaxQueue)),
+			sdktrace.WithSampler(otlp.sampler()),
+			sdktrace.WithResource(res),
+		),
+		propagator: otlp.propagator(),
+	}
+	otel.SetTextMapPropagator(t.propagator)
+	return t, nil
+}
+
+// Flush exports all the spans ended and not yet exported
+func (t *Tracer) Flush(ctx context.Context) error {
+	return t.provider.ForceFlush(ctx)
+}
+
+// Shutdown exports the last spans and stops the exporter, the spans ended after it are dropped
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	return t.provider.Shutdown(ctx)
+}
+
+// traceRequests is the Middleware creating a server span for each request of the route with otelhttp. the incoming
+// trace context is continued following OTEL_PROPAGATORS and the sampler decides which spans are exported.
+// without tracer the requests are passed through untouched.
+func (s *GoHttpServer) traceRequests(route string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if s.tracer == nil {
+			return next
+		}
+		return otelhttp.NewHandler(next, route,
+			otelhttp.WithTracerProvider(s.tracer.provider),
+			otelhttp.WithPropagators(s.tracer.propagator),
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return r.Method + " " + route }),
+			otelhttp.WithSpanOptions(trace.WithAttributes(attribute.String("http.route", route))),
+		)
+	}
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGetOtlpConfigFromConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		configure    func(c *config.Config)
+		wantEndpoint string
+		wantHeaders  map[string]string
+		wantNil      bool
+	}{
+		{name: "1: no endpoint should disable tracing", configure: func(c *config.Config) {}, wantNil: true},
+		{name: "2: base endpoint should get the traces path", configure: func(c *config.Config) { c.OtlpEndpoint = "http://otel-collector:4318/" },
+			wantEndpoint: "http://otel-collector:4318/v1/traces", wantHeaders: map[string]string{}},
+		{name: "3: traces endpoint should have precedence", configure: func(c *config.Config) {
+			c.OtlpEndpoint, c.OtlpTraces, c.OtlpHeaders = "http://otel-collector:4318", "https://traces.example.com/otlp", "api-key=secret, x-tenant=demo"
+		}, wantEndpoint: "https://traces.example.com/otlp", wantHeaders: map[string]string{"api-key": "secret", "x-tenant": "demo"}},
+		{name: "4: otel_sdk_disabled should disable tracing", configure: func(c *config.Config) {
+			c.OtlpEndpoint, c.OtelDisabled = "http://otel-collector:4318", true
+		}, wantNil: true},
+		{name: "5: the grpc endpoint should not get the traces path", configure: func(c *config.Config) {
+			c.OtlpEndpoint, c.OtlpProtocol = "http://otel-collector:4317", config.OtlpProtocolGrpc
+		}, wantEndpoint: "http://otel-collector:4317", wantHeaders: map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.DefaultConfig()
+			tt.configure(&settings)
+			otlp := GetOtlpConfigFromConfig(settings)
+			if tt.wantNil {
+				assert.Nil(t, otlp)
+				return
+			}
+			assert.Equal(t, tt.wantEndpoint, otlp.Endpoint)
+			assert.Equal(t, tt.wantHeaders, otlp.Headers)
+			assert.Equal(t, info.APP, otlp.ServiceName)
+		})
+	}
+}
+
+func TestOtlpConfigSampler(t *testing.T) {
+	sampled := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}, TraceFlags: trace.FlagsSampled})
+	tests := []struct {
+		name       string
+		sampler    string
+		ratio      float64
+		parent     trace.SpanContext
+		wantRecord bool
+	}{
+		{name: "1: always_on should record", sampler: "always_on", wantRecord: true},
+		{name: "2: always_off should drop", sampler: "always_off"},
+		{name: "3: traceidratio 0 should drop", sampler: "traceidratio", ratio: 0},
+		{name: "4: parentbased_always_off should drop a root span", sampler: "parentbased_always_off"},
+		{name: "5: parentbased_always_off should follow a sampled parent", sampler: "parentbased_always_off", parent: sampled, wantRecord: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			otlp := OtlpConfig{Sampler: tt.sampler, SamplerArg: tt.ratio}
+			result := otlp.sampler().ShouldSample(sdktrace.SamplingParameters{
+				ParentContext: trace.ContextWithSpanContext(context.Background(), tt.parent), TraceID: trace.TraceID{2}, Name: "GET /"})
+			assert.Equal(t, tt.wantRecord, result.Decision == sdktrace.RecordAndSample)
+		})
+	}
+}
+
+func TestGoHttpServerTracing(t *testing.T) {
+	var mu sync.Mutex
+	var exports []*coltracepb.ExportTraceServiceRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		export := &coltracepb.ExportTraceServiceRequest{}
+		assert.NoError(t, proto.Unmarshal(body, export), "the export should be OTLP protobuf")
+		mu.Lock()
+		exports = append(exports, export)
+		mu.Unlock()
+		assert.Equal(t, "secret", r.Header.Get("api-key"))
+		assert.Equal(t, otlpTracesPath, r.URL.Path)
+	}))
+	defer collector.Close()
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL)
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "api-key=secret")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=test")
+
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	if !assert.NotNil(t, myServer.tracer, "tracer should be created when an endpoint is defined") {
+		return
+	}
+	defer myServer.tracer.Shutdown(context.Background())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	doGet := func(path, traceparent string) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if traceparent != "" {
+			req.Header.Set(traceparentHeader, traceparent)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	doGet("/health", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	doGet("/time", "00-11111111111111111111111111111111-2222222222222222-00")
+	doGet("/health", "")
+
+	assert.NoError(t, myServer.tracer.Flush(context.Background()))
+	mu.Lock()
+	defer mu.Unlock()
+	if !assert.Equal(t, 1, len(exports), "all spans should be exported in one batch") {
+		return
+	}
+	resourceSpans := exports[0].ResourceSpans[0]
+	resource := make(map[string]string)
+	for _, kv := range resourceSpans.Resource.Attributes {
+		resource[kv.Key] = kv.Value.GetStringValue()
+	}
+	assert.Equal(t, info.APP, resource["service.name"])
+	assert.Equal(t, "test", resource["deployment.environment"], "OTEL_RESOURCE_ATTRIBUTES should be in the resource")
+	var spans []*tracepb.Span
+	for _, scopeSpans := range resourceSpans.ScopeSpans {
+		spans = append(spans, scopeSpans.Spans...)
+	}
+	if !assert.Equal(t, 2, len(spans), "the unsampled request should not be exported") {
+		return
+	}
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", hex.EncodeToString(spans[0].TraceId), "the incoming trace should be continued")
+	assert.Equal(t, "00f067aa0ba902b7", hex.EncodeToString(spans[0].ParentSpanId))
+	assert.Equal(t, "GET /health", spans[0].Name)
+	assert.Equal(t, tracepb.Span_SPAN_KIND_SERVER, spans[0].Kind)
+	assert.NotEqual(t, spans[0].TraceId, spans[1].TraceId, "a request without traceparent should start a new trace")
+	assert.Empty(t, spans[1].ParentSpanId)
+}
+
+func TestInjectTraceContext(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{0x4b, 0xf9}, SpanID: trace.SpanID{0x00, 0xf0}, TraceFlags: trace.FlagsSampled})
+	defer otel.SetTextMapPropagator(otel.GetTextMapPropagator())
+	otel.SetTextMapPropagator(OtlpConfig{Propagators: []string{"tracecontext"}}.propagator())
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	InjectTraceContext(context.Background(), req)
+	assert.Equal(t, "", req.Header.Get(traceparentHeader), "nothing should be injected outside a traced request")
+	InjectTraceContext(trace.ContextWithSpanContext(context.Background(), sc), req)
+	assert.Equal(t, "00-4bf90000000000000000000000000000-00f0000000000000-01", req.Header.Get(traceparentHeader))
+
+	otel.SetTextMapPropagator(OtlpConfig{Propagators: []string{"none"}}.propagator())
+	req, _ = http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	InjectTraceContext(trace.ContextWithSpanContext(context.Background(), sc), req)
+	assert.Empty(t, req.Header, "the none propagator should not inject anything")
+}
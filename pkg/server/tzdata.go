@@ -0,0 +1,8 @@
+//go:build timetzdata
+
+package server
+
+// the timetzdata tag of the go toolchain embeds the time zone database in the binary, like importing time/tzdata
+func init() {
+	tzdataEmbedded = true
+}
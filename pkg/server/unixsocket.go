@@ -0,0 +1,31 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// listenUnixSocket listens on the unix socket path with the permissions mode, so that the containers of the pod
+// sharing the volume of the socket can call the server without a tcp port. the socket left by a previous run is
+// removed, any other file at path is an error
+func listenUnixSocket(path string, mode os.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a unix socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("removing the stale socket %s: %w", path, err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// the socket is created with the umask of the process, the listener removes it when it is closed
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("changing the permissions of %s: %w", path, err)
+	}
+	return ln, nil
+}
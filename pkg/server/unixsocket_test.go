@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenUnixSocket(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "stale.sock")
+	staleLn, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the socket file is kept, like after a crash
+	staleLn.(*net.UnixListener).SetUnlinkOnClose(false)
+	staleLn.Close()
+	regular := filepath.Join(dir, "regular.sock")
+	assert.NoError(t, os.WriteFile(regular, nil, 0o600))
+
+	tests := []struct {
+		name    string
+		path    string
+		mode    os.FileMode
+		wantErr bool
+	}{
+		{name: "1: new socket should get the permissions", path: filepath.Join(dir, "new.sock"), mode: 0o660},
+		{name: "2: stale socket should be replaced", path: stale, mode: 0o600},
+		{name: "3: regular file should be an error", path: regular, mode: 0o660, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := listenUnixSocket(tt.path, tt.mode)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			fi, err := os.Stat(tt.path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.mode, fi.Mode().Perm())
+			ln.Close()
+			_, err = os.Stat(tt.path)
+			assert.True(t, os.IsNotExist(err), "the socket should be removed when the listener is closed")
+		})
+	}
+}
+
+func TestGoHttpServerUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "info.sock")
+	t.Setenv("LISTEN_SOCKET", socket)
+	t.Setenv("LISTEN_SOCKET_ONLY", "true")
+	t.Setenv("PRE_STOP_DELAY_SECONDS", "0")
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	stopped := make(chan error, 1)
+	go func() { stopped <- myServer.StartServer() }()
+
+	client := &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+	}}}
+	var resp *http.Response
+	var err error
+	for range 50 {
+		if resp, err = client.Get("http://info/health"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if assert.NoError(t, err, "the server should answer on the unix socket") {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, assertCorrectStatusCodeExpected)
+	}
+	myServer.Stop()
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("StartServer should return after Stop")
+	}
+	_, err = os.Stat(socket)
+	assert.True(t, os.IsNotExist(err), "the socket should be removed on shutdown")
+}
@@ -0,0 +1,391 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultUploadCleanupInterval = time.Minute
+	maxUploadFieldBytes          = defaultEchoMaxBodyBytes // form values bigger than this are truncated in the answer
+	uploadDataSuffix             = ".data"
+	uploadMetaSuffix             = ".json"
+	maxUploadFiles               = 10000 // files kept in the directory, so empty files cannot fill its inodes
+)
+
+var (
+	// errUploadNotFound is returned for an unknown or expired upload id
+	errUploadNotFound = errors.New("upload not found")
+	// errUploadQuota is returned when the file would not fit in the quota of the directory
+	errUploadQuota = errors.New("the files kept reached UPLOAD_QUOTA_MB or the maximum number of files, retry when older ones expire")
+)
+
+// activeContentTypes are the types a browser would render or run in the origin of the server, they are served as
+// application/octet-stream so an uploaded page cannot run scripts with the cookies of the site
+var activeContentTypes = map[string]bool{
+	"text/html":             true,
+	"application/xhtml+xml": true,
+	"image/svg+xml":         true,
+	"text/xml":              true,
+	"application/xml":       true,
+}
+
+// servedContentType returns the Content-Type sending an uploaded file of contentType, application/octet-stream when
+// it is unknown, invalid or active content
+func servedContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || activeContentTypes[mediaType] {
+		return MIMEAppOctetStream
+	}
+	return contentType
+}
+
+// UploadedFile is a file received by POST /upload, kept until Expires
+type UploadedFile struct {
+	Id          string    `json:"id"`
+	Field       string    `json:"field"` // name of the form field
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type,omitempty"`
+	Bytes       int64     `json:"bytes"`
+	Sha256      string    `json:"sha256"`
+	Uploaded    time.Time `json:"uploaded"`
+	Expires     time.Time `json:"expires"`
+	Url         string    `json:"url"` // path of GET /upload/{id} returning the file
+	Restored    bool      `json:"restored,omitempty"`
+}
+
+// UploadReport is the answer of POST /upload : the files stored and the other fields of the form
+type UploadReport struct {
+	Files    []UploadedFile    `json:"files"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Bytes    int64             `json:"bytes"` // of all the files
+	Seconds  float64           `json:"seconds"`
+	Dir      string            `json:"dir"`
+	Hostname string            `json:"hostname"`
+}
+
+// UploadStore keeps the uploaded files in a directory, usually an emptyDir volume, and deletes them after ttl.
+// the files found in the directory at startup are kept, to show that the volume survived a restart of the container
+type UploadStore struct {
+	dir        string
+	ttl        time.Duration
+	maxBytes   int64
+	quotaBytes int64 // of all the files kept, the ones being written included
+	logger     *slog.Logger
+	now        func() time.Time // time.Now, replaced in tests
+	mu         sync.Mutex
+	files      map[string]UploadedFile
+	usedBytes  int64 // of the files kept and of the ones being written
+	saving     int   // files being written
+}
+
+// NewUploadStore is a constructor for an UploadStore of dir accepting bodies of maxBytes and keeping quotaBytes
+// at most, it creates dir when it does not exist and restores the uploads of a previous process which are not expired
+func NewUploadStore(dir string, ttl time.Duration, maxBytes, quotaBytes int64, logger *slog.Logger) (*UploadStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	us := &UploadStore{dir: dir, ttl: ttl, maxBytes: maxBytes, quotaBytes: quotaBytes, logger: logger, now: time.Now, files: map[string]UploadedFile{}}
+	metas, err := filepath.Glob(filepath.Join(dir, "*"+uploadMetaSuffix))
+	if err != nil {
+		return nil, err
+	}
+	for _, meta := range metas {
+		data, err := os.ReadFile(meta)
+		var file UploadedFile
+		if err == nil {
+			err = json.Unmarshal(data, &file)
+		}
+		if err != nil || file.Id+uploadMetaSuffix != filepath.Base(meta) {
+			logger.Warn("invalid upload metadata ignored", "file", meta, "error", err)
+			continue
+		}
+		file.Restored = true
+		us.files[file.Id] = file
+		us.usedBytes += file.Bytes
+	}
+	if len(us.files) > 0 {
+		logger.Info("uploads restored from the upload directory", "dir", dir, "files", len(us.files))
+	}
+	us.Cleanup()
+	return us, nil
+}
+
+// paths returns the paths of the content and of the metadata of the upload id
+func (us *UploadStore) paths(id string) (string, string) {
+	return filepath.Join(us.dir, id+uploadDataSuffix), filepath.Join(us.dir, id+uploadMetaSuffix)
+}
+
+// newUploadId returns a random id of 32 hex characters
+func newUploadId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// reserve counts n more bytes in the directory, errUploadQuota when they do not fit in quotaBytes
+func (us *UploadStore) reserve(n int64) error {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if us.usedBytes+n > us.quotaBytes {
+		return errUploadQuota
+	}
+	us.usedBytes += n
+	return nil
+}
+
+// uploadQuotaWriter reserves in the quota of the store the bytes written to w, Save releases them on failure
+type uploadQuotaWriter struct {
+	us       *UploadStore
+	w        io.Writer
+	reserved int64
+}
+
+func (q *uploadQuotaWriter) Write(p []byte) (int, error) {
+	if err := q.us.reserve(int64(len(p))); err != nil {
+		return 0, err
+	}
+	q.reserved += int64(len(p))
+	return q.w.Write(p)
+}
+
+// Save writes the content of a file of the form field to the directory, computing its size and sha256.
+// errUploadQuota is returned when the files kept would exceed the quota of bytes or maxUploadFiles
+func (us *UploadStore) Save(content io.Reader, field, filename, contentType string) (UploadedFile, error) {
+	us.mu.Lock()
+	if len(us.files)+us.saving >= maxUploadFiles {
+		us.mu.Unlock()
+		return UploadedFile{}, errUploadQuota
+	}
+	us.saving++
+	us.mu.Unlock()
+	quota := &uploadQuotaWriter{us: us}
+	file, err := us.save(content, quota, field, filename, contentType)
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	us.saving--
+	if err != nil {
+		us.usedBytes -= quota.reserved
+		return UploadedFile{}, err
+	}
+	us.files[file.Id] = file
+	return file, nil
+}
+
+// save writes content through quota to a new file of the directory with its metadata
+func (us *UploadStore) save(content io.Reader, quota *uploadQuotaWriter, field, filename, contentType string) (UploadedFile, error) {
+	id, err := newUploadId()
+	if err != nil {
+		return UploadedFile{}, err
+	}
+	dataPath, metaPath := us.paths(id)
+	f, err := os.OpenFile(dataPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return UploadedFile{}, err
+	}
+	hash := sha256.New()
+	quota.w = io.MultiWriter(f, hash)
+	n, err := io.Copy(quota, content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	now := us.now().UTC()
+	file := UploadedFile{Id: id, Field: field, Filename: filename, ContentType: contentType, Bytes: n,
+		Sha256: hex.EncodeToString(hash.Sum(nil)), Uploaded: now, Expires: now.Add(us.ttl), Url: "/upload/" + id}
+	if err == nil {
+		var meta []byte
+		if meta, err = json.Marshal(file); err == nil {
+			err = os.WriteFile(metaPath, meta, 0o600)
+		}
+	}
+	if err != nil {
+		os.Remove(dataPath)
+		os.Remove(metaPath)
+		return UploadedFile{}, err
+	}
+	return file, nil
+}
+
+// Open returns the upload id with its content, errUploadNotFound when it is unknown or expired
+func (us *UploadStore) Open(id string) (UploadedFile, *os.File, error) {
+	us.mu.Lock()
+	file, found := us.files[id]
+	us.mu.Unlock()
+	if !found || !us.now().Before(file.Expires) {
+		return UploadedFile{}, nil, errUploadNotFound
+	}
+	dataPath, _ := us.paths(id)
+	f, err := os.Open(dataPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return UploadedFile{}, nil, errUploadNotFound
+	}
+	return file, f, err
+}
+
+// Delete removes the upload id from the directory
+func (us *UploadStore) Delete(id string) {
+	us.mu.Lock()
+	if file, found := us.files[id]; found {
+		us.usedBytes -= file.Bytes
+		delete(us.files, id)
+	}
+	us.mu.Unlock()
+	dataPath, metaPath := us.paths(id)
+	os.Remove(dataPath)
+	os.Remove(metaPath)
+}
+
+// Cleanup deletes the expired uploads and returns how many were deleted
+func (us *UploadStore) Cleanup() int {
+	now := us.now()
+	var expired []string
+	us.mu.Lock()
+	for id, file := range us.files {
+		if !now.Before(file.Expires) {
+			expired = append(expired, id)
+		}
+	}
+	us.mu.Unlock()
+	for _, id := range expired {
+		us.Delete(id)
+	}
+	if len(expired) > 0 {
+		us.logger.Debug("expired uploads deleted", "dir", us.dir, "files", len(expired))
+	}
+	return len(expired)
+}
+
+// Run deletes the expired uploads every interval until ctx is done
+func (us *UploadStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			us.Cleanup()
+		}
+	}
+}
+
+//############# BEGIN INFO HANDLERS
+
+// UploadHandler stores the files of a multipart/form-data body in UPLOAD_DIR and answers with their size, sha256
+// and the url returning them, the other fields of the form are echoed. it tests the volume of UPLOAD_DIR and the
+// body size limits of the ingress end to end
+func (s *GoHttpServer) UploadHandler(us *UploadStore) http.HandlerFunc {
+	handlerName := "UploadHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	hostname, _ := os.Hostname()
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > us.maxBytes {
+			http.Error(w, fmt.Sprintf("ERROR: the body of %d bytes is bigger than the %d bytes accepted by UPLOAD_MAX_MB", r.ContentLength, us.maxBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, us.maxBytes)
+		mr, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, "ERROR: the body should be a multipart/form-data form : "+err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		// the error is ignored, when the connection does not support deadlines there is no timeout to extend
+		_ = http.NewResponseController(w).SetReadDeadline(time.Now().Add(maxLoadDuration))
+		start := time.Now()
+		report := UploadReport{Files: []UploadedFile{}, Dir: us.dir, Hostname: hostname}
+		var status int
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err == nil {
+				if part.FileName() == "" {
+					var value []byte
+					if value, err = io.ReadAll(io.LimitReader(part, maxUploadFieldBytes)); err == nil {
+						if report.Fields == nil {
+							report.Fields = map[string]string{}
+						}
+						report.Fields[part.FormName()] = string(value)
+						_, err = io.Copy(io.Discard, part)
+					}
+				} else {
+					var file UploadedFile
+					// only the base name is kept, the file is stored under its id
+					file, err = us.Save(part, part.FormName(), filepath.Base(part.FileName()), part.Header.Get(HeaderContentType))
+					if err == nil {
+						report.Files = append(report.Files, file)
+						report.Bytes += file.Bytes
+					}
+				}
+				part.Close()
+			}
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				switch {
+				case errors.As(err, &tooLarge):
+					status = http.StatusRequestEntityTooLarge
+					err = fmt.Errorf("the body is bigger than the %d bytes accepted by UPLOAD_MAX_MB", us.maxBytes)
+				case errors.Is(err, errUploadQuota), errors.Is(err, syscall.ENOSPC):
+					status = http.StatusInsufficientStorage
+				default:
+					status = http.StatusBadRequest
+				}
+				for _, file := range report.Files {
+					us.Delete(file.Id)
+				}
+				s.logger.WarnContext(r.Context(), "upload failed", "handler", handlerName, "status", status, "error", err)
+				http.Error(w, "ERROR: "+err.Error(), status)
+				return
+			}
+		}
+		report.Seconds = time.Since(start).Seconds()
+		s.logger.InfoContext(r.Context(), "files uploaded", "handler", handlerName, "files", len(report.Files), "bytes", report.Bytes)
+		s.render(w, r, http.StatusCreated, report)
+	}
+}
+
+// UploadFileHandler returns the content of the file uploaded with the id path parameter, 404 once it expired.
+// the html and svg files are sent as application/octet-stream and the browsers are told not to sniff the type
+func (s *GoHttpServer) UploadFileHandler(us *UploadStore) http.HandlerFunc {
+	handlerName := "UploadFileHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		file, content, err := us.Open(r.PathValue("id"))
+		switch {
+		case errors.Is(err, errUploadNotFound):
+			http.Error(w, "ERROR: "+err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			s.logger.ErrorContext(r.Context(), "opening the upload failed", "handler", handlerName, "error", err)
+			http.Error(w, "ERROR: opening the upload failed", http.StatusInternalServerError)
+			return
+		}
+		defer content.Close()
+		w.Header().Set(HeaderContentType, servedContentType(file.ContentType))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename}))
+		w.Header().Set("X-Upload-Sha256", file.Sha256)
+		w.Header().Set("Expires", file.Expires.Format(http.TimeFormat))
+		noCompression(w)
+		http.ServeContent(w, r, "", file.Uploaded, content)
+	}
+}
+
+// ############# END INFO HANDLERS
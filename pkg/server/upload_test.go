@@ -0,0 +1,240 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewUploadStore(dir, time.Hour, 1<<20, 10<<20, getTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the restarted store deletes the uploads expired according to the real clock
+	now := time.Now().UTC().Truncate(time.Second)
+	store.now = func() time.Time { return now }
+	file, err := store.Save(strings.NewReader("hello"), "doc", "hello.txt", "text/plain")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), file.Bytes)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", file.Sha256)
+	assert.Equal(t, now.Add(time.Hour), file.Expires)
+	assert.Equal(t, "/upload/"+file.Id, file.Url)
+
+	got, content, err := store.Open(file.Id)
+	if assert.NoError(t, err) {
+		data, _ := io.ReadAll(content)
+		content.Close()
+		assert.Equal(t, "hello", string(data))
+		assert.Equal(t, file, got)
+	}
+	_, _, err = store.Open("../" + file.Id)
+	assert.ErrorIs(t, err, errUploadNotFound, "only the ids of the store should be opened")
+
+	// a new process on the same directory, like a container restarted with its emptyDir
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "junk"+uploadMetaSuffix), []byte("{"), 0o600))
+	restarted, err := NewUploadStore(dir, time.Hour, 1<<20, 10<<20, getTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.now = store.now
+	got, content, err = restarted.Open(file.Id)
+	if assert.NoError(t, err, "the uploads should survive a restart") {
+		content.Close()
+		assert.True(t, got.Restored)
+		assert.Equal(t, file.Sha256, got.Sha256)
+	}
+
+	now = now.Add(time.Hour)
+	_, _, err = restarted.Open(file.Id)
+	assert.ErrorIs(t, err, errUploadNotFound, "an expired upload should not be returned")
+	assert.Equal(t, 1, restarted.Cleanup())
+	_, err = os.Stat(filepath.Join(dir, file.Id+uploadDataSuffix))
+	assert.True(t, os.IsNotExist(err), "the content of an expired upload should be deleted")
+	_, err = os.Stat(filepath.Join(dir, file.Id+uploadMetaSuffix))
+	assert.True(t, os.IsNotExist(err), "the metadata of an expired upload should be deleted")
+}
+
+func TestUploadStoreQuota(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewUploadStore(dir, time.Hour, 1<<20, 10, getTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := store.Save(strings.NewReader("123456"), "doc", "first.txt", "text/plain")
+	assert.NoError(t, err)
+	_, err = store.Save(strings.NewReader("123456"), "doc", "second.txt", "text/plain")
+	assert.ErrorIs(t, err, errUploadQuota, "the files kept should not exceed the quota")
+	assert.Equal(t, int64(6), store.usedBytes, "the bytes of a refused file should be released")
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 2, "only the data and metadata of the first file should be kept")
+
+	store.Delete(first.Id)
+	_, err = store.Save(strings.NewReader("1234567890"), "doc", "third.txt", "text/plain")
+	assert.NoError(t, err, "the bytes of a deleted file should be available again")
+
+	store.quotaBytes = 1 << 20
+	store.files = map[string]UploadedFile{}
+	for i := 0; i < maxUploadFiles; i++ {
+		store.files[strconv.Itoa(i)] = UploadedFile{}
+	}
+	_, err = store.Save(strings.NewReader(""), "doc", "empty.txt", "text/plain")
+	assert.ErrorIs(t, err, errUploadQuota, "the number of files kept should be limited")
+}
+
+func TestGoHttpServerUploadQuota(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.UploadDir = filepath.Join(t.TempDir(), "uploads")
+	cfg.UploadQuotaMb = 1
+	myServer := NewGoHttpServerWithConfig(config.DefaultListenIp+":0", cfg, getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+	content := strings.Repeat("x", 600<<10)
+
+	for _, wantStatus := range []int{http.StatusCreated, http.StatusInsufficientStorage} {
+		body, contentType := multipartForm(t, nil, map[string]string{"blob": content})
+		resp, err := http.Post(ts.URL+"/upload", contentType, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		assert.Equal(t, wantStatus, resp.StatusCode, "the second upload should not fit in UPLOAD_QUOTA_MB")
+	}
+}
+
+// multipartForm returns a multipart body with the field and the files, and its Content-Type
+func multipartForm(t *testing.T, fields map[string]string, files map[string]string) (*bytes.Buffer, string) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, value := range fields {
+		assert.NoError(t, mw.WriteField(name, value))
+	}
+	for name, content := range files {
+		fw, err := mw.CreateFormFile(name, "dir/"+name+".bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(fw, content)
+	}
+	assert.NoError(t, mw.Close())
+	return &body, mw.FormDataContentType()
+}
+
+func TestGoHttpServerUploadHandlers(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.UploadDir = filepath.Join(t.TempDir(), "uploads")
+	cfg.UploadMaxMb = 1
+	myServer := NewGoHttpServerWithConfig(config.DefaultListenIp+":0", cfg, getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+	content := strings.Repeat("upload me ", 1000)
+	sum := sha256.Sum256([]byte(content))
+
+	body, contentType := multipartForm(t, map[string]string{"comment": "first try"}, map[string]string{"report": content})
+	resp, err := http.Post(ts.URL+"/upload", contentType, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report UploadReport
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, map[string]string{"comment": "first try"}, report.Fields)
+	assert.Equal(t, cfg.UploadDir, report.Dir)
+	if !assert.Len(t, report.Files, 1) {
+		return
+	}
+	file := report.Files[0]
+	assert.Equal(t, "report", file.Field)
+	assert.Equal(t, "report.bin", file.Filename, "only the base name of the file should be kept")
+	assert.Equal(t, int64(len(content)), file.Bytes)
+	assert.Equal(t, hex.EncodeToString(sum[:]), file.Sha256)
+
+	resp, err = http.Get(ts.URL + file.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, content, string(received))
+	assert.Equal(t, file.Sha256, resp.Header.Get("X-Upload-Sha256"))
+	assert.Equal(t, `attachment; filename=report.bin`, resp.Header.Get("Content-Disposition"))
+	assert.Equal(t, int64(len(content)), resp.ContentLength, "the file should be returned as is")
+	assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+
+	for uploaded, served := range map[string]string{
+		"text/plain; charset=utf-8":    "text/plain; charset=utf-8",
+		"text/html; charset=utf-8":     MIMEAppOctetStream,
+		"image/svg+xml":                MIMEAppOctetStream,
+		"":                             MIMEAppOctetStream,
+		"not a content type; charset=": MIMEAppOctetStream,
+	} {
+		page, err := myServer.uploads.Save(strings.NewReader("<script>alert(1)</script>"), "page", "page", uploaded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err = http.Get(ts.URL + page.Url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		assert.Equal(t, served, resp.Header.Get(HeaderContentType), "the file uploaded as %q should not be rendered by a browser", uploaded)
+		assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+		myServer.uploads.Delete(page.Id)
+	}
+
+	tests := []struct {
+		name        string
+		body        io.Reader
+		contentType string
+		chunked     bool
+		wantStatus  int
+	}{
+		{name: "1: a body which is not a form should be refused", body: strings.NewReader("{}"), contentType: MIMEAppJSON, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "2: a Content-Length above UPLOAD_MAX_MB should be refused at once", wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "3: a chunked body above UPLOAD_MAX_MB should be refused", chunked: true, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.body == nil {
+				big, bigType := multipartForm(t, nil, map[string]string{"small": "ok", "zbig": strings.Repeat("x", 2<<20)})
+				tt.body, tt.contentType = big, bigType
+				if tt.chunked {
+					tt.body = onlyReader{big}
+				}
+			}
+			resp, err := http.Post(ts.URL+"/upload", tt.contentType, tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+	entries, err := os.ReadDir(cfg.UploadDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2, "the files of a refused form should be deleted, only the first upload should remain")
+
+	resp, err = http.Get(ts.URL + "/upload/0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
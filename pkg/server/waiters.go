@@ -0,0 +1,53 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// WaiterPool is the semaphore limiting the number of requests sleeping in /wait at the same time, so that a load test
+// cannot pile up goroutines and connections without bound. the zero value is ready to use
+type WaiterPool struct {
+	active   int64
+	rejected uint64
+}
+
+// Acquire takes a place in the pool, it returns false when max requests are already waiting. max 0 means no limit
+func (p *WaiterPool) Acquire(max int) bool {
+	for {
+		active := atomic.LoadInt64(&p.active)
+		if max > 0 && active >= int64(max) {
+			atomic.AddUint64(&p.rejected, 1)
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&p.active, active, active+1) {
+			return true
+		}
+	}
+}
+
+// Release gives back the place taken by Acquire
+func (p *WaiterPool) Release() {
+	atomic.AddInt64(&p.active, -1)
+}
+
+// Active returns the number of requests waiting
+func (p *WaiterPool) Active() int64 {
+	return atomic.LoadInt64(&p.active)
+}
+
+// Rejected returns the number of requests refused because the pool was full
+func (p *WaiterPool) Rejected() uint64 {
+	return atomic.LoadUint64(&p.rejected)
+}
+
+// Write writes the state of the pool in the prometheus text format
+func (p *WaiterPool) Write(w io.Writer) {
+	fmt.Fprintln(w, "# HELP http_wait_waiters Number of requests currently waiting in /wait.")
+	fmt.Fprintln(w, "# TYPE http_wait_waiters gauge")
+	fmt.Fprintf(w, "http_wait_waiters %d\n", p.Active())
+	fmt.Fprintln(w, "# HELP http_wait_rejected_total Number of requests to /wait refused above wait_max_concurrent.")
+	fmt.Fprintln(w, "# TYPE http_wait_rejected_total counter")
+	fmt.Fprintf(w, "http_wait_rejected_total %d\n", p.Rejected())
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaiterPool(t *testing.T) {
+	tests := []struct {
+		name         string
+		max          int
+		acquire      int
+		wantActive   int64
+		wantRejected uint64
+	}{
+		{name: "1: no limit should accept every waiter", max: 0, acquire: 50, wantActive: 50},
+		{name: "2: limit should refuse the waiters above it", max: 10, acquire: 50, wantActive: 10, wantRejected: 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pool WaiterPool
+			var wg sync.WaitGroup
+			for i := 0; i < tt.acquire; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					pool.Acquire(tt.max)
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, tt.wantActive, pool.Active())
+			assert.Equal(t, tt.wantRejected, pool.Rejected())
+			var out bytes.Buffer
+			pool.Write(&out)
+			assert.Contains(t, out.String(), "http_wait_waiters ")
+			for i := int64(0); i < tt.wantActive; i++ {
+				pool.Release()
+			}
+			assert.Equal(t, int64(0), pool.Active())
+		})
+	}
+}
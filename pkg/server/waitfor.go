@@ -0,0 +1,281 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+)
+
+const (
+	defaultWaitForBackoffMin = 250 * time.Millisecond
+	defaultWaitForBackoffMax = 10 * time.Second
+	waitForAttemptTimeout    = 2 * time.Second // max time of one connection attempt
+	dependencyStateWaiting   = "waiting"
+	dependencyStateUp        = "up"
+)
+
+// Dependency is a service that must be reachable before this server is ready
+type Dependency struct {
+	Raw    string `json:"raw"`    // value as given in env WAIT_FOR
+	Scheme string `json:"scheme"` // tcp, http or https
+	Target string `json:"target"` // host:port for tcp, full url for http(s)
+}
+
+// DependencyStatus describes the progress of the wait for one Dependency
+type DependencyStatus struct {
+	Dependency
+	State     string `json:"state"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// ParseWaitFor parses a comma separated list of tcp://host:port or http(s)://host/path values.
+// all malformed entries are reported together in the returned error.
+func ParseWaitFor(list string) ([]Dependency, error) {
+	var deps []Dependency
+	var malformed []string
+	for _, raw := range strings.Split(list, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			malformed = append(malformed, fmt.Sprintf("%q (%v)", raw, err))
+			continue
+		}
+		switch u.Scheme {
+		case "tcp":
+			if _, port, err := net.SplitHostPort(u.Host); err != nil || port == "" || u.Hostname() == "" {
+				malformed = append(malformed, fmt.Sprintf("%q (tcp needs host:port)", raw))
+				continue
+			}
+			deps = append(deps, Dependency{Raw: raw, Scheme: u.Scheme, Target: u.Host})
+		case "http", "https":
+			if u.Host == "" {
+				malformed = append(malformed, fmt.Sprintf("%q (missing host)", raw))
+				continue
+			}
+			deps = append(deps, Dependency{Raw: raw, Scheme: u.Scheme, Target: raw})
+		default:
+			malformed = append(malformed, fmt.Sprintf("%q (scheme should be tcp, http or https)", raw))
+		}
+	}
+	if len(malformed) > 0 {
+		return nil, &config.ErrorConfig{
+			Err: fmt.Errorf("%s", strings.Join(malformed, ", ")),
+			Msg: fmt.Sprintf("ERROR: CONFIG wait_for (env WAIT_FOR) contains %d malformed entries", len(malformed)),
+		}
+	}
+	return deps, nil
+}
+
+// GetDependencyWaiterFromConfig returns the DependencyWaiter of the dependencies of the wait_for setting, giving up after
+// the wait_for_timeout setting. it returns nil when there is nothing to wait for
+func GetDependencyWaiterFromConfig(settings config.Config, logger *slog.Logger) (*DependencyWaiter, error) {
+	deps, err := ParseWaitFor(settings.WaitFor)
+	if err != nil || len(deps) == 0 {
+		return nil, err
+	}
+	return NewDependencyWaiter(deps, settings.WaitForTimeout, logger), nil
+}
+
+// DependencyWaiter polls a list of dependencies with exponential backoff and jitter until all are up
+type DependencyWaiter struct {
+	logger     *slog.Logger
+	timeout    time.Duration
+	backoffMin time.Duration
+	backoffMax time.Duration
+	mu         sync.Mutex
+	status     []DependencyStatus
+}
+
+// NewDependencyWaiter is a constructor for a DependencyWaiter giving up after timeout
+func NewDependencyWaiter(deps []Dependency, timeout time.Duration, logger *slog.Logger) *DependencyWaiter {
+	status := make([]DependencyStatus, len(deps))
+	for i, d := range deps {
+		status[i] = DependencyStatus{Dependency: d, State: dependencyStateWaiting}
+	}
+	return &DependencyWaiter{
+		logger:     logger,
+		timeout:    timeout,
+		backoffMin: defaultWaitForBackoffMin,
+		backoffMax: defaultWaitForBackoffMax,
+		status:     status,
+	}
+}
+
+// Status returns a copy of the current state of every dependency
+func (dw *DependencyWaiter) Status() []DependencyStatus {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	res := make([]DependencyStatus, len(dw.status))
+	copy(res, dw.status)
+	return res
+}
+
+// Ready returns true when all dependencies are up
+func (dw *DependencyWaiter) Ready() bool {
+	for _, s := range dw.Status() {
+		if s.State != dependencyStateUp {
+			return false
+		}
+	}
+	return true
+}
+
+func (dw *DependencyWaiter) update(i int, err error) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	dw.status[i].Attempts++
+	if err != nil {
+		dw.status[i].LastError = err.Error()
+		return
+	}
+	dw.status[i].State = dependencyStateUp
+	dw.status[i].LastError = ""
+}
+
+// checkDependency makes one connection attempt to the dependency
+func checkDependency(ctx context.Context, d Dependency) error {
+	ctx, cancel := context.WithTimeout(ctx, waitForAttemptTimeout)
+	defer cancel()
+	if d.Scheme == "tcp" {
+		return (&TcpCheck{CheckName: d.Raw, Address: d.Target}).Check(ctx)
+	}
+	return (&HttpCheck{CheckName: d.Raw, Url: d.Target}).Check(ctx)
+}
+
+// Wait blocks until every dependency is up or the timeout is reached,
+// in that case the returned error lists the dependencies that never came up.
+func (dw *DependencyWaiter) Wait(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, dw.timeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i, s := range dw.Status() {
+		wg.Add(1)
+		go func(i int, d Dependency) {
+			defer wg.Done()
+			retryWithBackoff(ctx, dw.backoffMin, dw.backoffMax, func(attempt int) (bool, error) {
+				err := checkDependency(ctx, d)
+				dw.update(i, err)
+				if err == nil {
+					dw.logger.Info("dependency is up", "dependency", d.Raw, "attempts", attempt)
+				}
+				return err == nil, err
+			}, func(attempt int, err error, sleep time.Duration) {
+				dw.logger.Info("waiting for dependency", "dependency", d.Raw, "attempt", attempt, "error", err, "next_try_in", sleep)
+			})
+		}(i, s.Dependency)
+	}
+	wg.Wait()
+	var down []string
+	for _, s := range dw.Status() {
+		if s.State != dependencyStateUp {
+			down = append(down, fmt.Sprintf("%s (%d attempts, last error: %s)", s.Raw, s.Attempts, s.LastError))
+		}
+	}
+	if len(down) > 0 {
+		return &config.ErrorConfig{
+			Err: fmt.Errorf("%s", strings.Join(down, ", ")),
+			Msg: fmt.Sprintf("ERROR: %d dependencies never came up after %v", len(down), dw.timeout),
+		}
+	}
+	return nil
+}
+
+// DependencyCheck is a readiness check failing until all the dependencies of the DependencyWaiter are up, the admin port
+// listens during the wait so its progress can be followed
+type DependencyCheck struct {
+	Waiter *DependencyWaiter
+}
+
+func (c *DependencyCheck) Name() string { return "dependencies" }
+func (c *DependencyCheck) Type() string { return "runtime" }
+
+func (c *DependencyCheck) Check(_ context.Context) error {
+	var waiting []string
+	for _, s := range c.Waiter.Status() {
+		if s.State != dependencyStateUp {
+			waiting = append(waiting, s.Raw)
+		}
+	}
+	if len(waiting) > 0 {
+		return fmt.Errorf("waiting for %s", strings.Join(waiting, ", "))
+	}
+	return nil
+}
+
+// DependencyReport is the answer of /dependencies
+type DependencyReport struct {
+	Ready        bool               `json:"ready"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
+
+// UseDependencyWaiter makes StartServer wait for the dependencies of dw before it binds the main port, /readiness of the
+// admin port fails until they are all up and StartServer returns the error of dw when they are not up before its timeout
+func (s *GoHttpServer) UseDependencyWaiter(dw *DependencyWaiter) {
+	s.dependencies = dw
+	s.readiness.Register(&DependencyCheck{Waiter: dw})
+}
+
+// waitForDependencies blocks until the dependencies are up, stopped is true when SIGINT, SIGTERM or Stop ended the wait
+func (s *GoHttpServer) waitForDependencies() (stopped bool, err error) {
+	s.logger.Info("Waiting for dependencies before listening", "max_wait", s.dependencies.timeout, "dependencies", len(s.dependencies.Status()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupted := make(chan bool, 1)
+	go func() {
+		select {
+		case sig := <-s.interrupts:
+			s.logger.Info("Stopped while waiting for the dependencies", "signal", sig.String())
+			cancel()
+			interrupted <- true
+		case <-ctx.Done():
+			interrupted <- false
+		}
+	}()
+	err = s.dependencies.Wait(ctx)
+	cancel()
+	if <-interrupted {
+		return true, nil
+	}
+	if err != nil {
+		s.logger.Error("dependencies not available, giving up", "error", err)
+	}
+	return false, err
+}
+
+// dependencyReport returns the state of the wait for the dependencies, ready without any dependency
+func (s *GoHttpServer) dependencyReport() DependencyReport {
+	if s.dependencies == nil {
+		return DependencyReport{Ready: true, Dependencies: []DependencyStatus{}}
+	}
+	return DependencyReport{Ready: s.dependencies.Ready(), Dependencies: s.dependencies.Status()}
+}
+
+//############# BEGIN DEPENDENCIES HANDLERS
+
+// DependenciesHandler answers the state of every dependency of WAIT_FOR, 503 until they are all up
+func (s *GoHttpServer) DependenciesHandler() http.HandlerFunc {
+	handlerName := "DependenciesHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := s.dependencyReport()
+		status := http.StatusOK
+		if !report.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		s.render(w, r, status, report)
+	}
+}
+
+// ############# END DEPENDENCIES HANDLERS
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWaitFor(t *testing.T) {
+	tests := []struct {
+		name        string
+		list        string
+		wantDeps    int
+		wantErr     bool
+		wantErrText []string
+	}{
+		{name: "1: empty list should return no dependencies", list: "", wantDeps: 0},
+		{name: "2: valid tcp and http entries should be accepted", list: "tcp://postgres:5432, http://auth:8080/health,https://api.example.com", wantDeps: 3},
+		{name: "3: every malformed entry should be reported at once", list: "tcp://postgres,ftp://files:21,http://auth:8080/health,http:///nohost", wantErr: true,
+			wantErrText: []string{"3 malformed", "tcp://postgres", "ftp://files:21", "http:///nohost"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps, err := ParseWaitFor(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWaitFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, text := range tt.wantErrText {
+				assert.Contains(t, err.Error(), text, "error should list every malformed entry")
+			}
+			assert.Equal(t, tt.wantDeps, len(deps))
+		})
+	}
+}
+
+func TestGetDependencyWaiterFromConfig(t *testing.T) {
+	settings := config.DefaultConfig()
+	dw, err := GetDependencyWaiterFromConfig(settings, getTestLogger())
+	assert.NoError(t, err)
+	assert.Nil(t, dw, "there should be no waiter without wait_for")
+
+	settings.WaitFor, settings.WaitForTimeout = "tcp://postgres:5432,http://auth:8080/health", 30*time.Second
+	dw, err = GetDependencyWaiterFromConfig(settings, getTestLogger())
+	assert.NoError(t, err)
+	if assert.NotNil(t, dw) {
+		assert.Len(t, dw.Status(), 2)
+		assert.Equal(t, 30*time.Second, dw.timeout)
+	}
+
+	settings.WaitFor = "ftp://files:21"
+	_, err = GetDependencyWaiterFromConfig(settings, getTestLogger())
+	assert.Error(t, err, "a malformed wait_for should be an error")
+}
+
+// freeAddress returns a local tcp address that nobody is listening on
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen : %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func TestDependencyWaiterWait(t *testing.T) {
+	l := getTestLogger()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+
+	// this tcp dependency comes up after a delay
+	lateAddr := freeAddress(t)
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		late, err := net.Listen("tcp", lateAddr)
+		if err != nil {
+			return
+		}
+		time.Sleep(2 * time.Second)
+		late.Close()
+	}()
+
+	deps, err := ParseWaitFor("tcp://" + lateAddr + "," + healthy.URL + "/health")
+	assert.Nil(t, err)
+	dw := NewDependencyWaiter(deps, 2*time.Second, l)
+	dw.backoffMin = 50 * time.Millisecond
+	assert.False(t, dw.Ready(), "dependencies should not be ready before Wait")
+	assert.Nil(t, dw.Wait(context.Background()), "all dependencies should come up")
+	assert.True(t, dw.Ready(), "dependencies should be ready after Wait")
+	for _, s := range dw.Status() {
+		assert.Equal(t, dependencyStateUp, s.State)
+	}
+
+	// this tcp dependency never comes up
+	neverAddr := freeAddress(t)
+	deps, _ = ParseWaitFor("tcp://" + neverAddr + "," + healthy.URL)
+	dw = NewDependencyWaiter(deps, 500*time.Millisecond, l)
+	dw.backoffMin = 50 * time.Millisecond
+	err = dw.Wait(context.Background())
+	assert.NotNil(t, err, "Wait should fail when a dependency never comes up")
+	assert.Contains(t, err.Error(), neverAddr, "error should name the missing dependency")
+	assert.False(t, strings.Contains(err.Error(), healthy.URL), "error should not name the available dependency")
+	assert.False(t, dw.Ready())
+}
+
+func TestGoHttpServerDependencies(t *testing.T) {
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+	deps, err := ParseWaitFor("tcp://" + upstream.Addr().String())
+	assert.Nil(t, err)
+	dw := NewDependencyWaiter(deps, 2*time.Second, getTestLogger())
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	myServer.UseDependencyWaiter(dw)
+
+	get := func(url string, report interface{}) int {
+		rec := httptest.NewRecorder()
+		myServer.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), report), "the output should be a valid json")
+		return rec.Code
+	}
+	var readiness ReadinessReport
+	assert.Equal(t, http.StatusServiceUnavailable, get("/readiness", &readiness), "the server should not be ready during the wait")
+	if assert.Len(t, readiness.Dependencies, 1) {
+		assert.Equal(t, dependencyStateWaiting, readiness.Dependencies[0].State)
+	}
+	var report DependencyReport
+	assert.Equal(t, http.StatusServiceUnavailable, get("/dependencies", &report))
+	assert.False(t, report.Ready)
+
+	assert.Nil(t, dw.Wait(context.Background()))
+	readiness = ReadinessReport{}
+	assert.Equal(t, http.StatusOK, get("/readiness", &readiness), "the server should be ready once the dependencies are up")
+	assert.Empty(t, readiness.Dependencies)
+	report = DependencyReport{}
+	assert.Equal(t, http.StatusOK, get("/dependencies", &report))
+	assert.True(t, report.Ready)
+	if assert.Len(t, report.Dependencies, 1) {
+		assert.Equal(t, dependencyStateUp, report.Dependencies[0].State)
+	}
+}
+
+func TestGoHttpServerStartServerWaitFor(t *testing.T) {
+	address := freeAddress(t)
+	lateAddr := freeAddress(t)
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		late, err := net.Listen("tcp", lateAddr)
+		if err != nil {
+			return
+		}
+		time.Sleep(3 * time.Second)
+		late.Close()
+	}()
+	deps, err := ParseWaitFor("tcp://" + lateAddr)
+	assert.Nil(t, err)
+	myServer := NewGoHttpServer(address, getTestLogger())
+	dw := NewDependencyWaiter(deps, 3*time.Second, getTestLogger())
+	dw.backoffMin = 50 * time.Millisecond
+	myServer.UseDependencyWaiter(dw)
+	stopped := make(chan error, 1)
+	go func() { stopped <- myServer.StartServer() }()
+	time.Sleep(200 * time.Millisecond)
+	_, err = net.DialTimeout("tcp", address, time.Second)
+	assert.Error(t, err, "the main port should not be bound during the wait")
+	WaitForHttpServer("http://"+address+"/health", 100*time.Millisecond, 30)
+	resp, err := http.Get("http://" + address + "/readiness")
+	if assert.NoError(t, err, "the main port should be bound once the dependencies are up") {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	myServer.Stop()
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("StartServer should return after Stop")
+	}
+
+	// this dependency never comes up
+	deps, _ = ParseWaitFor("tcp://" + freeAddress(t))
+	myServer = NewGoHttpServer(address, getTestLogger())
+	myServer.UseDependencyWaiter(NewDependencyWaiter(deps, 300*time.Millisecond, getTestLogger()))
+	err = myServer.StartServer()
+	var configErr *config.ErrorConfig
+	assert.True(t, errors.As(err, &configErr), "a wait timeout should be a config error, got %v", err)
+
+	// Stop during the wait should end it without error
+	myServer = NewGoHttpServer(address, getTestLogger())
+	myServer.UseDependencyWaiter(NewDependencyWaiter(deps, 10*time.Second, getTestLogger()))
+	go func() { stopped <- myServer.StartServer() }()
+	time.Sleep(100 * time.Millisecond)
+	myServer.Stop()
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err, "a stop during the wait should not be an error")
+	case <-time.After(5 * time.Second):
+		t.Fatal("StartServer should return after Stop during the wait")
+	}
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+const (
+	webhookFormatGeneric = config.WebhookFormatGeneric
+	webhookFormatSlack   = config.WebhookFormatSlack
+	defaultWebhookQueue  = 32              // events waiting to be sent, the next ones are dropped
+	webhookSendTimeout   = 5 * time.Second // maximum time to send one event to all the urls
+	// defaultWebhookTemplate is the text of the notifications, with the identity of the pod inside k8s
+	defaultWebhookTemplate = `{{.App}} {{.Version}} {{.Reason}} on {{if .Pod}}pod {{.Namespace}}/{{.Pod}} of node {{.Node}}{{else}}host {{.Hostname}}{{end}} : {{.Message}}`
+)
+
+// WebhookConfig are the urls notified of the lifecycle events of the server and the format of the notifications
+type WebhookConfig struct {
+	Urls     []string
+	Format   string   // generic posts the WebhookEvent, slack posts only the text like an incoming webhook expects
+	Template string   // text/template of the text executed with the WebhookEvent
+	Events   []string // reasons of the events notified like Started, all of them when empty
+}
+
+// GetWebhookConfigFromConfig returns the webhook configuration given by the validated webhook_urls, webhook_format,
+// webhook_template and webhook_events settings, the default template being used when webhook_template is empty.
+// nil is returned when webhook_urls is empty
+func GetWebhookConfigFromConfig(settings config.Config) *WebhookConfig {
+	urls := config.SplitList(settings.WebhookUrls)
+	if len(urls) == 0 {
+		return nil
+	}
+	webhooks := WebhookConfig{Urls: urls, Format: settings.WebhookFormat, Template: settings.WebhookTemplate,
+		Events: config.SplitList(settings.WebhookEvents)}
+	if webhooks.Template == "" {
+		webhooks.Template = defaultWebhookTemplate
+	}
+	return &webhooks
+}
+
+// WebhookEvent is a lifecycle event of the server with the identity of its pod, posted as is in the generic format
+type WebhookEvent struct {
+	Type      string    `json:"type"`   // Normal or Warning, like the k8s Events
+	Reason    string    `json:"reason"` // Started, ShuttingDown, Ready, NotReady, ProbeSwitchedDown, ChaosCrash...
+	Message   string    `json:"message"`
+	Time      time.Time `json:"time"`
+	App       string    `json:"app"`
+	Version   string    `json:"version"`
+	Hostname  string    `json:"hostname"`
+	Pod       string    `json:"pod,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Node      string    `json:"node,omitempty"`
+	PodIp     string    `json:"pod_ip,omitempty"`
+	Text      string    `json:"text"` // the message of WEBHOOK_TEMPLATE
+}
+
+// WebhookNotifier posts the lifecycle events of the server to webhooks, so the ops channels see the instances come
+// and go. Notify never blocks the caller, the events are sent one at a time by Run
+type WebhookNotifier struct {
+	config     WebhookConfig
+	tmpl       *template.Template
+	logger     *slog.Logger
+	httpClient *http.Client
+	identity   WebhookEvent // App, Version, Hostname and the downward api fields of every event
+	now        func() time.Time
+	queue      chan WebhookEvent
+	mu         sync.Mutex
+	failed     map[string]bool // the last event could not be sent to the url, to log the error once
+}
+
+// NewWebhookNotifier is a constructor for a WebhookNotifier of config
+func NewWebhookNotifier(config WebhookConfig, logger *slog.Logger) (*WebhookNotifier, error) {
+	tmpl, err := template.New("webhook").Parse(config.Template)
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	identity := WebhookEvent{App: info.APP, Version: info.VERSION, Hostname: hostname}
+	if k8s := info.GetK8sDownwardInfo(os.LookupEnv, info.DefaultK8sServiceAccountPath); k8s != nil {
+		identity.Pod, identity.Namespace, identity.Node, identity.PodIp = k8s.PodName, k8s.PodNamespace, k8s.NodeName, k8s.PodIp
+	}
+	return &WebhookNotifier{config: config, tmpl: tmpl, logger: logger, httpClient: &http.Client{Timeout: webhookSendTimeout},
+		identity: identity, now: time.Now, queue: make(chan WebhookEvent, defaultWebhookQueue), failed: map[string]bool{}}, nil
+}
+
+// newEvent returns the WebhookEvent of reason with its text, nil when reason is not in the notified events
+func (wn *WebhookNotifier) newEvent(eventType, reason, message string) *WebhookEvent {
+	if len(wn.config.Events) > 0 && !slices.Contains(wn.config.Events, reason) {
+		return nil
+	}
+	event := wn.identity
+	event.Type, event.Reason, event.Message, event.Time = eventType, reason, message, wn.now().UTC()
+	var text bytes.Buffer
+	if err := wn.tmpl.Execute(&text, event); err != nil {
+		wn.logger.Warn("WEBHOOK_TEMPLATE could not be executed, using the message", "reason", reason, "error", err)
+		text.Reset()
+		text.WriteString(message)
+	}
+	event.Text = text.String()
+	return &event
+}
+
+// Notify queues an event of eventType (Normal or Warning) to be sent by Run, it is dropped when too many are waiting
+func (wn *WebhookNotifier) Notify(eventType, reason, message string) {
+	event := wn.newEvent(eventType, reason, message)
+	if event == nil {
+		return
+	}
+	select {
+	case wn.queue <- *event:
+	default:
+		wn.logger.Warn("too many webhook events waiting, event dropped", "reason", reason)
+	}
+}
+
+// payload returns the body of event in the format of the config
+func (wn *WebhookNotifier) payload(event WebhookEvent) ([]byte, error) {
+	if wn.config.Format == webhookFormatSlack {
+		return json.Marshal(map[string]string{"text": event.Text})
+	}
+	return json.Marshal(event)
+}
+
+// send posts event to every url, the error is the one of the last url which failed
+func (wn *WebhookNotifier) send(ctx context.Context, event WebhookEvent) error {
+	body, err := wn.payload(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookSendTimeout)
+	defer cancel()
+	var lastErr error
+	for i, u := range wn.config.Urls {
+		err := wn.post(ctx, u, body)
+		wn.mu.Lock()
+		if err != nil && !wn.failed[u] {
+			// the url may contain a secret, it is logged by its position in WEBHOOK_URLS
+			wn.logger.Warn("unable to send the webhook", "webhook", i+1, "reason", event.Reason, "error", err)
+		}
+		wn.failed[u] = err != nil
+		wn.mu.Unlock()
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// post sends body to the url u once
+func (wn *WebhookNotifier) post(ctx context.Context, u string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return errors.New("invalid webhook url")
+	}
+	req.Header.Set("Content-Type", MIMEAppJSONCharsetUTF8)
+	req.Header.Set("User-Agent", info.APP+"/"+info.VERSION)
+	resp, err := wn.httpClient.Do(req)
+	if urlErr, ok := err.(*url.Error); ok {
+		// the error of the client repeats the url
+		return fmt.Errorf("request failed : %w", urlErr.Err)
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// Flush posts the notifications still waiting in the queue to every url of WEBHOOK_URLS, without retrying the urls
+// which fail
+func (wn *WebhookNotifier) Flush(ctx context.Context) error {
+	return drainQueue(ctx, wn.queue, wn.send)
+}
+
+// Stop returns a shutdown hook sending the events still waiting, then at once an event of reason, so the stop of
+// the server is notified before the process exits
+func (wn *WebhookNotifier) Stop(reason, message string) ShutdownHook {
+	return func(ctx context.Context) error {
+		if err := wn.Flush(ctx); err != nil {
+			return err
+		}
+		if event := wn.newEvent(k8sEventTypeNormal, reason, message); event != nil {
+			return wn.send(ctx, *event)
+		}
+		return nil
+	}
+}
+
+// Run sends the queued events until ctx is done
+func (wn *WebhookNotifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-wn.queue:
+			wn.send(ctx, event)
+		}
+	}
+}
+
+// UseWebhooks notifies the webhooks of config of the lifecycle events, the last one is sent when the server shuts down
+func (s *GoHttpServer) UseWebhooks(config WebhookConfig) error {
+	notifier, err := NewWebhookNotifier(config, s.logger)
+	if err != nil {
+		return err
+	}
+	s.webhooks = notifier
+	s.OnShutdown(notifier.Stop("ShuttingDown", "the server stopped accepting requests after a signal"))
+	return nil
+}
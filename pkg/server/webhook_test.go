@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWebhookConfigFromConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(c *config.Config)
+		want      *WebhookConfig
+	}{
+		{name: "1: no webhook_urls should disable the webhooks", configure: func(c *config.Config) {}},
+		{name: "2: webhook_urls alone should use the generic format and the default template", configure: func(c *config.Config) { c.WebhookUrls = "https://hooks/a, http://hooks/b" },
+			want: &WebhookConfig{Urls: []string{"https://hooks/a", "http://hooks/b"}, Format: webhookFormatGeneric, Template: defaultWebhookTemplate}},
+		{name: "3: the format, template and events should be used", configure: func(c *config.Config) {
+			c.WebhookUrls, c.WebhookFormat, c.WebhookTemplate, c.WebhookEvents = "https://hooks.slack.com/services/T0/B0/x", "slack", "{{.Reason}}", "Started,ShuttingDown"
+		}, want: &WebhookConfig{Urls: []string{"https://hooks.slack.com/services/T0/B0/x"}, Format: webhookFormatSlack, Template: "{{.Reason}}", Events: []string{"Started", "ShuttingDown"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.DefaultConfig()
+			tt.configure(&settings)
+			assert.Equal(t, tt.want, GetWebhookConfigFromConfig(settings))
+		})
+	}
+}
+
+// fakeWebhook records the bodies of the posted events
+type fakeWebhook struct {
+	mu     sync.Mutex
+	status int
+	bodies []string
+}
+
+func (fw *fakeWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.bodies = append(fw.bodies, string(body))
+	if fw.status != 0 {
+		w.WriteHeader(fw.status)
+	}
+}
+
+func (fw *fakeWebhook) received() []string {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return append([]string{}, fw.bodies...)
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	t.Setenv("MY_POD_NAME", "info-7d9f")
+	t.Setenv("MY_POD_NAMESPACE", "prod")
+	t.Setenv("MY_NODE_NAME", "node-3")
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		config     WebhookConfig
+		wantBodies []string
+	}{
+		{name: "1: the generic format should post the event with the identity of the pod", config: WebhookConfig{Format: webhookFormatGeneric, Template: defaultWebhookTemplate},
+			wantBodies: []string{`{"type":"Normal","reason":"Started","message":"listening on :8080","time":"2024-03-05T12:00:00Z","app":"` + info.APP + `","version":"` + info.VERSION +
+				`","hostname":"HOST","pod":"info-7d9f","namespace":"prod","node":"node-3","text":"` + info.APP + ` ` + info.VERSION + ` Started on pod prod/info-7d9f of node node-3 : listening on :8080"}`,
+				`{"type":"Warning","reason":"ChaosCrash","message":"exiting","time":"2024-03-05T12:00:00Z","app":"` + info.APP + `","version":"` + info.VERSION +
+					`","hostname":"HOST","pod":"info-7d9f","namespace":"prod","node":"node-3","text":"` + info.APP + ` ` + info.VERSION + ` ChaosCrash on pod prod/info-7d9f of node node-3 : exiting"}`}},
+		{name: "2: the slack format should post the text of the template", config: WebhookConfig{Format: webhookFormatSlack, Template: "{{if eq .Type \"Warning\"}}:warning: {{end}}{{.Reason}} {{.Pod}}"},
+			wantBodies: []string{`{"text":"Started info-7d9f"}`, `{"text":":warning: ChaosCrash info-7d9f"}`}},
+		{name: "3: only the events of the config should be posted", config: WebhookConfig{Format: webhookFormatSlack, Template: "{{.Reason}}", Events: []string{"ChaosCrash"}},
+			wantBodies: []string{`{"text":"ChaosCrash"}`}},
+	}
+	hostname, _ := os.Hostname()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := &fakeWebhook{}
+			ts := httptest.NewServer(hook)
+			defer ts.Close()
+			tt.config.Urls = []string{ts.URL}
+			notifier, err := NewWebhookNotifier(tt.config, getTestLogger())
+			if err != nil {
+				t.Fatal(err)
+			}
+			notifier.now = func() time.Time { return now }
+			notifier.Notify(k8sEventTypeNormal, "Started", "listening on :8080")
+			notifier.Notify(k8sEventTypeWarning, "ChaosCrash", "exiting")
+			assert.NoError(t, notifier.Flush(context.Background()))
+			bodies := hook.received()
+			if !assert.Len(t, bodies, len(tt.wantBodies)) {
+				return
+			}
+			for i, want := range tt.wantBodies {
+				if tt.config.Format == webhookFormatGeneric {
+					var event WebhookEvent
+					assert.NoError(t, json.Unmarshal([]byte(bodies[i]), &event))
+					assert.Equal(t, hostname, event.Hostname)
+					event.Hostname = "HOST"
+					got, _ := json.Marshal(event)
+					bodies[i] = string(got)
+				}
+				assert.JSONEq(t, want, bodies[i])
+			}
+		})
+	}
+}
+
+func TestWebhookNotifierStop(t *testing.T) {
+	hook := &fakeWebhook{status: http.StatusInternalServerError}
+	ts := httptest.NewServer(hook)
+	defer ts.Close()
+	closed := httptest.NewServer(hook)
+	closed.Close()
+	notifier, err := NewWebhookNotifier(WebhookConfig{Urls: []string{closed.URL, ts.URL}, Format: webhookFormatSlack, Template: "{{.Reason}}"}, getTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier.Notify(k8sEventTypeWarning, "NotReady", "db is down")
+	err = notifier.Stop("ShuttingDown", "bye")(context.Background())
+	assert.Error(t, err, "a webhook answering 500 should be reported")
+	assert.Equal(t, []string{`{"text":"NotReady"}`, `{"text":"ShuttingDown"}`}, hook.received(), "the waiting events should be sent before the last one")
+}
+
+func TestGoHttpServerReadinessWebhooks(t *testing.T) {
+	hook := &fakeWebhook{}
+	ts := httptest.NewServer(hook)
+	defer ts.Close()
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	check := &fakeCheck{name: "db"}
+	myServer.UseReadinessChecks(check)
+	assert.NoError(t, myServer.UseWebhooks(WebhookConfig{Urls: []string{ts.URL}, Format: webhookFormatSlack, Template: "{{.Reason}} {{.Message}}"}))
+	probe := func() {
+		rec := httptest.NewRecorder()
+		myServer.ReadinessHandler()(rec, httptest.NewRequest(http.MethodGet, "/readiness", nil))
+	}
+	probe()
+	check.err = errors.New("connection refused")
+	probe()
+	probe()
+	check.err = nil
+	probe()
+	assert.NoError(t, myServer.webhooks.Flush(context.Background()))
+	assert.Equal(t, []string{`{"text":"NotReady the readiness is not_ready : db (connection refused)"}`, `{"text":"Ready the readiness checks succeed again"}`},
+		hook.received(), "only the flips of the readiness should be notified")
+}
@@ -0,0 +1,297 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/info"
+)
+
+const (
+	websocketGuid         = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11" // rfc 6455 magic value of Sec-WebSocket-Accept
+	wsOpText              = 0x1
+	wsOpClose             = 0x8
+	wsOpPing              = 0x9
+	wsOpPong              = 0xA
+	wsMaxFrameSize        = 64 << 10 // the clients only send control frames, anything bigger is refused
+	wsWriteTimeout        = 10 * time.Second
+	defaultWsPingInterval = 30 * time.Second
+	defaultStatsInterval  = 2 * time.Second
+	maxStatsInterval      = 60 // seconds
+)
+
+var (
+	errWsVersion        = errors.New("unsupported websocket version, only 13 is supported")
+	errWsOrigin         = errors.New("websocket origin not allowed")
+	errWsClosedByClient = errors.New("closed by the client")
+)
+
+// WsConn is a server side WebSocket connection, its writes can be done from several goroutines
+type WsConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMu sync.Mutex
+}
+
+// websocketAccept returns the Sec-WebSocket-Accept value answering the Sec-WebSocket-Key of the client
+func websocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGuid))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContainsToken returns true when the comma separated header values contain token, ignoring the case
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkWebsocketOrigin refuses the handshake sent by the page of another site, the same origin policy does not apply
+// to the websockets so any page could read them with the cookies of its visitor otherwise. the Origin must be the one
+// of the server or one of allowedOrigins, the clients without Origin are not browsers and are accepted
+func checkWebsocketOrigin(r *http.Request, allowedOrigins []string) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return nil
+		}
+	}
+	return errWsOrigin
+}
+
+// UpgradeWebsocket checks the opening handshake of the client and its Origin, takes over the connection and answers 101
+func UpgradeWebsocket(w http.ResponseWriter, r *http.Request, allowedOrigins []string) (*WsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !headerContainsToken(r.Header, "Connection", "upgrade") ||
+		!headerContainsToken(r.Header, "Upgrade", "websocket") || key == "" {
+		return nil, errors.New("not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, errWsVersion
+	}
+	if err := checkWebsocketOrigin(r, allowedOrigins); err != nil {
+		return nil, err
+	}
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// the deadlines of the http server stay on a hijacked connection
+	_ = conn.SetDeadline(time.Time{})
+	ws := &WsConn{conn: conn, reader: rw.Reader}
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	_, err = fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", websocketAccept(key))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
+// WriteMessage sends payload in one unmasked frame, as the server frames must be
+func (ws *WsConn) WriteMessage(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode, 0}
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	_ = ws.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	_, err := ws.conn.Write(append(header, payload...))
+	return err
+}
+
+// ReadFrame returns the opcode and the unmasked payload of the next frame sent by the client
+func (ws *WsConn) ReadFrame() (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.reader, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := header[0] & 0x0f
+	if header[1]&0x80 == 0 {
+		return 0, nil, errors.New("the frames of a websocket client must be masked")
+	}
+	size := uint64(header[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.reader, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.reader, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	if size > wsMaxFrameSize {
+		return 0, nil, fmt.Errorf("websocket frame of %d bytes is too big", size)
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.reader, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(ws.reader, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// Close sends a close frame with the status code, then closes the connection
+func (ws *WsConn) Close(code uint16) error {
+	_ = ws.WriteMessage(wsOpClose, binary.BigEndian.AppendUint16(nil, code))
+	return ws.conn.Close()
+}
+
+// LiveStats is one snapshot of the state of this server pushed by /ws/stats
+type LiveStats struct {
+	Time         string     `json:"time"`
+	Hostname     string     `json:"hostname"`
+	Uptime       string     `json:"uptime"`
+	NumGoroutine int        `json:"num_goroutine"`
+	NumCPU       int        `json:"num_cpu"`
+	GoMaxProcs   int        `json:"gomaxprocs"`
+	Memory       MemoryInfo `json:"memory"`
+}
+
+// (*GoHttpServer) liveStats returns the current runtime, goroutine and memory statistics
+func (s *GoHttpServer) liveStats(hostname string) LiveStats {
+	return LiveStats{
+		Time:         time.Now().Format(time.RFC3339Nano),
+		Hostname:     hostname,
+		Uptime:       time.Since(s.startTime).Round(time.Second).String(),
+		NumGoroutine: runtime.NumGoroutine(),
+		NumCPU:       runtime.NumCPU(),
+		GoMaxProcs:   runtime.GOMAXPROCS(0),
+		Memory:       GetMemoryInfo(info.DefaultCgroupRoot),
+	}
+}
+
+// WsStatsHandler upgrades to a WebSocket and pushes a LiveStats every interval seconds, 2 by default.
+// ws_max_connections and ws_origins are read from the configuration given by current at each upgrade.
+// the client is pinged every pingInterval and disconnected when it does not answer before the next ping
+func (s *GoHttpServer) WsStatsHandler(current func() config.Config, pingInterval time.Duration) http.HandlerFunc {
+	handlerName := "WsStatsHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	hostname, _ := os.Hostname()
+	var connections int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		settings := current()
+		maxConnections, allowedOrigins := settings.WsMaxConns, config.SplitList(settings.WsOrigins)
+		seconds, err := parseIntParam(r, "interval", int(defaultStatsInterval.Seconds()), 1, maxStatsInterval)
+		if err != nil {
+			http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if atomic.AddInt32(&connections, 1) > int32(maxConnections) {
+			atomic.AddInt32(&connections, -1)
+			s.logger.WarnContext(r.Context(), "too many websocket connections", "handler", handlerName, "max", maxConnections)
+			http.Error(w, "ERROR: too many websocket connections, retry later", http.StatusServiceUnavailable)
+			return
+		}
+		defer atomic.AddInt32(&connections, -1)
+		ws, err := UpgradeWebsocket(w, r, allowedOrigins)
+		if err != nil {
+			status := http.StatusBadRequest
+			switch {
+			case errors.Is(err, errWsVersion):
+				status = http.StatusUpgradeRequired
+			case errors.Is(err, errWsOrigin):
+				status = http.StatusForbidden
+				s.logger.WarnContext(r.Context(), "websocket origin refused", "handler", handlerName, "origin", r.Header.Get("Origin"))
+			}
+			http.Error(w, "ERROR: "+err.Error(), status)
+			return
+		}
+		s.logger.InfoContext(r.Context(), "websocket connected", "handler", handlerName, "interval_seconds", seconds)
+		done := make(chan error, 1)
+		go func() {
+			// the read deadline is pushed back by every frame, a client silent for two pings is gone
+			for {
+				_ = ws.conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+				opcode, payload, err := ws.ReadFrame()
+				if err != nil {
+					done <- err
+					return
+				}
+				switch opcode {
+				case wsOpPing:
+					_ = ws.WriteMessage(wsOpPong, payload)
+				case wsOpClose:
+					done <- nil
+					return
+				}
+			}
+		}()
+		sendStats := func() error {
+			msg, err := json.Marshal(s.liveStats(hostname))
+			if err != nil {
+				return err
+			}
+			return ws.WriteMessage(wsOpText, msg)
+		}
+		stats := time.NewTicker(time.Duration(seconds) * time.Second)
+		defer stats.Stop()
+		ping := time.NewTicker(pingInterval)
+		defer ping.Stop()
+		err = sendStats()
+		for err == nil {
+			select {
+			case err = <-done:
+				if err == nil {
+					err = errWsClosedByClient
+				}
+			case <-ping.C:
+				err = ws.WriteMessage(wsOpPing, nil)
+			case <-stats.C:
+				err = sendStats()
+			}
+		}
+		ws.Close(1000)
+		s.logger.InfoContext(r.Context(), "websocket disconnected", "handler", handlerName, "reason", err)
+	}
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// dialTestWebsocket opens a websocket on the path of the test server and checks the answer of the handshake
+func dialTestWebsocket(t *testing.T, ts *httptest.Server, path string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nAccept-Encoding: gzip\r\n\r\n", path)
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"), "the accept value of rfc 6455")
+	return conn, reader
+}
+
+// writeTestFrame sends a masked frame like a websocket client
+func writeTestFrame(conn net.Conn, opcode byte, payload []byte) {
+	mask := []byte{1, 2, 3, 4}
+	frame := append([]byte{0x80 | opcode, 0x80 | byte(len(payload))}, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	conn.Write(frame)
+}
+
+// readTestFrame reads an unmasked frame sent by the server
+func readTestFrame(t *testing.T, reader *bufio.Reader) (byte, []byte) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		t.Fatal(err)
+	}
+	size := int(header[1] & 0x7f)
+	switch size {
+	case 126:
+		ext := make([]byte, 2)
+		io.ReadFull(reader, ext)
+		size = int(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		io.ReadFull(reader, ext)
+		size = int(binary.BigEndian.Uint64(ext))
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0f, payload
+}
+
+func TestGoHttpServerWsStats(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	current := func() config.Config {
+		config := config.DefaultConfig()
+		config.WsMaxConns = 1
+		return config
+	}
+	ts := httptest.NewServer(Chain(myServer.WsStatsHandler(current, 100*time.Millisecond), myServer.allowMethods(http.MethodGet)))
+	defer ts.Close()
+
+	conn, reader := dialTestWebsocket(t, ts, "/ws/stats?interval=1")
+	defer conn.Close()
+	opcode, payload := readTestFrame(t, reader)
+	assert.Equal(t, byte(wsOpText), opcode)
+	var stats LiveStats
+	assert.NoError(t, json.Unmarshal(payload, &stats), "the stats should be a valid json")
+	assert.Greater(t, stats.NumGoroutine, 0)
+	assert.Greater(t, stats.Memory.HeapAllocBytes, uint64(0))
+
+	opcode, _ = readTestFrame(t, reader)
+	assert.Equal(t, byte(wsOpPing), opcode, "the server should ping the client")
+	writeTestFrame(conn, wsOpPing, []byte("hello"))
+	for opcode != wsOpPong {
+		opcode, payload = readTestFrame(t, reader)
+	}
+	assert.Equal(t, "hello", string(payload), "the pong should echo the payload of the ping")
+
+	resp, err := http.Get(ts.URL + "/ws/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "the connections above the limit should be refused")
+
+	writeTestFrame(conn, wsOpClose, []byte{0x03, 0xe8})
+	for opcode != wsOpClose {
+		opcode, _ = readTestFrame(t, reader)
+	}
+}
+
+func TestGoHttpServerWsStatsReadsCurrentSettings(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	settings := config.DefaultConfig()
+	settings.WsMaxConns = 0
+	ts := httptest.NewServer(myServer.WsStatsHandler(func() config.Config { return settings }, defaultWsPingInterval))
+	defer ts.Close()
+	upgrade := func() int {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/ws/stats", nil)
+		req.Header.Set("Origin", "https://dashboard.example.com")
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, upgrade(), "ws_max_connections 0 should refuse the connection")
+	settings.WsMaxConns = 1
+	assert.Equal(t, http.StatusForbidden, upgrade(), "a raised ws_max_connections should apply to the next upgrade")
+	settings.WsOrigins = "https://dashboard.example.com"
+	assert.Equal(t, http.StatusSwitchingProtocols, upgrade(), "a new ws_origins should apply to the next upgrade")
+}
+
+func TestGoHttpServerWsStatsThroughMiddlewares(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+	conn, reader := dialTestWebsocket(t, ts, "/ws/stats")
+	defer conn.Close()
+	opcode, _ := readTestFrame(t, reader)
+	assert.Equal(t, byte(wsOpText), opcode, "the connection should be taken over through the logging, metrics and compression middlewares")
+}
+
+func TestCheckWebsocketOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		allowed []string
+		wantErr bool
+	}{
+		{name: "1: a client without Origin should be accepted"},
+		{name: "2: the origin of the server should be accepted", origin: "https://info.example.com"},
+		{name: "3: the origin of another site should be refused", origin: "https://evil.example", wantErr: true},
+		{name: "4: an allowed origin should be accepted", origin: "https://dashboard.example.com", allowed: []string{"https://Dashboard.example.com/"}},
+		{name: "5: another port of the server should be refused", origin: "https://info.example.com:8443", wantErr: true},
+		{name: "6: a null origin should be refused", origin: "null", wantErr: true},
+		{name: "7: * should accept all the origins", origin: "https://evil.example", allowed: []string{"*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "https://info.example.com/ws/stats", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			err := checkWebsocketOrigin(r, tt.allowed)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errWsOrigin)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGoHttpServerWsStatsInvalidHandshake(t *testing.T) {
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	ts := httptest.NewServer(myServer.WsStatsHandler(config.DefaultConfig, defaultWsPingInterval))
+	defer ts.Close()
+	tests := []struct {
+		name       string
+		query      string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{name: "1: a plain GET should be refused", wantStatus: http.StatusBadRequest},
+		{name: "2: an old websocket version should need an upgrade", wantStatus: http.StatusUpgradeRequired, headers: map[string]string{
+			"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ==", "Sec-WebSocket-Version": "8"}},
+		{name: "3: an invalid interval should be refused", query: "?interval=0", wantStatus: http.StatusBadRequest},
+		{name: "4: the page of another site should be refused", wantStatus: http.StatusForbidden, headers: map[string]string{"Origin": "https://evil.example",
+			"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ==", "Sec-WebSocket-Version": "13"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+"/ws/stats"+tt.query, nil)
+			for name, val := range tt.headers {
+				req.Header.Set(name, val)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// XfccElement is the certificate of one client given by a mesh sidecar in the X-Forwarded-Client-Cert header
+type XfccElement struct {
+	By      string   `json:"by,omitempty"`      // identity of the proxy which verified the client certificate
+	Hash    string   `json:"hash,omitempty"`    // sha256 of the client certificate
+	Subject string   `json:"subject,omitempty"` // subject of the client certificate
+	Uris    []string `json:"uris,omitempty"`    // uri sans of the client certificate, the spiffe id of the workload in a mesh
+	Dns     []string `json:"dns,omitempty"`     // dns sans of the client certificate
+}
+
+// WhoamiInfo is what the server knows about the caller, from the connection, its credentials and the proxies
+type WhoamiInfo struct {
+	ClientIp      string              `json:"client_ip"`                 // ip of the client, resolved with TRUSTED_PROXIES
+	ProxyAddr     string              `json:"proxy_addr,omitempty"`      // address of the trusted proxy which forwarded the request
+	IpChain       []string            `json:"ip_chain"`                  // X-Forwarded-For hops followed by the peer of the connection
+	AuthMode      string              `json:"auth_mode"`                 // AUTH_MODE of the server
+	BasicAuthUser string              `json:"basic_auth_user,omitempty"` // user of the Authorization: Basic header, the password is never shown
+	JwtVerified   bool                `json:"jwt_verified"`              // true when the jwt was validated with the keys of AUTH_JWKS_URL
+	JwtClaims     JwtClaims           `json:"jwt_claims,omitempty"`      // claims of AUTH_JWT_CLAIMS of the bearer jwt
+	TlsClient     *CertificateDetails `json:"tls_client,omitempty"`      // certificate presented by the client over https
+	MeshIdentity  []XfccElement       `json:"mesh_identity,omitempty"`   // X-Forwarded-Client-Cert of an envoy or istio sidecar
+}
+
+// splitQuoted splits s on sep, except inside the double quoted parts which may contain escaped quotes
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted, escaped, start := false, false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\' && quoted:
+			escaped = true
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// ParseXfcc returns the elements of the X-Forwarded-Client-Cert header values, as sent by envoy : a comma separated
+// element per proxy, each made of semicolon separated key=value pairs whose values may be quoted
+func ParseXfcc(values []string) []XfccElement {
+	var elements []XfccElement
+	for _, value := range values {
+		for _, rawElement := range splitQuoted(value, ',') {
+			var element XfccElement
+			for _, pair := range splitQuoted(rawElement, ';') {
+				key, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if !found {
+					continue
+				}
+				if len(val) >= 2 && strings.HasPrefix(val, `"`) && strings.HasSuffix(val, `"`) {
+					val = strings.ReplaceAll(val[1:len(val)-1], `\"`, `"`)
+				}
+				switch strings.ToLower(key) {
+				case "by":
+					element.By = val
+				case "hash":
+					element.Hash = val
+				case "subject":
+					element.Subject = val
+				case "uri":
+					element.Uris = append(element.Uris, val)
+				case "dns":
+					element.Dns = append(element.Dns, val)
+				}
+			}
+			if element.By != "" || element.Hash != "" || element.Subject != "" || len(element.Uris) > 0 || len(element.Dns) > 0 {
+				elements = append(elements, element)
+			}
+		}
+	}
+	return elements
+}
+
+// decodeJwtClaims returns the claims of the bearer jwt without checking it, like the tokens an OIDC ingress
+// already validated before forwarding them, nil when the token is not a jwt
+func decodeJwtClaims(token string) JwtClaims {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil
+	}
+	var claims JwtClaims
+	if json.Unmarshal(payload, &claims) != nil {
+		return nil
+	}
+	return claims
+}
+
+// GetWhoamiInfo returns the identity of the caller of r, authenticated with auth
+func GetWhoamiInfo(r *http.Request, auth AuthConfig) WhoamiInfo {
+	whoami := WhoamiInfo{
+		ClientIp:     ParseRemoteAddr(r.RemoteAddr).RemoteIp,
+		ProxyAddr:    ProxyAddrFromContext(r.Context()),
+		IpChain:      splitHeaderList(r.Header, "X-Forwarded-For"),
+		AuthMode:     auth.Mode,
+		MeshIdentity: ParseXfcc(r.Header.Values("X-Forwarded-Client-Cert")),
+	}
+	peer := r.RemoteAddr
+	if whoami.ProxyAddr != "" {
+		peer = whoami.ProxyAddr
+	}
+	whoami.IpChain = append(whoami.IpChain, ParseRemoteAddr(peer).RemoteIp)
+	if user, _, ok := r.BasicAuth(); ok {
+		whoami.BasicAuthUser = user
+	}
+	claims := JwtClaimsFromContext(r.Context())
+	whoami.JwtVerified = claims != nil
+	if bearer, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found && claims == nil {
+		claims = decodeJwtClaims(bearer)
+	}
+	if claims != nil {
+		whoami.JwtClaims = claims.Select(auth.Claims)
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		details := NewCertificateDetails(r.TLS.PeerCertificates[0])
+		whoami.TlsClient = &details
+	}
+	return whoami
+}
+
+//############# BEGIN WHOAMI HANDLERS
+
+// WhoamiHandler returns everything known about the caller : ip chain, tls client certificate, basic auth user,
+// jwt claims and mesh identity, to check what an ingress or a sidecar forwards to the pods
+func (s *GoHttpServer) WhoamiHandler() http.HandlerFunc {
+	handlerName := "WhoamiHandler"
+	s.logger.Debug(initCallMsg, "handler", handlerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.jsonResponseWithStatus(w, r, http.StatusOK, GetWhoamiInfo(r, s.auth))
+	}
+}
+
+// ############# END WHOAMI HANDLERS
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-cloud-k8s-info/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoHttpServerWhoamiJwt(t *testing.T) {
+	jwks := newTestJwks(t)
+	myServer := NewGoHttpServer(config.DefaultListenIp+":0", getTestLogger())
+	myServer.UseAuth(AuthConfig{Mode: authModeJwt, JwksUrl: jwks.server.URL, Audience: "go-info", Claims: []string{"sub", "email"}})
+	ts := httptest.NewServer(myServer.router)
+	defer ts.Close()
+
+	valid := jwks.sign(t, "RS256", "rsa-1", map[string]interface{}{"sub": "alice", "email": "alice@example.com", "aud": "go-info", "exp": time.Now().Add(time.Hour).Unix()})
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+		wantError  string
+	}{
+		{name: "1: a valid jwt should get the selected claims", token: valid, wantStatus: http.StatusOK},
+		{name: "2: a request without jwt should be refused", wantStatus: http.StatusUnauthorized, wantError: "jwt_malformed"},
+		{name: "3: a jwt for another audience should be refused", wantStatus: http.StatusUnauthorized, wantError: "jwt_invalid_audience",
+			token: jwks.sign(t, "RS256", "rsa-1", map[string]interface{}{"sub": "alice", "aud": "other"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+"/whoami", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantError != "" {
+				var body struct {
+					Error string `json:"error"`
+				}
+				json.NewDecoder(resp.Body).Decode(&body)
+				assert.Equal(t, tt.wantError, body.Error)
+				assert.Contains(t, resp.Header.Get("WWW-Authenticate"), `error="invalid_token"`)
+				return
+			}
+			var whoami WhoamiInfo
+			if err := json.NewDecoder(resp.Body).Decode(&whoami); err != nil {
+				t.Fatalf("the output should be a valid json : %v", err)
+			}
+			assert.Equal(t, authModeJwt, whoami.AuthMode)
+			assert.Equal(t, JwtClaims{"sub": "alice", "email": "alice@example.com"}, whoami.JwtClaims, "only the claims of AUTH_JWT_CLAIMS should be echoed")
+		})
+	}
+}
+
+func TestParseXfcc(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   []XfccElement
+	}{
+		{name: "1: an istio header should give the spiffe ids", values: []string{`By=spiffe://cluster.local/ns/default/sa/info;Hash=abc123;Subject="";URI=spiffe://cluster.local/ns/default/sa/client`},
+			want: []XfccElement{{By: "spiffe://cluster.local/ns/default/sa/info", Hash: "abc123", Uris: []string{"spiffe://cluster.local/ns/default/sa/client"}}}},
+		{name: "2: a quoted subject should keep its commas and escaped quotes", values: []string{`Hash=abc;Subject="CN=client,O=\"Acme, Inc\"";DNS=a.example;DNS=b.example`},
+			want: []XfccElement{{Hash: "abc", Subject: `CN=client,O="Acme, Inc"`, Dns: []string{"a.example", "b.example"}}}},
+		{name: "3: each proxy should give an element", values: []string{"Hash=one,Hash=two", "Hash=three"},
+			want: []XfccElement{{Hash: "one"}, {Hash: "two"}, {Hash: "three"}}},
+		{name: "4: a header without pairs should give nothing", values: []string{"garbage"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseXfcc(tt.values))
+		})
+	}
+}
+
+func TestGetWhoamiInfo(t *testing.T) {
+	claims := `{"sub":"bob","email":"bob@example.com"}`
+	unverified := "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".c2ln"
+	tests := []struct {
+		name    string
+		prepare func(r *http.Request) *http.Request
+		check   func(t *testing.T, got WhoamiInfo)
+	}{
+		{name: "1: a direct client should be its own ip chain", prepare: func(r *http.Request) *http.Request { return r },
+			check: func(t *testing.T, got WhoamiInfo) {
+				assert.Equal(t, []string{"192.0.2.10"}, got.IpChain)
+				assert.False(t, got.JwtVerified)
+				assert.Nil(t, got.TlsClient)
+			}},
+		{name: "2: the ip chain should end with the trusted proxy", prepare: func(r *http.Request) *http.Request {
+			r.Header.Set("X-Forwarded-For", "198.51.100.1, 10.0.0.5")
+			r = r.WithContext(context.WithValue(r.Context(), proxyAddrContextKey{}, "10.0.0.9:4000"))
+			r.RemoteAddr = "198.51.100.1:0"
+			return r
+		}, check: func(t *testing.T, got WhoamiInfo) {
+			assert.Equal(t, "198.51.100.1", got.ClientIp)
+			assert.Equal(t, []string{"198.51.100.1", "10.0.0.5", "10.0.0.9"}, got.IpChain)
+		}},
+		{name: "3: the basic auth user should be shown without its password", prepare: func(r *http.Request) *http.Request {
+			r.SetBasicAuth("admin", "secret")
+			return r
+		}, check: func(t *testing.T, got WhoamiInfo) {
+			assert.Equal(t, "admin", got.BasicAuthUser)
+		}},
+		{name: "4: a jwt validated by an ingress should be decoded but not verified", prepare: func(r *http.Request) *http.Request {
+			r.Header.Set("Authorization", "Bearer "+unverified)
+			return r
+		}, check: func(t *testing.T, got WhoamiInfo) {
+			assert.False(t, got.JwtVerified)
+			assert.Equal(t, JwtClaims{"sub": "bob"}, got.JwtClaims)
+		}},
+		{name: "5: the mesh identity should come from X-Forwarded-Client-Cert", prepare: func(r *http.Request) *http.Request {
+			r.Header.Set("X-Forwarded-Client-Cert", "URI=spiffe://cluster.local/ns/default/sa/client")
+			return r
+		}, check: func(t *testing.T, got WhoamiInfo) {
+			if assert.Len(t, got.MeshIdentity, 1) {
+				assert.Equal(t, []string{"spiffe://cluster.local/ns/default/sa/client"}, got.MeshIdentity[0].Uris)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+			r.RemoteAddr = "192.0.2.10:5555"
+			tt.check(t, GetWhoamiInfo(tt.prepare(r), AuthConfig{Mode: authModeNone, Claims: []string{"sub"}}))
+		})
+	}
+}
@@ -1,4 +1,4 @@
 #!/bin/bash
 rm test-report.json
 echo -n > test-report.json
-go test -coverprofile coverage.out -json . >> test-report.json
\ No newline at end of file
+go test -coverprofile coverage.out -json ./... >> test-report.json
\ No newline at end of file
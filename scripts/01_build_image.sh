@@ -27,7 +27,7 @@ if $DOCKER_BIN --version;
 then
   echo "## will use \"${DOCKER_BIN}\" to build the container image on linux "
   CONTAINER_REGISTRY_ID=laotseu
-  echo "## APP: ${APP_NAME}, version: ${APP_VERSION} detected in file server.go"
+  echo "## APP: ${APP_NAME}, version: ${APP_VERSION} detected in file pkg/info/buildinfo.go"
   IMAGE_FILTER="${CONTAINER_REGISTRY_ID}/${APP_NAME}"
   echo "## Checking if image:tag was already build in k8s namespace ${IMAGE_FILTER} tag:${APP_VERSION}"
   JSON_APP=$(${DOCKER_BIN} images --format '{{json .}}' | jq ".| select(.Repository | contains(\"${IMAGE_FILTER}\")) |select(.Tag | contains(\"${APP_VERSION}\"))")
@@ -46,7 +46,8 @@ then
       cd "$OLDPWD" || exit
       rm -rf "$TMP_Docker_Dir" # cleanup
       echo "will parse the multi-stage Dockerfile in the current directory and build the final image"
-      if ${DOCKER_BIN} build -t ${CONTAINER_REGISTRY_ID}/"${APP_NAME}" . ;
+      if ${DOCKER_BIN} build --build-arg APP_VERSION="${APP_VERSION}" --build-arg GIT_COMMIT="$(git rev-parse HEAD)" \
+        --build-arg BUILD_DATE="$(date -u +%Y-%m-%dT%H:%M:%SZ)" -t ${CONTAINER_REGISTRY_ID}/"${APP_NAME}" . ;
       then
         echo "will tag this image with version ${APP_VERSION}"
         ${DOCKER_BIN} tag ${CONTAINER_REGISTRY_ID}/"${APP_NAME}" ${CONTAINER_REGISTRY_ID}/"${APP_NAME}":"${APP_VERSION}"
@@ -66,7 +67,7 @@ then
     fi
   else
       echo "## 💥💥 ERROR: \"${IMAGE_FILTER}:${APP_VERSION}\" this image version is already build !"
-      echo "## 💥💥 ERROR: please upgrade version number in server.go file if you really want to rebuild !"
+      echo "## 💥💥 ERROR: please upgrade version number in pkg/info/buildinfo.go file if you really want to rebuild !"
       echo "## 💥💥 ERROR: or remove the image with : ${DOCKER_BIN} rmi ${CONTAINER_REGISTRY_ID}/${APP_NAME}"
       echo "${JSON_APP}" | jq '.'
   fi
@@ -1,9 +1,9 @@
 #!/bin/bash
 echo "## Extracting app name and version from source"
 DEPLOYMENT=k8s-deployment_with_docker.yml
-VERSION=`grep -E 'VERSION\s+=' server.go| awk '{ print $3 }'  | tr -d '"'`
-APPNAME=`grep -E 'APP\s+=' server.go| awk '{ print $3 }'  | tr -d '"'`
-echo "## APP: ${APPNAME}, version: ${VERSION} detected in file server.go"
+VERSION=`grep -E 'VERSION\s+=' pkg/info/buildinfo.go| awk '{ print $3 }'  | tr -d '"'`
+APPNAME=`grep -E 'APP\s+=' pkg/info/info.go| awk '{ print $3 }'  | tr -d '"'`
+echo "## APP: ${APPNAME}, version: ${VERSION} detected in file pkg/info/buildinfo.go"
 echo "## Listing relevant images in k8s namespace"
 docker images | grep ${APPNAME}
 TMP_K8S_CONFIG=$(mktemp -d)
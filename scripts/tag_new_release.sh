@@ -12,7 +12,7 @@ else
   echo "-- ERROR getAppInfo.sh was not found"
   exit 1
 fi
-echo "## APP: ${APP_NAME}, version: ${APP_VERSION} detected in file server.go"
+echo "## APP: ${APP_NAME}, version: ${APP_VERSION} detected in file pkg/info/buildinfo.go"
 if [ $(git tag -l "v$APP_VERSION") ]; then
     echo "## 💥💥 ERROR: \"${APP_NAME} tag ${APP_VERSION} \" already exist !"
 else